@@ -6,6 +6,7 @@
 package attrmgr
 
 import (
+	"crypto"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
@@ -42,10 +43,22 @@ type AttributeRequest interface {
 }
 
 // New constructs an attribute manager
-func New() *Mgr { return &Mgr{} }
+func New(opts ...Option) *Mgr {
+	mgr := &Mgr{}
+	for _, opt := range opts {
+		opt(mgr)
+	}
+	return mgr
+}
 
 // Mgr is the attribute manager and is the main object for this package
-type Mgr struct{}
+type Mgr struct {
+	// verificationKeys are the keys of the signers of attributes set with
+	// WithVerificationKeys.
+	verificationKeys []crypto.PublicKey
+	// requireSignature is set by WithRequiredSignature.
+	requireSignature bool
+}
 
 // ProcessAttributeRequestsForCert add attributes to an X509 certificate, given
 // attribute requests and attributes.
@@ -100,7 +113,12 @@ func (mgr *Mgr) AddAttributesToCert(attrs *Attributes, cert *x509.Certificate) e
 	return nil
 }
 
-// GetAttributesFromCert gets the attributes from a certificate.
+// GetAttributesFromCert gets the attributes from a certificate. If the
+// attribute manager has verification keys, the signature over the
+// attributes is verified and ErrInvalidAttributeSignature returned if it is
+// not valid; the Signature of the attributes tells whether it was present.
+// With WithRequiredSignature, ErrInvalidAttributeSignature is also returned
+// when the signature is missing.
 func (mgr *Mgr) GetAttributesFromCert(cert *x509.Certificate) (*Attributes, error) {
 	// Get certificate attributes from the certificate if it exists
 	buf, err := getAttributesFromCert(cert)
@@ -114,6 +132,10 @@ func (mgr *Mgr) GetAttributesFromCert(cert *x509.Certificate) (*Attributes, erro
 		if err != nil {
 			return nil, fmt.Errorf("failed to unmarshal attributes from certificate: %s", err)
 		}
+		attrs.Signature, err = mgr.verifySignature(cert, buf)
+		if err != nil {
+			return nil, err
+		}
 	}
 	return attrs, nil
 }
@@ -170,6 +192,9 @@ func (mgr *Mgr) GetAttributesFromIdemix(creator []byte) (*Attributes, error) {
 // Attributes contains attribute names and values
 type Attributes struct {
 	Attrs map[string]string `json:"attrs"`
+	// Signature is the status of the signature over the attributes, when
+	// they were read from a certificate by GetAttributesFromCert.
+	Signature SignatureStatus `json:"-"`
 }
 
 // Names returns the names of the attributes
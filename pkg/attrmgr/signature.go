@@ -0,0 +1,159 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package attrmgr
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	// AttrSignatureOID is the ASN.1 object identifier for the extension of an
+	// X509 certificate holding a signature over its attribute extension
+	AttrSignatureOID = asn1.ObjectIdentifier{1, 2, 3, 4, 5, 6, 7, 8, 2}
+	// AttrSignatureOIDString is the string version of AttrSignatureOID
+	AttrSignatureOIDString = "1.2.3.4.5.6.7.8.2"
+
+	// ErrInvalidAttributeSignature is returned by GetAttributesFromCert when
+	// the signature over the attributes of a certificate is not valid for
+	// any of the verification keys of the attribute manager, or is missing
+	// while it is required.
+	ErrInvalidAttributeSignature = errors.New("the signature over the attributes of the certificate is not valid")
+)
+
+// SignatureStatus is the result of verifying the signature over the
+// attributes of a certificate.
+type SignatureStatus int
+
+const (
+	// SignatureNotChecked means that the signature was not verified, because
+	// the attribute manager has no verification keys or the attributes do
+	// not come from an X509 certificate.
+	SignatureNotChecked SignatureStatus = iota
+	// SignatureMissing means that the certificate has no signature over its
+	// attributes.
+	SignatureMissing
+	// SignatureValid means that the signature over the attributes is valid
+	// for one of the verification keys.
+	SignatureValid
+)
+
+func (s SignatureStatus) String() string {
+	switch s {
+	case SignatureNotChecked:
+		return "not checked"
+	case SignatureMissing:
+		return "missing"
+	case SignatureValid:
+		return "valid"
+	default:
+		return fmt.Sprintf("SignatureStatus(%d)", int(s))
+	}
+}
+
+// Option configures an attribute manager.
+type Option func(*Mgr)
+
+// WithVerificationKeys makes the attribute manager verify the signature
+// over the attributes of certificates, added with SignAttributes by CA
+// integrations that do not otherwise protect the attributes they issue.
+// The ECDSA and RSA public keys of the signers are supported.
+func WithVerificationKeys(keys ...crypto.PublicKey) Option {
+	return func(mgr *Mgr) {
+		mgr.verificationKeys = append(mgr.verificationKeys, keys...)
+	}
+}
+
+// WithRequiredSignature makes the attribute manager reject the attributes
+// of certificates without a valid signature by one of its verification
+// keys, rather than returning them with a SignatureMissing status that the
+// caller must check. Without verification keys, no attributes are accepted.
+func WithRequiredSignature() Option {
+	return func(mgr *Mgr) {
+		mgr.requireSignature = true
+	}
+}
+
+// SignAttributes adds a signature by signer over the attribute extension
+// added to cert with AddAttributesToCert. The signature is the ASN.1 DER
+// encoded ECDSA signature or the PKCS #1 v1.5 RSA signature of the SHA-256
+// digest of the value of the attribute extension.
+func (mgr *Mgr) SignAttributes(cert *x509.Certificate, signer crypto.Signer) error {
+	buf, err := getAttributesFromCert(cert)
+	if err != nil {
+		return err
+	}
+	if buf == nil {
+		return errors.New("the certificate has no attributes to sign")
+	}
+	digest := sha256.Sum256(buf)
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("failed to sign attributes: %s", err)
+	}
+	ext := pkix.Extension{
+		Id:       AttrSignatureOID,
+		Critical: false,
+		Value:    sig,
+	}
+	cert.Extensions = append(cert.Extensions, ext)
+	return nil
+}
+
+// verifySignature returns the status of the signature over attrs, the value
+// of the attribute extension of cert.
+func (mgr *Mgr) verifySignature(cert *x509.Certificate, attrs []byte) (SignatureStatus, error) {
+	if len(mgr.verificationKeys) == 0 {
+		if mgr.requireSignature {
+			return SignatureNotChecked, ErrInvalidAttributeSignature
+		}
+		return SignatureNotChecked, nil
+	}
+	var sig []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(AttrSignatureOID) {
+			sig = ext.Value
+		}
+	}
+	if sig == nil {
+		if mgr.requireSignature {
+			return SignatureMissing, ErrInvalidAttributeSignature
+		}
+		return SignatureMissing, nil
+	}
+	digest := sha256.Sum256(attrs)
+	for _, key := range mgr.verificationKeys {
+		if verify(key, digest[:], sig) {
+			return SignatureValid, nil
+		}
+	}
+	return SignatureNotChecked, ErrInvalidAttributeSignature
+}
+
+// verify reports whether sig is a valid signature of digest by the holder
+// of key.
+func verify(key crypto.PublicKey, digest, sig []byte) bool {
+	switch key := key.(type) {
+	case *ecdsa.PublicKey:
+		var esig struct{ R, S *big.Int }
+		rest, err := asn1.Unmarshal(sig, &esig)
+		if err != nil || len(rest) != 0 || esig.R == nil || esig.S == nil {
+			return false
+		}
+		return ecdsa.Verify(key, digest, esig.R, esig.S)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, digest, sig) == nil
+	default:
+		return false
+	}
+}
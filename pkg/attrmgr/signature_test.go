@@ -0,0 +1,123 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package attrmgr_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signedCert(t *testing.T, signer crypto.Signer) *x509.Certificate {
+	mgr := attrmgr.New()
+	cert := &x509.Certificate{}
+	attrs := &attrmgr.Attributes{Attrs: map[string]string{"role": "auditor"}}
+	require.NoError(t, mgr.AddAttributesToCert(attrs, cert))
+	require.NoError(t, mgr.SignAttributes(cert, signer))
+	return cert
+}
+
+func TestSignAttributes(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name   string
+		signer crypto.Signer
+		keys   []crypto.PublicKey
+		status attrmgr.SignatureStatus
+		err    error
+	}{
+		{name: "ECDSA", signer: ecKey, keys: []crypto.PublicKey{&ecKey.PublicKey}, status: attrmgr.SignatureValid},
+		{name: "RSA", signer: rsaKey, keys: []crypto.PublicKey{&rsaKey.PublicKey}, status: attrmgr.SignatureValid},
+		{name: "Second Key", signer: ecKey, keys: []crypto.PublicKey{&otherKey.PublicKey, &ecKey.PublicKey}, status: attrmgr.SignatureValid},
+		{name: "Not Checked", signer: ecKey, status: attrmgr.SignatureNotChecked},
+		{name: "Other Key", signer: ecKey, keys: []crypto.PublicKey{&otherKey.PublicKey, &rsaKey.PublicKey}, err: attrmgr.ErrInvalidAttributeSignature},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cert := signedCert(t, test.signer)
+			attrs, err := attrmgr.New(attrmgr.WithVerificationKeys(test.keys...)).GetAttributesFromCert(cert)
+			if test.err != nil {
+				assert.Equal(t, test.err, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.status, attrs.Signature)
+			checkAttr(t, "role", "auditor", attrs)
+		})
+	}
+}
+
+func TestTamperedAttributes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := signedCert(t, key)
+	for i, ext := range cert.Extensions {
+		if ext.Id.Equal(attrmgr.AttrOID) {
+			cert.Extensions[i].Value = []byte(`{"attrs":{"role":"admin"}}`)
+		}
+	}
+
+	_, err = attrmgr.New(attrmgr.WithVerificationKeys(&key.PublicKey)).GetAttributesFromCert(cert)
+	assert.Equal(t, attrmgr.ErrInvalidAttributeSignature, err)
+}
+
+func TestUnsignedAttributes(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	mgr := attrmgr.New(attrmgr.WithVerificationKeys(&key.PublicKey))
+	cert := &x509.Certificate{}
+	require.NoError(t, mgr.AddAttributesToCert(&attrmgr.Attributes{Attrs: map[string]string{"role": "auditor"}}, cert))
+
+	attrs, err := mgr.GetAttributesFromCert(cert)
+	require.NoError(t, err)
+	assert.Equal(t, attrmgr.SignatureMissing, attrs.Signature)
+	assert.Equal(t, "missing", attrs.Signature.String())
+
+	err = mgr.SignAttributes(&x509.Certificate{}, key)
+	assert.EqualError(t, err, "the certificate has no attributes to sign")
+}
+
+func TestRequiredSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	mgr := attrmgr.New(attrmgr.WithVerificationKeys(&key.PublicKey), attrmgr.WithRequiredSignature())
+
+	attrs, err := mgr.GetAttributesFromCert(signedCert(t, key))
+	require.NoError(t, err)
+	assert.Equal(t, attrmgr.SignatureValid, attrs.Signature)
+
+	_, err = mgr.GetAttributesFromCert(signedCert(t, otherKey))
+	assert.Equal(t, attrmgr.ErrInvalidAttributeSignature, err)
+
+	unsigned := &x509.Certificate{}
+	require.NoError(t, mgr.AddAttributesToCert(&attrmgr.Attributes{Attrs: map[string]string{"role": "auditor"}}, unsigned))
+	_, err = mgr.GetAttributesFromCert(unsigned)
+	assert.Equal(t, attrmgr.ErrInvalidAttributeSignature, err)
+
+	// without verification keys, no signature can be valid
+	_, err = attrmgr.New(attrmgr.WithRequiredSignature()).GetAttributesFromCert(signedCert(t, key))
+	assert.Equal(t, attrmgr.ErrInvalidAttributeSignature, err)
+
+	// certificates without attributes have no signature to check
+	attrs, err = mgr.GetAttributesFromCert(&x509.Certificate{})
+	require.NoError(t, err)
+	assert.Empty(t, attrs.Attrs)
+}
@@ -0,0 +1,147 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+const (
+	// AuditEventName is the name of the chaincode event set by the sink
+	// returned by NewEventAuditSink.
+	AuditEventName = "cid.audit"
+	// AuditObjectType is the object type of the composite keys written by
+	// the sink returned by NewStateAuditSink.
+	AuditObjectType = "cid.audit"
+)
+
+// AccessDecision records the outcome of an authorization check made by a
+// ClientIdentity.
+type AccessDecision struct {
+	Allowed bool `json:"allowed"`
+	// Rule describes the check, e.g. "attribute role == admin".
+	Rule  string `json:"rule"`
+	MSPID string `json:"msp_id"`
+	// IdentityDigest is the hex encoded SHA-256 hash of the serialized
+	// identity of the invoker.
+	IdentityDigest string `json:"identity_digest"`
+	// Reason explains a denial.
+	Reason string `json:"reason,omitempty"`
+}
+
+// AuditSink records access decisions. An error returned by Record is
+// returned by the check, which then fails even if access was allowed.
+type AuditSink interface {
+	Record(decision *AccessDecision) error
+}
+
+// Option configures a ClientIdentity returned by New.
+type Option func(*clientIdentityImpl)
+
+// WithAuditSink records every decision made by AssertAttributeValue in
+// sink.
+func WithAuditSink(sink AuditSink) Option {
+	return func(c *clientIdentityImpl) {
+		c.auditSink = sink
+	}
+}
+
+// EventStub is the subset of the chaincode stub used by the sink returned by
+// NewEventAuditSink.
+type EventStub interface {
+	SetEvent(name string, payload []byte) error
+}
+
+type eventAuditSink struct {
+	stub      EventStub
+	mutex     sync.Mutex
+	decisions []*AccessDecision
+}
+
+// NewEventAuditSink returns a sink setting an AuditEventName event whose
+// payload is the JSON array of the decisions recorded so far. A transaction
+// has a single event, so the audit event replaces any event set by the
+// chaincode and vice versa; use a sink per transaction.
+func NewEventAuditSink(stub EventStub) AuditSink {
+	return &eventAuditSink{stub: stub}
+}
+
+func (s *eventAuditSink) Record(decision *AccessDecision) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	payload, err := json.Marshal(append(s.decisions, decision))
+	if err != nil {
+		return err
+	}
+	if err := s.stub.SetEvent(AuditEventName, payload); err != nil {
+		return err
+	}
+	s.decisions = append(s.decisions, decision)
+	return nil
+}
+
+// StateStub is the subset of the chaincode stub used by the sink returned by
+// NewStateAuditSink.
+type StateStub interface {
+	GetTxID() string
+	CreateCompositeKey(objectType string, attributes []string) (string, error)
+	PutState(key string, value []byte) error
+}
+
+type stateAuditSink struct {
+	stub  StateStub
+	mutex sync.Mutex
+	seq   int
+}
+
+// NewStateAuditSink returns a sink writing each decision as JSON under the
+// composite key (AuditObjectType, txid, sequence number). Use a sink per
+// transaction.
+func NewStateAuditSink(stub StateStub) AuditSink {
+	return &stateAuditSink{stub: stub}
+}
+
+func (s *stateAuditSink) Record(decision *AccessDecision) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key, err := s.stub.CreateCompositeKey(AuditObjectType, []string{s.stub.GetTxID(), strconv.Itoa(s.seq)})
+	if err != nil {
+		return err
+	}
+	value, err := json.Marshal(decision)
+	if err != nil {
+		return err
+	}
+	if err := s.stub.PutState(key, value); err != nil {
+		return err
+	}
+	s.seq++
+	return nil
+}
+
+// audit records a decision about rule, denied if err is not nil, and
+// returns err or the error recording the decision.
+func (c *clientIdentityImpl) audit(rule string, err error) error {
+	if c.auditSink == nil {
+		return err
+	}
+	decision := &AccessDecision{
+		Allowed:        err == nil,
+		Rule:           rule,
+		MSPID:          c.mspID,
+		IdentityDigest: c.identityDigest,
+	}
+	if err != nil {
+		decision.Reason = err.Error()
+	}
+	if recErr := c.auditSink.Record(decision); recErr != nil {
+		return fmt.Errorf("failed to record access decision: %s", recErr)
+	}
+	return err
+}
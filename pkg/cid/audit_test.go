@@ -0,0 +1,95 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type recordingSink struct {
+	decisions []*cid.AccessDecision
+	err       error
+}
+
+func (s *recordingSink) Record(decision *cid.AccessDecision) error {
+	s.decisions = append(s.decisions, decision)
+	return s.err
+}
+
+func newAuditStub(t *testing.T) (*shimtest.MockStub, string) {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "SampleOrg", IdBytes: []byte(certWithAttrs)})
+	require.NoError(t, err)
+	digest := sha256.Sum256(creator)
+	return shimtest.NewStubBuilder().WithCreator(creator).Build(), hex.EncodeToString(digest[:])
+}
+
+func TestWithAuditSink(t *testing.T) {
+	stub, digest := newAuditStub(t)
+	sink := &recordingSink{}
+	c, err := cid.New(stub, cid.WithAuditSink(sink))
+	require.NoError(t, err)
+
+	assert.NoError(t, c.AssertAttributeValue("attr1", "val1"))
+	assert.Error(t, c.AssertAttributeValue("attr1", "val2"))
+	assert.Equal(t, []*cid.AccessDecision{
+		{Allowed: true, Rule: "attribute attr1 == val1", MSPID: "SampleOrg", IdentityDigest: digest},
+		{Allowed: false, Rule: "attribute attr1 == val2", MSPID: "SampleOrg", IdentityDigest: digest, Reason: "attribute 'attr1' equals 'val1', not 'val2'"},
+	}, sink.decisions)
+
+	// a decision that cannot be recorded denies access
+	sink.err = errors.New("boom")
+	err = c.AssertAttributeValue("attr1", "val1")
+	assert.EqualError(t, err, "failed to record access decision: boom")
+}
+
+func TestEventAuditSink(t *testing.T) {
+	stub, digest := newAuditStub(t)
+	stub.MockTransactionStart("tx1")
+	c, err := cid.New(stub, cid.WithAuditSink(cid.NewEventAuditSink(stub)))
+	require.NoError(t, err)
+	assert.NoError(t, c.AssertAttributeValue("attr1", "val1"))
+	assert.Error(t, c.AssertAttributeValue("missing", "x"))
+	stub.MockTransactionEnd("tx1")
+
+	events := stub.ChaincodeEventsForTx("tx1")
+	require.Len(t, events, 2)
+	assert.Equal(t, cid.AuditEventName, events[1].EventName)
+
+	var decisions []*cid.AccessDecision
+	require.NoError(t, json.Unmarshal(events[1].Payload, &decisions))
+	assert.Equal(t, []*cid.AccessDecision{
+		{Allowed: true, Rule: "attribute attr1 == val1", MSPID: "SampleOrg", IdentityDigest: digest},
+		{Allowed: false, Rule: "attribute missing == x", MSPID: "SampleOrg", IdentityDigest: digest, Reason: "attribute 'missing' was not found"},
+	}, decisions)
+}
+
+func TestStateAuditSink(t *testing.T) {
+	stub, digest := newAuditStub(t)
+	stub.MockTransactionStart("tx1")
+	c, err := cid.New(stub, cid.WithAuditSink(cid.NewStateAuditSink(stub)))
+	require.NoError(t, err)
+	assert.NoError(t, c.AssertAttributeValue("attr1", "val1"))
+	assert.Error(t, c.AssertAttributeValue("attr1", "val2"))
+	stub.MockTransactionEnd("tx1")
+
+	for i, allowed := range []bool{true, false} {
+		key, err := stub.CreateCompositeKey(cid.AuditObjectType, []string{"tx1", []string{"0", "1"}[i]})
+		require.NoError(t, err)
+		decision := &cid.AccessDecision{}
+		require.NoError(t, json.Unmarshal(stub.State[key], decision))
+		assert.Equal(t, allowed, decision.Allowed)
+		assert.Equal(t, digest, decision.IdentityDigest)
+	}
+}
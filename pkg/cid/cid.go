@@ -4,6 +4,8 @@
 package cid
 
 import (
+	"crypto"
+	"crypto/sha256"
 	"crypto/x509"
 	"crypto/x509/pkix"
 	"encoding/asn1"
@@ -71,11 +73,19 @@ type clientIdentityImpl struct {
 	mspID string
 	cert  *x509.Certificate
 	attrs *attrmgr.Attributes
+
+	identityDigest    string
+	auditSink         AuditSink
+	revocationChecker RevocationChecker
+	attributeOptions  []attrmgr.Option
 }
 
 // New returns an instance of ClientIdentity
-func New(stub ChaincodeStubInterface) (ClientIdentity, error) {
+func New(stub ChaincodeStubInterface, opts ...Option) (ClientIdentity, error) {
 	c := &clientIdentityImpl{stub: stub}
+	for _, opt := range opts {
+		opt(c)
+	}
 	err := c.init()
 	if err != nil {
 		return nil, err
@@ -83,6 +93,16 @@ func New(stub ChaincodeStubInterface) (ClientIdentity, error) {
 	return c, nil
 }
 
+// WithAttributeVerificationKeys makes New reject invokers identified by an
+// X509 certificate whose attributes are not signed by one of keys, as added
+// with attrmgr.SignAttributes. Certificates without attributes and the
+// attributes of idemix credentials are not affected.
+func WithAttributeVerificationKeys(keys ...crypto.PublicKey) Option {
+	return func(c *clientIdentityImpl) {
+		c.attributeOptions = append(c.attributeOptions, attrmgr.WithVerificationKeys(keys...), attrmgr.WithRequiredSignature())
+	}
+}
+
 // GetID returns a unique ID associated with the invoking identity.
 func (c *clientIdentityImpl) GetID() (string, error) {
 	// When IdeMix, c.cert is nil for x509 type
@@ -113,6 +133,11 @@ func (c *clientIdentityImpl) GetAttributeValue(attrName string) (value string, f
 
 // AssertAttributeValue checks to see if an attribute value equals the specified value
 func (c *clientIdentityImpl) AssertAttributeValue(attrName, attrValue string) error {
+	rule := fmt.Sprintf("attribute %s == %s", attrName, attrValue)
+	return c.audit(rule, c.assertAttributeValue(attrName, attrValue))
+}
+
+func (c *clientIdentityImpl) assertAttributeValue(attrName, attrValue string) error {
 	val, ok, err := c.GetAttributeValue(attrName)
 	if err != nil {
 		return err
@@ -153,7 +178,10 @@ func (c *clientIdentityImpl) init() error {
 		return fmt.Errorf("failed to parse certificate: %s", err)
 	}
 	c.cert = cert
-	attrs, err := attrmgr.New().GetAttributesFromCert(cert)
+	if err := c.checkRevocation(); err != nil {
+		return err
+	}
+	attrs, err := attrmgr.New(c.attributeOptions...).GetAttributesFromCert(cert)
 	if err != nil {
 		return fmt.Errorf("failed to get attributes from the transaction invoker's certificate: %s", err)
 	}
@@ -173,6 +201,8 @@ func (c *clientIdentityImpl) getIdentity() (*msp.SerializedIdentity, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal transaction invoker's identity: %s", err)
 	}
+	digest := sha256.Sum256(creator)
+	c.identityDigest = hex.EncodeToString(digest[:])
 	return sid, nil
 }
 
@@ -4,13 +4,22 @@
 package cid_test
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
 	"encoding/base64"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
 	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
 	"github.com/hyperledger/fabric-protos-go/msp"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const certWithOutAttrs = `-----BEGIN CERTIFICATE-----
@@ -120,6 +129,52 @@ func TestIdemix(t *testing.T) {
 	assert.False(t, found, "Attribute 'id' should not be found in the submitter cert")
 }
 
+func TestWithAttributeVerificationKeys(t *testing.T) {
+	ca := newTestCA(t, "ca.org1.example.com")
+	signer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	// issue returns the certificate of a user with the attribute role,
+	// signed by key if it is not nil
+	issue := func(serial int64, key *ecdsa.PrivateKey) []byte {
+		mgr := attrmgr.New()
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			Subject:      pkix.Name{CommonName: "user1"},
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+		}
+		require.NoError(t, mgr.AddAttributesToCert(&attrmgr.Attributes{Attrs: map[string]string{"role": "admin"}}, template))
+		if key != nil {
+			require.NoError(t, mgr.SignAttributes(template, key))
+		}
+		template.ExtraExtensions = template.Extensions
+		return ca.sign(t, template)
+	}
+	opt := cid.WithAttributeVerificationKeys(&signer.PublicKey)
+
+	c, err := cid.New(newCreatorStub(t, issue(2, signer)), opt)
+	require.NoError(t, err)
+	assert.NoError(t, c.AssertAttributeValue("role", "admin"))
+
+	const invalid = "failed to get attributes from the transaction invoker's certificate: the signature over the attributes of the certificate is not valid"
+	_, err = cid.New(newCreatorStub(t, issue(3, nil)), opt)
+	assert.EqualError(t, err, invalid)
+	_, err = cid.New(newCreatorStub(t, issue(4, other)), opt)
+	assert.EqualError(t, err, invalid)
+
+	// unsigned attributes are accepted without verification keys
+	c, err = cid.New(newCreatorStub(t, issue(5, nil)))
+	require.NoError(t, err)
+	assert.NoError(t, c.AssertAttributeValue("role", "admin"))
+
+	// certificates without attributes are not affected
+	_, err = cid.New(newCreatorStub(t, ca.issue(t, 6)), opt)
+	assert.NoError(t, err)
+}
+
 func getMockStub() (cid.ChaincodeStubInterface, error) {
 	stub := &mockStub{}
 	sid := &msp.SerializedIdentity{Mspid: "SampleOrg",
@@ -0,0 +1,81 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"net"
+	"net/url"
+)
+
+// SubjectAltNames are the subject alternative names of a certificate.
+type SubjectAltNames struct {
+	DNSNames       []string
+	EmailAddresses []string
+	IPAddresses    []net.IP
+	URIs           []*url.URL
+}
+
+// GetExtension returns the value of the extension identified by oid in the
+// X509 certificate of the invoking identity, if it has one.
+func GetExtension(stub ChaincodeStubInterface, oid asn1.ObjectIdentifier) (value []byte, found bool, err error) {
+	c, err := New(stub)
+	if err != nil {
+		return nil, false, err
+	}
+	value, found = c.GetExtension(oid)
+	return value, found, nil
+}
+
+// GetExtension returns the DER encoded value of the extension identified by
+// oid in the X509 certificate of the client. found is false if the client
+// was not identified by an X509 certificate or the certificate does not
+// have the extension.
+func (c *clientIdentityImpl) GetExtension(oid asn1.ObjectIdentifier) (value []byte, found bool) {
+	if c.cert == nil {
+		return nil, false
+	}
+	for _, ext := range c.cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return ext.Value, true
+		}
+	}
+	return nil, false
+}
+
+// GetSubjectAltNames returns the subject alternative names of the X509
+// certificate of the client, which are empty if it was not identified by
+// an X509 certificate.
+func (c *clientIdentityImpl) GetSubjectAltNames() SubjectAltNames {
+	if c.cert == nil {
+		return SubjectAltNames{}
+	}
+	return SubjectAltNames{
+		DNSNames:       c.cert.DNSNames,
+		EmailAddresses: c.cert.EmailAddresses,
+		IPAddresses:    c.cert.IPAddresses,
+		URIs:           c.cert.URIs,
+	}
+}
+
+// HasKeyUsage reports whether the X509 certificate of the client allows
+// all of the key usages in usage.
+func (c *clientIdentityImpl) HasKeyUsage(usage x509.KeyUsage) bool {
+	return c.cert != nil && c.cert.KeyUsage&usage == usage
+}
+
+// HasExtKeyUsage reports whether the X509 certificate of the client allows
+// the extended key usage, either explicitly or with x509.ExtKeyUsageAny.
+func (c *clientIdentityImpl) HasExtKeyUsage(usage x509.ExtKeyUsage) bool {
+	if c.cert == nil {
+		return false
+	}
+	for _, u := range c.cert.ExtKeyUsage {
+		if u == usage || u == x509.ExtKeyUsageAny {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,99 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid_test
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var roleOID = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 99999, 1}
+
+func TestExtensions(t *testing.T) {
+	role, err := asn1.Marshal("auditor")
+	require.NoError(t, err)
+	uri, err := url.Parse("spiffe://org1.example.com/user1")
+	require.NoError(t, err)
+
+	ca := newTestCA(t, "ca.org1.example.com")
+	cert := ca.sign(t, &x509.Certificate{
+		SerialNumber:    big.NewInt(2),
+		Subject:         pkix.Name{CommonName: "user1"},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(time.Hour),
+		KeyUsage:        x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		DNSNames:        []string{"user1.org1.example.com"},
+		EmailAddresses:  []string{"user1@org1.example.com"},
+		IPAddresses:     []net.IP{net.ParseIP("10.0.0.1")},
+		URIs:            []*url.URL{uri},
+		ExtraExtensions: []pkix.Extension{{Id: roleOID, Value: role}},
+	})
+	stub := newCreatorStub(t, cert)
+
+	value, found, err := cid.GetExtension(stub, roleOID)
+	require.NoError(t, err)
+	assert.True(t, found)
+	var decoded string
+	_, err = asn1.Unmarshal(value, &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, "auditor", decoded)
+
+	_, found, err = cid.GetExtension(stub, asn1.ObjectIdentifier{1, 2, 3})
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	c, err := cid.New(stub)
+	require.NoError(t, err)
+	names := c.GetSubjectAltNames()
+	assert.Equal(t, []string{"user1.org1.example.com"}, names.DNSNames)
+	assert.Equal(t, []string{"user1@org1.example.com"}, names.EmailAddresses)
+	require.Len(t, names.IPAddresses, 1)
+	assert.True(t, names.IPAddresses[0].Equal(net.ParseIP("10.0.0.1")))
+	require.Len(t, names.URIs, 1)
+	assert.Equal(t, uri.String(), names.URIs[0].String())
+
+	assert.True(t, c.HasKeyUsage(x509.KeyUsageDigitalSignature))
+	assert.True(t, c.HasKeyUsage(x509.KeyUsageDigitalSignature|x509.KeyUsageKeyEncipherment))
+	assert.False(t, c.HasKeyUsage(x509.KeyUsageDigitalSignature|x509.KeyUsageCertSign))
+	assert.True(t, c.HasExtKeyUsage(x509.ExtKeyUsageClientAuth))
+	assert.False(t, c.HasExtKeyUsage(x509.ExtKeyUsageServerAuth))
+}
+
+func TestExtKeyUsageAny(t *testing.T) {
+	ca := newTestCA(t, "ca.org1.example.com")
+	cert := ca.sign(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "user1"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	c, err := cid.New(newCreatorStub(t, cert))
+	require.NoError(t, err)
+	assert.True(t, c.HasExtKeyUsage(x509.ExtKeyUsageServerAuth))
+}
+
+func TestExtensionsIdemix(t *testing.T) {
+	stub, err := getIdemixMockStubWithAttrs()
+	require.NoError(t, err)
+	c, err := cid.New(stub)
+	require.NoError(t, err)
+
+	_, found := c.GetExtension(roleOID)
+	assert.False(t, found)
+	assert.Equal(t, cid.SubjectAltNames{}, c.GetSubjectAltNames())
+	assert.False(t, c.HasKeyUsage(x509.KeyUsageDigitalSignature))
+	assert.False(t, c.HasExtKeyUsage(x509.ExtKeyUsageClientAuth))
+}
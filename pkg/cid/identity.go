@@ -0,0 +1,64 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// creatorStub is a ChaincodeStubInterface returning a creator.
+type creatorStub []byte
+
+func (s creatorStub) GetCreator() ([]byte, error) {
+	return s, nil
+}
+
+// UniqueKey returns a key identifying the invoker, stable across
+// transactions; see ClientIdentity.UniqueKey.
+func UniqueKey(stub ChaincodeStubInterface) (string, error) {
+	c, err := New(stub)
+	if err != nil {
+		return "", err
+	}
+	return c.UniqueKey()
+}
+
+// Same reports whether the serialized identities a and b, as returned by
+// GetCreator in two transactions, identify the same client: they belong to
+// the same MSP and have the same UniqueKey. The certificates of a client
+// re-enrolled or renewed with the same subject by the same issuer are the
+// same client. Identities which are not X509 certificates, such as idemix
+// credentials, are only the same if they are identical.
+func Same(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	ka, err := UniqueKey(creatorStub(a))
+	if err != nil {
+		return false
+	}
+	kb, err := UniqueKey(creatorStub(b))
+	if err != nil {
+		return false
+	}
+	return ka == kb
+}
+
+// UniqueKey returns a key identifying the client across transactions,
+// suitable for storing as the owner of an asset. It is the MSP ID followed
+// by the hex encoded SHA-256 hash of the subject and issuer of the
+// certificate, so it does not change when the certificate is renewed or
+// the client re-enrolls with the same subject, but does when the issuing
+// CA changes. Like GetID, it fails for identities without an X509
+// certificate.
+func (c *clientIdentityImpl) UniqueKey() (string, error) {
+	if c.cert == nil {
+		return "", fmt.Errorf("cannot determine identity")
+	}
+	digest := sha256.Sum256([]byte(fmt.Sprintf("x509::%s::%s", getDN(&c.cert.Subject), getDN(&c.cert.Issuer))))
+	return c.mspID + ":" + hex.EncodeToString(digest[:]), nil
+}
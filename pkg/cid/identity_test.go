@@ -0,0 +1,71 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func serializedIdentity(t *testing.T, mspID string, idBytes []byte) []byte {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: idBytes})
+	require.NoError(t, err)
+	return creator
+}
+
+func TestSame(t *testing.T) {
+	ca := newTestCA(t, "ca.org1.example.com")
+	otherCA := newTestCA(t, "ca.org2.example.com")
+	user1 := serializedIdentity(t, "Org1MSP", ca.issue(t, 2))
+	idemix, err := base64.StdEncoding.DecodeString(idemixCred)
+	require.NoError(t, err)
+
+	var tests = []struct {
+		name string
+		a, b []byte
+		same bool
+	}{
+		{name: "Identical", a: user1, b: user1, same: true},
+		{name: "Renewed", a: user1, b: serializedIdentity(t, "Org1MSP", ca.issue(t, 3)), same: true},
+		{name: "Other Subject", a: user1, b: serializedIdentity(t, "Org1MSP", ca.issueFor(t, 3, "user2")), same: false},
+		{name: "Other Issuer", a: user1, b: serializedIdentity(t, "Org1MSP", otherCA.issue(t, 2)), same: false},
+		{name: "Other MSP", a: user1, b: serializedIdentity(t, "Org2MSP", ca.issue(t, 2)), same: false},
+		{name: "Identical Idemix", a: idemix, b: idemix, same: true},
+		{name: "Idemix", a: user1, b: idemix, same: false},
+		{name: "Malformed", a: user1, b: []byte("garbage"), same: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.same, cid.Same(test.a, test.b))
+			assert.Equal(t, test.same, cid.Same(test.b, test.a))
+		})
+	}
+}
+
+func TestUniqueKey(t *testing.T) {
+	ca := newTestCA(t, "ca.org1.example.com")
+	stub := shimtest.NewStubBuilder().WithCreator(serializedIdentity(t, "Org1MSP", ca.issue(t, 2))).Build()
+	key, err := cid.UniqueKey(stub)
+	require.NoError(t, err)
+	assert.Regexp(t, "^Org1MSP:[0-9a-f]{64}$", key)
+
+	c, err := cid.New(shimtest.NewStubBuilder().WithCreator(serializedIdentity(t, "Org1MSP", ca.issue(t, 3))).Build())
+	require.NoError(t, err)
+	renewed, err := c.UniqueKey()
+	assert.NoError(t, err)
+	assert.Equal(t, key, renewed, "the key is stable across renewals")
+
+	idemix, err := getIdemixMockStubWithAttrs()
+	require.NoError(t, err)
+	_, err = cid.UniqueKey(idemix)
+	assert.EqualError(t, err, "cannot determine identity")
+}
@@ -3,7 +3,10 @@
 
 package cid
 
-import "crypto/x509"
+import (
+	"crypto/x509"
+	"encoding/asn1"
+)
 
 // ChaincodeStubInterface is used by deployable chaincode apps to get identity
 // of the  agent (or user) submitting the transaction.
@@ -22,6 +25,11 @@ type ClientIdentity interface {
 	// is guaranteed to be unique within the MSP.
 	GetID() (string, error)
 
+	// UniqueKey returns a key identifying the invoking identity across MSPs,
+	// which does not change when its certificate is renewed with the same
+	// subject and issuer.
+	UniqueKey() (string, error)
+
 	// Return the MSP ID of the client
 	GetMSPID() (string, error)
 
@@ -39,4 +47,22 @@ type ClientIdentity interface {
 	// GetX509Certificate returns the X509 certificate associated with the client,
 	// or nil if it was not identified by an X509 certificate.
 	GetX509Certificate() (*x509.Certificate, error)
+
+	// GetExtension returns the DER encoded value of the extension identified
+	// by `oid` in the client's X509 certificate. If the client was not
+	// identified by an X509 certificate or the certificate does not have the
+	// extension, `found` is false.
+	GetExtension(oid asn1.ObjectIdentifier) (value []byte, found bool)
+
+	// GetSubjectAltNames returns the subject alternative names of the client's
+	// X509 certificate.
+	GetSubjectAltNames() SubjectAltNames
+
+	// HasKeyUsage reports whether the client's X509 certificate allows all the
+	// key usages in `usage`.
+	HasKeyUsage(usage x509.KeyUsage) bool
+
+	// HasExtKeyUsage reports whether the client's X509 certificate allows the
+	// extended key usage `usage`.
+	HasExtKeyUsage(usage x509.ExtKeyUsage) bool
 }
@@ -0,0 +1,150 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrCertificateRevoked is returned by New when the certificate of the
+// invoker is revoked according to the RevocationChecker set with
+// WithRevocationChecker.
+var ErrCertificateRevoked = errors.New("the transaction invoker's certificate is revoked")
+
+// RevocationChecker checks whether X509 certificates are revoked, for
+// example with certificate revocation lists or OCSP. Since the chaincode
+// of all the endorsing peers must reach the same decision, a checker must
+// be given the same revocation information on all of them.
+type RevocationChecker interface {
+	IsRevoked(cert *x509.Certificate) (bool, error)
+}
+
+// WithRevocationChecker makes New reject invokers identified by an X509
+// certificate which checker reports as revoked, such as certificates
+// revoked since the last update of the MSP configuration of the channel.
+func WithRevocationChecker(checker RevocationChecker) Option {
+	return func(c *clientIdentityImpl) {
+		c.revocationChecker = checker
+	}
+}
+
+// checkRevocation returns ErrCertificateRevoked if the certificate of c is
+// revoked.
+func (c *clientIdentityImpl) checkRevocation() error {
+	if c.revocationChecker == nil || c.cert == nil {
+		return nil
+	}
+	revoked, err := c.revocationChecker.IsRevoked(c.cert)
+	if err != nil {
+		return fmt.Errorf("failed to check revocation of the transaction invoker's certificate: %s", err)
+	}
+	if revoked {
+		return ErrCertificateRevoked
+	}
+	return nil
+}
+
+// NewCRLFileChecker returns a RevocationChecker reporting the certificates
+// listed in the PEM or DER encoded certificate revocation lists at paths
+// as revoked. The files are read again when they are modified, so the
+// lists can be updated without restarting the chaincode. The signatures of
+// the lists are not verified: the files must come from a trusted source.
+func NewCRLFileChecker(paths ...string) (RevocationChecker, error) {
+	checker := &crlFileChecker{paths: paths}
+	if err := checker.load(); err != nil {
+		return nil, err
+	}
+	return checker, nil
+}
+
+type crlFileChecker struct {
+	paths []string
+
+	mutex    sync.Mutex
+	modTimes []time.Time
+	// revoked holds the issuer and serial number of the revoked
+	// certificates.
+	revoked map[string]bool
+}
+
+func (c *crlFileChecker) IsRevoked(cert *x509.Certificate) (bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if err := c.load(); err != nil {
+		return false, err
+	}
+	return c.revoked[revocationKey(cert.Issuer.String(), cert.SerialNumber.String())], nil
+}
+
+// load reads the lists if any of them was modified since they were last
+// read. The caller must hold the mutex, except when the checker is created.
+func (c *crlFileChecker) load() error {
+	modTimes := make([]time.Time, len(c.paths))
+	changed := c.revoked == nil
+	for i, path := range c.paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read CRL: %s", err)
+		}
+		modTimes[i] = info.ModTime()
+		if !changed && !modTimes[i].Equal(c.modTimes[i]) {
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+
+	revoked := map[string]bool{}
+	for _, path := range c.paths {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read CRL: %s", err)
+		}
+		if err := addRevoked(revoked, path, data); err != nil {
+			return err
+		}
+	}
+	c.revoked, c.modTimes = revoked, modTimes
+	return nil
+}
+
+// addRevoked adds the certificates revoked by the lists of data to
+// revoked.
+func addRevoked(revoked map[string]bool, path string, data []byte) error {
+	ders := [][]byte{data}
+	if block, rest := pem.Decode(data); block != nil {
+		ders = nil
+		for ; block != nil; block, rest = pem.Decode(rest) {
+			if block.Type == "X509 CRL" {
+				ders = append(ders, block.Bytes)
+			}
+		}
+	}
+	for _, der := range ders {
+		crl, err := x509.ParseDERCRL(der)
+		if err != nil {
+			return fmt.Errorf("failed to parse CRL %s: %s", path, err)
+		}
+		var issuer pkix.Name
+		issuer.FillFromRDNSequence(&crl.TBSCertList.Issuer)
+		for _, cert := range crl.TBSCertList.RevokedCertificates {
+			revoked[revocationKey(issuer.String(), cert.SerialNumber.String())] = true
+		}
+	}
+	return nil
+}
+
+func revocationKey(issuer, serialNumber string) string {
+	return issuer + "\x00" + serialNumber
+}
@@ -0,0 +1,167 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package cid_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key}
+}
+
+// issue returns the PEM encoded certificate of user1 with serial number
+// serial.
+func (ca *testCA) issue(t *testing.T, serial int64) []byte {
+	return ca.issueFor(t, serial, "user1")
+}
+
+// issueFor returns the PEM encoded certificate of the subject with common
+// name cn with serial number serial.
+func (ca *testCA) issueFor(t *testing.T, serial int64, cn string) []byte {
+	return ca.sign(t, &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	})
+}
+
+// sign returns the PEM encoded certificate issued from template for a new
+// key.
+func (ca *testCA) sign(t *testing.T, template *x509.Certificate) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// crl returns the PEM encoded CRL revoking serials.
+func (ca *testCA) crl(t *testing.T, serials ...int64) []byte {
+	var revoked []pkix.RevokedCertificate
+	for _, serial := range serials {
+		revoked = append(revoked, pkix.RevokedCertificate{SerialNumber: big.NewInt(serial), RevocationTime: time.Now()})
+	}
+	der, err := ca.cert.CreateCRL(rand.Reader, ca.key, revoked, time.Now(), time.Now().Add(time.Hour))
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+}
+
+func newCreatorStub(t *testing.T, cert []byte) *shimtest.MockStub {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1MSP", IdBytes: cert})
+	require.NoError(t, err)
+	return shimtest.NewStubBuilder().WithCreator(creator).Build()
+}
+
+type staticChecker struct {
+	revoked bool
+	err     error
+}
+
+func (c staticChecker) IsRevoked(*x509.Certificate) (bool, error) {
+	return c.revoked, c.err
+}
+
+func TestWithRevocationChecker(t *testing.T) {
+	ca := newTestCA(t, "ca.org1.example.com")
+	stub := newCreatorStub(t, ca.issue(t, 2))
+
+	_, err := cid.New(stub, cid.WithRevocationChecker(staticChecker{}))
+	assert.NoError(t, err)
+
+	_, err = cid.New(stub, cid.WithRevocationChecker(staticChecker{revoked: true}))
+	assert.Equal(t, cid.ErrCertificateRevoked, err)
+
+	_, err = cid.New(stub, cid.WithRevocationChecker(staticChecker{err: errors.New("OCSP responder unavailable")}))
+	assert.EqualError(t, err, "failed to check revocation of the transaction invoker's certificate: OCSP responder unavailable")
+
+	// idemix identities have no certificate to check
+	idemix, err := getIdemixMockStubWithAttrs()
+	require.NoError(t, err)
+	_, err = cid.New(idemix, cid.WithRevocationChecker(staticChecker{revoked: true}))
+	assert.NoError(t, err)
+}
+
+func TestCRLFileChecker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "crl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "org1.crl")
+
+	_, err = cid.NewCRLFileChecker(path)
+	assert.EqualError(t, err, "failed to read CRL: stat "+path+": no such file or directory")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("not a CRL"), 0600))
+	_, err = cid.NewCRLFileChecker(path)
+	assert.Contains(t, err.Error(), "failed to parse CRL "+path)
+
+	ca := newTestCA(t, "ca.org1.example.com")
+	other := newTestCA(t, "ca.org2.example.com")
+	require.NoError(t, ioutil.WriteFile(path, ca.crl(t, 2), 0600))
+	checker, err := cid.NewCRLFileChecker(path)
+	require.NoError(t, err)
+
+	_, err = cid.New(newCreatorStub(t, ca.issue(t, 2)), cid.WithRevocationChecker(checker))
+	assert.Equal(t, cid.ErrCertificateRevoked, err)
+	_, err = cid.New(newCreatorStub(t, ca.issue(t, 3)), cid.WithRevocationChecker(checker))
+	assert.NoError(t, err)
+
+	// the list is read again once it is modified
+	require.NoError(t, ioutil.WriteFile(path, ca.crl(t, 2, 3), 0600))
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+	_, err = cid.New(newCreatorStub(t, ca.issue(t, 3)), cid.WithRevocationChecker(checker))
+	assert.Equal(t, cid.ErrCertificateRevoked, err)
+
+	// DER lists are supported, and serial numbers are scoped to an issuer
+	block, _ := pem.Decode(other.crl(t, 4))
+	der := filepath.Join(dir, "other.crl")
+	require.NoError(t, ioutil.WriteFile(der, block.Bytes, 0600))
+	checker, err = cid.NewCRLFileChecker(path, der)
+	require.NoError(t, err)
+	_, err = cid.New(newCreatorStub(t, other.issue(t, 4)), cid.WithRevocationChecker(checker))
+	assert.Equal(t, cid.ErrCertificateRevoked, err)
+	_, err = cid.New(newCreatorStub(t, ca.issue(t, 4)), cid.WithRevocationChecker(checker))
+	assert.NoError(t, err)
+}
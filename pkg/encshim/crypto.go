@@ -0,0 +1,262 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package encshim
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Encrypter encrypts and decrypts values with AES-GCM.
+//
+// Encryption is deterministic: the nonce is a synthetic IV, the first 96
+// bits of an HMAC-SHA256 of the additional data and the plaintext, so every
+// endorser produces the same ciphertext for the same input, as required for
+// their write sets to match. As a consequence, equal plaintexts encrypted
+// with the same key and additional data yield equal ciphertexts: anyone who
+// can read the ciphertexts can tell which values are equal. Distinct
+// plaintexts only share a nonce on a 96 bit collision of the HMAC.
+//
+// The AES-GCM key and the HMAC key are derived from the key given to
+// NewEncrypter with HKDF-SHA256, so that the same key is never used with
+// both algorithms.
+type Encrypter struct {
+	nonceKey []byte
+	aead     cipher.AEAD
+}
+
+// HKDF labels of the subkeys derived by NewEncrypter.
+const (
+	encryptionKeyLabel = "encshim AES-GCM key"
+	nonceKeyLabel      = "encshim synthetic IV key"
+)
+
+// NewEncrypter returns an Encrypter using key, which must be 16, 24 or 32
+// bytes long to select AES-128, AES-192 or AES-256.
+func NewEncrypter(key []byte) (*Encrypter, error) {
+	switch len(key) {
+	case 16, 24, 32:
+	default:
+		return nil, fmt.Errorf("invalid encryption key: %s", aes.KeySizeError(len(key)))
+	}
+	prk := hkdfExtract(nil, key)
+	block, err := aes.NewCipher(hkdfExpand(prk, []byte(encryptionKeyLabel), len(key)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid encryption key: %s", err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &Encrypter{nonceKey: hkdfExpand(prk, []byte(nonceKeyLabel), sha256.Size), aead: aead}, nil
+}
+
+// Encrypt encrypts plaintext and authenticates it along with additionalData.
+// The returned ciphertext is prefixed with the nonce.
+func (e *Encrypter) Encrypt(plaintext, additionalData []byte) ([]byte, error) {
+	mac := hmac.New(sha256.New, e.nonceKey)
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(additionalData)))
+	mac.Write(size[:])
+	mac.Write(additionalData)
+	mac.Write(plaintext)
+	nonce := mac.Sum(nil)[:e.aead.NonceSize()]
+
+	return e.aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// Decrypt decrypts a ciphertext returned by Encrypt with the same
+// additionalData.
+func (e *Encrypter) Decrypt(ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < e.aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:e.aead.NonceSize()], ciphertext[e.aead.NonceSize():]
+	plaintext, err := e.aead.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %s", err)
+	}
+	return plaintext, nil
+}
+
+// Signer signs values with ECDSA over SHA-256. Signatures are deterministic
+// (RFC 6979) so that every endorser produces the same signature.
+type Signer struct {
+	key *ecdsa.PrivateKey
+}
+
+// NewSigner returns a Signer using an EC private key in SEC 1 or PKCS #8
+// DER form, optionally PEM encoded.
+func NewSigner(key []byte) (*Signer, error) {
+	der := pemOrDER(key)
+	if k, err := x509.ParseECPrivateKey(der); err == nil {
+		return &Signer{key: k}, nil
+	}
+	k, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("invalid signing key: %s", err)
+	}
+	ecKey, ok := k.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("invalid signing key: not an ECDSA key")
+	}
+	return &Signer{key: ecKey}, nil
+}
+
+// ecdsaSignature is the ASN.1 structure of an ECDSA signature.
+type ecdsaSignature struct {
+	R, S *big.Int
+}
+
+// Sign returns the ASN.1 DER encoded signature of msg.
+func (s *Signer) Sign(msg []byte) ([]byte, error) {
+	digest := sha256.Sum256(msg)
+	r, sig := signRFC6979(s.key, digest[:])
+	return asn1.Marshal(ecdsaSignature{R: r, S: sig})
+}
+
+// Verifier verifies signatures created by Signer.
+type Verifier struct {
+	key *ecdsa.PublicKey
+}
+
+// NewVerifier returns a Verifier using an EC public key in PKIX DER form,
+// optionally PEM encoded.
+func NewVerifier(key []byte) (*Verifier, error) {
+	k, err := x509.ParsePKIXPublicKey(pemOrDER(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid verification key: %s", err)
+	}
+	ecKey, ok := k.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("invalid verification key: not an ECDSA key")
+	}
+	return &Verifier{key: ecKey}, nil
+}
+
+// Verify returns an error unless sig is a valid signature of msg.
+func (v *Verifier) Verify(msg, sig []byte) error {
+	var es ecdsaSignature
+	if rest, err := asn1.Unmarshal(sig, &es); err != nil || len(rest) != 0 {
+		return errors.New("malformed signature")
+	}
+	digest := sha256.Sum256(msg)
+	if es.R == nil || es.S == nil || !ecdsa.Verify(v.key, digest[:], es.R, es.S) {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+// pemOrDER returns the DER content of a PEM block, or b if it is not PEM
+// encoded.
+func pemOrDER(b []byte) []byte {
+	if block, _ := pem.Decode(b); block != nil {
+		return block.Bytes
+	}
+	return b
+}
+
+// hkdfExtract is the extract step of HKDF-SHA256 (RFC 5869). A nil salt
+// stands for a string of zeros.
+func hkdfExtract(salt, secret []byte) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	mac := hmac.New(sha256.New, salt)
+	mac.Write(secret)
+	return mac.Sum(nil)
+}
+
+// hkdfExpand is the expand step of HKDF-SHA256 (RFC 5869), returning length
+// bytes of key material for info. length must not exceed 255 * 32 bytes.
+func hkdfExpand(prk, info []byte, length int) []byte {
+	var okm, t []byte
+	for i := byte(1); len(okm) < length; i++ {
+		mac := hmac.New(sha256.New, prk)
+		mac.Write(t)
+		mac.Write(info)
+		mac.Write([]byte{i})
+		t = mac.Sum(nil)
+		okm = append(okm, t...)
+	}
+	return okm[:length]
+}
+
+// signRFC6979 signs digest with the nonce generated as specified by
+// RFC 6979 using HMAC-SHA256.
+func signRFC6979(priv *ecdsa.PrivateKey, digest []byte) (*big.Int, *big.Int) {
+	n := priv.Curve.Params().N
+	qlen := n.BitLen()
+	rolen := (qlen + 7) / 8
+
+	bits2int := func(b []byte) *big.Int {
+		v := new(big.Int).SetBytes(b)
+		if excess := len(b)*8 - qlen; excess > 0 {
+			v.Rsh(v, uint(excess))
+		}
+		return v
+	}
+	int2octets := func(v *big.Int) []byte {
+		out := make([]byte, rolen)
+		b := v.Bytes()
+		copy(out[rolen-len(b):], b)
+		return out
+	}
+	hmacK := func(key []byte, data ...[]byte) []byte {
+		mac := hmac.New(sha256.New, key)
+		for _, d := range data {
+			mac.Write(d)
+		}
+		return mac.Sum(nil)
+	}
+
+	e := bits2int(digest)
+	h1 := new(big.Int).Mod(e, n)
+	x := int2octets(priv.D)
+	hOctets := int2octets(h1)
+
+	v := make([]byte, sha256.Size)
+	for i := range v {
+		v[i] = 0x01
+	}
+	k := make([]byte, sha256.Size)
+	k = hmacK(k, v, []byte{0x00}, x, hOctets)
+	v = hmacK(k, v)
+	k = hmacK(k, v, []byte{0x01}, x, hOctets)
+	v = hmacK(k, v)
+
+	for {
+		var t []byte
+		for len(t) < rolen {
+			v = hmacK(k, v)
+			t = append(t, v...)
+		}
+		nonce := bits2int(t[:rolen])
+		if nonce.Sign() > 0 && nonce.Cmp(n) < 0 {
+			r, _ := priv.Curve.ScalarBaseMult(int2octets(nonce))
+			r.Mod(r, n)
+			if r.Sign() != 0 {
+				s := new(big.Int).Mul(r, priv.D)
+				s.Add(s, e)
+				s.Mul(s, new(big.Int).ModInverse(nonce, n))
+				s.Mod(s, n)
+				if s.Sign() != 0 {
+					return r, s
+				}
+			}
+		}
+		k = hmacK(k, v, []byte{0x00})
+		v = hmacK(k, v)
+	}
+}
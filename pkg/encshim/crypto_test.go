@@ -0,0 +1,128 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package encshim
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncrypter(t *testing.T) {
+	for _, size := range []int{16, 24, 32} {
+		enc, err := NewEncrypter(make([]byte, size))
+		require.NoError(t, err)
+
+		ct1, err := enc.Encrypt([]byte("plaintext"), []byte("ad"))
+		require.NoError(t, err)
+		ct2, err := enc.Encrypt([]byte("plaintext"), []byte("ad"))
+		require.NoError(t, err)
+		assert.Equal(t, ct1, ct2, "encryption must be deterministic")
+
+		ct3, err := enc.Encrypt([]byte("plaintext"), []byte("other"))
+		require.NoError(t, err)
+		assert.NotEqual(t, ct1[:12], ct3[:12], "nonce must depend on additional data")
+
+		pt, err := enc.Decrypt(ct1, []byte("ad"))
+		require.NoError(t, err)
+		assert.Equal(t, []byte("plaintext"), pt)
+
+		_, err = enc.Decrypt(ct1, []byte("other"))
+		assert.EqualError(t, err, "failed to decrypt: cipher: message authentication failed")
+		_, err = enc.Decrypt(ct1[:4], []byte("ad"))
+		assert.EqualError(t, err, "ciphertext too short")
+	}
+}
+
+func TestEncrypterSubkeys(t *testing.T) {
+	key := []byte("0123456789abcdef")
+	enc, err := NewEncrypter(key)
+	require.NoError(t, err)
+
+	// neither the HMAC nor the AES-GCM key is the key itself
+	assert.NotEqual(t, key, enc.nonceKey[:len(key)])
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	aead, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	ct, err := enc.Encrypt([]byte("plaintext"), nil)
+	require.NoError(t, err)
+	_, err = aead.Open(nil, ct[:12], ct[12:], nil)
+	assert.Error(t, err)
+
+	_, err = NewEncrypter(make([]byte, 20))
+	assert.EqualError(t, err, "invalid encryption key: crypto/aes: invalid key size 20")
+}
+
+func TestHKDF(t *testing.T) {
+	// test case 1 from RFC 5869, appendix A.1
+	ikm, _ := hex.DecodeString("0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b0b")
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+
+	prk := hkdfExtract(salt, ikm)
+	assert.Equal(t, "077709362c2e32df0ddc3f0dc47bba6390b6c73bb50f9c3122ec844ad7c2b3e5", hex.EncodeToString(prk))
+	okm := hkdfExpand(prk, info, 42)
+	assert.Equal(t, "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865", hex.EncodeToString(okm))
+}
+
+func TestSignRFC6979(t *testing.T) {
+	// test vector from RFC 6979, A.2.5, P-256 with SHA-256, message "sample"
+	d, _ := new(big.Int).SetString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721", 16)
+	priv := &ecdsa.PrivateKey{D: d}
+	priv.Curve = elliptic.P256()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(d.Bytes())
+
+	digest := sha256.Sum256([]byte("sample"))
+	r, s := signRFC6979(priv, digest[:])
+	assert.Equal(t, "efd48b2aacb6a8fd1140dd9cd45e81d69d2c877b56aaf991c34d0ea84eaf3716", hex.EncodeToString(r.Bytes()))
+	assert.Equal(t, "f7cb1c942d657c41d436c7a1b6e29f65f3e900dbb9aff4064dc4ab2f843acda8", hex.EncodeToString(s.Bytes()))
+}
+
+func TestSignerVerifier(t *testing.T) {
+	d, _ := new(big.Int).SetString("C9AFA9D845BA75166B5C215767B1D6934E50C3DB36E89B127B8A622B120F6721", 16)
+	priv := &ecdsa.PrivateKey{D: d}
+	priv.Curve = elliptic.P256()
+	priv.X, priv.Y = priv.Curve.ScalarBaseMult(d.Bytes())
+
+	sec1, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(priv)
+	require.NoError(t, err)
+	pub, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+
+	verifier, err := NewVerifier(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pub}))
+	require.NoError(t, err)
+
+	var sigs [][]byte
+	for _, key := range [][]byte{sec1, pkcs8, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: sec1})} {
+		signer, err := NewSigner(key)
+		require.NoError(t, err)
+		sig, err := signer.Sign([]byte("message"))
+		require.NoError(t, err)
+		assert.NoError(t, verifier.Verify([]byte("message"), sig))
+		assert.EqualError(t, verifier.Verify([]byte("other"), sig), "invalid signature")
+		sigs = append(sigs, sig)
+	}
+	assert.Equal(t, sigs[0], sigs[1], "signatures must be deterministic")
+	assert.Equal(t, sigs[0], sigs[2], "signatures must be deterministic")
+
+	assert.EqualError(t, verifier.Verify([]byte("message"), []byte("junk")), "malformed signature")
+
+	_, err = NewSigner([]byte("junk"))
+	assert.Contains(t, err.Error(), "invalid signing key")
+	_, err = NewVerifier([]byte("junk"))
+	assert.Contains(t, err.Error(), "invalid verification key")
+}
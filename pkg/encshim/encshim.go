@@ -0,0 +1,197 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package encshim encrypts and signs state values using keys passed to the
+// chaincode in the transient map of the proposal, so that the keys never
+// reach the ledger.
+package encshim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+const (
+	// EncryptionKeyField is the transient field holding the AES key.
+	EncryptionKeyField = "ENCKEY"
+	// SigningKeyField is the transient field holding the ECDSA private key.
+	SigningKeyField = "SIGKEY"
+	// VerificationKeyField is the transient field holding the ECDSA public key.
+	VerificationKeyField = "VERKEY"
+)
+
+// SignedValue is the state representation of a value written by
+// PutStateSigned.
+type SignedValue struct {
+	Value     []byte `json:"value"`
+	Signature []byte `json:"signature"`
+}
+
+// PutStateEncrypted encrypts value with the key in the EncryptionKeyField
+// transient field and writes it to the state. The ciphertext is bound to key
+// and cannot be moved to another key.
+func PutStateEncrypted(stub ChaincodeStubInterface, key string, value []byte) error {
+	enc, err := encrypterFromTransient(stub)
+	if err != nil {
+		return err
+	}
+	ciphertext, err := enc.Encrypt(value, []byte(key))
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, ciphertext)
+}
+
+// GetStateDecrypted reads key from the state and decrypts it with the key in
+// the EncryptionKeyField transient field. It returns nil if key does not
+// exist.
+func GetStateDecrypted(stub ChaincodeStubInterface, key string) ([]byte, error) {
+	enc, err := encrypterFromTransient(stub)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := stub.GetState(key)
+	if err != nil || ciphertext == nil {
+		return nil, err
+	}
+	plaintext, err := enc.Decrypt(ciphertext, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %s", key, err)
+	}
+	return plaintext, nil
+}
+
+// PutStateSigned signs value with the key in the SigningKeyField transient
+// field and writes it to the state as a JSON encoded SignedValue.
+func PutStateSigned(stub ChaincodeStubInterface, key string, value []byte) error {
+	skey, err := transientField(stub, SigningKeyField)
+	if err != nil {
+		return err
+	}
+	signer, err := NewSigner(skey)
+	if err != nil {
+		return err
+	}
+	sig, err := signer.Sign(signedMessage(key, value))
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(&SignedValue{Value: value, Signature: sig})
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, b)
+}
+
+// GetStateVerified reads a value written by PutStateSigned and verifies its
+// signature with the key in the VerificationKeyField transient field. It
+// returns nil if key does not exist.
+func GetStateVerified(stub ChaincodeStubInterface, key string) ([]byte, error) {
+	vkey, err := transientField(stub, VerificationKeyField)
+	if err != nil {
+		return nil, err
+	}
+	verifier, err := NewVerifier(vkey)
+	if err != nil {
+		return nil, err
+	}
+	b, err := stub.GetState(key)
+	if err != nil || b == nil {
+		return nil, err
+	}
+	var sv SignedValue
+	if err := json.Unmarshal(b, &sv); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal signed value of %s: %s", key, err)
+	}
+	if err := verifier.Verify(signedMessage(key, sv.Value), sv.Signature); err != nil {
+		return nil, fmt.Errorf("failed to verify %s: %s", key, err)
+	}
+	return sv.Value, nil
+}
+
+// EncryptArgs encrypts args, the arguments of a call of function, with
+// key for DecryptArgs. Clients pass the function name in clear followed by
+// the returned ciphertexts, and key in the EncryptionKeyField transient
+// field.
+func EncryptArgs(key []byte, function string, args [][]byte) ([][]byte, error) {
+	enc, err := NewEncrypter(key)
+	if err != nil {
+		return nil, err
+	}
+	ciphertexts := make([][]byte, len(args))
+	for i, arg := range args {
+		if ciphertexts[i], err = enc.Encrypt(arg, argData(function, i)); err != nil {
+			return nil, err
+		}
+	}
+	return ciphertexts, nil
+}
+
+// DecryptArgs decrypts the arguments following the function name, which
+// were encrypted by EncryptArgs, with the key in the EncryptionKeyField
+// transient field, and leaves the transient data unchanged. It has the
+// signature of a shim.DecryptorFunc, so that the chaincode only reads
+// plaintext arguments:
+//
+//	err := shim.Start(cc, shim.WithDecryptor(shim.DecryptorFunc(encshim.DecryptArgs)))
+func DecryptArgs(args [][]byte, transient map[string][]byte) ([][]byte, map[string][]byte, error) {
+	if len(args) == 0 {
+		return nil, nil, errors.New("no function name in arguments")
+	}
+	ekey, ok := transient[EncryptionKeyField]
+	if !ok || len(ekey) == 0 {
+		return nil, nil, fmt.Errorf("transient field %s not found", EncryptionKeyField)
+	}
+	enc, err := NewEncrypter(ekey)
+	if err != nil {
+		return nil, nil, err
+	}
+	function := string(args[0])
+	plaintexts := [][]byte{args[0]}
+	for i, arg := range args[1:] {
+		plaintext, err := enc.Decrypt(arg, argData(function, i))
+		if err != nil {
+			return nil, nil, fmt.Errorf("argument %d: %s", i+1, err)
+		}
+		plaintexts = append(plaintexts, plaintext)
+	}
+	return plaintexts, transient, nil
+}
+
+// argData binds the i-th argument of a call of function to the function
+// and its position, so that encrypted arguments cannot be reordered or
+// passed to another function.
+func argData(function string, i int) []byte {
+	return []byte(function + "\x00" + strconv.Itoa(i))
+}
+
+// signedMessage binds value to key so a signed value cannot be moved to
+// another key.
+func signedMessage(key string, value []byte) []byte {
+	msg := make([]byte, 0, len(key)+1+len(value))
+	msg = append(msg, key...)
+	msg = append(msg, 0)
+	return append(msg, value...)
+}
+
+func encrypterFromTransient(stub ChaincodeStubInterface) (*Encrypter, error) {
+	ekey, err := transientField(stub, EncryptionKeyField)
+	if err != nil {
+		return nil, err
+	}
+	return NewEncrypter(ekey)
+}
+
+func transientField(stub ChaincodeStubInterface, field string) ([]byte, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transient map: %s", err)
+	}
+	v, ok := transient[field]
+	if !ok || len(v) == 0 {
+		return nil, fmt.Errorf("transient field %s not found", field)
+	}
+	return v, nil
+}
@@ -0,0 +1,119 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package encshim_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/encshim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPutStateEncrypted(t *testing.T) {
+	transient := map[string][]byte{encshim.EncryptionKeyField: make([]byte, 32)}
+	stub := shimtest.NewStubBuilder().WithTransient(transient).Build()
+
+	stub.MockTransactionStart("tx1")
+	err := encshim.PutStateEncrypted(stub, "key", []byte("secret"))
+	require.NoError(t, err)
+	stub.MockTransactionEnd("tx1")
+
+	raw, err := stub.GetState("key")
+	require.NoError(t, err)
+	assert.NotContains(t, string(raw), "secret")
+
+	value, err := encshim.GetStateDecrypted(stub, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secret"), value)
+
+	value, err = encshim.GetStateDecrypted(stub, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, value)
+
+	stub.MockTransactionStart("tx2")
+	stub.PutState("moved", raw)
+	stub.MockTransactionEnd("tx2")
+	_, err = encshim.GetStateDecrypted(stub, "moved")
+	assert.EqualError(t, err, "failed to decrypt moved: failed to decrypt: cipher: message authentication failed")
+
+	stub.Transient[encshim.EncryptionKeyField] = []byte("short")
+	_, err = encshim.GetStateDecrypted(stub, "key")
+	assert.EqualError(t, err, "invalid encryption key: crypto/aes: invalid key size 5")
+
+	delete(stub.Transient, encshim.EncryptionKeyField)
+	err = encshim.PutStateEncrypted(stub, "key", []byte("secret"))
+	assert.EqualError(t, err, "transient field ENCKEY not found")
+}
+
+func TestPutStateSigned(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	sigKey, err := x509.MarshalECPrivateKey(priv)
+	require.NoError(t, err)
+	verKey, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	require.NoError(t, err)
+
+	transient := map[string][]byte{
+		encshim.SigningKeyField:      sigKey,
+		encshim.VerificationKeyField: verKey,
+	}
+	stub := shimtest.NewStubBuilder().WithTransient(transient).Build()
+
+	stub.MockTransactionStart("tx1")
+	err = encshim.PutStateSigned(stub, "key", []byte("value"))
+	require.NoError(t, err)
+	stub.MockTransactionEnd("tx1")
+
+	value, err := encshim.GetStateVerified(stub, "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	raw, err := stub.GetState("key")
+	require.NoError(t, err)
+	stub.MockTransactionStart("tx2")
+	stub.PutState("moved", raw)
+	stub.PutState("garbage", []byte("garbage"))
+	stub.MockTransactionEnd("tx2")
+
+	_, err = encshim.GetStateVerified(stub, "moved")
+	assert.EqualError(t, err, "failed to verify moved: invalid signature")
+	_, err = encshim.GetStateVerified(stub, "garbage")
+	assert.Contains(t, err.Error(), "failed to unmarshal signed value of garbage")
+
+	delete(stub.Transient, encshim.SigningKeyField)
+	err = encshim.PutStateSigned(stub, "key", []byte("value"))
+	assert.EqualError(t, err, "transient field SIGKEY not found")
+}
+
+func TestEncryptArgs(t *testing.T) {
+	key := make([]byte, 32)
+	ciphertexts, err := encshim.EncryptArgs(key, "transfer", [][]byte{[]byte("alice"), []byte("100")})
+	require.NoError(t, err)
+	require.Len(t, ciphertexts, 2)
+	assert.NotContains(t, string(ciphertexts[0]), "alice")
+
+	transient := map[string][]byte{encshim.EncryptionKeyField: key}
+	args, decryptedTransient, err := encshim.DecryptArgs(append([][]byte{[]byte("transfer")}, ciphertexts...), transient)
+	require.NoError(t, err)
+	assert.Equal(t, [][]byte{[]byte("transfer"), []byte("alice"), []byte("100")}, args)
+	assert.Equal(t, transient, decryptedTransient)
+
+	_, _, err = encshim.DecryptArgs([][]byte{[]byte("transfer"), ciphertexts[1], ciphertexts[0]}, transient)
+	assert.EqualError(t, err, "argument 1: failed to decrypt: cipher: message authentication failed")
+	_, _, err = encshim.DecryptArgs(append([][]byte{[]byte("burn")}, ciphertexts...), transient)
+	assert.EqualError(t, err, "argument 1: failed to decrypt: cipher: message authentication failed")
+	_, _, err = encshim.DecryptArgs(append([][]byte{[]byte("transfer")}, ciphertexts...), nil)
+	assert.EqualError(t, err, "transient field ENCKEY not found")
+	_, _, err = encshim.DecryptArgs(nil, transient)
+	assert.EqualError(t, err, "no function name in arguments")
+
+	_, err = encshim.EncryptArgs([]byte("short"), "transfer", nil)
+	assert.EqualError(t, err, "invalid encryption key: crypto/aes: invalid key size 5")
+}
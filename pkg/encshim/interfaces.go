@@ -0,0 +1,17 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package encshim
+
+// ChaincodeStubInterface is the subset of the chaincode stub used to read
+// keys from the transient map and to access the world state.
+type ChaincodeStubInterface interface {
+	// GetTransient returns the transient map of the proposal.
+	GetTransient() (map[string][]byte, error)
+
+	// GetState returns the value of key from the ledger.
+	GetState(key string) ([]byte, error)
+
+	// PutState writes value for key to the write set.
+	PutState(key string, value []byte) error
+}
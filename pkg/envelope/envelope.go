@@ -0,0 +1,144 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package envelope stores values in a versioned envelope recording the
+// version of the schema of the value and who wrote it:
+//
+//	{"schemaVersion": 2, "writerMSP": "Org1MSP", "txid": "...", "data": {...}}
+//
+// The value is stored as JSON in the data field, so rich queries can select
+// on it with the "data." prefix. When the schema of the value changes, its
+// version is incremented and an upcaster converting the data of the previous
+// version is registered; values written with older versions are then
+// converted when they are read:
+//
+//	schema := envelope.NewSchema(2).
+//		Upcaster(1, func(data json.RawMessage) (json.RawMessage, error) {
+//			... // convert the data of version 1 to version 2
+//		})
+//	err := schema.PutState(stub, key, asset)
+//	env, err := schema.GetState(stub, key, &asset)
+package envelope
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+)
+
+// Envelope is the stored form of a value.
+type Envelope struct {
+	// SchemaVersion is the version of the schema of Data.
+	SchemaVersion int `json:"schemaVersion"`
+	// WriterMSP is the MSP ID of the identity which wrote the value.
+	WriterMSP string `json:"writerMSP"`
+	// TxID is the ID of the transaction which wrote the value.
+	TxID string `json:"txid"`
+	// Data is the JSON encoding of the value.
+	Data json.RawMessage `json:"data"`
+}
+
+// Upcaster converts the data of a value from a schema version to the next
+// one.
+type Upcaster func(data json.RawMessage) (json.RawMessage, error)
+
+// Schema writes values with its version and reads values written with
+// the same or an earlier version.
+type Schema struct {
+	version   int
+	upcasters map[int]Upcaster
+}
+
+// NewSchema returns a schema of the given version, which must be positive.
+func NewSchema(version int) *Schema {
+	return &Schema{version: version, upcasters: map[int]Upcaster{}}
+}
+
+// Upcaster registers the upcaster converting data of version from to
+// version from+1 and returns the schema.
+func (s *Schema) Upcaster(from int, upcaster Upcaster) *Schema {
+	s.upcasters[from] = upcaster
+	return s
+}
+
+// Version returns the version of the schema.
+func (s *Schema) Version() int {
+	return s.version
+}
+
+// Wrap returns the envelope of value written by the transaction of stub.
+func (s *Schema) Wrap(stub ChaincodeStubInterface, value interface{}) ([]byte, error) {
+	if s.version <= 0 {
+		return nil, fmt.Errorf("schema version must be positive, got %d", s.version)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal value: %s", err)
+	}
+	mspID, err := cid.GetMSPID(stub)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(&Envelope{
+		SchemaVersion: s.version,
+		WriterMSP:     mspID,
+		TxID:          stub.GetTxID(),
+		Data:          data,
+	})
+}
+
+// Unwrap decodes envelope, upcasts its data to the version of the schema and
+// unmarshals it into value. The envelope is returned with the data as
+// stored, before upcasting.
+func (s *Schema) Unwrap(envelope []byte, value interface{}) (*Envelope, error) {
+	env := &Envelope{}
+	if err := json.Unmarshal(envelope, env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal envelope: %s", err)
+	}
+	if env.SchemaVersion > s.version {
+		return nil, fmt.Errorf("envelope schema version %d is newer than %d", env.SchemaVersion, s.version)
+	}
+
+	data := env.Data
+	for version := env.SchemaVersion; version < s.version; version++ {
+		upcaster, ok := s.upcasters[version]
+		if !ok {
+			return nil, fmt.Errorf("no upcaster from schema version %d", version)
+		}
+		var err error
+		if data, err = upcaster(data); err != nil {
+			return nil, fmt.Errorf("failed to upcast from schema version %d: %s", version, err)
+		}
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal value: %s", err)
+	}
+	return env, nil
+}
+
+// PutState writes the envelope of value to key.
+func (s *Schema) PutState(stub ChaincodeStubInterface, key string, value interface{}) error {
+	envelope, err := s.Wrap(stub, value)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, envelope)
+}
+
+// GetState reads the envelope of key into value and returns it, or returns
+// nil if key does not exist.
+func (s *Schema) GetState(stub ChaincodeStubInterface, key string, value interface{}) (*Envelope, error) {
+	envelope, err := stub.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	if envelope == nil {
+		return nil, nil
+	}
+	env, err := s.Unwrap(envelope, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key %s: %s", key, err)
+	}
+	return env, nil
+}
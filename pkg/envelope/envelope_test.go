@@ -0,0 +1,129 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package envelope_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/envelope"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStub(t *testing.T, mspID string) *shimtest.MockStub {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "writer"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: cert})
+	require.NoError(t, err)
+	return shimtest.NewStubBuilder().WithCreator(creator).Build()
+}
+
+type assetV1 struct {
+	Name string `json:"name"`
+}
+
+type assetV3 struct {
+	Name  string `json:"name"`
+	Owner string `json:"owner"`
+	Size  int    `json:"size"`
+}
+
+func TestSchema(t *testing.T) {
+	stub := newStub(t, "Org1MSP")
+	stub.MockTransactionStart("tx1")
+	require.NoError(t, envelope.NewSchema(1).PutState(stub, "asset", assetV1{Name: "a1"}))
+	stub.MockTransactionEnd("tx1")
+
+	var stored envelope.Envelope
+	require.NoError(t, json.Unmarshal(stub.State["asset"], &stored))
+	assert.Equal(t, envelope.Envelope{SchemaVersion: 1, WriterMSP: "Org1MSP", TxID: "tx1", Data: json.RawMessage(`{"name":"a1"}`)}, stored)
+
+	schema := envelope.NewSchema(3).
+		Upcaster(1, func(data json.RawMessage) (json.RawMessage, error) {
+			var v map[string]interface{}
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+			v["owner"] = "unknown"
+			return json.Marshal(v)
+		}).
+		Upcaster(2, func(data json.RawMessage) (json.RawMessage, error) {
+			var v map[string]interface{}
+			if err := json.Unmarshal(data, &v); err != nil {
+				return nil, err
+			}
+			v["size"] = 1
+			return json.Marshal(v)
+		})
+
+	var asset assetV3
+	env, err := schema.GetState(stub, "asset", &asset)
+	require.NoError(t, err)
+	assert.Equal(t, assetV3{Name: "a1", Owner: "unknown", Size: 1}, asset)
+	assert.Equal(t, 1, env.SchemaVersion)
+
+	stub.MockTransactionStart("tx2")
+	require.NoError(t, schema.PutState(stub, "asset", assetV3{Name: "a1", Owner: "alice", Size: 5}))
+	stub.MockTransactionEnd("tx2")
+	env, err = schema.GetState(stub, "asset", &asset)
+	require.NoError(t, err)
+	assert.Equal(t, assetV3{Name: "a1", Owner: "alice", Size: 5}, asset)
+	assert.Equal(t, 3, env.SchemaVersion)
+	assert.Equal(t, "tx2", env.TxID)
+
+	env, err = schema.GetState(stub, "missing", &asset)
+	assert.NoError(t, err)
+	assert.Nil(t, env)
+}
+
+func TestSchemaUnwrapErrors(t *testing.T) {
+	failing := envelope.NewSchema(2).Upcaster(1, func(json.RawMessage) (json.RawMessage, error) {
+		return nil, errors.New("bad data")
+	})
+	tests := []struct {
+		name     string
+		schema   *envelope.Schema
+		envelope string
+		errMsg   string
+	}{
+		{"Newer", envelope.NewSchema(1), `{"schemaVersion":2,"data":{}}`, "envelope schema version 2 is newer than 1"},
+		{"MissingUpcaster", envelope.NewSchema(3), `{"schemaVersion":1,"data":{}}`, "no upcaster from schema version 1"},
+		{"FailingUpcaster", failing, `{"schemaVersion":1,"data":{}}`, "failed to upcast from schema version 1: bad data"},
+		{"Malformed", envelope.NewSchema(1), `not json`, "failed to unmarshal envelope: invalid character 'o' in literal null (expecting 'u')"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var v map[string]interface{}
+			_, err := tt.schema.Unwrap([]byte(tt.envelope), &v)
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestSchemaInvalidVersion(t *testing.T) {
+	_, err := envelope.NewSchema(0).Wrap(newStub(t, "Org1MSP"), assetV1{})
+	assert.EqualError(t, err, "schema version must be positive, got 0")
+}
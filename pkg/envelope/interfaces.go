@@ -0,0 +1,17 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package envelope
+
+// ChaincodeStubInterface is the subset of the chaincode stub used to write
+// and read envelopes.
+type ChaincodeStubInterface interface {
+	// GetCreator returns the identity submitting the transaction.
+	GetCreator() ([]byte, error)
+	// GetTxID returns the ID of the transaction.
+	GetTxID() string
+	// GetState returns the value of key.
+	GetState(key string) ([]byte, error)
+	// PutState sets key to value.
+	PutState(key string, value []byte) error
+}
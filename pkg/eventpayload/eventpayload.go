@@ -0,0 +1,73 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package eventpayload encodes and decodes the compressed payloads of
+// chaincode events. A chaincode started with the shim.WithEventCompression
+// option compresses the event payloads above a size with gzip, in an
+// envelope starting with Marker; clients receiving the events decode them
+// with Decode, which returns the other payloads as they are:
+//
+//	payload, err := eventpayload.Decode(event.Payload)
+//
+// The package has no dependencies outside the standard library, so that
+// client applications can use it without the shim.
+package eventpayload
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+)
+
+// Marker starts the envelope of compressed payloads. It starts with a null
+// byte, which JSON and text payloads never start with, and is followed by
+// the gzip stream of the payload.
+const Marker = "\x00fabric-gzip\x00"
+
+// compressionLevel is the fixed gzip level of Compress, part of the format:
+// changing it changes the compressed bytes.
+const compressionLevel = gzip.BestCompression
+
+// IsCompressed reports whether payload is in the envelope of compressed
+// payloads.
+func IsCompressed(payload []byte) bool {
+	return bytes.HasPrefix(payload, []byte(Marker))
+}
+
+// Compress returns payload compressed in an envelope. The gzip header
+// holds no name or modification time, so that Compress returns the same
+// bytes for the same payload on every endorser running the same binary.
+func Compress(payload []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(Marker)
+	w, err := gzip.NewWriterLevel(&buf, compressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(payload); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decode returns the decompressed payload if payload is in the envelope of
+// compressed payloads, and payload itself otherwise.
+func Decode(payload []byte) ([]byte, error) {
+	if !IsCompressed(payload) {
+		return payload, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(payload[len(Marker):]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress event payload: %s", err)
+	}
+	defer r.Close()
+	decompressed, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress event payload: %s", err)
+	}
+	return decompressed, nil
+}
@@ -0,0 +1,54 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package eventpayload_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/eventpayload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompress(t *testing.T) {
+	payload := bytes.Repeat([]byte(`{"owner":"alice","size":10}`), 100)
+
+	compressed, err := eventpayload.Compress(payload)
+	require.NoError(t, err)
+	assert.True(t, eventpayload.IsCompressed(compressed))
+	assert.Less(t, len(compressed), len(payload))
+
+	// compression is deterministic
+	again, err := eventpayload.Compress(payload)
+	require.NoError(t, err)
+	assert.Equal(t, compressed, again)
+
+	decoded, err := eventpayload.Decode(compressed)
+	require.NoError(t, err)
+	assert.Equal(t, payload, decoded)
+
+	empty, err := eventpayload.Compress(nil)
+	require.NoError(t, err)
+	decoded, err = eventpayload.Decode(empty)
+	require.NoError(t, err)
+	assert.Empty(t, decoded)
+}
+
+func TestDecode(t *testing.T) {
+	for _, payload := range [][]byte{nil, []byte(`{"owner":"alice"}`), {0x00, 0x01}} {
+		assert.False(t, eventpayload.IsCompressed(payload))
+		decoded, err := eventpayload.Decode(payload)
+		require.NoError(t, err)
+		assert.Equal(t, payload, decoded)
+	}
+
+	_, err := eventpayload.Decode([]byte(eventpayload.Marker + "not a gzip stream"))
+	assert.EqualError(t, err, "failed to decompress event payload: gzip: invalid header")
+
+	compressed, err := eventpayload.Compress([]byte("payload"))
+	require.NoError(t, err)
+	_, err = eventpayload.Decode(compressed[:len(compressed)-4])
+	assert.EqualError(t, err, "failed to decompress event payload: unexpected EOF")
+}
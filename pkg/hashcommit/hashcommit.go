@@ -0,0 +1,72 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package hashcommit implements the commit-reveal pattern used to transfer
+// private assets: the preimage of a value is written to a private data
+// collection, where peers that are not members of the collection can only
+// see its hash, and the value is later revealed to anyone who needs to
+// verify it against that hash.
+//
+// A typical flow is:
+//
+//	salt := ... // at least 16 random bytes, passed in the transient map
+//	stub.PutPrivateData(collection, key, hashcommit.Preimage(value, salt))
+//
+// and, later, on any peer:
+//
+//	ok, err := hashcommit.VerifyAgainstPrivateDataHash(stub, collection, key, value, salt)
+//
+// The salt prevents values drawn from a small domain, such as prices, from
+// being recovered from the hash by brute force.
+package hashcommit
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+)
+
+// Preimage returns the bytes whose hash is CommitHash(value, salt). These are
+// the bytes to write to the private data collection.
+func Preimage(value, salt []byte) []byte {
+	preimage := make([]byte, 4, 4+len(salt)+len(value))
+	binary.BigEndian.PutUint32(preimage, uint32(len(salt)))
+	preimage = append(preimage, salt...)
+	return append(preimage, value...)
+}
+
+// OpenPreimage splits a preimage returned by Preimage into its value and
+// salt.
+func OpenPreimage(preimage []byte) (value, salt []byte, err error) {
+	if len(preimage) < 4 {
+		return nil, nil, fmt.Errorf("preimage too short: %d bytes", len(preimage))
+	}
+	saltLen := binary.BigEndian.Uint32(preimage)
+	if uint64(saltLen) > uint64(len(preimage)-4) {
+		return nil, nil, fmt.Errorf("preimage salt length %d exceeds preimage", saltLen)
+	}
+	return preimage[4+saltLen:], preimage[4 : 4+saltLen], nil
+}
+
+// CommitHash returns the SHA-256 hash of Preimage(value, salt). It can be
+// written to the public state and equals the private data hash of a key
+// whose value is that preimage.
+func CommitHash(value, salt []byte) []byte {
+	hash := sha256.Sum256(Preimage(value, salt))
+	return hash[:]
+}
+
+// VerifyAgainstPrivateDataHash reports whether the value of key in collection
+// is Preimage(value, salt). It only reads the private data hash, so it can
+// be used by peers that are not members of the collection.
+func VerifyAgainstPrivateDataHash(stub ChaincodeStubInterface, collection, key string, value, salt []byte) (bool, error) {
+	hash, err := stub.GetPrivateDataHash(collection, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to get private data hash of %s in collection %s: %s", key, collection, err)
+	}
+	if hash == nil {
+		return false, nil
+	}
+	return bytes.Equal(hash, CommitHash(value, salt)), nil
+}
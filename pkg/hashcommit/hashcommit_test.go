@@ -0,0 +1,71 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package hashcommit_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/hashcommit"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPreimage(t *testing.T) {
+	preimage := hashcommit.Preimage([]byte("value"), []byte("salt"))
+	assert.Equal(t, []byte("\x00\x00\x00\x04saltvalue"), preimage)
+
+	value, salt, err := hashcommit.OpenPreimage(preimage)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+	assert.Equal(t, []byte("salt"), salt)
+
+	_, _, err = hashcommit.OpenPreimage([]byte("abc"))
+	assert.EqualError(t, err, "preimage too short: 3 bytes")
+	_, _, err = hashcommit.OpenPreimage([]byte("\x00\x00\x00\x05salt"))
+	assert.EqualError(t, err, "preimage salt length 5 exceeds preimage")
+
+	// the salt length prevents moving bytes between salt and value
+	assert.NotEqual(t,
+		hashcommit.CommitHash([]byte("value"), []byte("salt")),
+		hashcommit.CommitHash([]byte("tvalue"), []byte("sal")),
+	)
+}
+
+func TestVerifyAgainstPrivateDataHash(t *testing.T) {
+	salt := []byte("0123456789abcdef")
+	stub := shimtest.NewStubBuilder().Build()
+
+	stub.MockTransactionStart("tx1")
+	err := stub.PutPrivateData("col", "asset", hashcommit.Preimage([]byte("100"), salt))
+	require.NoError(t, err)
+	stub.MockTransactionEnd("tx1")
+
+	tests := []struct {
+		name       string
+		collection string
+		key        string
+		value      string
+		salt       []byte
+		expected   bool
+		err        string
+	}{
+		{name: "Match", collection: "col", key: "asset", value: "100", salt: salt, expected: true},
+		{name: "WrongValue", collection: "col", key: "asset", value: "101", salt: salt},
+		{name: "WrongSalt", collection: "col", key: "asset", value: "100", salt: []byte("salt")},
+		{name: "MissingKey", collection: "col", key: "missing", value: "100", salt: salt},
+		{name: "Error", key: "asset", value: "100", salt: salt, err: "failed to get private data hash of asset in collection : collection must not be an empty string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, err := hashcommit.VerifyAgainstPrivateDataHash(stub, tt.collection, tt.key, []byte(tt.value), tt.salt)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, ok)
+		})
+	}
+}
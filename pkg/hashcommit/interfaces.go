@@ -0,0 +1,12 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package hashcommit
+
+// ChaincodeStubInterface is the subset of the chaincode stub used by
+// VerifyAgainstPrivateDataHash.
+type ChaincodeStubInterface interface {
+	// GetPrivateDataHash returns the hash of the value of key in collection.
+	// It is available to peers that are not members of the collection.
+	GetPrivateDataHash(collection, key string) ([]byte, error)
+}
@@ -0,0 +1,90 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+)
+
+const (
+	// lifecycleChaincode is the name of the chaincode managing chaincode
+	// definitions.
+	lifecycleChaincode = "_lifecycle"
+	// queryChaincodeDefinition is the function of _lifecycle returning the
+	// definition of a chaincode.
+	queryChaincodeDefinition = "QueryChaincodeDefinition"
+	// errorThreshold is the lowest status of an error response.
+	errorThreshold = 400
+)
+
+// ChaincodePolicy is the endorsement policy of a chaincode definition,
+// which is either a signature policy or a reference to a policy of the
+// channel configuration.
+type ChaincodePolicy struct {
+	// Signature is the signature policy, or nil if the definition
+	// references a policy of the channel configuration.
+	Signature *Policy
+	// ChannelConfigPolicyReference is the path of the referenced policy of
+	// the channel configuration, such as /Channel/Application/Endorsement.
+	ChannelConfigPolicyReference string
+}
+
+// String returns the signature policy in the syntax of Policy.String, or
+// the path of the referenced channel configuration policy.
+func (c *ChaincodePolicy) String() string {
+	if c.Signature != nil {
+		return c.Signature.String()
+	}
+	return c.ChannelConfigPolicyReference
+}
+
+// GetChaincodeEndorsementPolicy returns the endorsement policy of the
+// definition of chaincodeName committed on the channel of the transaction,
+// read from _lifecycle, so that it can be displayed or compared with the
+// key-level endorsement policies the chaincode sets. The peer must allow
+// chaincode to query _lifecycle.
+func GetChaincodeEndorsementPolicy(stub LifecycleStubInterface, chaincodeName string) (*ChaincodePolicy, error) {
+	args, err := proto.Marshal(&lifecycle.QueryChaincodeDefinitionArgs{Name: chaincodeName})
+	if err != nil {
+		return nil, err
+	}
+	res := stub.InvokeChaincode(lifecycleChaincode, [][]byte{[]byte(queryChaincodeDefinition), args}, "")
+	if res.Status >= errorThreshold {
+		return nil, fmt.Errorf("failed to query definition of chaincode %s: %s", chaincodeName, res.Message)
+	}
+	def := &lifecycle.QueryChaincodeDefinitionResult{}
+	if err := proto.Unmarshal(res.Payload, def); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal definition of chaincode %s: %s", chaincodeName, err)
+	}
+	return ParseChaincodePolicy(def.ValidationParameter)
+}
+
+// ParseChaincodePolicy decodes the serialized ApplicationPolicy of a
+// chaincode definition.
+func ParseChaincodePolicy(policy []byte) (*ChaincodePolicy, error) {
+	ap := &pb.ApplicationPolicy{}
+	if err := proto.Unmarshal(policy, ap); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal application policy: %s", err)
+	}
+	switch t := ap.Type.(type) {
+	case *pb.ApplicationPolicy_SignaturePolicy:
+		if t.SignaturePolicy == nil {
+			return nil, errors.New("application policy has an empty signature policy")
+		}
+		signature, err := policyFromEnvelope(t.SignaturePolicy)
+		if err != nil {
+			return nil, err
+		}
+		return &ChaincodePolicy{Signature: signature}, nil
+	case *pb.ApplicationPolicy_ChannelConfigPolicyReference:
+		return &ChaincodePolicy{ChannelConfigPolicyReference: t.ChannelConfigPolicyReference}, nil
+	default:
+		return nil, fmt.Errorf("unsupported application policy type %T", t)
+	}
+}
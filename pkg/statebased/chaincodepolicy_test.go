@@ -0,0 +1,91 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type lifecycleStub struct {
+	definitions map[string]*lifecycle.QueryChaincodeDefinitionResult
+}
+
+func (s *lifecycleStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	if chaincodeName != "_lifecycle" || channel != "" || len(args) != 2 || string(args[0]) != "QueryChaincodeDefinition" {
+		return pb.Response{Status: 500, Message: "unexpected invocation"}
+	}
+	query := &lifecycle.QueryChaincodeDefinitionArgs{}
+	if err := proto.Unmarshal(args[1], query); err != nil {
+		return pb.Response{Status: 500, Message: err.Error()}
+	}
+	def, ok := s.definitions[query.Name]
+	if !ok {
+		return pb.Response{Status: 404, Message: "namespace " + query.Name + " is not defined"}
+	}
+	payload, err := proto.Marshal(def)
+	if err != nil {
+		return pb.Response{Status: 500, Message: err.Error()}
+	}
+	return pb.Response{Status: 200, Payload: payload}
+}
+
+func applicationPolicy(t *testing.T, ap *pb.ApplicationPolicy) []byte {
+	b, err := proto.Marshal(ap)
+	require.NoError(t, err)
+	return b
+}
+
+func TestGetChaincodeEndorsementPolicy(t *testing.T) {
+	signature := statebased.OutOf(2,
+		statebased.SignedBy(statebased.RoleTypePeer, "Org1"),
+		statebased.SignedBy(statebased.RoleTypePeer, "Org2"),
+		statebased.SignedBy(statebased.RoleTypePeer, "Org3"),
+	)
+	spe, err := signature.Envelope()
+	require.NoError(t, err)
+
+	stub := &lifecycleStub{definitions: map[string]*lifecycle.QueryChaincodeDefinitionResult{
+		"assets": {
+			ValidationParameter: applicationPolicy(t, &pb.ApplicationPolicy{
+				Type: &pb.ApplicationPolicy_SignaturePolicy{SignaturePolicy: spe},
+			}),
+		},
+		"marbles": {
+			ValidationParameter: applicationPolicy(t, &pb.ApplicationPolicy{
+				Type: &pb.ApplicationPolicy_ChannelConfigPolicyReference{
+					ChannelConfigPolicyReference: "/Channel/Application/Endorsement",
+				},
+			}),
+		},
+	}}
+
+	policy, err := statebased.GetChaincodeEndorsementPolicy(stub, "assets")
+	require.NoError(t, err)
+	assert.Equal(t, &statebased.ChaincodePolicy{Signature: signature}, policy)
+	assert.Equal(t, "OutOf(2, 'Org1.peer', 'Org2.peer', 'Org3.peer')", policy.String())
+
+	policy, err = statebased.GetChaincodeEndorsementPolicy(stub, "marbles")
+	require.NoError(t, err)
+	assert.Nil(t, policy.Signature)
+	assert.Equal(t, "/Channel/Application/Endorsement", policy.ChannelConfigPolicyReference)
+	assert.Equal(t, "/Channel/Application/Endorsement", policy.String())
+
+	_, err = statebased.GetChaincodeEndorsementPolicy(stub, "missing")
+	assert.EqualError(t, err, "failed to query definition of chaincode missing: namespace missing is not defined")
+}
+
+func TestParseChaincodePolicyErrors(t *testing.T) {
+	_, err := statebased.ParseChaincodePolicy([]byte("garbage"))
+	assert.Contains(t, err.Error(), "failed to unmarshal application policy")
+
+	_, err = statebased.ParseChaincodePolicy(nil)
+	assert.EqualError(t, err, "unsupported application policy type <nil>")
+}
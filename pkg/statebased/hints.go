@@ -0,0 +1,148 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// HintsVersion is the version of the endorsement hints envelope written by
+// WrapPayload.
+const HintsVersion = 1
+
+// Hints tell clients which organizations must endorse a transaction for it
+// to pass validation, so that they can select endorsers before submitting
+// it. Chaincode returns them in the payload of its response with
+// WrapPayload, in the envelope
+//
+//	{
+//	  "endorsementHintsVersion": 1,
+//	  "endorsementHints": {
+//	    "requiredOrgs": ["Org1MSP", "Org2MSP"],
+//	    "keys": [{"key": "asset1", "orgs": ["Org1MSP", "Org2MSP"]}]
+//	  },
+//	  "payload": "<base64 encoding of the payload>"
+//	}
+//
+// and clients read them with ParseHints. The endorsementHintsVersion field
+// identifies the envelope; payloads that are not wrapped must not be JSON
+// objects with that field.
+type Hints struct {
+	// RequiredOrgs are the MSP IDs of the organizations whose endorsement
+	// the transaction requires, in lexical order.
+	RequiredOrgs []string `json:"requiredOrgs,omitempty"`
+	// Keys are the keys written by the transaction whose key-level
+	// endorsement policy requires the endorsement of specific
+	// organizations.
+	Keys []KeyHint `json:"keys,omitempty"`
+}
+
+// KeyHint lists the organizations required by the key-level endorsement
+// policy of a key.
+type KeyHint struct {
+	// Collection is the private data collection of the key, or empty for
+	// the public state.
+	Collection string `json:"collection,omitempty"`
+	// Key is the key.
+	Key string `json:"key"`
+	// Orgs are the MSP IDs of the organizations required by the policy, in
+	// lexical order.
+	Orgs []string `json:"orgs"`
+}
+
+// hintsEnvelope is the JSON encoding of a payload with hints.
+type hintsEnvelope struct {
+	Version int    `json:"endorsementHintsVersion"`
+	Hints   *Hints `json:"endorsementHints"`
+	Payload []byte `json:"payload"`
+}
+
+// AddOrgs adds orgs to the required organizations of h.
+func (h *Hints) AddOrgs(orgs ...string) {
+	required := map[string]bool{}
+	for _, org := range h.RequiredOrgs {
+		required[org] = true
+	}
+	for _, org := range orgs {
+		if !required[org] {
+			required[org] = true
+			h.RequiredOrgs = append(h.RequiredOrgs, org)
+		}
+	}
+	sort.Strings(h.RequiredOrgs)
+}
+
+// AddKey adds the organizations required by policy, the key-level
+// endorsement policy of key in collection, to h. An empty policy adds
+// nothing, since the chaincode or collection endorsement policy applies to
+// the key. Like Preflight, AddKey only understands policies built with
+// KeyEndorsementPolicy.
+func (h *Hints) AddKey(collection, key string, policy []byte) error {
+	if len(policy) == 0 {
+		return nil
+	}
+	ep, err := NewStateEP(policy)
+	if err != nil {
+		return fmt.Errorf("failed to parse endorsement policy of key %s: %s", key, err)
+	}
+	orgs := ep.ListOrgs()
+	sort.Strings(orgs)
+	h.Keys = append(h.Keys, KeyHint{Collection: collection, Key: key, Orgs: orgs})
+	h.AddOrgs(orgs...)
+	return nil
+}
+
+// HintKeys returns the hints for a transaction writing keys, derived from
+// their key-level endorsement policies.
+func HintKeys(stub ChaincodeStubInterface, keys ...string) (*Hints, error) {
+	hints := &Hints{}
+	for _, key := range keys {
+		ep, err := stub.GetStateValidationParameter(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get endorsement policy of key %s: %s", key, err)
+		}
+		if err := hints.AddKey("", key, ep); err != nil {
+			return nil, err
+		}
+	}
+	return hints, nil
+}
+
+// WrapPayload returns payload wrapped in an envelope with hints, to be
+// returned as the payload of the response of the chaincode:
+//
+//	hints, err := statebased.HintKeys(stub, key)
+//	...
+//	payload, err := statebased.WrapPayload(asset, hints)
+//	...
+//	return shim.Success(payload)
+func WrapPayload(payload []byte, hints *Hints) ([]byte, error) {
+	if hints == nil {
+		hints = &Hints{}
+	}
+	return json.Marshal(&hintsEnvelope{Version: HintsVersion, Hints: hints, Payload: payload})
+}
+
+// ParseHints returns the payload and the hints of a payload wrapped by
+// WrapPayload. Payloads without hints are returned unchanged with nil
+// hints.
+func ParseHints(payload []byte) ([]byte, *Hints, error) {
+	if !bytes.HasPrefix(bytes.TrimSpace(payload), []byte("{")) {
+		return payload, nil, nil
+	}
+	var env hintsEnvelope
+	if err := json.Unmarshal(payload, &env); err != nil || env.Version == 0 {
+		return payload, nil, nil
+	}
+	if env.Version > HintsVersion {
+		return nil, nil, fmt.Errorf("unsupported endorsement hints version %d", env.Version)
+	}
+	if env.Hints == nil {
+		env.Hints = &Hints{}
+	}
+	return env.Payload, env.Hints, nil
+}
@@ -0,0 +1,83 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHintKeys(t *testing.T) {
+	stub := shimtest.NewStubBuilder().Build()
+
+	ep, err := statebased.NewStateEP(nil)
+	require.NoError(t, err)
+	require.NoError(t, ep.AddOrgs(statebased.RoleTypePeer, "Org2", "Org1"))
+	policy1, err := ep.Policy()
+	require.NoError(t, err)
+	ep.DelOrgs("Org1")
+	require.NoError(t, ep.AddOrgs(statebased.RoleTypePeer, "Org3"))
+	policy2, err := ep.Policy()
+	require.NoError(t, err)
+
+	stub.MockTransactionStart("tx1")
+	stub.SetStateValidationParameter("asset1", policy1)
+	stub.SetStateValidationParameter("asset2", policy2)
+	stub.MockTransactionEnd("tx1")
+
+	hints, err := statebased.HintKeys(stub, "asset1", "asset2", "noPolicy")
+	require.NoError(t, err)
+	assert.Equal(t, &statebased.Hints{
+		RequiredOrgs: []string{"Org1", "Org2", "Org3"},
+		Keys: []statebased.KeyHint{
+			{Key: "asset1", Orgs: []string{"Org1", "Org2"}},
+			{Key: "asset2", Orgs: []string{"Org2", "Org3"}},
+		},
+	}, hints)
+
+	err = hints.AddKey("collection", "asset3", []byte("garbage"))
+	assert.Contains(t, err.Error(), "failed to parse endorsement policy of key asset3")
+}
+
+func TestWrapPayload(t *testing.T) {
+	hints := &statebased.Hints{}
+	hints.AddOrgs("Org2", "Org1", "Org2")
+	assert.Equal(t, []string{"Org1", "Org2"}, hints.RequiredOrgs)
+
+	wrapped, err := statebased.WrapPayload([]byte(`{"id":"asset1"}`), hints)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"endorsementHintsVersion": 1,
+		"endorsementHints": {"requiredOrgs": ["Org1", "Org2"]},
+		"payload": "eyJpZCI6ImFzc2V0MSJ9"
+	}`, string(wrapped))
+
+	payload, parsed, err := statebased.ParseHints(wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, []byte(`{"id":"asset1"}`), payload)
+	assert.Equal(t, hints, parsed)
+
+	wrapped, err = statebased.WrapPayload(nil, nil)
+	require.NoError(t, err)
+	payload, parsed, err = statebased.ParseHints(wrapped)
+	require.NoError(t, err)
+	assert.Empty(t, payload)
+	assert.Equal(t, &statebased.Hints{}, parsed)
+}
+
+func TestParseHintsUnwrapped(t *testing.T) {
+	for _, payload := range []string{"", "plain text", `{"id":"asset1"}`, `{"id":`, `["Org1"]`} {
+		parsed, hints, err := statebased.ParseHints([]byte(payload))
+		assert.NoError(t, err)
+		assert.Nil(t, hints)
+		assert.Equal(t, payload, string(parsed))
+	}
+
+	_, _, err := statebased.ParseHints([]byte(`{"endorsementHintsVersion": 2}`))
+	assert.EqualError(t, err, "unsupported endorsement hints version 2")
+}
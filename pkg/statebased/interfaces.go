@@ -3,7 +3,11 @@
 
 package statebased
 
-import "fmt"
+import (
+	"fmt"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
 
 // RoleType of an endorsement policy's identity
 type RoleType string
@@ -13,11 +17,16 @@ const (
 	RoleTypeMember = RoleType("MEMBER")
 	// RoleTypePeer identifies an org's peer identity
 	RoleTypePeer = RoleType("PEER")
+	// RoleTypeAdmin identifies an org's admin identity in a Policy
+	RoleTypeAdmin = RoleType("ADMIN")
+	// RoleTypeClient identifies an org's client identity in a Policy
+	RoleTypeClient = RoleType("CLIENT")
 )
 
 // RoleTypeDoesNotExistError is returned by function AddOrgs of
-// KeyEndorsementPolicy if a role type that does not match one
-// specified above is passed as an argument.
+// KeyEndorsementPolicy if a role type other than RoleTypeMember and
+// RoleTypePeer is passed as an argument, and by Policy if a role type that
+// does not match one specified above is used.
 type RoleTypeDoesNotExistError struct {
 	RoleType RoleType
 }
@@ -29,7 +38,8 @@ func (r *RoleTypeDoesNotExistError) Error() string {
 // KeyEndorsementPolicy provides a set of convenience methods to create and
 // modify a state-based endorsement policy. Endorsement policies created by
 // this convenience layer will always be a logical AND of "<ORG>.peer"
-// principals for one or more ORGs specified by the caller.
+// principals for one or more ORGs specified by the caller; use Policy for
+// other combinations.
 type KeyEndorsementPolicy interface {
 	// Policy returns the endorsement policy as bytes
 	Policy() ([]byte, error)
@@ -49,3 +59,36 @@ type KeyEndorsementPolicy interface {
 	// ListOrgs returns an array of channel orgs that are required to endorse chnages
 	ListOrgs() []string
 }
+
+// ChaincodeStubInterface is the subset of the chaincode stub used by
+// Preflight.
+type ChaincodeStubInterface interface {
+	// GetCreator returns the serialized identity of the invoker.
+	GetCreator() ([]byte, error)
+
+	// GetStateValidationParameter returns the key-level endorsement policy
+	// of key.
+	GetStateValidationParameter(key string) ([]byte, error)
+}
+
+// PrivateDataStubInterface is the subset of the chaincode stub used by
+// PreflightPrivateData and SetPrivateDataEndorsementOrgs.
+type PrivateDataStubInterface interface {
+	// GetCreator returns the serialized identity of the invoker.
+	GetCreator() ([]byte, error)
+
+	// GetPrivateDataValidationParameter returns the key-level endorsement
+	// policy of key in collection.
+	GetPrivateDataValidationParameter(collection, key string) ([]byte, error)
+
+	// SetPrivateDataValidationParameter sets the key-level endorsement
+	// policy of key in collection.
+	SetPrivateDataValidationParameter(collection, key string, ep []byte) error
+}
+
+// LifecycleStubInterface is the subset of the chaincode stub used by
+// GetChaincodeEndorsementPolicy.
+type LifecycleStubInterface interface {
+	// InvokeChaincode invokes chaincodeName with args on channel.
+	InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response
+}
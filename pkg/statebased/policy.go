@@ -0,0 +1,283 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// Policy is a signature policy: either the signature of a principal, the
+// role of an organization, or a rule satisfied when N of its sub-policies
+// are. A policy with a Role is a principal, any other policy is a rule. Policies are built with SignedBy, OutOf, And and Or, for example
+//
+//	statebased.OutOf(2,
+//		statebased.SignedBy(statebased.RoleTypePeer, "Org1MSP"),
+//		statebased.SignedBy(statebased.RoleTypePeer, "Org2MSP"),
+//		statebased.And(
+//			statebased.SignedBy(statebased.RoleTypePeer, "Org3MSP"),
+//			statebased.SignedBy(statebased.RoleTypeAdmin, "Org3MSP"),
+//		),
+//	)
+//
+// and their Bytes set as the key-level endorsement policy of a key.
+type Policy struct {
+	// MSPID is the MSP ID of the organization which must sign, if the
+	// policy is a principal.
+	MSPID string
+	// Role is the role of the identity of the organization which must sign,
+	// if the policy is a principal.
+	Role RoleType
+	// N is the number of Rules which must be satisfied, if the policy is a
+	// rule.
+	N int
+	// Rules are the sub-policies of the rule.
+	Rules []*Policy
+	// AllowZero permits N to be zero, which makes the rule satisfied by
+	// any transaction. Without it such rules fail to encode, so that a
+	// policy left empty by mistake does not endorse everything.
+	AllowZero bool
+}
+
+// SignedBy returns the policy satisfied by the signature of an identity
+// with role in the organization mspID.
+func SignedBy(role RoleType, mspID string) *Policy {
+	return &Policy{MSPID: mspID, Role: role}
+}
+
+// OutOf returns the policy satisfied when n of policies are.
+func OutOf(n int, policies ...*Policy) *Policy {
+	return &Policy{N: n, Rules: policies}
+}
+
+// And returns the policy satisfied when all of policies are.
+func And(policies ...*Policy) *Policy {
+	return OutOf(len(policies), policies...)
+}
+
+// Or returns the policy satisfied when any of policies is.
+func Or(policies ...*Policy) *Policy {
+	return OutOf(1, policies...)
+}
+
+// ParsePolicy decodes a serialized SignaturePolicyEnvelope, such as a
+// key-level endorsement policy. Only principals which are roles of an
+// organization are supported.
+func ParsePolicy(policy []byte) (*Policy, error) {
+	spe := &common.SignaturePolicyEnvelope{}
+	if err := proto.Unmarshal(policy, spe); err != nil {
+		return nil, fmt.Errorf("Error unmarshaling to SignaturePolicy: %s", err)
+	}
+	return policyFromEnvelope(spe)
+}
+
+func policyFromEnvelope(spe *common.SignaturePolicyEnvelope) (*Policy, error) {
+	principals := make([]*Policy, len(spe.Identities))
+	for i, identity := range spe.Identities {
+		if identity.PrincipalClassification != msp.MSPPrincipal_ROLE {
+			return nil, fmt.Errorf("unsupported principal classification %s", identity.PrincipalClassification)
+		}
+		msprole := &msp.MSPRole{}
+		if err := proto.Unmarshal(identity.Principal, msprole); err != nil {
+			return nil, fmt.Errorf("error unmarshaling msp principal: %s", err)
+		}
+		role, err := roleType(msprole.GetRole())
+		if err != nil {
+			return nil, err
+		}
+		principals[i] = SignedBy(role, msprole.GetMspIdentifier())
+	}
+	return policyFromRule(spe.Rule, principals)
+}
+
+func policyFromRule(rule *common.SignaturePolicy, principals []*Policy) (*Policy, error) {
+	switch t := rule.GetType().(type) {
+	case *common.SignaturePolicy_SignedBy:
+		if t.SignedBy < 0 || int(t.SignedBy) >= len(principals) {
+			return nil, fmt.Errorf("identity index %d out of range", t.SignedBy)
+		}
+		p := *principals[t.SignedBy]
+		return &p, nil
+	case *common.SignaturePolicy_NOutOf_:
+		rules := make([]*Policy, len(t.NOutOf.GetRules()))
+		for i, r := range t.NOutOf.GetRules() {
+			p, err := policyFromRule(r, principals)
+			if err != nil {
+				return nil, err
+			}
+			rules[i] = p
+		}
+		p := OutOf(int(t.NOutOf.GetN()), rules...)
+		p.AllowZero = p.N == 0
+		return p, nil
+	default:
+		return nil, fmt.Errorf("unsupported signature policy type %T", t)
+	}
+}
+
+// Bytes returns the policy as a serialized SignaturePolicyEnvelope.
+func (p *Policy) Bytes() ([]byte, error) {
+	spe, err := p.Envelope()
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(spe)
+}
+
+// Envelope returns the policy as a SignaturePolicyEnvelope. Each principal
+// is listed once among its identities, in the order of first use.
+func (p *Policy) Envelope() (*common.SignaturePolicyEnvelope, error) {
+	spe := &common.SignaturePolicyEnvelope{Version: 0}
+	indexes := map[principalKey]int32{}
+	rule, err := p.rule(spe, indexes)
+	if err != nil {
+		return nil, err
+	}
+	spe.Rule = rule
+	return spe, nil
+}
+
+// principalKey identifies a principal among the identities of an envelope.
+type principalKey struct {
+	mspID string
+	role  RoleType
+}
+
+// rule returns the SignaturePolicy of p, adding its principals missing
+// from indexes to the identities of spe.
+func (p *Policy) rule(spe *common.SignaturePolicyEnvelope, indexes map[principalKey]int32) (*common.SignaturePolicy, error) {
+	if p.isPrincipal() {
+		if p.MSPID == "" {
+			return nil, fmt.Errorf("principal %s has no MSP ID", p.Role)
+		}
+		key := principalKey{mspID: p.MSPID, role: p.Role}
+		index, ok := indexes[key]
+		if !ok {
+			mspRole, err := mspRoleType(p.Role)
+			if err != nil {
+				return nil, err
+			}
+			principal, err := proto.Marshal(&msp.MSPRole{Role: mspRole, MspIdentifier: p.MSPID})
+			if err != nil {
+				return nil, err
+			}
+			index = int32(len(spe.Identities))
+			indexes[key] = index
+			spe.Identities = append(spe.Identities, &msp.MSPPrincipal{
+				PrincipalClassification: msp.MSPPrincipal_ROLE,
+				Principal:               principal,
+			})
+		}
+		return &common.SignaturePolicy{
+			Type: &common.SignaturePolicy_SignedBy{SignedBy: index},
+		}, nil
+	}
+
+	if p.N < 0 || p.N > len(p.Rules) {
+		return nil, fmt.Errorf("cannot require %d out of %d rules", p.N, len(p.Rules))
+	}
+	if p.N == 0 && !p.AllowZero {
+		return nil, fmt.Errorf("rule requiring 0 out of %d rules is satisfied by any transaction", len(p.Rules))
+	}
+	rules := make([]*common.SignaturePolicy, len(p.Rules))
+	for i, r := range p.Rules {
+		if r == nil {
+			return nil, fmt.Errorf("rule %d is nil", i)
+		}
+		rule, err := r.rule(spe, indexes)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = rule
+	}
+	return &common.SignaturePolicy{
+		Type: &common.SignaturePolicy_NOutOf_{
+			NOutOf: &common.SignaturePolicy_NOutOf{N: int32(p.N), Rules: rules},
+		},
+	}, nil
+}
+
+// isPrincipal reports whether p is the signature of a principal.
+func (p *Policy) isPrincipal() bool {
+	return p.Role != ""
+}
+
+// ListOrgs returns the MSP IDs of the organizations of the principals of the
+// policy, in the order of first use.
+func (p *Policy) ListOrgs() []string {
+	var orgs []string
+	seen := map[string]bool{}
+	var walk func(*Policy)
+	walk = func(p *Policy) {
+		if p == nil {
+			return
+		}
+		if p.isPrincipal() && !seen[p.MSPID] {
+			seen[p.MSPID] = true
+			orgs = append(orgs, p.MSPID)
+		}
+		for _, r := range p.Rules {
+			walk(r)
+		}
+	}
+	walk(p)
+	return orgs
+}
+
+// String returns the policy in the syntax of endorsement policies of the
+// peer CLI, such as OutOf(2, 'Org1MSP.peer', AND('Org2MSP.member',
+// 'Org3MSP.admin')).
+func (p *Policy) String() string {
+	if p.isPrincipal() {
+		return fmt.Sprintf("'%s.%s'", p.MSPID, strings.ToLower(string(p.Role)))
+	}
+	rules := make([]string, len(p.Rules))
+	for i, r := range p.Rules {
+		rules[i] = r.String()
+	}
+	switch {
+	case p.N == len(p.Rules) && p.N > 0:
+		return fmt.Sprintf("AND(%s)", strings.Join(rules, ", "))
+	case p.N == 1:
+		return fmt.Sprintf("OR(%s)", strings.Join(rules, ", "))
+	default:
+		return fmt.Sprintf("OutOf(%s)", strings.Join(append([]string{fmt.Sprint(p.N)}, rules...), ", "))
+	}
+}
+
+// mspRoleType returns the MSP role of role.
+func mspRoleType(role RoleType) (msp.MSPRole_MSPRoleType, error) {
+	switch role {
+	case RoleTypeMember:
+		return msp.MSPRole_MEMBER, nil
+	case RoleTypePeer:
+		return msp.MSPRole_PEER, nil
+	case RoleTypeAdmin:
+		return msp.MSPRole_ADMIN, nil
+	case RoleTypeClient:
+		return msp.MSPRole_CLIENT, nil
+	default:
+		return 0, &RoleTypeDoesNotExistError{RoleType: role}
+	}
+}
+
+// roleType returns the role type of the MSP role.
+func roleType(role msp.MSPRole_MSPRoleType) (RoleType, error) {
+	switch role {
+	case msp.MSPRole_MEMBER:
+		return RoleTypeMember, nil
+	case msp.MSPRole_PEER:
+		return RoleTypePeer, nil
+	case msp.MSPRole_ADMIN:
+		return RoleTypeAdmin, nil
+	case msp.MSPRole_CLIENT:
+		return RoleTypeClient, nil
+	default:
+		return "", fmt.Errorf("unsupported role %s", role)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyEnvelope(t *testing.T) {
+	policy := statebased.OutOf(2,
+		statebased.SignedBy(statebased.RoleTypePeer, "Org1"),
+		statebased.SignedBy(statebased.RoleTypePeer, "Org2"),
+		statebased.And(
+			statebased.SignedBy(statebased.RoleTypePeer, "Org1"),
+			statebased.SignedBy(statebased.RoleTypeAdmin, "Org3"),
+		),
+	)
+	spe, err := policy.Envelope()
+	require.NoError(t, err)
+
+	signedBy := func(i int32) *common.SignaturePolicy {
+		return &common.SignaturePolicy{Type: &common.SignaturePolicy_SignedBy{SignedBy: i}}
+	}
+	outOf := func(n int32, rules ...*common.SignaturePolicy) *common.SignaturePolicy {
+		return &common.SignaturePolicy{Type: &common.SignaturePolicy_NOutOf_{
+			NOutOf: &common.SignaturePolicy_NOutOf{N: n, Rules: rules},
+		}}
+	}
+	principal := func(role msp.MSPRole_MSPRoleType, mspID string) *msp.MSPPrincipal {
+		b, err := proto.Marshal(&msp.MSPRole{Role: role, MspIdentifier: mspID})
+		require.NoError(t, err)
+		return &msp.MSPPrincipal{PrincipalClassification: msp.MSPPrincipal_ROLE, Principal: b}
+	}
+	expected := &common.SignaturePolicyEnvelope{
+		Rule: outOf(2, signedBy(0), signedBy(1), outOf(2, signedBy(0), signedBy(2))),
+		Identities: []*msp.MSPPrincipal{
+			principal(msp.MSPRole_PEER, "Org1"),
+			principal(msp.MSPRole_PEER, "Org2"),
+			principal(msp.MSPRole_ADMIN, "Org3"),
+		},
+	}
+	assert.True(t, proto.Equal(expected, spe), "unexpected envelope %v", spe)
+	assert.Equal(t, []string{"Org1", "Org2", "Org3"}, policy.ListOrgs())
+}
+
+func TestPolicyRoundTrip(t *testing.T) {
+	var tests = []struct {
+		policy *statebased.Policy
+		str    string
+	}{
+		{
+			policy: statebased.SignedBy(statebased.RoleTypeMember, "Org1"),
+			str:    "'Org1.member'",
+		},
+		{
+			policy: statebased.And(
+				statebased.SignedBy(statebased.RoleTypePeer, "Org1"),
+				statebased.SignedBy(statebased.RoleTypePeer, "Org2"),
+			),
+			str: "AND('Org1.peer', 'Org2.peer')",
+		},
+		{
+			policy: statebased.Or(
+				statebased.SignedBy(statebased.RoleTypeClient, "Org1"),
+				statebased.And(
+					statebased.SignedBy(statebased.RoleTypePeer, "Org2"),
+					statebased.SignedBy(statebased.RoleTypeAdmin, "Org2"),
+				),
+			),
+			str: "OR('Org1.client', AND('Org2.peer', 'Org2.admin'))",
+		},
+		{
+			policy: statebased.OutOf(2,
+				statebased.SignedBy(statebased.RoleTypePeer, "Org1"),
+				statebased.SignedBy(statebased.RoleTypePeer, "Org2"),
+				statebased.SignedBy(statebased.RoleTypePeer, "Org3"),
+			),
+			str: "OutOf(2, 'Org1.peer', 'Org2.peer', 'Org3.peer')",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.str, func(t *testing.T) {
+			assert.Equal(t, test.str, test.policy.String())
+			b, err := test.policy.Bytes()
+			require.NoError(t, err)
+			parsed, err := statebased.ParsePolicy(b)
+			require.NoError(t, err)
+			assert.Equal(t, test.policy, parsed)
+		})
+	}
+}
+
+func TestPolicyAllowZero(t *testing.T) {
+	policy := statebased.OutOf(0, statebased.SignedBy(statebased.RoleTypePeer, "Org1"))
+	policy.AllowZero = true
+	b, err := policy.Bytes()
+	require.NoError(t, err)
+
+	parsed, err := statebased.ParsePolicy(b)
+	require.NoError(t, err)
+	assert.Equal(t, policy, parsed)
+}
+
+func TestParseKeyEndorsementPolicy(t *testing.T) {
+	ep, err := statebased.NewStateEP(nil)
+	require.NoError(t, err)
+	require.NoError(t, ep.AddOrgs(statebased.RoleTypePeer, "Org2", "Org1"))
+	b, err := ep.Policy()
+	require.NoError(t, err)
+
+	policy, err := statebased.ParsePolicy(b)
+	require.NoError(t, err)
+	assert.Equal(t, "AND('Org1.peer', 'Org2.peer')", policy.String())
+}
+
+func TestPolicyErrors(t *testing.T) {
+	_, err := statebased.OutOf(3,
+		statebased.SignedBy(statebased.RoleTypePeer, "Org1"),
+		statebased.SignedBy(statebased.RoleTypePeer, "Org2"),
+	).Bytes()
+	assert.EqualError(t, err, "cannot require 3 out of 2 rules")
+
+	_, err = statebased.SignedBy(statebased.RoleTypePeer, "").Bytes()
+	assert.EqualError(t, err, "principal PEER has no MSP ID")
+
+	_, err = statebased.SignedBy(statebased.RoleType("bogus"), "").Bytes()
+	assert.EqualError(t, err, "principal bogus has no MSP ID")
+
+	_, err = statebased.SignedBy("", "Org1").Bytes()
+	assert.EqualError(t, err, "rule requiring 0 out of 0 rules is satisfied by any transaction")
+
+	_, err = statebased.Or(statebased.OutOf(0, statebased.SignedBy(statebased.RoleTypePeer, "Org1"))).Bytes()
+	assert.EqualError(t, err, "rule requiring 0 out of 1 rules is satisfied by any transaction")
+
+	_, err = statebased.SignedBy(statebased.RoleType("unknown"), "Org1").Bytes()
+	assert.Equal(t, &statebased.RoleTypeDoesNotExistError{RoleType: statebased.RoleType("unknown")}, err)
+
+	b, err := proto.Marshal(&common.SignaturePolicyEnvelope{
+		Rule: &common.SignaturePolicy{Type: &common.SignaturePolicy_SignedBy{SignedBy: 1}},
+		Identities: []*msp.MSPPrincipal{
+			{PrincipalClassification: msp.MSPPrincipal_ROLE},
+		},
+	})
+	require.NoError(t, err)
+	_, err = statebased.ParsePolicy(b)
+	assert.EqualError(t, err, "identity index 1 out of range")
+
+	b, err = proto.Marshal(&common.SignaturePolicyEnvelope{
+		Identities: []*msp.MSPPrincipal{
+			{PrincipalClassification: msp.MSPPrincipal_ORGANIZATION_UNIT},
+		},
+	})
+	require.NoError(t, err)
+	_, err = statebased.ParsePolicy(b)
+	assert.EqualError(t, err, "unsupported principal classification ORGANIZATION_UNIT")
+}
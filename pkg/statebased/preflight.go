@@ -0,0 +1,95 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// PreflightResult describes how the key-level endorsement policy of a key
+// relates to the organization of the invoker.
+type PreflightResult struct {
+	// MSPID is the MSP ID of the invoker.
+	MSPID string
+	// HasKeyPolicy is false if the key has no key-level endorsement policy,
+	// in which case the chaincode endorsement policy applies.
+	HasKeyPolicy bool
+	// RequiredOrgs are the organizations whose endorsement the key-level
+	// endorsement policy requires, in lexical order.
+	RequiredOrgs []string
+	// InvokerOrgRequired is true if the organization of the invoker is one
+	// of RequiredOrgs.
+	InvokerOrgRequired bool
+}
+
+// Preflight reads the key-level endorsement policy of key and the MSP ID of
+// the invoker and reports whether the organization of the invoker is part of
+// the set of organizations required to endorse changes to key. It lets
+// applications warn users before they submit a transaction which is bound to
+// fail validation. Preflight only understands policies built with
+// KeyEndorsementPolicy.
+func Preflight(stub ChaincodeStubInterface, key string) (*PreflightResult, error) {
+	policy := func() ([]byte, error) {
+		ep, err := stub.GetStateValidationParameter(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get endorsement policy of key %s: %s", key, err)
+		}
+		return ep, nil
+	}
+	return preflight(stub.GetCreator, policy)
+}
+
+// PreflightPrivateData is Preflight for the key-level endorsement policy of
+// key in collection. The policy of a private data key is distinct from the
+// policy of the public key with the same name and from the endorsement
+// policy of the collection, which applies when the key has none.
+func PreflightPrivateData(stub PrivateDataStubInterface, collection, key string) (*PreflightResult, error) {
+	policy := func() ([]byte, error) {
+		ep, err := stub.GetPrivateDataValidationParameter(collection, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get endorsement policy of key %s in collection %s: %s", key, collection, err)
+		}
+		return ep, nil
+	}
+	return preflight(stub.GetCreator, policy)
+}
+
+func preflight(getCreator func() ([]byte, error), getPolicy func() ([]byte, error)) (*PreflightResult, error) {
+	creator, err := getCreator()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get invoker identity: %s", err)
+	}
+	sid := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, sid); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal invoker identity: %s", err)
+	}
+
+	policy, err := getPolicy()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PreflightResult{MSPID: sid.GetMspid()}
+	if len(policy) == 0 {
+		return result, nil
+	}
+
+	ep, err := NewStateEP(policy)
+	if err != nil {
+		return nil, err
+	}
+	result.HasKeyPolicy = true
+	result.RequiredOrgs = ep.ListOrgs()
+	sort.Strings(result.RequiredOrgs)
+	for _, org := range result.RequiredOrgs {
+		if org == result.MSPID {
+			result.InvokerOrgRequired = true
+		}
+	}
+	return result, nil
+}
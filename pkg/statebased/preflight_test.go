@@ -0,0 +1,59 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPreflight(t *testing.T) {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1", IdBytes: []byte("cert")})
+	assert.NoError(t, err)
+	stub := shimtest.NewStubBuilder().WithCreator(creator).Build()
+
+	ep, err := statebased.NewStateEP(nil)
+	assert.NoError(t, err)
+	ep.AddOrgs(statebased.RoleTypePeer, "Org2", "Org1")
+	policy, err := ep.Policy()
+	assert.NoError(t, err)
+
+	stub.MockTransactionStart("tx1")
+	stub.SetStateValidationParameter("required", policy)
+	ep.DelOrgs("Org1")
+	policy, err = ep.Policy()
+	assert.NoError(t, err)
+	stub.SetStateValidationParameter("notRequired", policy)
+	stub.MockTransactionEnd("tx1")
+
+	result, err := statebased.Preflight(stub, "required")
+	assert.NoError(t, err)
+	assert.Equal(t, &statebased.PreflightResult{
+		MSPID:              "Org1",
+		HasKeyPolicy:       true,
+		RequiredOrgs:       []string{"Org1", "Org2"},
+		InvokerOrgRequired: true,
+	}, result)
+
+	result, err = statebased.Preflight(stub, "notRequired")
+	assert.NoError(t, err)
+	assert.Equal(t, &statebased.PreflightResult{
+		MSPID:        "Org1",
+		HasKeyPolicy: true,
+		RequiredOrgs: []string{"Org2"},
+	}, result)
+
+	result, err = statebased.Preflight(stub, "noPolicy")
+	assert.NoError(t, err)
+	assert.Equal(t, &statebased.PreflightResult{MSPID: "Org1"}, result)
+
+	stub.Creator = []byte("garbage")
+	_, err = statebased.Preflight(stub, "required")
+	assert.Contains(t, err.Error(), "failed to unmarshal invoker identity")
+}
@@ -0,0 +1,38 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased
+
+import (
+	"errors"
+	"fmt"
+)
+
+// SetPrivateDataEndorsementOrgs sets the key-level endorsement policy of key
+// in collection to require the endorsement of each of orgs with the given
+// role. The policy replaces the endorsement policy of the collection for
+// this key only; the public key with the same name is not affected. Use
+// SetPrivateDataValidationParameter with a nil policy to remove it.
+func SetPrivateDataEndorsementOrgs(stub PrivateDataStubInterface, collection, key string, role RoleType, orgs ...string) error {
+	if collection == "" {
+		return errors.New("collection must not be an empty string")
+	}
+	if len(orgs) == 0 {
+		return errors.New("at least one organization is required")
+	}
+	ep, err := NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	if err := ep.AddOrgs(role, orgs...); err != nil {
+		return err
+	}
+	policy, err := ep.Policy()
+	if err != nil {
+		return err
+	}
+	if err := stub.SetPrivateDataValidationParameter(collection, key, policy); err != nil {
+		return fmt.Errorf("failed to set endorsement policy of key %s in collection %s: %s", key, collection, err)
+	}
+	return nil
+}
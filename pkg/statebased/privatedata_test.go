@@ -0,0 +1,56 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package statebased_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetPrivateDataEndorsementOrgs(t *testing.T) {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: "Org1", IdBytes: []byte("cert")})
+	require.NoError(t, err)
+	stub := shimtest.NewStubBuilder().WithCreator(creator).Build()
+
+	stub.MockTransactionStart("tx1")
+	require.NoError(t, statebased.SetPrivateDataEndorsementOrgs(stub, "coll", "key", statebased.RoleTypePeer, "Org2", "Org1"))
+	stub.MockTransactionEnd("tx1")
+
+	result, err := statebased.PreflightPrivateData(stub, "coll", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &statebased.PreflightResult{
+		MSPID:              "Org1",
+		HasKeyPolicy:       true,
+		RequiredOrgs:       []string{"Org1", "Org2"},
+		InvokerOrgRequired: true,
+	}, result)
+
+	// the public key with the same name has no policy
+	result, err = statebased.Preflight(stub, "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &statebased.PreflightResult{MSPID: "Org1"}, result)
+
+	result, err = statebased.PreflightPrivateData(stub, "other", "key")
+	assert.NoError(t, err)
+	assert.Equal(t, &statebased.PreflightResult{MSPID: "Org1"}, result)
+}
+
+func TestSetPrivateDataEndorsementOrgsErrors(t *testing.T) {
+	stub := shimtest.NewMockStub("statebased", nil)
+
+	err := statebased.SetPrivateDataEndorsementOrgs(stub, "", "key", statebased.RoleTypePeer, "Org1")
+	assert.EqualError(t, err, "collection must not be an empty string")
+
+	err = statebased.SetPrivateDataEndorsementOrgs(stub, "coll", "key", statebased.RoleTypePeer)
+	assert.EqualError(t, err, "at least one organization is required")
+
+	err = statebased.SetPrivateDataEndorsementOrgs(stub, "coll", "key", statebased.RoleType("ADMIN"), "Org1")
+	assert.EqualError(t, err, "role type ADMIN does not exist")
+}
@@ -0,0 +1,319 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package acl controls which clients may call which functions of a
+// chaincode with a policy stored in the ledger, so that every endorser
+// enforces the same policy and changes to it are transactions with the
+// history of any other state.
+//
+// A Policy assigns clients to roles, and allows or denies functions to
+// roles. It is stored under a composite key of the "acl" object type,
+// which the chaincode must not otherwise use. The policy is set in Init
+// with Bootstrap, and enforced by the chaincode returned by Enforce:
+//
+//	store := acl.New()
+//	err := shim.Start(store.Enforce(cc))
+//
+// which also serves the functions GetFunction and UpdateFunction to read
+// and, for clients in an admin role, replace the policy.
+package acl
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const (
+	// objectType is the composite key object type of the policy.
+	objectType = "acl"
+
+	// GetFunction is the function of the chaincode returned by Enforce
+	// returning the policy as JSON. All clients may call it.
+	GetFunction = "acl:get"
+	// UpdateFunction is the function of the chaincode returned by Enforce
+	// replacing the policy with the JSON policy passed as its argument. Only
+	// clients in one of the admin roles of the current policy may call it.
+	UpdateFunction = "acl:update"
+
+	// AllFunctions stands for every function in the functions allowed or
+	// denied to a role.
+	AllFunctions = "*"
+)
+
+// ErrNoPolicy is returned when the ledger holds no policy.
+var ErrNoPolicy = errors.New("no access control policy in the ledger")
+
+// AccessDeniedError is returned when the policy does not allow the client
+// to call a function.
+type AccessDeniedError struct {
+	Function string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return fmt.Sprintf("access to function %s denied", e.Function)
+}
+
+// Policy is the access control policy stored in the ledger. A function is
+// allowed to a client if one of its roles allows it and none denies it.
+type Policy struct {
+	// Members maps each role to its members: MSP IDs, which make every
+	// identity of the organization a member, or the unique keys of
+	// identities returned by cid.UniqueKey.
+	Members map[string][]string `json:"members"`
+	// Allow maps roles to the functions their members may call.
+	Allow map[string][]string `json:"allow"`
+	// Deny maps roles to the functions their members may not call, even if
+	// another of their roles allows them.
+	Deny map[string][]string `json:"deny,omitempty"`
+	// Admins are the roles whose members may update the policy.
+	Admins []string `json:"admins"`
+}
+
+// Validate returns an error if the policy would lock out its admins.
+func (p *Policy) Validate() error {
+	if len(p.Admins) == 0 {
+		return errors.New("policy has no admin roles")
+	}
+	for _, role := range p.Admins {
+		if len(p.Members[role]) == 0 {
+			return fmt.Errorf("admin role %s has no members", role)
+		}
+	}
+	return nil
+}
+
+// rolesOf returns the roles of the client with the MSP ID mspID and the
+// unique key key, which is empty if the client has none.
+func (p *Policy) rolesOf(mspID, key string) []string {
+	var roles []string
+	for role, members := range p.Members {
+		for _, m := range members {
+			if m == mspID || key != "" && m == key {
+				roles = append(roles, role)
+				break
+			}
+		}
+	}
+	return roles
+}
+
+// allows reports whether the policy allows function to the roles.
+func (p *Policy) allows(roles []string, function string) bool {
+	allowed := false
+	for _, role := range roles {
+		if contains(p.Deny[role], function) {
+			return false
+		}
+		allowed = allowed || contains(p.Allow[role], function)
+	}
+	return allowed
+}
+
+func contains(functions []string, function string) bool {
+	for _, f := range functions {
+		if f == function || f == AllFunctions {
+			return true
+		}
+	}
+	return false
+}
+
+// Store reads and enforces the policy. It caches the policy read by a
+// transaction for its later checks.
+type Store struct {
+	mutex sync.Mutex
+	// txID and policy are the ID of the last transaction which read the
+	// policy and the policy it read.
+	txID   string
+	policy *Policy
+}
+
+// New returns a Store.
+func New() *Store {
+	return &Store{}
+}
+
+// Get returns the policy, or ErrNoPolicy if the ledger holds none.
+func (s *Store) Get(stub shim.ChaincodeStubInterface) (*Policy, error) {
+	cacheKey := stub.GetChannelID() + "/" + stub.GetTxID()
+	s.mutex.Lock()
+	if s.txID == cacheKey && s.policy != nil {
+		policy := s.policy
+		s.mutex.Unlock()
+		return policy, nil
+	}
+	s.mutex.Unlock()
+
+	key, err := stub.CreateCompositeKey(objectType, []string{"policy"})
+	if err != nil {
+		return nil, err
+	}
+	b, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access control policy: %s", err)
+	}
+	if b == nil {
+		return nil, ErrNoPolicy
+	}
+	policy := &Policy{}
+	if err := json.Unmarshal(b, policy); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal access control policy: %s", err)
+	}
+
+	s.mutex.Lock()
+	s.txID, s.policy = cacheKey, policy
+	s.mutex.Unlock()
+	return policy, nil
+}
+
+// Bootstrap stores policy if the ledger holds none, typically in Init. It
+// does nothing if the ledger already holds a policy, which can only be
+// replaced with Update.
+func (s *Store) Bootstrap(stub shim.ChaincodeStubInterface, policy *Policy) error {
+	_, err := s.Get(stub)
+	switch {
+	case err == ErrNoPolicy:
+		return put(stub, policy)
+	case err != nil:
+		return err
+	default:
+		return nil
+	}
+}
+
+// Update replaces the policy with policy if the client is in one of the
+// admin roles of the current policy. The new policy is enforced from the
+// next transaction.
+func (s *Store) Update(stub shim.ChaincodeStubInterface, policy *Policy) error {
+	current, err := s.Get(stub)
+	if err != nil {
+		return err
+	}
+	roles, err := clientRoles(stub, current)
+	if err != nil {
+		return err
+	}
+	admin := false
+	for _, role := range roles {
+		admin = admin || containsRole(current.Admins, role)
+	}
+	if !admin {
+		return &AccessDeniedError{Function: UpdateFunction}
+	}
+	return put(stub, policy)
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func put(stub shim.ChaincodeStubInterface, policy *Policy) error {
+	if err := policy.Validate(); err != nil {
+		return fmt.Errorf("invalid access control policy: %s", err)
+	}
+	key, err := stub.CreateCompositeKey(objectType, []string{"policy"})
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(policy)
+	if err != nil {
+		return fmt.Errorf("failed to marshal access control policy: %s", err)
+	}
+	if err := stub.PutState(key, b); err != nil {
+		return fmt.Errorf("failed to write access control policy: %s", err)
+	}
+	return nil
+}
+
+// Check returns an AccessDeniedError if the policy does not allow the
+// client to call function.
+func (s *Store) Check(stub shim.ChaincodeStubInterface, function string) error {
+	policy, err := s.Get(stub)
+	if err != nil {
+		return err
+	}
+	roles, err := clientRoles(stub, policy)
+	if err != nil {
+		return err
+	}
+	if !policy.allows(roles, function) {
+		return &AccessDeniedError{Function: function}
+	}
+	return nil
+}
+
+// clientRoles returns the roles of the client in policy.
+func clientRoles(stub shim.ChaincodeStubInterface, policy *Policy) ([]string, error) {
+	c, err := cid.New(stub)
+	if err != nil {
+		return nil, err
+	}
+	mspID, err := c.GetMSPID()
+	if err != nil {
+		return nil, err
+	}
+	// identities without an X509 certificate are only members through
+	// their MSP ID
+	key, _ := c.UniqueKey()
+	return policy.rolesOf(mspID, key), nil
+}
+
+// Enforce returns a chaincode calling cc for the invocations the policy
+// allows, and serving GetFunction and UpdateFunction. Init is not
+// enforced, so that it can Bootstrap the policy.
+func (s *Store) Enforce(cc shim.Chaincode) shim.Chaincode {
+	return &enforcer{cc: cc, store: s}
+}
+
+type enforcer struct {
+	cc    shim.Chaincode
+	store *Store
+}
+
+func (e *enforcer) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return e.cc.Init(stub)
+}
+
+func (e *enforcer) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	switch function {
+	case GetFunction:
+		policy, err := e.store.Get(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		b, err := json.Marshal(policy)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(b)
+	case UpdateFunction:
+		if len(args) != 1 {
+			return shim.Error(fmt.Sprintf("%s takes the policy as its single argument", UpdateFunction))
+		}
+		policy := &Policy{}
+		if err := json.Unmarshal([]byte(args[0]), policy); err != nil {
+			return shim.Error(fmt.Sprintf("failed to unmarshal access control policy: %s", err))
+		}
+		if err := e.store.Update(stub, policy); err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(nil)
+	}
+
+	if err := e.store.Check(stub, function); err != nil {
+		return shim.Error(err.Error())
+	}
+	return e.cc.Invoke(stub)
+}
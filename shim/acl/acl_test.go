@@ -0,0 +1,217 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package acl_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/acl"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creator returns the serialized identity of a client of mspID with a
+// self-signed certificate for the common name cn.
+func creator(t *testing.T, mspID, cn string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	b, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: cert})
+	require.NoError(t, err)
+	return b
+}
+
+func uniqueKey(t *testing.T, creator []byte) string {
+	key, err := cid.UniqueKey(shimtest.NewStubBuilder().WithCreator(creator).Build())
+	require.NoError(t, err)
+	return key
+}
+
+type echoChaincode struct{}
+
+func (echoChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (echoChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, _ := stub.GetFunctionAndParameters()
+	return shim.Success([]byte(function))
+}
+
+func invoke(stub *shimtest.MockStub, txID string, creator []byte, args ...string) pb.Response {
+	stub.Creator = creator
+	var b [][]byte
+	for _, arg := range args {
+		b = append(b, []byte(arg))
+	}
+	return stub.MockInvoke(txID, b)
+}
+
+func TestEnforce(t *testing.T) {
+	admin := creator(t, "Org1MSP", "admin")
+	user1 := creator(t, "Org1MSP", "user1")
+	user2 := creator(t, "Org2MSP", "user2")
+	auditor := creator(t, "Org2MSP", "auditor")
+
+	store := acl.New()
+	policy := &acl.Policy{
+		Members: map[string][]string{
+			"admin":   {uniqueKey(t, admin)},
+			"member":  {"Org1MSP", "Org2MSP"},
+			"auditor": {uniqueKey(t, auditor)},
+		},
+		Allow: map[string][]string{
+			"admin":   {acl.AllFunctions},
+			"member":  {"read", "write"},
+			"auditor": {"audit"},
+		},
+		Deny: map[string][]string{
+			"auditor": {"write"},
+		},
+		Admins: []string{"admin"},
+	}
+	stub := shimtest.NewMockStub("acl", store.Enforce(echoChaincode{}))
+	stub.MockTransactionStart("init")
+	require.NoError(t, store.Bootstrap(stub, policy))
+	stub.MockTransactionEnd("init")
+
+	var tests = []struct {
+		name     string
+		creator  []byte
+		function string
+		allowed  bool
+	}{
+		{name: "Member Read", creator: user1, function: "read", allowed: true},
+		{name: "Other Org Member", creator: user2, function: "write", allowed: true},
+		{name: "Unlisted Function", creator: user1, function: "audit", allowed: false},
+		{name: "Admin All Functions", creator: admin, function: "audit", allowed: true},
+		{name: "Auditor", creator: auditor, function: "audit", allowed: true},
+		{name: "Auditor Read", creator: auditor, function: "read", allowed: true},
+		{name: "Auditor Denied", creator: auditor, function: "write", allowed: false},
+		{name: "Other MSP", creator: creator(t, "Org3MSP", "user3"), function: "read", allowed: false},
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp := invoke(stub, string(rune('a'+i)), test.creator, test.function)
+			if test.allowed {
+				assert.Equal(t, int32(shim.OK), resp.Status, resp.Message)
+				assert.Equal(t, test.function, string(resp.Payload))
+			} else {
+				assert.Equal(t, "access to function "+test.function+" denied", resp.Message)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	admin := creator(t, "Org1MSP", "admin")
+	user := creator(t, "Org1MSP", "user")
+
+	store := acl.New()
+	stub := shimtest.NewMockStub("acl", store.Enforce(echoChaincode{}))
+	resp := invoke(stub, "tx0", user, "read")
+	assert.Equal(t, acl.ErrNoPolicy.Error(), resp.Message)
+
+	stub.MockTransactionStart("init")
+	require.NoError(t, store.Bootstrap(stub, &acl.Policy{
+		Members: map[string][]string{"admin": {uniqueKey(t, admin)}},
+		Allow:   map[string][]string{"admin": {acl.AllFunctions}},
+		Admins:  []string{"admin"},
+	}))
+	stub.MockTransactionEnd("init")
+
+	// a second bootstrap keeps the policy
+	stub.MockTransactionStart("init2")
+	require.NoError(t, store.Bootstrap(stub, &acl.Policy{
+		Members: map[string][]string{"admin": {"Org1MSP"}},
+		Admins:  []string{"admin"},
+	}))
+	stub.MockTransactionEnd("init2")
+
+	resp = invoke(stub, "tx1", user, "read")
+	assert.Equal(t, "access to function read denied", resp.Message)
+
+	updated := &acl.Policy{
+		Members: map[string][]string{"admin": {uniqueKey(t, admin)}, "member": {"Org1MSP"}},
+		Allow:   map[string][]string{"admin": {acl.AllFunctions}, "member": {"read"}},
+		Admins:  []string{"admin"},
+	}
+	b, err := json.Marshal(updated)
+	require.NoError(t, err)
+
+	resp = invoke(stub, "tx2", user, acl.UpdateFunction, string(b))
+	assert.Equal(t, "access to function acl:update denied", resp.Message)
+
+	resp = invoke(stub, "tx3", admin, acl.UpdateFunction, `{"members": {}, "admins": ["admin"]}`)
+	assert.Equal(t, "invalid access control policy: admin role admin has no members", resp.Message)
+
+	resp = invoke(stub, "tx4", admin, acl.UpdateFunction, string(b))
+	assert.Equal(t, int32(shim.OK), resp.Status, resp.Message)
+
+	resp = invoke(stub, "tx5", user, "read")
+	assert.Equal(t, int32(shim.OK), resp.Status, resp.Message)
+
+	resp = invoke(stub, "tx6", user, acl.GetFunction)
+	require.Equal(t, int32(shim.OK), resp.Status, resp.Message)
+	got := &acl.Policy{}
+	require.NoError(t, json.Unmarshal(resp.Payload, got))
+	assert.Equal(t, updated, got)
+}
+
+func TestCache(t *testing.T) {
+	store := acl.New()
+	stub := shimtest.NewMockStub("acl", nil)
+	policy := &acl.Policy{
+		Members: map[string][]string{"admin": {"Org1MSP"}},
+		Admins:  []string{"admin"},
+	}
+	stub.MockTransactionStart("tx1")
+	require.NoError(t, store.Bootstrap(stub, policy))
+	stub.MockTransactionEnd("tx1")
+
+	stub.MockTransactionStart("tx2")
+	first, err := store.Get(stub)
+	require.NoError(t, err)
+	key, err := stub.CreateCompositeKey("acl", []string{"policy"})
+	require.NoError(t, err)
+	stub.State[key] = []byte("garbage")
+	second, err := store.Get(stub)
+	require.NoError(t, err)
+	assert.True(t, first == second, "the policy is read once per transaction")
+	stub.MockTransactionEnd("tx2")
+
+	stub.MockTransactionStart("tx3")
+	_, err = store.Get(stub)
+	assert.Contains(t, err.Error(), "failed to unmarshal access control policy")
+	stub.MockTransactionEnd("tx3")
+}
+
+func TestValidate(t *testing.T) {
+	assert.EqualError(t, (&acl.Policy{}).Validate(), "policy has no admin roles")
+	assert.EqualError(t, (&acl.Policy{Admins: []string{"admin"}}).Validate(), "admin role admin has no members")
+}
@@ -0,0 +1,197 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// AdaptivePaging configures the pages requested by GetQueryResult when
+// WithAdaptivePaging is set.
+type AdaptivePaging struct {
+	// TargetPageBytes is the size of the pages requested from the peer.
+	TargetPageBytes int
+	// MinPageSize and MaxPageSize bound the number of results per page.
+	// Queries start with MinPageSize results per page until the size of
+	// the results has been observed.
+	MinPageSize int32
+	MaxPageSize int32
+}
+
+// DefaultAdaptivePaging requests pages of about 1MB of up to 1000 results.
+var DefaultAdaptivePaging = AdaptivePaging{TargetPageBytes: 1024 * 1024, MinPageSize: 10, MaxPageSize: 1000}
+
+// WithAdaptivePaging makes GetQueryResult fetch the results of rich
+// queries in pages of about paging.TargetPageBytes, so that results close
+// to the message size limit are not fetched and unmarshaled in one piece.
+// The number of results per page is derived from the average size of the
+// results observed by the previous pages of all the queries.
+//
+// The pages are fetched with paginated queries, which the peer only allows
+// in transactions that do not write to the ledger. Chaincode writing in the
+// transactions making rich queries must not set this option.
+func WithAdaptivePaging(paging AdaptivePaging) Option {
+	if paging.TargetPageBytes <= 0 {
+		return func(*Handler) error { return errors.New("adaptive paging target page bytes must be positive") }
+	}
+	if paging.MinPageSize <= 0 || paging.MaxPageSize < paging.MinPageSize {
+		return func(*Handler) error {
+			return fmt.Errorf("adaptive paging page sizes must satisfy 0 < min <= max, got min=%d max=%d", paging.MinPageSize, paging.MaxPageSize)
+		}
+	}
+	// the observed result size is shared by the handlers of all streams
+	pager := &adaptivePager{paging: paging}
+	return func(h *Handler) error {
+		h.adaptivePager = pager
+		return nil
+	}
+}
+
+// adaptivePager sizes the pages of rich queries.
+type adaptivePager struct {
+	paging AdaptivePaging
+
+	mutex sync.Mutex
+	// resultSize is the moving average of the size of the results, zero
+	// until a page has been read.
+	resultSize int
+}
+
+// pageSize returns the number of results of the next page.
+func (p *adaptivePager) pageSize() int32 {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.resultSize == 0 {
+		return p.paging.MinPageSize
+	}
+	size := p.paging.TargetPageBytes / p.resultSize
+	switch {
+	case size < int(p.paging.MinPageSize):
+		return p.paging.MinPageSize
+	case size > int(p.paging.MaxPageSize):
+		return p.paging.MaxPageSize
+	default:
+		return int32(size)
+	}
+}
+
+// observe records that a page of count results of bytes bytes was read.
+func (p *adaptivePager) observe(count, bytes int) {
+	if count == 0 {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	size := bytes / count
+	if size == 0 {
+		size = 1
+	}
+	if p.resultSize == 0 {
+		p.resultSize = size
+	} else {
+		p.resultSize = (3*p.resultSize + size) / 4
+	}
+}
+
+// adaptiveQueryResult performs the rich query in pages sized by the
+// adaptive pager of the handler.
+func (s *ChaincodeStub) adaptiveQueryResult(query string) (StateQueryIteratorInterface, error) {
+	iter := &adaptiveQueryIterator{stub: s, query: query, pager: s.handler.adaptivePager}
+	if err := iter.nextPage(); err != nil {
+		return nil, err
+	}
+	return iter, nil
+}
+
+// adaptiveQueryIterator iterates over the pages of a rich query.
+type adaptiveQueryIterator struct {
+	stub  *ChaincodeStub
+	query string
+	pager *adaptivePager
+
+	page     StateQueryIteratorInterface
+	pageSize int32
+	bookmark string
+	// count and bytes are the number and size of the results read from the
+	// page.
+	count int
+	bytes int
+	// last is set when the page is the last one of the query.
+	last   bool
+	err    error
+	closed bool
+}
+
+// nextPage closes the current page, if any, and fetches the next one.
+func (iter *adaptiveQueryIterator) nextPage() error {
+	if iter.page != nil {
+		iter.pager.observe(iter.count, iter.bytes)
+		if err := iter.page.Close(); err != nil {
+			return err
+		}
+		iter.page = nil
+	}
+	iter.pageSize = iter.pager.pageSize()
+	page, metadata, err := iter.stub.GetQueryResultWithPagination(iter.query, iter.pageSize, iter.bookmark)
+	if err != nil {
+		return err
+	}
+	iter.page, iter.count, iter.bytes = page, 0, 0
+	// a page with fewer results than requested is the last one
+	iter.last = metadata.Bookmark == "" || metadata.FetchedRecordsCount < iter.pageSize
+	iter.bookmark = metadata.Bookmark
+	return nil
+}
+
+// HasNext returns true if the query has more results, fetching the next
+// page if the current one is exhausted. An error fetching the page is
+// returned by Next.
+func (iter *adaptiveQueryIterator) HasNext() bool {
+	if iter.closed {
+		return false
+	}
+	for iter.err == nil && !iter.page.HasNext() {
+		if iter.last {
+			return false
+		}
+		iter.err = iter.nextPage()
+	}
+	return true
+}
+
+// Next returns the next result of the query.
+func (iter *adaptiveQueryIterator) Next() (*queryresult.KV, error) {
+	if !iter.HasNext() {
+		return nil, errors.New("no such key")
+	}
+	if iter.err != nil {
+		return nil, iter.err
+	}
+	kv, err := iter.page.Next()
+	if err != nil {
+		return nil, err
+	}
+	iter.count++
+	iter.bytes += proto.Size(kv)
+	return kv, nil
+}
+
+// Close closes the current page, recording the size of its results.
+func (iter *adaptiveQueryIterator) Close() error {
+	iter.closed = true
+	if iter.page == nil {
+		return nil
+	}
+	iter.pager.observe(iter.count, iter.bytes)
+	err := iter.page.Close()
+	iter.page = nil
+	return err
+}
@@ -0,0 +1,159 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithAdaptivePaging(t *testing.T) {
+	var tests = []struct {
+		name        string
+		paging      AdaptivePaging
+		expectedErr string
+	}{
+		{name: "Default", paging: DefaultAdaptivePaging},
+		{name: "Zero Target", paging: AdaptivePaging{MinPageSize: 1, MaxPageSize: 1}, expectedErr: "adaptive paging target page bytes must be positive"},
+		{name: "Zero Min", paging: AdaptivePaging{TargetPageBytes: 1, MaxPageSize: 1}, expectedErr: "adaptive paging page sizes must satisfy 0 < min <= max, got min=0 max=1"},
+		{name: "Max Below Min", paging: AdaptivePaging{TargetPageBytes: 1, MinPageSize: 2, MaxPageSize: 1}, expectedErr: "adaptive paging page sizes must satisfy 0 < min <= max, got min=2 max=1"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := newChaincodeHandler(nil, &mockChaincode{}, WithAdaptivePaging(test.paging))
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, "target=1048576,min=10,max=1000", h.features()["adaptive_paging"])
+		})
+	}
+}
+
+func TestAdaptivePagerPageSize(t *testing.T) {
+	p := &adaptivePager{paging: AdaptivePaging{TargetPageBytes: 1000, MinPageSize: 5, MaxPageSize: 50}}
+	assert.Equal(t, int32(5), p.pageSize(), "the page size starts at the minimum")
+
+	p.observe(0, 0)
+	assert.Equal(t, int32(5), p.pageSize())
+	p.observe(10, 1000)
+	assert.Equal(t, int32(10), p.pageSize())
+	p.observe(10, 5000)
+	assert.Equal(t, 200, p.resultSize, "the result size is a moving average")
+	assert.Equal(t, int32(5), p.pageSize())
+	for i := 0; i < 20; i++ {
+		p.observe(10, 10)
+	}
+	assert.Equal(t, int32(50), p.pageSize(), "the page size is bounded by the maximum")
+}
+
+// newPagingPeerStub returns a stub whose peer answers paginated rich
+// queries over n results, and the page sizes requested.
+func newPagingPeerStub(t *testing.T, paging AdaptivePaging, n int, failPage int) (*ChaincodeStub, func() []int32) {
+	handler := &Handler{cc: &mockChaincode{}, router: NewResponseRouter(), state: ready}
+	require.NoError(t, WithAdaptivePaging(paging)(handler))
+	stub := &ChaincodeStub{ChannelID: "channel", TxID: "txid", handler: handler}
+
+	var mutex sync.Mutex
+	var pageSizes []int32
+	chatStream := &mock.PeerChaincodeStream{}
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		resp := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_RESPONSE, ChannelId: msg.ChannelId, Txid: msg.Txid}
+		switch msg.Type {
+		case peerpb.ChaincodeMessage_GET_QUERY_RESULT:
+			request := &peerpb.GetQueryResult{}
+			require.NoError(t, proto.Unmarshal(msg.Payload, request))
+			metadata := &peerpb.QueryMetadata{}
+			require.NoError(t, proto.Unmarshal(request.Metadata, metadata))
+
+			mutex.Lock()
+			pageSizes = append(pageSizes, metadata.PageSize)
+			page := len(pageSizes)
+			mutex.Unlock()
+			if page == failPage {
+				resp.Type, resp.Payload = peerpb.ChaincodeMessage_ERROR, []byte("query failed")
+				break
+			}
+
+			start, _ := strconv.Atoi(metadata.Bookmark)
+			response := &peerpb.QueryResponse{Id: "queryid"}
+			for i := start; i < n && i < start+int(metadata.PageSize); i++ {
+				kv := &queryresult.KV{Key: fmt.Sprintf("key%03d", i), Value: make([]byte, 100)}
+				response.Results = append(response.Results, &peerpb.QueryResultBytes{ResultBytes: marshalOrPanic(kv)})
+			}
+			response.Metadata = marshalOrPanic(&peerpb.QueryResponseMetadata{
+				FetchedRecordsCount: int32(len(response.Results)),
+				Bookmark:            strconv.Itoa(start + len(response.Results)),
+			})
+			resp.Payload = marshalOrPanic(response)
+		default:
+			resp.Payload = marshalOrPanic(&peerpb.QueryResponse{Id: "queryid"})
+		}
+		go handler.handleResponse(resp)
+		return nil
+	}
+	handler.chatStream = chatStream
+	return stub, func() []int32 {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return pageSizes
+	}
+}
+
+func TestAdaptiveQueryResult(t *testing.T) {
+	size := proto.Size(&queryresult.KV{Key: "key000", Value: make([]byte, 100)})
+	paging := AdaptivePaging{TargetPageBytes: 10 * size, MinPageSize: 2, MaxPageSize: 50}
+
+	t.Run("Pages", func(t *testing.T) {
+		stub, pageSizes := newPagingPeerStub(t, paging, 25, 0)
+		iter, err := stub.GetQueryResult(`{"selector":{}}`)
+		require.NoError(t, err)
+
+		var keys []string
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			require.NoError(t, err)
+			keys = append(keys, kv.Key)
+		}
+		require.Len(t, keys, 25)
+		assert.Equal(t, "key000", keys[0])
+		assert.Equal(t, "key024", keys[24])
+		assert.Equal(t, []int32{2, 10, 10, 10}, pageSizes())
+		_, err = iter.Next()
+		assert.EqualError(t, err, "no such key")
+		assert.NoError(t, iter.Close())
+		assert.False(t, iter.HasNext())
+	})
+
+	t.Run("Page Error", func(t *testing.T) {
+		stub, _ := newPagingPeerStub(t, paging, 25, 2)
+		iter, err := stub.GetQueryResult(`{"selector":{}}`)
+		require.NoError(t, err)
+
+		for i := 0; i < 2; i++ {
+			_, err := iter.Next()
+			require.NoError(t, err)
+		}
+		assert.True(t, iter.HasNext())
+		_, err = iter.Next()
+		assert.EqualError(t, err, "query failed")
+	})
+
+	t.Run("Query Error", func(t *testing.T) {
+		stub, _ := newPagingPeerStub(t, paging, 25, 1)
+		_, err := stub.GetQueryResult(`{"selector":{}}`)
+		assert.EqualError(t, err, "query failed")
+	})
+}
@@ -0,0 +1,306 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package approvals requires the approval of several clients before an
+// operation is executed, such as two admins approving a change of
+// configuration.
+//
+// An operation is a chaincode function and its arguments, identified by
+// the hash returned by OperationID. It goes through three steps, each a
+// transaction:
+//
+//	id, err := workflow.Propose(stub, "setLimit", []string{"1000"})
+//	...
+//	err = workflow.Approve(stub, id)
+//	...
+//	op, err := workflow.Execute(stub, id)
+//	// op.Function and op.Args are now run by the chaincode
+//
+// Each approval is stored under its own composite key of the "approvals"
+// object type, so that approvals submitted concurrently do not conflict.
+package approvals
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+const (
+	// operationType is the composite key object type of operations.
+	operationType = "approvals"
+	// approvalType is the composite key object type of approvals.
+	approvalType = "approvals.approval"
+)
+
+var (
+	// ErrNotApprover is returned when the client is not one of the
+	// approvers of the workflow.
+	ErrNotApprover = errors.New("the client is not an approver of the operation")
+	// ErrNotFound is returned for operations which were not proposed.
+	ErrNotFound = errors.New("operation not found")
+	// ErrNotPending is returned when approving or executing an operation
+	// which has already been executed.
+	ErrNotPending = errors.New("operation is not pending")
+)
+
+// InsufficientApprovalsError is returned by Execute when an operation does
+// not have the required number of approvals.
+type InsufficientApprovalsError struct {
+	Approvals int
+	Required  int
+}
+
+func (e *InsufficientApprovalsError) Error() string {
+	return fmt.Sprintf("operation has %d of %d required approvals", e.Approvals, e.Required)
+}
+
+// Status is the status of an operation.
+type Status string
+
+const (
+	// StatusPending is the status of a proposed operation until it is
+	// executed.
+	StatusPending = Status("PENDING")
+	// StatusExecuted is the status of an executed operation.
+	StatusExecuted = Status("EXECUTED")
+)
+
+// Approvers are the clients who may approve operations, and the number of
+// distinct clients among them which must approve an operation. A client is
+// an approver if its MSP ID is one of MSPIDs, when MSPIDs is not empty,
+// and its attribute Attribute equals AttributeValue, when Attribute is not
+// empty.
+type Approvers struct {
+	MSPIDs         []string
+	Attribute      string
+	AttributeValue string
+	Required       int
+}
+
+// Operation is a proposed operation.
+type Operation struct {
+	// ID is the OperationID of Function and Args.
+	ID       string   `json:"id"`
+	Function string   `json:"function"`
+	Args     []string `json:"args"`
+	// Proposer is the unique key of the client who proposed the operation.
+	Proposer string `json:"proposer"`
+	Status   Status `json:"status"`
+	// Round counts the proposals of the operation; the approvals of
+	// previous rounds are ignored.
+	Round int `json:"round"`
+	// Approvals are the unique keys of the clients who approved the
+	// operation in its current round, returned by Get.
+	Approvals []string `json:"-"`
+}
+
+// OperationID returns the hex encoded SHA-256 hash identifying the call of
+// function with args.
+func OperationID(function string, args []string) string {
+	h := sha256.New()
+	for _, s := range append([]string{function}, args...) {
+		var size [8]byte
+		binary.BigEndian.PutUint64(size[:], uint64(len(s)))
+		h.Write(size[:])
+		h.Write([]byte(s))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Workflow proposes, approves and executes operations.
+type Workflow struct {
+	approvers Approvers
+}
+
+// New returns a Workflow requiring the approval of approvers.
+func New(approvers Approvers) (*Workflow, error) {
+	if approvers.Required < 1 {
+		return nil, fmt.Errorf("required approvals must be positive, got %d", approvers.Required)
+	}
+	if approvers.Attribute == "" && approvers.AttributeValue != "" {
+		return nil, errors.New("attribute value set without an attribute")
+	}
+	return &Workflow{approvers: approvers}, nil
+}
+
+// approver returns the unique key of the client, or ErrNotApprover if it
+// is not an approver.
+func (w *Workflow) approver(stub shim.ChaincodeStubInterface) (string, error) {
+	c, err := cid.New(stub)
+	if err != nil {
+		return "", err
+	}
+	if len(w.approvers.MSPIDs) > 0 {
+		mspID, err := c.GetMSPID()
+		if err != nil {
+			return "", err
+		}
+		found := false
+		for _, id := range w.approvers.MSPIDs {
+			found = found || id == mspID
+		}
+		if !found {
+			return "", ErrNotApprover
+		}
+	}
+	if w.approvers.Attribute != "" {
+		value, found, err := c.GetAttributeValue(w.approvers.Attribute)
+		if err != nil {
+			return "", err
+		}
+		if !found || value != w.approvers.AttributeValue {
+			return "", ErrNotApprover
+		}
+	}
+	return c.UniqueKey()
+}
+
+// Propose proposes the call of function with args, approved by the
+// proposer, and returns its ID. Only approvers may propose operations. An
+// executed operation can be proposed again, which starts a new round of
+// approvals.
+func (w *Workflow) Propose(stub shim.ChaincodeStubInterface, function string, args []string) (string, error) {
+	proposer, err := w.approver(stub)
+	if err != nil {
+		return "", err
+	}
+	id := OperationID(function, args)
+	op, err := getOperation(stub, id)
+	switch {
+	case err == ErrNotFound:
+		op = &Operation{ID: id, Function: function, Args: args}
+	case err != nil:
+		return "", err
+	case op.Status == StatusPending:
+		return "", fmt.Errorf("operation %s is already pending", id)
+	}
+	op.Proposer = proposer
+	op.Status = StatusPending
+	op.Round++
+	if err := putOperation(stub, op); err != nil {
+		return "", err
+	}
+	if err := putApproval(stub, op, proposer); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+// Approve records the approval of the pending operation id by the client,
+// which must be an approver. Approving an operation twice has no effect.
+func (w *Workflow) Approve(stub shim.ChaincodeStubInterface, id string) error {
+	approver, err := w.approver(stub)
+	if err != nil {
+		return err
+	}
+	op, err := getOperation(stub, id)
+	if err != nil {
+		return err
+	}
+	if op.Status != StatusPending {
+		return ErrNotPending
+	}
+	return putApproval(stub, op, approver)
+}
+
+// Execute marks the pending operation id as executed and returns it, for
+// the chaincode to run it in the same transaction, if it has the required
+// approvals. The client must be an approver.
+func (w *Workflow) Execute(stub shim.ChaincodeStubInterface, id string) (*Operation, error) {
+	if _, err := w.approver(stub); err != nil {
+		return nil, err
+	}
+	op, err := w.Get(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	if op.Status != StatusPending {
+		return nil, ErrNotPending
+	}
+	if len(op.Approvals) < w.approvers.Required {
+		return nil, &InsufficientApprovalsError{Approvals: len(op.Approvals), Required: w.approvers.Required}
+	}
+	op.Status = StatusExecuted
+	if err := putOperation(stub, op); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+// Get returns the operation id with its approvals.
+func (w *Workflow) Get(stub shim.ChaincodeStubInterface, id string) (*Operation, error) {
+	op, err := getOperation(stub, id)
+	if err != nil {
+		return nil, err
+	}
+	iter, err := stub.GetStateByPartialCompositeKey(approvalType, []string{id, strconv.Itoa(op.Round)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read approvals of operation %s: %s", id, err)
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read approvals of operation %s: %s", id, err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil || len(attrs) != 3 {
+			return nil, fmt.Errorf("invalid approval key %q", kv.Key)
+		}
+		op.Approvals = append(op.Approvals, attrs[2])
+	}
+	return op, nil
+}
+
+func getOperation(stub shim.ChaincodeStubInterface, id string) (*Operation, error) {
+	key, err := stub.CreateCompositeKey(operationType, []string{id})
+	if err != nil {
+		return nil, err
+	}
+	b, err := stub.GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read operation %s: %s", id, err)
+	}
+	if b == nil {
+		return nil, ErrNotFound
+	}
+	op := &Operation{}
+	if err := json.Unmarshal(b, op); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal operation %s: %s", id, err)
+	}
+	return op, nil
+}
+
+func putOperation(stub shim.ChaincodeStubInterface, op *Operation) error {
+	key, err := stub.CreateCompositeKey(operationType, []string{op.ID})
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(op)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operation %s: %s", op.ID, err)
+	}
+	if err := stub.PutState(key, b); err != nil {
+		return fmt.Errorf("failed to write operation %s: %s", op.ID, err)
+	}
+	return nil
+}
+
+func putApproval(stub shim.ChaincodeStubInterface, op *Operation, approver string) error {
+	key, err := stub.CreateCompositeKey(approvalType, []string{op.ID, strconv.Itoa(op.Round), approver})
+	if err != nil {
+		return err
+	}
+	if err := stub.PutState(key, []byte{1}); err != nil {
+		return fmt.Errorf("failed to write approval of operation %s: %s", op.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package approvals_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-chaincode-go/shim/approvals"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creator returns the serialized identity of a client of mspID with a
+// self-signed certificate for the common name cn with attrs.
+func creator(t *testing.T, mspID, cn string, attrs map[string]string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	if attrs != nil {
+		b, err := json.Marshal(&attrmgr.Attributes{Attrs: attrs})
+		require.NoError(t, err)
+		template.ExtraExtensions = []pkix.Extension{{Id: attrmgr.AttrOID, Value: b}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	b, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: cert})
+	require.NoError(t, err)
+	return b
+}
+
+// tx runs fn in a transaction submitted by creator.
+func tx(stub *shimtest.MockStub, txID string, creator []byte, fn func() error) error {
+	stub.Creator = creator
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	return fn()
+}
+
+func TestTwoAdmins(t *testing.T) {
+	admin1 := creator(t, "Org1MSP", "admin1", map[string]string{"role": "admin"})
+	admin2 := creator(t, "Org2MSP", "admin2", map[string]string{"role": "admin"})
+	user := creator(t, "Org1MSP", "user", map[string]string{"role": "user"})
+
+	workflow, err := approvals.New(approvals.Approvers{Attribute: "role", AttributeValue: "admin", Required: 2})
+	require.NoError(t, err)
+	stub := shimtest.NewMockStub("approvals", nil)
+
+	err = tx(stub, "tx0", user, func() error {
+		_, err := workflow.Propose(stub, "setLimit", []string{"1000"})
+		return err
+	})
+	assert.Equal(t, approvals.ErrNotApprover, err)
+
+	var id string
+	require.NoError(t, tx(stub, "tx1", admin1, func() error {
+		id, err = workflow.Propose(stub, "setLimit", []string{"1000"})
+		return err
+	}))
+	assert.Equal(t, approvals.OperationID("setLimit", []string{"1000"}), id)
+
+	err = tx(stub, "tx2", admin1, func() error {
+		_, err := workflow.Propose(stub, "setLimit", []string{"1000"})
+		return err
+	})
+	assert.EqualError(t, err, "operation "+id+" is already pending")
+
+	// the proposer approving again does not count twice
+	require.NoError(t, tx(stub, "tx3", admin1, func() error {
+		return workflow.Approve(stub, id)
+	}))
+	err = tx(stub, "tx4", admin1, func() error {
+		_, err := workflow.Execute(stub, id)
+		return err
+	})
+	assert.Equal(t, &approvals.InsufficientApprovalsError{Approvals: 1, Required: 2}, err)
+	assert.EqualError(t, err, "operation has 1 of 2 required approvals")
+
+	err = tx(stub, "tx5", user, func() error {
+		return workflow.Approve(stub, id)
+	})
+	assert.Equal(t, approvals.ErrNotApprover, err)
+
+	require.NoError(t, tx(stub, "tx6", admin2, func() error {
+		return workflow.Approve(stub, id)
+	}))
+
+	var op *approvals.Operation
+	require.NoError(t, tx(stub, "tx7", admin2, func() error {
+		op, err = workflow.Execute(stub, id)
+		return err
+	}))
+	assert.Equal(t, "setLimit", op.Function)
+	assert.Equal(t, []string{"1000"}, op.Args)
+	assert.Equal(t, approvals.StatusExecuted, op.Status)
+	assert.Len(t, op.Approvals, 2)
+
+	err = tx(stub, "tx8", admin1, func() error {
+		_, err := workflow.Execute(stub, id)
+		return err
+	})
+	assert.Equal(t, approvals.ErrNotPending, err)
+	err = tx(stub, "tx9", admin2, func() error {
+		return workflow.Approve(stub, id)
+	})
+	assert.Equal(t, approvals.ErrNotPending, err)
+
+	// proposing the operation again requires new approvals
+	require.NoError(t, tx(stub, "tx10", admin2, func() error {
+		_, err := workflow.Propose(stub, "setLimit", []string{"1000"})
+		return err
+	}))
+	op, err = workflow.Get(stub, id)
+	require.NoError(t, err)
+	assert.Equal(t, approvals.StatusPending, op.Status)
+	assert.Equal(t, 2, op.Round)
+	assert.Len(t, op.Approvals, 1)
+}
+
+func TestMSPApprovers(t *testing.T) {
+	org1 := creator(t, "Org1MSP", "user1", nil)
+	org2 := creator(t, "Org2MSP", "user2", nil)
+	org3 := creator(t, "Org3MSP", "user3", nil)
+
+	workflow, err := approvals.New(approvals.Approvers{MSPIDs: []string{"Org1MSP", "Org2MSP"}, Required: 2})
+	require.NoError(t, err)
+	stub := shimtest.NewMockStub("approvals", nil)
+
+	var id string
+	require.NoError(t, tx(stub, "tx1", org1, func() error {
+		id, err = workflow.Propose(stub, "rotate", nil)
+		return err
+	}))
+	err = tx(stub, "tx2", org3, func() error {
+		return workflow.Approve(stub, id)
+	})
+	assert.Equal(t, approvals.ErrNotApprover, err)
+	require.NoError(t, tx(stub, "tx3", org2, func() error {
+		return workflow.Approve(stub, id)
+	}))
+	require.NoError(t, tx(stub, "tx4", org1, func() error {
+		_, err := workflow.Execute(stub, id)
+		return err
+	}))
+
+	err = tx(stub, "tx5", org1, func() error {
+		return workflow.Approve(stub, "unknown")
+	})
+	assert.Equal(t, approvals.ErrNotFound, err)
+}
+
+func TestOperationID(t *testing.T) {
+	assert.NotEqual(t, approvals.OperationID("a", []string{"bc"}), approvals.OperationID("ab", []string{"c"}))
+	assert.NotEqual(t, approvals.OperationID("a", []string{"b", "c"}), approvals.OperationID("a", []string{"bc"}))
+	assert.Len(t, approvals.OperationID("a", nil), 64)
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := approvals.New(approvals.Approvers{})
+	assert.EqualError(t, err, "required approvals must be positive, got 0")
+	_, err = approvals.New(approvals.Approvers{AttributeValue: "admin", Required: 1})
+	assert.EqualError(t, err, "attribute value set without an attribute")
+}
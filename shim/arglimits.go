@@ -0,0 +1,76 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ArgLimits bounds the arguments of the transactions passed to the
+// chaincode. Zero values mean no limit.
+type ArgLimits struct {
+	// MaxCount is the maximum number of arguments, including the function
+	// name.
+	MaxCount int
+	// MaxSize is the maximum size in bytes of each argument.
+	MaxSize int
+	// MaxTotalSize is the maximum size in bytes of all the arguments.
+	MaxTotalSize int
+}
+
+// WithArgLimits sets limits on the arguments of transactions. Transactions
+// exceeding a limit are not passed to Init or Invoke; they complete with a
+// response of status ERRORTHRESHOLD whose message names the offending
+// argument by its index, the function name being argument 0.
+func WithArgLimits(limits ArgLimits) Option {
+	return func(h *Handler) error {
+		if limits.MaxCount < 0 || limits.MaxSize < 0 || limits.MaxTotalSize < 0 {
+			return errors.New("argument limits must not be negative")
+		}
+		h.argLimits = limits
+		return nil
+	}
+}
+
+// check returns an error describing the first limit exceeded by args.
+func (l ArgLimits) check(args [][]byte) error {
+	if l.MaxCount > 0 && len(args) > l.MaxCount {
+		return fmt.Errorf("argument count %d exceeds the maximum of %d", len(args), l.MaxCount)
+	}
+	total := 0
+	for i, arg := range args {
+		if l.MaxSize > 0 && len(arg) > l.MaxSize {
+			return fmt.Errorf("argument %d is %d bytes, exceeding the maximum of %d bytes", i, len(arg), l.MaxSize)
+		}
+		total += len(arg)
+	}
+	if l.MaxTotalSize > 0 && total > l.MaxTotalSize {
+		return fmt.Errorf("arguments total %d bytes, exceeding the maximum of %d bytes", total, l.MaxTotalSize)
+	}
+	return nil
+}
+
+// callChaincode calls fn, the Init or Invoke function of the chaincode,
+// with stub unless the arguments of stub exceed the argument limits or fail
+// to decrypt, and records the statistics of the function called, if
+// enabled.
+func (h *Handler) callChaincode(fn func(ChaincodeStubInterface) pb.Response, stub *ChaincodeStub) pb.Response {
+	if h.functionStats != nil {
+		return h.functionStats.call(stub, func() pb.Response { return h.callWithinLimits(fn, stub) })
+	}
+	return h.callWithinLimits(fn, stub)
+}
+
+func (h *Handler) callWithinLimits(fn func(ChaincodeStubInterface) pb.Response, stub *ChaincodeStub) pb.Response {
+	if err := h.argLimits.check(stub.args); err != nil {
+		return pb.Response{Status: ERRORTHRESHOLD, Message: err.Error()}
+	}
+	if err := h.decryptInputs(stub); err != nil {
+		return pb.Response{Status: ERRORTHRESHOLD, Message: err.Error()}
+	}
+	return fn(stub)
+}
@@ -0,0 +1,68 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArgLimitsCheck(t *testing.T) {
+	args := [][]byte{[]byte("fn"), []byte("abcd"), []byte("abcdefgh")}
+
+	var tests = []struct {
+		name        string
+		limits      ArgLimits
+		expectedErr string
+	}{
+		{name: "No Limits"},
+		{name: "Within Limits", limits: ArgLimits{MaxCount: 3, MaxSize: 8, MaxTotalSize: 14}},
+		{name: "Count", limits: ArgLimits{MaxCount: 2}, expectedErr: "argument count 3 exceeds the maximum of 2"},
+		{name: "Size", limits: ArgLimits{MaxSize: 4}, expectedErr: "argument 2 is 8 bytes, exceeding the maximum of 4 bytes"},
+		{name: "Total Size", limits: ArgLimits{MaxTotalSize: 13}, expectedErr: "arguments total 14 bytes, exceeding the maximum of 13 bytes"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			err := test.limits.check(args)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestWithArgLimitsInvalid(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithArgLimits(ArgLimits{MaxSize: -1}))
+	assert.EqualError(t, err, "argument limits must not be negative")
+}
+
+func TestHandleTransactionArgLimits(t *testing.T) {
+	cc := &mockChaincode{}
+	h, err := newChaincodeHandler(nil, cc, WithArgLimits(ArgLimits{MaxSize: 4}))
+	require.NoError(t, err)
+
+	input, err := proto.Marshal(&peerpb.ChaincodeInput{Args: [][]byte{[]byte("fn"), []byte("too large")}})
+	require.NoError(t, err)
+
+	for _, handle := range []stubHandlerFunc{h.handleInit, h.handleTransaction} {
+		msg, err := handle(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, Txid: "txid", Payload: input})
+		require.NoError(t, err)
+		assert.Equal(t, peerpb.ChaincodeMessage_COMPLETED, msg.Type)
+
+		res := &peerpb.Response{}
+		require.NoError(t, proto.Unmarshal(msg.Payload, res))
+		assert.Equal(t, int32(ERRORTHRESHOLD), res.Status)
+		assert.Equal(t, "argument 1 is 9 bytes, exceeding the maximum of 4 bytes", res.Message)
+	}
+	assert.False(t, cc.initCalled)
+	assert.False(t, cc.invokeCalled)
+}
@@ -0,0 +1,104 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	// AuthTokenEnv sets a token sent to the peer when registering; see
+	// WithAuthToken.
+	AuthTokenEnv = "CORE_CHAINCODE_AUTH_TOKEN"
+	// AuthTokenFileEnv sets a file holding the token sent to the peer when
+	// registering. The file is read for every stream, so the token can be
+	// rotated by replacing the file, as when it is mounted from a secret.
+	AuthTokenFileEnv = "CORE_CHAINCODE_AUTH_TOKEN_FILE"
+)
+
+// AuthTokenMetadataKey is the gRPC metadata key of the token sent to the
+// peer. Its value is "Bearer " followed by the token.
+const AuthTokenMetadataKey = "authorization"
+
+// AuthTokenProvider provides the token authenticating the chaincode to the
+// peer, beyond what network policies enforce.
+type AuthTokenProvider interface {
+	// AuthToken returns the token. It is called for every stream opened to
+	// the peer, so a provider can return a rotated token for the streams
+	// opened after a rotation.
+	AuthToken() (string, error)
+}
+
+// AuthTokenFunc is an AuthTokenProvider calling a function.
+type AuthTokenFunc func() (string, error)
+
+// AuthToken returns f().
+func (f AuthTokenFunc) AuthToken() (string, error) {
+	return f()
+}
+
+// StaticAuthToken returns an AuthTokenProvider providing token.
+func StaticAuthToken(token string) AuthTokenProvider {
+	return AuthTokenFunc(func() (string, error) { return token, nil })
+}
+
+// FileAuthToken returns an AuthTokenProvider reading the token from the
+// file at path, without its surrounding white space, whenever the token is
+// needed.
+func FileAuthToken(path string) AuthTokenProvider {
+	return AuthTokenFunc(func() (string, error) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read auth token file: %s", err)
+		}
+		token := strings.TrimSpace(string(data))
+		if token == "" {
+			return "", fmt.Errorf("auth token file %s is empty", path)
+		}
+		return token, nil
+	})
+}
+
+// WithAuthToken attaches the token of provider to the gRPC metadata of the
+// streams opened to the peer by Start, under AuthTokenMetadataKey. Start
+// sets it from AuthTokenEnv or AuthTokenFileEnv unless it is passed
+// explicitly. WithAuthToken has no effect on StartInProc.
+func WithAuthToken(provider AuthTokenProvider) Option {
+	return func(h *Handler) error {
+		if provider == nil {
+			return errors.New("auth token provider must not be nil")
+		}
+		h.authToken = provider
+		return nil
+	}
+}
+
+// authTokenFromEnv returns the option sending the token set by AuthTokenEnv
+// or AuthTokenFileEnv, or nil if neither is set.
+func authTokenFromEnv() Option {
+	if token := os.Getenv(AuthTokenEnv); token != "" {
+		return WithAuthToken(StaticAuthToken(token))
+	}
+	if path := os.Getenv(AuthTokenFileEnv); path != "" {
+		return WithAuthToken(FileAuthToken(path))
+	}
+	return nil
+}
+
+// authMetadata returns the metadata value of the token of provider, or an
+// empty string if provider is nil.
+func authMetadata(provider AuthTokenProvider) (string, error) {
+	if provider == nil {
+		return "", nil
+	}
+	token, err := provider.AuthToken()
+	if err != nil {
+		return "", fmt.Errorf("failed to get auth token: %s", err)
+	}
+	return "Bearer " + token, nil
+}
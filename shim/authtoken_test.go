@@ -0,0 +1,126 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestAuthTokenProviders(t *testing.T) {
+	token, err := StaticAuthToken("secret").AuthToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", token)
+
+	dir, err := ioutil.TempDir("", "authtoken")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	provider := FileAuthToken(path)
+
+	_, err = provider.AuthToken()
+	assert.EqualError(t, err, "failed to read auth token file: open "+path+": no such file or directory")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte(" \n"), 0600))
+	_, err = provider.AuthToken()
+	assert.EqualError(t, err, "auth token file "+path+" is empty")
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("first\n"), 0600))
+	token, err = provider.AuthToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "first", token)
+
+	require.NoError(t, ioutil.WriteFile(path, []byte("rotated"), 0600))
+	token, err = provider.AuthToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", token, "the file is read again for every token")
+}
+
+func TestWithAuthToken(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithAuthToken(nil))
+	assert.EqualError(t, err, "auth token provider must not be nil")
+
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithAuthToken(StaticAuthToken("secret")))
+	require.NoError(t, err)
+	assert.Equal(t, "true", h.features()["auth_token"])
+
+	auth, err := authMetadata(h.authToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer secret", auth)
+
+	_, err = authMetadata(AuthTokenFunc(func() (string, error) { return "", errors.New("vault sealed") }))
+	assert.EqualError(t, err, "failed to get auth token: vault sealed")
+}
+
+func TestAuthTokenFromEnv(t *testing.T) {
+	assert.Nil(t, authTokenFromEnv())
+
+	os.Setenv(AuthTokenFileEnv, "/nonexistent/token")
+	defer os.Unsetenv(AuthTokenFileEnv)
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, authTokenFromEnv())
+	require.NoError(t, err)
+	_, err = h.authToken.AuthToken()
+	assert.EqualError(t, err, "failed to read auth token file: open /nonexistent/token: no such file or directory")
+
+	os.Setenv(AuthTokenEnv, "secret")
+	defer os.Unsetenv(AuthTokenEnv)
+	h, err = newChaincodeHandler(nil, &mockChaincode{}, authTokenFromEnv())
+	require.NoError(t, err)
+	token, err := h.authToken.AuthToken()
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", token, "the token takes precedence over the file")
+}
+
+// metadataServer records the metadata of the streams registered.
+type metadataServer struct {
+	md chan metadata.MD
+}
+
+func (s *metadataServer) Register(stream peerpb.ChaincodeSupport_RegisterServer) error {
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.md <- md
+	_, err := stream.Recv()
+	return err
+}
+
+func TestDialPeerAuthToken(t *testing.T) {
+	os.Setenv("CORE_PEER_TLS_ENABLED", "false")
+	defer os.Unsetenv("CORE_PEER_TLS_ENABLED")
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	server := grpc.NewServer()
+	ms := &metadataServer{md: make(chan metadata.MD, 2)}
+	peerpb.RegisterChaincodeSupportServer(server, ms)
+	go server.Serve(lis)
+	defer server.Stop()
+
+	for _, test := range []struct {
+		provider AuthTokenProvider
+		expected []string
+	}{
+		{provider: StaticAuthToken("secret"), expected: []string{"Bearer secret"}},
+		{provider: nil, expected: nil},
+	} {
+		stream, err := dialPeer(lis.Addr().String(), nil, test.provider)
+		require.NoError(t, err)
+		require.NoError(t, stream.Send(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTER}))
+		md := <-ms.md
+		assert.Equal(t, test.expected, md.Get(AuthTokenMetadataKey))
+		stream.CloseSend()
+	}
+
+	_, err = dialPeer(lis.Addr().String(), nil, AuthTokenFunc(func() (string, error) { return "", errors.New("vault sealed") }))
+	assert.EqualError(t, err, "failed to get auth token: vault sealed")
+}
@@ -0,0 +1,318 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package balance keeps the balances of accounts in a token, for account
+// based token chaincode. A Ledger stores the balance of each account in
+// the state, and a record of each movement of funds under a composite key
+// of the account and the transaction, so that the history of an account
+// can be listed without the history database:
+//
+//	tokens, err := balance.New("USD", 2)
+//	...
+//	err = tokens.Transfer(stub, "alice", "bob", money.MustParse("12.50"))
+//
+// The peer does not return the writes of a transaction to its own reads,
+// so a transaction must make all its movements of a token in a single call
+// to Apply, which nets them before updating the balances. Paginated
+// listings are only allowed by the peer in read-only transactions.
+package balance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/money"
+)
+
+const (
+	// balanceType is the composite key object type of balances.
+	balanceType = "balance"
+	// recordType is the composite key object type of movement records.
+	recordType = "balance.record"
+)
+
+// InsufficientFundsError is returned when a movement would make the
+// balance of an account negative.
+type InsufficientFundsError struct {
+	Account string
+	Balance money.Decimal
+	Amount  money.Decimal
+}
+
+func (e *InsufficientFundsError) Error() string {
+	return fmt.Sprintf("insufficient funds in account %s: balance %s, required %s", e.Account, e.Balance, e.Amount)
+}
+
+// Transfer is a movement of Amount from the account From to the account To.
+// Funds are minted if From is empty, and burnt if To is empty.
+type Transfer struct {
+	From   string
+	To     string
+	Amount money.Decimal
+}
+
+// Record is the record of a movement of funds of an account.
+type Record struct {
+	// TxID is the ID of the transaction of the movement.
+	TxID string `json:"txId"`
+	// Counterparty is the other account of the movement, or empty if funds
+	// were minted or burnt.
+	Counterparty string `json:"counterparty,omitempty"`
+	// Amount is the amount credited to the account, negative if it was
+	// debited.
+	Amount money.Decimal `json:"amount"`
+}
+
+// AccountBalance is the balance of an account.
+type AccountBalance struct {
+	Account string
+	Balance money.Decimal
+}
+
+// Ledger keeps the balances of accounts in a token.
+type Ledger struct {
+	token string
+	scale int
+}
+
+// New returns a Ledger for the token, whose amounts have at most scale
+// digits after the decimal point.
+func New(token string, scale int) (*Ledger, error) {
+	if token == "" {
+		return nil, errors.New("token must not be an empty string")
+	}
+	if scale < 0 || scale > money.MaxScale {
+		return nil, fmt.Errorf("scale must be between 0 and %d, got %d", money.MaxScale, scale)
+	}
+	return &Ledger{token: token, scale: scale}, nil
+}
+
+// Balance returns the balance of account, which is zero for an account
+// without funds.
+func (l *Ledger) Balance(stub shim.ChaincodeStubInterface, account string) (money.Decimal, error) {
+	key, err := stub.CreateCompositeKey(balanceType, []string{l.token, account})
+	if err != nil {
+		return money.Decimal{}, fmt.Errorf("invalid account %s: %s", account, err)
+	}
+	b, err := stub.GetState(key)
+	if err != nil {
+		return money.Decimal{}, fmt.Errorf("failed to read balance of account %s: %s", account, err)
+	}
+	if b == nil {
+		return l.zero(), nil
+	}
+	amount, err := money.Parse(string(b))
+	if err != nil {
+		return money.Decimal{}, fmt.Errorf("failed to parse balance of account %s: %s", account, err)
+	}
+	return amount, nil
+}
+
+// Mint credits amount to account.
+func (l *Ledger) Mint(stub shim.ChaincodeStubInterface, account string, amount money.Decimal) error {
+	return l.Apply(stub, Transfer{To: account, Amount: amount})
+}
+
+// Burn debits amount from account.
+func (l *Ledger) Burn(stub shim.ChaincodeStubInterface, account string, amount money.Decimal) error {
+	return l.Apply(stub, Transfer{From: account, Amount: amount})
+}
+
+// Transfer moves amount from the account from to the account to.
+func (l *Ledger) Transfer(stub shim.ChaincodeStubInterface, from, to string, amount money.Decimal) error {
+	return l.Apply(stub, Transfer{From: from, To: to, Amount: amount})
+}
+
+// Apply makes the transfers atomically: either all of them are written, or
+// none is and an error is returned, such as an InsufficientFundsError if
+// the balance of an account would become negative. Balances are checked
+// after netting all the transfers, so funds received by an account can be
+// spent by a later transfer of the same call.
+func (l *Ledger) Apply(stub shim.ChaincodeStubInterface, transfers ...Transfer) error {
+	type account struct {
+		balance money.Decimal
+		debits  money.Decimal
+	}
+	accounts := map[string]*account{}
+	var order []string
+	get := func(name string) (*account, error) {
+		if a, ok := accounts[name]; ok {
+			return a, nil
+		}
+		balance, err := l.Balance(stub, name)
+		if err != nil {
+			return nil, err
+		}
+		a := &account{balance: balance, debits: l.zero()}
+		accounts[name] = a
+		order = append(order, name)
+		return a, nil
+	}
+
+	var records []recordWrite
+	for i, t := range transfers {
+		if t.From == "" && t.To == "" {
+			return fmt.Errorf("transfer %d has neither a source nor a destination account", i)
+		}
+		if t.From == t.To {
+			return fmt.Errorf("transfer %d has the same source and destination account %s", i, t.From)
+		}
+		amount, err := l.amount(t.Amount)
+		if err != nil {
+			return fmt.Errorf("invalid amount of transfer %d: %s", i, err)
+		}
+		if t.From != "" {
+			a, err := get(t.From)
+			if err != nil {
+				return err
+			}
+			a.balance = a.balance.Sub(amount)
+			a.debits = a.debits.Add(amount)
+			records = append(records, recordWrite{index: i, account: t.From, record: Record{Counterparty: t.To, Amount: amount.Neg()}})
+		}
+		if t.To != "" {
+			a, err := get(t.To)
+			if err != nil {
+				return err
+			}
+			a.balance = a.balance.Add(amount)
+			records = append(records, recordWrite{index: i, account: t.To, record: Record{Counterparty: t.From, Amount: amount}})
+		}
+	}
+
+	for _, name := range order {
+		a := accounts[name]
+		if a.balance.Sign() < 0 {
+			return &InsufficientFundsError{Account: name, Balance: a.balance.Add(a.debits), Amount: a.debits}
+		}
+	}
+	for _, name := range order {
+		if err := l.putBalance(stub, name, accounts[name].balance); err != nil {
+			return err
+		}
+	}
+	for _, r := range records {
+		if err := l.putRecord(stub, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// zero returns 0 at the scale of the ledger.
+func (l *Ledger) zero() money.Decimal {
+	// the scale was checked by New
+	zero, _ := money.NewFromInt64(0, l.scale)
+	return zero
+}
+
+// amount returns amount at the scale of the ledger, or an error if it is
+// not positive or has more digits after the decimal point.
+func (l *Ledger) amount(amount money.Decimal) (money.Decimal, error) {
+	if amount.Sign() <= 0 {
+		return money.Decimal{}, fmt.Errorf("amount must be positive, got %s", amount)
+	}
+	scaled, err := amount.Rescale(l.scale, money.RoundDown)
+	if err != nil || scaled.Cmp(amount) != 0 {
+		return money.Decimal{}, fmt.Errorf("amount %s has more than %d digits after the decimal point", amount, l.scale)
+	}
+	return scaled, nil
+}
+
+func (l *Ledger) putBalance(stub shim.ChaincodeStubInterface, account string, balance money.Decimal) error {
+	key, err := stub.CreateCompositeKey(balanceType, []string{l.token, account})
+	if err != nil {
+		return fmt.Errorf("invalid account %s: %s", account, err)
+	}
+	if balance.IsZero() {
+		err = stub.DelState(key)
+	} else {
+		err = stub.PutState(key, []byte(balance.String()))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write balance of account %s: %s", account, err)
+	}
+	return nil
+}
+
+// recordWrite is a record to write for the transfer at index in a call to
+// Apply.
+type recordWrite struct {
+	index   int
+	account string
+	record  Record
+}
+
+func (l *Ledger) putRecord(stub shim.ChaincodeStubInterface, r recordWrite) error {
+	txID := stub.GetTxID()
+	// the index keeps the records of the transfers of a transaction apart
+	key, err := stub.CreateCompositeKey(recordType, []string{l.token, r.account, txID, fmt.Sprintf("%08d", r.index)})
+	if err != nil {
+		return fmt.Errorf("invalid account %s: %s", r.account, err)
+	}
+	r.record.TxID = txID
+	b, err := json.Marshal(&r.record)
+	if err != nil {
+		return fmt.Errorf("failed to marshal record of account %s: %s", r.account, err)
+	}
+	if err := stub.PutState(key, b); err != nil {
+		return fmt.Errorf("failed to write record of account %s: %s", r.account, err)
+	}
+	return nil
+}
+
+// ListBalances returns a page of at most pageSize balances of accounts
+// with funds, in the order of the account names, starting at bookmark,
+// and the bookmark of the next page, which is empty after the last page.
+func (l *Ledger) ListBalances(stub shim.ChaincodeStubInterface, pageSize int32, bookmark string) ([]AccountBalance, string, error) {
+	iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(balanceType, []string{l.token}, pageSize, bookmark)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list balances: %s", err)
+	}
+	defer iter.Close()
+
+	var balances []AccountBalance
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list balances: %s", err)
+		}
+		_, attrs, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil || len(attrs) != 2 {
+			return nil, "", fmt.Errorf("invalid balance key %q", kv.Key)
+		}
+		amount, err := money.Parse(string(kv.Value))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse balance of account %s: %s", attrs[1], err)
+		}
+		balances = append(balances, AccountBalance{Account: attrs[1], Balance: amount})
+	}
+	return balances, metadata.GetBookmark(), nil
+}
+
+// ListRecords returns a page of at most pageSize records of the movements
+// of account, in the order of their transaction IDs, starting at bookmark,
+// and the bookmark of the next page, which is empty after the last page.
+func (l *Ledger) ListRecords(stub shim.ChaincodeStubInterface, account string, pageSize int32, bookmark string) ([]Record, string, error) {
+	iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(recordType, []string{l.token, account}, pageSize, bookmark)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to list records of account %s: %s", account, err)
+	}
+	defer iter.Close()
+
+	var records []Record
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list records of account %s: %s", account, err)
+		}
+		var r Record
+		if err := json.Unmarshal(kv.Value, &r); err != nil {
+			return nil, "", fmt.Errorf("failed to unmarshal record of account %s: %s", account, err)
+		}
+		records = append(records, r)
+	}
+	return records, metadata.GetBookmark(), nil
+}
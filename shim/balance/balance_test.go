@@ -0,0 +1,193 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package balance_test
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/balance"
+	"github.com/hyperledger/fabric-chaincode-go/shim/money"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newLedger(t *testing.T) (*balance.Ledger, *shimtest.MockStub) {
+	ledger, err := balance.New("USD", 2)
+	require.NoError(t, err)
+	return ledger, shimtest.NewMockStub("balance", nil)
+}
+
+func tx(stub *shimtest.MockStub, txID string, fn func() error) error {
+	stub.MockTransactionStart(txID)
+	defer stub.MockTransactionEnd(txID)
+	return fn()
+}
+
+func assertBalance(t *testing.T, ledger *balance.Ledger, stub *shimtest.MockStub, account, expected string) {
+	amount, err := ledger.Balance(stub, account)
+	require.NoError(t, err)
+	assert.Equal(t, expected, amount.String(), "balance of %s", account)
+}
+
+func TestTransfer(t *testing.T) {
+	ledger, stub := newLedger(t)
+	assertBalance(t, ledger, stub, "alice", "0.00")
+
+	require.NoError(t, tx(stub, "tx1", func() error {
+		return ledger.Mint(stub, "alice", money.MustParse("100"))
+	}))
+	require.NoError(t, tx(stub, "tx2", func() error {
+		return ledger.Transfer(stub, "alice", "bob", money.MustParse("12.5"))
+	}))
+	assertBalance(t, ledger, stub, "alice", "87.50")
+	assertBalance(t, ledger, stub, "bob", "12.50")
+
+	err := tx(stub, "tx3", func() error {
+		return ledger.Transfer(stub, "bob", "carol", money.MustParse("20"))
+	})
+	assert.Equal(t, &balance.InsufficientFundsError{
+		Account: "bob",
+		Balance: money.MustParse("12.50"),
+		Amount:  money.MustParse("20.00"),
+	}, err)
+	assert.EqualError(t, err, "insufficient funds in account bob: balance 12.50, required 20.00")
+	assertBalance(t, ledger, stub, "bob", "12.50")
+	assertBalance(t, ledger, stub, "carol", "0.00")
+
+	require.NoError(t, tx(stub, "tx4", func() error {
+		return ledger.Burn(stub, "bob", money.MustParse("12.50"))
+	}))
+	assertBalance(t, ledger, stub, "bob", "0.00")
+	key, err := stub.CreateCompositeKey("balance", []string{"USD", "bob"})
+	require.NoError(t, err)
+	assert.NotContains(t, stub.State, key, "empty balances are deleted")
+}
+
+func TestApply(t *testing.T) {
+	ledger, stub := newLedger(t)
+	require.NoError(t, tx(stub, "tx1", func() error {
+		return ledger.Mint(stub, "alice", money.MustParse("10"))
+	}))
+
+	// bob spends funds received in the same call
+	require.NoError(t, tx(stub, "tx2", func() error {
+		return ledger.Apply(stub,
+			balance.Transfer{From: "alice", To: "bob", Amount: money.MustParse("6")},
+			balance.Transfer{From: "bob", To: "carol", Amount: money.MustParse("5")},
+			balance.Transfer{From: "alice", To: "bob", Amount: money.MustParse("4")},
+		)
+	}))
+	assertBalance(t, ledger, stub, "alice", "0.00")
+	assertBalance(t, ledger, stub, "bob", "5.00")
+	assertBalance(t, ledger, stub, "carol", "5.00")
+
+	// nothing is written if one transfer fails
+	err := tx(stub, "tx3", func() error {
+		return ledger.Apply(stub,
+			balance.Transfer{From: "bob", To: "carol", Amount: money.MustParse("5")},
+			balance.Transfer{From: "carol", To: "dave", Amount: money.MustParse("11")},
+		)
+	})
+	assert.IsType(t, &balance.InsufficientFundsError{}, err)
+	assertBalance(t, ledger, stub, "bob", "5.00")
+	assertBalance(t, ledger, stub, "carol", "5.00")
+}
+
+func TestApplyErrors(t *testing.T) {
+	ledger, stub := newLedger(t)
+	var tests = []struct {
+		name     string
+		transfer balance.Transfer
+		err      string
+	}{
+		{name: "No Accounts", transfer: balance.Transfer{Amount: money.MustParse("1")}, err: "transfer 0 has neither a source nor a destination account"},
+		{name: "Same Account", transfer: balance.Transfer{From: "alice", To: "alice", Amount: money.MustParse("1")}, err: "transfer 0 has the same source and destination account alice"},
+		{name: "Zero", transfer: balance.Transfer{To: "alice"}, err: "invalid amount of transfer 0: amount must be positive, got 0"},
+		{name: "Negative", transfer: balance.Transfer{To: "alice", Amount: money.MustParse("-1")}, err: "invalid amount of transfer 0: amount must be positive, got -1"},
+		{name: "Scale", transfer: balance.Transfer{To: "alice", Amount: money.MustParse("0.001")}, err: "invalid amount of transfer 0: amount 0.001 has more than 2 digits after the decimal point"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := tx(stub, "tx", func() error {
+				return ledger.Apply(stub, test.transfer)
+			})
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	_, err := balance.New("", 2)
+	assert.EqualError(t, err, "token must not be an empty string")
+	_, err = balance.New("USD", -1)
+	assert.EqualError(t, err, "scale must be between 0 and 36, got -1")
+}
+
+func TestListBalances(t *testing.T) {
+	ledger, stub := newLedger(t)
+	other, err := balance.New("EUR", 2)
+	require.NoError(t, err)
+	require.NoError(t, tx(stub, "tx1", func() error {
+		for i, account := range []string{"dave", "alice", "carol", "bob", "erin"} {
+			if err := ledger.Mint(stub, account, money.MustParse(strconv.Itoa(i+1))); err != nil {
+				return err
+			}
+		}
+		return other.Mint(stub, "alice", money.MustParse("1"))
+	}))
+
+	var balances []balance.AccountBalance
+	bookmark := ""
+	for {
+		page, next, err := ledger.ListBalances(stub, 2, bookmark)
+		require.NoError(t, err)
+		assert.True(t, len(page) <= 2)
+		balances = append(balances, page...)
+		if next == "" {
+			break
+		}
+		bookmark = next
+	}
+
+	var listed []string
+	for _, b := range balances {
+		listed = append(listed, b.Account+"="+b.Balance.String())
+	}
+	assert.Equal(t, []string{"alice=2.00", "bob=4.00", "carol=3.00", "dave=1.00", "erin=5.00"}, listed)
+}
+
+func TestListRecords(t *testing.T) {
+	ledger, stub := newLedger(t)
+	require.NoError(t, tx(stub, "tx1", func() error {
+		return ledger.Mint(stub, "alice", money.MustParse("10"))
+	}))
+	require.NoError(t, tx(stub, "tx2", func() error {
+		return ledger.Apply(stub,
+			balance.Transfer{From: "alice", To: "bob", Amount: money.MustParse("1")},
+			balance.Transfer{From: "alice", To: "bob", Amount: money.MustParse("2")},
+		)
+	}))
+
+	records, next, err := ledger.ListRecords(stub, "alice", 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, []balance.Record{
+		{TxID: "tx1", Amount: money.MustParse("10.00")},
+		{TxID: "tx2", Counterparty: "bob", Amount: money.MustParse("-1.00")},
+	}, records)
+	require.NotEmpty(t, next)
+
+	records, next, err = ledger.ListRecords(stub, "alice", 2, next)
+	require.NoError(t, err)
+	assert.Equal(t, []balance.Record{
+		{TxID: "tx2", Counterparty: "bob", Amount: money.MustParse("-2.00")},
+	}, records)
+	assert.Empty(t, next)
+
+	records, _, err = ledger.ListRecords(stub, "bob", 0, "")
+	require.NoError(t, err)
+	assert.Len(t, records, 2)
+}
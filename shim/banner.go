@@ -0,0 +1,215 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal"
+)
+
+// modulePath is the path of the module providing the shim.
+const modulePath = "github.com/hyperledger/fabric-chaincode-go"
+
+// redacted replaces the values of the secret environment variables in the
+// startup banner.
+const redacted = "[REDACTED]"
+
+// maxBannerValueLength is the length above which the value of an
+// environment variable, typically inline TLS material, is replaced by its
+// length in the startup banner.
+const maxBannerValueLength = 256
+
+// startupBanner is the configuration in effect when a chaincode starts,
+// logged as a single JSON record so that it can be attached to a support
+// request.
+type startupBanner struct {
+	Version        string            `json:"version"`
+	Mode           string            `json:"mode"`
+	ChaincodeID    string            `json:"chaincode_id"`
+	PeerAddress    string            `json:"peer_address"`
+	TLSEnabled     bool              `json:"tls_enabled"`
+	MaxPayloadSize int               `json:"max_payload_size"`
+	MaxRecvMsgSize int               `json:"max_recv_message_size"`
+	MaxSendMsgSize int               `json:"max_send_message_size"`
+	Features       map[string]string `json:"features"`
+	Env            map[string]string `json:"env"`
+}
+
+// logStartupBanner logs the configuration of handler when chaincodename
+// starts in client mode.
+func (h *Handler) logStartupBanner(chaincodename string) {
+	banner := h.startupBanner(chaincodename)
+	record, err := json.Marshal(banner)
+	if err != nil {
+		h.logf("failed to marshal startup banner: %s", err)
+		return
+	}
+	h.logf("starting chaincode %s: %s", chaincodename, record)
+}
+
+func (h *Handler) startupBanner(chaincodename string) startupBanner {
+	maxPayloadSize := h.maxPayloadSize
+	if maxPayloadSize <= 0 {
+		maxPayloadSize = DefaultMaxPayloadSize
+	}
+	tlsEnabled, _ := strconv.ParseBool(os.Getenv("CORE_PEER_TLS_ENABLED"))
+
+	return startupBanner{
+		Version:        shimVersion(),
+		Mode:           "client",
+		ChaincodeID:    chaincodename,
+		PeerAddress:    *peerAddress,
+		TLSEnabled:     tlsEnabled,
+		MaxPayloadSize: maxPayloadSize,
+		MaxRecvMsgSize: internal.MaxRecvMessageSize,
+		MaxSendMsgSize: internal.MaxSendMessageSize,
+		Features:       h.features(),
+		Env:            bannerEnv(os.Environ()),
+	}
+}
+
+// features returns the optional features enabled on the handler.
+func (h *Handler) features() map[string]string {
+	features := map[string]string{}
+	if h.streams > 1 {
+		features["streams"] = strconv.Itoa(h.streams)
+	}
+	if h.scheduler != nil {
+		features["scheduler_workers"] = strconv.Itoa(h.scheduler.config.Workers)
+	}
+	if h.retryPolicy != nil {
+		features["retry_max_attempts"] = strconv.Itoa(h.retryPolicy.MaxAttempts)
+	}
+	if h.offchain != nil {
+		features["offchain_queue_size"] = strconv.Itoa(h.offchain.policy.QueueSize)
+	}
+	if h.errorSampler != nil {
+		features["log_sampling"] = fmt.Sprintf("%d/%s", h.errorSampler.sampling.Burst, h.errorSampler.sampling.Interval)
+	}
+	if h.argLimits != (ArgLimits{}) {
+		features["arg_limits"] = fmt.Sprintf("count=%d,size=%d,total=%d", h.argLimits.MaxCount, h.argLimits.MaxSize, h.argLimits.MaxTotalSize)
+	}
+	if h.queryBudget > 0 {
+		features["query_budget"] = strconv.Itoa(h.queryBudget)
+	}
+	if h.writeLimits != nil {
+		features["write_limits"] = fmt.Sprintf("value_size=%d,keys=%d,banned_prefixes=%d", h.writeLimits.MaxValueSize, h.writeLimits.MaxKeysPerTx, len(h.writeLimits.BannedPrefixes))
+	}
+	if h.eventCompression > 0 {
+		features["event_compression"] = strconv.Itoa(h.eventCompression)
+	}
+	if h.watchdog != nil {
+		l := h.watchdog.limits
+		features["watchdog"] = fmt.Sprintf("goroutines=%d,heap_bytes=%d,in_flight=%d,reject=%t", l.MaxGoroutines, l.MaxHeapBytes, l.MaxInFlight, l.Reject)
+	}
+	if h.decryptor != nil {
+		features["decryptor"] = "true"
+	}
+	if h.functionStats != nil {
+		features["function_stats"] = "true"
+		if h.functionStats.queryFunction != "" {
+			features["function_stats"] = h.functionStats.queryFunction
+		}
+	}
+	if h.strictQueries {
+		features["strict_queries"] = "true"
+	}
+	if h.strictKeys {
+		features["strict_keys"] = "true"
+	}
+	if logPayloads {
+		features["log_payloads"] = "true"
+	}
+	if h.hashPayloads {
+		features["payload_hashing"] = "true"
+	}
+	if h.peerMonitor != nil {
+		features["peer_monitor"] = "true"
+	}
+	if h.credentials != nil {
+		features["credential_provider"] = "true"
+	}
+	if h.authToken != nil {
+		features["auth_token"] = "true"
+	}
+	if h.adaptivePager != nil {
+		paging := h.adaptivePager.paging
+		features["adaptive_paging"] = fmt.Sprintf("target=%d,min=%d,max=%d", paging.TargetPageBytes, paging.MinPageSize, paging.MaxPageSize)
+	}
+	if h.reconnect != nil {
+		features["reconnect_max_attempts"] = strconv.Itoa(h.reconnect.MaxAttempts)
+	}
+	if h.configReloader != nil {
+		features["config_file"] = h.configReloader.path
+	}
+	return features
+}
+
+// shimVersion returns the version of the module providing the shim as
+// recorded in the build information of the binary.
+func shimVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+	if info.Main.Path == modulePath {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Version + " => " + dep.Replace.Path + " " + dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+	return "unknown"
+}
+
+// bannerEnv returns the CORE_ variables of environ with the values of the
+// secret ones redacted.
+func bannerEnv(environ []string) map[string]string {
+	env := map[string]string{}
+	for _, kv := range environ {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || !strings.HasPrefix(parts[0], "CORE_") {
+			continue
+		}
+		name, value := parts[0], parts[1]
+		switch {
+		case isSecretEnv(name):
+			value = redacted
+		case len(value) > maxBannerValueLength:
+			value = fmt.Sprintf("<%d bytes>", len(value))
+		}
+		env[name] = value
+	}
+	return env
+}
+
+// secretEnvWords are the words identifying environment variables holding
+// secrets.
+var secretEnvWords = []string{"KEY", "SECRET", "PASSWORD", "TOKEN"}
+
+// isSecretEnv reports whether the environment variable name holds a secret
+// rather than the path of a file holding one.
+func isSecretEnv(name string) bool {
+	if strings.HasSuffix(name, "_PATH") || strings.HasSuffix(name, "_FILE") {
+		return false
+	}
+	for _, word := range strings.Split(name, "_") {
+		for _, secret := range secretEnvWords {
+			if word == secret {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// BuilderMetadataDirEnv is the environment variable naming the
+	// directory holding the metadata written for the chaincode by an
+	// external builder.
+	BuilderMetadataDirEnv = "CORE_CHAINCODE_METADATA_DIR"
+	// DefaultBuilderMetadataDir is the directory searched for builder
+	// metadata when BuilderMetadataDirEnv is not set.
+	DefaultBuilderMetadataDir = "/chaincode/env"
+)
+
+// builderMetadataFiles are the names of the metadata files, in order of
+// preference: metadata.json, then the chaincode.json written by the run
+// stage of external builders.
+var builderMetadataFiles = []string{"metadata.json", "chaincode.json"}
+
+// BuilderMetadata is the connection information an external builder
+// provides to the chaincode. The TLS material is either PEM data or the
+// path of a file holding it, relative to the metadata directory.
+type BuilderMetadata struct {
+	ChaincodeID string `json:"chaincode_id"`
+	PeerAddress string `json:"peer_address"`
+	ClientCert  string `json:"client_cert"`
+	ClientKey   string `json:"client_key"`
+	RootCert    string `json:"root_cert"`
+	MSPID       string `json:"mspid"`
+}
+
+// LoadBuilderMetadata reads the builder metadata of dir. It returns nil if
+// dir holds no metadata file.
+func LoadBuilderMetadata(dir string) (*BuilderMetadata, error) {
+	for _, name := range builderMetadataFiles {
+		path := filepath.Join(dir, name)
+		data, err := ioutil.ReadFile(path)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read builder metadata: %s", err)
+		}
+		metadata := &BuilderMetadata{}
+		if err := json.Unmarshal(data, metadata); err != nil {
+			return nil, fmt.Errorf("failed to parse builder metadata %s: %s", path, err)
+		}
+		metadata.resolvePaths(dir)
+		return metadata, nil
+	}
+	return nil, nil
+}
+
+// resolvePaths makes the paths of the TLS material relative to dir.
+func (m *BuilderMetadata) resolvePaths(dir string) {
+	for _, value := range []*string{&m.ClientCert, &m.ClientKey, &m.RootCert} {
+		if *value != "" && !isPEM(*value) && !filepath.IsAbs(*value) {
+			*value = filepath.Join(dir, *value)
+		}
+	}
+}
+
+func isPEM(value string) bool {
+	return strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN")
+}
+
+// apply sets the environment variables and the peer address flag read by
+// Start from m. Variables and flags which are already set take precedence.
+func (m *BuilderMetadata) apply() error {
+	if *peerAddress == "" {
+		*peerAddress = m.PeerAddress
+	}
+	vars := map[string]string{
+		"CORE_CHAINCODE_ID_NAME": m.ChaincodeID,
+		"CORE_PEER_LOCALMSPID":   m.MSPID,
+	}
+	tlsEnabled := m.ClientCert != "" || m.ClientKey != "" || m.RootCert != ""
+	vars["CORE_PEER_TLS_ENABLED"] = fmt.Sprint(tlsEnabled)
+	tlsVars := []struct{ env, value string }{
+		{"CORE_TLS_CLIENT_CERT", m.ClientCert},
+		{"CORE_TLS_CLIENT_KEY", m.ClientKey},
+		{"CORE_PEER_TLS_ROOTCERT", m.RootCert},
+	}
+	for _, v := range tlsVars {
+		if _, set := os.LookupEnv(v.env); set {
+			continue
+		}
+		if _, set := os.LookupEnv(v.env + "_FILE"); set {
+			continue
+		}
+		if isPEM(v.value) {
+			vars[v.env] = v.value
+		} else {
+			vars[v.env+"_FILE"] = v.value
+		}
+	}
+
+	for env, value := range vars {
+		if _, set := os.LookupEnv(env); set || value == "" {
+			continue
+		}
+		if err := os.Setenv(env, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyBuilderMetadataFromEnv applies the builder metadata of the directory
+// named by BuilderMetadataDirEnv, or of DefaultBuilderMetadataDir, if any.
+func applyBuilderMetadataFromEnv() error {
+	dir := os.Getenv(BuilderMetadataDirEnv)
+	if dir == "" {
+		dir = DefaultBuilderMetadataDir
+	}
+	metadata, err := LoadBuilderMetadata(dir)
+	if err != nil || metadata == nil {
+		return err
+	}
+	return metadata.apply()
+}
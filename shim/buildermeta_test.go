@@ -0,0 +1,89 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBuilderMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "builder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	metadata, err := LoadBuilderMetadata(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, metadata, "no metadata file")
+
+	chaincodeJSON := `{"chaincode_id": "cc:1", "peer_address": "peer0:7052", "root_cert": "-----BEGIN CERTIFICATE-----\nroot", "mspid": "Org1MSP"}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "chaincode.json"), []byte(chaincodeJSON), 0600))
+	metadata, err = LoadBuilderMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, &BuilderMetadata{
+		ChaincodeID: "cc:1",
+		PeerAddress: "peer0:7052",
+		RootCert:    "-----BEGIN CERTIFICATE-----\nroot",
+		MSPID:       "Org1MSP",
+	}, metadata)
+
+	metadataJSON := `{"chaincode_id": "cc:2", "peer_address": "peer1:7052", "client_cert": "tls/client.crt", "client_key": "/etc/tls/client.key", "root_cert": "tls/root.crt"}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "metadata.json"), []byte(metadataJSON), 0600))
+	metadata, err = LoadBuilderMetadata(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, &BuilderMetadata{
+		ChaincodeID: "cc:2",
+		PeerAddress: "peer1:7052",
+		ClientCert:  filepath.Join(dir, "tls", "client.crt"),
+		ClientKey:   "/etc/tls/client.key",
+		RootCert:    filepath.Join(dir, "tls", "root.crt"),
+	}, metadata, "metadata.json should be preferred and relative paths resolved")
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "metadata.json"), []byte("{"), 0600))
+	_, err = LoadBuilderMetadata(dir)
+	assert.Contains(t, err.Error(), "failed to parse builder metadata")
+}
+
+func TestApplyBuilderMetadata(t *testing.T) {
+	envs := []string{
+		"CORE_CHAINCODE_ID_NAME", "CORE_PEER_LOCALMSPID", "CORE_PEER_TLS_ENABLED",
+		"CORE_TLS_CLIENT_CERT", "CORE_TLS_CLIENT_CERT_FILE", "CORE_TLS_CLIENT_KEY", "CORE_TLS_CLIENT_KEY_FILE",
+		"CORE_PEER_TLS_ROOTCERT", "CORE_PEER_TLS_ROOTCERT_FILE", BuilderMetadataDirEnv,
+	}
+	for _, env := range envs {
+		defer os.Unsetenv(env)
+	}
+	defer func(original *string) { peerAddress = original }(peerAddress)
+	address := ""
+	peerAddress = &address
+
+	dir, err := ioutil.TempDir("", "builder")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	metadataJSON := `{"chaincode_id": "cc:1", "peer_address": "peer0:7052", "client_cert": "client.crt", "client_key": "client.key", "root_cert": "-----BEGIN CERTIFICATE-----\nroot", "mspid": "Org1MSP"}`
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "metadata.json"), []byte(metadataJSON), 0600))
+
+	os.Setenv(BuilderMetadataDirEnv, dir)
+	os.Setenv("CORE_CHAINCODE_ID_NAME", "explicit")
+	os.Setenv("CORE_TLS_CLIENT_KEY", "explicit key")
+	require.NoError(t, applyBuilderMetadataFromEnv())
+
+	assert.Equal(t, "peer0:7052", *peerAddress)
+	assert.Equal(t, "explicit", os.Getenv("CORE_CHAINCODE_ID_NAME"), "the environment should take precedence")
+	assert.Equal(t, "Org1MSP", os.Getenv("CORE_PEER_LOCALMSPID"))
+	assert.Equal(t, "true", os.Getenv("CORE_PEER_TLS_ENABLED"))
+	assert.Equal(t, filepath.Join(dir, "client.crt"), os.Getenv("CORE_TLS_CLIENT_CERT_FILE"))
+	assert.Equal(t, "explicit key", os.Getenv("CORE_TLS_CLIENT_KEY"))
+	_, set := os.LookupEnv("CORE_TLS_CLIENT_KEY_FILE")
+	assert.False(t, set)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\nroot", os.Getenv("CORE_PEER_TLS_ROOTCERT"))
+
+	os.Setenv(BuilderMetadataDirEnv, filepath.Join(dir, "missing"))
+	assert.NoError(t, applyBuilderMetadataFromEnv())
+}
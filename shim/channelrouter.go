@@ -0,0 +1,78 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ChannelRouter is a Chaincode passing the transactions of each channel to
+// the Chaincode registered for the channel, so that one chaincode process
+// serves different implementations on different channels, such as test
+// and production channels:
+//
+//	router, err := shim.NewChannelRouter(map[string]shim.Chaincode{
+//		"testnet": &testnetChaincode{},
+//	}, &mainnetChaincode{})
+//	...
+//	err = shim.Start(router)
+//
+// The channel is the channel ID of the proposal, as returned by
+// GetChannelID.
+type ChannelRouter struct {
+	chaincodes map[string]Chaincode
+	fallback   Chaincode
+}
+
+// NewChannelRouter returns a ChannelRouter passing the transactions of the
+// channels of chaincodes to their Chaincode, and those of the other
+// channels to fallback. If fallback is nil, the transactions of the other
+// channels fail.
+func NewChannelRouter(chaincodes map[string]Chaincode, fallback Chaincode) (*ChannelRouter, error) {
+	r := &ChannelRouter{chaincodes: map[string]Chaincode{}, fallback: fallback}
+	for channel, cc := range chaincodes {
+		if channel == "" {
+			return nil, errors.New("channel ID must not be an empty string")
+		}
+		if cc == nil {
+			return nil, fmt.Errorf("chaincode of channel %s must not be nil", channel)
+		}
+		r.chaincodes[channel] = cc
+	}
+	if len(r.chaincodes) == 0 && fallback == nil {
+		return nil, errors.New("no chaincode to route to")
+	}
+	return r, nil
+}
+
+// Chaincode returns the Chaincode serving the transactions of channel, or
+// nil if there is none.
+func (r *ChannelRouter) Chaincode(channel string) Chaincode {
+	if cc, ok := r.chaincodes[channel]; ok {
+		return cc
+	}
+	return r.fallback
+}
+
+// Init calls the Init function of the Chaincode of the channel of stub.
+func (r *ChannelRouter) Init(stub ChaincodeStubInterface) pb.Response {
+	cc := r.Chaincode(stub.GetChannelID())
+	if cc == nil {
+		return Error(fmt.Sprintf("no chaincode serves channel %s", stub.GetChannelID()))
+	}
+	return cc.Init(stub)
+}
+
+// Invoke calls the Invoke function of the Chaincode of the channel of
+// stub.
+func (r *ChannelRouter) Invoke(stub ChaincodeStubInterface) pb.Response {
+	cc := r.Chaincode(stub.GetChannelID())
+	if cc == nil {
+		return Error(fmt.Sprintf("no chaincode serves channel %s", stub.GetChannelID()))
+	}
+	return cc.Invoke(stub)
+}
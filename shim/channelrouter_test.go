@@ -0,0 +1,62 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelRouter(t *testing.T) {
+	testnet, fallback := &mockChaincode{}, &mockChaincode{}
+	router, err := NewChannelRouter(map[string]Chaincode{"testnet": testnet}, fallback)
+	require.NoError(t, err)
+
+	res := router.Invoke(&ChaincodeStub{ChannelID: "testnet"})
+	assert.Equal(t, int32(OK), res.Status)
+	assert.True(t, testnet.invokeCalled)
+	assert.False(t, fallback.invokeCalled)
+
+	res = router.Init(&ChaincodeStub{ChannelID: "mainnet"})
+	assert.Equal(t, int32(OK), res.Status)
+	assert.True(t, fallback.initCalled)
+	assert.False(t, testnet.initCalled)
+
+	assert.Equal(t, testnet, router.Chaincode("testnet"))
+	assert.Equal(t, fallback, router.Chaincode("mainnet"))
+}
+
+func TestChannelRouterWithoutFallback(t *testing.T) {
+	testnet := &mockChaincode{}
+	router, err := NewChannelRouter(map[string]Chaincode{"testnet": testnet}, nil)
+	require.NoError(t, err)
+
+	res := router.Invoke(&ChaincodeStub{ChannelID: "mainnet"})
+	assert.Equal(t, int32(ERROR), res.Status)
+	assert.Equal(t, "no chaincode serves channel mainnet", res.Message)
+	res = router.Init(&ChaincodeStub{ChannelID: "mainnet"})
+	assert.Equal(t, "no chaincode serves channel mainnet", res.Message)
+	assert.False(t, testnet.invokeCalled)
+	assert.Nil(t, router.Chaincode("mainnet"))
+}
+
+func TestNewChannelRouterInvalid(t *testing.T) {
+	var tests = []struct {
+		name       string
+		chaincodes map[string]Chaincode
+		errMsg     string
+	}{
+		{name: "EmptyChannel", chaincodes: map[string]Chaincode{"": &mockChaincode{}}, errMsg: "channel ID must not be an empty string"},
+		{name: "NilChaincode", chaincodes: map[string]Chaincode{"testnet": nil}, errMsg: "chaincode of channel testnet must not be nil"},
+		{name: "NoChaincode", errMsg: "no chaincode to route to"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewChannelRouter(test.chaincodes, nil)
+			assert.EqualError(t, err, test.errMsg)
+		})
+	}
+}
@@ -0,0 +1,220 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package checksum attests the integrity of the state of a chaincode
+// across organizations. Each organization computes the checksum of a
+// range of keys on its own peers, in a query, and records it in the
+// ledger in a transaction; differing checksums reveal a peer whose state
+// database diverged or was tampered with:
+//
+//	sum, err := checksum.Scan(stub, "asset:", "asset;", 1000)  // query
+//	...
+//	err = checksum.Attest(stub, "assets", sum)  // transaction
+//	...
+//	attestations, agree, err := checksum.Compare(stub, "assets")
+//
+// Scan reads the keys with paginated queries, which the peer only allows
+// in queries that are not submitted for ordering.
+package checksum
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// objectType is the composite key object type of attestations.
+const objectType = "checksum"
+
+// Checksum is the checksum of the keys and values of a range of keys.
+type Checksum struct {
+	// Root is the hex encoded root of the hash chain of the keys and their
+	// values, in key order.
+	Root string `json:"root"`
+	// Keys is the number of keys.
+	Keys int `json:"keys"`
+}
+
+// Attestation is the checksum recorded by an organization.
+type Attestation struct {
+	Checksum
+	// MSPID is the MSP ID of the organization.
+	MSPID string `json:"mspId"`
+	// TxID is the ID of the transaction which recorded the attestation.
+	TxID string `json:"txId"`
+	// Timestamp is the timestamp of that transaction.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// hasher computes the hash chain of a sequence of keys and values. Each
+// key and value is hashed into a leaf, and each leaf is chained to the
+// hash of the previous ones, so that the root depends on the order,
+// the keys and the values.
+type hasher struct {
+	root [sha256.Size]byte
+	keys int
+}
+
+func (h *hasher) add(key string, value []byte) {
+	leaf := sha256.New()
+	leaf.Write([]byte{0})
+	writeLengthPrefixed(leaf, []byte(key))
+	writeLengthPrefixed(leaf, value)
+
+	chain := sha256.New()
+	chain.Write([]byte{1})
+	chain.Write(h.root[:])
+	chain.Write(leaf.Sum(nil))
+	copy(h.root[:], chain.Sum(nil))
+	h.keys++
+}
+
+func writeLengthPrefixed(w interface{ Write([]byte) (int, error) }, b []byte) {
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(b)))
+	w.Write(size[:])
+	w.Write(b)
+}
+
+func (h *hasher) checksum() *Checksum {
+	return &Checksum{Root: hex.EncodeToString(h.root[:]), Keys: h.keys}
+}
+
+// Scan returns the checksum of the simple keys in the range [startKey,
+// endKey), read in pages of pageSize keys. Empty start and end keys are
+// unbounded, as with GetStateByRange.
+func Scan(stub shim.ChaincodeStubInterface, startKey, endKey string, pageSize int32) (*Checksum, error) {
+	return scan(func(bookmark string) (shim.StateQueryIteratorInterface, string, error) {
+		iter, metadata, err := stub.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+		if err != nil {
+			return nil, "", err
+		}
+		return iter, metadata.GetBookmark(), nil
+	}, pageSize)
+}
+
+// ScanCompositeKeys returns the checksum of the composite keys of
+// objectType starting with the attributes of partial, read in pages of
+// pageSize keys.
+func ScanCompositeKeys(stub shim.ChaincodeStubInterface, objectType string, partial []string, pageSize int32) (*Checksum, error) {
+	return scan(func(bookmark string) (shim.StateQueryIteratorInterface, string, error) {
+		iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination(objectType, partial, pageSize, bookmark)
+		if err != nil {
+			return nil, "", err
+		}
+		return iter, metadata.GetBookmark(), nil
+	}, pageSize)
+}
+
+func scan(page func(bookmark string) (shim.StateQueryIteratorInterface, string, error), pageSize int32) (*Checksum, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be positive, got %d", pageSize)
+	}
+	h := &hasher{}
+	bookmark := ""
+	for {
+		iter, next, err := page(bookmark)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read keys: %s", err)
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return nil, fmt.Errorf("failed to read keys: %s", err)
+			}
+			h.add(kv.Key, kv.Value)
+		}
+		iter.Close()
+		if next == "" {
+			return h.checksum(), nil
+		}
+		bookmark = next
+	}
+}
+
+// Attest records checksum as the attestation of the organization of the
+// client for name, replacing its previous attestation.
+func Attest(stub shim.ChaincodeStubInterface, name string, checksum *Checksum) error {
+	if name == "" {
+		return errors.New("name must not be an empty string")
+	}
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return fmt.Errorf("failed to get invoker identity: %s", err)
+	}
+	sid := &msp.SerializedIdentity{}
+	if err := proto.Unmarshal(creator, sid); err != nil {
+		return fmt.Errorf("failed to unmarshal invoker identity: %s", err)
+	}
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return fmt.Errorf("failed to get transaction timestamp: %s", err)
+	}
+	timestamp, err := ptypes.Timestamp(ts)
+	if err != nil {
+		return fmt.Errorf("invalid transaction timestamp: %s", err)
+	}
+
+	attestation := &Attestation{
+		Checksum:  *checksum,
+		MSPID:     sid.GetMspid(),
+		TxID:      stub.GetTxID(),
+		Timestamp: timestamp.UTC(),
+	}
+	key, err := stub.CreateCompositeKey(objectType, []string{name, attestation.MSPID})
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(attestation)
+	if err != nil {
+		return fmt.Errorf("failed to marshal attestation: %s", err)
+	}
+	if err := stub.PutState(key, b); err != nil {
+		return fmt.Errorf("failed to write attestation of %s: %s", name, err)
+	}
+	return nil
+}
+
+// Compare returns the attestations recorded for name, in the order of the
+// MSP IDs of the organizations, and whether they all have the same
+// checksum.
+func Compare(stub shim.ChaincodeStubInterface, name string) ([]*Attestation, bool, error) {
+	iter, err := stub.GetStateByPartialCompositeKey(objectType, []string{name})
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read attestations of %s: %s", name, err)
+	}
+	defer iter.Close()
+
+	var attestations []*Attestation
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read attestations of %s: %s", name, err)
+		}
+		a := &Attestation{}
+		if err := json.Unmarshal(kv.Value, a); err != nil {
+			return nil, false, fmt.Errorf("failed to unmarshal attestation of %s: %s", name, err)
+		}
+		attestations = append(attestations, a)
+	}
+	sort.Slice(attestations, func(i, j int) bool {
+		return attestations[i].MSPID < attestations[j].MSPID
+	})
+
+	agree := true
+	for _, a := range attestations {
+		agree = agree && a.Checksum == attestations[0].Checksum
+	}
+	return attestations, agree, nil
+}
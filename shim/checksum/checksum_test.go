@@ -0,0 +1,103 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package checksum_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim/checksum"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStub(t *testing.T, keys int) *shimtest.MockStub {
+	stub := shimtest.NewMockStub("checksum", nil)
+	stub.MockTransactionStart("init")
+	for i := 0; i < keys; i++ {
+		require.NoError(t, stub.PutState(fmt.Sprintf("asset%02d", i), []byte(fmt.Sprintf("value%d", i))))
+		key, err := stub.CreateCompositeKey("owner", []string{"alice", fmt.Sprint(i)})
+		require.NoError(t, err)
+		require.NoError(t, stub.PutState(key, []byte{byte(i)}))
+	}
+	stub.MockTransactionEnd("init")
+	return stub
+}
+
+func TestScan(t *testing.T) {
+	stub := newStub(t, 10)
+	sum, err := checksum.Scan(stub, "asset", "b", 3)
+	require.NoError(t, err)
+	assert.Equal(t, 10, sum.Keys)
+	assert.Len(t, sum.Root, 64)
+
+	// the page size does not change the checksum
+	other, err := checksum.Scan(stub, "asset", "b", 100)
+	require.NoError(t, err)
+	assert.Equal(t, sum, other)
+
+	// the same state has the same checksum on another peer
+	other, err = checksum.Scan(newStub(t, 10), "asset", "b", 4)
+	require.NoError(t, err)
+	assert.Equal(t, sum, other)
+
+	partial, err := checksum.Scan(stub, "asset02", "asset05", 2)
+	require.NoError(t, err)
+	assert.Equal(t, 3, partial.Keys)
+
+	stub.State["asset03"] = []byte("tampered")
+	tampered, err := checksum.Scan(stub, "asset", "b", 3)
+	require.NoError(t, err)
+	assert.NotEqual(t, sum.Root, tampered.Root)
+
+	composite, err := checksum.ScanCompositeKeys(stub, "owner", []string{"alice"}, 3)
+	require.NoError(t, err)
+	assert.Equal(t, 10, composite.Keys)
+
+	_, err = checksum.Scan(stub, "asset", "b", 0)
+	assert.EqualError(t, err, "page size must be positive, got 0")
+}
+
+func attest(t *testing.T, stub *shimtest.MockStub, txID, mspID string, sum *checksum.Checksum) {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID})
+	require.NoError(t, err)
+	stub.Creator = creator
+	stub.MockTransactionStart(txID)
+	stub.TxTimestamp = &timestamp.Timestamp{Seconds: 1}
+	require.NoError(t, checksum.Attest(stub, "assets", sum))
+	stub.MockTransactionEnd(txID)
+}
+
+func TestCompare(t *testing.T) {
+	stub := newStub(t, 5)
+	sum, err := checksum.Scan(stub, "asset", "b", 10)
+	require.NoError(t, err)
+
+	attest(t, stub, "tx1", "Org2MSP", sum)
+	attest(t, stub, "tx2", "Org1MSP", sum)
+	attestations, agree, err := checksum.Compare(stub, "assets")
+	require.NoError(t, err)
+	assert.True(t, agree)
+	require.Len(t, attestations, 2)
+	assert.Equal(t, "Org1MSP", attestations[0].MSPID)
+	assert.Equal(t, "tx2", attestations[0].TxID)
+	assert.Equal(t, int64(1), attestations[0].Timestamp.Unix())
+	assert.Equal(t, *sum, attestations[1].Checksum)
+
+	attest(t, stub, "tx3", "Org3MSP", &checksum.Checksum{Root: "other", Keys: 5})
+	attestations, agree, err = checksum.Compare(stub, "assets")
+	require.NoError(t, err)
+	assert.False(t, agree)
+	assert.Len(t, attestations, 3)
+
+	// a new attestation replaces the previous one of the organization
+	attest(t, stub, "tx4", "Org3MSP", sum)
+	_, agree, err = checksum.Compare(stub, "assets")
+	require.NoError(t, err)
+	assert.True(t, agree)
+}
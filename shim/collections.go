@@ -0,0 +1,72 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/common"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+)
+
+const (
+	lifecycleName                  = "_lifecycle"
+	lifecycleQueryChaincodeDefFunc = "QueryChaincodeDefinition"
+	lsccName                       = "lscc"
+	lsccGetCollectionsConfigFunc   = "GetCollectionsConfig"
+)
+
+// GetCollectionsConfig documentation can be found in interfaces.go
+func (s *ChaincodeStub) GetCollectionsConfig() ([]*common.StaticCollectionConfig, error) {
+	if s.collectionsConfig != nil {
+		return s.collectionsConfig, nil
+	}
+	if s.chaincodeName == "" {
+		return nil, errors.New("chaincode name not available from the proposal")
+	}
+
+	pkg, err := s.queryCollectionsConfig()
+	if err != nil {
+		return nil, err
+	}
+	configs := []*common.StaticCollectionConfig{}
+	for _, c := range pkg.GetConfig() {
+		if static := c.GetStaticCollectionConfig(); static != nil {
+			configs = append(configs, static)
+		}
+	}
+	s.collectionsConfig = configs
+	return configs, nil
+}
+
+// queryCollectionsConfig queries the collections of the chaincode from
+// _lifecycle and, if that fails, from lscc, which manages chaincodes
+// deployed with the legacy lifecycle.
+func (s *ChaincodeStub) queryCollectionsConfig() (*common.CollectionConfigPackage, error) {
+	args, err := proto.Marshal(&lifecycle.QueryChaincodeDefinitionArgs{Name: s.chaincodeName})
+	if err != nil {
+		return nil, err
+	}
+	resp := s.InvokeChaincode(lifecycleName, [][]byte{[]byte(lifecycleQueryChaincodeDefFunc), args}, "")
+	if resp.Status == OK {
+		result := &lifecycle.QueryChaincodeDefinitionResult{}
+		if err := proto.Unmarshal(resp.Payload, result); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal chaincode definition of %s: %s", s.chaincodeName, err)
+		}
+		return result.GetCollections(), nil
+	}
+	lifecycleErr := resp.Message
+
+	resp = s.InvokeChaincode(lsccName, [][]byte{[]byte(lsccGetCollectionsConfigFunc), []byte(s.chaincodeName)}, "")
+	if resp.Status != OK {
+		return nil, fmt.Errorf("failed to query collections config of %s: %s: %s; %s: %s", s.chaincodeName, lifecycleName, lifecycleErr, lsccName, resp.Message)
+	}
+	pkg := &common.CollectionConfigPackage{}
+	if err := proto.Unmarshal(resp.Payload, pkg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal collections config of %s: %s", s.chaincodeName, err)
+	}
+	return pkg, nil
+}
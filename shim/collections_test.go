@@ -0,0 +1,125 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	"github.com/hyperledger/fabric-protos-go/common"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSystemChaincodeStub returns a stub for a proposal invoking chaincode
+// name, whose handler answers chaincode invocations with responses.
+func newSystemChaincodeStub(t *testing.T, name string, responses map[string]peerpb.Response) (*ChaincodeStub, *[]string) {
+	handler := &Handler{
+		cc:     &mockChaincode{},
+		router: NewResponseRouter(),
+		state:  ready,
+	}
+	var invoked []string
+	chatStream := &mock.PeerChaincodeStream{}
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		spec := &peerpb.ChaincodeSpec{}
+		require.NoError(t, proto.Unmarshal(msg.Payload, spec))
+		invoked = append(invoked, spec.ChaincodeId.Name+" "+string(spec.Input.Args[0]))
+		resp := responses[spec.ChaincodeId.Name]
+		go handler.handleResponse(&peerpb.ChaincodeMessage{
+			Type:      peerpb.ChaincodeMessage_RESPONSE,
+			ChannelId: msg.GetChannelId(),
+			Txid:      msg.GetTxid(),
+			Payload: marshalOrPanic(&peerpb.ChaincodeMessage{
+				Type:    peerpb.ChaincodeMessage_COMPLETED,
+				Payload: marshalOrPanic(&resp),
+			}),
+		})
+		return nil
+	}
+	handler.chatStream = chatStream
+
+	signedProposal := &peerpb.SignedProposal{
+		ProposalBytes: marshalOrPanic(&peerpb.Proposal{
+			Header: marshalOrPanic(&common.Header{
+				ChannelHeader: marshalOrPanic(&common.ChannelHeader{
+					Type: int32(common.HeaderType_ENDORSER_TRANSACTION),
+					Extension: marshalOrPanic(&peerpb.ChaincodeHeaderExtension{
+						ChaincodeId: &peerpb.ChaincodeID{Name: name},
+					}),
+				}),
+				SignatureHeader: marshalOrPanic(&common.SignatureHeader{}),
+			}),
+			Payload: marshalOrPanic(&peerpb.ChaincodeProposalPayload{}),
+		}),
+	}
+	stub, err := newChaincodeStub(handler, "channel", "txid", &peerpb.ChaincodeInput{}, signedProposal)
+	require.NoError(t, err)
+	return stub, &invoked
+}
+
+func TestGetCollectionsConfig(t *testing.T) {
+	collection := &common.StaticCollectionConfig{Name: "col", RequiredPeerCount: 1, MaximumPeerCount: 3}
+	pkg := &common.CollectionConfigPackage{
+		Config: []*common.CollectionConfig{
+			{Payload: &common.CollectionConfig_StaticCollectionConfig{StaticCollectionConfig: collection}},
+		},
+	}
+	failed := peerpb.Response{Status: ERROR, Message: "not found"}
+
+	t.Run("Lifecycle", func(t *testing.T) {
+		stub, invoked := newSystemChaincodeStub(t, "mycc", map[string]peerpb.Response{
+			"_lifecycle": {Status: OK, Payload: marshalOrPanic(&lifecycle.QueryChaincodeDefinitionResult{Collections: pkg})},
+		})
+		configs, err := stub.GetCollectionsConfig()
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+		assert.True(t, proto.Equal(collection, configs[0]))
+
+		// cached for the rest of the transaction
+		_, err = stub.GetCollectionsConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"_lifecycle QueryChaincodeDefinition"}, *invoked)
+	})
+
+	t.Run("LegacyLifecycle", func(t *testing.T) {
+		stub, invoked := newSystemChaincodeStub(t, "mycc", map[string]peerpb.Response{
+			"_lifecycle": failed,
+			"lscc":       {Status: OK, Payload: marshalOrPanic(pkg)},
+		})
+		configs, err := stub.GetCollectionsConfig()
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+		assert.Equal(t, "col", configs[0].Name)
+		assert.Equal(t, []string{"_lifecycle QueryChaincodeDefinition", "lscc GetCollectionsConfig"}, *invoked)
+	})
+
+	t.Run("NoCollections", func(t *testing.T) {
+		stub, _ := newSystemChaincodeStub(t, "mycc", map[string]peerpb.Response{
+			"_lifecycle": {Status: OK, Payload: marshalOrPanic(&lifecycle.QueryChaincodeDefinitionResult{})},
+		})
+		configs, err := stub.GetCollectionsConfig()
+		assert.NoError(t, err)
+		assert.Empty(t, configs)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		stub, _ := newSystemChaincodeStub(t, "mycc", map[string]peerpb.Response{
+			"_lifecycle": failed,
+			"lscc":       failed,
+		})
+		_, err := stub.GetCollectionsConfig()
+		assert.EqualError(t, err, "failed to query collections config of mycc: _lifecycle: not found; lscc: not found")
+	})
+
+	t.Run("NoChaincodeName", func(t *testing.T) {
+		stub, err := newChaincodeStub(&Handler{}, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+		require.NoError(t, err)
+		_, err = stub.GetCollectionsConfig()
+		assert.EqualError(t, err, "chaincode name not available from the proposal")
+	})
+}
@@ -0,0 +1,108 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// connectionSubscriptionBuffer is the number of state transitions buffered
+// for each subscriber. Transitions are dropped for subscribers that fall
+// further behind.
+const connectionSubscriptionBuffer = 16
+
+// ConnectionState returns the state of the gRPC connection to the peer of
+// the stream opened by Start: connectivity.Ready while the connection is
+// up and connectivity.TransientFailure while it is failing, for example.
+// It is connectivity.Idle until Start dials the peer and
+// connectivity.Shutdown once the connection is closed.
+func ConnectionState() connectivity.State {
+	return peerConnection.state()
+}
+
+// SubscribeConnectionState returns a channel receiving the state of the
+// connection to the peer whenever it changes, and a function to call to
+// end the subscription, which closes the channel. A subscriber not
+// receiving the transitions quickly enough misses some of them; the
+// current state is always available from ConnectionState.
+func SubscribeConnectionState() (<-chan connectivity.State, func()) {
+	return peerConnection.subscribe()
+}
+
+// peerConnection tracks the connection of the stream opened by Start.
+var peerConnection = &connectionTracker{}
+
+// connectionTracker tracks the state of the current connection to the
+// peer and reports its transitions to subscribers.
+type connectionTracker struct {
+	mutex       sync.Mutex
+	conn        *grpc.ClientConn
+	current     connectivity.State
+	subscribers map[chan connectivity.State]struct{}
+}
+
+func (t *connectionTracker) state() connectivity.State {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.current
+}
+
+func (t *connectionTracker) subscribe() (<-chan connectivity.State, func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.subscribers == nil {
+		t.subscribers = map[chan connectivity.State]struct{}{}
+	}
+	c := make(chan connectivity.State, connectionSubscriptionBuffer)
+	t.subscribers[c] = struct{}{}
+	var once sync.Once
+	return c, func() {
+		once.Do(func() {
+			t.mutex.Lock()
+			defer t.mutex.Unlock()
+			delete(t.subscribers, c)
+			close(c)
+		})
+	}
+}
+
+// track makes conn the current connection and watches its state until it
+// is shut down.
+func (t *connectionTracker) track(conn *grpc.ClientConn) {
+	t.mutex.Lock()
+	t.conn = conn
+	t.mutex.Unlock()
+
+	state := conn.GetState()
+	t.update(conn, state)
+	go func() {
+		for state != connectivity.Shutdown && conn.WaitForStateChange(context.Background(), state) {
+			state = conn.GetState()
+			t.update(conn, state)
+		}
+	}()
+}
+
+// update records the state of conn, unless it was replaced by another
+// connection.
+func (t *connectionTracker) update(conn *grpc.ClientConn, state connectivity.State) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.conn != conn || t.current == state {
+		return
+	}
+	t.current = state
+	for c := range t.subscribers {
+		select {
+		case c <- state:
+		default:
+		}
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+// waitForState receives transitions from c until state.
+func waitForState(t *testing.T, c <-chan connectivity.State, state connectivity.State) {
+	for {
+		select {
+		case s := <-c:
+			if s == state {
+				return
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for state %s", state)
+		}
+	}
+}
+
+func TestConnectionTracker(t *testing.T) {
+	tracker := &connectionTracker{}
+	assert.Equal(t, connectivity.Idle, tracker.state())
+
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer lis.Close()
+	server := grpc.NewServer()
+	go server.Serve(lis)
+	defer server.Stop()
+
+	transitions, cancel := tracker.subscribe()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.NoError(t, err)
+	tracker.track(conn)
+	waitForState(t, transitions, connectivity.Ready)
+	assert.Equal(t, connectivity.Ready, tracker.state())
+
+	server.Stop()
+	waitForState(t, transitions, connectivity.TransientFailure)
+
+	// the transitions of a replaced connection are ignored
+	replacement, err := grpc.Dial("127.0.0.1:0", grpc.WithInsecure())
+	require.NoError(t, err)
+	defer replacement.Close()
+	tracker.track(replacement)
+	conn.Close()
+	time.Sleep(10 * time.Millisecond)
+	assert.NotEqual(t, connectivity.Shutdown, tracker.state())
+
+	replacement.Close()
+	waitForState(t, transitions, connectivity.Shutdown)
+	assert.Equal(t, connectivity.Shutdown, tracker.state())
+
+	cancel()
+	cancel()
+	_, ok := <-transitions
+	for ok {
+		_, ok = <-transitions
+	}
+}
+
+func TestConnectionState(t *testing.T) {
+	transitions, cancel := SubscribeConnectionState()
+	defer cancel()
+	assert.NotNil(t, transitions)
+	assert.Equal(t, peerConnection.state(), ConnectionState())
+}
@@ -0,0 +1,311 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package contract hosts contracts written in the style of the contract API
+// (github.com/hyperledger/fabric-contract-api-go/contractapi) in a Chaincode
+// of this shim, and Chaincodes of this shim in contracts, so that large
+// codebases can migrate from one to the other incrementally.
+//
+// The contract API is not imported: TransactionContextInterface has the
+// method set of contractapi.TransactionContextInterface, and
+// TransactionContext the method set of contractapi.TransactionContext, so
+// values of one are values of the other. A contract written against the
+// contract API:
+//
+//	type AssetContract struct {
+//		contractapi.Contract
+//	}
+//
+//	func (c *AssetContract) Create(ctx contractapi.TransactionContextInterface, id string, value int) error {
+//		return ctx.GetStub().PutState(id, []byte(strconv.Itoa(value)))
+//	}
+//
+// is hosted by this shim with
+//
+//	cc, err := contract.NewChaincode(&AssetContract{})
+//	...
+//	err = shim.Start(cc)
+//
+// and invoked with the function name "Create" or "AssetContract:Create".
+package contract
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// TransactionContextInterface is the transaction context passed to the
+// functions of contracts.
+type TransactionContextInterface interface {
+	// GetStub returns the stub of the transaction.
+	GetStub() shim.ChaincodeStubInterface
+	// GetClientIdentity returns the identity of the client submitting the
+	// transaction.
+	GetClientIdentity() cid.ClientIdentity
+}
+
+// TransactionContext is the implementation of TransactionContextInterface
+// used by NewChaincode.
+type TransactionContext struct {
+	stub           shim.ChaincodeStubInterface
+	clientIdentity cid.ClientIdentity
+}
+
+// SetStub sets the stub of the transaction.
+func (ctx *TransactionContext) SetStub(stub shim.ChaincodeStubInterface) {
+	ctx.stub = stub
+}
+
+// SetClientIdentity sets the identity of the client.
+func (ctx *TransactionContext) SetClientIdentity(ci cid.ClientIdentity) {
+	ctx.clientIdentity = ci
+}
+
+// GetStub returns the stub of the transaction.
+func (ctx *TransactionContext) GetStub() shim.ChaincodeStubInterface {
+	return ctx.stub
+}
+
+// GetClientIdentity returns the identity of the client. Unless it was set
+// with SetClientIdentity, it is read from the creator of the transaction
+// on first use; it is nil if the creator is not a valid identity.
+func (ctx *TransactionContext) GetClientIdentity() cid.ClientIdentity {
+	if ctx.clientIdentity == nil && ctx.stub != nil {
+		ctx.clientIdentity, _ = cid.New(ctx.stub)
+	}
+	return ctx.clientIdentity
+}
+
+var (
+	contextType = reflect.TypeOf((*TransactionContext)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Chaincode is a shim.Chaincode calling the functions of contracts.
+type Chaincode struct {
+	contracts map[string]*hostedContract
+	// defaultContract is the name of the contract of functions invoked
+	// without a contract name.
+	defaultContract string
+}
+
+type hostedContract struct {
+	name      string
+	functions map[string]reflect.Value
+}
+
+// NewChaincode returns a Chaincode hosting contracts. The functions of a
+// contract are its exported methods taking a transaction context as their
+// first parameter. They are invoked with the function name
+// "contract:function", where the name of a contract is the result of its
+// GetName method if it has one and the name of its type otherwise; the
+// contract name may be left out for the first contract.
+//
+// The arguments of the transaction are converted to the remaining
+// parameters of the function: strings are passed as they are, booleans and
+// numbers are parsed and other types are decoded from JSON. A function may
+// return nothing, an error, a value or a value and an error. Values are
+// returned as the payload of the response: strings and byte slices as they
+// are, booleans and numbers formatted and other types encoded as JSON.
+func NewChaincode(contracts ...interface{}) (*Chaincode, error) {
+	if len(contracts) == 0 {
+		return nil, errors.New("no contracts to host")
+	}
+	cc := &Chaincode{contracts: map[string]*hostedContract{}}
+	for i, c := range contracts {
+		hosted, err := newHostedContract(c)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := cc.contracts[hosted.name]; ok {
+			return nil, fmt.Errorf("multiple contracts named %s", hosted.name)
+		}
+		cc.contracts[hosted.name] = hosted
+		if i == 0 {
+			cc.defaultContract = hosted.name
+		}
+	}
+	return cc, nil
+}
+
+func newHostedContract(c interface{}) (*hostedContract, error) {
+	v := reflect.ValueOf(c)
+	t := v.Type()
+	name := t.Name()
+	if t.Kind() == reflect.Ptr {
+		name = t.Elem().Name()
+	}
+	if named, ok := c.(interface{ GetName() string }); ok && named.GetName() != "" {
+		name = named.GetName()
+	}
+
+	hosted := &hostedContract{name: name, functions: map[string]reflect.Value{}}
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		mt := method.Type
+		if mt.NumIn() < 2 || !contextType.AssignableTo(mt.In(1)) {
+			continue
+		}
+		if err := checkResults(mt); err != nil {
+			return nil, fmt.Errorf("function %s of contract %s %s", method.Name, name, err)
+		}
+		hosted.functions[method.Name] = v.Method(i)
+	}
+	if len(hosted.functions) == 0 {
+		return nil, fmt.Errorf("contract %s has no functions taking a transaction context", name)
+	}
+	return hosted, nil
+}
+
+// checkResults returns an error if the results of the method type mt are
+// not supported.
+func checkResults(mt reflect.Type) error {
+	switch mt.NumOut() {
+	case 0, 1:
+		return nil
+	case 2:
+		if mt.Out(1) != errorType {
+			return errors.New("must return an error as its second result")
+		}
+		return nil
+	default:
+		return errors.New("must return at most two results")
+	}
+}
+
+// Init calls the function named by the arguments of the transaction, if
+// any.
+func (cc *Chaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	if len(stub.GetArgs()) == 0 {
+		return shim.Success(nil)
+	}
+	return cc.Invoke(stub)
+}
+
+// Invoke calls the function named by the arguments of the transaction.
+func (cc *Chaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	name, args := stub.GetFunctionAndParameters()
+	contractName, function := cc.defaultContract, name
+	if i := strings.LastIndex(name, ":"); i >= 0 {
+		contractName, function = name[:i], name[i+1:]
+	}
+	hosted, ok := cc.contracts[contractName]
+	if !ok {
+		return shim.Error(fmt.Sprintf("Contract not found with name %s", contractName))
+	}
+	fn, ok := hosted.functions[function]
+	if !ok {
+		return shim.Error(fmt.Sprintf("Function %s not found in contract %s", function, contractName))
+	}
+
+	ctx := &TransactionContext{}
+	ctx.SetStub(stub)
+	payload, err := call(fn, ctx, args)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(payload)
+}
+
+// call calls fn with ctx and args and returns the encoded result.
+func call(fn reflect.Value, ctx *TransactionContext, args []string) ([]byte, error) {
+	ft := fn.Type()
+	if len(args) != ft.NumIn()-1 {
+		return nil, fmt.Errorf("incorrect number of params. Expected %d, received %d", ft.NumIn()-1, len(args))
+	}
+	in := []reflect.Value{reflect.ValueOf(ctx)}
+	for i, arg := range args {
+		v, err := parseArg(arg, ft.In(i+1))
+		if err != nil {
+			return nil, fmt.Errorf("error managing parameter %d: %s", i, err)
+		}
+		in = append(in, v)
+	}
+
+	out := fn.Call(in)
+	if len(out) > 0 && out[len(out)-1].Type() == errorType {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return nil, err
+		}
+		out = out[:len(out)-1]
+	}
+	if len(out) == 0 {
+		return nil, nil
+	}
+	return formatResult(out[0])
+}
+
+// parseArg converts arg to a value of type t.
+func parseArg(arg string, t reflect.Type) (reflect.Value, error) {
+	v := reflect.New(t).Elem()
+	var err error
+	switch t.Kind() {
+	case reflect.String:
+		v.SetString(arg)
+	case reflect.Bool:
+		var b bool
+		b, err = strconv.ParseBool(arg)
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var n int64
+		n, err = strconv.ParseInt(arg, 10, t.Bits())
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var n uint64
+		n, err = strconv.ParseUint(arg, 10, t.Bits())
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		f, err = strconv.ParseFloat(arg, t.Bits())
+		v.SetFloat(f)
+	default:
+		err = json.Unmarshal([]byte(arg), v.Addr().Interface())
+	}
+	if err != nil {
+		return reflect.Value{}, fmt.Errorf("value %s was not passed in expected format %s", arg, t)
+	}
+	return v, nil
+}
+
+// formatResult returns the encoding of v.
+func formatResult(v reflect.Value) ([]byte, error) {
+	switch v.Kind() {
+	case reflect.String:
+		return []byte(v.String()), nil
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return []byte(fmt.Sprint(v.Interface())), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return v.Bytes(), nil
+		}
+	case reflect.Ptr, reflect.Interface, reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+	payload, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %s", err)
+	}
+	return payload, nil
+}
+
+// Invoke calls cc with the stub of ctx, so that a contract can host a
+// Chaincode of this shim. The payload of a successful response is returned
+// as the result; the message of an error response as the error.
+func Invoke(ctx TransactionContextInterface, cc shim.Chaincode) ([]byte, error) {
+	res := cc.Invoke(ctx.GetStub())
+	if res.Status >= shim.ERRORTHRESHOLD {
+		return nil, errors.New(res.Message)
+	}
+	return res.Payload, nil
+}
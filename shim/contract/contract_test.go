@@ -0,0 +1,185 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package contract_test
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/contract"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type asset struct {
+	ID    string `json:"id"`
+	Value int    `json:"value"`
+}
+
+type assetContract struct{}
+
+func (c *assetContract) Create(ctx contract.TransactionContextInterface, id string, value int) error {
+	if value < 0 {
+		return errors.New("value must not be negative")
+	}
+	return ctx.GetStub().PutState(id, []byte(strconv.Itoa(value)))
+}
+
+func (c *assetContract) Read(ctx contract.TransactionContextInterface, id string) (*asset, error) {
+	data, err := ctx.GetStub().GetState(id)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	value, err := strconv.Atoi(string(data))
+	if err != nil {
+		return nil, err
+	}
+	return &asset{ID: id, Value: value}, nil
+}
+
+func (c *assetContract) Exists(ctx *contract.TransactionContext, id string) (bool, error) {
+	data, err := ctx.GetStub().GetState(id)
+	return data != nil, err
+}
+
+func (c *assetContract) Import(ctx contract.TransactionContextInterface, a asset) error {
+	return c.Create(ctx, a.ID, a.Value)
+}
+
+func (c *assetContract) helper(id string) string { return id }
+
+type namedContract struct{}
+
+func (namedContract) GetName() string { return "org.example.Named" }
+
+func (namedContract) Hello(ctx contract.TransactionContextInterface, name string) string {
+	return "hello " + name
+}
+
+func TestNewChaincode(t *testing.T) {
+	cc, err := contract.NewChaincode(&assetContract{}, namedContract{})
+	require.NoError(t, err)
+	stub := shimtest.NewMockStub("contract", cc)
+
+	invoke := func(args ...string) pb.Response {
+		var bargs [][]byte
+		for _, arg := range args {
+			bargs = append(bargs, []byte(arg))
+		}
+		return stub.MockInvoke("tx1", bargs)
+	}
+
+	res := invoke("Create", "asset1", "10")
+	assert.Equal(t, int32(shim.OK), res.Status, res.Message)
+
+	res = invoke("assetContract:Read", "asset1")
+	assert.Equal(t, int32(shim.OK), res.Status, res.Message)
+	assert.JSONEq(t, `{"id":"asset1","value":10}`, string(res.Payload))
+
+	res = invoke("Read", "missing")
+	assert.Equal(t, int32(shim.OK), res.Status, res.Message)
+	assert.Nil(t, res.Payload)
+
+	res = invoke("Exists", "asset1")
+	assert.Equal(t, "true", string(res.Payload))
+
+	res = invoke("Import", `{"id":"asset2","value":3}`)
+	assert.Equal(t, int32(shim.OK), res.Status, res.Message)
+	assert.Equal(t, []byte("3"), stub.State["asset2"])
+
+	res = invoke("org.example.Named:Hello", "world")
+	assert.Equal(t, "hello world", string(res.Payload))
+
+	var tests = []struct {
+		args   []string
+		errMsg string
+	}{
+		{args: []string{"Create", "asset3", "-1"}, errMsg: "value must not be negative"},
+		{args: []string{"Create", "asset3", "ten"}, errMsg: "error managing parameter 1: value ten was not passed in expected format int"},
+		{args: []string{"Create", "asset3"}, errMsg: "incorrect number of params. Expected 2, received 1"},
+		{args: []string{"Import", "{"}, errMsg: "error managing parameter 0: value { was not passed in expected format contract_test.asset"},
+		{args: []string{"helper", "asset1"}, errMsg: "Function helper not found in contract assetContract"},
+		{args: []string{"Missing:Hello", "world"}, errMsg: "Contract not found with name Missing"},
+	}
+	for _, test := range tests {
+		res := invoke(test.args...)
+		assert.Equal(t, int32(shim.ERROR), res.Status)
+		assert.Equal(t, test.errMsg, res.Message)
+	}
+
+	res = stub.MockInit("tx1", nil)
+	assert.Equal(t, int32(shim.OK), res.Status)
+}
+
+type badResults struct{}
+
+func (badResults) Fn(ctx contract.TransactionContextInterface) (string, string) { return "", "" }
+
+type noFunctions struct{}
+
+func (noFunctions) Fn(id string) {}
+
+func TestNewChaincodeErrors(t *testing.T) {
+	_, err := contract.NewChaincode()
+	assert.EqualError(t, err, "no contracts to host")
+
+	_, err = contract.NewChaincode(badResults{})
+	assert.EqualError(t, err, "function Fn of contract badResults must return an error as its second result")
+
+	_, err = contract.NewChaincode(noFunctions{})
+	assert.EqualError(t, err, "contract noFunctions has no functions taking a transaction context")
+
+	_, err = contract.NewChaincode(&assetContract{}, &assetContract{})
+	assert.EqualError(t, err, "multiple contracts named assetContract")
+}
+
+type legacyChaincode struct{}
+
+func (legacyChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response { return shim.Success(nil) }
+
+func (legacyChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	if fn, _ := stub.GetFunctionAndParameters(); fn != "Ping" {
+		return shim.Error("unknown function " + fn)
+	}
+	return shim.Success([]byte("pong"))
+}
+
+// migratingContract forwards to the chaincode it replaces.
+type migratingContract struct{}
+
+func (migratingContract) Ping(ctx contract.TransactionContextInterface) ([]byte, error) {
+	return contract.Invoke(ctx, legacyChaincode{})
+}
+
+func (migratingContract) Pong(ctx contract.TransactionContextInterface) ([]byte, error) {
+	return contract.Invoke(ctx, legacyChaincode{})
+}
+
+func TestInvoke(t *testing.T) {
+	cc, err := contract.NewChaincode(migratingContract{})
+	require.NoError(t, err)
+	stub := shimtest.NewMockStub("migrating", cc)
+
+	res := stub.MockInvoke("tx1", [][]byte{[]byte("Ping")})
+	assert.Equal(t, int32(shim.OK), res.Status, res.Message)
+	assert.Equal(t, "pong", string(res.Payload))
+
+	res = stub.MockInvoke("tx2", [][]byte{[]byte("Pong")})
+	assert.Equal(t, int32(shim.ERROR), res.Status)
+	assert.Equal(t, "unknown function Pong", res.Message)
+}
+
+func TestTransactionContext(t *testing.T) {
+	ctx := &contract.TransactionContext{}
+	assert.Nil(t, ctx.GetClientIdentity())
+
+	stub := shimtest.NewMockStub("context", nil)
+	ctx.SetStub(stub)
+	assert.Equal(t, stub, ctx.GetStub())
+	assert.Nil(t, ctx.GetClientIdentity(), "the stub has no valid creator")
+}
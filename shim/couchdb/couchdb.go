@@ -0,0 +1,131 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package couchdb checks that keys and values can be stored by a peer using
+// CouchDB as its state database. A value that is a JSON object is stored as
+// a CouchDB document whose ID is the key; the peer rejects such values when
+// they use the fields CouchDB reserves, which fails the transaction only
+// when it is committed.
+//
+//	if err := couchdb.CheckDocument(key, value); err != nil {
+//		return shim.Error(err.Error())
+//	}
+package couchdb
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// VersionField is the top-level field the peer uses to store the version
+// of a document, which documents must not contain.
+const VersionField = "~version"
+
+// IsReservedField reports whether a top-level field named name is rejected
+// by the peer: the fields starting with an underscore are reserved by
+// CouchDB, and VersionField by the peer itself.
+func IsReservedField(name string) bool {
+	return strings.HasPrefix(name, "_") || name == VersionField
+}
+
+// CheckDocument returns an error if key is not a valid document ID or if
+// value is a JSON object with reserved top-level fields. Values that are not
+// JSON objects are stored as attachments and only key is checked.
+func CheckDocument(key string, value []byte) error {
+	if err := shim.CheckKey(key); err != nil {
+		return err
+	}
+	if err := shim.CheckValue(value); err != nil {
+		return err
+	}
+	fields, ok := objectFields(value)
+	if !ok {
+		return nil
+	}
+	var reserved []string
+	for name := range fields {
+		if IsReservedField(name) {
+			reserved = append(reserved, name)
+		}
+	}
+	if len(reserved) > 0 {
+		sort.Strings(reserved)
+		return fmt.Errorf("document [%s] has fields reserved by the CouchDB state database: %s", key, strings.Join(reserved, ", "))
+	}
+	return nil
+}
+
+// RenameFunc returns the new name of a reserved field, or an empty string
+// to drop the field.
+type RenameFunc func(name string) string
+
+// PrefixReserved returns a RenameFunc adding prefix to the reserved fields,
+// turning "_id" into "x_id" for prefix "x".
+func PrefixReserved(prefix string) RenameFunc {
+	return func(name string) string {
+		return prefix + name
+	}
+}
+
+// DropReserved is a RenameFunc dropping the reserved fields.
+func DropReserved(string) string {
+	return ""
+}
+
+// Sanitize returns value with its reserved top-level fields renamed by
+// rename. Values that are not JSON objects, or without reserved fields, are
+// returned unchanged. Otherwise the object is encoded again with its fields
+// sorted. An error is returned if a new name is itself reserved or is
+// already used by another field.
+func Sanitize(value []byte, rename RenameFunc) ([]byte, error) {
+	fields, ok := objectFields(value)
+	if !ok {
+		return value, nil
+	}
+	var reserved []string
+	for name := range fields {
+		if IsReservedField(name) {
+			reserved = append(reserved, name)
+		}
+	}
+	if len(reserved) == 0 {
+		return value, nil
+	}
+	sort.Strings(reserved)
+
+	for _, name := range reserved {
+		field := fields[name]
+		delete(fields, name)
+		newName := rename(name)
+		if newName == "" {
+			continue
+		}
+		if IsReservedField(newName) {
+			return nil, fmt.Errorf("field %s renamed to %s, which is reserved", name, newName)
+		}
+		if _, exists := fields[newName]; exists {
+			return nil, fmt.Errorf("field %s renamed to %s, which already exists", name, newName)
+		}
+		fields[newName] = field
+	}
+	return json.Marshal(fields)
+}
+
+// objectFields decodes the top-level fields of value, returning false if
+// value is not a JSON object.
+func objectFields(value []byte) (map[string]json.RawMessage, bool) {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return nil, false
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &fields); err != nil {
+		return nil, false
+	}
+	return fields, true
+}
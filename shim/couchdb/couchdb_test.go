@@ -0,0 +1,66 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package couchdb_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/couchdb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value string
+		err   string
+	}{
+		{name: "Valid", key: "asset1", value: `{"owner":"alice","nested":{"_id":"ok"}}`},
+		{name: "Not JSON", key: "asset1", value: "_id"},
+		{name: "JSON Array", key: "asset1", value: `[{"_id":"a"}]`},
+		{name: "Invalid ID", key: "_design/asset", value: `{"owner":"alice"}`, err: "key [_design/asset] must not start with an underscore"},
+		{name: "Reserved Fields", key: "asset1", value: `{"_rev":"1","owner":"alice","_id":"asset1"}`, err: "document [asset1] has fields reserved by the CouchDB state database: _id, _rev"},
+		{name: "Version Field", key: "asset1", value: `{"~version":"1"}`, err: "document [asset1] has fields reserved by the CouchDB state database: ~version"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := couchdb.CheckDocument(tt.key, []byte(tt.value))
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		rename   couchdb.RenameFunc
+		expected string
+		err      string
+	}{
+		{name: "Unchanged", value: `{"owner": "alice"}`, rename: couchdb.DropReserved, expected: `{"owner": "alice"}`},
+		{name: "Not An Object", value: `"_id"`, rename: couchdb.DropReserved, expected: `"_id"`},
+		{name: "Drop", value: `{"_id":"a","owner":"alice","~version":"1"}`, rename: couchdb.DropReserved, expected: `{"owner":"alice"}`},
+		{name: "Prefix", value: `{"_id":"a","owner":"alice"}`, rename: couchdb.PrefixReserved("x"), expected: `{"owner":"alice","x_id":"a"}`},
+		{name: "Still Reserved", value: `{"_id":"a"}`, rename: couchdb.PrefixReserved("_"), err: "field _id renamed to __id, which is reserved"},
+		{name: "Collision", value: `{"_id":"a","x_id":"b"}`, rename: couchdb.PrefixReserved("x"), err: "field _id renamed to x_id, which already exists"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sanitized, err := couchdb.Sanitize([]byte(tt.value), tt.rename)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expected, string(sanitized))
+			assert.NoError(t, couchdb.CheckDocument("key", sanitized))
+		})
+	}
+}
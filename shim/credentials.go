@@ -0,0 +1,165 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal"
+)
+
+// CredentialProvider provides the client TLS certificate the chaincode
+// presents to the peer, in place of the certificate and key configured by
+// the CORE_TLS_CLIENT_* environment variables. The root certificates of the
+// peer are still taken from the environment. Register it with Start using
+// WithCredentialProvider.
+type CredentialProvider interface {
+	// ClientCertificate returns the client certificate. It is called for
+	// every TLS handshake with the peer, so a provider can return a renewed
+	// certificate for the connections made after a renewal.
+	ClientCertificate() (*tls.Certificate, error)
+}
+
+// WithCredentialProvider obtains the client TLS certificate of the
+// connections to the peer from provider. TLS must be enabled.
+// WithCredentialProvider has no effect on StartInProc.
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(h *Handler) error {
+		if provider == nil {
+			return errors.New("credential provider must not be nil")
+		}
+		h.credentials = provider
+		return nil
+	}
+}
+
+// RefreshingCredentials returns a CredentialProvider caching the
+// certificate returned by load until margin before it expires, and loading
+// a new one from then on. If loading fails while the cached certificate is
+// still valid, the cached certificate is returned. This suits short-lived
+// certificates issued by services such as SPIFFE or Vault.
+func RefreshingCredentials(load func() (*tls.Certificate, error), margin time.Duration) CredentialProvider {
+	return &refreshingCredentials{load: load, margin: margin, now: time.Now}
+}
+
+type refreshingCredentials struct {
+	load   func() (*tls.Certificate, error)
+	margin time.Duration
+	now    func() time.Time
+
+	mutex    sync.Mutex
+	cert     *tls.Certificate
+	notAfter time.Time
+}
+
+func (r *refreshingCredentials) ClientCertificate() (*tls.Certificate, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := r.now()
+	if r.cert != nil && now.Before(r.notAfter.Add(-r.margin)) {
+		return r.cert, nil
+	}
+
+	cert, notAfter, err := r.loadCertificate()
+	if err != nil {
+		if r.cert != nil && now.Before(r.notAfter) {
+			return r.cert, nil
+		}
+		return nil, err
+	}
+	r.cert, r.notAfter = cert, notAfter
+	return cert, nil
+}
+
+// loadCertificate loads a certificate and returns it with its expiry.
+func (r *refreshingCredentials) loadCertificate() (*tls.Certificate, time.Time, error) {
+	cert, err := r.load()
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to load client certificate: %s", err)
+	}
+	if cert == nil || len(cert.Certificate) == 0 {
+		return nil, time.Time{}, errors.New("failed to load client certificate: no certificate returned")
+	}
+	leaf := cert.Leaf
+	if leaf == nil {
+		if leaf, err = x509.ParseCertificate(cert.Certificate[0]); err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to parse client certificate: %s", err)
+		}
+	}
+	return cert, leaf.NotAfter, nil
+}
+
+// WithClientKeySigner signs the TLS handshakes with the peer with signer,
+// in place of the private key configured by the CORE_TLS_CLIENT_KEY*
+// environment variables, which need not be set. The client certificate is
+// still taken from the CORE_TLS_CLIENT_CERT* environment variables, and
+// must hold the public key of signer. This keeps the private key in a
+// hardware security module, for example a crypto.Signer backed by a
+// PKCS#11 token, when keys must not be stored in the chaincode container.
+// TLS must be enabled. WithClientKeySigner has no effect on StartInProc.
+func WithClientKeySigner(signer crypto.Signer) Option {
+	return func(h *Handler) error {
+		if signer == nil {
+			return errors.New("client key signer must not be nil")
+		}
+		certPEM, err := internal.LoadClientCertificate()
+		if err != nil {
+			return err
+		}
+		provider, err := signerCredentials(certPEM, signer)
+		if err != nil {
+			return err
+		}
+		h.credentials = provider
+		return nil
+	}
+}
+
+// signerCredentials returns a CredentialProvider returning the PEM encoded
+// certificate chain certPEM with signer as its private key.
+func signerCredentials(certPEM []byte, signer crypto.Signer) (CredentialProvider, error) {
+	cert := &tls.Certificate{PrivateKey: signer}
+	for block, rest := pem.Decode(certPEM); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			cert.Certificate = append(cert.Certificate, block.Bytes)
+		}
+	}
+	if len(cert.Certificate) == 0 {
+		return nil, errors.New("failed to parse client certificate: no certificate found")
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse client certificate: %s", err)
+	}
+	certKey, err := x509.MarshalPKIXPublicKey(leaf.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key of client certificate: %s", err)
+	}
+	signerKey, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal public key of client key signer: %s", err)
+	}
+	if !bytes.Equal(certKey, signerKey) {
+		return nil, errors.New("client certificate does not match the public key of the client key signer")
+	}
+	cert.Leaf = leaf
+	return staticCredentials{cert: cert}, nil
+}
+
+type staticCredentials struct {
+	cert *tls.Certificate
+}
+
+func (s staticCredentials) ClientCertificate() (*tls.Certificate, error) {
+	return s.cert, nil
+}
@@ -0,0 +1,275 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DebugAddressEnv sets the address of the debug server started by Start,
+// for example "127.0.0.1:6060".
+const DebugAddressEnv = "CORE_CHAINCODE_DEBUG_ADDRESS"
+
+// WithDebugServer starts an HTTP server on address serving the runtime
+// profiles under /debug/pprof/ and the state of the handlers under
+// /debug/shim/state. The host of address must be a loopback address so the
+// server is only reachable from within the container.
+//
+// The profiles are served from runtime/pprof: /debug/pprof/ lists them,
+// /debug/pprof/<name> returns one (with the debug query parameter of
+// pprof.Profile.WriteTo) and /debug/pprof/profile returns a CPU profile of
+// the number of seconds in the seconds query parameter, 30 by default.
+//
+// The server logs with the logger set by the options preceding it.
+func WithDebugServer(address string) Option {
+	if err := checkLoopback(address); err != nil {
+		return func(*Handler) error { return err }
+	}
+	server := &debugServer{address: address}
+	return func(h *Handler) error {
+		return server.register(h)
+	}
+}
+
+// checkLoopback returns an error if the host of address is not a loopback
+// address.
+func checkLoopback(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("invalid debug server address: %s", err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return nil
+	}
+	return fmt.Errorf("debug server address %s is not a loopback address", address)
+}
+
+// debugServer serves the state of the handlers of the streams of a
+// chaincode.
+type debugServer struct {
+	address string
+
+	once     sync.Once
+	err      error
+	listener net.Listener
+	// logger is the logger of the first handler registered, kept by the
+	// server so that it does not depend on the handlers still registered.
+	logger Logger
+
+	mutex    sync.Mutex
+	handlers []*Handler
+}
+
+// register adds h to the handlers served, starting the server on first
+// use.
+func (s *debugServer) register(h *Handler) error {
+	s.once.Do(func() {
+		s.listener, s.err = net.Listen("tcp", s.address)
+		if s.err != nil {
+			s.err = fmt.Errorf("debug server: %s", s.err)
+			return
+		}
+		s.logger = h.logger
+		if s.logger == nil {
+			s.logger = defaultLogger
+		}
+		server := &http.Server{Handler: s.mux()}
+		go func() {
+			err := server.Serve(s.listener)
+			s.logger.Printf("debug server on %s stopped: %s", s.address, err)
+		}()
+	})
+	if s.err != nil {
+		return s.err
+	}
+
+	h.debugServer = s
+	s.mutex.Lock()
+	s.handlers = append(s.handlers, h)
+	s.mutex.Unlock()
+	return nil
+}
+
+// unregister removes h from the handlers served once its stream ended.
+func (s *debugServer) unregister(h *Handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for i, registered := range s.handlers {
+		if registered == h {
+			s.handlers = append(s.handlers[:i], s.handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+func (s *debugServer) mux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/shim/state", s.serveState)
+	mux.HandleFunc("/debug/pprof/", servePprof)
+	return mux
+}
+
+// DebugState is the state of the handlers served by /debug/shim/state.
+type DebugState struct {
+	Streams   []DebugStream   `json:"streams"`
+	Scheduler *DebugScheduler `json:"scheduler,omitempty"`
+	Offchain  *DebugOffchain  `json:"offchain,omitempty"`
+	// Functions are the statistics of the functions of the chaincode,
+	// enabled with WithFunctionStats.
+	Functions map[string]FunctionStats `json:"functions,omitempty"`
+}
+
+// DebugStream is the state of the handler of a stream to the peer.
+type DebugStream struct {
+	// InFlight is the transaction context IDs, the channel ID followed by
+	// the transaction ID, of the transactions waiting for a response from
+	// the peer.
+	InFlight []string `json:"in_flight"`
+}
+
+// DebugScheduler is the state of the scheduler set with
+// WithChannelScheduler.
+type DebugScheduler struct {
+	Running  int                          `json:"running"`
+	Channels map[string]DebugChannelQueue `json:"channels"`
+}
+
+// DebugChannelQueue is the state of the transactions of a channel in the
+// scheduler.
+type DebugChannelQueue struct {
+	Pending int `json:"pending"`
+	Running int `json:"running"`
+}
+
+// DebugOffchain is the state of the delivery set with WithOffchainSink.
+type DebugOffchain struct {
+	// Pending is the number of transactions whose writes are queued or
+	// being delivered.
+	Pending  int `json:"pending"`
+	Capacity int `json:"capacity"`
+	// Dropped is the number of transactions whose writes were dropped
+	// because the queue was full or their delivery kept failing.
+	Dropped int64 `json:"dropped"`
+}
+
+func (s *debugServer) state() DebugState {
+	s.mutex.Lock()
+	handlers := append([]*Handler(nil), s.handlers...)
+	s.mutex.Unlock()
+
+	state := DebugState{Streams: []DebugStream{}}
+	for _, h := range handlers {
+		state.Streams = append(state.Streams, DebugStream{InFlight: h.inFlight()})
+	}
+	if len(handlers) == 0 {
+		return state
+	}
+	// the scheduler and offchain dispatcher are shared by the handlers
+	h := handlers[0]
+	if h.scheduler != nil {
+		state.Scheduler = h.scheduler.debugState()
+	}
+	if h.offchain != nil {
+		state.Offchain = &DebugOffchain{
+			Pending:  int(atomic.LoadInt32(&h.offchain.pending)),
+			Dropped:  atomic.LoadInt64(&h.offchain.dropped),
+			Capacity: h.offchain.policy.QueueSize,
+		}
+	}
+	if h.functionStats != nil {
+		state.Functions = h.functionStats.snapshot()
+	}
+	return state
+}
+
+func (s *debugServer) serveState(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(s.state())
+}
+
+// inFlight returns the sorted transaction context IDs waiting for a
+// response from the peer, when they are known to the router.
+func (h *Handler) inFlight() []string {
+	if r, ok := h.router.(*responseRouter); ok {
+		return r.inFlight()
+	}
+	return []string{}
+}
+
+func (s *scheduler) debugState() *DebugScheduler {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := &DebugScheduler{Running: s.running, Channels: map[string]DebugChannelQueue{}}
+	for name, c := range s.channels {
+		state.Channels[name] = DebugChannelQueue{Pending: len(c.pending), Running: c.running}
+	}
+	return state
+}
+
+// servePprof serves the profiles of runtime/pprof.
+func servePprof(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	switch name {
+	case "":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, p := range pprof.Profiles() {
+			fmt.Fprintf(w, "%s %d\n", p.Name(), p.Count())
+		}
+		fmt.Fprintln(w, "profile")
+	case "profile":
+		seconds, err := strconv.Atoi(r.FormValue("seconds"))
+		if err != nil || seconds <= 0 {
+			seconds = 30
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := pprof.StartCPUProfile(w); err != nil {
+			http.Error(w, fmt.Sprintf("could not start CPU profile: %s", err), http.StatusInternalServerError)
+			return
+		}
+		select {
+		case <-time.After(time.Duration(seconds) * time.Second):
+		case <-r.Context().Done():
+		}
+		pprof.StopCPUProfile()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			http.Error(w, fmt.Sprintf("unknown profile %s", name), http.StatusNotFound)
+			return
+		}
+		debug, _ := strconv.Atoi(r.FormValue("debug"))
+		if debug == 0 {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		p.WriteTo(w, debug)
+	}
+}
+
+// debugServerFromEnv returns the option starting the debug server set by
+// DebugAddressEnv, or nil if it is not set.
+func debugServerFromEnv() Option {
+	address := os.Getenv(DebugAddressEnv)
+	if address == "" {
+		return nil
+	}
+	return WithDebugServer(address)
+}
@@ -0,0 +1,127 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithDebugServerAddress(t *testing.T) {
+	var tests = []struct {
+		address     string
+		expectedErr string
+	}{
+		{address: "127.0.0.1:0"},
+		{address: "localhost:0"},
+		{address: "[::1]:0"},
+		{address: "0.0.0.0:6060", expectedErr: "debug server address 0.0.0.0:6060 is not a loopback address"},
+		{address: ":6060", expectedErr: "debug server address :6060 is not a loopback address"},
+		{address: "10.0.0.1:6060", expectedErr: "debug server address 10.0.0.1:6060 is not a loopback address"},
+		{address: "localhost", expectedErr: "invalid debug server address: address localhost: missing port in address"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.address, func(t *testing.T) {
+			err := checkLoopback(test.address)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestDebugServer(t *testing.T) {
+	server := &debugServer{address: "127.0.0.1:0"}
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithChannelScheduler(SchedulerConfig{Workers: 2}))
+	require.NoError(t, err)
+	require.NoError(t, server.register(h))
+	defer server.listener.Close()
+	url := "http://" + server.listener.Addr().String()
+
+	_, err = h.createResponseChannel("channel", "txid")
+	require.NoError(t, err)
+
+	t.Run("State", func(t *testing.T) {
+		resp, err := http.Get(url + "/debug/shim/state")
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var state DebugState
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&state))
+		assert.Equal(t, []DebugStream{{InFlight: []string{"channeltxid"}}}, state.Streams)
+		assert.Equal(t, &DebugScheduler{Running: 0, Channels: map[string]DebugChannelQueue{}}, state.Scheduler)
+		assert.Nil(t, state.Offchain)
+	})
+
+	t.Run("Profiles", func(t *testing.T) {
+		resp, err := http.Get(url + "/debug/pprof/")
+		require.NoError(t, err)
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Contains(t, string(body), "goroutine")
+
+		resp, err = http.Get(url + "/debug/pprof/goroutine?debug=1")
+		require.NoError(t, err)
+		body, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, string(body), "goroutine profile")
+
+		resp, err = http.Get(url + "/debug/pprof/missing")
+		require.NoError(t, err)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	})
+}
+
+func TestDebugServerLogger(t *testing.T) {
+	logger := &notifyingLogger{lines: make(chan string, 1)}
+	server := &debugServer{address: "127.0.0.1:0"}
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithLogger(logger))
+	require.NoError(t, err)
+	require.NoError(t, server.register(h))
+	server.unregister(h)
+	// the server does not log through the handler which registered first
+	h.logger = &recordingLogger{}
+
+	require.NoError(t, server.listener.Close())
+	select {
+	case line := <-logger.lines:
+		assert.Contains(t, line, "debug server on 127.0.0.1:0 stopped")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the debug server did not log that it stopped")
+	}
+}
+
+// notifyingLogger passes the log records to lines.
+type notifyingLogger struct {
+	lines chan string
+}
+
+func (l *notifyingLogger) Printf(format string, v ...interface{}) {
+	l.lines <- fmt.Sprintf(format, v...)
+}
+
+func TestDebugServerListenError(t *testing.T) {
+	first := &debugServer{address: "127.0.0.1:0"}
+	require.NoError(t, first.register(&Handler{}))
+	defer first.listener.Close()
+
+	err := WithDebugServer(first.listener.Addr().String())(&Handler{})
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "debug server: listen tcp")
+}
@@ -0,0 +1,115 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// DecodeInto calls fn with the key and a JSON decoder reading the value of
+// each result of iter, then closes iter. Iteration stops at the first error
+// returned by fn, which DecodeInto returns.
+//
+// For iterators returned by ChaincodeStub, the decoders read the values in
+// place in the batches fetched from the peer instead of copying each value
+// into its own byte slice first, which reduces garbage when scanning many
+// documents. Other iterators are read with Next.
+func DecodeInto(iter StateQueryIteratorInterface, fn func(key string, dec *json.Decoder) error) error {
+	pages, err := Pages(iter)
+	if err != nil {
+		return decodeEach(iter, fn)
+	}
+	defer pages.Close()
+
+	for pages.HasNextPage() {
+		page, err := pages.NextPage()
+		if err != nil {
+			return err
+		}
+		for _, result := range page.Results {
+			key, value, err := splitKV(result.ResultBytes)
+			if err != nil {
+				return err
+			}
+			if err := fn(key, json.NewDecoder(bytes.NewReader(value))); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// decodeEach is DecodeInto for iterators not backed by the peer.
+func decodeEach(iter StateQueryIteratorInterface, fn func(key string, dec *json.Decoder) error) error {
+	defer iter.Close()
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		if err := fn(kv.Key, json.NewDecoder(bytes.NewReader(kv.Value))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Field numbers and wire types of queryresult.KV.
+const (
+	kvKeyField   = 2
+	kvValueField = 3
+
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// splitKV returns the key and value of an encoded queryresult.KV, the
+// value sharing the memory of b.
+func splitKV(b []byte) (string, []byte, error) {
+	var key string
+	var value []byte
+	for len(b) > 0 {
+		tag, n := binary.Uvarint(b)
+		if n <= 0 {
+			return "", nil, errors.New("error unmarshaling result from bytes: invalid tag")
+		}
+		b = b[n:]
+
+		field, wireType := tag>>3, tag&7
+		switch wireType {
+		case wireVarint:
+			_, n = binary.Uvarint(b)
+		case wireFixed64:
+			n = 8
+		case wireFixed32:
+			n = 4
+		case wireBytes:
+			length, m := binary.Uvarint(b)
+			if m <= 0 || length > uint64(len(b)-m) {
+				return "", nil, errors.New("error unmarshaling result from bytes: invalid length")
+			}
+			data := b[m : m+int(length)]
+			switch field {
+			case kvKeyField:
+				key = string(data)
+			case kvValueField:
+				value = data
+			}
+			n = m + int(length)
+		default:
+			return "", nil, fmt.Errorf("error unmarshaling result from bytes: unexpected wire type %d", wireType)
+		}
+		if n <= 0 || n > len(b) {
+			return "", nil, errors.New("error unmarshaling result from bytes: truncated field")
+		}
+		b = b[n:]
+	}
+	return key, value, nil
+}
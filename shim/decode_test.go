@@ -0,0 +1,85 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type decodedAsset struct {
+	Owner string `json:"owner"`
+}
+
+func TestSplitKV(t *testing.T) {
+	b := marshalOrPanic(&queryresult.KV{Namespace: "cc", Key: "asset1", Value: []byte(`{"owner":"alice"}`)})
+	key, value, err := splitKV(b)
+	require.NoError(t, err)
+	assert.Equal(t, "asset1", key)
+	assert.Equal(t, `{"owner":"alice"}`, string(value))
+
+	key, value, err = splitKV(marshalOrPanic(&queryresult.KV{Key: "empty"}))
+	require.NoError(t, err)
+	assert.Equal(t, "empty", key)
+	assert.Empty(t, value)
+
+	_, _, err = splitKV(b[:len(b)-1])
+	assert.EqualError(t, err, "error unmarshaling result from bytes: invalid length")
+	_, _, err = splitKV([]byte{0x1f})
+	assert.EqualError(t, err, "error unmarshaling result from bytes: unexpected wire type 7")
+}
+
+func TestDecodeInto(t *testing.T) {
+	kv := func(key, owner string) *peerpb.QueryResultBytes {
+		return &peerpb.QueryResultBytes{ResultBytes: marshalOrPanic(&queryresult.KV{Key: key, Value: []byte(`{"owner":"` + owner + `"}`)})}
+	}
+	stub, fetches := newBatchingStub(
+		&peerpb.QueryResponse{Results: []*peerpb.QueryResultBytes{kv("a", "alice"), kv("b", "bob")}, HasMore: true, Id: "queryid"},
+		&peerpb.QueryResponse{Results: []*peerpb.QueryResultBytes{kv("c", "carol")}, Id: "queryid"},
+	)
+
+	iter, err := stub.GetStateByRange("a", "z")
+	require.NoError(t, err)
+
+	owners := map[string]string{}
+	err = DecodeInto(iter, func(key string, dec *json.Decoder) error {
+		var asset decodedAsset
+		if err := dec.Decode(&asset); err != nil {
+			return err
+		}
+		owners[key] = asset.Owner
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"a": "alice", "b": "bob", "c": "carol"}, owners)
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches))
+}
+
+func TestDecodeIntoNotPeer(t *testing.T) {
+	iter := &sliceStateIterator{kvs: []*queryresult.KV{
+		{Key: "a", Value: []byte(`{"owner":"alice"}`)},
+		{Key: "b", Value: []byte(`not json`)},
+		{Key: "c", Value: []byte(`{"owner":"carol"}`)},
+	}}
+
+	var keys []string
+	err := DecodeInto(iter, func(key string, dec *json.Decoder) error {
+		keys = append(keys, key)
+		var asset decodedAsset
+		if err := dec.Decode(&asset); err != nil {
+			return errors.New("bad document " + key)
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "bad document b")
+	assert.Equal(t, []string{"a", "b"}, keys)
+	assert.True(t, iter.closed)
+}
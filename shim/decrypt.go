@@ -0,0 +1,56 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Decryptor decrypts the confidential inputs of transactions before they
+// are passed to the chaincode, so that the chaincode reads the plaintext
+// from GetArgs and GetTransient.
+type Decryptor interface {
+	// Decrypt returns the plaintext of the arguments, including the
+	// function name, and of the transient data of a transaction. The
+	// returned slices and maps replace those of the transaction.
+	Decrypt(args [][]byte, transient map[string][]byte) ([][]byte, map[string][]byte, error)
+}
+
+// DecryptorFunc is a function implementing Decryptor.
+type DecryptorFunc func(args [][]byte, transient map[string][]byte) ([][]byte, map[string][]byte, error)
+
+// Decrypt calls f.
+func (f DecryptorFunc) Decrypt(args [][]byte, transient map[string][]byte) ([][]byte, map[string][]byte, error) {
+	return f(args, transient)
+}
+
+// WithDecryptor decrypts the arguments and transient data of each Init and
+// Invoke transaction with decryptor before calling the chaincode. The
+// transactions whose inputs fail to decrypt get an error response without
+// calling the chaincode. The argument limits apply to the inputs as
+// received from the peer.
+func WithDecryptor(decryptor Decryptor) Option {
+	return func(h *Handler) error {
+		if decryptor == nil {
+			return errors.New("decryptor must not be nil")
+		}
+		h.decryptor = decryptor
+		return nil
+	}
+}
+
+// decryptInputs replaces the arguments and transient data of stub with
+// their plaintext.
+func (h *Handler) decryptInputs(stub *ChaincodeStub) error {
+	if h.decryptor == nil {
+		return nil
+	}
+	args, transient, err := h.decryptor.Decrypt(stub.args, stub.transient)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt transaction inputs: %s", err)
+	}
+	stub.args, stub.transient = args, transient
+	return nil
+}
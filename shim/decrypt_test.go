@@ -0,0 +1,88 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// reverseDecryptor reverses the bytes of the arguments and adds a
+// transient field, failing for the function "bad".
+var reverseDecryptor = DecryptorFunc(func(args [][]byte, transient map[string][]byte) ([][]byte, map[string][]byte, error) {
+	if len(args) > 0 && string(args[0]) == "bad" {
+		return nil, nil, errors.New("bad key")
+	}
+	var plaintexts [][]byte
+	for _, arg := range args {
+		plaintext := append([]byte(nil), arg...)
+		for i, j := 0, len(plaintext)-1; i < j; i, j = i+1, j-1 {
+			plaintext[i], plaintext[j] = plaintext[j], plaintext[i]
+		}
+		plaintexts = append(plaintexts, plaintext)
+	}
+	return plaintexts, map[string][]byte{"decrypted": []byte("true")}, nil
+})
+
+// inputRecorder records the inputs of the transactions.
+type inputRecorder struct {
+	args      [][]byte
+	transient map[string][]byte
+}
+
+func (r *inputRecorder) Init(stub ChaincodeStubInterface) peerpb.Response {
+	return r.Invoke(stub)
+}
+
+func (r *inputRecorder) Invoke(stub ChaincodeStubInterface) peerpb.Response {
+	r.args = stub.GetArgs()
+	r.transient, _ = stub.GetTransient()
+	return Success(nil)
+}
+
+func TestWithDecryptor(t *testing.T) {
+	cc := &inputRecorder{}
+	h, err := newChaincodeHandler(nil, cc, WithDecryptor(reverseDecryptor), WithArgLimits(ArgLimits{MaxSize: 4}))
+	require.NoError(t, err)
+	assert.Equal(t, "true", h.features()["decryptor"])
+
+	for _, handle := range []stubHandlerFunc{h.handleInit, h.handleTransaction} {
+		input, err := proto.Marshal(&peerpb.ChaincodeInput{Args: [][]byte{[]byte("nf"), []byte("cba")}})
+		require.NoError(t, err)
+		msg, err := handle(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, Txid: "txid", Payload: input})
+		require.NoError(t, err)
+		assert.Equal(t, peerpb.ChaincodeMessage_COMPLETED, msg.Type)
+		assert.Equal(t, [][]byte{[]byte("fn"), []byte("abc")}, cc.args)
+		assert.Equal(t, map[string][]byte{"decrypted": []byte("true")}, cc.transient)
+	}
+
+	var tests = []struct {
+		name   string
+		args   [][]byte
+		errMsg string
+	}{
+		{name: "DecryptionError", args: [][]byte{[]byte("bad")}, errMsg: "failed to decrypt transaction inputs: bad key"},
+		{name: "ArgLimits", args: [][]byte{[]byte("fn"), bytes.Repeat([]byte("a"), 5)}, errMsg: "argument 1 is 5 bytes, exceeding the maximum of 4 bytes"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cc.args = nil
+			res := h.callChaincode(cc.Invoke, &ChaincodeStub{args: test.args})
+			assert.Equal(t, int32(ERRORTHRESHOLD), res.Status)
+			assert.Equal(t, test.errMsg, res.Message)
+			assert.Nil(t, cc.args)
+		})
+	}
+}
+
+func TestWithDecryptorNil(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithDecryptor(nil))
+	assert.EqualError(t, err, "decryptor must not be nil")
+}
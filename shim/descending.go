@@ -0,0 +1,75 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// ReverseStateIterator reads iter to the end, closes it and returns an
+// iterator over its results in reverse order. If limit is positive, only
+// the last limit results are retained, so the last entries of a range can
+// be read with memory bounded by limit; iter is read to the end regardless.
+func ReverseStateIterator(iter StateQueryIteratorInterface, limit int) (StateQueryIteratorInterface, error) {
+	defer iter.Close()
+
+	// once limit results are retained, results is a ring buffer whose
+	// oldest entry is at oldest
+	var results []*queryresult.KV
+	oldest := 0
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(results) == limit {
+			results[oldest] = kv
+			oldest = (oldest + 1) % limit
+			continue
+		}
+		results = append(results, kv)
+	}
+	if oldest > 0 {
+		unrolled := make([]*queryresult.KV, 0, len(results))
+		results = append(append(unrolled, results[oldest:]...), results[:oldest]...)
+	}
+	return &reverseStateIterator{results: results}, nil
+}
+
+// reverseStateIterator iterates over results from the last to the first.
+type reverseStateIterator struct {
+	results []*queryresult.KV
+	closed  bool
+}
+
+func (r *reverseStateIterator) HasNext() bool {
+	return !r.closed && len(r.results) > 0
+}
+
+func (r *reverseStateIterator) Next() (*queryresult.KV, error) {
+	if !r.HasNext() {
+		return nil, errors.New("no such key")
+	}
+	last := len(r.results) - 1
+	kv := r.results[last]
+	r.results = r.results[:last]
+	return kv, nil
+}
+
+func (r *reverseStateIterator) Close() error {
+	r.closed = true
+	r.results = nil
+	return nil
+}
+
+// GetStateByRangeDescending documentation can be found in interfaces.go
+func (s *ChaincodeStub) GetStateByRangeDescending(startKey, endKey string) (StateQueryIteratorInterface, error) {
+	iter, err := s.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return ReverseStateIterator(iter, 0)
+}
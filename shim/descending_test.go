@@ -0,0 +1,49 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReverseStateIterator(t *testing.T) {
+	tests := []struct {
+		name     string
+		limit    int
+		expected []string
+	}{
+		{"All", 0, []string{"e", "d", "c", "b", "a"}},
+		{"Last", 2, []string{"e", "d"}},
+		{"LastOne", 1, []string{"e"}},
+		{"Wrapped", 3, []string{"e", "d", "c"}},
+		{"LimitEqualsCount", 5, []string{"e", "d", "c", "b", "a"}},
+		{"LimitAboveCount", 10, []string{"e", "d", "c", "b", "a"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := &sliceStateIterator{}
+			for _, k := range []string{"a", "b", "c", "d", "e"} {
+				source.kvs = append(source.kvs, &queryresult.KV{Key: k})
+			}
+
+			iter, err := ReverseStateIterator(source, tt.limit)
+			assert.NoError(t, err)
+			assert.True(t, source.closed)
+
+			var keys []string
+			for iter.HasNext() {
+				kv, err := iter.Next()
+				assert.NoError(t, err)
+				keys = append(keys, kv.Key)
+			}
+			assert.Equal(t, tt.expected, keys)
+			_, err = iter.Next()
+			assert.EqualError(t, err, "no such key")
+			assert.NoError(t, iter.Close())
+		})
+	}
+}
@@ -0,0 +1,56 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest/conformance"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/require"
+)
+
+// stubChaincode hands the stub of each transaction over to the test, and
+// completes the transaction when the test releases it.
+type stubChaincode struct {
+	stubs   chan shim.ChaincodeStubInterface
+	release chan struct{}
+}
+
+func (c *stubChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (c *stubChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	c.stubs <- stub
+	<-c.release
+	return shim.Success(nil)
+}
+
+func TestConformance(t *testing.T) {
+	conformance.Run(t, func(t *testing.T, state map[string][]byte) (shim.ChaincodeStubInterface, func()) {
+		ledger := NewMemoryLedger()
+		var writes []KVWrite
+		for key, value := range state {
+			writes = append(writes, KVWrite{Key: key, Value: value})
+		}
+		require.NoError(t, ledger.Commit(writes, nil))
+
+		cc := &stubChaincode{stubs: make(chan shim.ChaincodeStubInterface), release: make(chan struct{})}
+		r, err := Start("conformance", cc, ledger)
+		require.NoError(t, err)
+		done := make(chan struct{})
+		go func() {
+			r.Invoke(Transaction{ChannelID: "channel", TxID: "tx"})
+			close(done)
+		}()
+
+		return <-cc.stubs, func() {
+			close(cc.release)
+			<-done
+			r.Stop()
+		}
+	})
+}
@@ -0,0 +1,96 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// DebugRequest is the JSON description of a transaction accepted by the
+// debug handler.
+type DebugRequest struct {
+	ChannelID string   `json:"channel_id"`
+	TxID      string   `json:"tx_id"`
+	Init      bool     `json:"init"`
+	Args      []string `json:"args"`
+}
+
+// DebugResponse is the JSON description of the outcome of a transaction
+// returned by the debug handler. Payloads are returned as strings to keep
+// the output readable.
+type DebugResponse struct {
+	Status  int32       `json:"status"`
+	Message string      `json:"message,omitempty"`
+	Payload string      `json:"payload,omitempty"`
+	Event   *DebugEvent `json:"event,omitempty"`
+}
+
+// DebugEvent is the JSON description of a chaincode event.
+type DebugEvent struct {
+	Name    string `json:"name"`
+	Payload string `json:"payload,omitempty"`
+}
+
+// debugHandler serves DebugRequests.
+type debugHandler struct {
+	runner *Runner
+	nextTx uint64
+}
+
+// NewDebugHandler returns an http.Handler which executes the transactions
+// POSTed to it as DebugRequests using r and replies with a DebugResponse.
+// A transaction ID is generated when the request does not provide one.
+//
+// The handler performs no authentication and is only meant to exercise
+// chaincode functions during development, for example:
+//
+//	curl -d '{"args":["put","a","1"]}' http://localhost:8080/
+func NewDebugHandler(r *Runner) http.Handler {
+	return &debugHandler{runner: r}
+}
+
+func (h *debugHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var dr DebugRequest
+	if err := json.NewDecoder(req.Body).Decode(&dr); err != nil {
+		http.Error(w, fmt.Sprintf("invalid request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if dr.TxID == "" {
+		dr.TxID = fmt.Sprintf("debug-%d", atomic.AddUint64(&h.nextTx, 1))
+	}
+
+	tx := Transaction{ChannelID: dr.ChannelID, TxID: dr.TxID}
+	for _, arg := range dr.Args {
+		tx.Args = append(tx.Args, []byte(arg))
+	}
+	execute := h.runner.Invoke
+	if dr.Init {
+		execute = h.runner.Init
+	}
+	result, err := execute(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	resp := DebugResponse{
+		Status:  result.Response.Status,
+		Message: result.Response.Message,
+		Payload: string(result.Response.Payload),
+	}
+	if result.Event != nil {
+		resp.Event = &DebugEvent{Name: result.Event.EventName, Payload: string(result.Event.Payload)}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
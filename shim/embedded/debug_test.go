@@ -0,0 +1,54 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	r, ledger := startRunner(t)
+	defer r.Stop()
+	h := NewDebugHandler(r)
+
+	var tests = []struct {
+		name       string
+		method     string
+		body       string
+		statusCode int
+		expected   *DebugResponse
+	}{
+		{name: "Init", method: "POST", body: `{"init":true}`, statusCode: http.StatusOK, expected: &DebugResponse{Status: 200}},
+		{name: "Put", method: "POST", body: `{"channel_id":"ch","args":["put","a","1"]}`, statusCode: http.StatusOK, expected: &DebugResponse{Status: 200}},
+		{name: "Get", method: "POST", body: `{"tx_id":"tx","args":["get","a"]}`, statusCode: http.StatusOK, expected: &DebugResponse{Status: 200, Payload: "1"}},
+		{name: "Event", method: "POST", body: `{"args":["event","e","p"]}`, statusCode: http.StatusOK, expected: &DebugResponse{Status: 200, Event: &DebugEvent{Name: "e", Payload: "p"}}},
+		{name: "ChaincodeError", method: "POST", body: `{"args":["bad"]}`, statusCode: http.StatusOK, expected: &DebugResponse{Status: 500, Message: "unknown function bad"}},
+		{name: "InvalidJSON", method: "POST", body: `{`, statusCode: http.StatusBadRequest},
+		{name: "MethodNotAllowed", method: "GET", statusCode: http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(test.method, "/", strings.NewReader(test.body)))
+			assert.Equal(t, test.statusCode, rec.Code)
+			if test.expected != nil {
+				resp := &DebugResponse{}
+				require.NoError(t, json.Unmarshal(rec.Body.Bytes(), resp))
+				assert.Equal(t, test.expected, resp)
+			}
+		})
+	}
+
+	value, _ := ledger.GetState("", "a")
+	assert.Equal(t, []byte("1"), value)
+}
@@ -0,0 +1,235 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package embedded runs a chaincode in the current process without a peer.
+// The chaincode is connected to the runner through an in-memory stream and
+// is driven by exactly the same shim handler as a chaincode talking to a
+// peer over gRPC, which makes it possible to embed chaincode logic in
+// simulators, notebooks and local development tools. Chaincode started
+// with shim.Start in its own process connects to an InMemoryPeer instead.
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Transaction describes a transaction to execute.
+type Transaction struct {
+	ChannelID string
+	TxID      string
+	Args      [][]byte
+
+	// SignedProposal is optional. When set, the creator, transient data and
+	// timestamp available to the chaincode are taken from it.
+	SignedProposal *pb.SignedProposal
+}
+
+// Result is the outcome of a transaction.
+type Result struct {
+	// Response is the response returned by the chaincode.
+	Response pb.Response
+	// Event is the event set by the chaincode, if any.
+	Event *pb.ChaincodeEvent
+}
+
+// Runner executes transactions against a chaincode running in the current
+// process. It is safe for concurrent use.
+type Runner struct {
+	ledger Ledger
+	stream chaincodeStream
+	// closeStream disconnects the chaincode.
+	closeStream func()
+
+	mutex          sync.Mutex
+	txs            map[string]*txContext
+	nextIteratorID int
+
+	stopped chan struct{}
+	err     error
+}
+
+// chaincodeStream is the peer end of the stream to the chaincode.
+type chaincodeStream interface {
+	Send(*pb.ChaincodeMessage) error
+	Recv() (*pb.ChaincodeMessage, error)
+}
+
+func newRunner(ledger Ledger, stream chaincodeStream, closeStream func()) *Runner {
+	return &Runner{
+		ledger:      ledger,
+		stream:      stream,
+		closeStream: closeStream,
+		txs:         map[string]*txContext{},
+		stopped:     make(chan struct{}),
+	}
+}
+
+// Start starts the chaincode cc with the given name and returns a Runner
+// executing transactions against it. The chaincode reads from and commits
+// to ledger. The options are passed to the shim handler.
+func Start(name string, cc shim.Chaincode, ledger Ledger, opts ...shim.Option) (*Runner, error) {
+	if ledger == nil {
+		return nil, errors.New("ledger must not be nil")
+	}
+
+	ccStream, peerStream := NewStreamPair()
+	r := newRunner(ledger, peerStream, func() { peerStream.CloseSend() })
+	go func() {
+		r.err = shim.StartInProc(name, ccStream, cc, opts...)
+		close(r.stopped)
+	}()
+
+	if _, err := r.register(); err != nil {
+		peerStream.CloseSend()
+		<-r.stopped
+		return nil, err
+	}
+
+	go r.serve()
+	return r, nil
+}
+
+// register performs the registration handshake with the chaincode and
+// returns the ID it registered with.
+func (r *Runner) register() (*pb.ChaincodeID, error) {
+	msg, err := r.stream.Recv()
+	if err != nil {
+		return nil, fmt.Errorf("failed to receive registration: %s", err)
+	}
+	if msg.Type != pb.ChaincodeMessage_REGISTER {
+		return nil, fmt.Errorf("expected %s message, received %s", pb.ChaincodeMessage_REGISTER, msg.Type)
+	}
+	id := &pb.ChaincodeID{}
+	if err := proto.Unmarshal(msg.Payload, id); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal chaincode ID: %s", err)
+	}
+	for _, t := range []pb.ChaincodeMessage_Type{pb.ChaincodeMessage_REGISTERED, pb.ChaincodeMessage_READY} {
+		if err := r.stream.Send(&pb.ChaincodeMessage{Type: t}); err != nil {
+			return nil, fmt.Errorf("failed to send %s: %s", t, err)
+		}
+	}
+	return id, nil
+}
+
+// Stop disconnects the chaincode and waits for its handler to exit.
+func (r *Runner) Stop() {
+	r.closeStream()
+	<-r.stopped
+}
+
+// Init executes tx by calling the Init function of the chaincode.
+func (r *Runner) Init(tx Transaction) (*Result, error) {
+	return r.execute(pb.ChaincodeMessage_INIT, tx)
+}
+
+// Invoke executes tx by calling the Invoke function of the chaincode.
+func (r *Runner) Invoke(tx Transaction) (*Result, error) {
+	return r.execute(pb.ChaincodeMessage_TRANSACTION, tx)
+}
+
+func (r *Runner) execute(msgType pb.ChaincodeMessage_Type, tx Transaction) (*Result, error) {
+	ctx, err := r.beginTx(tx.ChannelID, tx.TxID)
+	if err != nil {
+		return nil, err
+	}
+	defer r.endTx(tx.ChannelID, tx.TxID)
+
+	payload, err := proto.Marshal(&pb.ChaincodeInput{Args: tx.Args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal input: %s", err)
+	}
+	msg := &pb.ChaincodeMessage{
+		Type:      msgType,
+		Payload:   payload,
+		Txid:      tx.TxID,
+		ChannelId: tx.ChannelID,
+		Proposal:  tx.SignedProposal,
+	}
+	if err := r.stream.Send(msg); err != nil {
+		return nil, fmt.Errorf("failed to send %s: %s", msgType, err)
+	}
+
+	var resp *pb.ChaincodeMessage
+	select {
+	case resp = <-ctx.done:
+	case <-r.stopped:
+		return nil, fmt.Errorf("chaincode stopped: %s", r.err)
+	}
+
+	switch resp.Type {
+	case pb.ChaincodeMessage_COMPLETED:
+		result := &Result{Event: resp.ChaincodeEvent}
+		if err := proto.Unmarshal(resp.Payload, &result.Response); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response: %s", err)
+		}
+		if result.Response.Status < shim.ERRORTHRESHOLD {
+			if err := ctx.commit(r.ledger); err != nil {
+				return nil, fmt.Errorf("failed to commit transaction: %s", err)
+			}
+		}
+		return result, nil
+	default:
+		return &Result{Response: shim.Error(string(resp.Payload)), Event: resp.ChaincodeEvent}, nil
+	}
+}
+
+func (r *Runner) beginTx(channelID, txID string) (*txContext, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	id := channelID + txID
+	if _, ok := r.txs[id]; ok {
+		return nil, fmt.Errorf("transaction %s is already executing on channel '%s'", txID, channelID)
+	}
+	ctx := newTxContext()
+	r.txs[id] = ctx
+	return ctx, nil
+}
+
+func (r *Runner) endTx(channelID, txID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.txs, channelID+txID)
+}
+
+func (r *Runner) txContext(channelID, txID string) *txContext {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.txs[channelID+txID]
+}
+
+func (r *Runner) iteratorID() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.nextIteratorID++
+	return fmt.Sprintf("%d", r.nextIteratorID)
+}
+
+// serve processes the messages sent by the chaincode until the stream is
+// closed.
+func (r *Runner) serve() {
+	for {
+		msg, err := r.stream.Recv()
+		if err != nil {
+			return
+		}
+
+		switch msg.Type {
+		case pb.ChaincodeMessage_KEEPALIVE:
+		case pb.ChaincodeMessage_COMPLETED, pb.ChaincodeMessage_ERROR:
+			if ctx := r.txContext(msg.ChannelId, msg.Txid); ctx != nil {
+				ctx.done <- msg
+			}
+		default:
+			if err := r.stream.Send(r.handleRequest(msg)); err != nil {
+				return
+			}
+		}
+	}
+}
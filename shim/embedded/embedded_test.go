@@ -0,0 +1,224 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kvChaincode is a chaincode exercising the ledger APIs.
+type kvChaincode struct{}
+
+func (kvChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (kvChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	fn, args := stub.GetFunctionAndParameters()
+	switch fn {
+	case "put":
+		if err := stub.PutState(args[0], []byte(args[1])); err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(nil)
+	case "putFail":
+		if err := stub.PutState(args[0], []byte(args[1])); err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Error("failed on purpose")
+	case "get":
+		value, err := stub.GetState(args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(value)
+	case "del":
+		if err := stub.DelState(args[0]); err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(nil)
+	case "range":
+		iter, err := stub.GetStateByRange(args[0], args[1])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		defer iter.Close()
+		var keys []string
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			keys = append(keys, kv.Key)
+		}
+		return shim.Success([]byte(strings.Join(keys, ",")))
+	case "page":
+		iter, md, err := stub.GetStateByRangeWithPagination("", "", 2, args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		defer iter.Close()
+		var keys []string
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				return shim.Error(err.Error())
+			}
+			keys = append(keys, kv.Key)
+		}
+		return shim.Success([]byte(fmt.Sprintf("%s|%d|%s", strings.Join(keys, ","), md.FetchedRecordsCount, md.Bookmark)))
+	case "setPolicy":
+		if err := stub.SetStateValidationParameter(args[0], []byte(args[1])); err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(nil)
+	case "getPolicy":
+		ep, err := stub.GetStateValidationParameter(args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(ep)
+	case "event":
+		if err := stub.SetEvent(args[0], []byte(args[1])); err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(nil)
+	case "history":
+		if _, err := stub.GetHistoryForKey(args[0]); err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(nil)
+	}
+	return shim.Error("unknown function " + fn)
+}
+
+func startRunner(t *testing.T) (*Runner, *MemoryLedger) {
+	ledger := NewMemoryLedger()
+	r, err := Start("kv", kvChaincode{}, ledger)
+	require.NoError(t, err)
+	return r, ledger
+}
+
+func invoke(t *testing.T, r *Runner, txID string, args ...string) *Result {
+	var bargs [][]byte
+	for _, arg := range args {
+		bargs = append(bargs, []byte(arg))
+	}
+	result, err := r.Invoke(Transaction{ChannelID: "channel", TxID: txID, Args: bargs})
+	require.NoError(t, err)
+	return result
+}
+
+func TestInitInvoke(t *testing.T) {
+	r, ledger := startRunner(t)
+	defer r.Stop()
+
+	result, err := r.Init(Transaction{ChannelID: "channel", TxID: "tx0"})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(shim.OK), result.Response.Status)
+
+	result = invoke(t, r, "tx1", "put", "a", "1")
+	assert.Equal(t, int32(shim.OK), result.Response.Status)
+	value, _ := ledger.GetState("", "a")
+	assert.Equal(t, []byte("1"), value)
+
+	result = invoke(t, r, "tx2", "get", "a")
+	assert.Equal(t, []byte("1"), result.Response.Payload)
+
+	invoke(t, r, "tx3", "del", "a")
+	value, _ = ledger.GetState("", "a")
+	assert.Nil(t, value)
+
+	result = invoke(t, r, "tx4", "unknown")
+	assert.Equal(t, int32(shim.ERROR), result.Response.Status)
+	assert.Equal(t, "unknown function unknown", result.Response.Message)
+}
+
+func TestInvokeErrorNotCommitted(t *testing.T) {
+	r, ledger := startRunner(t)
+	defer r.Stop()
+
+	result := invoke(t, r, "tx1", "putFail", "a", "1")
+	assert.Equal(t, int32(shim.ERROR), result.Response.Status)
+	value, _ := ledger.GetState("", "a")
+	assert.Nil(t, value)
+}
+
+func TestRangeQuery(t *testing.T) {
+	r, ledger := startRunner(t)
+	defer r.Stop()
+
+	var writes []KVWrite
+	for i := 0; i < 250; i++ {
+		writes = append(writes, KVWrite{Key: fmt.Sprintf("k%03d", i), Value: []byte("v")})
+	}
+	require.NoError(t, ledger.Commit(writes, nil))
+
+	result := invoke(t, r, "tx1", "range", "k010", "k013")
+	assert.Equal(t, "k010,k011,k012", string(result.Response.Payload))
+
+	result = invoke(t, r, "tx2", "range", "", "")
+	assert.Len(t, strings.Split(string(result.Response.Payload), ","), 250)
+
+	result = invoke(t, r, "tx3", "page", "")
+	assert.Equal(t, "k000,k001|2|k002", string(result.Response.Payload))
+	result = invoke(t, r, "tx4", "page", "k248")
+	assert.Equal(t, "k248,k249|2|", string(result.Response.Payload))
+}
+
+func TestStateMetadata(t *testing.T) {
+	r, ledger := startRunner(t)
+	defer r.Stop()
+
+	invoke(t, r, "tx1", "put", "a", "1")
+	invoke(t, r, "tx2", "setPolicy", "a", "policy")
+	md, _ := ledger.GetStateMetadata("", "a")
+	assert.Equal(t, map[string][]byte{pb.MetaDataKeys_VALIDATION_PARAMETER.String(): []byte("policy")}, md)
+
+	result := invoke(t, r, "tx3", "getPolicy", "a")
+	assert.Equal(t, []byte("policy"), result.Response.Payload)
+
+	invoke(t, r, "tx4", "del", "a")
+	md, _ = ledger.GetStateMetadata("", "a")
+	assert.Empty(t, md)
+}
+
+func TestEvent(t *testing.T) {
+	r, _ := startRunner(t)
+	defer r.Stop()
+
+	result := invoke(t, r, "tx1", "event", "name", "payload")
+	require.NotNil(t, result.Event)
+	assert.Equal(t, "name", result.Event.EventName)
+	assert.Equal(t, []byte("payload"), result.Event.Payload)
+}
+
+func TestUnsupportedRequest(t *testing.T) {
+	r, _ := startRunner(t)
+	defer r.Stop()
+
+	result := invoke(t, r, "tx1", "history", "a")
+	assert.Equal(t, int32(shim.ERROR), result.Response.Status)
+	assert.Contains(t, result.Response.Message, "GET_HISTORY_FOR_KEY is not supported by the embedded runner")
+}
+
+func TestStop(t *testing.T) {
+	r, _ := startRunner(t)
+	r.Stop()
+
+	_, err := r.Invoke(Transaction{ChannelID: "channel", TxID: "tx1"})
+	assert.Error(t, err)
+}
+
+func TestStartNilLedger(t *testing.T) {
+	_, err := Start("kv", kvChaincode{}, nil)
+	assert.EqualError(t, err, "ledger must not be nil")
+}
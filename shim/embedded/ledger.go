@@ -0,0 +1,126 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// Ledger is the state backing an embedded chaincode. Reads see committed
+// state only; the writes of a transaction are committed when the chaincode
+// completes the transaction successfully. The collection is empty for the
+// public state.
+type Ledger interface {
+	// GetState returns the value of key, or nil if it does not exist.
+	GetState(collection, key string) ([]byte, error)
+
+	// GetStateMetadata returns the metadata entries of key.
+	GetStateMetadata(collection, key string) (map[string][]byte, error)
+
+	// GetStateRange returns the keys and values between startKey
+	// (inclusive) and endKey (exclusive) in lexical order. An empty endKey
+	// means the range is unbounded.
+	GetStateRange(collection, startKey, endKey string) ([]*queryresult.KV, error)
+
+	// Commit applies the writes of a transaction.
+	Commit(writes []KVWrite, metadataWrites []MetadataWrite) error
+}
+
+// KVWrite is a write of a key made by a transaction.
+type KVWrite struct {
+	Collection string
+	Key        string
+	Value      []byte
+	IsDelete   bool
+}
+
+// MetadataWrite is a write of a metadata entry of a key made by a
+// transaction.
+type MetadataWrite struct {
+	Collection string
+	Key        string
+	Name       string
+	Value      []byte
+}
+
+// MemoryLedger is a Ledger which keeps its state in memory. It is safe for
+// concurrent use.
+type MemoryLedger struct {
+	mutex    sync.RWMutex
+	state    map[string]map[string][]byte
+	metadata map[string]map[string]map[string][]byte
+}
+
+// NewMemoryLedger returns an empty MemoryLedger.
+func NewMemoryLedger() *MemoryLedger {
+	return &MemoryLedger{
+		state:    map[string]map[string][]byte{},
+		metadata: map[string]map[string]map[string][]byte{},
+	}
+}
+
+// GetState implements Ledger.
+func (l *MemoryLedger) GetState(collection, key string) ([]byte, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.state[collection][key], nil
+}
+
+// GetStateMetadata implements Ledger.
+func (l *MemoryLedger) GetStateMetadata(collection, key string) (map[string][]byte, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	md := map[string][]byte{}
+	for name, value := range l.metadata[collection][key] {
+		md[name] = value
+	}
+	return md, nil
+}
+
+// GetStateRange implements Ledger.
+func (l *MemoryLedger) GetStateRange(collection, startKey, endKey string) ([]*queryresult.KV, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	var results []*queryresult.KV
+	for key, value := range l.state[collection] {
+		if key >= startKey && (endKey == "" || key < endKey) {
+			results = append(results, &queryresult.KV{Key: key, Value: value})
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Key < results[j].Key })
+	return results, nil
+}
+
+// Commit implements Ledger.
+func (l *MemoryLedger) Commit(writes []KVWrite, metadataWrites []MetadataWrite) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, w := range writes {
+		if w.IsDelete {
+			delete(l.state[w.Collection], w.Key)
+			delete(l.metadata[w.Collection], w.Key)
+			continue
+		}
+		if l.state[w.Collection] == nil {
+			l.state[w.Collection] = map[string][]byte{}
+		}
+		l.state[w.Collection][w.Key] = w.Value
+	}
+	for _, w := range metadataWrites {
+		if l.metadata[w.Collection] == nil {
+			l.metadata[w.Collection] = map[string]map[string][]byte{}
+		}
+		if l.metadata[w.Collection][w.Key] == nil {
+			l.metadata[w.Collection][w.Key] = map[string][]byte{}
+		}
+		l.metadata[w.Collection][w.Key][w.Name] = w.Value
+	}
+	return nil
+}
@@ -0,0 +1,244 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// queryBatchSize is the number of results returned to the chaincode per
+// QueryResponse.
+const queryBatchSize = 100
+
+// txContext holds the state of a transaction executing in the chaincode.
+type txContext struct {
+	done chan *pb.ChaincodeMessage
+
+	writes         map[string]map[string]KVWrite
+	metadataWrites []MetadataWrite
+	iterators      map[string][]*queryresult.KV
+}
+
+func newTxContext() *txContext {
+	return &txContext{
+		done:      make(chan *pb.ChaincodeMessage, 1),
+		writes:    map[string]map[string]KVWrite{},
+		iterators: map[string][]*queryresult.KV{},
+	}
+}
+
+func (ctx *txContext) write(w KVWrite) {
+	if ctx.writes[w.Collection] == nil {
+		ctx.writes[w.Collection] = map[string]KVWrite{}
+	}
+	ctx.writes[w.Collection][w.Key] = w
+}
+
+// commit applies the writes of the transaction to ledger in a deterministic
+// order.
+func (ctx *txContext) commit(ledger Ledger) error {
+	var writes []KVWrite
+	for _, byKey := range ctx.writes {
+		for _, w := range byKey {
+			writes = append(writes, w)
+		}
+	}
+	sort.Slice(writes, func(i, j int) bool {
+		if writes[i].Collection != writes[j].Collection {
+			return writes[i].Collection < writes[j].Collection
+		}
+		return writes[i].Key < writes[j].Key
+	})
+	return ledger.Commit(writes, ctx.metadataWrites)
+}
+
+// handleRequest handles a ledger request sent by the chaincode and returns
+// the response to send back.
+func (r *Runner) handleRequest(msg *pb.ChaincodeMessage) *pb.ChaincodeMessage {
+	payload, err := r.processRequest(msg)
+	if err != nil {
+		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: msg.Txid, ChannelId: msg.ChannelId}
+	}
+	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Payload: payload, Txid: msg.Txid, ChannelId: msg.ChannelId}
+}
+
+func (r *Runner) processRequest(msg *pb.ChaincodeMessage) ([]byte, error) {
+	ctx := r.txContext(msg.ChannelId, msg.Txid)
+	if ctx == nil {
+		return nil, fmt.Errorf("[%s] no transaction context for %s", msg.Txid, msg.Type)
+	}
+
+	switch msg.Type {
+	case pb.ChaincodeMessage_GET_STATE:
+		req := &pb.GetState{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		return r.ledger.GetState(req.Collection, req.Key)
+
+	case pb.ChaincodeMessage_GET_PRIVATE_DATA_HASH:
+		req := &pb.GetState{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		value, err := r.ledger.GetState(req.Collection, req.Key)
+		if err != nil || value == nil {
+			return nil, err
+		}
+		hash := sha256.Sum256(value)
+		return hash[:], nil
+
+	case pb.ChaincodeMessage_PUT_STATE:
+		req := &pb.PutState{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		ctx.write(KVWrite{Collection: req.Collection, Key: req.Key, Value: req.Value})
+		return nil, nil
+
+	case pb.ChaincodeMessage_DEL_STATE:
+		req := &pb.DelState{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		ctx.write(KVWrite{Collection: req.Collection, Key: req.Key, IsDelete: true})
+		return nil, nil
+
+	case pb.ChaincodeMessage_GET_STATE_METADATA:
+		req := &pb.GetStateMetadata{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		md, err := r.ledger.GetStateMetadata(req.Collection, req.Key)
+		if err != nil {
+			return nil, err
+		}
+		result := &pb.StateMetadataResult{}
+		for name, value := range md {
+			result.Entries = append(result.Entries, &pb.StateMetadata{Metakey: name, Value: value})
+		}
+		sort.Slice(result.Entries, func(i, j int) bool { return result.Entries[i].Metakey < result.Entries[j].Metakey })
+		return proto.Marshal(result)
+
+	case pb.ChaincodeMessage_PUT_STATE_METADATA:
+		req := &pb.PutStateMetadata{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		if req.Metadata == nil {
+			return nil, fmt.Errorf("[%s] metadata entry missing", msg.Txid)
+		}
+		ctx.metadataWrites = append(ctx.metadataWrites, MetadataWrite{
+			Collection: req.Collection,
+			Key:        req.Key,
+			Name:       req.Metadata.Metakey,
+			Value:      req.Metadata.Value,
+		})
+		return nil, nil
+
+	case pb.ChaincodeMessage_GET_STATE_BY_RANGE:
+		req := &pb.GetStateByRange{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		return r.rangeQuery(ctx, req)
+
+	case pb.ChaincodeMessage_QUERY_STATE_NEXT:
+		req := &pb.QueryStateNext{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		results, ok := ctx.iterators[req.Id]
+		if !ok {
+			return nil, fmt.Errorf("[%s] query iterator %s not found", msg.Txid, req.Id)
+		}
+		return nextBatch(ctx, req.Id, results, nil)
+
+	case pb.ChaincodeMessage_QUERY_STATE_CLOSE:
+		req := &pb.QueryStateClose{}
+		if err := proto.Unmarshal(msg.Payload, req); err != nil {
+			return nil, err
+		}
+		delete(ctx.iterators, req.Id)
+		return proto.Marshal(&pb.QueryResponse{Id: req.Id})
+
+	default:
+		return nil, fmt.Errorf("[%s] %s is not supported by the embedded runner", msg.Txid, msg.Type)
+	}
+}
+
+// rangeQuery executes a range query and returns the first batch of results.
+func (r *Runner) rangeQuery(ctx *txContext, req *pb.GetStateByRange) ([]byte, error) {
+	results, err := r.ledger.GetStateRange(req.Collection, req.StartKey, req.EndKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var respMetadata *pb.QueryResponseMetadata
+	if len(req.Metadata) > 0 {
+		md := &pb.QueryMetadata{}
+		if err := proto.Unmarshal(req.Metadata, md); err != nil {
+			return nil, err
+		}
+		results, respMetadata = paginate(results, md)
+	}
+
+	return nextBatch(ctx, r.iteratorID(), results, respMetadata)
+}
+
+// paginate returns the page of results selected by the query metadata along
+// with the response metadata describing it.
+func paginate(results []*queryresult.KV, md *pb.QueryMetadata) ([]*queryresult.KV, *pb.QueryResponseMetadata) {
+	start := 0
+	if md.Bookmark != "" {
+		start = sort.Search(len(results), func(i int) bool { return results[i].Key >= md.Bookmark })
+	}
+	results = results[start:]
+
+	bookmark := ""
+	if md.PageSize > 0 && len(results) > int(md.PageSize) {
+		bookmark = results[md.PageSize].Key
+		results = results[:md.PageSize]
+	}
+	return results, &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(results)), Bookmark: bookmark}
+}
+
+// nextBatch marshals the next batch of results of the iterator with the given
+// id and retains the remaining results in the transaction context.
+func nextBatch(ctx *txContext, id string, results []*queryresult.KV, md *pb.QueryResponseMetadata) ([]byte, error) {
+	batch := results
+	if len(batch) > queryBatchSize {
+		batch = batch[:queryBatchSize]
+	}
+	remaining := results[len(batch):]
+
+	resp := &pb.QueryResponse{Id: id, HasMore: len(remaining) > 0}
+	for _, kv := range batch {
+		b, err := proto.Marshal(kv)
+		if err != nil {
+			return nil, err
+		}
+		resp.Results = append(resp.Results, &pb.QueryResultBytes{ResultBytes: b})
+	}
+	if md != nil {
+		b, err := proto.Marshal(md)
+		if err != nil {
+			return nil, err
+		}
+		resp.Metadata = b
+	}
+
+	if resp.HasMore {
+		ctx.iterators[id] = remaining
+	} else {
+		delete(ctx.iterators, id)
+	}
+	return proto.Marshal(resp)
+}
@@ -0,0 +1,172 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+)
+
+// ErrPeerStopped is returned when waiting for a chaincode on a stopped
+// InMemoryPeer.
+var ErrPeerStopped = errors.New("in-memory peer stopped")
+
+// InMemoryPeer is a minimal peer serving the chaincode support service over
+// gRPC, without TLS, for chaincode started with shim.Start in another
+// process or goroutine. Transactions are executed against the chaincode
+// with the Runner returned by WaitForChaincode, and committed to the
+// ledger of the peer. It lets examples and demos run the full registration
+// and invocation flows of a chaincode without a Fabric network:
+//
+//	peer, err := embedded.NewInMemoryPeer("127.0.0.1:7052", embedded.NewMemoryLedger())
+//	...
+//	// CORE_CHAINCODE_ID_NAME=mycc CORE_PEER_TLS_ENABLED=false \
+//	//     go run ./chaincode -peer.address 127.0.0.1:7052
+//	runner, err := peer.WaitForChaincode("mycc", time.Minute)
+//	...
+//	result, err := runner.Invoke(embedded.Transaction{ChannelID: "ch", TxID: "tx1", Args: args})
+//
+// The peer does not check the identity of the chaincode and is only meant
+// for development.
+type InMemoryPeer struct {
+	ledger   Ledger
+	listener net.Listener
+	server   *grpc.Server
+
+	mutex   sync.Mutex
+	runners map[string]*Runner
+	// changed is closed and replaced when a chaincode registers and when
+	// the peer stops.
+	changed chan struct{}
+	stopped bool
+}
+
+// NewInMemoryPeer returns an InMemoryPeer listening on address, such as
+// "127.0.0.1:0" for a port chosen by the system, and committing to ledger.
+func NewInMemoryPeer(address string, ledger Ledger) (*InMemoryPeer, error) {
+	if ledger == nil {
+		return nil, errors.New("ledger must not be nil")
+	}
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %s", address, err)
+	}
+	p := &InMemoryPeer{
+		ledger:   ledger,
+		listener: listener,
+		server:   grpc.NewServer(),
+		runners:  map[string]*Runner{},
+		changed:  make(chan struct{}),
+	}
+	pb.RegisterChaincodeSupportServer(p.server, p)
+	go p.server.Serve(listener)
+	return p, nil
+}
+
+// Address returns the address the peer listens on, to pass to the
+// chaincode with the peer.address flag.
+func (p *InMemoryPeer) Address() string {
+	return p.listener.Addr().String()
+}
+
+// Register implements the chaincode support service: it registers the
+// chaincode and serves its requests until the chaincode disconnects or its
+// Runner is stopped.
+func (p *InMemoryPeer) Register(stream pb.ChaincodeSupport_RegisterServer) error {
+	quit := make(chan struct{})
+	var once sync.Once
+	r := newRunner(p.ledger, stream, func() { once.Do(func() { close(quit) }) })
+
+	id, err := r.register()
+	if err != nil {
+		return err
+	}
+	if err := p.add(id.Name, r); err != nil {
+		return err
+	}
+	defer p.remove(id.Name, r)
+
+	served := make(chan struct{})
+	go func() {
+		r.serve()
+		close(served)
+	}()
+	select {
+	case <-quit:
+		r.err = errors.New("runner stopped")
+	case <-served:
+		r.err = errors.New("chaincode disconnected")
+	}
+	close(r.stopped)
+	return nil
+}
+
+func (p *InMemoryPeer) add(name string, r *Runner) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.stopped {
+		return ErrPeerStopped
+	}
+	if _, ok := p.runners[name]; ok {
+		return fmt.Errorf("chaincode %s is already registered", name)
+	}
+	p.runners[name] = r
+	close(p.changed)
+	p.changed = make(chan struct{})
+	return nil
+}
+
+func (p *InMemoryPeer) remove(name string, r *Runner) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.runners[name] == r {
+		delete(p.runners, name)
+	}
+}
+
+// WaitForChaincode returns the Runner of the chaincode registered with
+// name, waiting up to timeout for it to register.
+func (p *InMemoryPeer) WaitForChaincode(name string, timeout time.Duration) (*Runner, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+	for {
+		p.mutex.Lock()
+		r, stopped, changed := p.runners[name], p.stopped, p.changed
+		p.mutex.Unlock()
+		switch {
+		case r != nil:
+			return r, nil
+		case stopped:
+			return nil, ErrPeerStopped
+		}
+
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return nil, fmt.Errorf("chaincode %s did not register within %s", name, timeout)
+		}
+	}
+}
+
+// Stop disconnects the chaincodes and stops listening.
+func (p *InMemoryPeer) Stop() {
+	p.mutex.Lock()
+	p.stopped = true
+	close(p.changed)
+	p.changed = make(chan struct{})
+	runners := p.runners
+	p.runners = map[string]*Runner{}
+	p.mutex.Unlock()
+
+	for _, r := range runners {
+		r.Stop()
+	}
+	p.server.Stop()
+}
@@ -0,0 +1,64 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"flag"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInMemoryPeer(t *testing.T) {
+	_, err := NewInMemoryPeer("127.0.0.1:0", nil)
+	assert.EqualError(t, err, "ledger must not be nil")
+
+	ledger := NewMemoryLedger()
+	peer, err := NewInMemoryPeer("127.0.0.1:0", ledger)
+	require.NoError(t, err)
+	defer peer.Stop()
+
+	_, err = peer.WaitForChaincode("kv", 10*time.Millisecond)
+	assert.EqualError(t, err, "chaincode kv did not register within 10ms")
+
+	// start the chaincode as its main function would
+	os.Setenv("CORE_CHAINCODE_ID_NAME", "kv")
+	os.Setenv("CORE_PEER_TLS_ENABLED", "false")
+	defer os.Unsetenv("CORE_CHAINCODE_ID_NAME")
+	defer os.Unsetenv("CORE_PEER_TLS_ENABLED")
+	require.NoError(t, flag.Set("peer.address", peer.Address()))
+	defer flag.Set("peer.address", "")
+	started := make(chan error, 1)
+	go func() { started <- shim.Start(kvChaincode{}) }()
+
+	r, err := peer.WaitForChaincode("kv", 10*time.Second)
+	require.NoError(t, err)
+
+	result, err := r.Init(Transaction{ChannelID: "channel", TxID: "init"})
+	require.NoError(t, err)
+	assert.Equal(t, int32(shim.OK), result.Response.Status)
+	assert.Equal(t, int32(shim.OK), invoke(t, r, "tx1", "put", "a", "1").Response.Status)
+	assert.Equal(t, []byte("1"), invoke(t, r, "tx2", "get", "a").Response.Payload)
+	value, err := ledger.GetState("", "a")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), value)
+
+	r.Stop()
+	select {
+	case err := <-started:
+		assert.Error(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("chaincode did not exit after its runner stopped")
+	}
+	_, err = r.Invoke(Transaction{ChannelID: "channel", TxID: "tx3"})
+	assert.Error(t, err)
+
+	peer.Stop()
+	_, err = peer.WaitForChaincode("kv", time.Second)
+	assert.Equal(t, ErrPeerStopped, err)
+}
@@ -0,0 +1,76 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package embedded
+
+import (
+	"io"
+	"sync"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Stream is one end of an in-memory chaincode stream. It implements
+// shim.PeerChaincodeStream.
+type Stream struct {
+	in         <-chan *pb.ChaincodeMessage
+	out        chan<- *pb.ChaincodeMessage
+	sendClosed chan struct{}
+	recvClosed <-chan struct{}
+	closeOnce  sync.Once
+}
+
+// NewStreamPair returns two connected in-memory streams. Messages sent on
+// one stream are received from the other. Once a stream has been closed
+// with CloseSend, the other stream receives io.EOF.
+func NewStreamPair() (*Stream, *Stream) {
+	aToB := make(chan *pb.ChaincodeMessage, 16)
+	bToA := make(chan *pb.ChaincodeMessage, 16)
+	aClosed := make(chan struct{})
+	bClosed := make(chan struct{})
+
+	a := &Stream{in: bToA, out: aToB, sendClosed: aClosed, recvClosed: bClosed}
+	b := &Stream{in: aToB, out: bToA, sendClosed: bClosed, recvClosed: aClosed}
+	return a, b
+}
+
+// Send sends a message to the other end of the stream.
+func (s *Stream) Send(msg *pb.ChaincodeMessage) error {
+	select {
+	case <-s.sendClosed:
+		return io.ErrClosedPipe
+	case <-s.recvClosed:
+		return io.ErrClosedPipe
+	default:
+	}
+
+	select {
+	case s.out <- msg:
+		return nil
+	case <-s.recvClosed:
+		return io.ErrClosedPipe
+	}
+}
+
+// Recv receives the next message from the other end of the stream. It
+// returns io.EOF once the other end has been closed.
+func (s *Stream) Recv() (*pb.ChaincodeMessage, error) {
+	select {
+	case msg := <-s.in:
+		return msg, nil
+	case <-s.recvClosed:
+		// deliver messages sent before the other end was closed
+		select {
+		case msg := <-s.in:
+			return msg, nil
+		default:
+			return nil, io.EOF
+		}
+	}
+}
+
+// CloseSend closes the stream for sending.
+func (s *Stream) CloseSend() error {
+	s.closeOnce.Do(func() { close(s.sendClosed) })
+	return nil
+}
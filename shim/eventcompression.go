@@ -0,0 +1,43 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/eventpayload"
+)
+
+// WithEventCompression makes SetEvent compress the payloads of at least
+// minSize bytes with gzip, in the envelope of the eventpayload package,
+// to reduce the size of the blocks holding the events. Clients decode the
+// payloads with eventpayload.Decode, which also returns uncompressed
+// payloads as they are.
+//
+// The compression is deterministic for a given chaincode binary, but
+// endorsers running binaries built with different Go releases may compress
+// differently and fail to agree on the event, so chaincode enabling
+// compression must be built once, for example as an external service.
+func WithEventCompression(minSize int) Option {
+	return func(h *Handler) error {
+		if minSize <= 0 {
+			return fmt.Errorf("event compression size must be positive, got %d", minSize)
+		}
+		h.eventCompression = minSize
+		return nil
+	}
+}
+
+// eventPayload returns payload compressed if event compression is enabled
+// and payload is at least the compression size.
+func (s *ChaincodeStub) eventPayload(payload []byte) ([]byte, error) {
+	if s.handler == nil || s.handler.eventCompression <= 0 || len(payload) < s.handler.eventCompression {
+		return payload, nil
+	}
+	compressed, err := eventpayload.Compress(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compress event payload: %s", err)
+	}
+	return compressed, nil
+}
@@ -0,0 +1,43 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/eventpayload"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithEventCompression(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithEventCompression(0))
+	assert.EqualError(t, err, "event compression size must be positive, got 0")
+
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithEventCompression(100))
+	require.NoError(t, err)
+	assert.Equal(t, 100, h.eventCompression)
+	assert.Equal(t, "100", h.features()["event_compression"])
+}
+
+func TestEventCompression(t *testing.T) {
+	large := bytes.Repeat([]byte("payload "), 20)
+
+	stub := &ChaincodeStub{handler: &Handler{eventCompression: len(large)}}
+	require.NoError(t, stub.SetEvent("small", large[:len(large)-1]))
+	assert.Equal(t, large[:len(large)-1], stub.chaincodeEvent.Payload)
+
+	require.NoError(t, stub.SetEvent("large", large))
+	assert.Equal(t, "large", stub.chaincodeEvent.EventName)
+	assert.True(t, eventpayload.IsCompressed(stub.chaincodeEvent.Payload))
+	assert.Less(t, len(stub.chaincodeEvent.Payload), len(large))
+	decoded, err := eventpayload.Decode(stub.chaincodeEvent.Payload)
+	require.NoError(t, err)
+	assert.Equal(t, large, decoded)
+
+	stub = &ChaincodeStub{handler: &Handler{}}
+	require.NoError(t, stub.SetEvent("large", large))
+	assert.Equal(t, large, stub.chaincodeEvent.Payload)
+}
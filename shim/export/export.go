@@ -0,0 +1,269 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package export writes the keys of a namespace to a stream for off-chain
+// processing, and reads them back.
+//
+// An export is a sequence of records, one per key, in key order. Exports
+// are read page by page and can be split across several transactions: an
+// export stopped by WithMaxRecords returns the bookmark to pass to
+// WithBookmark to continue where it stopped, and the records of the parts
+// can be concatenated.
+package export
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Format is the encoding of the records of an export.
+type Format int
+
+const (
+	// JSONL encodes each record as a JSON object {"key": ..., "value": ...}
+	// on its own line, with the value base64 encoded.
+	JSONL Format = iota
+	// Protobuf encodes each record as a queryresult.KV message prefixed by
+	// its length as a varint.
+	Protobuf
+)
+
+// DefaultPageSize is the number of keys read per page unless WithPageSize
+// is used.
+const DefaultPageSize = 100
+
+// ChaincodeStubInterface is the subset of shim.ChaincodeStubInterface used
+// by ExportNamespace.
+type ChaincodeStubInterface interface {
+	GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error)
+}
+
+// Result describes the records written by ExportNamespace.
+type Result struct {
+	// Records is the number of records written.
+	Records int
+	// Bookmark is the bookmark to continue the export from, or "" if the
+	// export is complete.
+	Bookmark string
+}
+
+type options struct {
+	pageSize   int32
+	bookmark   string
+	maxRecords int
+}
+
+// Option configures ExportNamespace.
+type Option func(*options) error
+
+// WithPageSize sets the number of keys read per page.
+func WithPageSize(pageSize int32) Option {
+	return func(o *options) error {
+		if pageSize <= 0 {
+			return fmt.Errorf("page size must be positive, got %d", pageSize)
+		}
+		o.pageSize = pageSize
+		return nil
+	}
+}
+
+// WithBookmark continues an export from the bookmark returned by a
+// previous one.
+func WithBookmark(bookmark string) Option {
+	return func(o *options) error {
+		o.bookmark = bookmark
+		return nil
+	}
+}
+
+// WithMaxRecords stops the export at the end of the first page that brings
+// the number of records written to at least maxRecords.
+func WithMaxRecords(maxRecords int) Option {
+	return func(o *options) error {
+		if maxRecords <= 0 {
+			return fmt.Errorf("max records must be positive, got %d", maxRecords)
+		}
+		o.maxRecords = maxRecords
+		return nil
+	}
+}
+
+// ExportNamespace writes the keys starting with prefix and their values to
+// w in format. An empty prefix exports all the simple keys of the
+// namespace. Composite keys are not exported.
+func ExportNamespace(stub ChaincodeStubInterface, prefix string, w io.Writer, format Format, opts ...Option) (*Result, error) {
+	o := &options{pageSize: DefaultPageSize}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+	enc, err := NewEncoder(w, format)
+	if err != nil {
+		return nil, err
+	}
+
+	startKey, endKey := prefix, ""
+	if prefix != "" {
+		endKey = prefix + string(utf8.MaxRune)
+	}
+
+	result := &Result{}
+	bookmark := o.bookmark
+	for {
+		iter, metadata, err := stub.GetStateByRangeWithPagination(startKey, endKey, o.pageSize, bookmark)
+		if err != nil {
+			return nil, err
+		}
+		n, err := encodeAll(enc, iter)
+		if err != nil {
+			return nil, err
+		}
+		result.Records += n
+
+		next := metadata.GetBookmark()
+		if n == 0 || next == "" {
+			return result, enc.Flush()
+		}
+		if next == bookmark {
+			return nil, fmt.Errorf("query returned bookmark %s for the page it started", bookmark)
+		}
+		bookmark = next
+		if o.maxRecords > 0 && result.Records >= o.maxRecords {
+			result.Bookmark = bookmark
+			return result, enc.Flush()
+		}
+	}
+}
+
+func encodeAll(enc *Encoder, iter shim.StateQueryIteratorInterface) (int, error) {
+	defer iter.Close()
+
+	n := 0
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return 0, err
+		}
+		if err := enc.Encode(kv); err != nil {
+			return 0, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+type jsonRecord struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// Encoder writes records in a format.
+type Encoder struct {
+	w      *bufio.Writer
+	format Format
+}
+
+// NewEncoder returns an encoder writing records to w in format. Flush must
+// be called once all the records are encoded.
+func NewEncoder(w io.Writer, format Format) (*Encoder, error) {
+	if format != JSONL && format != Protobuf {
+		return nil, fmt.Errorf("unknown export format %d", format)
+	}
+	return &Encoder{w: bufio.NewWriter(w), format: format}, nil
+}
+
+// Encode writes the record of kv.
+func (e *Encoder) Encode(kv *queryresult.KV) error {
+	var record []byte
+	var err error
+	switch e.format {
+	case JSONL:
+		record, err = json.Marshal(jsonRecord{Key: kv.Key, Value: kv.Value})
+		record = append(record, '\n')
+	default:
+		var msg []byte
+		msg, err = proto.Marshal(&queryresult.KV{Key: kv.Key, Value: kv.Value})
+		record = append(proto.EncodeVarint(uint64(len(msg))), msg...)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to encode key %s: %s", kv.Key, err)
+	}
+	_, err = e.w.Write(record)
+	return err
+}
+
+// Flush writes the buffered records to the underlying writer.
+func (e *Encoder) Flush() error {
+	return e.w.Flush()
+}
+
+// Decoder reads records in a format.
+type Decoder struct {
+	r      *bufio.Reader
+	format Format
+}
+
+// NewDecoder returns a decoder reading records from r in format.
+func NewDecoder(r io.Reader, format Format) (*Decoder, error) {
+	if format != JSONL && format != Protobuf {
+		return nil, fmt.Errorf("unknown export format %d", format)
+	}
+	return &Decoder{r: bufio.NewReader(r), format: format}, nil
+}
+
+// Decode returns the next record, or io.EOF if there are no more records.
+func (d *Decoder) Decode() (*queryresult.KV, error) {
+	if d.format == JSONL {
+		return d.decodeJSON()
+	}
+	return d.decodeProto()
+}
+
+func (d *Decoder) decodeJSON() (*queryresult.KV, error) {
+	for {
+		line, err := d.r.ReadBytes('\n')
+		if len(line) == 0 || (len(line) == 1 && line[0] == '\n') {
+			if err == nil {
+				continue
+			}
+			return nil, err
+		}
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		var record jsonRecord
+		if err := json.Unmarshal(line, &record); err != nil {
+			return nil, fmt.Errorf("failed to decode record: %s", err)
+		}
+		return &queryresult.KV{Key: record.Key, Value: record.Value}, nil
+	}
+}
+
+func (d *Decoder) decodeProto() (*queryresult.KV, error) {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return nil, err
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(d.r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	kv := &queryresult.KV{}
+	if err := proto.Unmarshal(msg, kv); err != nil {
+		return nil, fmt.Errorf("failed to decode record: %s", err)
+	}
+	return kv, nil
+}
@@ -0,0 +1,107 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package export_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/export"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStub(t *testing.T) *shimtest.MockStub {
+	stub := shimtest.NewMockStub("export", nil)
+	stub.MockTransactionStart("seed")
+	for _, key := range []string{"asset1", "asset2", "asset3", "asset4", "asset5", "other"} {
+		require.NoError(t, stub.PutState(key, []byte("value of "+key)))
+	}
+	stub.MockTransactionEnd("seed")
+	return stub
+}
+
+func TestExportNamespace(t *testing.T) {
+	tests := []struct {
+		name   string
+		format export.Format
+	}{
+		{"JSONL", export.JSONL},
+		{"Protobuf", export.Protobuf},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := newStub(t)
+
+			var buf bytes.Buffer
+			result, err := export.ExportNamespace(stub, "asset", &buf, tt.format, export.WithPageSize(2))
+			require.NoError(t, err)
+			assert.Equal(t, &export.Result{Records: 5}, result)
+
+			imported := shimtest.NewMockStub("import", nil)
+			n, err := imported.Import(&buf, tt.format)
+			require.NoError(t, err)
+			assert.Equal(t, 5, n)
+			for _, key := range []string{"asset1", "asset2", "asset3", "asset4", "asset5"} {
+				assert.Equal(t, []byte("value of "+key), imported.State[key])
+			}
+			assert.NotContains(t, imported.State, "other")
+		})
+	}
+}
+
+func TestExportNamespaceJSONL(t *testing.T) {
+	stub := newStub(t)
+
+	var buf bytes.Buffer
+	result, err := export.ExportNamespace(stub, "other", &buf, export.JSONL)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Records)
+	assert.Equal(t, `{"key":"other","value":"dmFsdWUgb2Ygb3RoZXI="}`+"\n", buf.String())
+}
+
+func TestExportNamespaceResume(t *testing.T) {
+	stub := newStub(t)
+
+	var buf bytes.Buffer
+	result, err := export.ExportNamespace(stub, "", &buf, export.Protobuf, export.WithPageSize(2), export.WithMaxRecords(3))
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Records: 4, Bookmark: "asset5"}, result)
+
+	result, err = export.ExportNamespace(stub, "", &buf, export.Protobuf, export.WithPageSize(2), export.WithBookmark(result.Bookmark))
+	require.NoError(t, err)
+	assert.Equal(t, &export.Result{Records: 2}, result)
+
+	imported := shimtest.NewMockStub("import", nil)
+	n, err := imported.Import(&buf, export.Protobuf)
+	require.NoError(t, err)
+	assert.Equal(t, 6, n)
+	assert.Equal(t, stub.State, imported.State)
+}
+
+func TestExportNamespaceErrors(t *testing.T) {
+	stub := newStub(t)
+
+	_, err := export.ExportNamespace(stub, "", &bytes.Buffer{}, export.Format(7))
+	assert.EqualError(t, err, "unknown export format 7")
+	_, err = export.ExportNamespace(stub, "", &bytes.Buffer{}, export.JSONL, export.WithPageSize(0))
+	assert.EqualError(t, err, "page size must be positive, got 0")
+	_, err = export.ExportNamespace(stub, "", &bytes.Buffer{}, export.JSONL, export.WithMaxRecords(-1))
+	assert.EqualError(t, err, "max records must be positive, got -1")
+}
+
+func TestDecodeErrors(t *testing.T) {
+	dec, err := export.NewDecoder(strings.NewReader("{\"key\":\n"), export.JSONL)
+	require.NoError(t, err)
+	_, err = dec.Decode()
+	assert.Error(t, err)
+
+	dec, err = export.NewDecoder(bytes.NewReader([]byte{5, 'a'}), export.Protobuf)
+	require.NoError(t, err)
+	_, err = dec.Decode()
+	assert.EqualError(t, err, "unexpected EOF")
+}
@@ -0,0 +1,66 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// MessageHandler handles a message of a type registered with
+// WithMessageHandler. It returns the message sent back to the peer, or nil
+// to send nothing. An error is sent to the peer as an ERROR message with
+// the txid and channel of msg.
+type MessageHandler func(msg *pb.ChaincodeMessage) (*pb.ChaincodeMessage, error)
+
+// builtinMessageTypes are the message types handled by the shim itself,
+// which cannot be registered with WithMessageHandler.
+var builtinMessageTypes = map[pb.ChaincodeMessage_Type]bool{
+	pb.ChaincodeMessage_REGISTERED:  true,
+	pb.ChaincodeMessage_READY:       true,
+	pb.ChaincodeMessage_INIT:        true,
+	pb.ChaincodeMessage_TRANSACTION: true,
+	pb.ChaincodeMessage_RESPONSE:    true,
+	pb.ChaincodeMessage_ERROR:       true,
+	pb.ChaincodeMessage_KEEPALIVE:   true,
+}
+
+// WithMessageHandler registers handler for the messages of msgType received
+// from the peer once the chaincode is ready, so that message types the shim
+// does not know yet can be prototyped without changing the shim. The type
+// may be a value not defined by the protos, such as
+// pb.ChaincodeMessage_Type(100). Each message is handled in its own
+// goroutine. The types handled by the shim itself cannot be registered.
+func WithMessageHandler(msgType pb.ChaincodeMessage_Type, handler MessageHandler) Option {
+	return func(h *Handler) error {
+		if handler == nil {
+			return fmt.Errorf("message handler for %s must not be nil", msgType)
+		}
+		if builtinMessageTypes[msgType] {
+			return fmt.Errorf("message type %s is handled by the shim", msgType)
+		}
+		if _, ok := h.extensions[msgType]; ok {
+			return fmt.Errorf("message handler for %s is already registered", msgType)
+		}
+		if h.extensions == nil {
+			h.extensions = map[pb.ChaincodeMessage_Type]MessageHandler{}
+		}
+		h.extensions[msgType] = handler
+		return nil
+	}
+}
+
+// handleExtension calls handler with msg and sends its response, if any.
+func (h *Handler) handleExtension(handler MessageHandler, msg *pb.ChaincodeMessage, errc chan<- error) {
+	resp, err := handler(msg)
+	if err != nil {
+		h.logHandlerError(msg, err)
+		resp = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: msg.Txid, ChannelId: msg.ChannelId}
+	}
+	if resp == nil {
+		return
+	}
+	h.serialSendAsync(resp, errc)
+}
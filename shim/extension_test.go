@@ -0,0 +1,83 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithMessageHandlerInvalid(t *testing.T) {
+	echo := func(msg *peerpb.ChaincodeMessage) (*peerpb.ChaincodeMessage, error) { return msg, nil }
+
+	var tests = []struct {
+		name        string
+		opts        []Option
+		expectedErr string
+	}{
+		{
+			name:        "Nil Handler",
+			opts:        []Option{WithMessageHandler(100, nil)},
+			expectedErr: "message handler for 100 must not be nil",
+		},
+		{
+			name:        "Builtin Type",
+			opts:        []Option{WithMessageHandler(peerpb.ChaincodeMessage_TRANSACTION, echo)},
+			expectedErr: "message type TRANSACTION is handled by the shim",
+		},
+		{
+			name:        "Duplicate",
+			opts:        []Option{WithMessageHandler(100, echo), WithMessageHandler(100, echo)},
+			expectedErr: "message handler for 100 is already registered",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newChaincodeHandler(nil, &mockChaincode{}, test.opts...)
+			assert.EqualError(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestMessageHandler(t *testing.T) {
+	const experimental = peerpb.ChaincodeMessage_Type(100)
+
+	chatStream := &mock.PeerChaincodeStream{}
+	sent := make(chan *peerpb.ChaincodeMessage, 1)
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		sent <- msg
+		return nil
+	}
+
+	handler := func(msg *peerpb.ChaincodeMessage) (*peerpb.ChaincodeMessage, error) {
+		if string(msg.Payload) == "fail" {
+			return nil, errors.New("unsupported request")
+		}
+		return &peerpb.ChaincodeMessage{Type: experimental, Payload: []byte("pong"), Txid: msg.Txid, ChannelId: msg.ChannelId}, nil
+	}
+	h, err := newChaincodeHandler(chatStream, &mockChaincode{}, WithMessageHandler(experimental, handler), WithLogger(&recordingLogger{}))
+	require.NoError(t, err)
+	h.state = ready
+
+	errc := make(chan error, 1)
+	err = h.handleMessage(&peerpb.ChaincodeMessage{Type: experimental, Payload: []byte("ping"), Txid: "txid", ChannelId: "channel"}, errc)
+	require.NoError(t, err)
+	assert.NoError(t, <-errc)
+	assert.Equal(t, &peerpb.ChaincodeMessage{Type: experimental, Payload: []byte("pong"), Txid: "txid", ChannelId: "channel"}, <-sent)
+
+	err = h.handleMessage(&peerpb.ChaincodeMessage{Type: experimental, Payload: []byte("fail"), Txid: "txid", ChannelId: "channel"}, errc)
+	require.NoError(t, err)
+	assert.NoError(t, <-errc)
+	assert.Equal(t, &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_ERROR, Payload: []byte("unsupported request"), Txid: "txid", ChannelId: "channel"}, <-sent)
+
+	err = h.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_Type(101), Txid: "txid"}, errc)
+	assert.EqualError(t, err, "[txid] Chaincode h cannot handle message (101) while in state: ready")
+}
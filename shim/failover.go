@@ -0,0 +1,162 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PeerHealth is the health of one of the peers set by the peer.address
+// flag.
+type PeerHealth struct {
+	Address string
+	// Active is set for the peer the chaincode is registered with.
+	Active bool
+	// Failures is the number of consecutive failures to connect to or
+	// stay registered with the peer.
+	Failures int
+	// LastError is the error of the last failure, if any, and LastFailure
+	// its time.
+	LastError   error
+	LastFailure time.Time
+}
+
+// WithPeerFailoverCallback sets a function called with the address of the
+// active peer and the health of all the peers whenever the chaincode
+// registers with one of the peers of a peer.address flag listing several,
+// or registers again after reconnecting as configured by WithReconnect.
+func WithPeerFailoverCallback(callback func(active string, health []PeerHealth)) Option {
+	return func(h *Handler) error {
+		if callback == nil {
+			return errors.New("peer failover callback must not be nil")
+		}
+		h.onActivePeer = callback
+		return nil
+	}
+}
+
+// peerAddresses returns the addresses of the peer.address flag, which may
+// list several separated by commas.
+func peerAddresses() []string {
+	var addresses []string
+	for _, address := range strings.Split(*peerAddress, ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
+
+// peerFailover tracks the health of the peers the chaincode fails over
+// between.
+type peerFailover struct {
+	mutex  sync.Mutex
+	health []PeerHealth
+}
+
+func newPeerFailover(addresses []string) *peerFailover {
+	f := &peerFailover{}
+	for _, address := range addresses {
+		f.health = append(f.health, PeerHealth{Address: address})
+	}
+	return f
+}
+
+// activate records that the chaincode registered with the peer i and
+// returns the health of the peers.
+func (f *peerFailover) activate(i int) []PeerHealth {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for j := range f.health {
+		f.health[j].Active = j == i
+	}
+	f.health[i].Failures = 0
+	return append([]PeerHealth(nil), f.health...)
+}
+
+// fail records the failure of the peer i.
+func (f *peerFailover) fail(i int, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.health[i].Active = false
+	f.health[i].Failures++
+	f.health[i].LastError = err
+	f.health[i].LastFailure = time.Now()
+}
+
+// startWithFailover registers the chaincode with the first of addresses,
+// and with the next one whenever connecting to the peer fails or its
+// stream ends. Once every peer failed in turn without the chaincode
+// registering with any of them, it starts over after the backoff of the
+// reconnect policy of primary, or returns the last error if there is none
+// or its attempts are exhausted.
+func startWithFailover(addresses []string, dial func(address string) (PeerChaincodeStream, error), chaincodename string, cc Chaincode, primary *Handler, opts ...Option) error {
+	f := newPeerFailover(addresses)
+	handler := primary
+	var err error
+	for i, failures, attempts := 0, 0, 0; ; i = (i + 1) % len(addresses) {
+		if failures == len(addresses) {
+			attempts++
+			if primary.reconnect == nil || primary.reconnect.MaxAttempts > 0 && attempts > primary.reconnect.MaxAttempts {
+				return err
+			}
+			backoff := primary.reconnect.backoff(attempts)
+			primary.logf("reconnecting to peers in %s (attempt %d)", backoff, attempts)
+			time.Sleep(backoff)
+			failures = 0
+		}
+
+		if handler == nil {
+			if handler, err = newChaincodeHandler(nil, cc, opts...); err != nil {
+				return fmt.Errorf("invalid shim option: %s", err)
+			}
+		}
+		address := addresses[i]
+		getStream := func(string) (PeerChaincodeStream, error) {
+			stream, err := dial(address)
+			if err != nil {
+				return nil, fmt.Errorf("failed to connect to peer %s: %s", address, err)
+			}
+			return stream, nil
+		}
+
+		registered := make(chan struct{})
+		handler.registered = registered
+		done := make(chan struct{})
+		reported := make(chan struct{})
+		go func(i int) {
+			defer close(reported)
+			select {
+			case <-registered:
+				health := f.activate(i)
+				primary.logf("registered with peer %s", health[i].Address)
+				if primary.onActivePeer != nil {
+					primary.onActivePeer(health[i].Address, health)
+				}
+			case <-done:
+			}
+		}(i)
+
+		err = serve(getStream, chaincodename, cc, handler, opts...)
+		close(done)
+		<-reported
+		select {
+		case <-registered:
+			failures, attempts = 0, 0
+		default:
+		}
+		f.fail(i, err)
+		failures++
+		primary.logf("peer %s failed: %s", address, err)
+		// the stream may have failed before chat could unregister handler
+		handler.unregister()
+		handler = nil
+	}
+}
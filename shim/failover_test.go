@@ -0,0 +1,111 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeerAddresses(t *testing.T) {
+	defer func(original *string) { peerAddress = original }(peerAddress)
+
+	var tests = []struct {
+		flag      string
+		addresses []string
+	}{
+		{flag: "", addresses: nil},
+		{flag: "peer0:7052", addresses: []string{"peer0:7052"}},
+		{flag: "peer0:7052,peer1:7052", addresses: []string{"peer0:7052", "peer1:7052"}},
+		{flag: " peer0:7052 , ,peer1:7052,", addresses: []string{"peer0:7052", "peer1:7052"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.flag, func(t *testing.T) {
+			flag := test.flag
+			peerAddress = &flag
+			assert.Equal(t, test.addresses, peerAddresses())
+		})
+	}
+}
+
+func TestWithPeerFailoverCallback(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithPeerFailoverCallback(nil))
+	assert.EqualError(t, err, "peer failover callback must not be nil")
+}
+
+func TestStartWithFailover(t *testing.T) {
+	registered := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}
+	refused := errors.New("connection refused")
+
+	var dialed []string
+	var stream *pipeStream
+	dial := func(address string) (PeerChaincodeStream, error) {
+		dialed = append(dialed, address)
+		if address != "peer1" {
+			return nil, refused
+		}
+		stream = newPipeStream(registered)
+		return stream, nil
+	}
+
+	var actives []string
+	var health []PeerHealth
+	callback := func(active string, h []PeerHealth) {
+		actives = append(actives, active)
+		health = h
+		// the stream to the active peer ends
+		stream.CloseSend()
+	}
+
+	logger := &recordingLogger{}
+	opts := []Option{WithLogger(logger), WithPeerFailoverCallback(callback)}
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, opts...)
+	require.NoError(t, err)
+
+	err = startWithFailover([]string{"peer0", "peer1", "peer2"}, dial, "cc", &mockChaincode{}, h, opts...)
+	assert.EqualError(t, err, "failed to connect to peer peer0: connection refused")
+	assert.Equal(t, []string{"peer0", "peer1", "peer2", "peer0"}, dialed, "every peer fails in turn after the last registration")
+	assert.Equal(t, []string{"peer1"}, actives)
+
+	require.Len(t, health, 3)
+	assert.Equal(t, PeerHealth{Address: "peer1", Active: true}, health[1])
+	assert.False(t, health[0].Active)
+	assert.Equal(t, 1, health[0].Failures)
+	assert.EqualError(t, health[0].LastError, "failed to connect to peer peer0: connection refused")
+	assert.False(t, health[0].LastFailure.IsZero())
+	assert.Equal(t, PeerHealth{Address: "peer2"}, health[2])
+
+	assert.Equal(t, []string{
+		"peer peer0 failed: failed to connect to peer peer0: connection refused",
+		"registered with peer peer1",
+		"peer peer1 failed: received EOF, ending chaincode stream",
+		"peer peer2 failed: failed to connect to peer peer2: connection refused",
+		"peer peer0 failed: failed to connect to peer peer0: connection refused",
+	}, logger.lines)
+}
+
+func TestStartWithFailoverInvalidOption(t *testing.T) {
+	dial := func(address string) (PeerChaincodeStream, error) {
+		return nil, errors.New("connection refused")
+	}
+	handlers := 0
+	singleHandler := func(*Handler) error {
+		handlers++
+		if handlers > 1 {
+			return errors.New("already in use")
+		}
+		return nil
+	}
+	opts := []Option{WithLogger(&recordingLogger{}), singleHandler}
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, opts...)
+	require.NoError(t, err)
+
+	err = startWithFailover([]string{"peer0", "peer1"}, dial, "cc", &mockChaincode{}, h, opts...)
+	assert.EqualError(t, err, "invalid shim option: already in use")
+}
@@ -0,0 +1,141 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+const (
+	// maxTrackedFunctions is the number of distinct function names whose
+	// statistics are kept separately, so that clients calling made up
+	// functions cannot grow the statistics without bound.
+	maxTrackedFunctions = 256
+	// OtherFunctions is the name under which the calls of the functions
+	// beyond the first 256 distinct ones are counted.
+	OtherFunctions = "(other)"
+)
+
+// FunctionStats are the execution statistics of a chaincode function.
+type FunctionStats struct {
+	// Invocations is the number of calls of the function.
+	Invocations int64 `json:"invocations"`
+	// Errors is the number of calls returning an error response.
+	Errors int64 `json:"errors"`
+	// ErrorRate is the fraction of the calls returning an error response.
+	ErrorRate float64 `json:"error_rate"`
+	// AverageLatency and MaxLatency are the average and maximum time spent
+	// in the chaincode, in nanoseconds.
+	AverageLatency time.Duration `json:"average_latency_ns"`
+	MaxLatency     time.Duration `json:"max_latency_ns"`
+	// QueryBytes and MaxQueryBytes are the total and per call maximum
+	// bytes of query results charged to the budget set with
+	// WithQueryBudget.
+	QueryBytes    int64 `json:"query_bytes,omitempty"`
+	MaxQueryBytes int64 `json:"max_query_bytes,omitempty"`
+	// BudgetExceeded is the number of calls whose queries returned
+	// ErrBudgetExceeded.
+	BudgetExceeded int64 `json:"budget_exceeded,omitempty"`
+
+	total time.Duration
+}
+
+// WithFunctionStats accumulates the invocation count, latency and error
+// rate of each function of the chaincode, named by the first argument of
+// the transaction. The statistics are served by the debug server under
+// /debug/shim/state and, when queryFunction is not empty, returned as JSON
+// by the chaincode function queryFunction, which the shim answers itself
+// without calling the chaincode and which is not counted. The statistics
+// are those of the chaincode process since it started, so each peer
+// returns different ones: queryFunction must only be queried, never
+// submitted for ordering.
+func WithFunctionStats(queryFunction string) Option {
+	stats := &functionStats{queryFunction: queryFunction, functions: map[string]*FunctionStats{}}
+	return func(h *Handler) error {
+		h.functionStats = stats
+		return nil
+	}
+}
+
+// functionStats accumulates the statistics of the functions of a
+// chaincode. It is shared by the handlers of the streams to the peer.
+type functionStats struct {
+	queryFunction string
+
+	mutex     sync.Mutex
+	functions map[string]*FunctionStats
+}
+
+// call calls the chaincode with call, unless the function of stub is the
+// query function, and records the statistics of the call.
+func (s *functionStats) call(stub *ChaincodeStub, call func() pb.Response) pb.Response {
+	function := ""
+	if len(stub.args) > 0 {
+		function = string(stub.args[0])
+	}
+	if s.queryFunction != "" && function == s.queryFunction {
+		b, err := json.Marshal(s.snapshot())
+		if err != nil {
+			return Error(err.Error())
+		}
+		return Success(b)
+	}
+
+	start := time.Now()
+	res := call()
+	queryBytes, budgetExceeded := stub.queryBudget.usage()
+	s.record(function, time.Since(start), res.Status >= ERRORTHRESHOLD, queryBytes, budgetExceeded)
+	return res
+}
+
+func (s *functionStats) record(function string, latency time.Duration, failed bool, queryBytes int64, budgetExceeded bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	stats, ok := s.functions[function]
+	if !ok {
+		if len(s.functions) >= maxTrackedFunctions {
+			function = OtherFunctions
+		}
+		if stats, ok = s.functions[function]; !ok {
+			stats = &FunctionStats{}
+			s.functions[function] = stats
+		}
+	}
+	stats.Invocations++
+	if failed {
+		stats.Errors++
+	}
+	stats.total += latency
+	if latency > stats.MaxLatency {
+		stats.MaxLatency = latency
+	}
+	stats.QueryBytes += queryBytes
+	if queryBytes > stats.MaxQueryBytes {
+		stats.MaxQueryBytes = queryBytes
+	}
+	if budgetExceeded {
+		stats.BudgetExceeded++
+	}
+}
+
+// snapshot returns a copy of the statistics of the functions called.
+func (s *functionStats) snapshot() map[string]FunctionStats {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := make(map[string]FunctionStats, len(s.functions))
+	for function, stats := range s.functions {
+		fs := *stats
+		fs.ErrorRate = float64(fs.Errors) / float64(fs.Invocations)
+		fs.AverageLatency = fs.total / time.Duration(fs.Invocations)
+		fs.total = 0
+		snapshot[function] = fs
+	}
+	return snapshot
+}
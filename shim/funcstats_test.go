@@ -0,0 +1,137 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// functionChaincode fails the invocations of the function "fail".
+type functionChaincode struct {
+	calls int
+}
+
+func (cc *functionChaincode) Init(stub ChaincodeStubInterface) peerpb.Response {
+	return Success(nil)
+}
+
+func (cc *functionChaincode) Invoke(stub ChaincodeStubInterface) peerpb.Response {
+	cc.calls++
+	if function, _ := stub.GetFunctionAndParameters(); function == "fail" {
+		return Error("failed")
+	}
+	return Success(nil)
+}
+
+func invokeFunction(h *Handler, args ...string) peerpb.Response {
+	stub := &ChaincodeStub{}
+	for _, arg := range args {
+		stub.args = append(stub.args, []byte(arg))
+	}
+	return h.callChaincode(h.cc.Invoke, stub)
+}
+
+func TestFunctionStats(t *testing.T) {
+	cc := &functionChaincode{}
+	h, err := newChaincodeHandler(nil, cc, WithFunctionStats("shim:stats"), WithArgLimits(ArgLimits{MaxCount: 2}))
+	require.NoError(t, err)
+
+	invokeFunction(h, "get", "key")
+	invokeFunction(h, "get", "key")
+	invokeFunction(h, "fail")
+	invokeFunction(h, "get", "key", "too many")
+	invokeFunction(h)
+	assert.Equal(t, 4, cc.calls)
+
+	res := invokeFunction(h, "shim:stats")
+	assert.Equal(t, int32(OK), res.Status)
+	assert.Equal(t, 4, cc.calls, "the query function is answered by the shim")
+	var stats map[string]FunctionStats
+	require.NoError(t, json.Unmarshal(res.Payload, &stats))
+	for function, fs := range stats {
+		assert.True(t, fs.AverageLatency <= fs.MaxLatency, function)
+	}
+	assert.Equal(t, map[string]FunctionStats{
+		"get":  {Invocations: 3, Errors: 1, ErrorRate: 1.0 / 3},
+		"fail": {Invocations: 1, Errors: 1, ErrorRate: 1},
+		"":     {Invocations: 1},
+	}, clearLatencies(stats))
+
+	server := &debugServer{handlers: []*Handler{h}}
+	assert.Len(t, server.state().Functions, 3)
+	assert.Equal(t, "shim:stats", h.features()["function_stats"])
+}
+
+func TestFunctionStatsWithoutQuery(t *testing.T) {
+	cc := &functionChaincode{}
+	h, err := newChaincodeHandler(nil, cc, WithFunctionStats(""))
+	require.NoError(t, err)
+
+	invokeFunction(h, "")
+	assert.Equal(t, 1, cc.calls)
+	assert.Equal(t, map[string]FunctionStats{"": {Invocations: 1, ErrorRate: 0}}, clearLatencies(h.functionStats.snapshot()))
+}
+
+func TestFunctionStatsQueryBudget(t *testing.T) {
+	h, err := newChaincodeHandler(nil, &functionChaincode{}, WithFunctionStats(""), WithQueryBudget(100))
+	require.NoError(t, err)
+	response := func(size int) *peerpb.QueryResponse {
+		return &peerpb.QueryResponse{Results: []*peerpb.QueryResultBytes{{ResultBytes: make([]byte, size)}}}
+	}
+	query := func(sizes ...int) {
+		stub, err := newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{Args: [][]byte{[]byte("query")}}, nil)
+		require.NoError(t, err)
+		h.functionStats.call(stub, func() peerpb.Response {
+			for _, size := range sizes {
+				if err := stub.queryBudget.charge(response(size)); err != nil {
+					return Error(err.Error())
+				}
+			}
+			return Success(nil)
+		})
+	}
+
+	query(30, 40)
+	query(60, 50)
+	query()
+
+	stats := clearLatencies(h.functionStats.snapshot())
+	assert.Equal(t, FunctionStats{
+		Invocations:    3,
+		Errors:         1,
+		ErrorRate:      1.0 / 3,
+		QueryBytes:     180,
+		MaxQueryBytes:  110,
+		BudgetExceeded: 1,
+	}, stats["query"])
+}
+
+func TestFunctionStatsLimit(t *testing.T) {
+	h, err := newChaincodeHandler(nil, &functionChaincode{}, WithFunctionStats(""))
+	require.NoError(t, err)
+
+	for i := 0; i < maxTrackedFunctions+10; i++ {
+		invokeFunction(h, fmt.Sprintf("fn%d", i))
+	}
+	invokeFunction(h, "fn0")
+
+	stats := h.functionStats.snapshot()
+	assert.Len(t, stats, maxTrackedFunctions+1)
+	assert.Equal(t, int64(2), stats["fn0"].Invocations)
+	assert.Equal(t, int64(10), stats[OtherFunctions].Invocations)
+}
+
+func clearLatencies(stats map[string]FunctionStats) map[string]FunctionStats {
+	for function, fs := range stats {
+		fs.AverageLatency, fs.MaxLatency = 0, 0
+		stats[function] = fs
+	}
+	return stats
+}
@@ -0,0 +1,85 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.18
+// +build go1.18
+
+package shim
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// FuzzHandleMessage decodes the input as a chaincode message and passes it
+// to a handler in the ready state whose chaincode reads everything the stub
+// decodes from the message.
+func FuzzHandleMessage(f *testing.F) {
+	input := marshalOrPanic(&pb.ChaincodeInput{Args: [][]byte{[]byte("function"), []byte("\x00type\x00attr\x00")}})
+	seeds := []*pb.ChaincodeMessage{
+		{Type: pb.ChaincodeMessage_TRANSACTION, Txid: "txid", Payload: input},
+		// oversized
+		{Type: pb.ChaincodeMessage_TRANSACTION, Txid: "txid", Payload: make([]byte, 1025)},
+		{Type: pb.ChaincodeMessage_RESPONSE, ChannelId: "channel", Txid: "txid", Payload: make([]byte, 1025)},
+		// truncated
+		{Type: pb.ChaincodeMessage_TRANSACTION, Txid: "txid", Payload: input[:len(input)-1]},
+		{Type: pb.ChaincodeMessage_INIT, Txid: "txid", Payload: input[:len(input)-1]},
+		// invalid UTF-8
+		{Type: pb.ChaincodeMessage_TRANSACTION, Txid: "txid", Payload: marshalOrPanic(&pb.ChaincodeInput{Args: [][]byte{[]byte("\xff"), []byte("\x00\xff\x00")}})},
+		{Type: pb.ChaincodeMessage_KEEPALIVE},
+	}
+	for _, seed := range seeds {
+		f.Add(marshalOrPanic(seed))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		msg := &pb.ChaincodeMessage{}
+		if err := proto.Unmarshal(data, msg); err != nil {
+			return
+		}
+
+		h, err := newChaincodeHandler(discardStream{}, fuzzChaincode{}, WithMaxPayloadSize(1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h.state = ready
+
+		errc := make(chan error, 1)
+		if err := h.handleMessage(msg, errc); err != nil {
+			return
+		}
+		switch msg.Type {
+		case pb.ChaincodeMessage_INIT, pb.ChaincodeMessage_TRANSACTION, pb.ChaincodeMessage_KEEPALIVE:
+			// wait for the reply so the chaincode has run to completion
+			<-errc
+		}
+	})
+}
+
+type discardStream struct{}
+
+func (discardStream) Send(*pb.ChaincodeMessage) error     { return nil }
+func (discardStream) Recv() (*pb.ChaincodeMessage, error) { select {} }
+func (discardStream) CloseSend() error                    { return nil }
+
+type fuzzChaincode struct{}
+
+func (fuzzChaincode) Init(stub ChaincodeStubInterface) pb.Response {
+	return fuzzChaincode{}.Invoke(stub)
+}
+
+func (fuzzChaincode) Invoke(stub ChaincodeStubInterface) pb.Response {
+	stub.GetFunctionAndParameters()
+	stub.GetArgsSlice()
+	stub.GetCreator()
+	stub.GetTransient()
+	stub.GetBinding()
+	stub.GetDecorations()
+	stub.GetTxTimestamp()
+	for _, arg := range stub.GetStringArgs() {
+		stub.SplitCompositeKey(arg)
+	}
+	return Success(nil)
+}
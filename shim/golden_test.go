@@ -0,0 +1,276 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// A golden recording is the exchange of messages between a peer and the
+// shim. The recordings in testdata/golden are replayed through the handler
+// by TestGolden: the messages from the peer are delivered to the shim in
+// order, and every message sent by the shim must match the recorded one.
+// A recording that no longer replays means the shim no longer speaks the
+// protocol the peer expects.
+type goldenRecording struct {
+	Description string `json:"description"`
+	// Error is the error returned by the shim when the exchange ends. An
+	// exchange that runs to the end of the recording ends with
+	// errStreamEOF.
+	Error    string          `json:"error"`
+	Messages []goldenMessage `json:"messages"`
+}
+
+// goldenMessage is a recorded chaincode message. The payload is either raw
+// text or the JSON encoding of the protobuf message named by PayloadType.
+type goldenMessage struct {
+	From        string          `json:"from"`
+	Type        string          `json:"type"`
+	Txid        string          `json:"txid,omitempty"`
+	ChannelID   string          `json:"channel_id,omitempty"`
+	PayloadType string          `json:"payload_type,omitempty"`
+	Payload     json.RawMessage `json:"payload,omitempty"`
+	Raw         string          `json:"raw,omitempty"`
+}
+
+// message returns the chaincode message recorded by m.
+func (m goldenMessage) message() (*peerpb.ChaincodeMessage, error) {
+	msgType, ok := peerpb.ChaincodeMessage_Type_value[m.Type]
+	if !ok {
+		return nil, fmt.Errorf("unknown message type %s", m.Type)
+	}
+	msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_Type(msgType), Txid: m.Txid, ChannelId: m.ChannelID}
+	if m.Raw != "" {
+		msg.Payload = []byte(m.Raw)
+	}
+	if m.PayloadType != "" {
+		payload, err := m.payload()
+		if err != nil {
+			return nil, err
+		}
+		if msg.Payload, err = proto.Marshal(payload); err != nil {
+			return nil, err
+		}
+	}
+	return msg, nil
+}
+
+// payload returns the protobuf payload recorded by m.
+func (m goldenMessage) payload() (proto.Message, error) {
+	payload, err := newGoldenPayload(m.PayloadType)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Payload) == 0 {
+		return payload, nil
+	}
+	if err := jsonpb.Unmarshal(bytes.NewReader(m.Payload), payload); err != nil {
+		return nil, fmt.Errorf("invalid %s payload: %s", m.PayloadType, err)
+	}
+	return payload, nil
+}
+
+func newGoldenPayload(name string) (proto.Message, error) {
+	t := proto.MessageType(name)
+	if t == nil {
+		return nil, fmt.Errorf("unknown payload type %s", name)
+	}
+	return reflect.New(t.Elem()).Interface().(proto.Message), nil
+}
+
+// match returns an error describing how msg differs from m.
+func (m goldenMessage) match(msg *peerpb.ChaincodeMessage) error {
+	expected, err := m.message()
+	if err != nil {
+		return err
+	}
+	if msg.Type != expected.Type || msg.Txid != expected.Txid || msg.ChannelId != expected.ChannelId {
+		return fmt.Errorf("sent %s txid=%q channel=%q, expected %s txid=%q channel=%q",
+			msg.Type, msg.Txid, msg.ChannelId, expected.Type, expected.Txid, expected.ChannelId)
+	}
+	if m.PayloadType == "" {
+		if !bytes.Equal(msg.Payload, expected.Payload) {
+			return fmt.Errorf("sent %s with payload %q, expected %q", msg.Type, msg.Payload, expected.Payload)
+		}
+		return nil
+	}
+	payload, err := newGoldenPayload(m.PayloadType)
+	if err != nil {
+		return err
+	}
+	if err := proto.Unmarshal(msg.Payload, payload); err != nil {
+		return fmt.Errorf("sent %s with a payload that is not a %s: %s", msg.Type, m.PayloadType, err)
+	}
+	expectedPayload, _ := m.payload()
+	if !proto.Equal(payload, expectedPayload) {
+		sent, _ := (&jsonpb.Marshaler{}).MarshalToString(payload)
+		return fmt.Errorf("sent %s with payload %s, expected %s", msg.Type, sent, m.Payload)
+	}
+	return nil
+}
+
+// replayStream plays the part of the peer in a golden recording. Recv
+// returns the next message from the peer once the shim has sent every
+// message recorded before it, and io.EOF at the end of the recording.
+type replayStream struct {
+	mutex    sync.Mutex
+	messages []goldenMessage
+	next     int
+	sent     chan struct{}
+	errs     []string
+}
+
+func newReplayStream(messages []goldenMessage) *replayStream {
+	return &replayStream{messages: messages, sent: make(chan struct{}, 1)}
+}
+
+func (s *replayStream) Send(msg *peerpb.ChaincodeMessage) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	defer func() {
+		select {
+		case s.sent <- struct{}{}:
+		default:
+		}
+	}()
+
+	if s.next == len(s.messages) || s.messages[s.next].From != "shim" {
+		s.errs = append(s.errs, fmt.Sprintf("unexpected message %s txid=%q payload=%q", msg.Type, msg.Txid, msg.Payload))
+		return nil
+	}
+	if err := s.messages[s.next].match(msg); err != nil {
+		s.errs = append(s.errs, fmt.Sprintf("message %d: %s", s.next, err))
+	}
+	s.next++
+	return nil
+}
+
+func (s *replayStream) Recv() (*peerpb.ChaincodeMessage, error) {
+	for {
+		s.mutex.Lock()
+		if s.next == len(s.messages) {
+			s.mutex.Unlock()
+			return nil, io.EOF
+		}
+		if m := s.messages[s.next]; m.From == "peer" {
+			s.next++
+			s.mutex.Unlock()
+			return m.message()
+		}
+		expected := s.messages[s.next]
+		s.mutex.Unlock()
+
+		select {
+		case <-s.sent:
+		case <-time.After(5 * time.Second):
+			return nil, fmt.Errorf("timed out waiting for the shim to send message %d (%s)", s.next, expected.Type)
+		}
+	}
+}
+
+func (s *replayStream) CloseSend() error { return nil }
+
+// goldenChaincode is the chaincode of the golden recordings.
+type goldenChaincode struct{}
+
+func (goldenChaincode) Init(stub ChaincodeStubInterface) peerpb.Response {
+	if fn, _ := stub.GetFunctionAndParameters(); fn == "fail" {
+		return Error("init failed")
+	}
+	return Success(nil)
+}
+
+func (goldenChaincode) Invoke(stub ChaincodeStubInterface) peerpb.Response {
+	fn, args := stub.GetFunctionAndParameters()
+	switch fn {
+	case "put":
+		if err := stub.PutState(args[0], []byte(args[1])); err != nil {
+			return Error(err.Error())
+		}
+		return Success(nil)
+	case "get":
+		value, err := stub.GetState(args[0])
+		if err != nil {
+			return Error(err.Error())
+		}
+		return Success(value)
+	case "range":
+		iter, err := stub.GetStateByRange(args[0], args[1])
+		if err != nil {
+			return Error(err.Error())
+		}
+		return Success([]byte(goldenKeys(iter, "")))
+	case "page":
+		iter, metadata, err := stub.GetStateByRangeWithPagination(args[0], args[1], 2, "")
+		if err != nil {
+			return Error(err.Error())
+		}
+		return Success([]byte(goldenKeys(iter, metadata.Bookmark)))
+	default:
+		return Error("unknown function " + fn)
+	}
+}
+
+// goldenKeys returns the keys of iter followed by the bookmark, if any.
+func goldenKeys(iter StateQueryIteratorInterface, bookmark string) string {
+	defer iter.Close()
+	var keys []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return err.Error()
+		}
+		keys = append(keys, kv.Key)
+	}
+	if bookmark != "" {
+		keys = append(keys, "bookmark="+bookmark)
+	}
+	return strings.Join(keys, ",")
+}
+
+func TestGolden(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join("testdata", "golden", "*.json"))
+	require.NoError(t, err)
+	require.NotEmpty(t, files)
+
+	for _, file := range files {
+		t.Run(strings.TrimSuffix(filepath.Base(file), ".json"), func(t *testing.T) {
+			data, err := ioutil.ReadFile(file)
+			require.NoError(t, err)
+			var recording goldenRecording
+			require.NoError(t, json.Unmarshal(data, &recording))
+
+			stream := newReplayStream(recording.Messages)
+			err = chatWithPeer("golden", stream, goldenChaincode{})
+			if recording.Error == "" {
+				assert.Equal(t, errStreamEOF, err)
+			} else {
+				assert.EqualError(t, err, recording.Error)
+			}
+
+			stream.mutex.Lock()
+			defer stream.mutex.Unlock()
+			for _, e := range stream.errs {
+				t.Error(e)
+			}
+			assert.Equal(t, len(recording.Messages), stream.next, "recording was not replayed to the end")
+		})
+	}
+}
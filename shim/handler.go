@@ -7,6 +7,7 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 
 	"github.com/golang/protobuf/proto"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
@@ -22,16 +23,17 @@ const (
 
 // PeerChaincodeStream interface for stream between Peer and chaincode instance.
 type PeerChaincodeStream interface {
-	Send(*pb.ChaincodeMessage) error
-	Recv() (*pb.ChaincodeMessage, error)
+	MessageSender
+	MessageReceiver
 	CloseSend() error
 }
 
 // Handler handler implementation for shim side of chaincode.
 type Handler struct {
-	// serialLock is used to prevent concurrent calls to Send on the
-	// PeerChaincodeStream. This is required by gRPC.
-	serialLock sync.Mutex
+	// sendLanes is used to prevent concurrent calls to Send on the
+	// PeerChaincodeStream. This is required by gRPC. Control messages are
+	// sent ahead of data messages waiting for the stream.
+	sendLanes sendLanes
 	// chatStream is the client used to access the chaincode support server on
 	// the peer.
 	chatStream PeerChaincodeStream
@@ -41,12 +43,115 @@ type Handler struct {
 	// state holds the current state of this handler.
 	state state
 
-	// Multiple queries (and one transaction) with different txids can be executing in parallel for this chaincode
-	// responseChannels is the channel on which responses are communicated by the shim to the chaincodeStub.
-	// need lock to protect chaincode from attempting
-	// concurrent requests to the peer
-	responseChannelsMutex sync.Mutex
-	responseChannels      map[string]chan pb.ChaincodeMessage
+	// router delivers the responses of the peer to the requests of the
+	// chaincode stubs.
+	router ResponseRouter
+
+	// maxPayloadSize is the maximum size of a message payload accepted from
+	// the peer; zero means DefaultMaxPayloadSize.
+	maxPayloadSize int
+
+	// logger is used to write log records; nil means the default logger.
+	logger Logger
+	// quiet is set to 1 to suppress log records; it is changed atomically
+	// by configuration reloads.
+	quiet int32
+	// configReloader, if set, applies the runtime configuration file.
+	configReloader *configReloader
+	// debugServer, if set, serves the state of the handler.
+	debugServer *debugServer
+
+	// hashPayloads enables the hashing of message payloads which are then
+	// logged and passed to the hashRecorders.
+	hashPayloads  bool
+	hashRecorders []PayloadHashRecorder
+
+	// peerMonitor, if set, tracks when messages are received from the peer.
+	peerMonitor *PeerMonitor
+
+	// streams is the number of streams Start opens to the peer; zero means
+	// a single stream.
+	streams int
+	// registered, if set, is closed when the peer acknowledges the
+	// registration of the chaincode.
+	registered chan struct{}
+	// onActivePeer, if set, is called when the chaincode registers with
+	// one of several peers it fails over between.
+	onActivePeer func(active string, health []PeerHealth)
+	// reconnect, if set, makes Start reconnect to the peer when the stream
+	// to the peer ends.
+	reconnect *ReconnectPolicy
+
+	// retryPolicy, if set, configures the retry of idempotent requests.
+	retryPolicy *RetryPolicy
+
+	// strictQueries enables the rejection of rich queries that are not
+	// canonical JSON.
+	strictQueries bool
+	// strictKeys enables the rejection of keys and values that a CouchDB
+	// state database cannot store.
+	strictKeys bool
+
+	// offchain, if set, delivers the writes of successful transactions to
+	// an OffchainSink.
+	offchain *offchainDispatcher
+
+	// scheduler, if set, schedules the execution of transactions between
+	// channels.
+	scheduler *scheduler
+
+	// credentials, if set, provides the client TLS certificate of the
+	// connections to the peer.
+	credentials CredentialProvider
+	// authToken, if set, provides the token sent to the peer when opening
+	// a stream.
+	authToken AuthTokenProvider
+
+	// hooks are invoked as the stream to the peer changes state.
+	hooks LifecycleHooks
+
+	// errorSampler, if set, limits the logging of repeated handler errors;
+	// nil means the sampling of DefaultLogSampling.
+	errorSampler *logSampler
+
+	// argLimits bounds the arguments of the transactions passed to the
+	// chaincode.
+	argLimits ArgLimits
+
+	// extensions are the handlers of the message types registered with
+	// WithMessageHandler.
+	extensions map[pb.ChaincodeMessage_Type]MessageHandler
+
+	// queryBudget is the maximum number of bytes of query results fetched
+	// by each transaction; zero means no limit.
+	queryBudget int
+
+	// writeLimits, if set, are the limits on the writes of transactions.
+	writeLimits *WriteLimits
+
+	// eventCompression is the size from which SetEvent compresses event
+	// payloads; zero means no compression.
+	eventCompression int
+
+	// adaptivePager, if set, sizes the pages in which GetQueryResult
+	// fetches the results of rich queries.
+	adaptivePager *adaptivePager
+
+	// functionStats, if set, accumulates the execution statistics of the
+	// functions of the chaincode.
+	functionStats *functionStats
+
+	// decryptor, if set, decrypts the inputs of transactions before they
+	// are passed to the chaincode.
+	decryptor Decryptor
+
+	// watchdog, if set, monitors the resource usage of the process and
+	// may reject transactions.
+	watchdog *watchdog
+
+	// traces maps the context IDs of the transactions in progress to the
+	// IDs of their traces, tagged on the log records of the handler.
+	traces sync.Map
 }
 
 func shorttxid(txid string) string {
@@ -56,14 +161,25 @@ func shorttxid(txid string) string {
 	return txid[0:8]
 }
 
-// serialSend serializes calls to Send on the gRPC client.
+// serialSend serializes calls to Send on the gRPC client, sending control
+// messages first.
 func (h *Handler) serialSend(msg *pb.ChaincodeMessage) error {
-	h.serialLock.Lock()
-	defer h.serialLock.Unlock()
+	h.recordPayloadHash(Sent, msg)
+
+	h.sendLanes.acquire(sendLane(msg))
+	defer h.sendLanes.release()
 
 	return h.chatStream.Send(msg)
 }
 
+// closeSend closes the send direction of the stream to the peer.
+func (h *Handler) closeSend() error {
+	h.sendLanes.acquire(dataLane)
+	defer h.sendLanes.release()
+
+	return h.chatStream.CloseSend()
+}
+
 // serialSendAsync sends the provided message asynchronously in a separate
 // goroutine. The result of the send is communicated back to the caller via
 // errc.
@@ -80,47 +196,15 @@ func transactionContextID(chainID, txid string) string {
 }
 
 func (h *Handler) createResponseChannel(channelID, txid string) (<-chan pb.ChaincodeMessage, error) {
-	h.responseChannelsMutex.Lock()
-	defer h.responseChannelsMutex.Unlock()
-
-	if h.responseChannels == nil {
-		return nil, fmt.Errorf("[%s] cannot create response channel", shorttxid(txid))
-	}
-
-	txCtxID := transactionContextID(channelID, txid)
-	if h.responseChannels[txCtxID] != nil {
-		return nil, fmt.Errorf("[%s] channel exists", shorttxid(txCtxID))
-	}
-
-	responseChan := make(chan pb.ChaincodeMessage)
-	h.responseChannels[txCtxID] = responseChan
-	return responseChan, nil
+	return h.router.Register(channelID, txid)
 }
 
 func (h *Handler) deleteResponseChannel(channelID, txid string) {
-	h.responseChannelsMutex.Lock()
-	defer h.responseChannelsMutex.Unlock()
-	if h.responseChannels != nil {
-		txCtxID := transactionContextID(channelID, txid)
-		delete(h.responseChannels, txCtxID)
-	}
+	h.router.Unregister(channelID, txid)
 }
 
 func (h *Handler) handleResponse(msg *pb.ChaincodeMessage) error {
-	h.responseChannelsMutex.Lock()
-	defer h.responseChannelsMutex.Unlock()
-
-	if h.responseChannels == nil {
-		return fmt.Errorf("[%s] Cannot send message response channel", shorttxid(msg.Txid))
-	}
-
-	txCtxID := transactionContextID(msg.ChannelId, msg.Txid)
-	responseCh := h.responseChannels[txCtxID]
-	if responseCh == nil {
-		return fmt.Errorf("[%s] responseChannel does not exist", shorttxid(msg.Txid))
-	}
-	responseCh <- *msg
-	return nil
+	return h.router.Route(msg)
 }
 
 // sendReceive sends msg to the peer and waits for the response to arrive on
@@ -138,22 +222,45 @@ func (h *Handler) sendReceive(msg *pb.ChaincodeMessage, responseChan <-chan pb.C
 }
 
 // NewChaincodeHandler returns a new instance of the shim side handler.
-func newChaincodeHandler(peerChatStream PeerChaincodeStream, chaincode Chaincode) *Handler {
-	return &Handler{
-		chatStream:       peerChatStream,
-		cc:               chaincode,
-		responseChannels: map[string]chan pb.ChaincodeMessage{},
-		state:            created,
+func newChaincodeHandler(peerChatStream PeerChaincodeStream, chaincode Chaincode, opts ...Option) (*Handler, error) {
+	h := &Handler{
+		chatStream: peerChatStream,
+		cc:         chaincode,
+		router:     NewResponseRouter(),
+		state:      created,
+	}
+	for _, opt := range opts {
+		if err := opt(h); err != nil {
+			return nil, err
+		}
 	}
+	return h, nil
+}
+
+// checkPayloadSize returns an error if the payload of msg exceeds the
+// maximum payload size of the handler.
+func (h *Handler) checkPayloadSize(msg *pb.ChaincodeMessage) error {
+	limit := h.maxPayloadSize
+	if limit <= 0 {
+		limit = DefaultMaxPayloadSize
+	}
+	if len(msg.Payload) > limit {
+		return fmt.Errorf("[%s] %s payload size %d exceeds maximum of %d bytes", shorttxid(msg.Txid), msg.Type, len(msg.Payload), limit)
+	}
+	return nil
 }
 
 type stubHandlerFunc func(*pb.ChaincodeMessage) (*pb.ChaincodeMessage, error)
 
 func (h *Handler) handleStubInteraction(handler stubHandlerFunc, msg *pb.ChaincodeMessage, errc chan<- error) {
+	// the trace registered by handler tags the error logged below
+	defer h.forgetTrace(msg.ChannelId, msg.Txid)
 	resp, err := handler(msg)
 	if err != nil {
+		h.logHandlerError(msg, err)
 		resp = &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(err.Error()), Txid: msg.Txid, ChannelId: msg.ChannelId}
 	}
+	h.completed(msg, resp)
 	h.serialSendAsync(resp, errc)
 }
 
@@ -171,11 +278,13 @@ func (h *Handler) handleInit(msg *pb.ChaincodeMessage) (*pb.ChaincodeMessage, er
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new ChaincodeStub: %s", err)
 	}
+	h.traceTransaction(stub)
 
-	res := h.cc.Init(stub)
+	res := h.callChaincode(h.cc.Init, stub)
 	if res.Status >= ERROR {
 		return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(res.Message), Txid: msg.Txid, ChaincodeEvent: stub.chaincodeEvent, ChannelId: msg.ChannelId}, nil
 	}
+	h.deliverOffchain(stub, res)
 
 	resBytes, err := proto.Marshal(&res)
 	if err != nil {
@@ -199,8 +308,10 @@ func (h *Handler) handleTransaction(msg *pb.ChaincodeMessage) (*pb.ChaincodeMess
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new ChaincodeStub: %s", err)
 	}
+	h.traceTransaction(stub)
 
-	res := h.cc.Invoke(stub)
+	res := h.callChaincode(h.cc.Invoke, stub)
+	h.deliverOffchain(stub, res)
 
 	// Endorser will handle error contained in Response.
 	resBytes, err := proto.Marshal(&res)
@@ -211,9 +322,30 @@ func (h *Handler) handleTransaction(msg *pb.ChaincodeMessage) (*pb.ChaincodeMess
 	return &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_COMPLETED, Payload: resBytes, Txid: msg.Txid, ChaincodeEvent: stub.chaincodeEvent, ChannelId: stub.ChannelID}, nil
 }
 
+// deliverOffchain queues the writes of stub for delivery to the offchain
+// sink, if any, when res is successful.
+func (h *Handler) deliverOffchain(stub *ChaincodeStub, res pb.Response) {
+	if h.offchain == nil || res.Status >= ERRORTHRESHOLD {
+		return
+	}
+	h.offchain.enqueue(offchainWrites(stub), h.logf)
+}
+
 // callPeerWithChaincodeMsg sends a chaincode message to the peer for the given
-// txid and channel and receives the response.
+// txid and channel and receives the response. Idempotent requests failing
+// with a transient error are retried according to the retry policy.
 func (h *Handler) callPeerWithChaincodeMsg(msg *pb.ChaincodeMessage, channelID, txid string) (pb.ChaincodeMessage, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := h.callPeerOnce(msg, channelID, txid)
+		if err != nil || !h.retryPolicy.shouldRetry(msg, &resp, attempt) {
+			return resp, err
+		}
+		h.logf("[%s] retrying %s after transient error (attempt %d of %d): %s", h.txTag(channelID, txid), msg.Type, attempt, h.retryPolicy.MaxAttempts, resp.Payload)
+		time.Sleep(h.retryPolicy.backoff(attempt))
+	}
+}
+
+func (h *Handler) callPeerOnce(msg *pb.ChaincodeMessage, channelID, txid string) (pb.ChaincodeMessage, error) {
 	// Create the channel on which to communicate the response from the peer
 	respChan, err := h.createResponseChannel(channelID, txid)
 	if err != nil {
@@ -512,21 +644,13 @@ func (h *Handler) handleGetQueryResult(collection string, query string, metadata
 }
 
 func (h *Handler) handleGetHistoryForKey(key string, channelID string, txid string) (*pb.QueryResponse, error) {
-	// Create the channel on which to communicate the response from validating peer
-	respChan, err := h.createResponseChannel(channelID, txid)
-	if err != nil {
-		return nil, err
-	}
-	defer h.deleteResponseChannel(channelID, txid)
-
 	// Send GET_HISTORY_FOR_KEY message to peer chaincode support
 	payloadBytes := marshalOrPanic(&pb.GetHistoryForKey{Key: key})
 
 	msg := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_GET_HISTORY_FOR_KEY, Payload: payloadBytes, Txid: txid, ChannelId: channelID}
-	var responseMsg pb.ChaincodeMessage
-
-	if responseMsg, err = h.sendReceive(msg, respChan); err != nil {
-		return nil, fmt.Errorf("[%s] error sending %s", shorttxid(msg.Txid), pb.ChaincodeMessage_GET_HISTORY_FOR_KEY)
+	responseMsg, err := h.callPeerWithChaincodeMsg(msg, channelID, txid)
+	if err != nil {
+		return nil, fmt.Errorf("[%s] error sending %s: %s", shorttxid(msg.Txid), pb.ChaincodeMessage_GET_HISTORY_FOR_KEY, err)
 	}
 
 	if responseMsg.Type == pb.ChaincodeMessage_RESPONSE {
@@ -547,18 +671,29 @@ func (h *Handler) handleGetHistoryForKey(key string, channelID string, txid stri
 	return nil, fmt.Errorf("incorrect chaincode message %s received. Expecting %s or %s", responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)
 }
 
+// createInvokeResult creates an InvokeResult without event.
+func (h *Handler) createInvokeResult(status int32, payload []byte) *InvokeResult {
+	return &InvokeResult{Response: h.createResponse(status, payload)}
+}
+
 func (h *Handler) createResponse(status int32, payload []byte) pb.Response {
 	return pb.Response{Status: status, Payload: payload}
 }
 
 // handleInvokeChaincode communicates with the peer to invoke another chaincode.
 func (h *Handler) handleInvokeChaincode(chaincodeName string, args [][]byte, channelID string, txid string) pb.Response {
+	return h.handleInvokeChaincodeResult(chaincodeName, args, channelID, txid).Response
+}
+
+// handleInvokeChaincodeResult communicates with the peer to invoke another
+// chaincode and returns its response along with the event it set.
+func (h *Handler) handleInvokeChaincodeResult(chaincodeName string, args [][]byte, channelID string, txid string) *InvokeResult {
 	payloadBytes := marshalOrPanic(&pb.ChaincodeSpec{ChaincodeId: &pb.ChaincodeID{Name: chaincodeName}, Input: &pb.ChaincodeInput{Args: args}})
 
 	// Create the channel on which to communicate the response from validating peer
 	respChan, err := h.createResponseChannel(channelID, txid)
 	if err != nil {
-		return h.createResponse(ERROR, []byte(err.Error()))
+		return h.createInvokeResult(ERROR, []byte(err.Error()))
 	}
 	defer h.deleteResponseChannel(channelID, txid)
 
@@ -569,36 +704,40 @@ func (h *Handler) handleInvokeChaincode(chaincodeName string, args [][]byte, cha
 
 	if responseMsg, err = h.sendReceive(msg, respChan); err != nil {
 		errStr := fmt.Sprintf("[%s] error sending %s", shorttxid(msg.Txid), pb.ChaincodeMessage_INVOKE_CHAINCODE)
-		return h.createResponse(ERROR, []byte(errStr))
+		return h.createInvokeResult(ERROR, []byte(errStr))
 	}
 
 	if responseMsg.Type == pb.ChaincodeMessage_RESPONSE {
 		// Success response
 		respMsg := &pb.ChaincodeMessage{}
 		if err := proto.Unmarshal(responseMsg.Payload, respMsg); err != nil {
-			return h.createResponse(ERROR, []byte(err.Error()))
+			return h.createInvokeResult(ERROR, []byte(err.Error()))
 		}
 		if respMsg.Type == pb.ChaincodeMessage_COMPLETED {
 			// Success response
 			res := &pb.Response{}
 			if err = proto.Unmarshal(respMsg.Payload, res); err != nil {
-				return h.createResponse(ERROR, []byte(err.Error()))
+				return h.createInvokeResult(ERROR, []byte(err.Error()))
 			}
-			return *res
+			return &InvokeResult{Response: *res, Event: respMsg.ChaincodeEvent}
 		}
-		return h.createResponse(ERROR, responseMsg.Payload)
+		return h.createInvokeResult(ERROR, responseMsg.Payload)
 	}
 	if responseMsg.Type == pb.ChaincodeMessage_ERROR {
 		// Error response
-		return h.createResponse(ERROR, responseMsg.Payload)
+		return h.createInvokeResult(ERROR, responseMsg.Payload)
 	}
 
 	// Incorrect chaincode message received
-	return h.createResponse(ERROR, []byte(fmt.Sprintf("[%s] Incorrect chaincode message %s received. Expecting %s or %s", shorttxid(responseMsg.Txid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)))
+	return h.createInvokeResult(ERROR, []byte(fmt.Sprintf("[%s] Incorrect chaincode message %s received. Expecting %s or %s", shorttxid(responseMsg.Txid), responseMsg.Type, pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR)))
 }
 
 // handleReady handles messages received from the peer when the handler is in the "ready" state.
 func (h *Handler) handleReady(msg *pb.ChaincodeMessage, errc chan error) error {
+	if err := h.checkPayloadSize(msg); err != nil {
+		return h.rejectMessage(msg, err, errc)
+	}
+
 	switch msg.Type {
 	case pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR:
 		if err := h.handleResponse(msg); err != nil {
@@ -607,18 +746,40 @@ func (h *Handler) handleReady(msg *pb.ChaincodeMessage, errc chan error) error {
 		return nil
 
 	case pb.ChaincodeMessage_INIT:
-		go h.handleStubInteraction(h.handleInit, msg, errc)
-		return nil
+		return h.dispatchTransaction(msg, h.handleInit, errc)
 
 	case pb.ChaincodeMessage_TRANSACTION:
-		go h.handleStubInteraction(h.handleTransaction, msg, errc)
-		return nil
+		return h.dispatchTransaction(msg, h.handleTransaction, errc)
 
 	default:
+		if handler, ok := h.extensions[msg.Type]; ok {
+			go h.handleExtension(handler, msg, errc)
+			return nil
+		}
 		return fmt.Errorf("[%s] Chaincode h cannot handle message (%s) while in state: %s", msg.Txid, msg.Type, h.state)
 	}
 }
 
+// rejectMessage fails the request associated with msg without passing msg
+// on. Responses to an outstanding request are replaced by an error response
+// and transactions are answered with an error; the stream remains usable.
+func (h *Handler) rejectMessage(msg *pb.ChaincodeMessage, reason error, errc chan error) error {
+	resp := &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_ERROR, Payload: []byte(reason.Error()), Txid: msg.Txid, ChannelId: msg.ChannelId}
+	switch msg.Type {
+	case pb.ChaincodeMessage_RESPONSE, pb.ChaincodeMessage_ERROR:
+		return h.handleResponse(resp)
+	case pb.ChaincodeMessage_INIT, pb.ChaincodeMessage_TRANSACTION:
+		h.serialSendAsync(resp, errc)
+		return nil
+	default:
+		if _, ok := h.extensions[msg.Type]; ok {
+			h.serialSendAsync(resp, errc)
+			return nil
+		}
+		return reason
+	}
+}
+
 // handleEstablished handles messages received from the peer when the handler is in the "established" state.
 func (h *Handler) handleEstablished(msg *pb.ChaincodeMessage, errc chan error) error {
 	if msg.Type != pb.ChaincodeMessage_READY {
@@ -626,6 +787,9 @@ func (h *Handler) handleEstablished(msg *pb.ChaincodeMessage, errc chan error) e
 	}
 
 	h.state = ready
+	if h.hooks.OnReady != nil {
+		h.hooks.OnReady()
+	}
 	return nil
 }
 
@@ -636,11 +800,22 @@ func (h *Handler) handleCreated(msg *pb.ChaincodeMessage, errc chan error) error
 	}
 
 	h.state = established
+	if h.registered != nil {
+		close(h.registered)
+	}
+	if h.hooks.OnRegister != nil {
+		h.hooks.OnRegister()
+	}
 	return nil
 }
 
 // handleMessage message handles loop for shim side of chaincode/peer stream.
 func (h *Handler) handleMessage(msg *pb.ChaincodeMessage, errc chan error) error {
+	if h.peerMonitor != nil {
+		h.peerMonitor.received(msg, time.Now())
+	}
+	h.recordPayloadHash(Received, msg)
+
 	if msg.Type == pb.ChaincodeMessage_KEEPALIVE {
 		h.serialSendAsync(msg, errc)
 		return nil
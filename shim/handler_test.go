@@ -40,13 +40,14 @@ func TestNewHandler_CreatedState(t *testing.T) {
 	cc := &mockChaincode{}
 
 	expected := &Handler{
-		chatStream:       chatStream,
-		cc:               cc,
-		responseChannels: map[string]chan peerpb.ChaincodeMessage{},
-		state:            created,
+		chatStream: chatStream,
+		cc:         cc,
+		router:     NewResponseRouter(),
+		state:      created,
 	}
 
-	handler := newChaincodeHandler(chatStream, cc)
+	handler, err := newChaincodeHandler(chatStream, cc)
+	assert.NoError(t, err)
 	if handler == nil {
 		t.Fatal("Handler should not be nil")
 	}
@@ -204,10 +205,10 @@ func TestHandleMessage(t *testing.T) {
 
 			// create handler in ready state
 			handler := &Handler{
-				chatStream:       chatStream,
-				cc:               cc,
-				responseChannels: map[string]chan peerpb.ChaincodeMessage{},
-				state:            ready,
+				chatStream: chatStream,
+				cc:         cc,
+				router:     NewResponseRouter(),
+				state:      ready,
 			}
 
 			err := handler.handleMessage(test.msg, nil)
@@ -229,9 +230,9 @@ func TestHandleMessage(t *testing.T) {
 func TestHandlePeerCalls(t *testing.T) {
 	payload := []byte("error")
 	h := &Handler{
-		cc:               &mockChaincode{},
-		responseChannels: map[string]chan peerpb.ChaincodeMessage{},
-		state:            ready,
+		cc:     &mockChaincode{},
+		router: NewResponseRouter(),
+		state:  ready,
 	}
 	chatStream := &mock.PeerChaincodeStream{}
 	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
@@ -255,8 +256,8 @@ func TestHandlePeerCalls(t *testing.T) {
 	_, err = h.handleQueryStateClose("id", "channel", "txid")
 	assert.EqualError(t, err, string(payload))
 
-	// force error by removing responseChannels
-	h.responseChannels = nil
+	// force error by removing the response channels
+	h.router = &responseRouter{}
 	_, err = h.handleGetState("col", "key", "channel", "txid")
 	assert.Contains(t, err.Error(), "[txid] error sending GET_STATE")
 
@@ -291,3 +292,76 @@ func TestHandlePeerCalls(t *testing.T) {
 	assert.Contains(t, err.Error(), "cannot create response channel")
 
 }
+
+func TestNewHandlerInvalidOption(t *testing.T) {
+	_, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithMaxPayloadSize(0))
+	assert.EqualError(t, err, "max payload size must be positive, got 0")
+}
+
+func TestHandleMessageMaxPayloadSize(t *testing.T) {
+	chatStream := &mock.PeerChaincodeStream{}
+	msgChan := make(chan *peerpb.ChaincodeMessage, 1)
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		msgChan <- msg
+		return nil
+	}
+	cc := &mockChaincode{}
+	handler, err := newChaincodeHandler(chatStream, cc, WithMaxPayloadSize(4))
+	assert.NoError(t, err)
+	handler.state = ready
+
+	// an oversized transaction is answered with an error without invoking the chaincode
+	errc := make(chan error, 1)
+	err = handler.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, Txid: "txid", Payload: []byte("too large")}, errc)
+	assert.NoError(t, err)
+	resp := <-msgChan
+	assert.Equal(t, peerpb.ChaincodeMessage_ERROR, resp.Type)
+	assert.Equal(t, "[txid] TRANSACTION payload size 9 exceeds maximum of 4 bytes", string(resp.Payload))
+	assert.False(t, cc.invokeCalled)
+
+	// an oversized response fails the pending request
+	respChan, err := handler.createResponseChannel("channel", "txid")
+	assert.NoError(t, err)
+	go handler.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_RESPONSE, ChannelId: "channel", Txid: "txid", Payload: []byte("too large")}, errc)
+	resp2 := <-respChan
+	assert.Equal(t, peerpb.ChaincodeMessage_ERROR, resp2.Type)
+	assert.Equal(t, "[txid] RESPONSE payload size 9 exceeds maximum of 4 bytes", string(resp2.Payload))
+}
+
+func TestHandleMessageMalformedPayload(t *testing.T) {
+	chatStream := &mock.PeerChaincodeStream{}
+	msgChan := make(chan *peerpb.ChaincodeMessage, 1)
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		msgChan <- msg
+		return nil
+	}
+	handler, err := newChaincodeHandler(chatStream, &mockChaincode{})
+	assert.NoError(t, err)
+	handler.state = ready
+
+	input := marshalOrPanic(&peerpb.ChaincodeInput{Args: [][]byte{[]byte("function")}})
+	errc := make(chan error, 1)
+	err = handler.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, Payload: input[:len(input)-1]}, errc)
+	assert.NoError(t, err)
+	resp := <-msgChan
+	assert.Equal(t, peerpb.ChaincodeMessage_ERROR, resp.Type)
+	assert.Contains(t, string(resp.Payload), "failed to unmarshal input")
+}
+
+func TestResponseRouter(t *testing.T) {
+	router := NewResponseRouter()
+
+	respChan, err := router.Register("channel", "txid")
+	assert.NoError(t, err)
+	_, err = router.Register("channel", "txid")
+	assert.EqualError(t, err, "[channelt] channel exists")
+
+	msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_RESPONSE, ChannelId: "channel", Txid: "txid"}
+	go router.Route(msg)
+	assert.Equal(t, *msg, <-respChan)
+	assert.Equal(t, []string{"channeltxid"}, router.(*responseRouter).inFlight())
+
+	router.Unregister("channel", "txid")
+	assert.EqualError(t, router.Route(msg), "[txid] responseChannel does not exist")
+	assert.Empty(t, router.(*responseRouter).inFlight())
+}
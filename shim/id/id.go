@@ -0,0 +1,127 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package id generates identifiers that are unique across transactions and
+// identical on every endorser, for use instead of random UUIDs, which would
+// produce different write sets on each endorser and fail endorsement.
+//
+// Identifiers are derived from the transaction ID and a counter incremented
+// on every call, so a transaction must use a single Generator:
+//
+//	gen, err := id.New(stub)
+//	...
+//	orderID := gen.UUID()
+//	lineID := gen.UUID()
+//
+// Identifiers are predictable by anyone who knows the transaction ID and
+// must not be used as secrets.
+package id
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"sync"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+)
+
+// ChaincodeStubInterface is the subset of the chaincode stub used to derive
+// identifiers.
+type ChaincodeStubInterface interface {
+	// GetTxID returns the ID of the transaction.
+	GetTxID() string
+
+	// GetTxTimestamp returns the timestamp of the transaction proposal.
+	GetTxTimestamp() (*timestamp.Timestamp, error)
+}
+
+// Generator derives identifiers from a transaction. It is safe for
+// concurrent use, but concurrent callers receive identifiers in an
+// unspecified order, which makes the write set differ between endorsers.
+type Generator struct {
+	txID      string
+	timestamp uint64
+
+	mutex   sync.Mutex
+	counter uint64
+}
+
+// New returns a Generator for the transaction of stub.
+func New(stub ChaincodeStubInterface) (*Generator, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get transaction timestamp: %s", err)
+	}
+	return &Generator{
+		txID:      stub.GetTxID(),
+		timestamp: uint64(ts.GetSeconds())*1000 + uint64(ts.GetNanos())/1e6,
+	}, nil
+}
+
+// next returns 16 bytes derived from the transaction ID and the next value
+// of the counter.
+func (g *Generator) next() [16]byte {
+	g.mutex.Lock()
+	counter := g.counter
+	g.counter++
+	g.mutex.Unlock()
+
+	var size, count [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(g.txID)))
+	binary.BigEndian.PutUint64(count[:], counter)
+	h := sha256.New()
+	h.Write(size[:])
+	h.Write([]byte(g.txID))
+	h.Write(count[:])
+
+	var b [16]byte
+	copy(b[:], h.Sum(nil))
+	return b
+}
+
+// UUID returns the next identifier as an RFC 9562 version 8 UUID in its
+// canonical textual form.
+func (g *Generator) UUID() string {
+	b := g.next()
+	b[6] = b[6]&0x0f | 0x80 // version 8
+	b[8] = b[8]&0x3f | 0x80 // RFC 9562 variant
+
+	var s [36]byte
+	hex.Encode(s[0:8], b[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], b[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], b[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], b[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], b[10:])
+	return string(s[:])
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ULID returns the next identifier as a ULID. Its time component is the
+// transaction timestamp, so ULIDs of later transactions usually sort after
+// those of earlier ones; within a transaction, they do not sort by call
+// order.
+func (g *Generator) ULID() string {
+	b := g.next()
+	binary.BigEndian.PutUint16(b[0:2], uint16(g.timestamp>>32))
+	binary.BigEndian.PutUint32(b[2:6], uint32(g.timestamp))
+
+	// encode the 128 bits as 26 characters of 5 bits each, the first
+	// character holding the 3 most significant bits
+	hi := binary.BigEndian.Uint64(b[0:8])
+	lo := binary.BigEndian.Uint64(b[8:16])
+	var s [26]byte
+	for i := 25; i >= 0; i-- {
+		s[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(s[:])
+}
@@ -0,0 +1,50 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package id_test
+
+import (
+	"testing"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim/id"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newGenerator(t *testing.T, txID string) *id.Generator {
+	stub := shimtest.NewMockStub("id", nil)
+	stub.MockTransactionStart(txID)
+	stub.TxTimestamp = &timestamp.Timestamp{Seconds: 1}
+	gen, err := id.New(stub)
+	require.NoError(t, err)
+	return gen
+}
+
+func TestGenerator(t *testing.T) {
+	gen := newGenerator(t, "txid")
+	assert.Equal(t, "0d1e123a-7323-8ec1-8fdb-8fac5d713b13", gen.UUID())
+	assert.Equal(t, "00000000Z8ZNDVJ0BC6EKTB25V", gen.ULID())
+
+	// identical on every endorser
+	other := newGenerator(t, "txid")
+	assert.Equal(t, "0d1e123a-7323-8ec1-8fdb-8fac5d713b13", other.UUID())
+
+	// unique across calls and transactions
+	seen := map[string]bool{}
+	for _, txID := range []string{"tx1", "tx2"} {
+		gen := newGenerator(t, txID)
+		for i := 0; i < 100; i++ {
+			u := gen.UUID()
+			assert.False(t, seen[u], "duplicate id %s", u)
+			seen[u] = true
+		}
+	}
+}
+
+func TestNewError(t *testing.T) {
+	stub := shimtest.NewMockStub("id", nil)
+	_, err := id.New(stub)
+	assert.EqualError(t, err, "failed to get transaction timestamp: TxTimestamp not set")
+}
@@ -0,0 +1,123 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package idempotency makes chaincode functions safe to retry. A client
+// supplies a request ID with each request; the first successful execution
+// records the response in the state under that ID, and later requests with
+// the same ID return the recorded response without executing the function
+// again.
+//
+// Request IDs are scoped to the invoker, as identified by cid.UniqueKey,
+// and to the function called, so that a client reusing or guessing the
+// request ID of another client neither reads its response nor prevents its
+// request from executing. Invokers without an X509 certificate are not
+// supported.
+//
+// Two transactions with the same request ID endorsed concurrently both read
+// the record key, so at most one of them is committed.
+package idempotency
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// objectType is the composite key object type of the records.
+const objectType = "idempotency"
+
+// record is the state representation of a processed request.
+type record struct {
+	Status  int32  `json:"status"`
+	Message string `json:"message,omitempty"`
+	Payload []byte `json:"payload,omitempty"`
+	// Expires is the time, in nanoseconds since the epoch, after which the
+	// record is ignored; zero means never.
+	Expires int64 `json:"expires,omitempty"`
+}
+
+// Guard short-circuits duplicate requests.
+type Guard struct {
+	ttl time.Duration
+}
+
+// New returns a Guard keeping records for ttl, measured with transaction
+// timestamps. A zero ttl keeps records forever. Expired records are replaced
+// when their request ID is reused, but are not deleted otherwise.
+func New(ttl time.Duration) (*Guard, error) {
+	if ttl < 0 {
+		return nil, fmt.Errorf("ttl must not be negative, got %s", ttl)
+	}
+	return &Guard{ttl: ttl}, nil
+}
+
+// Do returns the recorded response of requestID if the invoker of the
+// transaction called the same function with it before, and otherwise calls
+// fn and, if fn succeeds, records its response.
+func (g *Guard) Do(stub shim.ChaincodeStubInterface, requestID string, fn func() pb.Response) pb.Response {
+	if requestID == "" {
+		return shim.Error("request ID must not be an empty string")
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	invoker, err := cid.UniqueKey(stub)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("failed to identify the invoker: %s", err))
+	}
+	function, _ := stub.GetFunctionAndParameters()
+	key, err := stub.CreateCompositeKey(objectType, []string{invoker, function, requestID})
+	if err != nil {
+		return shim.Error(fmt.Sprintf("invalid request ID %s: %s", requestID, err))
+	}
+
+	b, err := stub.GetState(key)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("failed to read record of request %s: %s", requestID, err))
+	}
+	if b != nil {
+		rec := &record{}
+		if err := json.Unmarshal(b, rec); err != nil {
+			return shim.Error(fmt.Sprintf("failed to unmarshal record of request %s: %s", requestID, err))
+		}
+		if rec.Expires == 0 || now.UnixNano() <= rec.Expires {
+			return pb.Response{Status: rec.Status, Message: rec.Message, Payload: rec.Payload}
+		}
+	}
+
+	resp := fn()
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		return resp
+	}
+
+	rec := &record{Status: resp.Status, Message: resp.Message, Payload: resp.Payload}
+	if g.ttl > 0 {
+		rec.Expires = now.Add(g.ttl).UnixNano()
+	}
+	b, err = json.Marshal(rec)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("failed to marshal record of request %s: %s", requestID, err))
+	}
+	if err := stub.PutState(key, b); err != nil {
+		return shim.Error(fmt.Sprintf("failed to write record of request %s: %s", requestID, err))
+	}
+	return resp
+}
+
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get transaction timestamp: %s", err)
+	}
+	if ts == nil {
+		return time.Time{}, errors.New("transaction timestamp not set")
+	}
+	return ptypes.Timestamp(ts)
+}
@@ -0,0 +1,184 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package idempotency_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/idempotency"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/msp"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// creator returns the serialized identity of a client of mspID with a
+// self-signed certificate for the common name cn.
+func creator(t *testing.T, mspID, cn string) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	b, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: cert})
+	require.NoError(t, err)
+	return b
+}
+
+// newStub returns a stub whose transactions are submitted by alice.
+func newStub(t *testing.T) *shimtest.MockStub {
+	stub := shimtest.NewMockStub("idempotency", nil)
+	stub.Creator = creator(t, "Org1MSP", "alice")
+	return stub
+}
+
+// invocation is a stub of a transaction calling function.
+type invocation struct {
+	*shimtest.MockStub
+	function string
+}
+
+func (i invocation) GetFunctionAndParameters() (string, []string) {
+	return i.function, nil
+}
+
+// do runs guard.Do in a transaction at the given time, in seconds, with a
+// function returning resp, and reports whether the function was called.
+func do(stub *shimtest.MockStub, guard *idempotency.Guard, txID string, seconds int64, requestID string, resp pb.Response) (pb.Response, bool) {
+	return doFunction(stub, guard, "transfer", txID, seconds, requestID, resp)
+}
+
+// doFunction runs do in a transaction calling function.
+func doFunction(stub *shimtest.MockStub, guard *idempotency.Guard, function, txID string, seconds int64, requestID string, resp pb.Response) (pb.Response, bool) {
+	called := false
+	stub.MockTransactionStart(txID)
+	stub.TxTimestamp = &timestamp.Timestamp{Seconds: seconds}
+	result := guard.Do(invocation{MockStub: stub, function: function}, requestID, func() pb.Response {
+		called = true
+		return resp
+	})
+	stub.MockTransactionEnd(txID)
+	return result, called
+}
+
+func TestGuard(t *testing.T) {
+	guard, err := idempotency.New(0)
+	require.NoError(t, err)
+	stub := newStub(t)
+
+	resp, called := do(stub, guard, "tx1", 1, "req1", shim.Success([]byte("first")))
+	assert.True(t, called)
+	assert.Equal(t, []byte("first"), resp.Payload)
+
+	resp, called = do(stub, guard, "tx2", 1000000, "req1", shim.Success([]byte("second")))
+	assert.False(t, called, "expected a duplicate request not to be executed")
+	assert.Equal(t, int32(shim.OK), resp.Status)
+	assert.Equal(t, []byte("first"), resp.Payload)
+
+	resp, called = do(stub, guard, "tx3", 1, "req2", shim.Success([]byte("other")))
+	assert.True(t, called)
+	assert.Equal(t, []byte("other"), resp.Payload)
+}
+
+func TestGuardFailureNotRecorded(t *testing.T) {
+	guard, err := idempotency.New(0)
+	require.NoError(t, err)
+	stub := newStub(t)
+
+	resp, called := do(stub, guard, "tx1", 1, "req1", shim.Error("boom"))
+	assert.True(t, called)
+	assert.Equal(t, "boom", resp.Message)
+
+	resp, called = do(stub, guard, "tx2", 1, "req1", shim.Success([]byte("retried")))
+	assert.True(t, called, "expected a failed request to be executed again")
+	assert.Equal(t, []byte("retried"), resp.Payload)
+}
+
+func TestGuardTTL(t *testing.T) {
+	guard, err := idempotency.New(time.Minute)
+	require.NoError(t, err)
+	stub := newStub(t)
+
+	_, called := do(stub, guard, "tx1", 100, "req1", shim.Success([]byte("first")))
+	assert.True(t, called)
+
+	resp, called := do(stub, guard, "tx2", 160, "req1", shim.Success([]byte("second")))
+	assert.False(t, called, "expected the record to be valid up to the ttl")
+	assert.Equal(t, []byte("first"), resp.Payload)
+
+	resp, called = do(stub, guard, "tx3", 161, "req1", shim.Success([]byte("third")))
+	assert.True(t, called, "expected an expired record to be ignored")
+	assert.Equal(t, []byte("third"), resp.Payload)
+
+	resp, called = do(stub, guard, "tx4", 200, "req1", shim.Success([]byte("fourth")))
+	assert.False(t, called, "expected the replaced record to be used")
+	assert.Equal(t, []byte("third"), resp.Payload)
+}
+
+func TestGuardScope(t *testing.T) {
+	guard, err := idempotency.New(0)
+	require.NoError(t, err)
+	stub := newStub(t)
+	alice := stub.Creator
+
+	_, called := do(stub, guard, "tx1", 1, "req1", shim.Success([]byte("alice")))
+	assert.True(t, called)
+
+	stub.Creator = creator(t, "Org2MSP", "mallory")
+	resp, called := do(stub, guard, "tx2", 1, "req1", shim.Success([]byte("mallory")))
+	assert.True(t, called, "expected the request ID of another client not to be recorded")
+	assert.Equal(t, []byte("mallory"), resp.Payload)
+
+	stub.Creator = alice
+	resp, called = doFunction(stub, guard, "burn", "tx3", 1, "req1", shim.Success([]byte("burn")))
+	assert.True(t, called, "expected the request ID of another function not to be recorded")
+	assert.Equal(t, []byte("burn"), resp.Payload)
+
+	resp, called = do(stub, guard, "tx4", 1, "req1", shim.Success([]byte("retried")))
+	assert.False(t, called)
+	assert.Equal(t, []byte("alice"), resp.Payload)
+
+	stub.Creator = nil
+	resp, called = do(stub, guard, "tx5", 1, "req1", shim.Success(nil))
+	assert.False(t, called)
+	assert.Contains(t, resp.Message, "failed to identify the invoker")
+}
+
+func TestGuardErrors(t *testing.T) {
+	_, err := idempotency.New(-time.Second)
+	assert.EqualError(t, err, "ttl must not be negative, got -1s")
+
+	guard, err := idempotency.New(0)
+	require.NoError(t, err)
+	stub := newStub(t)
+
+	resp, called := do(stub, guard, "tx1", 1, "", shim.Success(nil))
+	assert.False(t, called)
+	assert.Equal(t, "request ID must not be an empty string", resp.Message)
+
+	resp, called = do(stub, guard, "tx2", 1, "\x00", shim.Success(nil))
+	assert.False(t, called)
+	assert.Contains(t, resp.Message, "invalid request ID")
+
+	resp = guard.Do(shimtest.NewMockStub("notx", nil), "req1", func() pb.Response { return shim.Success(nil) })
+	assert.Equal(t, "failed to get transaction timestamp: TxTimestamp not set", resp.Message)
+}
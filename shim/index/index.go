@@ -0,0 +1,203 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package index maintains secondary indexes over JSON objects stored in the
+// world state. Each index entry is a composite key made of the index name,
+// the indexed attributes and the primary key, so that entries can be found
+// with GetStateByPartialCompositeKey.
+//
+//	m, err := index.NewManager(index.Index{Name: "owner~color", Extract: index.Fields("owner", "color")})
+//	...
+//	err = m.Put(stub, "asset1", assetJSON)
+//	keys, err := m.Query(stub, "owner~color", "alice")
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// entryValue is the value of index entries. The peer deletes keys written
+// with an empty value, so a single byte is stored instead.
+var entryValue = []byte{0x00}
+
+// Extractor returns the attributes under which value is indexed, or nil if
+// value is not indexed.
+type Extractor func(value []byte) ([]string, error)
+
+// Index declares a secondary index.
+type Index struct {
+	// Name is the name of the index, used as the object type of the
+	// composite keys of its entries.
+	Name string
+	// Extract returns the indexed attributes of a value.
+	Extract Extractor
+}
+
+// Fields returns an Extractor indexing a JSON object by the values of the
+// given top-level fields. Strings are indexed as is and other values by
+// their JSON encoding. Objects missing any of the fields, or where it is
+// null, are not indexed.
+func Fields(names ...string) Extractor {
+	return func(value []byte) ([]string, error) {
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(value, &obj); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal object: %s", err)
+		}
+		attrs := make([]string, 0, len(names))
+		for _, name := range names {
+			raw, ok := obj[name]
+			if !ok || string(raw) == "null" {
+				return nil, nil
+			}
+			var s string
+			if err := json.Unmarshal(raw, &s); err != nil {
+				s = string(raw)
+			}
+			attrs = append(attrs, s)
+		}
+		return attrs, nil
+	}
+}
+
+// Manager keeps the entries of a set of indexes in sync with the objects
+// they index.
+type Manager struct {
+	indexes []Index
+}
+
+// NewManager returns a Manager for the given indexes.
+func NewManager(indexes ...Index) (*Manager, error) {
+	names := map[string]bool{}
+	for _, idx := range indexes {
+		if idx.Name == "" {
+			return nil, errors.New("index name must not be an empty string")
+		}
+		if idx.Extract == nil {
+			return nil, fmt.Errorf("index %s has no extractor", idx.Name)
+		}
+		if names[idx.Name] {
+			return nil, fmt.Errorf("duplicate index %s", idx.Name)
+		}
+		names[idx.Name] = true
+	}
+	return &Manager{indexes: indexes}, nil
+}
+
+// Put writes value for key and updates the index entries of key. The
+// previous entries are found from the committed value of key, as the peer
+// does not return values written by the transaction in progress, so a key
+// must be written at most once per transaction through the Manager.
+func (m *Manager) Put(stub shim.ChaincodeStubInterface, key string, value []byte) error {
+	old, err := stub.GetState(key)
+	if err != nil {
+		return err
+	}
+	if err := stub.PutState(key, value); err != nil {
+		return err
+	}
+	return m.update(stub, key, old, value)
+}
+
+// Del deletes key and its index entries.
+func (m *Manager) Del(stub shim.ChaincodeStubInterface, key string) error {
+	old, err := stub.GetState(key)
+	if err != nil {
+		return err
+	}
+	if err := stub.DelState(key); err != nil {
+		return err
+	}
+	return m.update(stub, key, old, nil)
+}
+
+// update replaces the index entries of key derived from old by those derived
+// from value. A nil old or value has no entries.
+func (m *Manager) update(stub shim.ChaincodeStubInterface, key string, old, value []byte) error {
+	for _, idx := range m.indexes {
+		oldEntry, err := m.entry(stub, idx, key, old)
+		if err != nil {
+			return err
+		}
+		newEntry, err := m.entry(stub, idx, key, value)
+		if err != nil {
+			return err
+		}
+		if oldEntry == newEntry {
+			continue
+		}
+		if oldEntry != "" {
+			if err := stub.DelState(oldEntry); err != nil {
+				return err
+			}
+		}
+		if newEntry != "" {
+			if err := stub.PutState(newEntry, entryValue); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// entry returns the composite key of the entry of key in idx for value, or
+// "" if value is not indexed.
+func (m *Manager) entry(stub shim.ChaincodeStubInterface, idx Index, key string, value []byte) (string, error) {
+	if len(value) == 0 {
+		return "", nil
+	}
+	attrs, err := idx.Extract(value)
+	if err != nil {
+		return "", fmt.Errorf("failed to extract attributes of %s for index %s: %s", key, idx.Name, err)
+	}
+	if attrs == nil {
+		return "", nil
+	}
+	entry, err := stub.CreateCompositeKey(idx.Name, append(attrs, key))
+	if err != nil {
+		return "", fmt.Errorf("failed to create entry of %s for index %s: %s", key, idx.Name, err)
+	}
+	return entry, nil
+}
+
+// Query returns the primary keys indexed by index under attributes starting
+// with attrs, ordered by attributes and then by key.
+func (m *Manager) Query(stub shim.ChaincodeStubInterface, index string, attrs ...string) ([]string, error) {
+	if !m.has(index) {
+		return nil, fmt.Errorf("unknown index %s", index)
+	}
+	iter, err := stub.GetStateByPartialCompositeKey(index, attrs)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Close()
+
+	var keys []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		_, parts, err := stub.SplitCompositeKey(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		if len(parts) == 0 {
+			return nil, fmt.Errorf("malformed entry %q in index %s", kv.Key, index)
+		}
+		keys = append(keys, parts[len(parts)-1])
+	}
+	return keys, nil
+}
+
+func (m *Manager) has(index string) bool {
+	for _, idx := range m.indexes {
+		if idx.Name == index {
+			return true
+		}
+	}
+	return false
+}
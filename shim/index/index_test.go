@@ -0,0 +1,111 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package index_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/index"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFields(t *testing.T) {
+	extract := index.Fields("owner", "size")
+
+	attrs, err := extract([]byte(`{"owner":"alice","size":5}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alice", "5"}, attrs)
+
+	attrs, err = extract([]byte(`{"owner":"alice","size":null}`))
+	assert.NoError(t, err)
+	assert.Nil(t, attrs)
+
+	attrs, err = extract([]byte(`{"owner":"alice"}`))
+	assert.NoError(t, err)
+	assert.Nil(t, attrs)
+
+	_, err = extract([]byte(`not json`))
+	assert.Contains(t, err.Error(), "failed to unmarshal object")
+}
+
+func TestNewManager(t *testing.T) {
+	extract := index.Fields("owner")
+	tests := []struct {
+		name    string
+		indexes []index.Index
+		err     string
+	}{
+		{name: "Valid", indexes: []index.Index{{Name: "a", Extract: extract}, {Name: "b", Extract: extract}}},
+		{name: "EmptyName", indexes: []index.Index{{Extract: extract}}, err: "index name must not be an empty string"},
+		{name: "NoExtractor", indexes: []index.Index{{Name: "a"}}, err: "index a has no extractor"},
+		{name: "Duplicate", indexes: []index.Index{{Name: "a", Extract: extract}, {Name: "a", Extract: extract}}, err: "duplicate index a"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := index.NewManager(tt.indexes...)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestManager(t *testing.T) {
+	m, err := index.NewManager(
+		index.Index{Name: "owner~color", Extract: index.Fields("owner", "color")},
+		index.Index{Name: "color", Extract: index.Fields("color")},
+	)
+	require.NoError(t, err)
+	stub := shimtest.NewMockStub("index", nil)
+
+	stub.MockTransactionStart("tx1")
+	require.NoError(t, m.Put(stub, "asset1", []byte(`{"owner":"alice","color":"red"}`)))
+	require.NoError(t, m.Put(stub, "asset2", []byte(`{"owner":"bob","color":"red"}`)))
+	require.NoError(t, m.Put(stub, "asset3", []byte(`{"owner":"alice","color":"blue"}`)))
+	require.NoError(t, m.Put(stub, "asset4", []byte(`{"owner":"carol"}`)))
+	stub.MockTransactionEnd("tx1")
+
+	keys, err := m.Query(stub, "owner~color", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"asset3", "asset1"}, keys)
+	keys, err = m.Query(stub, "color", "red")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"asset1", "asset2"}, keys)
+
+	stub.MockTransactionStart("tx2")
+	require.NoError(t, m.Put(stub, "asset1", []byte(`{"owner":"bob","color":"red"}`)))
+	require.NoError(t, m.Del(stub, "asset2"))
+	stub.MockTransactionEnd("tx2")
+
+	keys, err = m.Query(stub, "owner~color", "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"asset3"}, keys)
+	keys, err = m.Query(stub, "owner~color", "bob", "red")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"asset1"}, keys)
+	keys, err = m.Query(stub, "color", "red")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"asset1"}, keys)
+
+	_, err = m.Query(stub, "missing")
+	assert.EqualError(t, err, "unknown index missing")
+}
+
+func TestManagerExtractError(t *testing.T) {
+	m, err := index.NewManager(index.Index{Name: "bad", Extract: func([]byte) ([]string, error) {
+		return nil, errors.New("boom")
+	}})
+	require.NoError(t, err)
+	stub := shimtest.NewMockStub("index", nil)
+
+	stub.MockTransactionStart("tx1")
+	err = m.Put(stub, "asset1", []byte("{}"))
+	assert.EqualError(t, err, "failed to extract attributes of asset1 for index bad: boom")
+	stub.MockTransactionEnd("tx1")
+}
@@ -0,0 +1,440 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// CallStats describes a ledger call made through a stub returned by
+// Instrument.
+type CallStats struct {
+	// TxID is the ID of the transaction which made the call.
+	TxID string
+	// TraceID is the ID of the trace of the transaction, if any, as
+	// returned by GetTraceContext.
+	TraceID string
+	// Method is the name of the stub method called.
+	Method string
+	// Collection is the private data collection of the call, if any.
+	Collection string
+	// Target is the key, range, query or chaincode the call is about.
+	Target string
+	// Duration is the time spent in the call. For queries, it includes the
+	// time spent reading the results from the iterator.
+	Duration time.Duration
+	// Bytes is the size of the values read or written.
+	Bytes int
+	// Results is the number of results read from the iterator of a query.
+	Results int
+	// Err is the error returned by the call, if any.
+	Err error
+}
+
+// CallRecorder receives the statistics of the calls made through a stub
+// returned by Instrument.
+type CallRecorder func(stats CallStats)
+
+// LogCalls returns a CallRecorder writing a log record for each call.
+func LogCalls(logger Logger) CallRecorder {
+	return func(s CallStats) {
+		target := s.Target
+		if s.Collection != "" {
+			target = s.Collection + "/" + target
+		}
+		status := "ok"
+		if s.Err != nil {
+			status = s.Err.Error()
+		}
+		tag := shorttxid(s.TxID)
+		if s.TraceID != "" {
+			tag += " trace=" + s.TraceID
+		}
+		logger.Printf("[%s] %s %s took %s, %d bytes, %d results: %s", tag, s.Method, target, s.Duration, s.Bytes, s.Results, status)
+	}
+}
+
+// Instrument returns a stub which passes the calls to stub and records the
+// duration and payload size of the calls which access the ledger or invoke
+// a chaincode. If no recorders are passed, the calls are logged to the
+// default logger of the shim.
+//
+// The statistics of a query are recorded when its iterator is closed, so
+// that they include the time spent fetching the results from the peer.
+func Instrument(stub ChaincodeStubInterface, recorders ...CallRecorder) ChaincodeStubInterface {
+	if len(recorders) == 0 {
+		recorders = []CallRecorder{LogCalls(defaultLogger)}
+	}
+	return &instrumentedStub{ChaincodeStubInterface: stub, recorders: recorders}
+}
+
+type instrumentedStub struct {
+	ChaincodeStubInterface
+	recorders []CallRecorder
+	// traceID is the ID of the trace of the transaction, if any, read on
+	// the first call.
+	traceOnce sync.Once
+	traceID   string
+}
+
+func (s *instrumentedStub) record(stats CallStats) {
+	stats.TxID = s.GetTxID()
+	s.traceOnce.Do(func() {
+		tc, _ := GetTraceContext(s.ChaincodeStubInterface)
+		s.traceID = tc.TraceID
+	})
+	stats.TraceID = s.traceID
+	for _, r := range s.recorders {
+		r(stats)
+	}
+}
+
+func (s *instrumentedStub) read(method, collection, key string, read func() ([]byte, error)) ([]byte, error) {
+	start := time.Now()
+	value, err := read()
+	s.record(CallStats{Method: method, Collection: collection, Target: key, Duration: time.Since(start), Bytes: len(value), Err: err})
+	return value, err
+}
+
+func (s *instrumentedStub) readMetadata(method, collection, key string, read func() (map[string][]byte, error)) (map[string][]byte, error) {
+	start := time.Now()
+	metadata, err := read()
+	size := 0
+	for _, value := range metadata {
+		size += len(value)
+	}
+	s.record(CallStats{Method: method, Collection: collection, Target: key, Duration: time.Since(start), Bytes: size, Err: err})
+	return metadata, err
+}
+
+func (s *instrumentedStub) write(method, collection, key string, size int, write func() error) error {
+	start := time.Now()
+	err := write()
+	s.record(CallStats{Method: method, Collection: collection, Target: key, Duration: time.Since(start), Bytes: size, Err: err})
+	return err
+}
+
+func (s *instrumentedStub) query(method, collection, target string, query func() (StateQueryIteratorInterface, error)) (StateQueryIteratorInterface, error) {
+	start := time.Now()
+	iter, err := query()
+	stats := CallStats{Method: method, Collection: collection, Target: target}
+	if err != nil {
+		stats.Duration, stats.Err = time.Since(start), err
+		s.record(stats)
+		return nil, err
+	}
+	return &instrumentedStateIterator{iter: iter, stub: s, stats: stats, elapsed: time.Since(start)}, nil
+}
+
+func (s *instrumentedStub) pagedQuery(method, target string, query func() (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error)) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	var metadata *pb.QueryResponseMetadata
+	iter, err := s.query(method, "", target, func() (StateQueryIteratorInterface, error) {
+		var iter StateQueryIteratorInterface
+		var err error
+		iter, metadata, err = query()
+		return iter, err
+	})
+	return iter, metadata, err
+}
+
+func (s *instrumentedStub) historyQuery(method, target string, query func() (HistoryQueryIteratorInterface, error)) (HistoryQueryIteratorInterface, error) {
+	start := time.Now()
+	iter, err := query()
+	stats := CallStats{Method: method, Target: target}
+	if err != nil {
+		stats.Duration, stats.Err = time.Since(start), err
+		s.record(stats)
+		return nil, err
+	}
+	return &instrumentedHistoryIterator{iter: iter, stub: s, stats: stats, elapsed: time.Since(start)}, nil
+}
+
+func (s *instrumentedStub) invoke(method, chaincodeName, channel string, args [][]byte, invoke func() pb.Response) pb.Response {
+	size := 0
+	for _, arg := range args {
+		size += len(arg)
+	}
+	start := time.Now()
+	res := invoke()
+	stats := CallStats{Method: method, Target: chaincodeName, Duration: time.Since(start), Bytes: size + len(res.Payload)}
+	if channel != "" {
+		stats.Target += "@" + channel
+	}
+	if res.Status >= ERRORTHRESHOLD {
+		stats.Err = fmt.Errorf("status %d: %s", res.Status, res.Message)
+	}
+	s.record(stats)
+	return res
+}
+
+func keyRange(startKey, endKey string) string {
+	return fmt.Sprintf("[%q, %q)", startKey, endKey)
+}
+
+func compositeTarget(objectType string, keys []string) string {
+	return fmt.Sprintf("%s%q", objectType, keys)
+}
+
+func (s *instrumentedStub) GetState(key string) ([]byte, error) {
+	return s.read("GetState", "", key, func() ([]byte, error) { return s.ChaincodeStubInterface.GetState(key) })
+}
+
+func (s *instrumentedStub) PutState(key string, value []byte) error {
+	return s.write("PutState", "", key, len(value), func() error { return s.ChaincodeStubInterface.PutState(key, value) })
+}
+
+func (s *instrumentedStub) DelState(key string) error {
+	return s.write("DelState", "", key, 0, func() error { return s.ChaincodeStubInterface.DelState(key) })
+}
+
+func (s *instrumentedStub) SetStateValidationParameter(key string, ep []byte) error {
+	return s.write("SetStateValidationParameter", "", key, len(ep), func() error { return s.ChaincodeStubInterface.SetStateValidationParameter(key, ep) })
+}
+
+func (s *instrumentedStub) GetStateValidationParameter(key string) ([]byte, error) {
+	return s.read("GetStateValidationParameter", "", key, func() ([]byte, error) { return s.ChaincodeStubInterface.GetStateValidationParameter(key) })
+}
+
+func (s *instrumentedStub) GetStateMetadata(key string) (map[string][]byte, error) {
+	return s.readMetadata("GetStateMetadata", "", key, func() (map[string][]byte, error) { return s.ChaincodeStubInterface.GetStateMetadata(key) })
+}
+
+func (s *instrumentedStub) SetStateMetadataEntry(key, metakey string, value []byte) error {
+	return s.write("SetStateMetadataEntry", "", key, len(value), func() error { return s.ChaincodeStubInterface.SetStateMetadataEntry(key, metakey, value) })
+}
+
+func (s *instrumentedStub) GetStateByRange(startKey, endKey string) (StateQueryIteratorInterface, error) {
+	return s.query("GetStateByRange", "", keyRange(startKey, endKey), func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetStateByRange(startKey, endKey)
+	})
+}
+
+func (s *instrumentedStub) GetStateByRangeDescending(startKey, endKey string) (StateQueryIteratorInterface, error) {
+	return s.query("GetStateByRangeDescending", "", keyRange(startKey, endKey), func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetStateByRangeDescending(startKey, endKey)
+	})
+}
+
+func (s *instrumentedStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return s.pagedQuery("GetStateByRangeWithPagination", keyRange(startKey, endKey), func() (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+		return s.ChaincodeStubInterface.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	})
+}
+
+func (s *instrumentedStub) GetStateByPartialCompositeKey(objectType string, keys []string) (StateQueryIteratorInterface, error) {
+	return s.query("GetStateByPartialCompositeKey", "", compositeTarget(objectType, keys), func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetStateByPartialCompositeKey(objectType, keys)
+	})
+}
+
+func (s *instrumentedStub) GetStateByCompositeKeyRange(objectType string, startAttrs, endAttrs []string) (StateQueryIteratorInterface, error) {
+	target := fmt.Sprintf("%s[%q, %q)", objectType, startAttrs, endAttrs)
+	return s.query("GetStateByCompositeKeyRange", "", target, func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetStateByCompositeKeyRange(objectType, startAttrs, endAttrs)
+	})
+}
+
+func (s *instrumentedStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return s.pagedQuery("GetStateByPartialCompositeKeyWithPagination", compositeTarget(objectType, keys), func() (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+		return s.ChaincodeStubInterface.GetStateByPartialCompositeKeyWithPagination(objectType, keys, pageSize, bookmark)
+	})
+}
+
+func (s *instrumentedStub) GetQueryResult(query string) (StateQueryIteratorInterface, error) {
+	return s.query("GetQueryResult", "", query, func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetQueryResult(query)
+	})
+}
+
+func (s *instrumentedStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	return s.pagedQuery("GetQueryResultWithPagination", query, func() (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+		return s.ChaincodeStubInterface.GetQueryResultWithPagination(query, pageSize, bookmark)
+	})
+}
+
+func (s *instrumentedStub) GetHistoryForKey(key string) (HistoryQueryIteratorInterface, error) {
+	return s.historyQuery("GetHistoryForKey", key, func() (HistoryQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetHistoryForKey(key)
+	})
+}
+
+func (s *instrumentedStub) GetHistoryForKeyRange(key string, from, to time.Time) (HistoryQueryIteratorInterface, error) {
+	return s.historyQuery("GetHistoryForKeyRange", key, func() (HistoryQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetHistoryForKeyRange(key, from, to)
+	})
+}
+
+func (s *instrumentedStub) GetPrivateData(collection, key string) ([]byte, error) {
+	return s.read("GetPrivateData", collection, key, func() ([]byte, error) { return s.ChaincodeStubInterface.GetPrivateData(collection, key) })
+}
+
+func (s *instrumentedStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
+	return s.read("GetPrivateDataHash", collection, key, func() ([]byte, error) { return s.ChaincodeStubInterface.GetPrivateDataHash(collection, key) })
+}
+
+func (s *instrumentedStub) PutPrivateData(collection string, key string, value []byte) error {
+	return s.write("PutPrivateData", collection, key, len(value), func() error { return s.ChaincodeStubInterface.PutPrivateData(collection, key, value) })
+}
+
+func (s *instrumentedStub) DelPrivateData(collection, key string) error {
+	return s.write("DelPrivateData", collection, key, 0, func() error { return s.ChaincodeStubInterface.DelPrivateData(collection, key) })
+}
+
+func (s *instrumentedStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	return s.write("SetPrivateDataValidationParameter", collection, key, len(ep), func() error {
+		return s.ChaincodeStubInterface.SetPrivateDataValidationParameter(collection, key, ep)
+	})
+}
+
+func (s *instrumentedStub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	return s.read("GetPrivateDataValidationParameter", collection, key, func() ([]byte, error) {
+		return s.ChaincodeStubInterface.GetPrivateDataValidationParameter(collection, key)
+	})
+}
+
+func (s *instrumentedStub) GetPrivateDataMetadata(collection, key string) (map[string][]byte, error) {
+	return s.readMetadata("GetPrivateDataMetadata", collection, key, func() (map[string][]byte, error) {
+		return s.ChaincodeStubInterface.GetPrivateDataMetadata(collection, key)
+	})
+}
+
+func (s *instrumentedStub) SetPrivateDataMetadataEntry(collection, key, metakey string, value []byte) error {
+	return s.write("SetPrivateDataMetadataEntry", collection, key, len(value), func() error {
+		return s.ChaincodeStubInterface.SetPrivateDataMetadataEntry(collection, key, metakey, value)
+	})
+}
+
+func (s *instrumentedStub) GetPrivateDataByRange(collection, startKey, endKey string) (StateQueryIteratorInterface, error) {
+	return s.query("GetPrivateDataByRange", collection, keyRange(startKey, endKey), func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetPrivateDataByRange(collection, startKey, endKey)
+	})
+}
+
+func (s *instrumentedStub) GetPrivateDataByPartialCompositeKey(collection, objectType string, keys []string) (StateQueryIteratorInterface, error) {
+	return s.query("GetPrivateDataByPartialCompositeKey", collection, compositeTarget(objectType, keys), func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetPrivateDataByPartialCompositeKey(collection, objectType, keys)
+	})
+}
+
+func (s *instrumentedStub) GetPrivateDataQueryResult(collection, query string) (StateQueryIteratorInterface, error) {
+	return s.query("GetPrivateDataQueryResult", collection, query, func() (StateQueryIteratorInterface, error) {
+		return s.ChaincodeStubInterface.GetPrivateDataQueryResult(collection, query)
+	})
+}
+
+func (s *instrumentedStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	return s.invoke("InvokeChaincode", chaincodeName, channel, args, func() pb.Response {
+		return s.ChaincodeStubInterface.InvokeChaincode(chaincodeName, args, channel)
+	})
+}
+
+func (s *instrumentedStub) InvokeChaincodeWithTransient(chaincodeName string, args [][]byte, channel string, transient map[string][]byte) pb.Response {
+	return s.invoke("InvokeChaincodeWithTransient", chaincodeName, channel, args, func() pb.Response {
+		return s.ChaincodeStubInterface.InvokeChaincodeWithTransient(chaincodeName, args, channel, transient)
+	})
+}
+
+func (s *instrumentedStub) InvokeChaincodeWithResult(chaincodeName string, args [][]byte, channel string) *InvokeResult {
+	var result *InvokeResult
+	s.invoke("InvokeChaincodeWithResult", chaincodeName, channel, args, func() pb.Response {
+		result = s.ChaincodeStubInterface.InvokeChaincodeWithResult(chaincodeName, args, channel)
+		return result.Response
+	})
+	return result
+}
+
+// instrumentedStateIterator accumulates the statistics of a query until it
+// is closed.
+type instrumentedStateIterator struct {
+	iter    StateQueryIteratorInterface
+	stub    *instrumentedStub
+	stats   CallStats
+	elapsed time.Duration
+	closed  bool
+}
+
+func (i *instrumentedStateIterator) HasNext() bool {
+	start := time.Now()
+	hasNext := i.iter.HasNext()
+	i.elapsed += time.Since(start)
+	return hasNext
+}
+
+func (i *instrumentedStateIterator) Next() (*queryresult.KV, error) {
+	start := time.Now()
+	kv, err := i.iter.Next()
+	i.elapsed += time.Since(start)
+	if err != nil {
+		i.stats.Err = err
+		return nil, err
+	}
+	i.stats.Results++
+	i.stats.Bytes += len(kv.Value)
+	return kv, nil
+}
+
+func (i *instrumentedStateIterator) Close() error {
+	start := time.Now()
+	err := i.iter.Close()
+	i.elapsed += time.Since(start)
+	if !i.closed {
+		i.closed = true
+		i.stats.Duration = i.elapsed
+		if i.stats.Err == nil {
+			i.stats.Err = err
+		}
+		i.stub.record(i.stats)
+	}
+	return err
+}
+
+// instrumentedHistoryIterator accumulates the statistics of a history query
+// until it is closed.
+type instrumentedHistoryIterator struct {
+	iter    HistoryQueryIteratorInterface
+	stub    *instrumentedStub
+	stats   CallStats
+	elapsed time.Duration
+	closed  bool
+}
+
+func (i *instrumentedHistoryIterator) HasNext() bool {
+	start := time.Now()
+	hasNext := i.iter.HasNext()
+	i.elapsed += time.Since(start)
+	return hasNext
+}
+
+func (i *instrumentedHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	start := time.Now()
+	km, err := i.iter.Next()
+	i.elapsed += time.Since(start)
+	if err != nil {
+		i.stats.Err = err
+		return nil, err
+	}
+	i.stats.Results++
+	i.stats.Bytes += len(km.Value)
+	return km, nil
+}
+
+func (i *instrumentedHistoryIterator) Close() error {
+	start := time.Now()
+	err := i.iter.Close()
+	i.elapsed += time.Since(start)
+	if !i.closed {
+		i.closed = true
+		i.stats.Duration = i.elapsed
+		if i.stats.Err == nil {
+			i.stats.Err = err
+		}
+		i.stub.record(i.stats)
+	}
+	return err
+}
@@ -0,0 +1,99 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+type instrumentTestStub struct {
+	*queryStub
+	state       map[string][]byte
+	decorations map[string][]byte
+}
+
+func (s *instrumentTestStub) GetTxID() string { return "txid1234" }
+
+func (s *instrumentTestStub) GetDecorations() map[string][]byte { return s.decorations }
+
+func (s *instrumentTestStub) GetTransient() (map[string][]byte, error) { return nil, nil }
+
+func (s *instrumentTestStub) GetState(key string) ([]byte, error) {
+	return s.state[key], nil
+}
+
+func (s *instrumentTestStub) PutState(key string, value []byte) error {
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	s.state[key] = value
+	return nil
+}
+
+func (s *instrumentTestStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) peerpb.Response {
+	return Error("unknown function")
+}
+
+func TestInstrument(t *testing.T) {
+	var calls []CallStats
+	stub := Instrument(&instrumentTestStub{queryStub: &queryStub{}, state: map[string][]byte{}}, func(s CallStats) {
+		s.Duration = 0
+		calls = append(calls, s)
+	})
+
+	assert.NoError(t, stub.PutState("key", []byte("value")))
+	assert.Error(t, stub.PutState("", []byte("value")))
+	value, err := stub.GetState("key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+
+	iter, err := stub.GetQueryResult(`{"selector":{}}`)
+	assert.NoError(t, err)
+	for iter.HasNext() {
+		_, err := iter.Next()
+		assert.NoError(t, err)
+	}
+	assert.Len(t, calls, 3, "queries are recorded when closed")
+	assert.NoError(t, iter.Close())
+	assert.NoError(t, iter.Close())
+
+	stub.InvokeChaincode("othercc", [][]byte{[]byte("fn")}, "")
+
+	assert.Equal(t, []CallStats{
+		{TxID: "txid1234", Method: "PutState", Target: "key", Bytes: 5},
+		{TxID: "txid1234", Method: "PutState", Target: "", Bytes: 5, Err: errors.New("key must not be an empty string")},
+		{TxID: "txid1234", Method: "GetState", Target: "key", Bytes: 5},
+		{TxID: "txid1234", Method: "GetQueryResult", Target: `{"selector":{}}`, Results: 5},
+		{TxID: "txid1234", Method: "InvokeChaincode", Target: "othercc", Bytes: 2, Err: errors.New("status 500: unknown function")},
+	}, calls)
+}
+
+func TestInstrumentPaginated(t *testing.T) {
+	var calls []CallStats
+	stub := Instrument(&instrumentTestStub{queryStub: &queryStub{}}, func(s CallStats) {
+		calls = append(calls, s)
+	})
+
+	iter, metadata, err := stub.GetStateByRangeWithPagination("a", "z", 5, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "next", metadata.Bookmark)
+	assert.NoError(t, iter.Close())
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "GetStateByRangeWithPagination", calls[0].Method)
+	assert.Equal(t, `["a", "z")`, calls[0].Target)
+}
+
+func TestLogCalls(t *testing.T) {
+	logger := &recordingLogger{}
+	LogCalls(logger)(CallStats{TxID: "txid1234", Method: "GetPrivateData", Collection: "coll", Target: "key", Bytes: 3})
+	LogCalls(logger)(CallStats{TxID: "txid1234", Method: "GetState", Target: "key", Err: errors.New("boom")})
+	assert.Equal(t, []string{
+		"[txid1234] GetPrivateData coll/key took 0s, 3 bytes, 0 results: ok",
+		"[txid1234] GetState key took 0s, 0 bytes, 0 results: boom",
+	}, logger.lines)
+}
@@ -4,7 +4,10 @@
 package shim
 
 import (
+	"time"
+
 	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric-protos-go/common"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 )
@@ -73,6 +76,20 @@ type ChaincodeStubInterface interface {
 	// If `channel` is empty, the caller's channel is assumed.
 	InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response
 
+	// InvokeChaincodeWithTransient calls the specified chaincode like
+	// InvokeChaincode and makes `transient` available to it through
+	// GetTransient. The peer propagates the transient map of the proposal to
+	// the called chaincode and has no means to carry a different one, so
+	// `transient` must either be empty or equal to the map returned by
+	// GetTransient; otherwise an error response wrapping
+	// ErrTransientPropagation is returned without calling the chaincode.
+	InvokeChaincodeWithTransient(chaincodeName string, args [][]byte, channel string, transient map[string][]byte) pb.Response
+
+	// InvokeChaincodeWithResult calls the specified chaincode like
+	// InvokeChaincode and returns its response wrapped in an InvokeResult,
+	// along with the event set by the called chaincode, if any.
+	InvokeChaincodeWithResult(chaincodeName string, args [][]byte, channel string) *InvokeResult
+
 	// GetState returns the value of the specified `key` from the
 	// ledger. Note that GetState doesn't read data from the writeset, which
 	// has not been committed to the ledger. In other words, GetState doesn't
@@ -103,6 +120,16 @@ type ChaincodeStubInterface interface {
 	// the transaction's readset.
 	GetStateValidationParameter(key string) ([]byte, error)
 
+	// GetStateMetadata returns the metadata entries of `key`, indexed by
+	// their name. The key-level endorsement policy is the entry named
+	// after peer.MetaDataKeys_VALIDATION_PARAMETER. Note that this
+	// introduces a read dependency on `key` in the transaction's readset.
+	GetStateMetadata(key string) (map[string][]byte, error)
+
+	// SetStateMetadataEntry sets the metadata entry named `metakey` of
+	// `key`. The peer rejects the names it does not support.
+	SetStateMetadataEntry(key, metakey string, value []byte) error
+
 	// GetStateByRange returns a range iterator over a set of keys in the
 	// ledger. The iterator can be used to iterate over all keys
 	// between the startKey (inclusive) and endKey (exclusive).
@@ -117,6 +144,14 @@ type ChaincodeStubInterface interface {
 	// has not changed since transaction endorsement (phantom reads detected).
 	GetStateByRange(startKey, endKey string) (StateQueryIteratorInterface, error)
 
+	// GetStateByRangeDescending is like GetStateByRange but the keys are
+	// returned by the iterator in reverse lexical order. The peer only
+	// returns keys in lexical order, so the whole range is read from the
+	// peer and held in memory before the first key is returned. Use
+	// ReverseStateIterator on the iterator returned by GetStateByRange to
+	// retain only the last keys of a large range.
+	GetStateByRangeDescending(startKey, endKey string) (StateQueryIteratorInterface, error)
+
 	// GetStateByRangeWithPagination returns a range iterator over a set of keys in the
 	// ledger. The iterator can be used to fetch keys between the startKey (inclusive)
 	// and endKey (exclusive).
@@ -150,6 +185,22 @@ type ChaincodeStubInterface interface {
 	// has not changed since transaction endorsement (phantom reads detected).
 	GetStateByPartialCompositeKey(objectType string, keys []string) (StateQueryIteratorInterface, error)
 
+	// GetStateByCompositeKeyRange queries the state in the ledger for the
+	// composite keys of `objectType` between the composite key formed by
+	// `startAttrs` (inclusive) and the composite key formed by `endAttrs`
+	// (exclusive). Keys with additional attributes are ordered after the key
+	// formed by their leading attributes, so for example the range
+	// [customer, dateA] to [customer, dateB] returns the keys of the customer
+	// from dateA up to, but not including, dateB. An empty `startAttrs` or
+	// `endAttrs` leaves the range unbounded within `objectType`.
+	// The `objectType` and attributes are expected to have only valid utf8
+	// strings and should not contain U+0000 (nil byte) and U+10FFFF (biggest
+	// and unallocated code point).
+	// Call Close() on the returned StateQueryIteratorInterface object when done.
+	// The query is re-executed during validation phase to ensure result set
+	// has not changed since transaction endorsement (phantom reads detected).
+	GetStateByCompositeKeyRange(objectType string, startAttrs, endAttrs []string) (StateQueryIteratorInterface, error)
+
 	// GetStateByPartialCompositeKeyWithPagination queries the state in the ledger based on
 	// a given partial composite key. This function returns an iterator
 	// which can be used to iterate over the composite keys whose
@@ -231,6 +282,13 @@ type ChaincodeStubInterface interface {
 	// update ledger, and should limit use to read-only chaincode operations.
 	GetHistoryForKey(key string) (HistoryQueryIteratorInterface, error)
 
+	// GetHistoryForKeyRange returns the history of key values whose timestamp
+	// is within [from, to). A zero `from` or `to` leaves the range unbounded on
+	// that side. The history is filtered by the shim, so the peer still
+	// returns the complete history of the key; the same caveats as for
+	// GetHistoryForKey apply.
+	GetHistoryForKeyRange(key string, from, to time.Time) (HistoryQueryIteratorInterface, error)
+
 	// GetPrivateData returns the value of the specified `key` from the specified
 	// `collection`. Note that GetPrivateData doesn't read data from the
 	// private writeset, which has not been committed to the `collection`. In
@@ -272,6 +330,16 @@ type ChaincodeStubInterface interface {
 	// a read dependency on `key` in the transaction's readset.
 	GetPrivateDataValidationParameter(collection, key string) ([]byte, error)
 
+	// GetPrivateDataMetadata returns the metadata entries of the private
+	// data specified by `key`, indexed by their name. Note that this
+	// introduces a read dependency on `key` in the transaction's readset.
+	GetPrivateDataMetadata(collection, key string) (map[string][]byte, error)
+
+	// SetPrivateDataMetadataEntry sets the metadata entry named `metakey`
+	// of the private data specified by `key`. The peer rejects the names it
+	// does not support.
+	SetPrivateDataMetadataEntry(collection, key, metakey string, value []byte) error
+
 	// GetPrivateDataByRange returns a range iterator over a set of keys in a
 	// given private collection. The iterator can be used to iterate over all keys
 	// between the startKey (inclusive) and endKey (exclusive).
@@ -341,11 +409,33 @@ type ChaincodeStubInterface interface {
 	// client's timestamp and will have the same value across all endorsers.
 	GetTxTimestamp() (*timestamp.Timestamp, error)
 
+	// Now returns the timestamp of the transaction as a time.Time. Chaincode
+	// should use it rather than time.Now, whose value differs between
+	// endorsers and makes the results of a transaction nondeterministic.
+	Now() (time.Time, error)
+
 	// SetEvent allows the chaincode to set an event on the response to the
 	// proposal to be included as part of a transaction. The event will be
 	// available within the transaction in the committed block regardless of the
 	// validity of the transaction.
 	SetEvent(name string, payload []byte) error
+
+	// RWSetDigest returns a SHA-256 digest of the reads and writes made by
+	// the chaincode in the current transaction so far. The digest is
+	// computed by the shim from the calls made through this stub, see
+	// RWSetTracker, and can be embedded in events or responses so that
+	// clients can verify what the chaincode accessed.
+	RWSetDigest() []byte
+
+	// GetCollectionsConfig returns the configuration of the private data
+	// collections of the chaincode named in the proposal, including their
+	// names, member policies and peer counts, so that chaincode can validate
+	// collection arguments before using them. The configuration is queried
+	// from _lifecycle, or from lscc for chaincodes deployed with the legacy
+	// lifecycle, on the first call and cached for the rest of the
+	// transaction. In a chaincode-to-chaincode call, the proposal names the
+	// calling chaincode.
+	GetCollectionsConfig() ([]*common.StaticCollectionConfig, error)
 }
 
 // CommonIteratorInterface allows a chaincode to check whether any more result
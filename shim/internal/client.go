@@ -6,21 +6,45 @@ package internal
 import (
 	"context"
 	"crypto/tls"
+	"strings"
 	"time"
 
 	peerpb "github.com/hyperledger/fabric-protos-go/peer"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/metadata"
 )
 
 const (
-	dialTimeout        = 10 * time.Second
-	maxRecvMessageSize = 100 * 1024 * 1024 // 100 MiB
-	maxSendMessageSize = 100 * 1024 * 1024 // 100 MiB
+	dialTimeout = 10 * time.Second
+	// MaxRecvMessageSize and MaxSendMessageSize are the maximum sizes of
+	// the messages received from and sent to the peer.
+	MaxRecvMessageSize = 100 * 1024 * 1024 // 100 MiB
+	MaxSendMessageSize = 100 * 1024 * 1024 // 100 MiB
 )
 
+// serviceConfig is the default gRPC service config of the connections to
+// the peer: a single connection to the first address the peer name
+// resolves to.
+const serviceConfig = `{"loadBalancingPolicy": "pick_first"}`
+
+// dialTarget returns the gRPC target of address. Addresses without a
+// scheme are resolved with the DNS resolver, which resolves the name again
+// when the connection fails, rather than once when dialing, so that a
+// peer restarted with a new IP address is found again.
+func dialTarget(address string) string {
+	if strings.Contains(address, "://") {
+		return address
+	}
+	return "dns:///" + address
+}
+
 // NewClientConn ...
+//
+// When the ServerName of tlsConf is set, it is used to verify the
+// certificate of the peer, sent in the TLS SNI extension and used as the
+// authority of the requests in place of address.
 func NewClientConn(
 	address string,
 	tlsConf *tls.Config,
@@ -31,9 +55,10 @@ func NewClientConn(
 		grpc.WithKeepaliveParams(kaOpts),
 		grpc.WithBlock(),
 		grpc.FailOnNonTempDialError(true),
+		grpc.WithDefaultServiceConfig(serviceConfig),
 		grpc.WithDefaultCallOptions(
-			grpc.MaxCallRecvMsgSize(maxRecvMessageSize),
-			grpc.MaxCallSendMsgSize(maxSendMessageSize),
+			grpc.MaxCallRecvMsgSize(MaxRecvMessageSize),
+			grpc.MaxCallSendMsgSize(MaxSendMessageSize),
 		),
 	}
 
@@ -46,10 +71,17 @@ func NewClientConn(
 
 	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
 	defer cancel()
-	return grpc.DialContext(ctx, address, dialOpts...)
+	return grpc.DialContext(ctx, dialTarget(address), dialOpts...)
 }
 
 // NewRegisterClient ...
 func NewRegisterClient(conn *grpc.ClientConn) (peerpb.ChaincodeSupport_RegisterClient, error) {
 	return peerpb.NewChaincodeSupportClient(conn).Register(context.Background())
 }
+
+// NewRegisterClientWithMetadata is like NewRegisterClient but sends the
+// key value pairs kv as the metadata of the stream.
+func NewRegisterClientWithMetadata(conn *grpc.ClientConn, kv ...string) (peerpb.ChaincodeSupport_RegisterClient, error) {
+	ctx := metadata.AppendToOutgoingContext(context.Background(), kv...)
+	return peerpb.NewChaincodeSupportClient(conn).Register(ctx)
+}
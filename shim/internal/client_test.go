@@ -64,8 +64,8 @@ func TestMessageSizes(t *testing.T) {
 	}
 
 	server := grpc.NewServer(
-		grpc.MaxSendMsgSize(2*maxSendMessageSize),
-		grpc.MaxRecvMsgSize(2*maxRecvMessageSize),
+		grpc.MaxSendMsgSize(2*MaxSendMessageSize),
+		grpc.MaxRecvMsgSize(2*MaxRecvMessageSize),
 	)
 	pb.RegisterChaincodeSupportServer(server, testServer)
 
@@ -80,7 +80,7 @@ func TestMessageSizes(t *testing.T) {
 
 	t.Run("acceptable messaages", func(t *testing.T) {
 		acceptableMessage := &pb.ChaincodeMessage{
-			Payload: make([]byte, maxSendMessageSize-100),
+			Payload: make([]byte, MaxSendMessageSize-100),
 		}
 		sendMessages <- acceptableMessage
 		err = regClient.Send(acceptableMessage)
@@ -88,19 +88,19 @@ func TestMessageSizes(t *testing.T) {
 
 		select {
 		case m := <-receivedMessages:
-			assert.Len(t, m.Payload, maxSendMessageSize-100)
+			assert.Len(t, m.Payload, MaxSendMessageSize-100)
 		case <-time.After(waitTime):
 			t.Fatalf("acceptable message was not received by server")
 		}
 
 		msg, err := regClient.Recv()
 		assert.NoError(t, err, "failed to receive message")
-		assert.Len(t, msg.Payload, maxSendMessageSize-100)
+		assert.Len(t, msg.Payload, MaxSendMessageSize-100)
 	})
 
 	t.Run("response message is too large", func(t *testing.T) {
 		sendMessages <- &pb.ChaincodeMessage{
-			Payload: make([]byte, maxSendMessageSize+1),
+			Payload: make([]byte, MaxSendMessageSize+1),
 		}
 		err = regClient.Send(&pb.ChaincodeMessage{})
 		assert.NoError(t, err, "sending messge below size threshold should succeed")
@@ -118,7 +118,7 @@ func TestMessageSizes(t *testing.T) {
 
 	t.Run("sent message is too large", func(t *testing.T) {
 		tooBig := &pb.ChaincodeMessage{
-			Payload: make([]byte, maxSendMessageSize+1),
+			Payload: make([]byte, MaxSendMessageSize+1),
 		}
 		err = regClient.Send(tooBig)
 		assert.Error(t, err, "sending messge above size threshold should fail")
@@ -132,3 +132,21 @@ func TestMessageSizes(t *testing.T) {
 		t.Fatal("server shutdown timeout")
 	}
 }
+
+func TestDialTarget(t *testing.T) {
+	var tests = []struct {
+		address string
+		target  string
+	}{
+		{address: "peer0:7052", target: "dns:///peer0:7052"},
+		{address: "127.0.0.1:7052", target: "dns:///127.0.0.1:7052"},
+		{address: "dns://8.8.8.8/peer0:7052", target: "dns://8.8.8.8/peer0:7052"},
+		{address: "passthrough:///peer0:7052", target: "passthrough:///peer0:7052"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.address, func(t *testing.T) {
+			assert.Equal(t, test.target, dialTarget(test.address))
+		})
+	}
+}
@@ -12,11 +12,18 @@ import (
 	"io/ioutil"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"google.golang.org/grpc/keepalive"
 )
 
+// ServerNameOverrideEnv sets the name used to verify the TLS certificate
+// of the peer, and sent in the TLS SNI extension, when it differs from the
+// host dialed, such as when the peer is behind a TLS terminating load
+// balancer.
+const ServerNameOverrideEnv = "CORE_PEER_TLS_SERVERHOSTOVERRIDE"
+
 // Config ...
 type Config struct {
 	ChaincodeName string
@@ -26,6 +33,17 @@ type Config struct {
 
 // LoadConfig ...
 func LoadConfig() (Config, error) {
+	return loadConfig(nil)
+}
+
+// LoadConfigWithClientCertificate is like LoadConfig but the client TLS
+// certificate is obtained from getCertificate for every TLS handshake
+// instead of being loaded from the environment. TLS must be enabled.
+func LoadConfigWithClientCertificate(getCertificate func() (*tls.Certificate, error)) (Config, error) {
+	return loadConfig(getCertificate)
+}
+
+func loadConfig(getCertificate func() (*tls.Certificate, error)) (Config, error) {
 	var err error
 	tlsEnabled, err := strconv.ParseBool(os.Getenv("CORE_PEER_TLS_ENABLED"))
 	if err != nil {
@@ -42,54 +60,64 @@ func LoadConfig() (Config, error) {
 		},
 	}
 
+	serverName := os.Getenv(ServerNameOverrideEnv)
 	if !tlsEnabled {
+		if getCertificate != nil {
+			return Config{}, errors.New("a client certificate provider requires 'CORE_PEER_TLS_ENABLED' to be 'true'")
+		}
+		if serverName != "" {
+			return Config{}, fmt.Errorf("'%s' requires 'CORE_PEER_TLS_ENABLED' to be 'true'", ServerNameOverrideEnv)
+		}
 		return conf, nil
 	}
 
-	var key []byte
-	path, set := os.LookupEnv("CORE_TLS_CLIENT_KEY_FILE")
-	if set {
-		key, err = ioutil.ReadFile(path)
+	if getCertificate != nil {
+		rootCertPool, err := loadRootCertPool()
 		if err != nil {
-			return Config{}, fmt.Errorf("failed to read private key file: %s", err)
+			return Config{}, err
 		}
-	} else {
-		data, err := ioutil.ReadFile(os.Getenv("CORE_TLS_CLIENT_KEY_PATH"))
-		if err != nil {
-			return Config{}, fmt.Errorf("failed to read private key file: %s", err)
-		}
-		key, err = base64.StdEncoding.DecodeString(string(data))
-		if err != nil {
-			return Config{}, fmt.Errorf("failed to decode private key file: %s", err)
+		conf.TLS = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    rootCertPool,
+			ServerName: serverName,
+			GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+				return getCertificate()
+			},
 		}
+		return conf, nil
 	}
 
-	var cert []byte
-	path, set = os.LookupEnv("CORE_TLS_CLIENT_CERT_FILE")
-	if set {
-		cert, err = ioutil.ReadFile(path)
-		if err != nil {
-			return Config{}, fmt.Errorf("failed to read public key file: %s", err)
-		}
-	} else {
-		data, err := ioutil.ReadFile(os.Getenv("CORE_TLS_CLIENT_CERT_PATH"))
-		if err != nil {
-			return Config{}, fmt.Errorf("failed to read public key file: %s", err)
-		}
-		cert, err = base64.StdEncoding.DecodeString(string(data))
-		if err != nil {
-			return Config{}, fmt.Errorf("failed to decode public key file: %s", err)
+	key, err := loadInline("CORE_TLS_CLIENT_KEY", "private key")
+	if err != nil {
+		return Config{}, err
+	}
+	if key == nil {
+		path, set := os.LookupEnv("CORE_TLS_CLIENT_KEY_FILE")
+		if set {
+			key, err = ioutil.ReadFile(path)
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to read private key file: %s", err)
+			}
+		} else {
+			data, err := ioutil.ReadFile(os.Getenv("CORE_TLS_CLIENT_KEY_PATH"))
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to read private key file: %s", err)
+			}
+			key, err = base64.StdEncoding.DecodeString(string(data))
+			if err != nil {
+				return Config{}, fmt.Errorf("failed to decode private key file: %s", err)
+			}
 		}
 	}
 
-	root, err := ioutil.ReadFile(os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"))
+	cert, err := LoadClientCertificate()
 	if err != nil {
-		return Config{}, fmt.Errorf("failed to read root cert file: %s", err)
+		return Config{}, err
 	}
 
-	rootCertPool := x509.NewCertPool()
-	if ok := rootCertPool.AppendCertsFromPEM(root); !ok {
-		return Config{}, errors.New("failed to load root cert file")
+	rootCertPool, err := loadRootCertPool()
+	if err != nil {
+		return Config{}, err
 	}
 	clientCert, err := tls.X509KeyPair(cert, key)
 	if err != nil {
@@ -100,7 +128,74 @@ func LoadConfig() (Config, error) {
 		MinVersion:   tls.VersionTLS12,
 		Certificates: []tls.Certificate{clientCert},
 		RootCAs:      rootCertPool,
+		ServerName:   serverName,
 	}
 
 	return conf, nil
 }
+
+// LoadClientCertificate returns the PEM encoded client TLS certificate
+// configured by the CORE_TLS_CLIENT_CERT* environment variables.
+func LoadClientCertificate() ([]byte, error) {
+	cert, err := loadInline("CORE_TLS_CLIENT_CERT", "public key")
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		return cert, nil
+	}
+	if path, set := os.LookupEnv("CORE_TLS_CLIENT_CERT_FILE"); set {
+		cert, err = ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read public key file: %s", err)
+		}
+		return cert, nil
+	}
+	data, err := ioutil.ReadFile(os.Getenv("CORE_TLS_CLIENT_CERT_PATH"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file: %s", err)
+	}
+	cert, err = base64.StdEncoding.DecodeString(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode public key file: %s", err)
+	}
+	return cert, nil
+}
+
+// loadRootCertPool returns the pool of the root certificates of the peer.
+func loadRootCertPool() (*x509.CertPool, error) {
+	root, err := loadInline("CORE_PEER_TLS_ROOTCERT", "root cert")
+	if err != nil {
+		return nil, err
+	}
+	if root == nil {
+		root, err = ioutil.ReadFile(os.Getenv("CORE_PEER_TLS_ROOTCERT_FILE"))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read root cert file: %s", err)
+		}
+	}
+
+	rootCertPool := x509.NewCertPool()
+	if ok := rootCertPool.AppendCertsFromPEM(root); !ok {
+		return nil, errors.New("failed to load root cert file")
+	}
+	return rootCertPool, nil
+}
+
+// loadInline returns the PEM data held by the environment variable env,
+// either as is or base64 encoded, or nil if env is not set. This allows TLS
+// material mounted as environment variables rather than files to be used.
+func loadInline(env, name string) ([]byte, error) {
+	value, set := os.LookupEnv(env)
+	if !set {
+		return nil, nil
+	}
+	if strings.HasPrefix(strings.TrimSpace(value), "-----BEGIN") {
+		return []byte(value), nil
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s from %s: %s", name, env, err)
+	}
+	return data, nil
+}
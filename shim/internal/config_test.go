@@ -430,4 +430,151 @@ func cleanupEnv() {
 	os.Unsetenv("CORE_TLS_CLIENT_CERT_PATH")
 	os.Unsetenv("CORE_PEER_TLS_ROOTCERT_FILE")
 	os.Unsetenv("CORE_CHAINCODE_ID_NAME")
+	os.Unsetenv("CORE_TLS_CLIENT_KEY")
+	os.Unsetenv("CORE_TLS_CLIENT_CERT")
+	os.Unsetenv("CORE_PEER_TLS_ROOTCERT")
+	os.Unsetenv("CORE_TLS_CLIENT_KEY_FILE")
+	os.Unsetenv("CORE_TLS_CLIENT_CERT_FILE")
+}
+
+func TestLoadInlineConfig(t *testing.T) {
+	defer cleanupEnv()
+
+	clientCert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatalf("Failed to load client cert pair: %s", err)
+	}
+
+	var tests = []struct {
+		name   string
+		env    map[string]string
+		errMsg string
+	}{
+		{
+			name: "PEM",
+			env: map[string]string{
+				"CORE_PEER_TLS_ENABLED":  "true",
+				"CORE_TLS_CLIENT_KEY":    keyPEM,
+				"CORE_TLS_CLIENT_CERT":   certPEM,
+				"CORE_PEER_TLS_ROOTCERT": rootPEM,
+			},
+		},
+		{
+			name: "Base64",
+			env: map[string]string{
+				"CORE_PEER_TLS_ENABLED":  "true",
+				"CORE_TLS_CLIENT_KEY":    base64.StdEncoding.EncodeToString([]byte(keyPEM)),
+				"CORE_TLS_CLIENT_CERT":   base64.StdEncoding.EncodeToString([]byte(certPEM)),
+				"CORE_PEER_TLS_ROOTCERT": base64.StdEncoding.EncodeToString([]byte(rootPEM)),
+			},
+		},
+		{
+			name: "Bad encoding",
+			env: map[string]string{
+				"CORE_PEER_TLS_ENABLED": "true",
+				"CORE_TLS_CLIENT_KEY":   "#####",
+			},
+			errMsg: "failed to decode private key from CORE_TLS_CLIENT_KEY",
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			cleanupEnv()
+			for k, v := range test.env {
+				os.Setenv(k, v)
+			}
+			conf, err := LoadConfig()
+			if test.errMsg != "" {
+				assert.Contains(t, err.Error(), test.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, clientCert.Certificate, conf.TLS.Certificates[0].Certificate)
+			assert.NotNil(t, conf.TLS.RootCAs)
+		})
+	}
+}
+
+func TestLoadConfigWithClientCertificate(t *testing.T) {
+	defer cleanupEnv()
+
+	clientCert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	if err != nil {
+		t.Fatalf("Failed to load client cert pair: %s", err)
+	}
+	getCertificate := func() (*tls.Certificate, error) { return &clientCert, nil }
+
+	cleanupEnv()
+	os.Setenv("CORE_PEER_TLS_ENABLED", "false")
+	_, err = LoadConfigWithClientCertificate(getCertificate)
+	assert.EqualError(t, err, "a client certificate provider requires 'CORE_PEER_TLS_ENABLED' to be 'true'")
+
+	os.Setenv("CORE_PEER_TLS_ENABLED", "true")
+	os.Setenv("CORE_PEER_TLS_ROOTCERT", rootPEM)
+	conf, err := LoadConfigWithClientCertificate(getCertificate)
+	assert.NoError(t, err)
+	assert.Empty(t, conf.TLS.Certificates)
+	assert.NotNil(t, conf.TLS.RootCAs)
+	cert, err := conf.TLS.GetClientCertificate(&tls.CertificateRequestInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, &clientCert, cert)
+}
+
+func TestLoadClientCertificate(t *testing.T) {
+	defer cleanupEnv()
+
+	cleanupEnv()
+	os.Setenv("CORE_TLS_CLIENT_CERT", base64.StdEncoding.EncodeToString([]byte(certPEM)))
+	cert, err := LoadClientCertificate()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(certPEM), cert)
+
+	cleanupEnv()
+	file, err := ioutil.TempFile("", "cert")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(certPEM)
+	assert.NoError(t, err)
+	file.Close()
+	os.Setenv("CORE_TLS_CLIENT_CERT_FILE", file.Name())
+	cert, err = LoadClientCertificate()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte(certPEM), cert)
+
+	cleanupEnv()
+	os.Setenv("CORE_TLS_CLIENT_CERT_PATH", "/missing")
+	_, err = LoadClientCertificate()
+	assert.Contains(t, err.Error(), "failed to read public key file")
+}
+
+func TestLoadConfigServerNameOverride(t *testing.T) {
+	defer cleanupEnv()
+	defer os.Unsetenv(ServerNameOverrideEnv)
+
+	cleanupEnv()
+	os.Setenv(ServerNameOverrideEnv, "peer0.org1.example.com")
+	os.Setenv("CORE_PEER_TLS_ENABLED", "false")
+	_, err := LoadConfig()
+	assert.EqualError(t, err, "'CORE_PEER_TLS_SERVERHOSTOVERRIDE' requires 'CORE_PEER_TLS_ENABLED' to be 'true'")
+
+	os.Setenv("CORE_PEER_TLS_ENABLED", "true")
+	os.Setenv("CORE_TLS_CLIENT_KEY", keyPEM)
+	os.Setenv("CORE_TLS_CLIENT_CERT", certPEM)
+	os.Setenv("CORE_PEER_TLS_ROOTCERT", rootPEM)
+	conf, err := LoadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, "peer0.org1.example.com", conf.TLS.ServerName)
+
+	clientCert, err := tls.X509KeyPair([]byte(certPEM), []byte(keyPEM))
+	assert.NoError(t, err)
+	conf, err = LoadConfigWithClientCertificate(func() (*tls.Certificate, error) { return &clientCert, nil })
+	assert.NoError(t, err)
+	assert.Equal(t, "peer0.org1.example.com", conf.TLS.ServerName)
+
+	os.Unsetenv(ServerNameOverrideEnv)
+	conf, err = LoadConfig()
+	assert.NoError(t, err)
+	assert.Empty(t, conf.TLS.ServerName)
 }
@@ -0,0 +1,88 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// StatusClass classifies the status of a chaincode response.
+type StatusClass int
+
+const (
+	// StatusOK is the class of statuses below ERRORTHRESHOLD.
+	StatusOK StatusClass = iota
+	// StatusClientError is the class of statuses from ERRORTHRESHOLD up to
+	// ERROR, excluded.
+	StatusClientError
+	// StatusServerError is the class of statuses from ERROR upwards.
+	StatusServerError
+)
+
+func (c StatusClass) String() string {
+	switch c {
+	case StatusOK:
+		return "OK"
+	case StatusClientError:
+		return "client error"
+	case StatusServerError:
+		return "server error"
+	default:
+		return fmt.Sprintf("StatusClass(%d)", int(c))
+	}
+}
+
+// InvokeResult is the result of a chaincode to chaincode invocation.
+type InvokeResult struct {
+	// Response is the response of the called chaincode.
+	Response pb.Response
+	// Event is the event set by the called chaincode, if any.
+	Event *pb.ChaincodeEvent
+}
+
+// StatusClass returns the class of the response status.
+func (r *InvokeResult) StatusClass() StatusClass {
+	switch {
+	case r.Response.Status < ERRORTHRESHOLD:
+		return StatusOK
+	case r.Response.Status < ERROR:
+		return StatusClientError
+	default:
+		return StatusServerError
+	}
+}
+
+// IsOK returns true if the called chaincode returned a successful response.
+func (r *InvokeResult) IsOK() bool {
+	return r.StatusClass() == StatusOK
+}
+
+// PayloadJSON unmarshals the JSON payload of a successful response into
+// out. An error is returned if the response is not successful.
+func (r *InvokeResult) PayloadJSON(out interface{}) error {
+	if !r.IsOK() {
+		// the shim reports invocation failures in the payload, which may
+		// also hold data
+		msg := r.Response.Message
+		if msg == "" {
+			msg = Redact(r.Response.Payload)
+		}
+		return fmt.Errorf("chaincode returned %s status %d: %s", r.StatusClass(), r.Response.Status, msg)
+	}
+	if err := json.Unmarshal(r.Response.Payload, out); err != nil {
+		return fmt.Errorf("failed to unmarshal chaincode payload: %s", err)
+	}
+	return nil
+}
+
+// MustPayloadJSON is like PayloadJSON but panics if the payload cannot be
+// unmarshaled.
+func (r *InvokeResult) MustPayloadJSON(out interface{}) {
+	if err := r.PayloadJSON(out); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvokeResultStatusClass(t *testing.T) {
+	var tests = []struct {
+		status   int32
+		expected StatusClass
+	}{
+		{status: OK, expected: StatusOK},
+		{status: 399, expected: StatusOK},
+		{status: ERRORTHRESHOLD, expected: StatusClientError},
+		{status: 404, expected: StatusClientError},
+		{status: ERROR, expected: StatusServerError},
+		{status: 503, expected: StatusServerError},
+	}
+
+	for _, test := range tests {
+		r := &InvokeResult{Response: pb.Response{Status: test.status}}
+		assert.Equal(t, test.expected, r.StatusClass(), "status %d", test.status)
+		assert.Equal(t, test.expected == StatusOK, r.IsOK(), "status %d", test.status)
+	}
+	assert.Equal(t, "client error", StatusClientError.String())
+}
+
+func TestInvokeResultPayloadJSON(t *testing.T) {
+	var out struct{ Name string }
+
+	r := &InvokeResult{Response: Success([]byte(`{"Name":"marble"}`))}
+	assert.NoError(t, r.PayloadJSON(&out))
+	assert.Equal(t, "marble", out.Name)
+
+	r = &InvokeResult{Response: Success([]byte(`{`))}
+	assert.EqualError(t, r.PayloadJSON(&out), "failed to unmarshal chaincode payload: unexpected end of JSON input")
+	assert.Panics(t, func() { r.MustPayloadJSON(&out) })
+
+	r = &InvokeResult{Response: pb.Response{Status: 404, Message: "not found"}}
+	assert.EqualError(t, r.PayloadJSON(&out), "chaincode returned client error status 404: not found")
+
+	r = &InvokeResult{Response: pb.Response{Status: ERROR, Payload: []byte("failed")}}
+	assert.EqualError(t, r.PayloadJSON(&out), "chaincode returned server error status 500: <redacted 6 bytes>")
+
+	defer func(enabled bool) { logPayloads = enabled }(logPayloads)
+	logPayloads = true
+	assert.EqualError(t, r.PayloadJSON(&out), "chaincode returned server error status 500: failed")
+}
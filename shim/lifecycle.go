@@ -0,0 +1,66 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// errStreamEOF is returned by chat when the peer closes the stream.
+var errStreamEOF = errors.New("received EOF, ending chaincode stream")
+
+// LifecycleHooks are callbacks invoked as the stream to the peer changes
+// state, for example to update a health endpoint or flush buffers. Unset
+// callbacks are ignored. The callbacks are invoked from the goroutine
+// receiving messages from the peer and must not block. With WithStreams,
+// they are invoked for each stream.
+type LifecycleHooks struct {
+	// OnRegister is invoked when the peer acknowledges the registration of
+	// the chaincode.
+	OnRegister func()
+	// OnReady is invoked when the peer is ready to send transactions.
+	OnReady func()
+	// OnError is invoked with the error ending the stream, unless the
+	// peer closed the stream.
+	OnError func(err error)
+	// OnDisconnect is invoked with the reason when the stream ends, after
+	// OnError.
+	OnDisconnect func(err error)
+	// OnComplete is invoked when an Init or Invoke transaction completes,
+	// with the message requesting it and the final message sent to the
+	// peer in response: COMPLETED, with the marshaled response and the
+	// event set by the chaincode, if any, or ERROR. It is invoked from the
+	// goroutine of the transaction, before the response is sent, and must
+	// not modify the messages.
+	OnComplete func(req, resp *pb.ChaincodeMessage)
+}
+
+// WithLifecycleHooks registers callbacks invoked as the stream to the peer
+// changes state.
+func WithLifecycleHooks(hooks LifecycleHooks) Option {
+	return func(h *Handler) error {
+		h.hooks = hooks
+		return nil
+	}
+}
+
+// disconnected invokes the hooks for the end of the stream with err.
+func (h *Handler) disconnected(err error) {
+	if err != errStreamEOF && h.hooks.OnError != nil {
+		h.hooks.OnError(err)
+	}
+	if h.hooks.OnDisconnect != nil {
+		h.hooks.OnDisconnect(err)
+	}
+}
+
+// completed invokes the hook for the completion of the transaction req
+// with the response resp.
+func (h *Handler) completed(req, resp *pb.ChaincodeMessage) {
+	if h.hooks.OnComplete != nil {
+		h.hooks.OnComplete(req, resp)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLifecycleHooks(t *testing.T) {
+	var tests = []struct {
+		name        string
+		recvErr     error
+		expectedErr string
+		events      []string
+	}{
+		{
+			name:        "Disconnect",
+			recvErr:     io.EOF,
+			expectedErr: "received EOF, ending chaincode stream",
+			events:      []string{"register", "ready", "disconnect: received EOF, ending chaincode stream"},
+		},
+		{
+			name:        "Error",
+			recvErr:     errors.New("recvError"),
+			expectedErr: "receive failed: recvError",
+			events:      []string{"register", "ready", "error: receive failed: recvError", "disconnect: receive failed: recvError"},
+		},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			stream := &mock.PeerChaincodeStream{}
+			stream.RecvReturnsOnCall(0, &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}, nil)
+			stream.RecvReturnsOnCall(1, &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_READY}, nil)
+			stream.RecvReturnsOnCall(2, nil, test.recvErr)
+
+			var events []string
+			hooks := LifecycleHooks{
+				OnRegister:   func() { events = append(events, "register") },
+				OnReady:      func() { events = append(events, "ready") },
+				OnError:      func(err error) { events = append(events, "error: "+err.Error()) },
+				OnDisconnect: func(err error) { events = append(events, "disconnect: "+err.Error()) },
+			}
+			err := chatWithPeer("cc", stream, &mockChaincode{}, WithLifecycleHooks(hooks))
+			assert.EqualError(t, err, test.expectedErr)
+			assert.Equal(t, test.events, events)
+		})
+	}
+}
+
+func TestLifecycleHooksUnset(t *testing.T) {
+	stream := &mock.PeerChaincodeStream{}
+	stream.RecvReturnsOnCall(0, &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}, nil)
+	stream.RecvReturnsOnCall(1, nil, io.EOF)
+
+	err := chatWithPeer("cc", stream, &mockChaincode{}, WithLifecycleHooks(LifecycleHooks{}))
+	assert.EqualError(t, err, "received EOF, ending chaincode stream")
+}
+
+type eventChaincode struct{}
+
+func (eventChaincode) Init(stub ChaincodeStubInterface) peerpb.Response {
+	return Error("init failed")
+}
+
+func (eventChaincode) Invoke(stub ChaincodeStubInterface) peerpb.Response {
+	if err := stub.SetEvent("sent", []byte("payload")); err != nil {
+		return Error(err.Error())
+	}
+	return Success([]byte("result"))
+}
+
+func TestLifecycleHooksOnComplete(t *testing.T) {
+	stream := &mock.PeerChaincodeStream{}
+	var requests, responses []*peerpb.ChaincodeMessage
+	hooks := LifecycleHooks{
+		OnComplete: func(req, resp *peerpb.ChaincodeMessage) {
+			requests = append(requests, req)
+			responses = append(responses, resp)
+		},
+	}
+	h, err := newChaincodeHandler(stream, eventChaincode{}, WithLifecycleHooks(hooks))
+	require.NoError(t, err)
+
+	input, err := proto.Marshal(&peerpb.ChaincodeInput{Args: [][]byte{[]byte("fn")}})
+	require.NoError(t, err)
+	errc := make(chan error, 3)
+	invoke := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, ChannelId: "channel", Txid: "txid", Payload: input}
+	h.handleStubInteraction(h.handleTransaction, invoke, errc)
+	init := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_INIT, ChannelId: "channel", Txid: "txid2", Payload: input}
+	h.handleStubInteraction(h.handleInit, init, errc)
+	bad := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, ChannelId: "channel", Txid: "txid3", Payload: []byte("bad")}
+	h.handleStubInteraction(h.handleTransaction, bad, errc)
+	for i := 0; i < 3; i++ {
+		assert.NoError(t, <-errc)
+	}
+
+	assert.Equal(t, []*peerpb.ChaincodeMessage{invoke, init, bad}, requests)
+	require.Len(t, responses, 3)
+	assert.Equal(t, peerpb.ChaincodeMessage_COMPLETED, responses[0].Type)
+	assert.Equal(t, &peerpb.ChaincodeEvent{EventName: "sent", Payload: []byte("payload")}, responses[0].ChaincodeEvent)
+	res := &peerpb.Response{}
+	require.NoError(t, proto.Unmarshal(responses[0].Payload, res))
+	assert.Equal(t, []byte("result"), res.Payload)
+	assert.Equal(t, peerpb.ChaincodeMessage_ERROR, responses[1].Type)
+	assert.Equal(t, "init failed", string(responses[1].Payload))
+	assert.Equal(t, peerpb.ChaincodeMessage_ERROR, responses[2].Type)
+
+	// the hook sees the messages sent to the peer, which are sent
+	// asynchronously
+	require.Equal(t, 3, stream.SendCallCount())
+	var sent []*peerpb.ChaincodeMessage
+	for i := 0; i < 3; i++ {
+		sent = append(sent, stream.SendArgsForCall(i))
+	}
+	assert.ElementsMatch(t, responses, sent)
+}
@@ -0,0 +1,187 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package lint checks chaincode sources for constructs that make the
+// results of transactions differ between endorsers.
+//
+// WallClock reports the uses of the wall clock in the chaincode callbacks,
+// which should use the transaction timestamp returned by stub.Now instead.
+// It is meant to be run from a test of the chaincode:
+//
+//	func TestDeterministicTime(t *testing.T) {
+//		findings, err := lint.WallClock(".")
+//		require.NoError(t, err)
+//		for _, f := range findings {
+//			t.Error(f)
+//		}
+//	}
+package lint
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// wallClockFuncs are the functions of the time package reading the wall
+// clock.
+var wallClockFuncs = map[string]bool{
+	"Now":   true,
+	"Since": true,
+	"Until": true,
+}
+
+// callbackParams are the names of the types whose presence among the
+// parameters of a function makes it a chaincode callback.
+var callbackParams = map[string]bool{
+	"ChaincodeStubInterface":      true,
+	"TransactionContextInterface": true,
+}
+
+// Finding is a use of the wall clock in a chaincode callback.
+type Finding struct {
+	// Pos is the position of the call.
+	Pos token.Position
+	// Func is the name of the callback.
+	Func string
+	// Call is the call reading the wall clock, such as time.Now.
+	Call string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s called in %s; use the transaction timestamp returned by stub.Now", f.Pos, f.Call, f.Func)
+}
+
+// WallClock returns the calls to time.Now, time.Since and time.Until in the
+// chaincode callbacks of the Go files at paths. A path is either a file or a
+// directory, of which the files other than tests are checked. Callbacks are
+// the Init and Invoke methods and the functions taking a stub or a
+// transaction context, including the function literals they contain.
+func WallClock(paths ...string) ([]Finding, error) {
+	fset := token.NewFileSet()
+	var findings []Finding
+	for _, path := range paths {
+		files, err := goFiles(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			f, err := parser.ParseFile(fset, file, nil, 0)
+			if err != nil {
+				return nil, err
+			}
+			findings = append(findings, checkFile(fset, f)...)
+		}
+	}
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i].Pos, findings[j].Pos
+		if a.Filename != b.Filename {
+			return a.Filename < b.Filename
+		}
+		return a.Offset < b.Offset
+	})
+	return findings, nil
+}
+
+// goFiles returns the Go files at path.
+func goFiles(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	files, err := filepath.Glob(filepath.Join(path, "*.go"))
+	if err != nil {
+		return nil, err
+	}
+	var sources []string
+	for _, file := range files {
+		if !strings.HasSuffix(file, "_test.go") {
+			sources = append(sources, file)
+		}
+	}
+	return sources, nil
+}
+
+// checkFile returns the findings of f.
+func checkFile(fset *token.FileSet, f *ast.File) []Finding {
+	timeName := ""
+	for _, spec := range f.Imports {
+		if path, _ := strconv.Unquote(spec.Path.Value); path == "time" {
+			timeName = "time"
+			if spec.Name != nil {
+				timeName = spec.Name.Name
+			}
+		}
+	}
+	if timeName == "" || timeName == "_" {
+		return nil
+	}
+
+	var findings []Finding
+	for _, decl := range f.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil || !isCallback(fn) {
+			continue
+		}
+		ast.Inspect(fn.Body, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok {
+				return true
+			}
+			if name := wallClockCall(call, timeName); name != "" {
+				findings = append(findings, Finding{Pos: fset.Position(call.Pos()), Func: fn.Name.Name, Call: name})
+			}
+			return true
+		})
+	}
+	return findings
+}
+
+// isCallback returns true if fn is a chaincode callback.
+func isCallback(fn *ast.FuncDecl) bool {
+	if fn.Recv != nil && (fn.Name.Name == "Init" || fn.Name.Name == "Invoke") {
+		return true
+	}
+	for _, param := range fn.Type.Params.List {
+		typ := param.Type
+		if star, ok := typ.(*ast.StarExpr); ok {
+			typ = star.X
+		}
+		switch t := typ.(type) {
+		case *ast.Ident:
+			if callbackParams[t.Name] {
+				return true
+			}
+		case *ast.SelectorExpr:
+			if callbackParams[t.Sel.Name] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// wallClockCall returns the name of the function called by call if it reads
+// the wall clock.
+func wallClockCall(call *ast.CallExpr, timeName string) string {
+	switch fun := call.Fun.(type) {
+	case *ast.SelectorExpr:
+		if x, ok := fun.X.(*ast.Ident); ok && x.Name == timeName && wallClockFuncs[fun.Sel.Name] {
+			return "time." + fun.Sel.Name
+		}
+	case *ast.Ident:
+		if timeName == "." && wallClockFuncs[fun.Name] {
+			return "time." + fun.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,39 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package lint_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/lint"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWallClock(t *testing.T) {
+	findings, err := lint.WallClock("testdata")
+	require.NoError(t, err)
+
+	var found []string
+	for _, f := range findings {
+		found = append(found, f.String())
+	}
+	assert.Equal(t, []string{
+		filepath.Join("testdata", "aliased.go") + ":10:9: time.Until called in Transfer; use the transaction timestamp returned by stub.Now",
+		filepath.Join("testdata", "chaincode.go") + ":13:29: time.Now called in Init; use the transaction timestamp returned by stub.Now",
+		filepath.Join("testdata", "chaincode.go") + ":25:43: time.Since called in record; use the transaction timestamp returned by stub.Now",
+	}, found)
+}
+
+func TestWallClockFile(t *testing.T) {
+	findings, err := lint.WallClock(filepath.Join("testdata", "chaincode_test.go"))
+	require.NoError(t, err)
+	require.Len(t, findings, 1)
+	assert.Equal(t, "invoke", findings[0].Func)
+	assert.Equal(t, "time.Now", findings[0].Call)
+
+	_, err = lint.WallClock("missing")
+	assert.Error(t, err)
+}
@@ -0,0 +1,13 @@
+package chaincode
+
+import (
+	clock "time"
+)
+
+type contract struct{}
+
+func (contract) Transfer(ctx TransactionContextInterface) clock.Duration {
+	return clock.Until(clock.Unix(0, 0))
+}
+
+type TransactionContextInterface interface{}
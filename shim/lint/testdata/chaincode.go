@@ -0,0 +1,32 @@
+package chaincode
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+type Chaincode struct{}
+
+func (cc *Chaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success([]byte(time.Now().String()))
+}
+
+func (cc *Chaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	now, err := stub.Now()
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	return record(stub, now)
+}
+
+func record(stub shim.ChaincodeStubInterface, now time.Time) pb.Response {
+	elapsed := func() time.Duration { return time.Since(now) }
+	return shim.Success([]byte(elapsed().String()))
+}
+
+// helper is not a callback and may use the wall clock.
+func helper() time.Time {
+	return time.Now()
+}
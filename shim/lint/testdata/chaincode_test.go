@@ -0,0 +1,11 @@
+package chaincode
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+func invoke(stub shim.ChaincodeStubInterface) {
+	time.Now()
+}
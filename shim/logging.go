@@ -0,0 +1,45 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"log"
+	"os"
+	"sync/atomic"
+)
+
+// Logger is the interface the shim uses to write log records. It is
+// satisfied by *log.Logger from the standard library.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// defaultLogger writes log records to standard error.
+var defaultLogger Logger = log.New(os.Stderr, "shim: ", log.LstdFlags)
+
+// WithLogger sets the logger used by the shim. By default log records are
+// written to standard error.
+func WithLogger(logger Logger) Option {
+	return func(h *Handler) error {
+		if logger == nil {
+			return errors.New("logger must not be nil")
+		}
+		h.logger = logger
+		return nil
+	}
+}
+
+// logf writes a log record using the logger of the handler, unless the
+// handler was made quiet by a configuration reload.
+func (h *Handler) logf(format string, v ...interface{}) {
+	if atomic.LoadInt32(&h.quiet) == 1 {
+		return
+	}
+	if h.logger == nil {
+		defaultLogger.Printf(format, v...)
+		return
+	}
+	h.logger.Printf(format, v...)
+}
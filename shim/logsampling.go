@@ -0,0 +1,127 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// LogSampling limits the logging of repeated identical handler errors, such
+// as the rejection of the same malformed proposal sent again and again by
+// the peer. Errors are identical when they have the same message type and
+// text, whatever their txid.
+type LogSampling struct {
+	// Burst is the number of identical errors logged in each interval.
+	Burst int
+	// Interval is the length of the sampling window. At the end of a
+	// window in which errors were suppressed, a summary line with their
+	// number is logged.
+	Interval time.Duration
+}
+
+// DefaultLogSampling is the sampling of handler errors used unless
+// WithLogSampling is set.
+var DefaultLogSampling = LogSampling{Burst: 10, Interval: time.Minute}
+
+// WithLogSampling sets the sampling of the handler errors logged.
+func WithLogSampling(sampling LogSampling) Option {
+	if sampling.Burst <= 0 {
+		return func(*Handler) error { return errors.New("log sampling burst must be positive") }
+	}
+	if sampling.Interval <= 0 {
+		return func(*Handler) error { return errors.New("log sampling interval must be positive") }
+	}
+	// the sampler is shared by the handlers of all streams
+	sampler := newLogSampler(sampling)
+	return func(h *Handler) error {
+		h.errorSampler = sampler
+		return nil
+	}
+}
+
+// logSampler logs the first Burst records with the same key in each
+// interval and counts the others.
+type logSampler struct {
+	// afterFunc schedules the end of a window; nil means time.AfterFunc.
+	afterFunc func(time.Duration, func())
+
+	mutex sync.Mutex
+	// sampling is guarded by the mutex since it can be changed by a
+	// configuration reload.
+	sampling LogSampling
+	windows  map[string]*sampleWindow
+}
+
+type sampleWindow struct {
+	interval   time.Duration
+	logged     int
+	suppressed int
+}
+
+func newLogSampler(sampling LogSampling) *logSampler {
+	return &logSampler{sampling: sampling, windows: map[string]*sampleWindow{}}
+}
+
+// logf writes the record formatted with format and v using logf unless
+// Burst records with key were already written in the current window.
+func (s *logSampler) logf(logf func(string, ...interface{}), key, format string, v ...interface{}) {
+	s.mutex.Lock()
+	w, ok := s.windows[key]
+	if !ok {
+		w = &sampleWindow{interval: s.sampling.Interval}
+		s.windows[key] = w
+		afterFunc := s.afterFunc
+		if afterFunc == nil {
+			afterFunc = func(d time.Duration, f func()) { time.AfterFunc(d, f) }
+		}
+		afterFunc(w.interval, func() { s.endWindow(logf, key) })
+	}
+	if w.logged >= s.sampling.Burst {
+		w.suppressed++
+		s.mutex.Unlock()
+		return
+	}
+	w.logged++
+	s.mutex.Unlock()
+
+	logf(format, v...)
+}
+
+// endWindow ends the window of key, logging the number of records
+// suppressed in it.
+func (s *logSampler) endWindow(logf func(string, ...interface{}), key string) {
+	s.mutex.Lock()
+	w := s.windows[key]
+	delete(s.windows, key)
+	s.mutex.Unlock()
+
+	if w != nil && w.suppressed > 0 {
+		logf("suppressed %d repeats in the last %s of: %s", w.suppressed, w.interval, key)
+	}
+}
+
+// setSampling changes the sampling of s. The windows already open keep
+// their interval.
+func (s *logSampler) setSampling(sampling LogSampling) {
+	s.mutex.Lock()
+	s.sampling = sampling
+	s.mutex.Unlock()
+}
+
+// logHandlerError logs the failure of the handler to process msg.
+func (h *Handler) logHandlerError(msg *pb.ChaincodeMessage, err error) {
+	sampler := h.errorSampler
+	if sampler == nil {
+		sampler = defaultErrorSampler
+	}
+	key := msg.Type.String() + " failed: " + err.Error()
+	sampler.logf(h.logf, key, "[%s] %s", h.txTag(msg.ChannelId, msg.Txid), key)
+}
+
+// defaultErrorSampler samples the handler errors with DefaultLogSampling.
+var defaultErrorSampler = newLogSampler(DefaultLogSampling)
@@ -0,0 +1,85 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLogSampling(t *testing.T) {
+	var tests = []struct {
+		name        string
+		sampling    LogSampling
+		expectedErr string
+	}{
+		{name: "Valid", sampling: LogSampling{Burst: 1, Interval: time.Second}},
+		{name: "Zero Burst", sampling: LogSampling{Interval: time.Second}, expectedErr: "log sampling burst must be positive"},
+		{name: "Zero Interval", sampling: LogSampling{Burst: 1}, expectedErr: "log sampling interval must be positive"},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			_, err := newChaincodeHandler(nil, &mockChaincode{}, WithLogSampling(test.sampling))
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestHandlerErrorSampling(t *testing.T) {
+	logger := &recordingLogger{}
+	h, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithLogger(logger), WithLogSampling(LogSampling{Burst: 2, Interval: time.Minute}))
+	require.NoError(t, err)
+
+	var windowEnds []func()
+	h.errorSampler.afterFunc = func(d time.Duration, f func()) {
+		assert.Equal(t, time.Minute, d)
+		windowEnds = append(windowEnds, f)
+	}
+
+	fail := func(err error) stubHandlerFunc {
+		return func(*peerpb.ChaincodeMessage) (*peerpb.ChaincodeMessage, error) { return nil, err }
+	}
+	errc := make(chan error, 1)
+	for i := 0; i < 5; i++ {
+		msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, Txid: fmt.Sprintf("txid%d", i)}
+		h.handleStubInteraction(fail(errors.New("bad proposal")), msg, errc)
+		require.NoError(t, <-errc)
+	}
+	msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_INIT, Txid: "txid5"}
+	h.handleStubInteraction(fail(errors.New("bad proposal")), msg, errc)
+	require.NoError(t, <-errc)
+
+	assert.Equal(t, []string{
+		"[txid0] TRANSACTION failed: bad proposal",
+		"[txid1] TRANSACTION failed: bad proposal",
+		"[txid5] INIT failed: bad proposal",
+	}, logger.lines)
+
+	require.Len(t, windowEnds, 2)
+	for _, end := range windowEnds {
+		end()
+	}
+	assert.Equal(t, "suppressed 3 repeats in the last 1m0s of: TRANSACTION failed: bad proposal", logger.lines[3])
+	assert.Len(t, logger.lines, 4)
+
+	// a new window starts after the end of the previous one
+	msg = &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, Txid: "txid6"}
+	h.handleStubInteraction(fail(errors.New("bad proposal")), msg, errc)
+	require.NoError(t, <-errc)
+	assert.Equal(t, "[txid6] TRANSACTION failed: bad proposal", logger.lines[4])
+	assert.Len(t, windowEnds, 3)
+}
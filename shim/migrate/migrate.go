@@ -0,0 +1,268 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package migrate applies versioned migrations to the data of a chaincode
+// after an upgrade changes its shape.
+//
+// Each migration is applied to the simple keys starting with its prefix. A
+// migration can touch more keys than a transaction should, so migrations
+// are applied in steps: each call to Step migrates at most a batch of keys
+// and records a cursor from which the next call continues. The version of
+// the last migration applied and the cursor are stored under a composite
+// key of the "migrate" object type.
+//
+// Range queries with pagination are not allowed in transactions which
+// write, so the keys are read with GetStateByRange starting at the cursor.
+// Writes made by a migration are not visible to the reads of the same
+// step, but keys written after the cursor are read by the following steps.
+package migrate
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// stateObjectType is the object type of the composite key holding the
+// migration state.
+const stateObjectType = "migrate"
+
+// DefaultBatchSize is the number of keys migrated by a step unless another
+// batch size is passed to New.
+const DefaultBatchSize = 100
+
+// ErrMigrationsPending is the error returned by the chaincode returned by
+// Guard while migrations have not been applied.
+var ErrMigrationsPending = errors.New("migrations pending, invocations are rejected until they are applied")
+
+// Migration is a versioned change applied to the keys starting with
+// Prefix.
+type Migration struct {
+	// Version orders the migrations. Versions must be positive and
+	// increasing.
+	Version int
+	// Description describes the migration in the progress of a step.
+	Description string
+	// Prefix selects the simple keys the migration is applied to; an
+	// empty prefix selects all of them.
+	Prefix string
+	// Migrate is called with each key and its value. It changes the state
+	// with the stub, for example by putting a new value for the key.
+	Migrate func(stub shim.ChaincodeStubInterface, kv *queryresult.KV) error
+}
+
+// State is the migration state stored in the ledger.
+type State struct {
+	// Version is the version of the last migration applied.
+	Version int `json:"version"`
+	// Cursor is the next key to migrate for the migration following
+	// Version, or "" if it has not started.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// Progress describes the outcome of a step.
+type Progress struct {
+	State
+	// Migrated is the number of keys migrated by the step.
+	Migrated int `json:"migrated"`
+	// Pending is the description of the migrations not yet applied.
+	Pending []string `json:"pending,omitempty"`
+}
+
+// Migrator applies migrations.
+type Migrator struct {
+	migrations []Migration
+	batchSize  int
+}
+
+// New returns a migrator applying migrations, migrating at most batchSize
+// keys per step; zero means DefaultBatchSize.
+func New(batchSize int, migrations ...Migration) (*Migrator, error) {
+	if batchSize < 0 {
+		return nil, fmt.Errorf("batch size must not be negative, got %d", batchSize)
+	}
+	if batchSize == 0 {
+		batchSize = DefaultBatchSize
+	}
+	previous := 0
+	for _, m := range migrations {
+		if m.Version <= previous {
+			return nil, fmt.Errorf("migration version %d must be greater than %d", m.Version, previous)
+		}
+		if m.Migrate == nil {
+			return nil, fmt.Errorf("migration version %d has no migrate function", m.Version)
+		}
+		previous = m.Version
+	}
+	return &Migrator{migrations: migrations, batchSize: batchSize}, nil
+}
+
+// GetState returns the migration state.
+func GetState(stub shim.ChaincodeStubInterface) (*State, error) {
+	key, err := stub.CreateCompositeKey(stateObjectType, []string{"state"})
+	if err != nil {
+		return nil, err
+	}
+	data, err := stub.GetState(key)
+	if err != nil {
+		return nil, err
+	}
+	state := &State{}
+	if data == nil {
+		return state, nil
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal migration state: %s", err)
+	}
+	return state, nil
+}
+
+func putState(stub shim.ChaincodeStubInterface, state *State) error {
+	key, err := stub.CreateCompositeKey(stateObjectType, []string{"state"})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return stub.PutState(key, data)
+}
+
+// pending returns the migrations not yet applied in state.
+func (m *Migrator) pending(state *State) []Migration {
+	for i, migration := range m.migrations {
+		if migration.Version > state.Version {
+			return m.migrations[i:]
+		}
+	}
+	return nil
+}
+
+// Pending reports whether some migrations have not been applied.
+func (m *Migrator) Pending(stub shim.ChaincodeStubInterface) (bool, error) {
+	state, err := GetState(stub)
+	if err != nil {
+		return false, err
+	}
+	return len(m.pending(state)) > 0, nil
+}
+
+// Step migrates at most a batch of keys, continuing from the cursor of the
+// migration state, and records the new state. A migration whose keys are
+// all migrated is recorded as applied and the step continues with the next
+// one.
+func (m *Migrator) Step(stub shim.ChaincodeStubInterface) (*Progress, error) {
+	state, err := GetState(stub)
+	if err != nil {
+		return nil, err
+	}
+	progress := &Progress{}
+	pending := m.pending(state)
+	for len(pending) > 0 && progress.Migrated < m.batchSize {
+		migration := pending[0]
+		n, next, err := m.migrateBatch(stub, migration, state.Cursor, m.batchSize-progress.Migrated)
+		if err != nil {
+			return nil, fmt.Errorf("migration version %d failed: %s", migration.Version, err)
+		}
+		progress.Migrated += n
+		if next != "" {
+			state.Cursor = next
+			break
+		}
+		state.Version = migration.Version
+		state.Cursor = ""
+		pending = pending[1:]
+	}
+	if err := putState(stub, state); err != nil {
+		return nil, err
+	}
+
+	progress.State = *state
+	for _, migration := range pending {
+		progress.Pending = append(progress.Pending, fmt.Sprintf("%d: %s", migration.Version, migration.Description))
+	}
+	return progress, nil
+}
+
+// migrateBatch migrates at most limit keys of migration starting at
+// cursor. It returns the number of keys migrated and the next key to
+// migrate, or "" if the migration is complete.
+func (m *Migrator) migrateBatch(stub shim.ChaincodeStubInterface, migration Migration, cursor string, limit int) (int, string, error) {
+	start := migration.Prefix
+	if cursor != "" {
+		start = cursor
+	}
+	iter, err := stub.GetStateByRange(start, migration.Prefix+string(utf8.MaxRune))
+	if err != nil {
+		return 0, "", err
+	}
+	defer iter.Close()
+
+	n := 0
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return n, "", err
+		}
+		if len(kv.Key) > 0 && kv.Key[0] == 0 {
+			// composite keys are not in the range of simple keys
+			continue
+		}
+		if n == limit {
+			return n, kv.Key, nil
+		}
+		if err := migration.Migrate(stub, kv); err != nil {
+			return n, "", fmt.Errorf("failed to migrate key %s: %s", kv.Key, err)
+		}
+		n++
+	}
+	return n, "", nil
+}
+
+// Guard returns a chaincode which applies a step of the migrations when
+// invoked with migrateFunction, returning the Progress as JSON, and rejects
+// the other invocations of cc until all the migrations are applied. Init is
+// passed to cc.
+func (m *Migrator) Guard(cc shim.Chaincode, migrateFunction string) shim.Chaincode {
+	return &guard{cc: cc, migrator: m, function: migrateFunction}
+}
+
+type guard struct {
+	cc       shim.Chaincode
+	migrator *Migrator
+	function string
+}
+
+func (g *guard) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return g.cc.Init(stub)
+}
+
+func (g *guard) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, _ := stub.GetFunctionAndParameters()
+	if function == g.function {
+		progress, err := g.migrator.Step(stub)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		payload, err := json.Marshal(progress)
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		return shim.Success(payload)
+	}
+
+	pending, err := g.migrator.Pending(stub)
+	if err != nil {
+		return shim.Error(err.Error())
+	}
+	if pending {
+		return shim.Error(ErrMigrationsPending.Error())
+	}
+	return g.cc.Invoke(stub)
+}
@@ -0,0 +1,108 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package migrate_test
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/migrate"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func upper(stub shim.ChaincodeStubInterface, kv *queryresult.KV) error {
+	return stub.PutState(kv.Key, []byte(strings.ToUpper(string(kv.Value))))
+}
+
+func suffix(stub shim.ChaincodeStubInterface, kv *queryresult.KV) error {
+	return stub.PutState(kv.Key, append(kv.Value, '!'))
+}
+
+type echoChaincode struct{}
+
+func (echoChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success([]byte("init"))
+}
+
+func (echoChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success([]byte("invoke"))
+}
+
+func TestMigrator(t *testing.T) {
+	migrator, err := migrate.New(2,
+		migrate.Migration{Version: 1, Description: "upper case assets", Prefix: "asset", Migrate: upper},
+		migrate.Migration{Version: 3, Description: "suffix owners", Prefix: "owner", Migrate: suffix},
+	)
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("migrate", migrator.Guard(echoChaincode{}, "migrate"))
+	stub.MockTransactionStart("seed")
+	for _, key := range []string{"asset1", "asset2", "asset3", "owner1", "other"} {
+		require.NoError(t, stub.PutState(key, []byte(key)))
+	}
+	stub.MockTransactionEnd("seed")
+
+	res := stub.MockInvoke("tx1", [][]byte{[]byte("get")})
+	assert.Equal(t, int32(shim.ERROR), res.Status)
+	assert.Equal(t, migrate.ErrMigrationsPending.Error(), res.Message)
+
+	var steps []migrate.Progress
+	for i := 0; i < 3; i++ {
+		res = stub.MockInvoke("migrate", [][]byte{[]byte("migrate")})
+		require.Equal(t, int32(shim.OK), res.Status, res.Message)
+		var progress migrate.Progress
+		require.NoError(t, json.Unmarshal(res.Payload, &progress))
+		steps = append(steps, progress)
+	}
+	assert.Equal(t, []migrate.Progress{
+		{State: migrate.State{Version: 0, Cursor: "asset3"}, Migrated: 2, Pending: []string{"1: upper case assets", "3: suffix owners"}},
+		{State: migrate.State{Version: 3}, Migrated: 2},
+		{State: migrate.State{Version: 3}, Migrated: 0},
+	}, steps)
+
+	assert.Equal(t, "ASSET1", string(stub.State["asset1"]))
+	assert.Equal(t, "ASSET3", string(stub.State["asset3"]))
+	assert.Equal(t, "owner1!", string(stub.State["owner1"]))
+	assert.Equal(t, "other", string(stub.State["other"]))
+
+	res = stub.MockInvoke("tx2", [][]byte{[]byte("get")})
+	assert.Equal(t, int32(shim.OK), res.Status)
+	assert.Equal(t, "invoke", string(res.Payload))
+}
+
+func TestMigratorFailure(t *testing.T) {
+	migrator, err := migrate.New(0, migrate.Migration{Version: 1, Migrate: func(shim.ChaincodeStubInterface, *queryresult.KV) error {
+		return errors.New("bad value")
+	}})
+	require.NoError(t, err)
+
+	stub := shimtest.NewMockStub("migrate", migrator.Guard(echoChaincode{}, "migrate"))
+	stub.MockTransactionStart("seed")
+	require.NoError(t, stub.PutState("key", []byte("value")))
+	stub.MockTransactionEnd("seed")
+
+	res := stub.MockInvoke("migrate", [][]byte{[]byte("migrate")})
+	assert.Equal(t, int32(shim.ERROR), res.Status)
+	assert.Equal(t, "migration version 1 failed: failed to migrate key key: bad value", res.Message)
+
+	state, err := migrate.GetState(stub)
+	assert.NoError(t, err)
+	assert.Equal(t, &migrate.State{}, state)
+}
+
+func TestNewInvalid(t *testing.T) {
+	_, err := migrate.New(-1)
+	assert.EqualError(t, err, "batch size must not be negative, got -1")
+	_, err = migrate.New(0, migrate.Migration{Version: 2, Migrate: upper}, migrate.Migration{Version: 2, Migrate: upper})
+	assert.EqualError(t, err, "migration version 2 must be greater than 2")
+	_, err = migrate.New(0, migrate.Migration{Version: 1})
+	assert.EqualError(t, err, "migration version 1 has no migrate function")
+}
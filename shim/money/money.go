@@ -0,0 +1,368 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package money provides deterministic arithmetic for balances and prices
+// kept in the ledger. Floating point numbers must not be used for them:
+// they cannot represent most decimal fractions exactly, and rounding errors
+// accumulate over transactions.
+//
+// A Decimal is a fixed-point decimal number of arbitrary size, and its
+// String is the canonical encoding to store in the state:
+//
+//	price, err := money.Parse("12.50")
+//	...
+//	total, err := price.Mul(money.MustParse("3"))
+//	...
+//	err = stub.PutState(key, []byte(total.String()))
+//
+// The operations whose result could exceed MaxScale digits after the
+// decimal point return ErrScaleOutOfRange rather than panicking, so that
+// amounts supplied by clients cannot crash the chaincode.
+//
+// The checked int64 operations AddInt64, SubInt64 and MulInt64 report
+// overflows instead of wrapping around, for amounts kept in integers of
+// the smallest unit.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// MaxScale is the largest number of digits after the decimal point of a
+// Decimal.
+const MaxScale = 36
+
+// ErrOverflow is returned by the checked int64 operations when the result
+// does not fit in an int64.
+var ErrOverflow = errors.New("integer overflow")
+
+// ErrDivisionByZero is returned by Quo when the divisor is zero.
+var ErrDivisionByZero = errors.New("division by zero")
+
+// ErrScaleOutOfRange is returned when the scale of a decimal would be
+// negative or greater than MaxScale.
+var ErrScaleOutOfRange = fmt.Errorf("scale out of range [0, %d]", MaxScale)
+
+// RoundingMode tells how a result is rounded when digits after the decimal
+// point are dropped.
+type RoundingMode int
+
+const (
+	// RoundDown rounds towards zero, truncating the dropped digits.
+	RoundDown RoundingMode = iota
+	// RoundUp rounds away from zero.
+	RoundUp
+	// RoundHalfUp rounds to the nearest value, and halves away from zero.
+	RoundHalfUp
+	// RoundHalfEven rounds to the nearest value, and halves to the value
+	// with an even last digit, which avoids a bias when many values are
+	// rounded.
+	RoundHalfEven
+)
+
+// Decimal is the fixed-point decimal number units × 10^-scale. Decimals
+// are values: operations return new Decimals and never modify their
+// operands. The zero value is 0.
+type Decimal struct {
+	units *big.Int
+	scale int
+}
+
+// New returns the decimal units × 10^-scale, or ErrScaleOutOfRange if
+// scale is negative or greater than MaxScale.
+func New(units *big.Int, scale int) (Decimal, error) {
+	if err := checkScale(scale); err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{units: new(big.Int).Set(units), scale: scale}, nil
+}
+
+// NewFromInt64 returns the decimal units × 10^-scale, or
+// ErrScaleOutOfRange if scale is negative or greater than MaxScale.
+func NewFromInt64(units int64, scale int) (Decimal, error) {
+	if err := checkScale(scale); err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{units: big.NewInt(units), scale: scale}, nil
+}
+
+func checkScale(scale int) error {
+	if scale < 0 || scale > MaxScale {
+		return ErrScaleOutOfRange
+	}
+	return nil
+}
+
+// Parse parses the canonical encoding of a decimal returned by String: an
+// optional minus sign, the integer part without leading zeros and, if the
+// scale is not zero, a decimal point followed by scale digits. The scale
+// of the result is the number of digits after the decimal point, so
+// "1.50" has scale 2. Other notations, such as "+1", "01", ".5", "1e3" or
+// "-0", are rejected, so that each decimal has a single encoding.
+func Parse(s string) (Decimal, error) {
+	digits := s
+	negative := strings.HasPrefix(digits, "-")
+	if negative {
+		digits = digits[1:]
+	}
+	integer, fraction := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		integer, fraction = digits[:i], digits[i+1:]
+		if fraction == "" {
+			return Decimal{}, fmt.Errorf("invalid decimal %q: no digits after the decimal point", s)
+		}
+	}
+	if integer == "" {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: no digits before the decimal point", s)
+	}
+	if len(integer) > 1 && integer[0] == '0' {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: leading zero", s)
+	}
+	if len(fraction) > MaxScale {
+		return Decimal{}, fmt.Errorf("invalid decimal %q: more than %d digits after the decimal point", s, MaxScale)
+	}
+	for _, c := range integer + fraction {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("invalid decimal %q: unexpected character %q", s, c)
+		}
+	}
+
+	units, _ := new(big.Int).SetString(integer+fraction, 10)
+	if negative {
+		if units.Sign() == 0 {
+			return Decimal{}, fmt.Errorf("invalid decimal %q: negative zero", s)
+		}
+		units.Neg(units)
+	}
+	return Decimal{units: units, scale: len(fraction)}, nil
+}
+
+// MustParse is like Parse but panics if s is not a valid decimal. It is
+// meant for constants.
+func MustParse(s string) Decimal {
+	d, err := Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// int returns the units of d, which must not be modified.
+func (d Decimal) int() *big.Int {
+	if d.units == nil {
+		return new(big.Int)
+	}
+	return d.units
+}
+
+// Units returns the units of d, the value of d × 10^scale.
+func (d Decimal) Units() *big.Int {
+	return new(big.Int).Set(d.int())
+}
+
+// Scale returns the number of digits of d after the decimal point.
+func (d Decimal) Scale() int {
+	return d.scale
+}
+
+// Sign returns -1, 0 or 1 depending on whether d is negative, zero or
+// positive.
+func (d Decimal) Sign() int {
+	return d.int().Sign()
+}
+
+// IsZero reports whether d is zero.
+func (d Decimal) IsZero() bool {
+	return d.Sign() == 0
+}
+
+// Neg returns -d.
+func (d Decimal) Neg() Decimal {
+	return Decimal{units: new(big.Int).Neg(d.int()), scale: d.scale}
+}
+
+// Abs returns the absolute value of d.
+func (d Decimal) Abs() Decimal {
+	return Decimal{units: new(big.Int).Abs(d.int()), scale: d.scale}
+}
+
+// Cmp compares the values of d and e and returns -1, 0 or 1 depending on
+// whether d is less than, equal to or greater than e. Decimals of
+// different scales are compared by value, so 1.5 equals 1.50.
+func (d Decimal) Cmp(e Decimal) int {
+	a, b := align(d, e)
+	return a.Cmp(b)
+}
+
+// Add returns d + e, with the larger scale of d and e.
+func (d Decimal) Add(e Decimal) Decimal {
+	a, b := align(d, e)
+	return Decimal{units: a.Add(a, b), scale: maxInt(d.scale, e.scale)}
+}
+
+// Sub returns d - e, with the larger scale of d and e.
+func (d Decimal) Sub(e Decimal) Decimal {
+	a, b := align(d, e)
+	return Decimal{units: a.Sub(a, b), scale: maxInt(d.scale, e.scale)}
+}
+
+// Mul returns d × e, with the sum of the scales of d and e; use Rescale to
+// round it to the scale of the amounts it is combined with. It returns
+// ErrScaleOutOfRange if the sum is greater than MaxScale.
+func (d Decimal) Mul(e Decimal) (Decimal, error) {
+	scale := d.scale + e.scale
+	if err := checkScale(scale); err != nil {
+		return Decimal{}, err
+	}
+	return Decimal{units: new(big.Int).Mul(d.int(), e.int()), scale: scale}, nil
+}
+
+// Quo returns d / e rounded to scale with mode, ErrDivisionByZero if e is
+// zero, or ErrScaleOutOfRange if scale is negative or greater than
+// MaxScale.
+func (d Decimal) Quo(e Decimal, scale int, mode RoundingMode) (Decimal, error) {
+	if err := checkScale(scale); err != nil {
+		return Decimal{}, err
+	}
+	if e.IsZero() {
+		return Decimal{}, ErrDivisionByZero
+	}
+	// d / e = (d.units × 10^(scale + e.scale - d.scale)) / e.units × 10^-scale
+	num := new(big.Int).Set(d.int())
+	den := new(big.Int).Set(e.int())
+	if shift := scale + e.scale - d.scale; shift >= 0 {
+		num.Mul(num, pow10(shift))
+	} else {
+		den.Mul(den, pow10(-shift))
+	}
+	return Decimal{units: roundQuo(num, den, mode), scale: scale}, nil
+}
+
+// Rescale returns d with scale digits after the decimal point, rounded with
+// mode if digits are dropped, or ErrScaleOutOfRange if scale is negative or
+// greater than MaxScale.
+func (d Decimal) Rescale(scale int, mode RoundingMode) (Decimal, error) {
+	if err := checkScale(scale); err != nil {
+		return Decimal{}, err
+	}
+	if scale >= d.scale {
+		return Decimal{units: new(big.Int).Mul(d.int(), pow10(scale-d.scale)), scale: scale}, nil
+	}
+	return Decimal{units: roundQuo(d.int(), pow10(d.scale-scale), mode), scale: scale}, nil
+}
+
+// String returns the canonical encoding of d, which Parse accepts: the
+// integer part and, if the scale of d is not zero, a decimal point followed
+// by exactly scale digits.
+func (d Decimal) String() string {
+	units := d.int()
+	digits := new(big.Int).Abs(units).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if units.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
+
+// MarshalText encodes d as its String, so that decimals are JSON strings.
+func (d Decimal) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText decodes the encoding of a decimal with Parse.
+func (d *Decimal) UnmarshalText(text []byte) error {
+	parsed, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
+
+// align returns the units of d and e at the larger of their scales.
+func align(d, e Decimal) (*big.Int, *big.Int) {
+	a, b := new(big.Int).Set(d.int()), new(big.Int).Set(e.int())
+	switch {
+	case d.scale < e.scale:
+		a.Mul(a, pow10(e.scale-d.scale))
+	case d.scale > e.scale:
+		b.Mul(b, pow10(d.scale-e.scale))
+	}
+	return a, b
+}
+
+// roundQuo returns num / den rounded with mode.
+func roundQuo(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	sign := int64(num.Sign() * den.Sign())
+	// compare the dropped fraction |r / den| with one half
+	half := new(big.Int).Abs(r)
+	half.Mul(half, big.NewInt(2))
+	cmp := half.Cmp(new(big.Int).Abs(den))
+
+	var away bool
+	switch mode {
+	case RoundUp:
+		away = true
+	case RoundHalfUp:
+		away = cmp >= 0
+	case RoundHalfEven:
+		away = cmp > 0 || cmp == 0 && q.Bit(0) == 1
+	}
+	if away {
+		q.Add(q, big.NewInt(sign))
+	}
+	return q
+}
+
+// pow10 returns 10^n.
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// AddInt64 returns a + b, or ErrOverflow if it does not fit in an int64.
+func AddInt64(a, b int64) (int64, error) {
+	if b > 0 && a > math.MaxInt64-b || b < 0 && a < math.MinInt64-b {
+		return 0, ErrOverflow
+	}
+	return a + b, nil
+}
+
+// SubInt64 returns a - b, or ErrOverflow if it does not fit in an int64.
+func SubInt64(a, b int64) (int64, error) {
+	if b < 0 && a > math.MaxInt64+b || b > 0 && a < math.MinInt64+b {
+		return 0, ErrOverflow
+	}
+	return a - b, nil
+}
+
+// MulInt64 returns a × b, or ErrOverflow if it does not fit in an int64.
+func MulInt64(a, b int64) (int64, error) {
+	if a == 0 || b == 0 {
+		return 0, nil
+	}
+	p := a * b
+	if p/b != a || a == -1 && b == math.MinInt64 || b == -1 && a == math.MinInt64 {
+		return 0, ErrOverflow
+	}
+	return p, nil
+}
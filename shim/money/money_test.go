@@ -0,0 +1,221 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package money_test
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/money"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	var tests = []struct {
+		in    string
+		units int64
+		scale int
+		err   string
+	}{
+		{in: "0", units: 0, scale: 0},
+		{in: "12", units: 12, scale: 0},
+		{in: "-12.50", units: -1250, scale: 2},
+		{in: "0.05", units: 5, scale: 2},
+		{in: "0.00", units: 0, scale: 2},
+		{in: "", err: `invalid decimal "": no digits before the decimal point`},
+		{in: "-", err: `invalid decimal "-": no digits before the decimal point`},
+		{in: ".5", err: `invalid decimal ".5": no digits before the decimal point`},
+		{in: "1.", err: `invalid decimal "1.": no digits after the decimal point`},
+		{in: "01", err: `invalid decimal "01": leading zero`},
+		{in: "+1", err: `invalid decimal "+1": unexpected character '+'`},
+		{in: "1e3", err: `invalid decimal "1e3": unexpected character 'e'`},
+		{in: "1.2.3", err: `invalid decimal "1.2.3": unexpected character '.'`},
+		{in: " 1", err: `invalid decimal " 1": unexpected character ' '`},
+		{in: "-0.00", err: `invalid decimal "-0.00": negative zero`},
+		{in: "0.0000000000000000000000000000000000001", err: `invalid decimal "0.0000000000000000000000000000000000001": more than 36 digits after the decimal point`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			d, err := money.Parse(test.in)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, big.NewInt(test.units), d.Units())
+			assert.Equal(t, test.scale, d.Scale())
+			assert.Equal(t, test.in, d.String(), "the encoding is canonical")
+		})
+	}
+}
+
+func TestString(t *testing.T) {
+	assert.Equal(t, "0", money.Decimal{}.String())
+	for _, test := range []struct {
+		units int64
+		scale int
+		out   string
+	}{
+		{units: 5, scale: 3, out: "0.005"},
+		{units: -5, scale: 1, out: "-0.5"},
+		{units: 1234, scale: 0, out: "1234"},
+	} {
+		d, err := money.NewFromInt64(test.units, test.scale)
+		require.NoError(t, err)
+		assert.Equal(t, test.out, d.String())
+	}
+
+	large, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	require.True(t, ok)
+	d, err := money.New(large, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "1234567890123456789012345678.90", d.String())
+}
+
+func TestArithmetic(t *testing.T) {
+	a := money.MustParse("10.25")
+	b := money.MustParse("0.125")
+
+	assert.Equal(t, "10.375", a.Add(b).String())
+	assert.Equal(t, "10.125", a.Sub(b).String())
+	assert.Equal(t, "-10.125", b.Sub(a).String())
+	product, err := a.Mul(b)
+	require.NoError(t, err)
+	assert.Equal(t, "1.28125", product.String())
+	assert.Equal(t, "-10.25", a.Neg().String())
+	assert.Equal(t, "10.25", a.Neg().Abs().String())
+	assert.Equal(t, "10.25", a.String(), "operands are not modified")
+
+	// 0.1 + 0.2 is exact
+	assert.Equal(t, 0, money.MustParse("0.1").Add(money.MustParse("0.2")).Cmp(money.MustParse("0.3")))
+
+	assert.Equal(t, 0, money.MustParse("1.5").Cmp(money.MustParse("1.50")))
+	assert.Equal(t, -1, money.MustParse("1.49").Cmp(money.MustParse("1.5")))
+	assert.Equal(t, 1, money.MustParse("-1").Cmp(money.MustParse("-1.01")))
+	assert.True(t, money.MustParse("0.00").IsZero())
+	assert.Equal(t, -1, money.MustParse("-0.01").Sign())
+}
+
+func TestRescale(t *testing.T) {
+	var tests = []struct {
+		in   string
+		mode money.RoundingMode
+		out  string
+	}{
+		{in: "1.25", mode: money.RoundDown, out: "1.2"},
+		{in: "1.25", mode: money.RoundUp, out: "1.3"},
+		{in: "1.25", mode: money.RoundHalfUp, out: "1.3"},
+		{in: "1.25", mode: money.RoundHalfEven, out: "1.2"},
+		{in: "1.35", mode: money.RoundHalfEven, out: "1.4"},
+		{in: "1.26", mode: money.RoundHalfEven, out: "1.3"},
+		{in: "-1.25", mode: money.RoundDown, out: "-1.2"},
+		{in: "-1.25", mode: money.RoundUp, out: "-1.3"},
+		{in: "-1.25", mode: money.RoundHalfUp, out: "-1.3"},
+		{in: "-1.25", mode: money.RoundHalfEven, out: "-1.2"},
+		{in: "-1.24", mode: money.RoundHalfUp, out: "-1.2"},
+		{in: "1.20", mode: money.RoundUp, out: "1.2"},
+		{in: "1", mode: money.RoundDown, out: "1.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.in, func(t *testing.T) {
+			d, err := money.MustParse(test.in).Rescale(1, test.mode)
+			require.NoError(t, err)
+			assert.Equal(t, test.out, d.String())
+		})
+	}
+}
+
+func TestQuo(t *testing.T) {
+	third, err := money.MustParse("10.00").Quo(money.MustParse("3"), 2, money.RoundHalfEven)
+	require.NoError(t, err)
+	assert.Equal(t, "3.33", third.String())
+
+	q, err := money.MustParse("-2").Quo(money.MustParse("0.3"), 3, money.RoundHalfUp)
+	require.NoError(t, err)
+	assert.Equal(t, "-6.667", q.String())
+
+	q, err = money.MustParse("1.000").Quo(money.MustParse("8"), 1, money.RoundHalfEven)
+	require.NoError(t, err)
+	assert.Equal(t, "0.1", q.String())
+
+	_, err = money.MustParse("1").Quo(money.MustParse("0.00"), 2, money.RoundDown)
+	assert.Equal(t, money.ErrDivisionByZero, err)
+}
+
+func TestJSON(t *testing.T) {
+	type account struct {
+		Balance money.Decimal `json:"balance"`
+	}
+	b, err := json.Marshal(&account{Balance: money.MustParse("42.10")})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"balance": "42.10"}`, string(b))
+
+	var acc account
+	require.NoError(t, json.Unmarshal(b, &acc))
+	assert.Equal(t, "42.10", acc.Balance.String())
+
+	err = json.Unmarshal([]byte(`{"balance": "4.2e1"}`), &acc)
+	assert.EqualError(t, err, `invalid decimal "4.2e1": unexpected character 'e'`)
+}
+
+func TestCheckedInt64(t *testing.T) {
+	var tests = []struct {
+		name     string
+		op       func(a, b int64) (int64, error)
+		a, b     int64
+		result   int64
+		overflow bool
+	}{
+		{name: "Add", op: money.AddInt64, a: 1, b: 2, result: 3},
+		{name: "Add Max", op: money.AddInt64, a: math.MaxInt64, b: 1, overflow: true},
+		{name: "Add Min", op: money.AddInt64, a: math.MinInt64, b: -1, overflow: true},
+		{name: "Sub", op: money.SubInt64, a: 1, b: 2, result: -1},
+		{name: "Sub Max", op: money.SubInt64, a: math.MaxInt64, b: -1, overflow: true},
+		{name: "Sub Min", op: money.SubInt64, a: math.MinInt64, b: 1, overflow: true},
+		{name: "Mul", op: money.MulInt64, a: -3, b: 4, result: -12},
+		{name: "Mul Zero", op: money.MulInt64, a: 0, b: math.MinInt64, result: 0},
+		{name: "Mul Max", op: money.MulInt64, a: math.MaxInt64/2 + 1, b: 2, overflow: true},
+		{name: "Mul Min", op: money.MulInt64, a: math.MinInt64, b: -1, overflow: true},
+		{name: "Mul Min Reversed", op: money.MulInt64, a: -1, b: math.MinInt64, overflow: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := test.op(test.a, test.b)
+			if test.overflow {
+				assert.Equal(t, money.ErrOverflow, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.result, result)
+		})
+	}
+}
+
+func TestScaleOutOfRange(t *testing.T) {
+	_, err := money.NewFromInt64(1, -1)
+	assert.Equal(t, money.ErrScaleOutOfRange, err)
+	_, err = money.NewFromInt64(1, money.MaxScale+1)
+	assert.Equal(t, money.ErrScaleOutOfRange, err)
+	_, err = money.New(big.NewInt(1), money.MaxScale+1)
+	assert.Equal(t, money.ErrScaleOutOfRange, err)
+
+	// amounts accepted by Parse whose product has too many digits
+	amount := money.MustParse("0.1234567890123456789")
+	_, err = amount.Mul(amount)
+	assert.Equal(t, money.ErrScaleOutOfRange, err)
+
+	_, err = amount.Rescale(money.MaxScale+1, money.RoundDown)
+	assert.Equal(t, money.ErrScaleOutOfRange, err)
+	_, err = amount.Quo(amount, -1, money.RoundDown)
+	assert.Equal(t, money.ErrScaleOutOfRange, err)
+	assert.EqualError(t, err, "scale out of range [0, 36]")
+
+	assert.Panics(t, func() { money.MustParse("x") })
+}
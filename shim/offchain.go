@@ -0,0 +1,221 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultOffchainQueueSize is the number of transactions whose writes can
+// be waiting for delivery to an OffchainSink before writes are dropped.
+const DefaultOffchainQueueSize = 1000
+
+// DefaultOffchainMaxAttempts is the number of deliveries of the writes of a
+// transaction before they are dropped when OffchainPolicy.MaxAttempts is
+// zero.
+const DefaultOffchainMaxAttempts = 10
+
+// DefaultOffchainInitialBackoff is the delay before the first redelivery
+// of writes to an OffchainSink when OffchainPolicy.InitialBackoff is zero.
+const DefaultOffchainInitialBackoff = 100 * time.Millisecond
+
+// ErrOffchainQueueFull is passed to OffchainPolicy.OnDrop for the writes
+// dropped because the delivery queue is full.
+var ErrOffchainQueueFull = errors.New("offchain delivery queue is full")
+
+// OffchainWrite is a write made by a transaction.
+type OffchainWrite struct {
+	ChannelID  string
+	TxID       string
+	Collection string
+	Key        string
+	Value      []byte
+	IsDelete   bool
+}
+
+// OffchainSink receives the writes of the transactions which completed
+// successfully, so that they can be mirrored to an external store. Register
+// it with a handler using WithOffchainSink.
+//
+// The writes are those the chaincode intends to commit: the transaction may
+// still fail endorsement or validation, in which case the peer discards
+// them. Writes are delivered in the order the transactions completed and
+// redelivered after an error, so Deliver must be idempotent. The writes of
+// a transaction are dropped if the delivery queue is full or they are
+// still failing after OffchainPolicy.MaxAttempts deliveries, so that an
+// unavailable store does not stop the chaincode from endorsing; use
+// OffchainPolicy.OnDrop to reconcile them.
+// The writes to private data collections are delivered with their values.
+type OffchainSink interface {
+	// Deliver receives the writes made by a transaction, in collection and
+	// key order.
+	Deliver(writes []OffchainWrite) error
+}
+
+// OffchainPolicy configures the delivery of writes to an OffchainSink.
+type OffchainPolicy struct {
+	// QueueSize is the number of transactions whose writes can be waiting
+	// for delivery; zero means DefaultOffchainQueueSize. The writes of
+	// the transactions completing while the queue is full are dropped.
+	QueueSize int
+	// MaxAttempts is the number of deliveries of the writes of a
+	// transaction before they are dropped; zero means
+	// DefaultOffchainMaxAttempts.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first redelivery; zero means
+	// DefaultOffchainInitialBackoff. The delay doubles after every failed
+	// redelivery.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between redeliveries; zero means no cap.
+	MaxBackoff time.Duration
+	// OnDrop, if set, is called with the writes of a transaction which are
+	// dropped and the reason, ErrOffchainQueueFull or the error of the
+	// last delivery. It must not block.
+	OnDrop func(writes []OffchainWrite, err error)
+}
+
+// WithOffchainSink delivers the writes of the transactions which complete
+// successfully to sink. Deliveries are made from a separate goroutine, a
+// failed delivery is retried up to the attempts of policy and the writes
+// which cannot be queued or delivered are dropped and logged. The delivery
+// is shared
+// by the streams of WithStreams and the streams opened on reconnection,
+// so that the writes are delivered in order across the chaincode.
+func WithOffchainSink(sink OffchainSink, policy OffchainPolicy) Option {
+	d, err := newOffchainDispatcher(sink, policy)
+	return func(h *Handler) error {
+		if err != nil {
+			return err
+		}
+		h.offchain = d
+		return nil
+	}
+}
+
+func newOffchainDispatcher(sink OffchainSink, policy OffchainPolicy) (*offchainDispatcher, error) {
+	if sink == nil {
+		return nil, errors.New("offchain sink must not be nil")
+	}
+	if policy.QueueSize < 0 {
+		return nil, errors.New("offchain queue size must not be negative")
+	}
+	if policy.MaxAttempts < 0 {
+		return nil, errors.New("offchain max attempts must not be negative")
+	}
+	if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 {
+		return nil, errors.New("offchain backoff must not be negative")
+	}
+	if policy.QueueSize == 0 {
+		policy.QueueSize = DefaultOffchainQueueSize
+	}
+	if policy.MaxAttempts == 0 {
+		policy.MaxAttempts = DefaultOffchainMaxAttempts
+	}
+	if policy.InitialBackoff == 0 {
+		policy.InitialBackoff = DefaultOffchainInitialBackoff
+	}
+	return &offchainDispatcher{sink: sink, policy: policy}, nil
+}
+
+// offchainBatch is the writes of a transaction queued for delivery, with
+// the log function of the handler of the transaction.
+type offchainBatch struct {
+	writes []OffchainWrite
+	logf   func(format string, v ...interface{})
+}
+
+// offchainDispatcher delivers writes to an OffchainSink in order.
+type offchainDispatcher struct {
+	sink   OffchainSink
+	policy OffchainPolicy
+	once   sync.Once
+	queue  chan offchainBatch
+	// pending is the number of batches queued or being delivered.
+	pending int32
+	// dropped is the number of batches dropped.
+	dropped int64
+	// sleep waits between deliveries; nil means time.Sleep.
+	sleep func(time.Duration)
+}
+
+// enqueue queues writes for delivery, starting the delivery goroutine on
+// first use, or drops them if the queue is full. Delivery failures are
+// logged with logf.
+func (d *offchainDispatcher) enqueue(writes []OffchainWrite, logf func(format string, v ...interface{})) {
+	if len(writes) == 0 {
+		return
+	}
+	d.once.Do(func() {
+		d.queue = make(chan offchainBatch, d.policy.QueueSize)
+		go d.run()
+	})
+	atomic.AddInt32(&d.pending, 1)
+	select {
+	case d.queue <- offchainBatch{writes: writes, logf: logf}:
+	default:
+		atomic.AddInt32(&d.pending, -1)
+		d.drop(writes, ErrOffchainQueueFull, logf)
+	}
+}
+
+// drop counts and logs writes which are not delivered.
+func (d *offchainDispatcher) drop(writes []OffchainWrite, err error, logf func(format string, v ...interface{})) {
+	atomic.AddInt64(&d.dropped, 1)
+	logf("[%s] dropping %d offchain writes: %s", shorttxid(writes[0].TxID), len(writes), err)
+	if d.policy.OnDrop != nil {
+		d.policy.OnDrop(writes, err)
+	}
+}
+
+func (d *offchainDispatcher) run() {
+	for batch := range d.queue {
+		d.deliver(batch.writes, batch.logf)
+		atomic.AddInt32(&d.pending, -1)
+	}
+}
+
+// deliver calls the sink until it accepts writes or the attempts are
+// exhausted.
+func (d *offchainDispatcher) deliver(writes []OffchainWrite, logf func(format string, v ...interface{})) {
+	sleep := d.sleep
+	if sleep == nil {
+		sleep = time.Sleep
+	}
+	backoff := d.policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := d.sink.Deliver(writes)
+		if err == nil {
+			return
+		}
+		if attempt >= d.policy.MaxAttempts {
+			d.drop(writes, err, logf)
+			return
+		}
+		logf("[%s] offchain delivery failed (attempt %d), retrying in %s: %s", shorttxid(writes[0].TxID), attempt, backoff, err)
+		sleep(backoff)
+		backoff *= 2
+		if d.policy.MaxBackoff > 0 && backoff > d.policy.MaxBackoff {
+			backoff = d.policy.MaxBackoff
+		}
+	}
+}
+
+// offchainWrites returns the writes recorded by the stub.
+func offchainWrites(stub *ChaincodeStub) []OffchainWrite {
+	var writes []OffchainWrite
+	stub.rwset.eachWrite(func(collection, key string, value []byte, isDelete bool) {
+		writes = append(writes, OffchainWrite{
+			ChannelID:  stub.ChannelID,
+			TxID:       stub.TxID,
+			Collection: collection,
+			Key:        key,
+			Value:      value,
+			IsDelete:   isDelete,
+		})
+	})
+	return writes
+}
@@ -0,0 +1,190 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sinkFunc func(writes []OffchainWrite) error
+
+func (f sinkFunc) Deliver(writes []OffchainWrite) error {
+	return f(writes)
+}
+
+func TestWithOffchainSinkInvalid(t *testing.T) {
+	sink := sinkFunc(func([]OffchainWrite) error { return nil })
+	tests := []struct {
+		name   string
+		option Option
+		errMsg string
+	}{
+		{"NilSink", WithOffchainSink(nil, OffchainPolicy{}), "offchain sink must not be nil"},
+		{"NegativeQueueSize", WithOffchainSink(sink, OffchainPolicy{QueueSize: -1}), "offchain queue size must not be negative"},
+		{"NegativeMaxAttempts", WithOffchainSink(sink, OffchainPolicy{MaxAttempts: -1}), "offchain max attempts must not be negative"},
+		{"NegativeBackoff", WithOffchainSink(sink, OffchainPolicy{MaxBackoff: -1}), "offchain backoff must not be negative"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, tt.option)
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestDeliverOffchain(t *testing.T) {
+	delivered := make(chan []OffchainWrite, 2)
+	sink := sinkFunc(func(writes []OffchainWrite) error {
+		delivered <- writes
+		return nil
+	})
+	h, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithOffchainSink(sink, OffchainPolicy{}))
+	require.NoError(t, err)
+
+	stub := &ChaincodeStub{ChannelID: "channel", TxID: "txid", rwset: RWSetTracker{keepValues: true}}
+	stub.rwset.Write("", "b", []byte("value"), false)
+	stub.rwset.Write("collection", "a", nil, true)
+	stub.rwset.Write("", "a", []byte("old"), false)
+	stub.rwset.Write("", "a", []byte("new"), false)
+
+	h.deliverOffchain(stub, Error("failed"))
+	h.deliverOffchain(&ChaincodeStub{ChannelID: "channel", TxID: "empty"}, Success(nil))
+	h.deliverOffchain(stub, Success(nil))
+
+	assert.Equal(t, []OffchainWrite{
+		{ChannelID: "channel", TxID: "txid", Key: "a", Value: []byte("new")},
+		{ChannelID: "channel", TxID: "txid", Key: "b", Value: []byte("value")},
+		{ChannelID: "channel", TxID: "txid", Collection: "collection", Key: "a", IsDelete: true},
+	}, <-delivered)
+	assert.Len(t, delivered, 0)
+}
+
+func TestOffchainDispatcherRetries(t *testing.T) {
+	attempts := 0
+	sink := sinkFunc(func([]OffchainWrite) error {
+		attempts++
+		if attempts < 4 {
+			return errors.New("store unavailable")
+		}
+		return nil
+	})
+	var delays []time.Duration
+	d := &offchainDispatcher{
+		sink:   sink,
+		policy: OffchainPolicy{MaxAttempts: 5, InitialBackoff: 10 * time.Millisecond, MaxBackoff: 30 * time.Millisecond},
+		sleep:  func(d time.Duration) { delays = append(delays, d) },
+	}
+
+	d.deliver([]OffchainWrite{{TxID: "txid", Key: "key"}}, func(string, ...interface{}) {})
+	assert.Equal(t, 4, attempts)
+	assert.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 30 * time.Millisecond}, delays)
+}
+
+func TestOffchainDispatcherGivesUp(t *testing.T) {
+	attempts := 0
+	unavailable := errors.New("store unavailable")
+	sink := sinkFunc(func([]OffchainWrite) error {
+		attempts++
+		return unavailable
+	})
+	var dropped []error
+	d, err := newOffchainDispatcher(sink, OffchainPolicy{
+		MaxAttempts: 3,
+		OnDrop:      func(writes []OffchainWrite, err error) { dropped = append(dropped, err) },
+	})
+	require.NoError(t, err)
+	var delays []time.Duration
+	d.sleep = func(d time.Duration) { delays = append(delays, d) }
+	logger := &recordingLogger{}
+
+	d.deliver([]OffchainWrite{{TxID: "txid", Key: "key"}}, logger.Printf)
+	assert.Equal(t, 3, attempts)
+	assert.Len(t, delays, 2)
+	assert.Equal(t, []error{unavailable}, dropped)
+	assert.Equal(t, int64(1), d.dropped)
+	assert.Contains(t, logger.lines, "[txid] dropping 1 offchain writes: store unavailable")
+}
+
+// writingChaincode writes a key in each transaction.
+type writingChaincode struct{}
+
+func (writingChaincode) Init(stub ChaincodeStubInterface) peerpb.Response {
+	return Success(nil)
+}
+
+func (writingChaincode) Invoke(stub ChaincodeStubInterface) peerpb.Response {
+	stub.(*ChaincodeStub).rwset.Write("", "key", []byte("value"), false)
+	return Success(nil)
+}
+
+func TestOffchainDeadSinkDoesNotBlock(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	sink := sinkFunc(func([]OffchainWrite) error {
+		<-release
+		return nil
+	})
+	dropped := make(chan error, 3)
+	policy := OffchainPolicy{
+		QueueSize: 1,
+		OnDrop:    func(writes []OffchainWrite, err error) { dropped <- err },
+	}
+	h, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, writingChaincode{}, WithOffchainSink(sink, policy), WithLogger(&recordingLogger{}))
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 3; i++ {
+			msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, ChannelId: "channel", Txid: fmt.Sprintf("tx%d", i), Payload: marshalOrPanic(&peerpb.ChaincodeInput{})}
+			resp, err := h.handleTransaction(msg)
+			assert.NoError(t, err)
+			assert.Equal(t, peerpb.ChaincodeMessage_COMPLETED, resp.Type)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("transactions blocked on the offchain sink")
+	}
+	assert.Equal(t, ErrOffchainQueueFull, <-dropped)
+}
+
+func TestOffchainDispatcherDefaultBackoff(t *testing.T) {
+	attempts := 0
+	sink := sinkFunc(func([]OffchainWrite) error {
+		attempts++
+		if attempts < 3 {
+			return errors.New("store unavailable")
+		}
+		return nil
+	})
+	d, err := newOffchainDispatcher(sink, OffchainPolicy{})
+	require.NoError(t, err)
+	var delays []time.Duration
+	d.sleep = func(d time.Duration) { delays = append(delays, d) }
+
+	d.deliver([]OffchainWrite{{TxID: "txid", Key: "key"}}, func(string, ...interface{}) {})
+	assert.Equal(t, []time.Duration{DefaultOffchainInitialBackoff, 2 * DefaultOffchainInitialBackoff}, delays)
+}
+
+func TestWithOffchainSinkShared(t *testing.T) {
+	sink := sinkFunc(func([]OffchainWrite) error { return nil })
+	opts := []Option{WithOffchainSink(sink, OffchainPolicy{})}
+
+	h1, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, opts...)
+	require.NoError(t, err)
+	h2, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, opts...)
+	require.NoError(t, err)
+	require.NotNil(t, h1.offchain)
+	assert.True(t, h1.offchain == h2.offchain, "handlers created from the same options share the dispatcher")
+}
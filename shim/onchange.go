@@ -0,0 +1,63 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+	"strings"
+)
+
+// StateChange describes a write made by the chaincode.
+type StateChange struct {
+	// Collection is the private data collection written to, or "" for the
+	// public state.
+	Collection string
+	Key        string
+	// Value is the value written, or nil if IsDelete is set.
+	Value    []byte
+	IsDelete bool
+}
+
+// ChangeHook is called by the stub after a matching key has been written.
+// An error is returned to the chaincode by the call that made the write.
+type ChangeHook func(stub ChaincodeStubInterface, change StateChange) error
+
+type changeHook struct {
+	prefix string
+	hook   ChangeHook
+}
+
+// ChangeObservers holds the hooks registered with ChaincodeStub.OnChange and
+// calls them on writes. The zero value has no hooks. It is used by the stub
+// implementations and is not needed by chaincode.
+type ChangeObservers struct {
+	hooks  []changeHook
+	firing bool
+}
+
+// Register adds hook to be called for writes to keys starting with prefix.
+func (o *ChangeObservers) Register(prefix string, hook ChangeHook) {
+	o.hooks = append(o.hooks, changeHook{prefix: prefix, hook: hook})
+}
+
+// Notify calls the hooks matching the key of change in the order they were
+// registered, stopping at the first error. Writes made by hooks do not
+// trigger hooks, which prevents a hook from triggering itself.
+func (o *ChangeObservers) Notify(stub ChaincodeStubInterface, change StateChange) error {
+	if o.firing {
+		return nil
+	}
+	o.firing = true
+	defer func() { o.firing = false }()
+
+	for _, h := range o.hooks {
+		if !strings.HasPrefix(change.Key, h.prefix) {
+			continue
+		}
+		if err := h.hook(stub, change); err != nil {
+			return fmt.Errorf("change hook for key %s failed: %s", change.Key, err)
+		}
+	}
+	return nil
+}
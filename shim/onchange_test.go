@@ -0,0 +1,51 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChangeObservers(t *testing.T) {
+	var calls []string
+	record := func(name string, err error) ChangeHook {
+		return func(stub ChaincodeStubInterface, change StateChange) error {
+			calls = append(calls, name+":"+change.Key)
+			return err
+		}
+	}
+
+	o := &ChangeObservers{}
+	assert.NoError(t, o.Notify(nil, StateChange{Key: "asset1"}), "expected no error without hooks")
+
+	o.Register("asset", record("first", nil))
+	o.Register("", record("all", nil))
+	o.Register("order", record("order", errors.New("boom")))
+
+	assert.NoError(t, o.Notify(nil, StateChange{Key: "asset1"}))
+	assert.Equal(t, []string{"first:asset1", "all:asset1"}, calls)
+
+	calls = nil
+	err := o.Notify(nil, StateChange{Key: "order1"})
+	assert.EqualError(t, err, "change hook for key order1 failed: boom")
+	assert.Equal(t, []string{"all:order1", "order:order1"}, calls)
+}
+
+func TestChangeObserversNoReentry(t *testing.T) {
+	o := &ChangeObservers{}
+	calls := 0
+	o.Register("", func(stub ChaincodeStubInterface, change StateChange) error {
+		calls++
+		// a write made by a hook notifies the observers again
+		return o.Notify(stub, StateChange{Key: "index~" + change.Key})
+	})
+
+	assert.NoError(t, o.Notify(nil, StateChange{Key: "asset1"}))
+	assert.Equal(t, 1, calls)
+	assert.NoError(t, o.Notify(nil, StateChange{Key: "asset2"}))
+	assert.Equal(t, 2, calls, "expected hooks to fire again after the previous notification")
+}
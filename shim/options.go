@@ -0,0 +1,47 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+)
+
+// DefaultMaxPayloadSize is the default maximum size in bytes of a message
+// payload accepted from the peer. It matches the maximum message size of the
+// gRPC connection to the peer.
+const DefaultMaxPayloadSize = 100 * 1024 * 1024 // 100 MiB
+
+// Option configures optional behavior of the shim handler created by Start
+// or StartInProc.
+type Option func(*Handler) error
+
+// WithMaxPayloadSize sets the maximum size in bytes of a message payload
+// accepted from the peer. Transactions whose payload exceeds the limit are
+// rejected with an error instead of being passed to the chaincode.
+func WithMaxPayloadSize(size int) Option {
+	return func(h *Handler) error {
+		if size <= 0 {
+			return fmt.Errorf("max payload size must be positive, got %d", size)
+		}
+		h.maxPayloadSize = size
+		return nil
+	}
+}
+
+// WithStreams sets the number of streams Start opens to the peer. Each
+// stream registers the chaincode and is served by its own handler, so the
+// responses of a transaction are sent on the stream the transaction arrived
+// on. Additional streams are opened once the first one is registered; if the
+// peer rejects the registration of an additional stream, the chaincode
+// continues with the streams already established. WithStreams has no effect
+// on StartInProc.
+func WithStreams(count int) Option {
+	return func(h *Handler) error {
+		if count <= 0 {
+			return fmt.Errorf("stream count must be positive, got %d", count)
+		}
+		h.streams = count
+		return nil
+	}
+}
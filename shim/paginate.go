@@ -0,0 +1,90 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ErrMaxResultsExceeded is returned by PaginateAll and its wrappers, along
+// with the first maxResults results, when a query has more results.
+var ErrMaxResultsExceeded = errors.New("query has more results than the maximum allowed")
+
+// PageQuery returns the page of results starting at bookmark and the
+// bookmark of the next page, or "" if there are no more pages.
+type PageQuery func(bookmark string) (results []*queryresult.KV, nextBookmark string, err error)
+
+// PaginateAll calls query with the bookmark of each page, starting with "",
+// until no results or no bookmark are returned, and returns the results of
+// all pages. If maxResults is positive and the query has more results, the
+// first maxResults results are returned with ErrMaxResultsExceeded.
+func PaginateAll(query PageQuery, maxResults int) ([]*queryresult.KV, error) {
+	var all []*queryresult.KV
+	bookmark := ""
+	for {
+		results, next, err := query(bookmark)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, results...)
+		if maxResults > 0 && len(all) > maxResults {
+			return all[:maxResults], ErrMaxResultsExceeded
+		}
+		if len(results) == 0 || next == "" {
+			return all, nil
+		}
+		if next == bookmark {
+			return nil, fmt.Errorf("query returned bookmark %s for the page it started", bookmark)
+		}
+		bookmark = next
+	}
+}
+
+// GetAllStateByRange returns all the keys in the range [startKey, endKey)
+// by fetching pages of pageSize results. See PaginateAll.
+func GetAllStateByRange(stub ChaincodeStubInterface, startKey, endKey string, pageSize int32, maxResults int) ([]*queryresult.KV, error) {
+	return PaginateAll(func(bookmark string) ([]*queryresult.KV, string, error) {
+		return collectPage(stub.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark))
+	}, maxResults)
+}
+
+// GetAllStateByPartialCompositeKey returns all the keys matching the given
+// partial composite key by fetching pages of pageSize results. See
+// PaginateAll.
+func GetAllStateByPartialCompositeKey(stub ChaincodeStubInterface, objectType string, keys []string, pageSize int32, maxResults int) ([]*queryresult.KV, error) {
+	return PaginateAll(func(bookmark string) ([]*queryresult.KV, string, error) {
+		return collectPage(stub.GetStateByPartialCompositeKeyWithPagination(objectType, keys, pageSize, bookmark))
+	}, maxResults)
+}
+
+// GetAllQueryResult returns all the results of a rich query by fetching pages
+// of pageSize results. See PaginateAll.
+func GetAllQueryResult(stub ChaincodeStubInterface, query string, pageSize int32, maxResults int) ([]*queryresult.KV, error) {
+	return PaginateAll(func(bookmark string) ([]*queryresult.KV, string, error) {
+		return collectPage(stub.GetQueryResultWithPagination(query, pageSize, bookmark))
+	}, maxResults)
+}
+
+// collectPage reads and closes the iterator of a page and returns its
+// results and the bookmark of the next page.
+func collectPage(iter StateQueryIteratorInterface, metadata *pb.QueryResponseMetadata, err error) ([]*queryresult.KV, string, error) {
+	if err != nil {
+		return nil, "", err
+	}
+	defer iter.Close()
+
+	var results []*queryresult.KV
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, "", err
+		}
+		results = append(results, kv)
+	}
+	return results, metadata.GetBookmark(), nil
+}
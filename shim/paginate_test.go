@@ -0,0 +1,168 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+type sliceStateIterator struct {
+	kvs    []*queryresult.KV
+	closed bool
+}
+
+func (i *sliceStateIterator) HasNext() bool { return len(i.kvs) > 0 }
+func (i *sliceStateIterator) Close() error  { i.closed = true; return nil }
+func (i *sliceStateIterator) Next() (*queryresult.KV, error) {
+	kv := i.kvs[0]
+	i.kvs = i.kvs[1:]
+	return kv, nil
+}
+
+// pagingStub serves the paginated queries from keys, using the index of the
+// next result as bookmark.
+type pagingStub struct {
+	ChaincodeStubInterface
+	keys      []string
+	calls     []string
+	iterators []*sliceStateIterator
+}
+
+func (s *pagingStub) page(call string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	s.calls = append(s.calls, call)
+	start := 0
+	if bookmark != "" {
+		start, _ = strconv.Atoi(bookmark)
+	}
+	iter := &sliceStateIterator{}
+	end := start + int(pageSize)
+	if end > len(s.keys) {
+		end = len(s.keys)
+	}
+	for _, k := range s.keys[start:end] {
+		iter.kvs = append(iter.kvs, &queryresult.KV{Key: k})
+	}
+	s.iterators = append(s.iterators, iter)
+	next := ""
+	if end < len(s.keys) {
+		next = strconv.Itoa(end)
+	}
+	return iter, &peerpb.QueryResponseMetadata{FetchedRecordsCount: int32(end - start), Bookmark: next}, nil
+}
+
+func (s *pagingStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	return s.page(fmt.Sprintf("range %s %s %s", startKey, endKey, bookmark), pageSize, bookmark)
+}
+
+func (s *pagingStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	return s.page(fmt.Sprintf("composite %s %v %s", objectType, keys, bookmark), pageSize, bookmark)
+}
+
+func (s *pagingStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	return s.page(fmt.Sprintf("query %s %s", query, bookmark), pageSize, bookmark)
+}
+
+func keysOf(kvs []*queryresult.KV) []string {
+	var keys []string
+	for _, kv := range kvs {
+		keys = append(keys, kv.Key)
+	}
+	return keys
+}
+
+func TestPaginateAll(t *testing.T) {
+	t.Run("AllPages", func(t *testing.T) {
+		var bookmarks []string
+		results, err := PaginateAll(func(bookmark string) ([]*queryresult.KV, string, error) {
+			bookmarks = append(bookmarks, bookmark)
+			switch bookmark {
+			case "":
+				return []*queryresult.KV{{Key: "a"}, {Key: "b"}}, "c", nil
+			case "c":
+				return []*queryresult.KV{{Key: "c"}}, "d", nil
+			default:
+				// CouchDB returns a bookmark with an empty last page
+				return nil, "e", nil
+			}
+		}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b", "c"}, keysOf(results))
+		assert.Equal(t, []string{"", "c", "d"}, bookmarks)
+	})
+
+	t.Run("MaxResults", func(t *testing.T) {
+		results, err := PaginateAll(func(bookmark string) ([]*queryresult.KV, string, error) {
+			return []*queryresult.KV{{Key: "a" + bookmark}, {Key: "b" + bookmark}}, bookmark + "x", nil
+		}, 3)
+		assert.Equal(t, ErrMaxResultsExceeded, err)
+		assert.Equal(t, []string{"a", "b", "ax"}, keysOf(results))
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		_, err := PaginateAll(func(bookmark string) ([]*queryresult.KV, string, error) {
+			if bookmark == "" {
+				return []*queryresult.KV{{Key: "a"}}, "b", nil
+			}
+			return nil, "", errors.New("boom")
+		}, 0)
+		assert.EqualError(t, err, "boom")
+	})
+
+	t.Run("StuckBookmark", func(t *testing.T) {
+		_, err := PaginateAll(func(bookmark string) ([]*queryresult.KV, string, error) {
+			return []*queryresult.KV{{Key: "a"}}, "b", nil
+		}, 0)
+		assert.EqualError(t, err, "query returned bookmark b for the page it started")
+	})
+}
+
+func TestGetAllWrappers(t *testing.T) {
+	keys := []string{"k0", "k1", "k2", "k3", "k4"}
+	tests := []struct {
+		name  string
+		query func(stub ChaincodeStubInterface) ([]*queryresult.KV, error)
+		calls []string
+	}{
+		{
+			name: "Range",
+			query: func(stub ChaincodeStubInterface) ([]*queryresult.KV, error) {
+				return GetAllStateByRange(stub, "k0", "k9", 2, 0)
+			},
+			calls: []string{"range k0 k9 ", "range k0 k9 2", "range k0 k9 4"},
+		},
+		{
+			name: "PartialCompositeKey",
+			query: func(stub ChaincodeStubInterface) ([]*queryresult.KV, error) {
+				return GetAllStateByPartialCompositeKey(stub, "type", []string{"a"}, 2, 0)
+			},
+			calls: []string{"composite type [a] ", "composite type [a] 2", "composite type [a] 4"},
+		},
+		{
+			name: "QueryResult",
+			query: func(stub ChaincodeStubInterface) ([]*queryresult.KV, error) {
+				return GetAllQueryResult(stub, "{}", 2, 0)
+			},
+			calls: []string{"query {} ", "query {} 2", "query {} 4"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &pagingStub{keys: keys}
+			results, err := tt.query(stub)
+			assert.NoError(t, err)
+			assert.Equal(t, keys, keysOf(results))
+			assert.Equal(t, tt.calls, stub.calls)
+			for _, iter := range stub.iterators {
+				assert.True(t, iter.closed, "expected every page iterator to be closed")
+			}
+		})
+	}
+}
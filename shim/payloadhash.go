@@ -0,0 +1,70 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"crypto/sha256"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Direction indicates whether a message was received from or sent to the
+// peer.
+type Direction string
+
+const (
+	// Received identifies a message received from the peer.
+	Received = Direction("received")
+	// Sent identifies a message sent to the peer.
+	Sent = Direction("sent")
+)
+
+// PayloadHash is the SHA-256 hash of the payload of a message exchanged with
+// the peer.
+type PayloadHash struct {
+	ChannelID string
+	TxID      string
+	Type      pb.ChaincodeMessage_Type
+	Direction Direction
+	Hash      []byte
+}
+
+// PayloadHashRecorder receives the payload hashes computed when payload
+// hashing is enabled. Implementations must be safe for concurrent use.
+type PayloadHashRecorder interface {
+	RecordPayloadHash(PayloadHash)
+}
+
+// WithPayloadHashing enables hashing of the payload of every message
+// exchanged with the peer, except keepalives. Each hash is logged together
+// with the txid of the message so that the behavior of endorsers can be
+// compared when investigating non-determinism. The hashes are additionally
+// passed to the given recorders.
+func WithPayloadHashing(recorders ...PayloadHashRecorder) Option {
+	return func(h *Handler) error {
+		h.hashPayloads = true
+		h.hashRecorders = append(h.hashRecorders, recorders...)
+		return nil
+	}
+}
+
+// recordPayloadHash hashes the payload of msg if payload hashing is enabled.
+func (h *Handler) recordPayloadHash(direction Direction, msg *pb.ChaincodeMessage) {
+	if !h.hashPayloads || msg.Type == pb.ChaincodeMessage_KEEPALIVE {
+		return
+	}
+
+	sum := sha256.Sum256(msg.Payload)
+	ph := PayloadHash{
+		ChannelID: msg.ChannelId,
+		TxID:      msg.Txid,
+		Type:      msg.Type,
+		Direction: direction,
+		Hash:      sum[:],
+	}
+	h.logf("[%s] %s %s payload on channel '%s' sha256=%x", shorttxid(ph.TxID), direction, ph.Type, ph.ChannelID, ph.Hash)
+	for _, r := range h.hashRecorders {
+		r.RecordPayloadHash(ph)
+	}
+}
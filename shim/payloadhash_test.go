@@ -0,0 +1,82 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type hashRecorder struct {
+	mutex  sync.Mutex
+	hashes []PayloadHash
+}
+
+func (r *hashRecorder) RecordPayloadHash(ph PayloadHash) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.hashes = append(r.hashes, ph)
+}
+
+type recordingLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestPayloadHashing(t *testing.T) {
+	chatStream := &mock.PeerChaincodeStream{}
+	msgChan := make(chan *peerpb.ChaincodeMessage, 1)
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		msgChan <- msg
+		return nil
+	}
+
+	recorder := &hashRecorder{}
+	logger := &recordingLogger{}
+	handler, err := newChaincodeHandler(chatStream, &mockChaincode{}, WithLogger(logger), WithPayloadHashing(recorder))
+	assert.NoError(t, err)
+	handler.state = ready
+
+	errc := make(chan error, 1)
+	msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, ChannelId: "channel", Txid: "txid"}
+	err = handler.handleMessage(msg, errc)
+	assert.NoError(t, err)
+	resp := <-msgChan
+	assert.NoError(t, <-errc)
+
+	// keepalives are not hashed
+	err = handler.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_KEEPALIVE}, errc)
+	assert.NoError(t, err)
+	<-msgChan
+	assert.NoError(t, <-errc)
+
+	emptyHash := sha256.Sum256(nil)
+	respHash := sha256.Sum256(resp.Payload)
+	assert.Equal(t, []PayloadHash{
+		{ChannelID: "channel", TxID: "txid", Type: peerpb.ChaincodeMessage_TRANSACTION, Direction: Received, Hash: emptyHash[:]},
+		{ChannelID: "channel", TxID: "txid", Type: peerpb.ChaincodeMessage_COMPLETED, Direction: Sent, Hash: respHash[:]},
+	}, recorder.hashes)
+	assert.Equal(t, []string{
+		fmt.Sprintf("[txid] received TRANSACTION payload on channel 'channel' sha256=%x", emptyHash),
+		fmt.Sprintf("[txid] sent COMPLETED payload on channel 'channel' sha256=%x", respHash),
+	}, logger.lines)
+}
+
+func TestWithLoggerNil(t *testing.T) {
+	_, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithLogger(nil))
+	assert.EqualError(t, err, "logger must not be nil")
+}
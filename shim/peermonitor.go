@@ -0,0 +1,80 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// PeerMonitor tracks when messages were last received from the peer so that
+// the liveness of the peer connection can be surfaced in health checks.
+// Register it with a handler using WithPeerMonitor.
+type PeerMonitor struct {
+	mutex         sync.Mutex
+	lastContact   time.Time
+	lastKeepalive time.Time
+	keepalives    chan time.Time
+}
+
+// NewPeerMonitor returns a new PeerMonitor.
+func NewPeerMonitor() *PeerMonitor {
+	return &PeerMonitor{
+		keepalives: make(chan time.Time, 1),
+	}
+}
+
+// LastPeerContact returns the time a message of any type was last received
+// from the peer. The zero time is returned if no message has been received.
+func (m *PeerMonitor) LastPeerContact() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lastContact
+}
+
+// LastKeepalive returns the time a KEEPALIVE message was last received from
+// the peer. The zero time is returned if no keepalive has been received.
+func (m *PeerMonitor) LastKeepalive() time.Time {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lastKeepalive
+}
+
+// Keepalives returns a channel on which the receipt time of KEEPALIVE
+// messages is delivered. Receipt times are dropped while the channel holds
+// a value that has not been consumed.
+func (m *PeerMonitor) Keepalives() <-chan time.Time {
+	return m.keepalives
+}
+
+func (m *PeerMonitor) received(msg *pb.ChaincodeMessage, now time.Time) {
+	m.mutex.Lock()
+	m.lastContact = now
+	if msg.Type == pb.ChaincodeMessage_KEEPALIVE {
+		m.lastKeepalive = now
+	}
+	m.mutex.Unlock()
+
+	if msg.Type == pb.ChaincodeMessage_KEEPALIVE {
+		select {
+		case m.keepalives <- now:
+		default:
+		}
+	}
+}
+
+// WithPeerMonitor registers a PeerMonitor which is updated whenever a
+// message is received from the peer.
+func WithPeerMonitor(m *PeerMonitor) Option {
+	return func(h *Handler) error {
+		if m == nil {
+			return errors.New("peer monitor must not be nil")
+		}
+		h.peerMonitor = m
+		return nil
+	}
+}
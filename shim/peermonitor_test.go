@@ -0,0 +1,54 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPeerMonitor(t *testing.T) {
+	monitor := NewPeerMonitor()
+	assert.True(t, monitor.LastPeerContact().IsZero())
+	assert.True(t, monitor.LastKeepalive().IsZero())
+
+	handler, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithPeerMonitor(monitor))
+	assert.NoError(t, err)
+
+	before := time.Now()
+	err = handler.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}, nil)
+	assert.NoError(t, err)
+	contact := monitor.LastPeerContact()
+	assert.False(t, contact.Before(before))
+	assert.True(t, monitor.LastKeepalive().IsZero())
+
+	errc := make(chan error, 2)
+	handler.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_KEEPALIVE}, errc)
+	handler.handleMessage(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_KEEPALIVE}, errc)
+	assert.False(t, monitor.LastPeerContact().Before(contact))
+	assert.Equal(t, monitor.LastPeerContact(), monitor.LastKeepalive())
+
+	// only one receipt time is buffered
+	select {
+	case received := <-monitor.Keepalives():
+		assert.False(t, received.Before(contact))
+	default:
+		t.Fatal("expected keepalive receipt time")
+	}
+	select {
+	case <-monitor.Keepalives():
+		t.Fatal("unexpected keepalive receipt time")
+	default:
+	}
+}
+
+func TestWithPeerMonitorNil(t *testing.T) {
+	_, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithPeerMonitor(nil))
+	assert.EqualError(t, err, "peer monitor must not be nil")
+}
@@ -0,0 +1,139 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package query
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// ChaincodeStubInterface is the subset of the chaincode stub used by
+// GetQueryResultParameterized.
+type ChaincodeStubInterface interface {
+	// GetQueryResult performs a rich query against the state database.
+	GetQueryResult(query string) (shim.StateQueryIteratorInterface, error)
+}
+
+// Parameterized returns template, a JSON query, with its placeholders
+// replaced by the JSON encoding of the corresponding params. A placeholder
+// is a JSON string consisting only of a parameter name in double braces,
+// such as "{{owner}}" in
+//
+//	{"selector":{"owner":"{{owner}}","price":{"$gt":"{{minPrice}}"}}}
+//
+// Parameters replace whole JSON values, so strings are escaped and numbers
+// stay numbers. Parameters must be strings, numbers, booleans or nil, so
+// that a parameter cannot change the structure of the query, for example
+// by passing the selector {"$gt":""} decoded from a client request as the
+// value of a field. Every placeholder must have a parameter and every
+// parameter must be used.
+func Parameterized(template string, params map[string]interface{}) (string, error) {
+	var tree interface{}
+	if err := unmarshalUseNumber([]byte(template), &tree); err != nil {
+		return "", fmt.Errorf("invalid query template: %s", err)
+	}
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !isScalar(params[name]) {
+			return "", fmt.Errorf("query parameter %s must be a string, number, boolean or nil, got %T", name, params[name])
+		}
+	}
+
+	used := map[string]bool{}
+	tree, err := substitute(tree, params, used)
+	if err != nil {
+		return "", err
+	}
+
+	var unused []string
+	for name := range params {
+		if !used[name] {
+			unused = append(unused, name)
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		return "", fmt.Errorf("unused query parameters: %s", strings.Join(unused, ", "))
+	}
+
+	b, err := canonicalJSON(tree)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal query: %s", err)
+	}
+	return string(b), nil
+}
+
+// GetQueryResultParameterized performs the rich query returned by
+// Parameterized.
+func GetQueryResultParameterized(stub ChaincodeStubInterface, template string, params map[string]interface{}) (shim.StateQueryIteratorInterface, error) {
+	q, err := Parameterized(template, params)
+	if err != nil {
+		return nil, err
+	}
+	return stub.GetQueryResult(q)
+}
+
+func substitute(v interface{}, params map[string]interface{}, used map[string]bool) (interface{}, error) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if strings.Contains(key, "{{") {
+				return nil, fmt.Errorf("placeholders are not allowed in field names: %s", key)
+			}
+			replaced, err := substitute(value, params, used)
+			if err != nil {
+				return nil, err
+			}
+			v[key] = replaced
+		}
+		return v, nil
+	case []interface{}:
+		for i, value := range v {
+			replaced, err := substitute(value, params, used)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = replaced
+		}
+		return v, nil
+	case string:
+		if !strings.Contains(v, "{{") {
+			return v, nil
+		}
+		if !strings.HasPrefix(v, "{{") || !strings.HasSuffix(v, "}}") || strings.Count(v, "{{") != 1 {
+			return nil, fmt.Errorf("placeholder must be a whole JSON string: %s", v)
+		}
+		name := strings.TrimSpace(v[2 : len(v)-2])
+		value, ok := params[name]
+		if !ok {
+			return nil, fmt.Errorf("missing query parameter %s", name)
+		}
+		used[name] = true
+		return value, nil
+	default:
+		return v, nil
+	}
+}
+
+// isScalar reports whether v is encoded as a JSON string, number, boolean
+// or null.
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case nil, string, bool, json.Number,
+		int, int8, int16, int32, int64,
+		uint, uint8, uint16, uint32, uint64,
+		float32, float64:
+		return true
+	default:
+		return false
+	}
+}
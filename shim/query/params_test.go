@@ -0,0 +1,94 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package query_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParameterized(t *testing.T) {
+	template := `{"selector": {"owner": "{{owner}}", "price": {"$gt": "{{ minPrice }}"}}, "sort": [{"price": "asc"}]}`
+	tests := []struct {
+		name     string
+		template string
+		params   map[string]interface{}
+		expected string
+		err      string
+	}{
+		{
+			name:     "Substitution",
+			template: template,
+			params:   map[string]interface{}{"owner": "alice", "minPrice": 100},
+			expected: `{"selector":{"owner":"alice","price":{"$gt":100}},"sort":[{"price":"asc"}]}`,
+		},
+		{
+			name:     "Injection",
+			template: template,
+			params:   map[string]interface{}{"owner": `alice"}, "$or": [{"_id": {"$gt": null}}], "x": {"y": "`, "minPrice": 0},
+			expected: `{"selector":{"owner":"alice\"}, \"$or\": [{\"_id\": {\"$gt\": null}}], \"x\": {\"y\": \"","price":{"$gt":0}},"sort":[{"price":"asc"}]}`,
+		},
+		{
+			name:     "ScalarValues",
+			template: `{"selector":{"active":"{{active}}","deleted":"{{deleted}}","price":"{{price}}"}}`,
+			params:   map[string]interface{}{"active": true, "deleted": nil, "price": json.Number("1.5")},
+			expected: `{"selector":{"active":true,"deleted":null,"price":1.5}}`,
+		},
+		{
+			name:     "OperatorInjection",
+			template: `{"selector":{"owner":"{{owner}}"}}`,
+			params:   map[string]interface{}{"owner": map[string]interface{}{"$gt": ""}},
+			err:      "query parameter owner must be a string, number, boolean or nil, got map[string]interface {}",
+		},
+		{
+			name:     "ArrayValue",
+			template: `{"selector":{"color":{"$in":"{{colors}}"}}}`,
+			params:   map[string]interface{}{"colors": []string{"red", "blue"}},
+			err:      "query parameter colors must be a string, number, boolean or nil, got []string",
+		},
+		{name: "InvalidTemplate", template: `{"selector":`, err: "invalid query template: unexpected EOF"},
+		{name: "Missing", template: template, params: map[string]interface{}{"owner": "alice"}, err: "missing query parameter minPrice"},
+		{name: "Unused", template: template, params: map[string]interface{}{"owner": "alice", "minPrice": 1, "b": 1, "a": 1}, err: "unused query parameters: a, b"},
+		{name: "Partial", template: `{"selector":{"owner":{"$regex":"^{{prefix}}"}}}`, params: map[string]interface{}{"prefix": "a"}, err: "placeholder must be a whole JSON string: ^{{prefix}}"},
+		{name: "FieldName", template: `{"selector":{"{{field}}":"x"}}`, params: map[string]interface{}{"field": "a"}, err: "placeholders are not allowed in field names: {{field}}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := query.Parameterized(tt.template, tt.params)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, actual)
+			assert.NoError(t, shim.CheckCanonicalQuery(actual))
+		})
+	}
+}
+
+type queryStub struct {
+	query string
+}
+
+func (s *queryStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	s.query = query
+	return nil, errors.New("not connected")
+}
+
+func TestGetQueryResultParameterized(t *testing.T) {
+	stub := &queryStub{}
+	_, err := query.GetQueryResultParameterized(stub, `{"selector":{"owner":"{{owner}}"}}`, map[string]interface{}{"owner": "alice"})
+	assert.EqualError(t, err, "not connected")
+	assert.Equal(t, `{"selector":{"owner":"alice"}}`, stub.query)
+
+	stub.query = ""
+	_, err = query.GetQueryResultParameterized(stub, `{"selector":{"owner":"{{owner}}"}}`, nil)
+	assert.EqualError(t, err, "missing query parameter owner")
+	assert.Empty(t, stub.query, "expected the query not to be sent")
+}
@@ -0,0 +1,295 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package query builds CouchDB Mango queries for GetQueryResult and
+// GetQueryResultWithPagination. Values are encoded as JSON, so they cannot
+// alter the structure of the query as string concatenation can.
+//
+//	q, err := query.Eq("type", "car").And(query.Gt("price", 100)).
+//		Sort("price").
+//		UseIndex("_design/priceDoc", "priceIdx").
+//		JSON()
+//	...
+//	iter, err := stub.GetQueryResult(q)
+//
+// Queries written as JSON templates can be filled in safely with
+// Parameterized. Both produce canonical JSON and are accepted when the shim
+// is started with shim.WithStrictQueries.
+package query
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Selector is a Mango selector, a condition on the fields of documents.
+type Selector struct {
+	expr map[string]interface{}
+	err  error
+}
+
+func condition(op, field string, value interface{}) *Selector {
+	if field == "" {
+		return &Selector{err: fmt.Errorf("%s: field name must not be an empty string", op)}
+	}
+	return &Selector{expr: map[string]interface{}{field: map[string]interface{}{op: value}}}
+}
+
+// Eq matches documents where field equals value.
+func Eq(field string, value interface{}) *Selector { return condition("$eq", field, value) }
+
+// Ne matches documents where field is not equal to value.
+func Ne(field string, value interface{}) *Selector { return condition("$ne", field, value) }
+
+// Gt matches documents where field is greater than value.
+func Gt(field string, value interface{}) *Selector { return condition("$gt", field, value) }
+
+// Gte matches documents where field is greater than or equal to value.
+func Gte(field string, value interface{}) *Selector { return condition("$gte", field, value) }
+
+// Lt matches documents where field is less than value.
+func Lt(field string, value interface{}) *Selector { return condition("$lt", field, value) }
+
+// Lte matches documents where field is less than or equal to value.
+func Lte(field string, value interface{}) *Selector { return condition("$lte", field, value) }
+
+// Exists matches documents where field exists, or does not exist if exists
+// is false.
+func Exists(field string, exists bool) *Selector { return condition("$exists", field, exists) }
+
+// Regex matches documents where field is a string matching pattern.
+func Regex(field, pattern string) *Selector { return condition("$regex", field, pattern) }
+
+// In matches documents where field equals one of values, which must be a
+// slice or an array.
+func In(field string, values interface{}) *Selector { return list("$in", field, values) }
+
+// Nin matches documents where field equals none of values, which must be a
+// slice or an array.
+func Nin(field string, values interface{}) *Selector { return list("$nin", field, values) }
+
+func list(op, field string, values interface{}) *Selector {
+	kind := reflect.ValueOf(values).Kind()
+	if kind != reflect.Slice && kind != reflect.Array {
+		return &Selector{err: fmt.Errorf("%s: values of %s must be a slice, got %T", op, field, values)}
+	}
+	return condition(op, field, values)
+}
+
+func combine(op string, selectors []*Selector) *Selector {
+	if len(selectors) == 0 {
+		return &Selector{err: fmt.Errorf("%s: at least one selector is required", op)}
+	}
+	var exprs []interface{}
+	for _, s := range selectors {
+		if s == nil {
+			return &Selector{err: fmt.Errorf("%s: nil selector", op)}
+		}
+		if s.err != nil {
+			return &Selector{err: s.err}
+		}
+		// flatten nested combinations with the same operator
+		if nested, ok := s.expr[op]; ok && len(s.expr) == 1 {
+			exprs = append(exprs, nested.([]interface{})...)
+			continue
+		}
+		exprs = append(exprs, s.expr)
+	}
+	return &Selector{expr: map[string]interface{}{op: exprs}}
+}
+
+// And matches documents matching all of selectors.
+func And(selectors ...*Selector) *Selector { return combine("$and", selectors) }
+
+// Or matches documents matching any of selectors.
+func Or(selectors ...*Selector) *Selector { return combine("$or", selectors) }
+
+// Nor matches documents matching none of selectors.
+func Nor(selectors ...*Selector) *Selector { return combine("$nor", selectors) }
+
+// Not matches documents not matching selector.
+func Not(selector *Selector) *Selector {
+	if selector == nil {
+		return &Selector{err: errors.New("$not: nil selector")}
+	}
+	if selector.err != nil {
+		return selector
+	}
+	return &Selector{expr: map[string]interface{}{"$not": selector.expr}}
+}
+
+// And matches documents matching s and all of others.
+func (s *Selector) And(others ...*Selector) *Selector {
+	return And(append([]*Selector{s}, others...)...)
+}
+
+// Or matches documents matching s or any of others.
+func (s *Selector) Or(others ...*Selector) *Selector {
+	return Or(append([]*Selector{s}, others...)...)
+}
+
+// Query returns a query with selector s.
+func (s *Selector) Query() *Query {
+	return &Query{selector: s}
+}
+
+// Sort returns a query with selector s sorted by fields in ascending order.
+func (s *Selector) Sort(fields ...string) *Query {
+	return s.Query().Sort(fields...)
+}
+
+// UseIndex returns a query with selector s using the given index. See
+// Query.UseIndex.
+func (s *Selector) UseIndex(designDoc string, indexName ...string) *Query {
+	return s.Query().UseIndex(designDoc, indexName...)
+}
+
+// JSON returns the JSON encoding of the query with selector s.
+func (s *Selector) JSON() (string, error) {
+	return s.Query().JSON()
+}
+
+// Query is a Mango query.
+type Query struct {
+	selector *Selector
+	sort     []map[string]string
+	fields   []string
+	useIndex interface{}
+	limit    int
+	skip     int
+	err      error
+}
+
+// Sort appends fields, in ascending order, to the sort order of q.
+func (q *Query) Sort(fields ...string) *Query {
+	return q.sortBy("asc", fields)
+}
+
+// SortDesc appends fields, in descending order, to the sort order of q.
+func (q *Query) SortDesc(fields ...string) *Query {
+	return q.sortBy("desc", fields)
+}
+
+func (q *Query) sortBy(direction string, fields []string) *Query {
+	for _, f := range fields {
+		if f == "" {
+			q.setErr(errors.New("sort: field name must not be an empty string"))
+			continue
+		}
+		q.sort = append(q.sort, map[string]string{f: direction})
+	}
+	return q
+}
+
+// Fields restricts the fields of the returned documents. Note that the
+// peer needs the _id and _rev fields of documents to build the read set.
+func (q *Query) Fields(fields ...string) *Query {
+	q.fields = append(q.fields, fields...)
+	return q
+}
+
+// UseIndex instructs CouchDB to use the index in designDoc, which should
+// include the "_design/" prefix, optionally naming the index within it.
+func (q *Query) UseIndex(designDoc string, indexName ...string) *Query {
+	switch {
+	case designDoc == "":
+		q.setErr(errors.New("use_index: design document must not be an empty string"))
+	case len(indexName) == 0:
+		q.useIndex = designDoc
+	case len(indexName) == 1:
+		q.useIndex = []string{designDoc, indexName[0]}
+	default:
+		q.setErr(errors.New("use_index: at most one index name may be given"))
+	}
+	return q
+}
+
+// Limit sets the maximum number of documents returned. The peer ignores it
+// for paginated queries, which use the page size instead.
+func (q *Query) Limit(limit int) *Query {
+	if limit < 0 {
+		q.setErr(errors.New("limit must not be negative"))
+	}
+	q.limit = limit
+	return q
+}
+
+// Skip sets the number of documents to skip.
+func (q *Query) Skip(skip int) *Query {
+	if skip < 0 {
+		q.setErr(errors.New("skip must not be negative"))
+	}
+	q.skip = skip
+	return q
+}
+
+func (q *Query) setErr(err error) {
+	if q.err == nil {
+		q.err = err
+	}
+}
+
+// JSON returns the JSON encoding of q, or the first error made while
+// building it.
+func (q *Query) JSON() (string, error) {
+	if q.selector == nil {
+		return "", errors.New("query has no selector")
+	}
+	if q.selector.err != nil {
+		return "", q.selector.err
+	}
+	if q.err != nil {
+		return "", q.err
+	}
+	mango := map[string]interface{}{"selector": q.selector.expr}
+	if len(q.fields) > 0 {
+		mango["fields"] = q.fields
+	}
+	if len(q.sort) > 0 {
+		mango["sort"] = q.sort
+	}
+	if q.useIndex != nil {
+		mango["use_index"] = q.useIndex
+	}
+	if q.limit > 0 {
+		mango["limit"] = q.limit
+	}
+	if q.skip > 0 {
+		mango["skip"] = q.skip
+	}
+	b, err := canonicalJSON(mango)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal query: %s", err)
+	}
+	return string(b), nil
+}
+
+// canonicalJSON encodes v as required by shim.CheckCanonicalQuery. Values
+// are decoded and encoded again so that the fields of structs are sorted
+// like the keys of maps.
+func canonicalJSON(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var generic interface{}
+	if err := unmarshalUseNumber(b, &generic); err != nil {
+		return nil, err
+	}
+	return json.Marshal(generic)
+}
+
+func unmarshalUseNumber(b []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package query_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueryJSON(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    interface{ JSON() (string, error) }
+		expected string
+		err      string
+	}{
+		{
+			name:     "Eq",
+			query:    query.Eq("type", "car"),
+			expected: `{"selector":{"type":{"$eq":"car"}}}`,
+		},
+		{
+			name:     "Chained",
+			query:    query.Eq("type", "car").And(query.Gt("price", 100)).Sort("price").UseIndex("_design/priceDoc", "priceIdx"),
+			expected: `{"selector":{"$and":[{"type":{"$eq":"car"}},{"price":{"$gt":100}}]},"sort":[{"price":"asc"}],"use_index":["_design/priceDoc","priceIdx"]}`,
+		},
+		{
+			name:     "FlattenAnd",
+			query:    query.Eq("a", 1).And(query.Eq("b", 2)).And(query.Eq("c", 3)),
+			expected: `{"selector":{"$and":[{"a":{"$eq":1}},{"b":{"$eq":2}},{"c":{"$eq":3}}]}}`,
+		},
+		{
+			name:     "OrNot",
+			query:    query.Or(query.In("color", []string{"red", "blue"}), query.Not(query.Exists("owner", true))),
+			expected: `{"selector":{"$or":[{"color":{"$in":["red","blue"]}},{"$not":{"owner":{"$exists":true}}}]}}`,
+		},
+		{
+			name:     "Options",
+			query:    query.Lte("price", 5.5).Query().SortDesc("price").Fields("_id", "_rev", "price").UseIndex("_design/priceDoc").Limit(10).Skip(5),
+			expected: `{"selector":{"price":{"$lte":5.5}},"fields":["_id","_rev","price"],"sort":[{"price":"desc"}],"use_index":"_design/priceDoc","limit":10,"skip":5}`,
+		},
+		{
+			name:     "Escaping",
+			query:    query.Eq("owner", `alice"},"$or":[{"_id":{"$gt":null}}]}`),
+			expected: `{"selector":{"owner":{"$eq":"alice\"},\"$or\":[{\"_id\":{\"$gt\":null}}]}"}}}`,
+		},
+		{
+			name: "StructValue",
+			query: query.Eq("dims", struct {
+				Width  int `json:"width"`
+				Height int `json:"height"`
+			}{Width: 1, Height: 2}),
+			expected: `{"selector":{"dims":{"$eq":{"height":2,"width":1}}}}`,
+		},
+		{name: "EmptyField", query: query.Eq("", 1).And(query.Eq("a", 1)), err: "$eq: field name must not be an empty string"},
+		{name: "InNotSlice", query: query.In("color", "red"), err: "$in: values of color must be a slice, got string"},
+		{name: "EmptyAnd", query: query.And(), err: "$and: at least one selector is required"},
+		{name: "NilSelector", query: query.Or(nil), err: "$or: nil selector"},
+		{name: "EmptySort", query: query.Eq("a", 1).Sort(""), err: "sort: field name must not be an empty string"},
+		{name: "TooManyIndexNames", query: query.Eq("a", 1).UseIndex("_design/doc", "a", "b"), err: "use_index: at most one index name may be given"},
+		{name: "NegativeLimit", query: query.Eq("a", 1).Query().Limit(-1), err: "limit must not be negative"},
+		{name: "NoSelector", query: &query.Query{}, err: "query has no selector"},
+		{name: "Unmarshallable", query: query.Eq("a", make(chan int)), err: "failed to marshal query: json: unsupported type: chan int"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := tt.query.JSON()
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.JSONEq(t, tt.expected, actual)
+			assert.NoError(t, shim.CheckCanonicalQuery(actual))
+		})
+	}
+}
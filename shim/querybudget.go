@@ -0,0 +1,79 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ErrBudgetExceeded is returned by the queries of a transaction, and by
+// the Next method of their iterators, once the results fetched from the
+// peer by the transaction exceed the budget set with WithQueryBudget.
+var ErrBudgetExceeded = errors.New("query memory budget of the transaction exceeded")
+
+// WithQueryBudget sets the maximum number of bytes of query results each
+// transaction can fetch from the peer, counting the encoded results of
+// range, rich and history queries as they are fetched. A transaction
+// scanning more data gets ErrBudgetExceeded instead of growing the memory
+// of the chaincode process until it is killed. Results that were fetched
+// are counted even if the iterator is closed. With WithFunctionStats, the
+// bytes charged and the transactions exceeding the budget are counted in
+// the statistics of each function.
+func WithQueryBudget(bytes int) Option {
+	return func(h *Handler) error {
+		if bytes <= 0 {
+			return fmt.Errorf("query budget must be positive, got %d", bytes)
+		}
+		h.queryBudget = bytes
+		return nil
+	}
+}
+
+// queryBudget counts the query results fetched by a transaction.
+type queryBudget struct {
+	limit   int64
+	fetched int64
+	// exceeded is set to 1 once ErrBudgetExceeded was returned.
+	exceeded int32
+}
+
+// charge counts the results of response and returns ErrBudgetExceeded if
+// the budget is exceeded. A nil budget is unlimited.
+func (b *queryBudget) charge(response *pb.QueryResponse) error {
+	if b == nil {
+		return nil
+	}
+	size := 0
+	for _, result := range response.Results {
+		size += len(result.ResultBytes)
+	}
+	if atomic.AddInt64(&b.fetched, int64(size)) > b.limit {
+		atomic.StoreInt32(&b.exceeded, 1)
+		return ErrBudgetExceeded
+	}
+	return nil
+}
+
+// usage returns the bytes of query results charged to the budget and
+// whether it was exceeded. A nil budget has no usage.
+func (b *queryBudget) usage() (int64, bool) {
+	if b == nil {
+		return 0, false
+	}
+	return atomic.LoadInt64(&b.fetched), atomic.LoadInt32(&b.exceeded) == 1
+}
+
+// chargeQuery charges the first response of a query to the budget of the
+// transaction, closing the query on the peer if the budget is exceeded.
+func (s *ChaincodeStub) chargeQuery(response *pb.QueryResponse) error {
+	if err := s.queryBudget.charge(response); err != nil {
+		s.handler.handleQueryStateClose(response.Id, s.ChannelID, s.TxID)
+		return err
+	}
+	return nil
+}
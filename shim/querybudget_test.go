@@ -0,0 +1,79 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithQueryBudget(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithQueryBudget(0))
+	assert.EqualError(t, err, "query budget must be positive, got 0")
+
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithQueryBudget(1024))
+	require.NoError(t, err)
+	stub, err := newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, &queryBudget{limit: 1024}, stub.queryBudget)
+}
+
+func TestQueryBudget(t *testing.T) {
+	result := &peerpb.QueryResultBytes{ResultBytes: marshalOrPanic(&queryresult.KV{Key: "key", Value: make([]byte, 100)})}
+	size := len(result.ResultBytes)
+	first := &peerpb.QueryResponse{Results: []*peerpb.QueryResultBytes{result, result}, HasMore: true, Id: "queryid"}
+	second := &peerpb.QueryResponse{Results: []*peerpb.QueryResultBytes{result}, Id: "queryid"}
+
+	t.Run("Within Budget", func(t *testing.T) {
+		stub, _ := newBatchingStub(first, second)
+		stub.queryBudget = &queryBudget{limit: int64(3 * size)}
+
+		iter, err := stub.GetStateByRange("a", "z")
+		require.NoError(t, err)
+		for i := 0; i < 3; i++ {
+			_, err := iter.Next()
+			require.NoError(t, err)
+		}
+		assert.False(t, iter.HasNext())
+	})
+
+	t.Run("Exceeded By Next", func(t *testing.T) {
+		stub, _ := newBatchingStub(first, second)
+		stub.queryBudget = &queryBudget{limit: int64(3*size - 1)}
+
+		iter, err := stub.GetStateByRange("a", "z")
+		require.NoError(t, err)
+		_, err = iter.Next()
+		require.NoError(t, err)
+		_, err = iter.Next()
+		assert.Equal(t, ErrBudgetExceeded, err)
+	})
+
+	t.Run("Exceeded By Query", func(t *testing.T) {
+		stub, _ := newBatchingStub(first, second)
+		stub.queryBudget = &queryBudget{limit: int64(size)}
+
+		_, err := stub.GetStateByRange("a", "z")
+		assert.Equal(t, ErrBudgetExceeded, err)
+		_, err = stub.GetQueryResult(`{"selector":{}}`)
+		assert.Equal(t, ErrBudgetExceeded, err)
+		_, err = stub.GetHistoryForKey("key")
+		assert.Equal(t, ErrBudgetExceeded, err)
+	})
+
+	t.Run("No Budget", func(t *testing.T) {
+		stub, _ := newBatchingStub(first, second)
+
+		iter, err := stub.GetStateByRange("a", "z")
+		require.NoError(t, err)
+		for iter.HasNext() {
+			_, err := iter.Next()
+			require.NoError(t, err)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// QueryDirection is the order in which a query returns keys.
+type QueryDirection int
+
+const (
+	// Ascending returns keys in lexical order.
+	Ascending QueryDirection = iota
+	// Descending returns keys in reverse lexical order.
+	Descending
+)
+
+// QueryOpts configures the queries made by GetStateByRangeWithOpts,
+// GetStateByPartialCompositeKeyWithOpts and GetQueryResultWithOpts.
+type QueryOpts struct {
+	// Limit is the maximum number of results returned; zero means no
+	// limit. An ascending query with a limit is paginated: it returns a
+	// page of Limit results and the metadata of the page.
+	Limit int32
+	// Bookmark is the bookmark of the page returned by a paginated query.
+	// It requires a Limit and is not supported by descending queries.
+	Bookmark string
+	// Direction is the order of the results. Descending queries read all
+	// the matching keys from the peer; see ReverseStateIterator. Rich
+	// queries do not support the Descending direction, their order is set
+	// by the sort of the query.
+	Direction QueryDirection
+}
+
+// queryOpts returns the single QueryOpts of opts, or the zero value.
+func queryOpts(opts []QueryOpts, rich bool) (QueryOpts, error) {
+	var o QueryOpts
+	switch len(opts) {
+	case 0:
+		return o, nil
+	case 1:
+		o = opts[0]
+	default:
+		return o, errors.New("at most one QueryOpts may be passed")
+	}
+
+	switch {
+	case o.Limit < 0:
+		return o, errors.New("query limit must not be negative")
+	case o.Bookmark != "" && o.Limit == 0:
+		return o, errors.New("query bookmark requires a limit")
+	case o.Direction != Ascending && o.Direction != Descending:
+		return o, errors.New("unknown query direction")
+	case o.Direction == Descending && rich:
+		return o, errors.New("rich queries do not support the descending direction")
+	case o.Direction == Descending && o.Bookmark != "":
+		return o, errors.New("descending queries do not support bookmarks")
+	}
+	return o, nil
+}
+
+// GetStateByRangeWithOpts queries the keys in the range [startKey, endKey)
+// as configured by opts. It calls GetStateByRange, or
+// GetStateByRangeWithPagination for a paginated query, on stub. The
+// metadata is only returned for paginated queries.
+func GetStateByRangeWithOpts(stub ChaincodeStubInterface, startKey, endKey string, opts ...QueryOpts) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	o, err := queryOpts(opts, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.Limit > 0 && o.Direction == Ascending {
+		return stub.GetStateByRangeWithPagination(startKey, endKey, o.Limit, o.Bookmark)
+	}
+	iter, err := stub.GetStateByRange(startKey, endKey)
+	return applyDirection(iter, err, o)
+}
+
+// GetStateByPartialCompositeKeyWithOpts queries the keys matching the given
+// partial composite key as configured by opts. It calls
+// GetStateByPartialCompositeKey, or
+// GetStateByPartialCompositeKeyWithPagination for a paginated query, on
+// stub. The metadata is only returned for paginated queries.
+func GetStateByPartialCompositeKeyWithOpts(stub ChaincodeStubInterface, objectType string, keys []string, opts ...QueryOpts) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	o, err := queryOpts(opts, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.Limit > 0 && o.Direction == Ascending {
+		return stub.GetStateByPartialCompositeKeyWithPagination(objectType, keys, o.Limit, o.Bookmark)
+	}
+	iter, err := stub.GetStateByPartialCompositeKey(objectType, keys)
+	return applyDirection(iter, err, o)
+}
+
+// GetQueryResultWithOpts performs the rich query as configured by opts. It
+// calls GetQueryResult, or GetQueryResultWithPagination for a paginated
+// query, on stub. The metadata is only returned for paginated queries.
+func GetQueryResultWithOpts(stub ChaincodeStubInterface, query string, opts ...QueryOpts) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	o, err := queryOpts(opts, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	if o.Limit > 0 {
+		return stub.GetQueryResultWithPagination(query, o.Limit, o.Bookmark)
+	}
+	iter, err := stub.GetQueryResult(query)
+	return iter, nil, err
+}
+
+// applyDirection returns the results of iter in the direction of o, and at
+// most o.Limit of them.
+func applyDirection(iter StateQueryIteratorInterface, err error, o QueryOpts) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	if err != nil || o.Direction == Ascending {
+		return iter, nil, err
+	}
+	iter, err = ReverseStateIterator(iter, int(o.Limit))
+	return iter, nil, err
+}
@@ -0,0 +1,176 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+// queryStub records the query methods called and returns the keys a to e.
+type queryStub struct {
+	ChaincodeStubInterface
+	calls []string
+}
+
+func (s *queryStub) results() *sliceStateIterator {
+	iter := &sliceStateIterator{}
+	for _, k := range []string{"a", "b", "c", "d", "e"} {
+		iter.kvs = append(iter.kvs, &queryresult.KV{Key: k})
+	}
+	return iter
+}
+
+func (s *queryStub) paginated(call string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	s.calls = append(s.calls, call)
+	return s.results(), &peerpb.QueryResponseMetadata{Bookmark: "next"}, nil
+}
+
+func (s *queryStub) GetStateByRange(startKey, endKey string) (StateQueryIteratorInterface, error) {
+	s.calls = append(s.calls, fmt.Sprintf("range %s %s", startKey, endKey))
+	return s.results(), nil
+}
+
+func (s *queryStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	return s.paginated(fmt.Sprintf("range %s %s %d %s", startKey, endKey, pageSize, bookmark))
+}
+
+func (s *queryStub) GetStateByPartialCompositeKey(objectType string, keys []string) (StateQueryIteratorInterface, error) {
+	s.calls = append(s.calls, fmt.Sprintf("composite %s %v", objectType, keys))
+	return s.results(), nil
+}
+
+func (s *queryStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	return s.paginated(fmt.Sprintf("composite %s %v %d %s", objectType, keys, pageSize, bookmark))
+}
+
+func (s *queryStub) GetQueryResult(query string) (StateQueryIteratorInterface, error) {
+	s.calls = append(s.calls, "rich "+query)
+	return s.results(), nil
+}
+
+func (s *queryStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+	return s.paginated(fmt.Sprintf("rich %s %d %s", query, pageSize, bookmark))
+}
+
+func TestQueryWithOpts(t *testing.T) {
+	all := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		name     string
+		query    func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error)
+		call     string
+		keys     []string
+		metadata bool
+	}{
+		{
+			name: "Range",
+			query: func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+				return GetStateByRangeWithOpts(stub, "a", "z")
+			},
+			call: "range a z",
+			keys: all,
+		},
+		{
+			name: "RangePaginated",
+			query: func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+				return GetStateByRangeWithOpts(stub, "a", "z", QueryOpts{Limit: 5, Bookmark: "b"})
+			},
+			call:     "range a z 5 b",
+			keys:     all,
+			metadata: true,
+		},
+		{
+			name: "RangeDescendingLimit",
+			query: func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+				return GetStateByRangeWithOpts(stub, "a", "z", QueryOpts{Limit: 2, Direction: Descending})
+			},
+			call: "range a z",
+			keys: []string{"e", "d"},
+		},
+		{
+			name: "CompositeDescending",
+			query: func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+				return GetStateByPartialCompositeKeyWithOpts(stub, "type", []string{"x"}, QueryOpts{Direction: Descending})
+			},
+			call: "composite type [x]",
+			keys: []string{"e", "d", "c", "b", "a"},
+		},
+		{
+			name: "CompositePaginated",
+			query: func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+				return GetStateByPartialCompositeKeyWithOpts(stub, "type", nil, QueryOpts{Limit: 3})
+			},
+			call:     "composite type [] 3 ",
+			keys:     all,
+			metadata: true,
+		},
+		{
+			name: "Rich",
+			query: func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+				return GetQueryResultWithOpts(stub, "{}")
+			},
+			call: "rich {}",
+			keys: all,
+		},
+		{
+			name: "RichPaginated",
+			query: func(stub ChaincodeStubInterface) (StateQueryIteratorInterface, *peerpb.QueryResponseMetadata, error) {
+				return GetQueryResultWithOpts(stub, "{}", QueryOpts{Limit: 10})
+			},
+			call:     "rich {} 10 ",
+			keys:     all,
+			metadata: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &queryStub{}
+			iter, metadata, err := tt.query(stub)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{tt.call}, stub.calls)
+			assert.Equal(t, tt.metadata, metadata != nil)
+
+			var keys []string
+			for iter.HasNext() {
+				kv, err := iter.Next()
+				assert.NoError(t, err)
+				keys = append(keys, kv.Key)
+			}
+			assert.Equal(t, tt.keys, keys)
+		})
+	}
+}
+
+func TestQueryWithOptsInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		rich   bool
+		opts   []QueryOpts
+		errMsg string
+	}{
+		{"TooManyOpts", false, []QueryOpts{{}, {}}, "at most one QueryOpts may be passed"},
+		{"NegativeLimit", false, []QueryOpts{{Limit: -1}}, "query limit must not be negative"},
+		{"BookmarkWithoutLimit", false, []QueryOpts{{Bookmark: "b"}}, "query bookmark requires a limit"},
+		{"UnknownDirection", false, []QueryOpts{{Direction: 2}}, "unknown query direction"},
+		{"DescendingBookmark", false, []QueryOpts{{Limit: 1, Bookmark: "b", Direction: Descending}}, "descending queries do not support bookmarks"},
+		{"DescendingRich", true, []QueryOpts{{Direction: Descending}}, "rich queries do not support the descending direction"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub := &queryStub{}
+			var err error
+			if tt.rich {
+				_, _, err = GetQueryResultWithOpts(stub, "{}", tt.opts...)
+			} else {
+				_, _, err = GetStateByRangeWithOpts(stub, "a", "z", tt.opts...)
+			}
+			assert.EqualError(t, err, tt.errMsg)
+			assert.Empty(t, stub.calls)
+		})
+	}
+}
@@ -0,0 +1,62 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/rand"
+)
+
+// NewDeterministicRand returns a pseudo-random number generator seeded from
+// txID and nonce. Generators created with the same txID and nonce produce the
+// same stream of numbers on every endorser and with every Go version. It is
+// not suitable for security-sensitive work, as anyone who knows the
+// transaction ID and nonce can predict its output.
+func NewDeterministicRand(txID string, nonce []byte) *rand.Rand {
+	h := sha256.New()
+	var size [8]byte
+	binary.BigEndian.PutUint64(size[:], uint64(len(txID)))
+	h.Write(size[:])
+	h.Write([]byte(txID))
+	h.Write(nonce)
+
+	src := &hashSource{}
+	copy(src.seed[:], h.Sum(nil))
+	return rand.New(src)
+}
+
+// hashSource is a rand.Source64 producing SHA-256(seed || counter) blocks.
+// Unlike rand.NewSource, its output does not depend on the Go version and
+// it uses the full 256 bit seed.
+type hashSource struct {
+	seed    [sha256.Size]byte
+	counter uint64
+	block   [sha256.Size]byte
+	offset  int
+}
+
+func (s *hashSource) Uint64() uint64 {
+	if s.offset == 0 {
+		var in [sha256.Size + 8]byte
+		copy(in[:], s.seed[:])
+		binary.BigEndian.PutUint64(in[sha256.Size:], s.counter)
+		s.block = sha256.Sum256(in[:])
+		s.counter++
+	}
+	v := binary.BigEndian.Uint64(s.block[s.offset:])
+	s.offset = (s.offset + 8) % sha256.Size
+	return v
+}
+
+func (s *hashSource) Int63() int64 {
+	return int64(s.Uint64() >> 1)
+}
+
+// Seed is required by rand.Source. It reseeds the source with seed, which
+// chaincode has no reason to do.
+func (s *hashSource) Seed(seed int64) {
+	*s = hashSource{}
+	binary.BigEndian.PutUint64(s.seed[:], uint64(seed))
+}
@@ -0,0 +1,64 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/common"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeterministicRand(t *testing.T) {
+	draw := func(txID string, nonce []byte) []uint64 {
+		r := NewDeterministicRand(txID, nonce)
+		var out []uint64
+		for i := 0; i < 10; i++ {
+			out = append(out, r.Uint64())
+		}
+		return out
+	}
+
+	stream := draw("txid", []byte("nonce"))
+	assert.Equal(t, stream, draw("txid", []byte("nonce")))
+	assert.NotEqual(t, stream, draw("txid", []byte("other")))
+	assert.NotEqual(t, stream, draw("other", []byte("nonce")))
+	// the txid is length prefixed so bytes cannot move to the nonce
+	assert.NotEqual(t, stream, draw("txi", []byte("dnonce")))
+
+	// pin the output so that changes to the algorithm are noticed, as they
+	// would break endorsement of chaincode upgraded across shim versions
+	assert.Equal(t, uint64(0x5277b851e54903a3), stream[0])
+	assert.Equal(t, []int{2, 4, 3, 1, 0}, NewDeterministicRand("txid", []byte("nonce")).Perm(5))
+}
+
+func TestChaincodeStubDeterministicRand(t *testing.T) {
+	signedProposal := &peerpb.SignedProposal{
+		ProposalBytes: marshalOrPanic(&peerpb.Proposal{
+			Header: marshalOrPanic(&common.Header{
+				ChannelHeader: marshalOrPanic(&common.ChannelHeader{
+					Type: int32(common.HeaderType_ENDORSER_TRANSACTION),
+				}),
+				SignatureHeader: marshalOrPanic(&common.SignatureHeader{
+					Nonce: []byte("nonce"),
+				}),
+			}),
+			Payload: marshalOrPanic(&peerpb.ChaincodeProposalPayload{}),
+		}),
+	}
+	stub, err := newChaincodeStub(&Handler{}, "channel", "txid", &peerpb.ChaincodeInput{}, signedProposal)
+	require.NoError(t, err)
+
+	r := stub.DeterministicRand()
+	assert.Same(t, r, stub.DeterministicRand(), "expected the same generator on every call")
+	assert.Equal(t, NewDeterministicRand("txid", []byte("nonce")).Int63(), r.Int63())
+
+	// chaincode reaches the generator through a type assertion
+	var cc ChaincodeStubInterface = stub
+	_, ok := cc.(interface{ DeterministicRand() *rand.Rand })
+	assert.True(t, ok)
+}
@@ -0,0 +1,91 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// QueryPage is a batch of query results as fetched from the peer. The size
+// of the batches is set by the peer configuration and is unrelated to the
+// page size of paginated queries.
+type QueryPage struct {
+	// Results are the encoded results: queryresult.KV for state queries
+	// and queryresult.KeyModification for history queries.
+	Results []*pb.QueryResultBytes
+	// HasMore reports whether the peer holds further batches.
+	HasMore bool
+	// Metadata is the response metadata of paginated queries, holding the
+	// bookmark of the next page, when the peer sent it with this batch.
+	Metadata *pb.QueryResponseMetadata
+}
+
+// PageIterator iterates over the batches of results of a query as fetched
+// from the peer, leaving their decoding to the caller. A batch is fetched
+// by NextPage only, so the caller controls exactly how many round trips to
+// the peer are made.
+type PageIterator struct {
+	iter     *CommonIterator
+	consumed bool
+}
+
+// Pages returns a PageIterator over the results of iter, a state or history
+// query iterator returned by ChaincodeStub. Pages must be called before
+// any result is read with Next, and iter must not be used afterwards except
+// through the PageIterator. Iterators not backed by the peer, such as those
+// of shimtest.MockStub, are not supported.
+func Pages(iter CommonIteratorInterface) (*PageIterator, error) {
+	var common *CommonIterator
+	switch it := iter.(type) {
+	case *StateQueryIterator:
+		common = it.CommonIterator
+	case *HistoryQueryIterator:
+		common = it.CommonIterator
+	default:
+		return nil, fmt.Errorf("iterator of type %T does not expose its pages", iter)
+	}
+	if common.currentLoc != 0 {
+		return nil, errors.New("iterator has already been read")
+	}
+	return &PageIterator{iter: common}, nil
+}
+
+// HasNextPage returns true if NextPage returns another batch.
+func (p *PageIterator) HasNextPage() bool {
+	return !p.consumed || p.iter.response.HasMore
+}
+
+// NextPage returns the next batch of results, fetching it from the peer
+// unless it is the first one, which the peer returns with the query.
+func (p *PageIterator) NextPage() (*QueryPage, error) {
+	if p.consumed {
+		if !p.iter.response.HasMore {
+			return nil, errors.New("no more pages")
+		}
+		if err := p.iter.fetchNextQueryResult(); err != nil {
+			return nil, err
+		}
+	}
+	p.consumed = true
+
+	response := p.iter.response
+	page := &QueryPage{Results: response.Results, HasMore: response.HasMore}
+	if len(response.Metadata) > 0 {
+		metadata, err := createQueryResponseMetadata(response.Metadata)
+		if err != nil {
+			return nil, err
+		}
+		page.Metadata = metadata
+	}
+	return page, nil
+}
+
+// Close closes the query on the peer, which releases the batches not
+// fetched yet.
+func (p *PageIterator) Close() error {
+	return p.iter.Close()
+}
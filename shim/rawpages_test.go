@@ -0,0 +1,103 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"sync/atomic"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newBatchingStub returns a stub whose queries return the first of
+// responses, the others being returned by QUERY_STATE_NEXT in order, and
+// the counter of QUERY_STATE_NEXT messages.
+func newBatchingStub(responses ...*peerpb.QueryResponse) (*ChaincodeStub, *int32) {
+	handler := &Handler{
+		cc:     &mockChaincode{},
+		router: NewResponseRouter(),
+		state:  ready,
+	}
+	stub := &ChaincodeStub{ChannelID: "channel", TxID: "txid", handler: handler}
+
+	var fetches int32
+	chatStream := &mock.PeerChaincodeStream{}
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		var payload []byte
+		switch msg.Type {
+		case peerpb.ChaincodeMessage_QUERY_STATE_NEXT:
+			payload = marshalOrPanic(responses[atomic.AddInt32(&fetches, 1)])
+		case peerpb.ChaincodeMessage_QUERY_STATE_CLOSE:
+			payload = marshalOrPanic(&peerpb.QueryResponse{Id: responses[0].Id})
+		default:
+			payload = marshalOrPanic(responses[0])
+		}
+		go handler.handleResponse(&peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_RESPONSE, ChannelId: msg.ChannelId, Txid: msg.Txid, Payload: payload})
+		return nil
+	}
+	handler.chatStream = chatStream
+	return stub, &fetches
+}
+
+func TestPages(t *testing.T) {
+	kv := func(key string) *peerpb.QueryResultBytes {
+		return &peerpb.QueryResultBytes{ResultBytes: marshalOrPanic(&queryresult.KV{Key: key, Value: []byte("value")})}
+	}
+	first := &peerpb.QueryResponse{
+		Results:  []*peerpb.QueryResultBytes{kv("a"), kv("b")},
+		HasMore:  true,
+		Id:       "queryid",
+		Metadata: marshalOrPanic(&peerpb.QueryResponseMetadata{Bookmark: "c", FetchedRecordsCount: 3}),
+	}
+	second := &peerpb.QueryResponse{Results: []*peerpb.QueryResultBytes{kv("c")}, Id: "queryid"}
+
+	stub, fetches := newBatchingStub(first, second)
+
+	iter, _, err := stub.GetQueryResultWithPagination("query", 3, "")
+	require.NoError(t, err)
+	pages, err := Pages(iter)
+	require.NoError(t, err)
+
+	require.True(t, pages.HasNextPage())
+	page, err := pages.NextPage()
+	require.NoError(t, err)
+	assert.Equal(t, resultBytes(first.Results), resultBytes(page.Results))
+	assert.True(t, page.HasMore)
+	assert.Equal(t, "c", page.Metadata.Bookmark)
+	assert.Equal(t, int32(0), atomic.LoadInt32(fetches), "the first page comes with the query")
+
+	require.True(t, pages.HasNextPage())
+	page, err = pages.NextPage()
+	require.NoError(t, err)
+	assert.Equal(t, resultBytes(second.Results), resultBytes(page.Results))
+	assert.False(t, page.HasMore)
+	assert.Nil(t, page.Metadata)
+	assert.Equal(t, int32(1), atomic.LoadInt32(fetches))
+
+	assert.False(t, pages.HasNextPage())
+	_, err = pages.NextPage()
+	assert.EqualError(t, err, "no more pages")
+	assert.NoError(t, pages.Close())
+}
+
+func resultBytes(results []*peerpb.QueryResultBytes) [][]byte {
+	var b [][]byte
+	for _, r := range results {
+		b = append(b, r.ResultBytes)
+	}
+	return b
+}
+
+func TestPagesUnsupported(t *testing.T) {
+	_, err := Pages(&sliceStateIterator{})
+	assert.EqualError(t, err, "iterator of type *shim.sliceStateIterator does not expose its pages")
+
+	iter := &StateQueryIterator{CommonIterator: &CommonIterator{response: &peerpb.QueryResponse{}, currentLoc: 1}}
+	_, err = Pages(iter)
+	assert.EqualError(t, err, "iterator has already been read")
+}
@@ -0,0 +1,63 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"time"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+)
+
+// ReconnectPolicy configures the reconnection to the peer when the stream
+// to the peer fails.
+type ReconnectPolicy struct {
+	// MaxAttempts is the maximum number of times the peers are dialed in
+	// turn after the stream fails before Start gives up; zero means no
+	// limit.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first reconnection after all
+	// the peers failed. The delay doubles after every attempt.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between attempts; zero means no cap.
+	MaxBackoff time.Duration
+}
+
+// WithReconnect makes Start reconnect to the peer and register the
+// chaincode again when the stream to the peer ends, instead of returning.
+// Every attempt dials a new connection, resolving the name of the peer
+// again, so that a peer restarted with a new IP address is reached without
+// restarting the chaincode.
+func WithReconnect(policy ReconnectPolicy) Option {
+	return func(h *Handler) error {
+		if policy.MaxAttempts < 0 {
+			return errors.New("reconnect policy max attempts must not be negative")
+		}
+		if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 {
+			return errors.New("reconnect policy backoff must not be negative")
+		}
+		h.reconnect = &policy
+		return nil
+	}
+}
+
+// backoff returns the delay before the reconnection following attempt.
+func (p *ReconnectPolicy) backoff(attempt int) time.Duration {
+	retry := RetryPolicy{InitialBackoff: p.InitialBackoff, MaxBackoff: p.MaxBackoff}
+	return retry.backoff(attempt)
+}
+
+// connStream is a stream to the peer which closes its connection when it
+// is closed, so that the connections of failed streams are not left open.
+type connStream struct {
+	peerpb.ChaincodeSupport_RegisterClient
+	conn *grpc.ClientConn
+}
+
+func (s *connStream) CloseSend() error {
+	err := s.ChaincodeSupport_RegisterClient.CloseSend()
+	s.conn.Close()
+	return err
+}
@@ -0,0 +1,154 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestWithReconnect(t *testing.T) {
+	var tests = []struct {
+		name        string
+		policy      ReconnectPolicy
+		expectedErr string
+	}{
+		{name: "Valid", policy: ReconnectPolicy{MaxAttempts: 3, InitialBackoff: time.Second}},
+		{name: "Unlimited", policy: ReconnectPolicy{}},
+		{name: "Negative Attempts", policy: ReconnectPolicy{MaxAttempts: -1}, expectedErr: "reconnect policy max attempts must not be negative"},
+		{name: "Negative Backoff", policy: ReconnectPolicy{InitialBackoff: -1}, expectedErr: "reconnect policy backoff must not be negative"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h, err := newChaincodeHandler(nil, &mockChaincode{}, WithReconnect(test.policy))
+			if test.expectedErr != "" {
+				assert.EqualError(t, err, test.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, &test.policy, h.reconnect)
+		})
+	}
+}
+
+func TestReconnectBackoff(t *testing.T) {
+	p := &ReconnectPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	assert.Equal(t, time.Second, p.backoff(1))
+	assert.Equal(t, 2*time.Second, p.backoff(2))
+	assert.Equal(t, 4*time.Second, p.backoff(3))
+	assert.Equal(t, 5*time.Second, p.backoff(4))
+}
+
+func TestStartWithReconnect(t *testing.T) {
+	registered := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}
+	refused := errors.New("connection refused")
+
+	dials := 0
+	var stream *pipeStream
+	dial := func(address string) (PeerChaincodeStream, error) {
+		dials++
+		if dials != 3 {
+			return nil, refused
+		}
+		stream = newPipeStream(registered)
+		return stream, nil
+	}
+	// the peer restarts once the chaincode registered
+	restart := func(string, []PeerHealth) { stream.CloseSend() }
+
+	logger := &recordingLogger{}
+	opts := []Option{
+		WithLogger(logger),
+		WithReconnect(ReconnectPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}),
+		WithPeerFailoverCallback(restart),
+	}
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, opts...)
+	require.NoError(t, err)
+
+	err = startWithFailover([]string{"peer0"}, dial, "cc", &mockChaincode{}, h, opts...)
+	assert.EqualError(t, err, "failed to connect to peer peer0: connection refused")
+	assert.Equal(t, 5, dials, "the attempts start over once the chaincode registered")
+	assert.Contains(t, logger.lines, "reconnecting to peers in 2ms (attempt 2)")
+}
+
+func TestStartWithReconnectUnregisters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reconnect")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`{}`), 0600))
+
+	registered := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}
+	dials := 0
+	var stream *pipeStream
+	dial := func(address string) (PeerChaincodeStream, error) {
+		dials++
+		if dials != 2 && dials != 4 {
+			return nil, errors.New("connection refused")
+		}
+		stream = newPipeStream(registered)
+		return stream, nil
+	}
+
+	var h *Handler
+	var served, configured []int
+	restart := func(string, []PeerHealth) {
+		h.debugServer.mutex.Lock()
+		served = append(served, len(h.debugServer.handlers))
+		h.debugServer.mutex.Unlock()
+		h.configReloader.mutex.Lock()
+		configured = append(configured, len(h.configReloader.handlers))
+		h.configReloader.mutex.Unlock()
+		stream.CloseSend()
+	}
+	opts := []Option{
+		WithDebugServer("127.0.0.1:0"),
+		WithConfigReload(path, 0),
+		WithReconnect(ReconnectPolicy{MaxAttempts: 1, InitialBackoff: time.Millisecond}),
+		WithPeerFailoverCallback(restart),
+		WithLogger(&recordingLogger{}),
+	}
+	h, err = newChaincodeHandler(nil, &mockChaincode{}, opts...)
+	require.NoError(t, err)
+	defer h.debugServer.listener.Close()
+
+	err = startWithFailover([]string{"peer0", "peer1"}, dial, "cc", &mockChaincode{}, h, opts...)
+	assert.EqualError(t, err, "failed to connect to peer peer0: connection refused")
+	assert.Equal(t, []int{1, 1}, served, "the handlers of the streams which ended are not served")
+	assert.Equal(t, []int{1, 1}, configured, "the handlers of the streams which ended are not configured")
+	assert.Empty(t, h.debugServer.handlers)
+	assert.Empty(t, h.configReloader.handlers)
+}
+
+func TestConnStreamCloseSend(t *testing.T) {
+	conn, err := grpc.Dial("127.0.0.1:0", grpc.WithInsecure())
+	require.NoError(t, err)
+	client := &closeRecorder{}
+	stream := &connStream{ChaincodeSupport_RegisterClient: client, conn: conn}
+
+	assert.NoError(t, stream.CloseSend())
+	assert.Equal(t, 1, client.closed)
+	assert.Equal(t, connectivity.Shutdown, conn.GetState())
+}
+
+type closeRecorder struct {
+	peerpb.ChaincodeSupport_RegisterClient
+	closed int
+}
+
+func (c *closeRecorder) CloseSend() error {
+	c.closed++
+	return nil
+}
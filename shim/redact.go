@@ -0,0 +1,34 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// LogPayloadsEnv is the environment variable which, when set to true,
+// makes Redact return payloads as is. It is meant for debugging chaincode
+// on a development network only: state values and transient data then
+// appear in the errors and logs of the shim.
+const LogPayloadsEnv = "CORE_CHAINCODE_LOG_PAYLOADS"
+
+// logPayloads is set from LogPayloadsEnv when the package is initialized.
+var logPayloads = payloadLoggingFromEnv()
+
+func payloadLoggingFromEnv() bool {
+	enabled, _ := strconv.ParseBool(os.Getenv(LogPayloadsEnv))
+	return enabled
+}
+
+// Redact returns the text to include in an error or a log record in place
+// of payload, such as a state value or transient data: its size, unless
+// LogPayloadsEnv is set to true, in which case payload itself.
+func Redact(payload []byte) string {
+	if logPayloads {
+		return string(payload)
+	}
+	return fmt.Sprintf("<redacted %d bytes>", len(payload))
+}
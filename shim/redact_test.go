@@ -0,0 +1,33 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedact(t *testing.T) {
+	defer func(enabled bool) { logPayloads = enabled }(logPayloads)
+
+	logPayloads = false
+	assert.Equal(t, "<redacted 6 bytes>", Redact([]byte("secret")))
+	assert.Equal(t, "<redacted 0 bytes>", Redact(nil))
+	assert.NotContains(t, (&Handler{}).features(), "log_payloads")
+
+	logPayloads = true
+	assert.Equal(t, "secret", Redact([]byte("secret")))
+	assert.Equal(t, "true", (&Handler{}).features()["log_payloads"])
+}
+
+func TestPayloadLoggingFromEnv(t *testing.T) {
+	defer os.Unsetenv(LogPayloadsEnv)
+
+	for value, expected := range map[string]bool{"": false, "false": false, "yes": false, "true": true, "1": true} {
+		os.Setenv(LogPayloadsEnv, value)
+		assert.Equal(t, expected, payloadLoggingFromEnv(), "%s=%q", LogPayloadsEnv, value)
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ConfigFileEnv sets the runtime configuration file reloaded by Start; see
+// WithConfigReload.
+const ConfigFileEnv = "CORE_CHAINCODE_CONFIG_FILE"
+
+// DefaultConfigPollInterval is the interval at which the runtime
+// configuration file set by ConfigFileEnv is checked for changes.
+const DefaultConfigPollInterval = 10 * time.Second
+
+// RuntimeConfig holds the settings of the shim which can be changed while
+// the chaincode runs. It is read from a JSON file such as
+//
+//	{
+//	  "quiet": false,
+//	  "log_sampling": {"burst": 5, "interval": "30s"},
+//	  "scheduler": {"workers": 8, "quotas": {"busy": 2}, "weights": {"important": 4}}
+//	}
+//
+// The sections left out of the file keep the settings configured when the
+// chaincode started.
+type RuntimeConfig struct {
+	// Quiet suppresses the log records of the shim.
+	Quiet bool `json:"quiet"`
+	// LogSampling replaces the sampling of the handler errors logged.
+	LogSampling *RuntimeLogSampling `json:"log_sampling"`
+	// Scheduler replaces the configuration of the channel scheduler, which
+	// must have been enabled when the chaincode started.
+	Scheduler *RuntimeScheduler `json:"scheduler"`
+}
+
+// RuntimeLogSampling is the JSON form of LogSampling. Interval is a
+// duration such as "1m".
+type RuntimeLogSampling struct {
+	Burst    int    `json:"burst"`
+	Interval string `json:"interval"`
+}
+
+// RuntimeScheduler is the JSON form of SchedulerConfig.
+type RuntimeScheduler struct {
+	Workers      int            `json:"workers"`
+	Quotas       map[string]int `json:"quotas"`
+	DefaultQuota int            `json:"default_quota"`
+	Weights      map[string]int `json:"weights"`
+}
+
+// WithConfigReload applies the runtime configuration of the file at path
+// when the chaincode starts, and again whenever the process receives
+// SIGHUP or, if pollInterval is positive, the modification time of the file
+// changes. A configuration which cannot be read or applied when reloading
+// is logged and ignored. The configuration applies to all the streams
+// opened by Start.
+func WithConfigReload(path string, pollInterval time.Duration) Option {
+	r := &configReloader{path: path, pollInterval: pollInterval}
+	return func(h *Handler) error {
+		return r.register(h)
+	}
+}
+
+// configReloadFromEnv returns the option reloading the configuration file
+// set by ConfigFileEnv, or nil if it is not set.
+func configReloadFromEnv() Option {
+	path := os.Getenv(ConfigFileEnv)
+	if path == "" {
+		return nil
+	}
+	return WithConfigReload(path, DefaultConfigPollInterval)
+}
+
+// configReloader applies a runtime configuration file to the handlers of
+// the streams of a chaincode.
+type configReloader struct {
+	path         string
+	pollInterval time.Duration
+
+	mutex    sync.Mutex
+	handlers []*Handler
+	// sampling and schedulerConfig are the settings configured when the
+	// chaincode started, restored when the file leaves them out.
+	sampling        LogSampling
+	schedulerConfig *SchedulerConfig
+	// current is the configuration applied, nil until the first handler
+	// is registered.
+	current *RuntimeConfig
+	modTime time.Time
+}
+
+// register adds h to the handlers configured, applying the configuration
+// and starting to watch it on first use.
+func (r *configReloader) register(h *Handler) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if h.errorSampler == nil {
+		// the handlers need their own sampler to change its sampling
+		if len(r.handlers) > 0 {
+			h.errorSampler = r.handlers[0].errorSampler
+		} else {
+			h.errorSampler = newLogSampler(DefaultLogSampling)
+		}
+	}
+	h.configReloader = r
+	r.handlers = append(r.handlers, h)
+	if r.current != nil {
+		return r.applyLocked(r.current)
+	}
+
+	r.sampling = h.errorSampler.sampling
+	if h.scheduler != nil {
+		config := h.scheduler.config
+		r.schedulerConfig = &config
+	}
+	config, modTime, err := r.load()
+	if err != nil {
+		return err
+	}
+	if err := r.applyLocked(config); err != nil {
+		return fmt.Errorf("invalid runtime configuration %s: %s", r.path, err)
+	}
+	r.modTime = modTime
+	go r.watch(h.logf)
+	return nil
+}
+
+// unregister removes h from the handlers configured once its stream ended.
+func (r *configReloader) unregister(h *Handler) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i, registered := range r.handlers {
+		if registered == h {
+			r.handlers = append(r.handlers[:i], r.handlers[i+1:]...)
+			return
+		}
+	}
+}
+
+// load reads the configuration file.
+func (r *configReloader) load() (*RuntimeConfig, time.Time, error) {
+	info, err := os.Stat(r.path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read runtime configuration: %s", err)
+	}
+	data, err := ioutil.ReadFile(r.path)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to read runtime configuration: %s", err)
+	}
+	config := &RuntimeConfig{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to parse runtime configuration %s: %s", r.path, err)
+	}
+	return config, info.ModTime(), nil
+}
+
+// watch reloads the configuration on SIGHUP and when the file changes.
+func (r *configReloader) watch(logf func(string, ...interface{})) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if r.pollInterval > 0 {
+		ticker := time.NewTicker(r.pollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-hup:
+			r.reload(logf, true)
+		case <-tick:
+			r.reload(logf, false)
+		}
+	}
+}
+
+// reload applies the configuration file if force is set or it changed
+// since it was last applied.
+func (r *configReloader) reload(logf func(string, ...interface{}), force bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !force {
+		info, err := os.Stat(r.path)
+		if err == nil && info.ModTime().Equal(r.modTime) {
+			return
+		}
+	}
+	config, modTime, err := r.load()
+	if err == nil {
+		err = r.applyLocked(config)
+	}
+	// the file is not checked again until it changes, whether it could be
+	// applied or not
+	r.modTime = modTime
+	if err != nil {
+		logf("failed to reload runtime configuration %s: %s", r.path, err)
+		return
+	}
+	logf("reloaded runtime configuration %s", r.path)
+}
+
+// applyLocked applies config to the handlers. The configuration is
+// validated before anything is changed.
+func (r *configReloader) applyLocked(config *RuntimeConfig) error {
+	sampling := r.sampling
+	if config.LogSampling != nil {
+		interval, err := time.ParseDuration(config.LogSampling.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid log sampling interval: %s", err)
+		}
+		sampling = LogSampling{Burst: config.LogSampling.Burst, Interval: interval}
+		if sampling.Burst <= 0 || sampling.Interval <= 0 {
+			return errors.New("log sampling burst and interval must be positive")
+		}
+	}
+	schedulerConfig := r.schedulerConfig
+	if config.Scheduler != nil {
+		if r.schedulerConfig == nil {
+			return errors.New("the channel scheduler is not enabled")
+		}
+		schedulerConfig = &SchedulerConfig{
+			Workers:      config.Scheduler.Workers,
+			Quotas:       config.Scheduler.Quotas,
+			DefaultQuota: config.Scheduler.DefaultQuota,
+			Weights:      config.Scheduler.Weights,
+		}
+		if _, err := newScheduler(*schedulerConfig); err != nil {
+			return err
+		}
+	}
+
+	var quiet int32
+	if config.Quiet {
+		quiet = 1
+	}
+	for _, h := range r.handlers {
+		atomic.StoreInt32(&h.quiet, quiet)
+		h.errorSampler.setSampling(sampling)
+		if schedulerConfig != nil && h.scheduler != nil {
+			h.scheduler.reconfigure(*schedulerConfig)
+		}
+	}
+	r.current = config
+	return nil
+}
@@ -0,0 +1,156 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeRuntimeConfig(t *testing.T, path, config string) {
+	require.NoError(t, ioutil.WriteFile(path, []byte(config), 0600))
+}
+
+func TestWithConfigReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+
+	var tests = []struct {
+		name        string
+		config      string
+		opts        []Option
+		expectedErr string
+	}{
+		{name: "Valid", config: `{"log_sampling": {"burst": 1, "interval": "1s"}}`},
+		{name: "Missing", expectedErr: "failed to read runtime configuration: stat " + path + ": no such file or directory"},
+		{name: "Malformed", config: `{`, expectedErr: "failed to parse runtime configuration " + path + ": unexpected end of JSON input"},
+		{
+			name:        "Invalid Interval",
+			config:      `{"log_sampling": {"burst": 1, "interval": "soon"}}`,
+			expectedErr: "invalid runtime configuration " + path + `: invalid log sampling interval: time: invalid duration "soon"`,
+		},
+		{
+			name:        "Zero Burst",
+			config:      `{"log_sampling": {"interval": "1s"}}`,
+			expectedErr: "invalid runtime configuration " + path + ": log sampling burst and interval must be positive",
+		},
+		{
+			name:        "No Scheduler",
+			config:      `{"scheduler": {"workers": 2}}`,
+			expectedErr: "invalid runtime configuration " + path + ": the channel scheduler is not enabled",
+		},
+		{
+			name:        "Invalid Scheduler",
+			config:      `{"scheduler": {"workers": 0}}`,
+			opts:        []Option{WithChannelScheduler(SchedulerConfig{Workers: 1})},
+			expectedErr: "invalid runtime configuration " + path + ": scheduler workers must be positive, got 0",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Remove(path)
+			if test.config != "" {
+				writeRuntimeConfig(t, path, test.config)
+			}
+			opts := append(test.opts, WithConfigReload(path, 0))
+			_, err := newChaincodeHandler(nil, &mockChaincode{}, opts...)
+			if test.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.expectedErr)
+			}
+		})
+	}
+}
+
+func TestConfigReload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	writeRuntimeConfig(t, path, `{"log_sampling": {"burst": 3, "interval": "2m"}}`)
+
+	logger := &recordingLogger{}
+	startup := SchedulerConfig{Workers: 2}
+	reload := WithConfigReload(path, 0)
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithLogger(logger), WithChannelScheduler(startup), reload)
+	require.NoError(t, err)
+	assert.Equal(t, LogSampling{Burst: 3, Interval: 2 * time.Minute}, h.errorSampler.sampling)
+	assert.Equal(t, path, h.features()["config_file"])
+
+	writeRuntimeConfig(t, path, `{"scheduler": {"workers": 4, "quotas": {"busy": 1}}}`)
+	h.configReloader.reload(h.logf, true)
+	assert.Equal(t, DefaultLogSampling, h.errorSampler.sampling, "left out sections revert to the startup configuration")
+	assert.Equal(t, SchedulerConfig{Workers: 4, Quotas: map[string]int{"busy": 1}}, h.scheduler.config)
+	assert.Equal(t, []string{"reloaded runtime configuration " + path}, logger.lines)
+
+	// a handler of another stream gets the configuration of the file
+	h2, err := newChaincodeHandler(nil, &mockChaincode{}, WithLogger(logger), WithChannelScheduler(startup), reload)
+	require.NoError(t, err)
+	assert.Equal(t, 4, h2.scheduler.config.Workers)
+
+	writeRuntimeConfig(t, path, `{"scheduler": {"workers": -1}}`)
+	h.configReloader.reload(h.logf, true)
+	assert.Equal(t, 4, h.scheduler.config.Workers, "an invalid configuration is ignored")
+	assert.Equal(t, "failed to reload runtime configuration "+path+": scheduler workers must be positive, got -1", logger.lines[1])
+
+	writeRuntimeConfig(t, path, `{}`)
+	h.configReloader.reload(h.logf, true)
+	assert.Equal(t, startup, h.scheduler.config)
+
+	writeRuntimeConfig(t, path, `{"quiet": true}`)
+	h.configReloader.reload(h.logf, true)
+	h2.logf("quiet")
+	assert.Len(t, logger.lines, 3)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&h.quiet))
+}
+
+func TestConfigReloadPolling(t *testing.T) {
+	dir, err := ioutil.TempDir("", "reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.json")
+	writeRuntimeConfig(t, path, `{}`)
+
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithLogger(&recordingLogger{}), WithConfigReload(path, 10*time.Millisecond))
+	require.NoError(t, err)
+
+	writeRuntimeConfig(t, path, `{"log_sampling": {"burst": 7, "interval": "1s"}}`)
+	// the modification time may not have changed on file systems with a
+	// coarse resolution
+	later := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(path, later, later))
+
+	sampling := func() LogSampling {
+		h.errorSampler.mutex.Lock()
+		defer h.errorSampler.mutex.Unlock()
+		return h.errorSampler.sampling
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for sampling().Burst != 7 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, LogSampling{Burst: 7, Interval: time.Second}, sampling())
+}
+
+func TestConfigReloadFromEnv(t *testing.T) {
+	assert.Nil(t, configReloadFromEnv())
+
+	os.Setenv(ConfigFileEnv, "/nonexistent/config.json")
+	defer os.Unsetenv(ConfigFileEnv)
+	opt := configReloadFromEnv()
+	require.NotNil(t, opt)
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, opt)
+	assert.EqualError(t, err, "failed to read runtime configuration: stat /nonexistent/config.json: no such file or directory")
+}
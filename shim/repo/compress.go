@@ -0,0 +1,91 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.18
+// +build go1.18
+
+package repo
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+const (
+	// headerRaw starts values stored without compression by Compressed.
+	headerRaw = 0x00
+	// headerDeflate starts values compressed with DEFLATE by Compressed.
+	headerDeflate = 0x01
+
+	// compressionLevel is the fixed DEFLATE level of Compressed, part of
+	// the format: changing it changes the stored bytes.
+	compressionLevel = flate.BestCompression
+)
+
+type compressedCodec[T any] struct {
+	codec   Codec[T]
+	minSize int
+}
+
+// Compressed returns a codec compressing the encoding of codec with
+// DEFLATE when it is at least minSize bytes long, to reduce the size of
+// the state of ledgers storing large documents. Stored values start with
+// a header byte telling whether they are compressed; values without the
+// header, written before compression was enabled, are decoded by codec
+// directly, since JSON never starts with one of the header bytes.
+//
+// The compression level is fixed, so that every endorser running the same
+// chaincode binary writes identical bytes. Endorsers running binaries
+// built with different Go releases may compress differently and fail to
+// agree on the write set, so chaincode enabling compression must be built
+// once, for example as an external service. Compressed values are not
+// JSON, so repositories using this codec do not support rich queries.
+func Compressed[T any](codec Codec[T], minSize int) Codec[T] {
+	return compressedCodec[T]{codec: codec, minSize: minSize}
+}
+
+func (c compressedCodec[T]) Marshal(value T) ([]byte, error) {
+	data, err := c.codec.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < c.minSize {
+		return append([]byte{headerRaw}, data...), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(headerDeflate)
+	w, err := flate.NewWriter(&buf, compressionLevel)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c compressedCodec[T]) Unmarshal(data []byte, value *T) error {
+	if len(data) == 0 {
+		return c.codec.Unmarshal(data, value)
+	}
+	switch data[0] {
+	case headerRaw:
+		return c.codec.Unmarshal(data[1:], value)
+	case headerDeflate:
+		r := flate.NewReader(bytes.NewReader(data[1:]))
+		defer r.Close()
+		decompressed, err := io.ReadAll(r)
+		if err != nil {
+			return fmt.Errorf("failed to decompress value: %s", err)
+		}
+		return c.codec.Unmarshal(decompressed, value)
+	default:
+		return c.codec.Unmarshal(data, value)
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.18
+// +build go1.18
+
+package repo_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/repo"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type document struct {
+	ID   string `json:"id"`
+	Body string `json:"body"`
+}
+
+func TestCompressed(t *testing.T) {
+	codec := repo.Compressed(repo.JSON[document](), 64)
+
+	small := document{ID: "d1", Body: "short"}
+	data, err := codec.Marshal(small)
+	require.NoError(t, err)
+	assert.Equal(t, append([]byte{0x00}, `{"id":"d1","body":"short"}`...), data)
+
+	large := document{ID: "d2", Body: strings.Repeat("lorem ipsum ", 100)}
+	data, err = codec.Marshal(large)
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x01), data[0])
+	assert.True(t, len(data) < 200, "compressed to %d bytes", len(data))
+
+	again, err := codec.Marshal(large)
+	require.NoError(t, err)
+	assert.Equal(t, data, again, "compression is deterministic")
+
+	var decoded document
+	require.NoError(t, codec.Unmarshal(data, &decoded))
+	assert.Equal(t, large, decoded)
+
+	// values written before compression was enabled
+	require.NoError(t, codec.Unmarshal([]byte(`{"id":"d3","body":"legacy"}`), &decoded))
+	assert.Equal(t, document{ID: "d3", Body: "legacy"}, decoded)
+
+	err = codec.Unmarshal([]byte{0x01, 0xff, 0xff}, &decoded)
+	assert.Contains(t, err.Error(), "failed to decompress value")
+}
+
+func TestCompressedRepository(t *testing.T) {
+	docs := repo.New[document](repo.CompositeKeys("doc"), repo.Compressed(repo.JSON[document](), 0), func(d document) []string {
+		return []string{d.ID}
+	})
+	stub := shimtest.NewMockStub("repo", nil)
+	stub.MockTransactionStart("put")
+	require.NoError(t, docs.Put(stub, document{ID: "d1", Body: strings.Repeat("a", 1000)}))
+	stub.MockTransactionEnd("put")
+
+	d, found, err := docs.Get(stub, "d1")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Len(t, d.Body, 1000)
+
+	all, err := docs.List(stub)
+	require.NoError(t, err)
+	assert.Len(t, all, 1)
+}
@@ -0,0 +1,206 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.18
+// +build go1.18
+
+// Package repo provides typed access to entities stored in the ledger.
+//
+// A Repository stores values of a type T, encoded by a Codec, under the keys
+// given by a KeyStrategy for the ID of each value:
+//
+//	assets := repo.New[Asset](repo.CompositeKeys("asset"), repo.JSON[Asset](), func(a Asset) []string {
+//		return []string{a.Owner, a.ID}
+//	})
+//	err := assets.Put(stub, Asset{Owner: "alice", ID: "a1"})
+//	asset, found, err := assets.Get(stub, "alice", "a1")
+//	owned, err := assets.List(stub, "alice")
+//
+// Wrapping the codec with Compressed stores large values compressed.
+package repo
+
+import (
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Codec encodes and decodes values of type T.
+type Codec[T any] interface {
+	Marshal(value T) ([]byte, error)
+	Unmarshal(data []byte, value *T) error
+}
+
+type jsonCodec[T any] struct{}
+
+func (jsonCodec[T]) Marshal(value T) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+func (jsonCodec[T]) Unmarshal(data []byte, value *T) error {
+	return json.Unmarshal(data, value)
+}
+
+// JSON returns a codec encoding values as JSON. Repositories using it
+// support rich queries.
+func JSON[T any]() Codec[T] {
+	return jsonCodec[T]{}
+}
+
+// KeyStrategy maps the IDs of entities to state keys. An ID is a list of
+// one or more attributes.
+type KeyStrategy interface {
+	// Key returns the state key of the entity with the given ID.
+	Key(stub shim.ChaincodeStubInterface, id []string) (string, error)
+	// List returns an iterator over the entities whose ID starts with the
+	// attributes of partial.
+	List(stub shim.ChaincodeStubInterface, partial []string) (shim.StateQueryIteratorInterface, error)
+}
+
+type simpleKeys struct {
+	prefix string
+}
+
+// SimpleKeys stores entities under the simple key formed by prefix and their
+// ID, which must have a single attribute.
+func SimpleKeys(prefix string) KeyStrategy {
+	return simpleKeys{prefix: prefix}
+}
+
+func (s simpleKeys) Key(stub shim.ChaincodeStubInterface, id []string) (string, error) {
+	if len(id) != 1 {
+		return "", fmt.Errorf("simple key ID must have a single attribute, got %d", len(id))
+	}
+	return s.prefix + id[0], nil
+}
+
+func (s simpleKeys) List(stub shim.ChaincodeStubInterface, partial []string) (shim.StateQueryIteratorInterface, error) {
+	if len(partial) > 1 {
+		return nil, fmt.Errorf("simple key ID must have a single attribute, got %d", len(partial))
+	}
+	start := s.prefix
+	if len(partial) == 1 {
+		start += partial[0]
+	}
+	end := ""
+	if start != "" {
+		end = start + string(utf8.MaxRune)
+	}
+	return stub.GetStateByRange(start, end)
+}
+
+type compositeKeys struct {
+	objectType string
+}
+
+// CompositeKeys stores entities under the composite key formed by
+// objectType and the attributes of their ID.
+func CompositeKeys(objectType string) KeyStrategy {
+	return compositeKeys{objectType: objectType}
+}
+
+func (c compositeKeys) Key(stub shim.ChaincodeStubInterface, id []string) (string, error) {
+	if len(id) == 0 {
+		return "", fmt.Errorf("composite key ID of %s must have at least one attribute", c.objectType)
+	}
+	return stub.CreateCompositeKey(c.objectType, id)
+}
+
+func (c compositeKeys) List(stub shim.ChaincodeStubInterface, partial []string) (shim.StateQueryIteratorInterface, error) {
+	return stub.GetStateByPartialCompositeKey(c.objectType, partial)
+}
+
+// Repository stores values of type T in the ledger.
+type Repository[T any] struct {
+	keys  KeyStrategy
+	codec Codec[T]
+	id    func(T) []string
+}
+
+// New returns a repository storing values under the keys given by keys for
+// the ID returned by id, encoded with codec.
+func New[T any](keys KeyStrategy, codec Codec[T], id func(T) []string) *Repository[T] {
+	return &Repository[T]{keys: keys, codec: codec, id: id}
+}
+
+// Get returns the value with the given ID, and whether it exists.
+func (r *Repository[T]) Get(stub shim.ChaincodeStubInterface, id ...string) (T, bool, error) {
+	var value T
+	key, err := r.keys.Key(stub, id)
+	if err != nil {
+		return value, false, err
+	}
+	data, err := stub.GetState(key)
+	if err != nil {
+		return value, false, err
+	}
+	if data == nil {
+		return value, false, nil
+	}
+	if err := r.codec.Unmarshal(data, &value); err != nil {
+		return value, false, fmt.Errorf("failed to decode value of key %s: %s", key, err)
+	}
+	return value, true, nil
+}
+
+// Put stores value under the key of its ID.
+func (r *Repository[T]) Put(stub shim.ChaincodeStubInterface, value T) error {
+	key, err := r.keys.Key(stub, r.id(value))
+	if err != nil {
+		return err
+	}
+	data, err := r.codec.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to encode value of key %s: %s", key, err)
+	}
+	return stub.PutState(key, data)
+}
+
+// Delete deletes the value with the given ID.
+func (r *Repository[T]) Delete(stub shim.ChaincodeStubInterface, id ...string) error {
+	key, err := r.keys.Key(stub, id)
+	if err != nil {
+		return err
+	}
+	return stub.DelState(key)
+}
+
+// List returns the values whose ID starts with the attributes of partial,
+// or all the values if partial is empty, in key order.
+func (r *Repository[T]) List(stub shim.ChaincodeStubInterface, partial ...string) ([]T, error) {
+	iter, err := r.keys.List(stub, partial)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeAll(iter)
+}
+
+// Query returns the values matching a rich query. The query must only
+// match the keys of the repository.
+func (r *Repository[T]) Query(stub shim.ChaincodeStubInterface, query string) ([]T, error) {
+	iter, err := stub.GetQueryResult(query)
+	if err != nil {
+		return nil, err
+	}
+	return r.decodeAll(iter)
+}
+
+func (r *Repository[T]) decodeAll(iter shim.StateQueryIteratorInterface) ([]T, error) {
+	defer iter.Close()
+
+	var values []T
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		var value T
+		if err := r.codec.Unmarshal(kv.Value, &value); err != nil {
+			return nil, fmt.Errorf("failed to decode value of key %s: %s", kv.Key, err)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
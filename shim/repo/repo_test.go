@@ -0,0 +1,95 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.18
+// +build go1.18
+
+package repo_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/repo"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type asset struct {
+	Owner string `json:"owner"`
+	ID    string `json:"id"`
+	Size  int    `json:"size"`
+}
+
+func TestRepository(t *testing.T) {
+	tests := []struct {
+		name string
+		keys repo.KeyStrategy
+		id   func(asset) []string
+	}{
+		{"SimpleKeys", repo.SimpleKeys("asset:"), func(a asset) []string { return []string{a.Owner + ":" + a.ID} }},
+		{"CompositeKeys", repo.CompositeKeys("asset"), func(a asset) []string { return []string{a.Owner, a.ID} }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assets := repo.New[asset](tt.keys, repo.JSON[asset](), tt.id)
+			id := func(owner, id string) []string { return tt.id(asset{Owner: owner, ID: id}) }
+
+			stub := shimtest.NewMockStub("repo", nil)
+			stub.MockTransactionStart("put")
+			require.NoError(t, assets.Put(stub, asset{Owner: "alice", ID: "a1", Size: 1}))
+			require.NoError(t, assets.Put(stub, asset{Owner: "alice", ID: "a2", Size: 2}))
+			require.NoError(t, assets.Put(stub, asset{Owner: "bob", ID: "b1", Size: 3}))
+			stub.MockTransactionEnd("put")
+
+			stub.MockTransactionStart("get")
+			a, found, err := assets.Get(stub, id("alice", "a2")...)
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, asset{Owner: "alice", ID: "a2", Size: 2}, a)
+
+			_, found, err = assets.Get(stub, id("carol", "c1")...)
+			assert.NoError(t, err)
+			assert.False(t, found)
+
+			all, err := assets.List(stub)
+			assert.NoError(t, err)
+			assert.Len(t, all, 3)
+
+			owned, err := assets.List(stub, "alice")
+			assert.NoError(t, err)
+			assert.Equal(t, []asset{{Owner: "alice", ID: "a1", Size: 1}, {Owner: "alice", ID: "a2", Size: 2}}, owned)
+
+			require.NoError(t, assets.Delete(stub, id("alice", "a1")...))
+			stub.MockTransactionEnd("get")
+
+			owned, err = assets.List(stub, "alice")
+			assert.NoError(t, err)
+			assert.Equal(t, []asset{{Owner: "alice", ID: "a2", Size: 2}}, owned)
+		})
+	}
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	stub := shimtest.NewMockStub("repo", nil)
+
+	simple := repo.New[asset](repo.SimpleKeys("asset:"), repo.JSON[asset](), func(a asset) []string { return []string{a.Owner, a.ID} })
+	err := simple.Put(stub, asset{Owner: "alice", ID: "a1"})
+	assert.EqualError(t, err, "simple key ID must have a single attribute, got 2")
+
+	composite := repo.New[asset](repo.CompositeKeys("asset"), repo.JSON[asset](), func(a asset) []string { return nil })
+	err = composite.Put(stub, asset{})
+	assert.EqualError(t, err, "composite key ID of asset must have at least one attribute")
+
+	stub.MockTransactionStart("corrupt")
+	require.NoError(t, stub.PutState("asset:bad", []byte("not json")))
+	stub.MockTransactionEnd("corrupt")
+	byID := repo.New[asset](repo.SimpleKeys("asset:"), repo.JSON[asset](), func(a asset) []string { return []string{a.ID} })
+	_, _, err = byID.Get(stub, "bad")
+	assert.Contains(t, err.Error(), "failed to decode value of key asset:bad")
+	_, err = byID.List(stub)
+	assert.Contains(t, err.Error(), "failed to decode value of key asset:bad")
+
+	_, err = byID.Query(stub, `{"selector":{}}`)
+	assert.EqualError(t, err, "not implemented")
+}
@@ -0,0 +1,97 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// RetryPolicy configures the automatic retry of idempotent requests to the
+// peer which fail with a transient error. Only reads which do not advance
+// the state of an iterator are retried: GET_STATE, GET_PRIVATE_DATA_HASH,
+// GET_STATE_METADATA, GET_STATE_BY_RANGE, GET_QUERY_RESULT and
+// GET_HISTORY_FOR_KEY.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent,
+	// including the first attempt.
+	MaxAttempts int
+	// InitialBackoff is the delay before the first retry. The delay doubles
+	// after every retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between retries; zero means no cap.
+	MaxBackoff time.Duration
+	// Retryable reports whether an error returned by the peer is transient.
+	// If nil, IsTransientPeerError is used.
+	Retryable func(errMsg string) bool
+}
+
+// WithRetryPolicy enables the automatic retry of idempotent requests which
+// fail with a transient error.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(h *Handler) error {
+		if policy.MaxAttempts < 1 {
+			return errors.New("retry policy max attempts must be at least 1")
+		}
+		if policy.InitialBackoff < 0 || policy.MaxBackoff < 0 {
+			return errors.New("retry policy backoff must not be negative")
+		}
+		h.retryPolicy = &policy
+		return nil
+	}
+}
+
+// IsTransientPeerError reports whether the error message returned by the
+// peer describes a condition which is likely to clear up on its own, such
+// as an unavailable or overloaded database.
+func IsTransientPeerError(errMsg string) bool {
+	errMsg = strings.ToLower(errMsg)
+	for _, s := range []string{"unavailable", "deadline exceeded", "timed out", "timeout", "too many requests"} {
+		if strings.Contains(errMsg, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// idempotentRequests are the requests which can safely be sent again.
+var idempotentRequests = map[pb.ChaincodeMessage_Type]bool{
+	pb.ChaincodeMessage_GET_STATE:             true,
+	pb.ChaincodeMessage_GET_PRIVATE_DATA_HASH: true,
+	pb.ChaincodeMessage_GET_STATE_METADATA:    true,
+	pb.ChaincodeMessage_GET_STATE_BY_RANGE:    true,
+	pb.ChaincodeMessage_GET_QUERY_RESULT:      true,
+	pb.ChaincodeMessage_GET_HISTORY_FOR_KEY:   true,
+}
+
+// shouldRetry reports whether request should be sent again after attempt
+// attempts which ended with resp.
+func (p *RetryPolicy) shouldRetry(request, resp *pb.ChaincodeMessage, attempt int) bool {
+	if p == nil || attempt >= p.MaxAttempts || resp.Type != pb.ChaincodeMessage_ERROR || !idempotentRequests[request.Type] {
+		return false
+	}
+	retryable := p.Retryable
+	if retryable == nil {
+		retryable = IsTransientPeerError
+	}
+	return retryable(string(resp.Payload))
+}
+
+// backoff returns the delay before the retry following attempt.
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		d *= 2
+		if p.MaxBackoff > 0 && d >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,106 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+// newFlakyPeerHandler returns a handler whose peer fails the first failures
+// requests with errMsg and answers the following ones with value.
+func newFlakyPeerHandler(t *testing.T, failures int32, errMsg string, value []byte, opts ...Option) (*Handler, *int32) {
+	chatStream := &mock.PeerChaincodeStream{}
+	h, err := newChaincodeHandler(chatStream, &mockChaincode{}, opts...)
+	assert.NoError(t, err)
+	h.state = ready
+
+	var calls int32
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		resp := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_RESPONSE, ChannelId: msg.ChannelId, Txid: msg.Txid, Payload: value}
+		if atomic.AddInt32(&calls, 1) <= failures {
+			resp.Type = peerpb.ChaincodeMessage_ERROR
+			resp.Payload = []byte(errMsg)
+		}
+		go h.handleResponse(resp)
+		return nil
+	}
+	return h, &calls
+}
+
+func TestRetryPolicy(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+	logger := &recordingLogger{}
+
+	h, calls := newFlakyPeerHandler(t, 2, "service unavailable", []byte("value"), WithRetryPolicy(policy), WithLogger(logger))
+	value, err := h.handleGetState("", "key", "channel", "txid")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("value"), value)
+	assert.Equal(t, int32(3), *calls)
+	assert.Len(t, logger.lines, 2)
+	assert.Contains(t, logger.lines[0], "[txid] retrying GET_STATE after transient error (attempt 1 of 3): service unavailable")
+
+	// attempts are exhausted
+	h, calls = newFlakyPeerHandler(t, 3, "service unavailable", []byte("value"), WithRetryPolicy(policy), WithLogger(logger))
+	_, err = h.handleGetState("", "key", "channel", "txid")
+	assert.EqualError(t, err, "service unavailable")
+	assert.Equal(t, int32(3), *calls)
+
+	// errors which are not transient are not retried
+	h, calls = newFlakyPeerHandler(t, 1, "key not found", []byte("value"), WithRetryPolicy(policy), WithLogger(logger))
+	_, err = h.handleGetState("", "key", "channel", "txid")
+	assert.EqualError(t, err, "key not found")
+	assert.Equal(t, int32(1), *calls)
+
+	// writes are not retried
+	h, calls = newFlakyPeerHandler(t, 1, "service unavailable", nil, WithRetryPolicy(policy), WithLogger(logger))
+	err = h.handlePutState("", "key", []byte("value"), "channel", "txid")
+	assert.EqualError(t, err, "service unavailable")
+	assert.Equal(t, int32(1), *calls)
+
+	// no retry without a policy
+	h, calls = newFlakyPeerHandler(t, 1, "service unavailable", []byte("value"))
+	_, err = h.handleGetState("", "key", "channel", "txid")
+	assert.EqualError(t, err, "service unavailable")
+	assert.Equal(t, int32(1), *calls)
+
+	// custom classification
+	policy.Retryable = func(errMsg string) bool { return errMsg == "busy" }
+	h, calls = newFlakyPeerHandler(t, 1, "busy", marshalOrPanic(&peerpb.QueryResponse{}), WithRetryPolicy(policy), WithLogger(logger))
+	_, err = h.handleGetStateByRange("", "a", "b", nil, "channel", "txid")
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), *calls)
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := &RetryPolicy{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+	assert.Equal(t, 10*time.Millisecond, p.backoff(1))
+	assert.Equal(t, 20*time.Millisecond, p.backoff(2))
+	assert.Equal(t, 40*time.Millisecond, p.backoff(3))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(4))
+	assert.Equal(t, 50*time.Millisecond, p.backoff(40))
+
+	p.MaxBackoff = 0
+	assert.Equal(t, 80*time.Millisecond, p.backoff(4))
+}
+
+func TestWithRetryPolicyInvalid(t *testing.T) {
+	_, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithRetryPolicy(RetryPolicy{}))
+	assert.EqualError(t, err, "retry policy max attempts must be at least 1")
+
+	_, err = newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithRetryPolicy(RetryPolicy{MaxAttempts: 1, InitialBackoff: -1}))
+	assert.EqualError(t, err, "retry policy backoff must not be negative")
+}
+
+func TestIsTransientPeerError(t *testing.T) {
+	assert.True(t, IsTransientPeerError("couchdb: Service Unavailable"))
+	assert.True(t, IsTransientPeerError("context deadline exceeded"))
+	assert.True(t, IsTransientPeerError("request timed out"))
+	assert.False(t, IsTransientPeerError("invalid key"))
+}
@@ -0,0 +1,123 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// MessageSender is the sending side of the stream to the peer.
+type MessageSender interface {
+	Send(*pb.ChaincodeMessage) error
+}
+
+// MessageReceiver is the receiving side of the stream to the peer.
+type MessageReceiver interface {
+	Recv() (*pb.ChaincodeMessage, error)
+}
+
+// ResponseRouter delivers the RESPONSE and ERROR messages of the peer to
+// the requests of the chaincode waiting for them. Requests are identified
+// by their channel and transaction IDs; a transaction has at most one
+// request outstanding at a time.
+type ResponseRouter interface {
+	// Register returns the channel on which the response to the request of
+	// txid on channelID is delivered.
+	Register(channelID, txid string) (<-chan pb.ChaincodeMessage, error)
+	// Unregister releases the channel returned by Register.
+	Unregister(channelID, txid string)
+	// Route delivers msg to the request registered for its channel and
+	// transaction IDs.
+	Route(msg *pb.ChaincodeMessage) error
+}
+
+// NewResponseRouter returns the ResponseRouter used by default.
+func NewResponseRouter() ResponseRouter {
+	return &responseRouter{channels: map[string]chan pb.ChaincodeMessage{}}
+}
+
+// WithResponseRouter replaces the router delivering the responses of the
+// peer, for example to observe the requests of the chaincode in tests of a
+// custom launcher.
+func WithResponseRouter(router ResponseRouter) Option {
+	return func(h *Handler) error {
+		if router == nil {
+			return errors.New("response router must not be nil")
+		}
+		h.router = router
+		return nil
+	}
+}
+
+type responseRouter struct {
+	// Multiple queries (and one transaction) with different txids can be
+	// executing in parallel for this chaincode. channels holds the channels
+	// on which responses are communicated to the chaincode stubs; the mutex
+	// protects it from concurrent requests to the peer.
+	mutex    sync.Mutex
+	channels map[string]chan pb.ChaincodeMessage
+}
+
+func (r *responseRouter) Register(channelID, txid string) (<-chan pb.ChaincodeMessage, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.channels == nil {
+		return nil, fmt.Errorf("[%s] cannot create response channel", shorttxid(txid))
+	}
+
+	txCtxID := transactionContextID(channelID, txid)
+	if r.channels[txCtxID] != nil {
+		return nil, fmt.Errorf("[%s] channel exists", shorttxid(txCtxID))
+	}
+
+	responseChan := make(chan pb.ChaincodeMessage)
+	r.channels[txCtxID] = responseChan
+	return responseChan, nil
+}
+
+func (r *responseRouter) Unregister(channelID, txid string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.channels != nil {
+		txCtxID := transactionContextID(channelID, txid)
+		delete(r.channels, txCtxID)
+	}
+}
+
+func (r *responseRouter) Route(msg *pb.ChaincodeMessage) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.channels == nil {
+		return fmt.Errorf("[%s] Cannot send message response channel", shorttxid(msg.Txid))
+	}
+
+	txCtxID := transactionContextID(msg.ChannelId, msg.Txid)
+	responseCh := r.channels[txCtxID]
+	if responseCh == nil {
+		return fmt.Errorf("[%s] responseChannel does not exist", shorttxid(msg.Txid))
+	}
+	responseCh <- *msg
+	return nil
+}
+
+// inFlight returns the sorted transaction context IDs waiting for a
+// response.
+func (r *responseRouter) inFlight() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	ids := []string{}
+	for id := range r.channels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
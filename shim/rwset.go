@@ -0,0 +1,185 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+	"sort"
+	"sync"
+)
+
+// RWSetTracker accumulates the reads and writes made by a transaction and
+// computes a digest of them. The zero value is ready to use and a
+// RWSetTracker is safe for concurrent use.
+//
+// The digest is computed from the calls made by the chaincode, not from the
+// read/write set built by the peer, so it does not include key versions.
+// Only the first read of a key, the last write of a key and the last write
+// of a metadata entry are retained; the digest does not depend on the order
+// of the calls. The values are retained as their SHA-256 hashes, so the
+// tracker does not keep the values read and written by the transaction in
+// memory.
+type RWSetTracker struct {
+	mutex          sync.Mutex
+	reads          map[rwsetKey]valueHash
+	writes         map[rwsetKey]rwsetWrite
+	metadataWrites map[rwsetKey]valueHash
+	rangeQueries   map[rwsetKey]struct{}
+	// keepValues retains a copy of the values written, for the offchain
+	// sink.
+	keepValues bool
+}
+
+// valueHash is the SHA-256 hash of a value.
+type valueHash [sha256.Size]byte
+
+// rwsetKey identifies an entry of the read/write set. For range queries,
+// key and name hold the start and end keys.
+type rwsetKey struct {
+	collection string
+	key        string
+	name       string
+}
+
+type rwsetWrite struct {
+	hash valueHash
+	// value is a copy of the value written if the tracker keeps values.
+	value    []byte
+	isDelete bool
+}
+
+// Read records that the value of key in collection was read.
+func (t *RWSetTracker) Read(collection, key string, value []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.reads == nil {
+		t.reads = map[rwsetKey]valueHash{}
+	}
+	k := rwsetKey{collection: collection, key: key}
+	if _, ok := t.reads[k]; !ok {
+		t.reads[k] = sha256.Sum256(value)
+	}
+}
+
+// Write records that key in collection was set to value, or deleted.
+func (t *RWSetTracker) Write(collection, key string, value []byte, isDelete bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.writes == nil {
+		t.writes = map[rwsetKey]rwsetWrite{}
+	}
+	w := rwsetWrite{hash: sha256.Sum256(value), isDelete: isDelete}
+	if t.keepValues && value != nil {
+		w.value = append([]byte(nil), value...)
+	}
+	t.writes[rwsetKey{collection: collection, key: key}] = w
+}
+
+// MetadataWrite records that the metadata entry name of key in collection
+// was set to value.
+func (t *RWSetTracker) MetadataWrite(collection, key, name string, value []byte) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.metadataWrites == nil {
+		t.metadataWrites = map[rwsetKey]valueHash{}
+	}
+	t.metadataWrites[rwsetKey{collection: collection, key: key, name: name}] = sha256.Sum256(value)
+}
+
+// RangeQuery records that the keys of collection between startKey and
+// endKey were queried.
+func (t *RWSetTracker) RangeQuery(collection, startKey, endKey string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.rangeQueries == nil {
+		t.rangeQueries = map[rwsetKey]struct{}{}
+	}
+	t.rangeQueries[rwsetKey{collection: collection, key: startKey, name: endKey}] = struct{}{}
+}
+
+// eachWrite calls fn with the last write of each key, in collection and key
+// order. The values are nil unless the tracker keeps values.
+func (t *RWSetTracker) eachWrite(fn func(collection, key string, value []byte, isDelete bool)) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, k := range sortedKeys(t.writes) {
+		w := t.writes[k]
+		fn(k.collection, k.key, w.value, w.isDelete)
+	}
+}
+
+// Digest returns the SHA-256 digest of the reads and writes recorded so far.
+func (t *RWSetTracker) Digest() []byte {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	h := sha256.New()
+	for _, k := range sortedKeys(t.reads) {
+		hash := t.reads[k]
+		writeField(h, []byte("read"), []byte(k.collection), []byte(k.key), hash[:])
+	}
+	for _, k := range sortedKeys(t.rangeQueries) {
+		writeField(h, []byte("range"), []byte(k.collection), []byte(k.key), []byte(k.name))
+	}
+	for _, k := range sortedKeys(t.writes) {
+		w := t.writes[k]
+		if w.isDelete {
+			writeField(h, []byte("delete"), []byte(k.collection), []byte(k.key))
+			continue
+		}
+		writeField(h, []byte("write"), []byte(k.collection), []byte(k.key), w.hash[:])
+	}
+	for _, k := range sortedKeys(t.metadataWrites) {
+		hash := t.metadataWrites[k]
+		writeField(h, []byte("metadata"), []byte(k.collection), []byte(k.key), []byte(k.name), hash[:])
+	}
+	return h.Sum(nil)
+}
+
+// writeField writes each field to h prefixed with its length.
+func writeField(h hash.Hash, fields ...[]byte) {
+	var size [8]byte
+	for _, f := range fields {
+		binary.BigEndian.PutUint64(size[:], uint64(len(f)))
+		h.Write(size[:])
+		h.Write(f)
+	}
+}
+
+// sortedKeys returns the keys of m, which must be a map keyed by rwsetKey,
+// in collection, key and name order.
+func sortedKeys(m interface{}) []rwsetKey {
+	var keys []rwsetKey
+	switch m := m.(type) {
+	case map[rwsetKey]valueHash:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[rwsetKey]rwsetWrite:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	case map[rwsetKey]struct{}:
+		for k := range m {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].collection != keys[j].collection {
+			return keys[i].collection < keys[j].collection
+		}
+		if keys[i].key != keys[j].key {
+			return keys[i].key < keys[j].key
+		}
+		return keys[i].name < keys[j].name
+	})
+	return keys
+}
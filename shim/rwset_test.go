@@ -0,0 +1,90 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRWSetTrackerDigest(t *testing.T) {
+	empty := (&RWSetTracker{}).Digest()
+	assert.Len(t, empty, 32)
+
+	t1 := &RWSetTracker{}
+	t1.Read("", "a", []byte("1"))
+	t1.Read("", "a", []byte("2"))
+	t1.Write("", "b", []byte("1"), false)
+	t1.Write("", "b", []byte("2"), false)
+	t1.RangeQuery("", "a", "z")
+	t1.MetadataWrite("col", "c", "name", []byte("v"))
+
+	// same calls in a different order, keeping the first read and last write
+	t2 := &RWSetTracker{}
+	t2.MetadataWrite("col", "c", "name", []byte("v"))
+	t2.RangeQuery("", "a", "z")
+	t2.Write("", "b", []byte("2"), false)
+	t2.Read("", "a", []byte("1"))
+	assert.Equal(t, t1.Digest(), t2.Digest())
+	assert.NotEqual(t, empty, t1.Digest())
+
+	var tests = []struct {
+		name   string
+		modify func(*RWSetTracker)
+	}{
+		{name: "Read", modify: func(t *RWSetTracker) { t.Read("col", "a", []byte("1")) }},
+		{name: "Write", modify: func(t *RWSetTracker) { t.Write("", "b", []byte("3"), false) }},
+		{name: "Delete", modify: func(t *RWSetTracker) { t.Write("", "b", nil, true) }},
+		{name: "RangeQuery", modify: func(t *RWSetTracker) { t.RangeQuery("", "a", "") }},
+		{name: "MetadataWrite", modify: func(t *RWSetTracker) { t.MetadataWrite("col", "c", "name", nil) }},
+	}
+	for _, test := range tests {
+		tracker := &RWSetTracker{}
+		tracker.Read("", "a", []byte("1"))
+		tracker.Write("", "b", []byte("2"), false)
+		tracker.RangeQuery("", "a", "z")
+		tracker.MetadataWrite("col", "c", "name", []byte("v"))
+		test.modify(tracker)
+		assert.NotEqual(t, t1.Digest(), tracker.Digest(), test.name)
+	}
+
+	// field boundaries are unambiguous
+	t3 := &RWSetTracker{}
+	t3.Write("", "ab", []byte("c"), false)
+	t4 := &RWSetTracker{}
+	t4.Write("", "a", []byte("bc"), false)
+	assert.NotEqual(t, t3.Digest(), t4.Digest())
+}
+
+func TestRWSetTrackerValues(t *testing.T) {
+	value := []byte("value")
+	var values [][]byte
+	collect := func(collection, key string, value []byte, isDelete bool) { values = append(values, value) }
+
+	tracker := &RWSetTracker{}
+	tracker.Read("", "a", value)
+	tracker.Write("", "b", value, false)
+	tracker.eachWrite(collect)
+	assert.Equal(t, [][]byte{nil}, values, "values are only retained as hashes")
+
+	values = nil
+	tracker = &RWSetTracker{keepValues: true}
+	tracker.Write("", "b", value, false)
+	value[0] = 'V'
+	tracker.eachWrite(collect)
+	assert.Equal(t, [][]byte{[]byte("value")}, values, "values are copied")
+}
+
+func TestNewChaincodeStubKeepsValues(t *testing.T) {
+	stub, err := newChaincodeStub(&Handler{}, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+	assert.NoError(t, err)
+	assert.False(t, stub.rwset.keepValues)
+
+	h := &Handler{offchain: &offchainDispatcher{}}
+	stub, err = newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+	assert.NoError(t, err)
+	assert.True(t, stub.rwset.keepValues)
+}
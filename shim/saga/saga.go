@@ -0,0 +1,141 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package saga coordinates workflows spanning several chaincodes by
+// recording, for each completed step, the invocation that undoes it. When a
+// later step fails, the saga emits a chaincode event listing those
+// compensating invocations, in reverse order, for an off-chain coordinator
+// to submit.
+//
+// Within a single transaction the writes of chaincodes invoked on the same
+// channel are atomic: returning an error response discards all of them and
+// no compensation is needed. Sagas are for steps whose effects are committed
+// independently, such as steps executed in earlier transactions or on other
+// channels. To get the compensation event committed, Abort and Fail return a
+// successful response, so writes made by the steps of the current
+// transaction are committed too and should be compensated like any other.
+//
+//	s := saga.New(stub, orderID)
+//	if _, ok := s.Invoke("reserve", reserve, &release); !ok {
+//		return s.Abort()
+//	}
+//	if _, ok := s.Invoke("charge", charge, &refund); !ok {
+//		return s.Abort()
+//	}
+//	return shim.Success(nil)
+package saga
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// EventName is the name of the compensation event.
+const EventName = "saga.compensate"
+
+// Invocation describes a chaincode invocation.
+type Invocation struct {
+	Chaincode string   `json:"chaincode"`
+	Channel   string   `json:"channel,omitempty"`
+	Args      [][]byte `json:"args"`
+}
+
+// Compensation is a compensating invocation and the step it undoes.
+type Compensation struct {
+	Step       string     `json:"step"`
+	Invocation Invocation `json:"invocation"`
+}
+
+// CompensationEvent is the JSON payload of the compensation event.
+type CompensationEvent struct {
+	Saga       string `json:"saga"`
+	TxID       string `json:"tx_id"`
+	FailedStep string `json:"failed_step"`
+	Reason     string `json:"reason"`
+	// Compensations lists the compensating invocations in the order they
+	// are to be submitted, which is the reverse of the order of the steps.
+	Compensations []Compensation `json:"compensations"`
+}
+
+// Saga records the compensations of the steps of a workflow.
+type Saga struct {
+	stub          shim.ChaincodeStubInterface
+	id            string
+	compensations []Compensation
+	failedStep    string
+	reason        string
+}
+
+// New returns a saga identified by id.
+func New(stub shim.ChaincodeStubInterface, id string) *Saga {
+	return &Saga{stub: stub, id: id}
+}
+
+// AddCompensation records the compensation of a step completed outside of
+// Invoke, for example in an earlier transaction.
+func (s *Saga) AddCompensation(step string, compensation Invocation) {
+	s.compensations = append(s.compensations, Compensation{Step: step, Invocation: compensation})
+}
+
+// Invoke executes step by invoking target. If the invocation succeeds,
+// compensation, if not nil, is recorded and true is returned. Otherwise the
+// saga is marked as failed at step and false is returned. Invoke does nothing
+// and returns false once the saga has failed.
+func (s *Saga) Invoke(step string, target Invocation, compensation *Invocation) (pb.Response, bool) {
+	if s.Failed() {
+		return shim.Error(fmt.Sprintf("saga %s already failed at step %s", s.id, s.failedStep)), false
+	}
+	resp := s.stub.InvokeChaincode(target.Chaincode, target.Args, target.Channel)
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		s.failedStep = step
+		s.reason = resp.Message
+		return resp, false
+	}
+	if compensation != nil {
+		s.AddCompensation(step, *compensation)
+	}
+	return resp, true
+}
+
+// Failed reports whether a step of the saga has failed.
+func (s *Saga) Failed() bool {
+	return s.failedStep != ""
+}
+
+// Fail marks the saga as failed at step for reason and aborts it.
+func (s *Saga) Fail(step, reason string) pb.Response {
+	s.failedStep = step
+	s.reason = reason
+	return s.Abort()
+}
+
+// Abort sets the compensation event of the saga and returns a successful
+// response whose payload is the event payload and whose message describes
+// the failure.
+func (s *Saga) Abort() pb.Response {
+	event := &CompensationEvent{
+		Saga:          s.id,
+		TxID:          s.stub.GetTxID(),
+		FailedStep:    s.failedStep,
+		Reason:        s.reason,
+		Compensations: make([]Compensation, 0, len(s.compensations)),
+	}
+	for i := len(s.compensations) - 1; i >= 0; i-- {
+		event.Compensations = append(event.Compensations, s.compensations[i])
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return shim.Error(fmt.Sprintf("failed to marshal compensation event of saga %s: %s", s.id, err))
+	}
+	if err := s.stub.SetEvent(EventName, payload); err != nil {
+		return shim.Error(fmt.Sprintf("failed to set compensation event of saga %s: %s", s.id, err))
+	}
+	return pb.Response{
+		Status:  shim.OK,
+		Message: fmt.Sprintf("saga %s failed at step %s: %s", s.id, s.failedStep, s.reason),
+		Payload: payload,
+	}
+}
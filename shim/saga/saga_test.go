@@ -0,0 +1,111 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package saga_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/saga"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// stepChaincode fails when invoked with "fail".
+type stepChaincode struct{}
+
+func (stepChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response { return shim.Success(nil) }
+
+func (stepChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	fn, _ := stub.GetFunctionAndParameters()
+	if fn == "fail" {
+		return shim.Error("insufficient funds")
+	}
+	return shim.Success([]byte(fn))
+}
+
+func newStub() *shimtest.MockStub {
+	stub := shimtest.NewMockStub("orchestrator", nil)
+	stub.MockPeerChaincode("inventory", shimtest.NewMockStub("inventory", stepChaincode{}), "")
+	stub.MockPeerChaincode("payment", shimtest.NewMockStub("payment", stepChaincode{}), "")
+	return stub
+}
+
+func invocation(chaincode, fn string) saga.Invocation {
+	return saga.Invocation{Chaincode: chaincode, Args: [][]byte{[]byte(fn)}}
+}
+
+func TestSagaSuccess(t *testing.T) {
+	stub := newStub()
+	stub.MockTransactionStart("tx1")
+	s := saga.New(stub, "order1")
+
+	resp, ok := s.Invoke("reserve", invocation("inventory", "reserve"), &saga.Invocation{Chaincode: "inventory"})
+	assert.True(t, ok)
+	assert.Equal(t, []byte("reserve"), resp.Payload)
+	_, ok = s.Invoke("charge", invocation("payment", "charge"), nil)
+	assert.True(t, ok)
+	assert.False(t, s.Failed())
+	stub.MockTransactionEnd("tx1")
+
+	assert.Empty(t, stub.ChaincodeEventsForTx("tx1"))
+}
+
+func TestSagaAbort(t *testing.T) {
+	stub := newStub()
+	stub.MockTransactionStart("tx1")
+	s := saga.New(stub, "order1")
+	s.AddCompensation("quote", invocation("inventory", "unquote"))
+
+	_, ok := s.Invoke("reserve", invocation("inventory", "reserve"), &saga.Invocation{Chaincode: "inventory", Args: [][]byte{[]byte("release")}})
+	require.True(t, ok)
+	resp, ok := s.Invoke("charge", invocation("payment", "fail"), &saga.Invocation{Chaincode: "payment", Args: [][]byte{[]byte("refund")}})
+	assert.False(t, ok)
+	assert.Equal(t, "insufficient funds", resp.Message)
+	assert.True(t, s.Failed())
+
+	resp, ok = s.Invoke("ship", invocation("inventory", "ship"), nil)
+	assert.False(t, ok)
+	assert.Equal(t, "saga order1 already failed at step charge", resp.Message)
+
+	resp = s.Abort()
+	stub.MockTransactionEnd("tx1")
+
+	assert.Equal(t, int32(shim.OK), resp.Status)
+	assert.Equal(t, "saga order1 failed at step charge: insufficient funds", resp.Message)
+
+	events := stub.ChaincodeEventsForTx("tx1")
+	require.Len(t, events, 1)
+	assert.Equal(t, saga.EventName, events[0].EventName)
+	assert.Equal(t, resp.Payload, events[0].Payload)
+
+	event := &saga.CompensationEvent{}
+	require.NoError(t, json.Unmarshal(events[0].Payload, event))
+	assert.Equal(t, &saga.CompensationEvent{
+		Saga:       "order1",
+		TxID:       "tx1",
+		FailedStep: "charge",
+		Reason:     "insufficient funds",
+		Compensations: []saga.Compensation{
+			{Step: "reserve", Invocation: saga.Invocation{Chaincode: "inventory", Args: [][]byte{[]byte("release")}}},
+			{Step: "quote", Invocation: invocation("inventory", "unquote")},
+		},
+	}, event)
+}
+
+func TestSagaFail(t *testing.T) {
+	stub := newStub()
+	stub.MockTransactionStart("tx1")
+	s := saga.New(stub, "order1")
+	resp := s.Fail("validate", "invalid address")
+	stub.MockTransactionEnd("tx1")
+
+	assert.Equal(t, "saga order1 failed at step validate: invalid address", resp.Message)
+	event := &saga.CompensationEvent{}
+	require.NoError(t, json.Unmarshal(resp.Payload, event))
+	assert.Empty(t, event.Compensations)
+}
@@ -0,0 +1,279 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Environment variables read by SchedulerConfigFromEnv.
+const (
+	// SchedulerWorkersEnv sets SchedulerConfig.Workers. The scheduler is
+	// enabled by Start when it is set.
+	SchedulerWorkersEnv = "CORE_CHAINCODE_SCHEDULER_WORKERS"
+	// SchedulerQuotasEnv sets SchedulerConfig.Quotas as a comma separated
+	// list of channel=quota pairs. The quota of channel "*" sets
+	// SchedulerConfig.DefaultQuota.
+	SchedulerQuotasEnv = "CORE_CHAINCODE_SCHEDULER_QUOTAS"
+	// SchedulerWeightsEnv sets SchedulerConfig.Weights as a comma separated
+	// list of channel=weight pairs.
+	SchedulerWeightsEnv = "CORE_CHAINCODE_SCHEDULER_WEIGHTS"
+)
+
+// SchedulerConfig configures the scheduling of transactions across
+// channels.
+//
+// At most Workers transactions are executed at once. When transactions are
+// waiting, the free workers are shared between the channels with waiting
+// transactions in proportion to their weights, so a busy channel cannot
+// starve the others. A channel never executes more transactions at once
+// than its quota.
+//
+// A transaction calling InvokeChaincode on a chaincode served by the same
+// process holds its worker while the called transaction waits for one:
+// Workers and the quotas must exceed the depth of such calls.
+type SchedulerConfig struct {
+	// Workers is the maximum number of transactions executed at once
+	// across all channels.
+	Workers int
+	// Quotas is the maximum number of transactions executed at once for
+	// each channel. Channels not listed use DefaultQuota.
+	Quotas map[string]int
+	// DefaultQuota is the quota of the channels not listed in Quotas; zero
+	// means Workers.
+	DefaultQuota int
+	// Weights is the share of the workers given to each channel relative
+	// to the other channels. Channels not listed have a weight of 1.
+	Weights map[string]int
+}
+
+// SchedulerConfigFromEnv returns the scheduler configuration set by the
+// SchedulerWorkersEnv, SchedulerQuotasEnv and SchedulerWeightsEnv
+// environment variables, or nil if SchedulerWorkersEnv is not set.
+func SchedulerConfigFromEnv() (*SchedulerConfig, error) {
+	workers, set := os.LookupEnv(SchedulerWorkersEnv)
+	if !set {
+		return nil, nil
+	}
+
+	config := &SchedulerConfig{}
+	var err error
+	if config.Workers, err = strconv.Atoi(workers); err != nil {
+		return nil, fmt.Errorf("invalid %s: %s", SchedulerWorkersEnv, err)
+	}
+	if config.Quotas, err = parseChannelValues(SchedulerQuotasEnv); err != nil {
+		return nil, err
+	}
+	if quota, ok := config.Quotas["*"]; ok {
+		config.DefaultQuota = quota
+		delete(config.Quotas, "*")
+	}
+	if config.Weights, err = parseChannelValues(SchedulerWeightsEnv); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// parseChannelValues parses the channel=value pairs of the environment
+// variable env.
+func parseChannelValues(env string) (map[string]int, error) {
+	values := map[string]int{}
+	for _, pair := range strings.Split(os.Getenv(env), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		i := strings.LastIndex(pair, "=")
+		if i <= 0 {
+			return nil, fmt.Errorf("invalid %s: %s is not a channel=value pair", env, pair)
+		}
+		value, err := strconv.Atoi(pair[i+1:])
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s: %s", env, err)
+		}
+		values[pair[:i]] = value
+	}
+	return values, nil
+}
+
+// WithChannelScheduler limits the number of transactions executed at once
+// and shares the execution between channels as configured by config. The
+// scheduler is shared by all the streams opened by Start.
+func WithChannelScheduler(config SchedulerConfig) Option {
+	s, err := newScheduler(config)
+	return func(h *Handler) error {
+		if err != nil {
+			return err
+		}
+		h.scheduler = s
+		return nil
+	}
+}
+
+// schedulerStride is the pass increment of a channel of weight 1.
+const schedulerStride = 1 << 20
+
+// scheduler executes tasks with stride scheduling between channels: each
+// channel has a pass advanced by its stride, inversely proportional to its
+// weight, whenever one of its tasks is started, and the next task started
+// is the oldest task of the waiting channel with the lowest pass.
+type scheduler struct {
+	mutex sync.Mutex
+	// config is guarded by the mutex since it can be changed by a
+	// configuration reload.
+	config   SchedulerConfig
+	running  int
+	vtime    uint64
+	channels map[string]*schedulerChannel
+}
+
+type schedulerChannel struct {
+	name    string
+	pending []func()
+	running int
+	pass    uint64
+}
+
+func newScheduler(config SchedulerConfig) (*scheduler, error) {
+	if config.Workers <= 0 {
+		return nil, fmt.Errorf("scheduler workers must be positive, got %d", config.Workers)
+	}
+	if config.DefaultQuota < 0 {
+		return nil, fmt.Errorf("scheduler default quota must not be negative, got %d", config.DefaultQuota)
+	}
+	for channel, quota := range config.Quotas {
+		if quota <= 0 {
+			return nil, fmt.Errorf("scheduler quota of channel %s must be positive, got %d", channel, quota)
+		}
+	}
+	for channel, weight := range config.Weights {
+		if weight <= 0 || weight > schedulerStride {
+			return nil, fmt.Errorf("scheduler weight of channel %s must be between 1 and %d, got %d", channel, schedulerStride, weight)
+		}
+	}
+	return &scheduler{config: config, channels: map[string]*schedulerChannel{}}, nil
+}
+
+func (s *scheduler) quota(channel string) int {
+	if quota, ok := s.config.Quotas[channel]; ok {
+		return quota
+	}
+	if s.config.DefaultQuota > 0 {
+		return s.config.DefaultQuota
+	}
+	return s.config.Workers
+}
+
+func (s *scheduler) stride(channel string) uint64 {
+	if weight, ok := s.config.Weights[channel]; ok {
+		return schedulerStride / uint64(weight)
+	}
+	return schedulerStride
+}
+
+// submit queues task for execution on behalf of channel.
+func (s *scheduler) submit(channel string, task func()) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	c, ok := s.channels[channel]
+	if !ok {
+		c = &schedulerChannel{name: channel}
+		s.channels[channel] = c
+	}
+	if len(c.pending) == 0 && c.pass < s.vtime {
+		// a channel that was idle does not get credit for the time it
+		// did not use
+		c.pass = s.vtime
+	}
+	c.pending = append(c.pending, task)
+	s.startLocked()
+}
+
+// done records the end of a task of channel and starts the tasks that can
+// now run.
+func (s *scheduler) done(channel string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.running--
+	c := s.channels[channel]
+	c.running--
+	if c.running == 0 && len(c.pending) == 0 {
+		delete(s.channels, channel)
+	}
+	s.startLocked()
+}
+
+// startLocked starts tasks while workers are available. The caller must hold
+// the mutex.
+func (s *scheduler) startLocked() {
+	for s.running < s.config.Workers {
+		c := s.nextLocked()
+		if c == nil {
+			return
+		}
+		task := c.pending[0]
+		c.pending[0] = nil
+		c.pending = c.pending[1:]
+		c.running++
+		s.running++
+		s.vtime = c.pass
+		c.pass += s.stride(c.name)
+
+		channel := c.name
+		go func() {
+			defer s.done(channel)
+			task()
+		}()
+	}
+}
+
+// nextLocked returns the channel whose task is started next, or nil if no
+// task can be started.
+func (s *scheduler) nextLocked() *schedulerChannel {
+	var eligible []*schedulerChannel
+	for _, c := range s.channels {
+		if len(c.pending) > 0 && c.running < s.quota(c.name) {
+			eligible = append(eligible, c)
+		}
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	sort.Slice(eligible, func(i, j int) bool {
+		if eligible[i].pass != eligible[j].pass {
+			return eligible[i].pass < eligible[j].pass
+		}
+		return eligible[i].name < eligible[j].name
+	})
+	return eligible[0]
+}
+
+// reconfigure replaces the configuration of s, which must have been
+// validated by newScheduler. Running tasks are not interrupted when the
+// workers or quotas are lowered; tasks are started again once the running
+// ones fit the new configuration.
+func (s *scheduler) reconfigure(config SchedulerConfig) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.config = config
+	s.startLocked()
+}
+
+// dispatch executes task on behalf of channel, through the scheduler if
+// one is configured.
+func (h *Handler) dispatch(channel string, task func()) {
+	if h.scheduler == nil {
+		go task()
+		return
+	}
+	h.scheduler.submit(channel, task)
+}
@@ -0,0 +1,121 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedulerWeights(t *testing.T) {
+	s, err := newScheduler(SchedulerConfig{Workers: 1, Weights: map[string]int{"a": 2}})
+	require.NoError(t, err)
+
+	// hold the only worker while the tasks are queued
+	release := make(chan struct{})
+	started := make(chan struct{})
+	s.submit("blocker", func() {
+		close(started)
+		<-release
+	})
+	<-started
+
+	var mutex sync.Mutex
+	var order []string
+	var wg sync.WaitGroup
+	submit := func(channel string, count int) {
+		for i := 0; i < count; i++ {
+			wg.Add(1)
+			s.submit(channel, func() {
+				mutex.Lock()
+				order = append(order, channel)
+				mutex.Unlock()
+				wg.Done()
+			})
+		}
+	}
+	submit("a", 6)
+	submit("b", 3)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, []string{"a", "b", "a", "a", "b", "a", "a", "b", "a"}, order)
+}
+
+func TestSchedulerQuotas(t *testing.T) {
+	s, err := newScheduler(SchedulerConfig{Workers: 4, Quotas: map[string]int{"busy": 1}})
+	require.NoError(t, err)
+
+	release := make(chan struct{})
+	started := make(chan string, 4)
+	for i := 0; i < 3; i++ {
+		s.submit("busy", func() {
+			started <- "busy"
+			<-release
+		})
+	}
+	s.submit("quiet", func() {
+		started <- "quiet"
+	})
+
+	// the quiet channel runs while the busy one is at its quota
+	assert.ElementsMatch(t, []string{"busy", "quiet"}, []string{<-started, <-started})
+	s.mutex.Lock()
+	assert.Len(t, s.channels["busy"].pending, 2)
+	s.mutex.Unlock()
+
+	close(release)
+	assert.Equal(t, "busy", <-started)
+	assert.Equal(t, "busy", <-started)
+}
+
+func TestNewSchedulerInvalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config SchedulerConfig
+		errMsg string
+	}{
+		{"NoWorkers", SchedulerConfig{}, "scheduler workers must be positive, got 0"},
+		{"NegativeDefaultQuota", SchedulerConfig{Workers: 1, DefaultQuota: -1}, "scheduler default quota must not be negative, got -1"},
+		{"ZeroQuota", SchedulerConfig{Workers: 1, Quotas: map[string]int{"ch": 0}}, "scheduler quota of channel ch must be positive, got 0"},
+		{"ZeroWeight", SchedulerConfig{Workers: 1, Weights: map[string]int{"ch": 0}}, "scheduler weight of channel ch must be between 1 and 1048576, got 0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithChannelScheduler(tt.config))
+			assert.EqualError(t, err, tt.errMsg)
+		})
+	}
+}
+
+func TestSchedulerConfigFromEnv(t *testing.T) {
+	config, err := SchedulerConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	os.Setenv(SchedulerWorkersEnv, "8")
+	defer os.Unsetenv(SchedulerWorkersEnv)
+	os.Setenv(SchedulerQuotasEnv, "mychannel=4, *=2")
+	defer os.Unsetenv(SchedulerQuotasEnv)
+	os.Setenv(SchedulerWeightsEnv, "mychannel=3")
+	defer os.Unsetenv(SchedulerWeightsEnv)
+
+	config, err = SchedulerConfigFromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, &SchedulerConfig{
+		Workers:      8,
+		Quotas:       map[string]int{"mychannel": 4},
+		DefaultQuota: 2,
+		Weights:      map[string]int{"mychannel": 3},
+	}, config)
+
+	os.Setenv(SchedulerWeightsEnv, "mychannel")
+	_, err = SchedulerConfigFromEnv()
+	assert.EqualError(t, err, "invalid CORE_CHAINCODE_SCHEDULER_WEIGHTS: mychannel is not a channel=value pair")
+}
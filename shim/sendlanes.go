@@ -0,0 +1,69 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"sync"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Messages are sent to the peer in one of two lanes. Control messages,
+// which answer keepalives and end transactions, are sent before any data
+// message waiting to be sent, so that they are not delayed behind the
+// requests of busy transactions until the peer times them out.
+const (
+	controlLane = iota
+	dataLane
+	laneCount
+)
+
+// sendLane returns the lane in which msg is sent.
+func sendLane(msg *pb.ChaincodeMessage) int {
+	switch msg.Type {
+	case pb.ChaincodeMessage_KEEPALIVE, pb.ChaincodeMessage_COMPLETED, pb.ChaincodeMessage_ERROR:
+		return controlLane
+	default:
+		return dataLane
+	}
+}
+
+// sendLanes serializes the calls to Send on a stream. Callers waiting for
+// the stream are let through in the order they arrived in their lane, the
+// control lane first. The zero value is ready to use.
+type sendLanes struct {
+	mutex   sync.Mutex
+	busy    bool
+	waiting [laneCount][]chan struct{}
+}
+
+// acquire waits for the stream to be free for a message of lane.
+func (l *sendLanes) acquire(lane int) {
+	l.mutex.Lock()
+	if !l.busy {
+		l.busy = true
+		l.mutex.Unlock()
+		return
+	}
+	ready := make(chan struct{})
+	l.waiting[lane] = append(l.waiting[lane], ready)
+	l.mutex.Unlock()
+
+	<-ready
+}
+
+// release hands the stream over to the next caller waiting for it.
+func (l *sendLanes) release() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for lane := range l.waiting {
+		if waiting := l.waiting[lane]; len(waiting) > 0 {
+			l.waiting[lane] = waiting[1:]
+			close(waiting[0])
+			return
+		}
+	}
+	l.busy = false
+}
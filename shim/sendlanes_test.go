@@ -0,0 +1,90 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendLane(t *testing.T) {
+	var tests = []struct {
+		msgType peerpb.ChaincodeMessage_Type
+		lane    int
+	}{
+		{msgType: peerpb.ChaincodeMessage_KEEPALIVE, lane: controlLane},
+		{msgType: peerpb.ChaincodeMessage_COMPLETED, lane: controlLane},
+		{msgType: peerpb.ChaincodeMessage_ERROR, lane: controlLane},
+		{msgType: peerpb.ChaincodeMessage_GET_STATE, lane: dataLane},
+		{msgType: peerpb.ChaincodeMessage_QUERY_STATE_NEXT, lane: dataLane},
+		{msgType: peerpb.ChaincodeMessage_REGISTER, lane: dataLane},
+	}
+
+	for _, test := range tests {
+		t.Run(test.msgType.String(), func(t *testing.T) {
+			assert.Equal(t, test.lane, sendLane(&peerpb.ChaincodeMessage{Type: test.msgType}))
+		})
+	}
+}
+
+func TestSerialSendPriority(t *testing.T) {
+	unblock := make(chan struct{})
+	var mutex sync.Mutex
+	var sent []string
+	chatStream := &mock.PeerChaincodeStream{}
+	chatStream.SendStub = func(msg *peerpb.ChaincodeMessage) error {
+		if msg.Txid == "blocking" {
+			<-unblock
+		}
+		mutex.Lock()
+		sent = append(sent, msg.Txid)
+		mutex.Unlock()
+		return nil
+	}
+	h := &Handler{chatStream: chatStream}
+
+	// queued reports whether the stream is busy with n senders waiting
+	// for it
+	queued := func(n int) bool {
+		h.sendLanes.mutex.Lock()
+		defer h.sendLanes.mutex.Unlock()
+		return h.sendLanes.busy && len(h.sendLanes.waiting[controlLane])+len(h.sendLanes.waiting[dataLane]) == n
+	}
+	errc := make(chan error, 6)
+	send := func(msgType peerpb.ChaincodeMessage_Type, txid string) {
+		h.serialSendAsync(&peerpb.ChaincodeMessage{Type: msgType, Txid: txid}, errc)
+	}
+	send(peerpb.ChaincodeMessage_GET_STATE, "blocking")
+	for !queued(0) {
+		time.Sleep(time.Millisecond)
+	}
+	send(peerpb.ChaincodeMessage_QUERY_STATE_NEXT, "data1")
+	for !queued(1) {
+		time.Sleep(time.Millisecond)
+	}
+	send(peerpb.ChaincodeMessage_QUERY_STATE_NEXT, "data2")
+	for !queued(2) {
+		time.Sleep(time.Millisecond)
+	}
+	send(peerpb.ChaincodeMessage_KEEPALIVE, "keepalive")
+	for !queued(3) {
+		time.Sleep(time.Millisecond)
+	}
+	send(peerpb.ChaincodeMessage_COMPLETED, "completed")
+	for !queued(4) {
+		time.Sleep(time.Millisecond)
+	}
+
+	close(unblock)
+	for i := 0; i < 5; i++ {
+		assert.NoError(t, <-errc)
+	}
+	assert.Equal(t, []string{"blocking", "keepalive", "completed", "data1", "data2"}, sent)
+	assert.False(t, queued(0), "the stream is free once every message is sent")
+}
@@ -0,0 +1,101 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.23
+// +build go1.23
+
+package shim
+
+import (
+	"iter"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+// StateSeq returns a sequence over the results of it for use with a range
+// loop. If err is not nil, the sequence yields err alone. A result which
+// cannot be read is yielded as an error and ends the sequence. The
+// iterator is closed when the loop ends, and an error closing it is yielded
+// if the loop ran to completion. The sequence can only be ranged over once.
+//
+// StateSeq accepts the results of the query methods of the stub directly:
+//
+//	for kv, err := range shim.StateSeq(stub.GetStateByRange(startKey, endKey)) {
+//		if err != nil {
+//			return shim.Error(err.Error())
+//		}
+//		...
+//	}
+func StateSeq(it StateQueryIteratorInterface, err error) iter.Seq2[*queryresult.KV, error] {
+	return func(yield func(*queryresult.KV, error) bool) {
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for it.HasNext() {
+			kv, err := it.Next()
+			if err != nil {
+				it.Close()
+				yield(nil, err)
+				return
+			}
+			if !yield(kv, nil) {
+				it.Close()
+				return
+			}
+		}
+		if err := it.Close(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// HistorySeq is like StateSeq for the results of a history query.
+func HistorySeq(it HistoryQueryIteratorInterface, err error) iter.Seq2[*queryresult.KeyModification, error] {
+	return func(yield func(*queryresult.KeyModification, error) bool) {
+		if err != nil {
+			yield(nil, err)
+			return
+		}
+		for it.HasNext() {
+			km, err := it.Next()
+			if err != nil {
+				it.Close()
+				yield(nil, err)
+				return
+			}
+			if !yield(km, nil) {
+				it.Close()
+				return
+			}
+		}
+		if err := it.Close(); err != nil {
+			yield(nil, err)
+		}
+	}
+}
+
+// States returns a sequence over the keys in the range [startKey, endKey).
+// See GetStateByRange and StateSeq.
+func States(stub ChaincodeStubInterface, startKey, endKey string) iter.Seq2[*queryresult.KV, error] {
+	return StateSeq(stub.GetStateByRange(startKey, endKey))
+}
+
+// StatesByPartialCompositeKey returns a sequence over the keys matching the
+// given partial composite key. See GetStateByPartialCompositeKey and
+// StateSeq.
+func StatesByPartialCompositeKey(stub ChaincodeStubInterface, objectType string, keys []string) iter.Seq2[*queryresult.KV, error] {
+	return StateSeq(stub.GetStateByPartialCompositeKey(objectType, keys))
+}
+
+// QueryResults returns a sequence over the results of a rich query. See
+// GetQueryResult and StateSeq.
+func QueryResults(stub ChaincodeStubInterface, query string) iter.Seq2[*queryresult.KV, error] {
+	return StateSeq(stub.GetQueryResult(query))
+}
+
+// History returns a sequence over the history of key. See GetHistoryForKey
+// and HistorySeq.
+func History(stub ChaincodeStubInterface, key string) iter.Seq2[*queryresult.KeyModification, error] {
+	return HistorySeq(stub.GetHistoryForKey(key))
+}
@@ -0,0 +1,80 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+//go:build go1.23
+// +build go1.23
+
+package shim
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/assert"
+)
+
+type failingStateIterator struct {
+	sliceStateIterator
+}
+
+func (i *failingStateIterator) HasNext() bool { return true }
+func (i *failingStateIterator) Next() (*queryresult.KV, error) {
+	if len(i.kvs) == 0 {
+		return nil, errors.New("failed to read result")
+	}
+	return i.sliceStateIterator.Next()
+}
+
+func TestStateSeq(t *testing.T) {
+	var keys []string
+	for kv, err := range States(&queryStub{}, "a", "z") {
+		assert.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, keys)
+
+	// breaking out of the loop closes the iterator
+	it := &sliceStateIterator{kvs: []*queryresult.KV{{Key: "a"}, {Key: "b"}}}
+	for range StateSeq(it, nil) {
+		break
+	}
+	assert.True(t, it.closed)
+
+	var errs []error
+	for kv, err := range StateSeq(nil, errors.New("query failed")) {
+		assert.Nil(t, kv)
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{errors.New("query failed")}, errs)
+
+	failing := &failingStateIterator{sliceStateIterator{kvs: []*queryresult.KV{{Key: "a"}}}}
+	keys, errs = nil, nil
+	for kv, err := range StateSeq(failing, nil) {
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		keys = append(keys, kv.Key)
+	}
+	assert.Equal(t, []string{"a"}, keys)
+	assert.Equal(t, []error{errors.New("failed to read result")}, errs)
+	assert.True(t, failing.closed)
+}
+
+func TestHistorySeq(t *testing.T) {
+	it := &sliceHistoryIterator{results: []*queryresult.KeyModification{{TxId: "tx1"}, {TxId: "tx2"}}}
+	var txids []string
+	for km, err := range HistorySeq(it, nil) {
+		assert.NoError(t, err)
+		txids = append(txids, km.TxId)
+	}
+	assert.Equal(t, []string{"tx1", "tx2"}, txids)
+
+	it = &sliceHistoryIterator{results: []*queryresult.KeyModification{{TxId: "tx1"}}, err: errors.New("failed to read result")}
+	var errs []error
+	for _, err := range HistorySeq(it, nil) {
+		errs = append(errs, err)
+	}
+	assert.Equal(t, []error{errors.New("failed to read result")}, errs)
+}
@@ -25,7 +25,8 @@ const (
 	emptyKeySubstitute    = "\x01"
 )
 
-var peerAddress = flag.String("peer.address", "", "peer address")
+var peerAddress = flag.String("peer.address", "", "peer address, or comma separated peer addresses to fail over between")
+var serverNameOverride = flag.String("tls.serverNameOverride", "", "name verified in the TLS certificate of the peer in place of its address; overrides "+internal.ServerNameOverrideEnv)
 
 //this separates the chaincode stream interface establishment
 //so we can replace it with a mock peer stream
@@ -35,57 +36,222 @@ type peerStreamGetter func(name string) (PeerChaincodeStream, error)
 var streamGetter peerStreamGetter
 
 //the non-mock user CC stream establishment func
-func userChaincodeStreamGetter(name string) (PeerChaincodeStream, error) {
+func userChaincodeStreamGetter(name string, credentials CredentialProvider, authToken AuthTokenProvider) (PeerChaincodeStream, error) {
 	if *peerAddress == "" {
 		return nil, errors.New("flag 'peer.address' must be set")
 	}
+	return dialPeer(*peerAddress, credentials, authToken)
+}
 
-	conf, err := internal.LoadConfig()
+// dialPeer opens a chaincode stream to the peer at address.
+func dialPeer(address string, credentials CredentialProvider, authToken AuthTokenProvider) (PeerChaincodeStream, error) {
+	var conf internal.Config
+	var err error
+	if credentials != nil {
+		conf, err = internal.LoadConfigWithClientCertificate(credentials.ClientCertificate)
+	} else {
+		conf, err = internal.LoadConfig()
+	}
 	if err != nil {
 		return nil, err
 	}
+	if *serverNameOverride != "" {
+		if conf.TLS == nil {
+			return nil, errors.New("flag 'tls.serverNameOverride' requires 'CORE_PEER_TLS_ENABLED' to be 'true'")
+		}
+		conf.TLS.ServerName = *serverNameOverride
+	}
 
-	conn, err := internal.NewClientConn(*peerAddress, conf.TLS, conf.KaOpts)
+	conn, err := internal.NewClientConn(address, conf.TLS, conf.KaOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	return internal.NewRegisterClient(conn)
+	var client peerpb.ChaincodeSupport_RegisterClient
+	if authToken != nil {
+		var auth string
+		if auth, err = authMetadata(authToken); err == nil {
+			client, err = internal.NewRegisterClientWithMetadata(conn, AuthTokenMetadataKey, auth)
+		}
+	} else {
+		client, err = internal.NewRegisterClient(conn)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return &connStream{ChaincodeSupport_RegisterClient: client, conn: conn}, nil
 }
 
 // Start chaincodes
-func Start(cc Chaincode) error {
+func Start(cc Chaincode, opts ...Option) error {
 	flag.Parse()
+	if err := applyBuilderMetadataFromEnv(); err != nil {
+		return err
+	}
 	chaincodename := os.Getenv("CORE_CHAINCODE_ID_NAME")
 	if chaincodename == "" {
 		return errors.New("'CORE_CHAINCODE_ID_NAME' must be set")
 	}
 
-	//mock stream not set up ... get real stream
-	if streamGetter == nil {
-		streamGetter = userChaincodeStreamGetter
+	schedulerConfig, err := SchedulerConfigFromEnv()
+	if err != nil {
+		return err
+	}
+	if schedulerConfig != nil {
+		// options passed explicitly take precedence over the environment
+		opts = append([]Option{WithChannelScheduler(*schedulerConfig)}, opts...)
 	}
+	if authToken := authTokenFromEnv(); authToken != nil {
+		opts = append([]Option{authToken}, opts...)
+	}
+	if debugServer := debugServerFromEnv(); debugServer != nil {
+		// after the options, so that the server logs with their logger
+		opts = append(opts, debugServer)
+	}
+	if configReload := configReloadFromEnv(); configReload != nil {
+		// last, so that the startup configuration is restored when a
+		// section is left out of the file
+		opts = append(opts, configReload)
+	}
+
+	handler, err := newChaincodeHandler(nil, cc, opts...)
+	if err != nil {
+		return fmt.Errorf("invalid shim option: %s", err)
+	}
+	handler.logStartupBanner(chaincodename)
+
+	addresses := peerAddresses()
+	if streamGetter == nil && (len(addresses) > 1 || len(addresses) == 1 && handler.reconnect != nil) {
+		dial := func(address string) (PeerChaincodeStream, error) {
+			return dialPeer(address, handler.credentials, handler.authToken)
+		}
+		return startWithFailover(addresses, dial, chaincodename, cc, handler, opts...)
+	}
+
+	getStream := streamGetter
+	if getStream == nil {
+		//mock stream not set up ... get real stream
+		getStream = func(name string) (PeerChaincodeStream, error) {
+			return userChaincodeStreamGetter(name, handler.credentials, handler.authToken)
+		}
+	}
+	return serve(getStream, chaincodename, cc, handler, opts...)
+}
 
-	stream, err := streamGetter(chaincodename)
+// serve opens the streams of handler with getStream and processes the
+// messages received from the peer until the first stream ends.
+func serve(getStream peerStreamGetter, chaincodename string, cc Chaincode, handler *Handler, opts ...Option) error {
+	stream, err := getStream(chaincodename)
 	if err != nil {
 		return err
 	}
+	if cs, ok := stream.(*connStream); ok {
+		peerConnection.track(cs.conn)
+	}
+	handler.chatStream = stream
+	if handler.streams <= 1 {
+		return chat(chaincodename, handler)
+	}
 
-	err = chatWithPeer(chaincodename, stream, cc)
+	if handler.registered == nil {
+		handler.registered = make(chan struct{})
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- chat(chaincodename, handler)
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-handler.registered:
+	}
 
+	additional := startAdditionalStreams(getStream, chaincodename, cc, handler, opts...)
+	err = <-done
+	for _, h := range additional {
+		h.closeSend()
+	}
 	return err
 }
 
+// startAdditionalStreams opens and registers the streams beyond the first
+// one requested with WithStreams. It stops at the first stream the peer
+// does not register and returns the handlers of the registered streams.
+func startAdditionalStreams(getStream peerStreamGetter, chaincodename string, cc Chaincode, primary *Handler, opts ...Option) []*Handler {
+	var handlers []*Handler
+	for i := 1; i < primary.streams; i++ {
+		stream, err := getStream(chaincodename)
+		if err != nil {
+			primary.logf("failed to open additional stream to peer: %s", err)
+			break
+		}
+
+		handler, err := newChaincodeHandler(stream, cc, opts...)
+		if err != nil {
+			primary.logf("failed to create handler of additional stream: %s", err)
+			stream.CloseSend()
+			break
+		}
+		handler.registered = make(chan struct{})
+		done := make(chan error, 1)
+		go func() {
+			done <- chat(chaincodename, handler)
+		}()
+
+		select {
+		case err := <-done:
+			primary.logf("peer does not support multiple streams, continuing with %d: %s", len(handlers)+1, err)
+			return handlers
+		case <-handler.registered:
+			handlers = append(handlers, handler)
+			go func() {
+				primary.logf("additional stream to peer ended: %s", <-done)
+			}()
+		}
+	}
+	return handlers
+}
+
 // StartInProc is an entry point for system chaincodes bootstrap. It is not an
 // API for chaincodes.
-func StartInProc(chaincodename string, stream PeerChaincodeStream, cc Chaincode) error {
-	return chatWithPeer(chaincodename, stream, cc)
+func StartInProc(chaincodename string, stream PeerChaincodeStream, cc Chaincode, opts ...Option) error {
+	return chatWithPeer(chaincodename, stream, cc, opts...)
 }
 
-func chatWithPeer(chaincodename string, stream PeerChaincodeStream, cc Chaincode) error {
+func chatWithPeer(chaincodename string, stream PeerChaincodeStream, cc Chaincode, opts ...Option) error {
 	// Create the shim handler responsible for all control logic
-	handler := newChaincodeHandler(stream, cc)
-	defer stream.CloseSend()
+	handler, err := newChaincodeHandler(stream, cc, opts...)
+	if err != nil {
+		return fmt.Errorf("invalid shim option: %s", err)
+	}
+	return chat(chaincodename, handler)
+}
+
+// chat registers the chaincode on the stream of handler and processes the
+// messages received from the peer until the stream ends.
+func chat(chaincodename string, handler *Handler) error {
+	err := converse(chaincodename, handler)
+	handler.disconnected(err)
+	handler.unregister()
+	return err
+}
+
+// unregister removes handler from the debug server and configuration
+// reloader it was registered with, so that the handlers of the streams
+// which ended are neither served nor configured.
+func (h *Handler) unregister() {
+	if h.debugServer != nil {
+		h.debugServer.unregister(h)
+	}
+	if h.configReloader != nil {
+		h.configReloader.unregister(h)
+	}
+}
+
+func converse(chaincodename string, handler *Handler) error {
+	stream := handler.chatStream
+	defer handler.closeSend()
 
 	// Send the ChaincodeID during register.
 	chaincodeID := &peerpb.ChaincodeID{Name: chaincodename}
@@ -119,7 +285,7 @@ func chatWithPeer(chaincodename string, stream PeerChaincodeStream, cc Chaincode
 		case rmsg := <-msgAvail:
 			switch {
 			case rmsg.err == io.EOF:
-				return errors.New("received EOF, ending chaincode stream")
+				return errStreamEOF
 			case rmsg.err != nil:
 				err := fmt.Errorf("receive failed: %s", rmsg.err)
 				return err
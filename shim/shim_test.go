@@ -7,12 +7,14 @@ import (
 	"errors"
 	"io"
 	"os"
+	"sync"
 	"testing"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
 	peerpb "github.com/hyperledger/fabric-protos-go/peer"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestStart(t *testing.T) {
@@ -133,3 +135,157 @@ func TestStart(t *testing.T) {
 	}
 
 }
+
+// pipeStream is a PeerChaincodeStream receiving the messages written to
+// recv until CloseSend is called.
+type pipeStream struct {
+	recv      chan *peerpb.ChaincodeMessage
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newPipeStream(msgs ...*peerpb.ChaincodeMessage) *pipeStream {
+	s := &pipeStream{
+		recv:   make(chan *peerpb.ChaincodeMessage, len(msgs)),
+		closed: make(chan struct{}),
+	}
+	for _, msg := range msgs {
+		s.recv <- msg
+	}
+	return s
+}
+
+func (s *pipeStream) Send(*peerpb.ChaincodeMessage) error { return nil }
+
+func (s *pipeStream) Recv() (*peerpb.ChaincodeMessage, error) {
+	select {
+	case msg := <-s.recv:
+		return msg, nil
+	case <-s.closed:
+		return nil, io.EOF
+	}
+}
+
+func (s *pipeStream) CloseSend() error {
+	s.closeOnce.Do(func() { close(s.closed) })
+	return nil
+}
+
+func TestStartMultipleStreams(t *testing.T) {
+	registered := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}
+	rejected := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_ERROR, Payload: []byte("duplicate chaincodeID")}
+
+	var tests = []struct {
+		name            string
+		responses       []*peerpb.ChaincodeMessage
+		expectedStreams int
+		expectedLog     string
+	}{
+		{
+			name:            "All Registered",
+			responses:       []*peerpb.ChaincodeMessage{registered, registered, registered},
+			expectedStreams: 3,
+		},
+		{
+			name:            "Rejected By Peer",
+			responses:       []*peerpb.ChaincodeMessage{registered, rejected, registered},
+			expectedStreams: 2,
+			expectedLog:     "peer does not support multiple streams, continuing with 1",
+		},
+	}
+
+	os.Setenv("CORE_CHAINCODE_ID_NAME", "cc")
+	defer os.Unsetenv("CORE_CHAINCODE_ID_NAME")
+	defer func() { streamGetter = nil }()
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var streams []*pipeStream
+			opened := make(chan struct{})
+			streamGetter = func(name string) (PeerChaincodeStream, error) {
+				stream := newPipeStream(test.responses[len(streams)])
+				streams = append(streams, stream)
+				if len(streams) == test.expectedStreams {
+					close(opened)
+				}
+				return stream, nil
+			}
+
+			go func() {
+				<-opened
+				streams[0].CloseSend()
+			}()
+
+			logger := &recordingLogger{}
+			err := Start(&mockChaincode{}, WithStreams(3), WithLogger(logger))
+			assert.EqualError(t, err, "received EOF, ending chaincode stream")
+			assert.Len(t, streams, test.expectedStreams)
+			for _, stream := range streams {
+				<-stream.closed
+			}
+			if test.expectedLog != "" {
+				// the first line is the startup banner
+				assert.Contains(t, logger.lines[1], test.expectedLog)
+			}
+		})
+	}
+}
+
+func TestStartAdditionalStreamsInvalidOption(t *testing.T) {
+	registered := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_REGISTERED}
+	var streams []*pipeStream
+	opened := make(chan struct{})
+	getStream := func(name string) (PeerChaincodeStream, error) {
+		stream := newPipeStream(registered)
+		streams = append(streams, stream)
+		if len(streams) == 2 {
+			close(opened)
+		}
+		return stream, nil
+	}
+	go func() {
+		<-opened
+		streams[0].CloseSend()
+	}()
+
+	handlers := 0
+	singleHandler := func(*Handler) error {
+		handlers++
+		if handlers > 1 {
+			return errors.New("already in use")
+		}
+		return nil
+	}
+	logger := &recordingLogger{}
+	opts := []Option{WithStreams(2), WithLogger(logger), singleHandler}
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, opts...)
+	require.NoError(t, err)
+
+	err = serve(getStream, "cc", &mockChaincode{}, h, opts...)
+	assert.EqualError(t, err, "received EOF, ending chaincode stream")
+	require.Len(t, streams, 2)
+	<-streams[1].closed
+	assert.Contains(t, logger.lines, "failed to create handler of additional stream: already in use")
+}
+
+func TestStartInvalidStreams(t *testing.T) {
+	os.Setenv("CORE_CHAINCODE_ID_NAME", "cc")
+	defer os.Unsetenv("CORE_CHAINCODE_ID_NAME")
+	streamGetter = func(name string) (PeerChaincodeStream, error) { return newPipeStream(), nil }
+	defer func() { streamGetter = nil }()
+
+	err := Start(&mockChaincode{}, WithStreams(0))
+	assert.EqualError(t, err, "invalid shim option: stream count must be positive, got 0")
+}
+
+func TestDialPeerServerNameOverride(t *testing.T) {
+	defer func(original *string) { serverNameOverride = original }(serverNameOverride)
+	name := "peer0.org1.example.com"
+	serverNameOverride = &name
+
+	os.Setenv("CORE_PEER_TLS_ENABLED", "false")
+	defer os.Unsetenv("CORE_PEER_TLS_ENABLED")
+	_, err := dialPeer("127.0.0.1:0", nil, nil)
+	assert.EqualError(t, err, "flag 'tls.serverNameOverride' requires 'CORE_PEER_TLS_ENABLED' to be 'true'")
+}
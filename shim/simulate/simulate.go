@@ -0,0 +1,235 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package simulate executes chaincode transactions against a read-only
+// snapshot of ledger state without a peer. A simulation captures the keys
+// the transaction would read and write and the event it would emit, which
+// is useful for policy checks in CI and for load modeling. The snapshot is
+// never modified.
+package simulate
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/embedded"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Read is a key read by a transaction along with the value it read.
+type Read struct {
+	Collection string
+	Key        string
+	Value      []byte
+}
+
+// RangeQuery is a range query executed by a transaction.
+type RangeQuery struct {
+	Collection string
+	StartKey   string
+	EndKey     string
+}
+
+// ReadWriteSet is the set of reads and writes a transaction would make.
+// The keys read by range queries are included in Reads.
+type ReadWriteSet struct {
+	Reads          []Read
+	RangeQueries   []RangeQuery
+	Writes         []embedded.KVWrite
+	MetadataWrites []embedded.MetadataWrite
+}
+
+// Result is the outcome of a simulated transaction.
+type Result struct {
+	Response pb.Response
+	Event    *pb.ChaincodeEvent
+	RWSet    ReadWriteSet
+}
+
+// Simulator executes transactions of a chaincode against a snapshot.
+// Simulations are executed one at a time; it is safe for concurrent use.
+type Simulator struct {
+	mutex    sync.Mutex
+	recorder *recorder
+	runner   *embedded.Runner
+	nextTx   int
+}
+
+// New starts the chaincode cc with the given name and returns a Simulator
+// executing transactions against snapshot. The options are passed to the
+// shim handler.
+func New(name string, cc shim.Chaincode, snapshot embedded.Ledger, opts ...shim.Option) (*Simulator, error) {
+	rec := &recorder{snapshot: snapshot}
+	runner, err := embedded.Start(name, cc, rec, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &Simulator{recorder: rec, runner: runner}, nil
+}
+
+// Stop stops the chaincode.
+func (s *Simulator) Stop() {
+	s.runner.Stop()
+}
+
+// Invoke simulates a transaction calling the Invoke function of the
+// chaincode with args. The writes are captured only when the chaincode
+// returns a successful response.
+func (s *Simulator) Invoke(args ...[]byte) (*Result, error) {
+	return s.simulate(s.runner.Invoke, args)
+}
+
+// Init simulates a transaction calling the Init function of the chaincode
+// with args.
+func (s *Simulator) Init(args ...[]byte) (*Result, error) {
+	return s.simulate(s.runner.Init, args)
+}
+
+func (s *Simulator) simulate(execute func(embedded.Transaction) (*embedded.Result, error), args [][]byte) (*Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.nextTx++
+	s.recorder.reset()
+	result, err := execute(embedded.Transaction{TxID: fmt.Sprintf("simulation-%d", s.nextTx), Args: args})
+	if err != nil {
+		return nil, err
+	}
+	return &Result{
+		Response: result.Response,
+		Event:    result.Event,
+		RWSet:    s.recorder.rwset(),
+	}, nil
+}
+
+// SnapshotFromMockStub returns a snapshot of the public state, private data
+// and key level endorsement policies of stub.
+func SnapshotFromMockStub(stub *shimtest.MockStub) *embedded.MemoryLedger {
+	var writes []embedded.KVWrite
+	for key, value := range stub.State {
+		writes = append(writes, embedded.KVWrite{Key: key, Value: value})
+	}
+	for collection, state := range stub.PvtState {
+		for key, value := range state {
+			writes = append(writes, embedded.KVWrite{Collection: collection, Key: key, Value: value})
+		}
+	}
+	var metadataWrites []embedded.MetadataWrite
+	for collection, policies := range stub.EndorsementPolicies {
+		for key, policy := range policies {
+			metadataWrites = append(metadataWrites, embedded.MetadataWrite{
+				Collection: collection,
+				Key:        key,
+				Name:       pb.MetaDataKeys_VALIDATION_PARAMETER.String(),
+				Value:      policy,
+			})
+		}
+	}
+
+	snapshot := embedded.NewMemoryLedger()
+	snapshot.Commit(writes, metadataWrites)
+	return snapshot
+}
+
+// recorder is a Ledger reading from a snapshot which records the reads and
+// captures the writes of the simulated transaction instead of committing
+// them.
+type recorder struct {
+	snapshot embedded.Ledger
+
+	mutex          sync.Mutex
+	reads          map[string]map[string][]byte
+	rangeQueries   []RangeQuery
+	writes         []embedded.KVWrite
+	metadataWrites []embedded.MetadataWrite
+}
+
+func (r *recorder) reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.reads = map[string]map[string][]byte{}
+	r.rangeQueries = nil
+	r.writes = nil
+	r.metadataWrites = nil
+}
+
+func (r *recorder) read(collection, key string, value []byte) {
+	if r.reads[collection] == nil {
+		r.reads[collection] = map[string][]byte{}
+	}
+	r.reads[collection][key] = value
+}
+
+// rwset returns the read/write set recorded since the last reset with the
+// reads in collection and key order.
+func (r *recorder) rwset() ReadWriteSet {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	rwset := ReadWriteSet{
+		RangeQueries:   r.rangeQueries,
+		Writes:         r.writes,
+		MetadataWrites: r.metadataWrites,
+	}
+	for collection, reads := range r.reads {
+		for key, value := range reads {
+			rwset.Reads = append(rwset.Reads, Read{Collection: collection, Key: key, Value: value})
+		}
+	}
+	sort.Slice(rwset.Reads, func(i, j int) bool {
+		if rwset.Reads[i].Collection != rwset.Reads[j].Collection {
+			return rwset.Reads[i].Collection < rwset.Reads[j].Collection
+		}
+		return rwset.Reads[i].Key < rwset.Reads[j].Key
+	})
+	return rwset
+}
+
+// GetState implements embedded.Ledger.
+func (r *recorder) GetState(collection, key string) ([]byte, error) {
+	value, err := r.snapshot.GetState(collection, key)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.read(collection, key, value)
+	return value, nil
+}
+
+// GetStateMetadata implements embedded.Ledger.
+func (r *recorder) GetStateMetadata(collection, key string) (map[string][]byte, error) {
+	return r.snapshot.GetStateMetadata(collection, key)
+}
+
+// GetStateRange implements embedded.Ledger.
+func (r *recorder) GetStateRange(collection, startKey, endKey string) ([]*queryresult.KV, error) {
+	results, err := r.snapshot.GetStateRange(collection, startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.rangeQueries = append(r.rangeQueries, RangeQuery{Collection: collection, StartKey: startKey, EndKey: endKey})
+	for _, kv := range results {
+		r.read(collection, kv.Key, kv.Value)
+	}
+	return results, nil
+}
+
+// Commit implements embedded.Ledger by capturing the writes.
+func (r *recorder) Commit(writes []embedded.KVWrite, metadataWrites []embedded.MetadataWrite) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.writes = writes
+	r.metadataWrites = metadataWrites
+	return nil
+}
@@ -0,0 +1,110 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package simulate
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/embedded"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// transferChaincode moves the value of one key to another.
+type transferChaincode struct{}
+
+func (transferChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (transferChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	fn, args := stub.GetFunctionAndParameters()
+	switch fn {
+	case "move":
+		value, err := stub.GetState(args[0])
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		if value == nil {
+			return shim.Error("missing " + args[0])
+		}
+		stub.DelState(args[0])
+		stub.PutState(args[1], value)
+		stub.SetEvent("moved", value)
+		return shim.Success(nil)
+	case "count":
+		iter, err := stub.GetStateByRange("", "")
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		defer iter.Close()
+		n := 0
+		for iter.HasNext() {
+			iter.Next()
+			n++
+		}
+		return shim.Success([]byte{byte(n)})
+	}
+	return shim.Error("unknown function " + fn)
+}
+
+func TestSimulate(t *testing.T) {
+	snapshot := embedded.NewMemoryLedger()
+	snapshot.Commit([]embedded.KVWrite{{Key: "a", Value: []byte("1")}, {Key: "b", Value: []byte("2")}}, nil)
+
+	sim, err := New("transfer", transferChaincode{}, snapshot)
+	require.NoError(t, err)
+	defer sim.Stop()
+
+	result, err := sim.Invoke([]byte("move"), []byte("a"), []byte("c"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(shim.OK), result.Response.Status)
+	assert.Equal(t, "moved", result.Event.EventName)
+	assert.Equal(t, ReadWriteSet{
+		Reads: []Read{{Key: "a", Value: []byte("1")}},
+		Writes: []embedded.KVWrite{
+			{Key: "a", IsDelete: true},
+			{Key: "c", Value: []byte("1")},
+		},
+	}, result.RWSet)
+
+	// the snapshot is not modified
+	value, _ := snapshot.GetState("", "a")
+	assert.Equal(t, []byte("1"), value)
+	value, _ = snapshot.GetState("", "c")
+	assert.Nil(t, value)
+
+	result, err = sim.Invoke([]byte("count"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{2}, result.Response.Payload)
+	assert.Equal(t, []RangeQuery{{StartKey: "\x01"}}, result.RWSet.RangeQueries)
+	assert.Len(t, result.RWSet.Reads, 2)
+	assert.Empty(t, result.RWSet.Writes)
+
+	result, err = sim.Invoke([]byte("move"), []byte("x"), []byte("y"))
+	require.NoError(t, err)
+	assert.Equal(t, int32(shim.ERROR), result.Response.Status)
+	assert.Equal(t, []Read{{Key: "x"}}, result.RWSet.Reads)
+	assert.Empty(t, result.RWSet.Writes)
+}
+
+func TestSnapshotFromMockStub(t *testing.T) {
+	stub := shimtest.NewMockStub("transfer", transferChaincode{})
+	stub.MockTransactionStart("tx1")
+	stub.PutState("a", []byte("1"))
+	stub.PutPrivateData("coll", "p", []byte("2"))
+	stub.SetStateValidationParameter("a", []byte("policy"))
+	stub.MockTransactionEnd("tx1")
+
+	snapshot := SnapshotFromMockStub(stub)
+	value, _ := snapshot.GetState("", "a")
+	assert.Equal(t, []byte("1"), value)
+	value, _ = snapshot.GetState("coll", "p")
+	assert.Equal(t, []byte("2"), value)
+	md, _ := snapshot.GetStateMetadata("", "a")
+	assert.Equal(t, map[string][]byte{pb.MetaDataKeys_VALIDATION_PARAMETER.String(): []byte("policy")}, md)
+}
@@ -0,0 +1,75 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// WithStrictKeys makes the writes of the stub (PutState, DelState,
+// PutPrivateData, DelPrivateData and the metadata setters) reject keys
+// failing CheckKey and values failing CheckValue. Such keys and values are
+// otherwise only rejected by a peer using CouchDB as its state database,
+// when the transaction is committed.
+func WithStrictKeys() Option {
+	return func(h *Handler) error {
+		h.strictKeys = true
+		return nil
+	}
+}
+
+// CheckKey returns an error if key cannot be stored in a CouchDB state
+// database: it must be valid UTF-8, must not start with an underscore and
+// must only start with a null byte if it is a composite key created by
+// CreateCompositeKey.
+func CheckKey(key string) error {
+	if key == "" {
+		return errors.New("key must not be an empty string")
+	}
+	if !utf8.ValidString(key) {
+		return fmt.Errorf("key [%x] is not a valid utf8 string", key)
+	}
+	if strings.HasPrefix(key, "_") {
+		return fmt.Errorf("key [%s] must not start with an underscore", key)
+	}
+	if key[0] == compositeKeyNamespace[0] {
+		if _, _, err := splitCompositeKey(key); err != nil {
+			return fmt.Errorf("key [%x] starts with a null byte but is not a composite key", key)
+		}
+	}
+	return nil
+}
+
+// CheckValue returns an error if value is a JSON document that a CouchDB
+// state database cannot store because it is not valid UTF-8. Values that
+// are not JSON are stored as attachments and are not checked.
+func CheckValue(value []byte) error {
+	trimmed := bytes.TrimSpace(value)
+	if len(trimmed) == 0 || trimmed[0] != '{' || !json.Valid(trimmed) {
+		return nil
+	}
+	if !utf8.Valid(trimmed) {
+		return errors.New("JSON value is not a valid utf8 string")
+	}
+	return nil
+}
+
+// checkWrite checks key and value when strict keys are enabled.
+func (s *ChaincodeStub) checkWrite(key string, value []byte) error {
+	if !s.handler.strictKeys {
+		return nil
+	}
+	if err := CheckKey(key); err != nil {
+		return fmt.Errorf("rejected by strict key mode: %s", err)
+	}
+	if err := CheckValue(value); err != nil {
+		return fmt.Errorf("rejected by strict key mode: key [%s]: %s", key, err)
+	}
+	return nil
+}
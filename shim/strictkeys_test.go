@@ -0,0 +1,79 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckKey(t *testing.T) {
+	compositeKey, err := CreateCompositeKey("asset", []string{"a", "b"})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name string
+		key  string
+		err  string
+	}{
+		{name: "Simple", key: "asset1"},
+		{name: "Composite", key: compositeKey},
+		{name: "Unicode", key: "資產"},
+		{name: "Empty", key: "", err: "key must not be an empty string"},
+		{name: "Invalid UTF-8", key: "a\xffb", err: "key [61ff62] is not a valid utf8 string"},
+		{name: "Underscore", key: "_design", err: "key [_design] must not start with an underscore"},
+		{name: "Null Byte", key: "\x00asset", err: "key [006173736574] starts with a null byte but is not a composite key"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckKey(tt.key)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestCheckValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value []byte
+		err   string
+	}{
+		{name: "JSON", value: []byte(`{"owner":"alice"}`)},
+		{name: "Binary", value: []byte{0xff, 0x00, 0xfe}},
+		{name: "Nil", value: nil},
+		{name: "Invalid UTF-8 In JSON", value: []byte("{\"owner\":\"\xff\"}"), err: "JSON value is not a valid utf8 string"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckValue(tt.value)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWithStrictKeys(t *testing.T) {
+	h := &Handler{}
+	require.NoError(t, WithStrictKeys()(h))
+	assert.True(t, h.strictKeys)
+
+	stub, err := newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+	require.NoError(t, err)
+
+	assert.EqualError(t, stub.PutState("_id", []byte("value")), "rejected by strict key mode: key [_id] must not start with an underscore")
+	assert.EqualError(t, stub.DelState("_id"), "rejected by strict key mode: key [_id] must not start with an underscore")
+	assert.EqualError(t, stub.PutPrivateData("col", "a\xff", []byte("value")), "rejected by strict key mode: key [61ff] is not a valid utf8 string")
+	assert.EqualError(t, stub.PutState("key", []byte("{\"a\":\"\xff\"}")), "rejected by strict key mode: key [key]: JSON value is not a valid utf8 string")
+	assert.EqualError(t, stub.SetStateValidationParameter("_id", []byte("ep")), "rejected by strict key mode: key [_id] must not start with an underscore")
+}
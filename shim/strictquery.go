@@ -0,0 +1,47 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// WithStrictQueries makes GetQueryResult, GetQueryResultWithPagination and
+// GetPrivateDataQueryResult reject queries that fail CheckCanonicalQuery.
+// Queries built by the shim/query package pass the check, while queries
+// assembled by string concatenation or fmt.Sprintf, which are prone to
+// injection, usually do not.
+func WithStrictQueries() Option {
+	return func(h *Handler) error {
+		h.strictQueries = true
+		return nil
+	}
+}
+
+// CheckCanonicalQuery returns an error unless query is a JSON object encoded
+// exactly as encoding/json encodes it: without insignificant whitespace and
+// with object keys sorted. This is a heuristic for detecting queries that
+// were not produced by a JSON encoder.
+func CheckCanonicalQuery(query string) error {
+	dec := json.NewDecoder(bytes.NewReader([]byte(query)))
+	dec.UseNumber()
+	var v map[string]interface{}
+	if err := dec.Decode(&v); err != nil {
+		return fmt.Errorf("query is not a JSON object: %s", err)
+	}
+	if dec.More() {
+		return errors.New("query has trailing data")
+	}
+	canonical, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode query: %s", err)
+	}
+	if string(canonical) != query {
+		return errors.New("query is not canonical JSON, build it with the shim/query package or encoding/json instead of string concatenation")
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCanonicalQuery(t *testing.T) {
+	tests := []struct {
+		query string
+		err   string
+	}{
+		{query: `{"selector":{"owner":"alice"}}`},
+		{query: `{"selector":{"price":{"$gt":100.50}}}`},
+		{query: `{"selector":{"owner": "alice"}}`, err: "query is not canonical JSON, build it with the shim/query package or encoding/json instead of string concatenation"},
+		{query: `{"sort":[{"a":"asc"}],"selector":{"a":1}}`, err: "query is not canonical JSON, build it with the shim/query package or encoding/json instead of string concatenation"},
+		{query: `{"selector":{"owner":"alice"}}{}`, err: "query has trailing data"},
+		{query: `["selector"]`, err: "query is not a JSON object: json: cannot unmarshal array into Go value of type map[string]interface {}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			err := CheckCanonicalQuery(tt.query)
+			if tt.err != "" {
+				assert.EqualError(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestWithStrictQueries(t *testing.T) {
+	h := &Handler{}
+	require.NoError(t, WithStrictQueries()(h))
+	assert.True(t, h.strictQueries)
+
+	stub, err := newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+	require.NoError(t, err)
+
+	expected := "rejected by strict query mode: query is not canonical JSON, build it with the shim/query package or encoding/json instead of string concatenation"
+	_, err = stub.GetQueryResult(`{"selector": {"owner": "` + "alice" + `"}}`)
+	assert.EqualError(t, err, expected)
+	_, _, err = stub.GetQueryResultWithPagination(`{"selector": {}}`, 10, "")
+	assert.EqualError(t, err, expected)
+	_, err = stub.GetPrivateDataQueryResult("col", `{"selector": {}}`)
+	assert.EqualError(t, err, expected)
+}
@@ -4,13 +4,17 @@
 package shim
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
 	"errors"
 	"fmt"
+	"math/rand"
+	"time"
 	"unicode/utf8"
 
 	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-protos-go/common"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
@@ -35,6 +39,33 @@ type ChaincodeStub struct {
 	binding   []byte
 
 	decorations map[string][]byte
+
+	// rwset tracks the reads and writes made by the chaincode.
+	rwset RWSetTracker
+
+	// observers holds the hooks registered with OnChange.
+	observers ChangeObservers
+
+	// nonce of the proposal and the generator seeded from it, created on
+	// first use by DeterministicRand.
+	nonce []byte
+	rand  *rand.Rand
+
+	// chaincodeName is the name of the chaincode the proposal invokes, and
+	// collectionsConfig caches the result of GetCollectionsConfig.
+	chaincodeName     string
+	collectionsConfig []*common.StaticCollectionConfig
+
+	// queryBudget, if set, limits the query results fetched by the
+	// transaction.
+	queryBudget *queryBudget
+
+	// writeGuard, if set, enforces the write limits on the writes of the
+	// transaction.
+	writeGuard *writeGuard
+
+	// trace is the trace context of the transaction, if any.
+	trace TraceContext
 }
 
 // ChaincodeInvocation functionality
@@ -49,6 +80,14 @@ func newChaincodeStub(handler *Handler, channelID, txid string, input *pb.Chainc
 		decorations:                input.Decorations,
 		validationParameterMetakey: pb.MetaDataKeys_VALIDATION_PARAMETER.String(),
 	}
+	if handler.queryBudget > 0 {
+		stub.queryBudget = &queryBudget{limit: int64(handler.queryBudget)}
+	}
+	if handler.writeLimits != nil {
+		stub.writeGuard = newWriteGuard(handler.writeLimits)
+	}
+	// the values written are only needed by the offchain sink
+	stub.rwset.keepValues = handler.offchain != nil
 
 	// TODO: sanity check: verify that every call to init with a nil
 	// signedProposal is a legitimate one, meaning it is an internal call
@@ -92,12 +131,20 @@ func newChaincodeStub(handler *Handler, channelID, txid string, input *pb.Chainc
 			)
 		}
 
+		// the header extension names the invoked chaincode; it is only used
+		// by GetCollectionsConfig, so a malformed extension is not an error
+		hdrExt := &pb.ChaincodeHeaderExtension{}
+		if err := proto.Unmarshal(chdr.GetExtension(), hdrExt); err == nil {
+			stub.chaincodeName = hdrExt.GetChaincodeId().GetName()
+		}
+
 		// extract creator from signature header
 		shdr := &common.SignatureHeader{}
 		if err := proto.Unmarshal(hdr.GetSignatureHeader(), shdr); err != nil {
 			return nil, fmt.Errorf("failed to extract signature header: %s", err)
 		}
 		stub.creator = shdr.GetCreator()
+		stub.nonce = shdr.GetNonce()
 
 		// extract trasient data from proposal payload
 		payload := &pb.ChaincodeProposalPayload{}
@@ -113,6 +160,7 @@ func newChaincodeStub(handler *Handler, channelID, txid string, input *pb.Chainc
 		stub.binding = digest[:]
 
 	}
+	stub.trace, _ = readTraceContext(stub.decorations, stub.GetTransient)
 
 	return stub, nil
 }
@@ -127,6 +175,41 @@ func (s *ChaincodeStub) GetChannelID() string {
 	return s.ChannelID
 }
 
+// OnChange registers hook to be called after every write made through this
+// stub, with PutState, DelState, PutPrivateData or DelPrivateData, to a key
+// starting with keyPrefix, in any collection. Hooks are called in the order
+// they were registered and are discarded at the end of the transaction. Use
+// it to keep derived keys, such as indexes, in sync with the keys they are
+// derived from. An error returned by a hook is returned by the call that
+// made the write. Writes made by a hook do not trigger hooks. As always,
+// GetState in a hook returns the committed value, not the value just
+// written.
+//
+// Like DeterministicRand, it is not part of ChaincodeStubInterface and is
+// reached with a type assertion, which shimtest.MockStub also satisfies.
+func (s *ChaincodeStub) OnChange(keyPrefix string, hook ChangeHook) {
+	s.observers.Register(keyPrefix, hook)
+}
+
+// DeterministicRand returns a pseudo-random number generator seeded from the
+// transaction ID and the nonce of the proposal, which produces the same
+// stream of numbers on every endorser. Use it instead of math/rand or
+// crypto/rand, which would produce different write sets on each endorser, to
+// shuffle, sample or generate identifiers. Every call returns the same
+// generator, so successive calls continue the stream; it is not safe for
+// concurrent use. The output is predictable by anyone who knows the proposal
+// and must not be used for secrets.
+//
+// It is not part of ChaincodeStubInterface: chaincode reaches it with a type
+// assertion to interface{ DeterministicRand() *rand.Rand }, which
+// shimtest.MockStub also satisfies.
+func (s *ChaincodeStub) DeterministicRand() *rand.Rand {
+	if s.rand == nil {
+		s.rand = NewDeterministicRand(s.TxID, s.nonce)
+	}
+	return s.rand
+}
+
 // GetDecorations ...
 func (s *ChaincodeStub) GetDecorations() map[string][]byte {
 	return s.decorations
@@ -143,18 +226,114 @@ func (s *ChaincodeStub) InvokeChaincode(chaincodeName string, args [][]byte, cha
 	return s.handler.handleInvokeChaincode(chaincodeName, args, s.ChannelID, s.TxID)
 }
 
+// InvokeChaincodeWithResult documentation can be found in interfaces.go
+func (s *ChaincodeStub) InvokeChaincodeWithResult(chaincodeName string, args [][]byte, channel string) *InvokeResult {
+	// Internally we handle chaincode name as a composite name
+	if channel != "" {
+		chaincodeName = chaincodeName + "/" + channel
+	}
+	return s.handler.handleInvokeChaincodeResult(chaincodeName, args, s.ChannelID, s.TxID)
+}
+
+// ErrTransientPropagation is the error returned by
+// InvokeChaincodeWithTransient when the transient map to pass differs from the
+// transient map of the proposal, which is the only one the peer propagates.
+var ErrTransientPropagation = errors.New("peer does not support passing a transient map other than the proposal's to the called chaincode")
+
+// InvokeChaincodeWithTransient documentation can be found in interfaces.go
+func (s *ChaincodeStub) InvokeChaincodeWithTransient(chaincodeName string, args [][]byte, channel string, transient map[string][]byte) pb.Response {
+	if err := CheckTransientPropagation(s.transient, transient); err != nil {
+		return Error(err.Error())
+	}
+	return s.InvokeChaincode(chaincodeName, args, channel)
+}
+
+// CheckTransientPropagation returns ErrTransientPropagation unless transient
+// is empty or equal to proposalTransient.
+func CheckTransientPropagation(proposalTransient, transient map[string][]byte) error {
+	if len(transient) == 0 {
+		return nil
+	}
+	if len(transient) != len(proposalTransient) {
+		return ErrTransientPropagation
+	}
+	for k, v := range transient {
+		pv, ok := proposalTransient[k]
+		if !ok || !bytes.Equal(v, pv) {
+			return ErrTransientPropagation
+		}
+	}
+	return nil
+}
+
 // --------- State functions ----------
 
 // GetState documentation can be found in interfaces.go
 func (s *ChaincodeStub) GetState(key string) ([]byte, error) {
 	// Access public data by setting the collection to empty string
 	collection := ""
-	return s.handler.handleGetState(collection, key, s.ChannelID, s.TxID)
+	return s.getState(collection, key)
+}
+
+// getState reads key from collection and records the read.
+func (s *ChaincodeStub) getState(collection, key string) ([]byte, error) {
+	value, err := s.handler.handleGetState(collection, key, s.ChannelID, s.TxID)
+	if err != nil {
+		return nil, err
+	}
+	s.rwset.Read(collection, key, value)
+	return value, nil
+}
+
+// putState writes key to collection and records the write.
+func (s *ChaincodeStub) putState(collection, key string, value []byte) error {
+	if err := s.checkWrite(key, value); err != nil {
+		return err
+	}
+	if err := s.writeGuard.check(collection, key, value); err != nil {
+		return err
+	}
+	if err := s.handler.handlePutState(collection, key, value, s.ChannelID, s.TxID); err != nil {
+		return err
+	}
+	s.rwset.Write(collection, key, value, false)
+	return s.observers.Notify(s, StateChange{Collection: collection, Key: key, Value: value})
+}
+
+// delState deletes key from collection and records the write.
+func (s *ChaincodeStub) delState(collection, key string) error {
+	if err := s.checkWrite(key, nil); err != nil {
+		return err
+	}
+	if err := s.writeGuard.check(collection, key, nil); err != nil {
+		return err
+	}
+	if err := s.handler.handleDelState(collection, key, s.ChannelID, s.TxID); err != nil {
+		return err
+	}
+	s.rwset.Write(collection, key, nil, true)
+	return s.observers.Notify(s, StateChange{Collection: collection, Key: key, IsDelete: true})
+}
+
+// putStateMetadataEntry writes a metadata entry of key in collection and
+// records the write.
+func (s *ChaincodeStub) putStateMetadataEntry(collection, key, name string, value []byte) error {
+	if err := s.checkWrite(key, nil); err != nil {
+		return err
+	}
+	if err := s.writeGuard.check(collection, key, value); err != nil {
+		return err
+	}
+	if err := s.handler.handlePutStateMetadataEntry(collection, key, name, value, s.ChannelID, s.TxID); err != nil {
+		return err
+	}
+	s.rwset.MetadataWrite(collection, key, name, value)
+	return nil
 }
 
 // SetStateValidationParameter documentation can be found in interfaces.go
 func (s *ChaincodeStub) SetStateValidationParameter(key string, ep []byte) error {
-	return s.handler.handlePutStateMetadataEntry("", key, s.validationParameterMetakey, ep, s.ChannelID, s.TxID)
+	return s.putStateMetadataEntry("", key, s.validationParameterMetakey, ep)
 }
 
 // GetStateValidationParameter documentation can be found in interfaces.go
@@ -176,7 +355,7 @@ func (s *ChaincodeStub) PutState(key string, value []byte) error {
 	}
 	// Access public data by setting the collection to empty string
 	collection := ""
-	return s.handler.handlePutState(collection, key, value, s.ChannelID, s.TxID)
+	return s.putState(collection, key, value)
 }
 
 func (s *ChaincodeStub) createStateQueryIterator(response *pb.QueryResponse) *StateQueryIterator {
@@ -187,12 +366,16 @@ func (s *ChaincodeStub) createStateQueryIterator(response *pb.QueryResponse) *St
 			txid:       s.TxID,
 			response:   response,
 			currentLoc: 0,
+			budget:     s.queryBudget,
 		},
 	}
 }
 
 // GetQueryResult documentation can be found in interfaces.go
 func (s *ChaincodeStub) GetQueryResult(query string) (StateQueryIteratorInterface, error) {
+	if s.handler.adaptivePager != nil {
+		return s.adaptiveQueryResult(query)
+	}
 	// Access public data by setting the collection to empty string
 	collection := ""
 	// ignore QueryResponseMetadata as it is not applicable for a rich query without pagination
@@ -205,7 +388,7 @@ func (s *ChaincodeStub) GetQueryResult(query string) (StateQueryIteratorInterfac
 func (s *ChaincodeStub) DelState(key string) error {
 	// Access public data by setting the collection to empty string
 	collection := ""
-	return s.handler.handleDelState(collection, key, s.ChannelID, s.TxID)
+	return s.delState(collection, key)
 }
 
 //  ---------  private state functions  ---------
@@ -215,7 +398,7 @@ func (s *ChaincodeStub) GetPrivateData(collection string, key string) ([]byte, e
 	if collection == "" {
 		return nil, fmt.Errorf("collection must not be an empty string")
 	}
-	return s.handler.handleGetState(collection, key, s.ChannelID, s.TxID)
+	return s.getState(collection, key)
 }
 
 // GetPrivateDataHash documentation can be found in interfaces.go
@@ -234,7 +417,7 @@ func (s *ChaincodeStub) PutPrivateData(collection string, key string, value []by
 	if key == "" {
 		return fmt.Errorf("key must not be an empty string")
 	}
-	return s.handler.handlePutState(collection, key, value, s.ChannelID, s.TxID)
+	return s.putState(collection, key, value)
 }
 
 // DelPrivateData documentation can be found in interfaces.go
@@ -242,7 +425,7 @@ func (s *ChaincodeStub) DelPrivateData(collection string, key string) error {
 	if collection == "" {
 		return fmt.Errorf("collection must not be an empty string")
 	}
-	return s.handler.handleDelState(collection, key, s.ChannelID, s.TxID)
+	return s.delState(collection, key)
 }
 
 // GetPrivateDataByRange documentation can be found in interfaces.go
@@ -314,7 +497,39 @@ func (s *ChaincodeStub) GetPrivateDataValidationParameter(collection, key string
 
 // SetPrivateDataValidationParameter documentation can be found in interfaces.go
 func (s *ChaincodeStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
-	return s.handler.handlePutStateMetadataEntry(collection, key, s.validationParameterMetakey, ep, s.ChannelID, s.TxID)
+	return s.putStateMetadataEntry(collection, key, s.validationParameterMetakey, ep)
+}
+
+// GetStateMetadata documentation can be found in interfaces.go
+func (s *ChaincodeStub) GetStateMetadata(key string) (map[string][]byte, error) {
+	return s.handler.handleGetStateMetadata("", key, s.ChannelID, s.TxID)
+}
+
+// SetStateMetadataEntry documentation can be found in interfaces.go
+func (s *ChaincodeStub) SetStateMetadataEntry(key, metakey string, value []byte) error {
+	if metakey == "" {
+		return errors.New("metakey must not be an empty string")
+	}
+	return s.putStateMetadataEntry("", key, metakey, value)
+}
+
+// GetPrivateDataMetadata documentation can be found in interfaces.go
+func (s *ChaincodeStub) GetPrivateDataMetadata(collection, key string) (map[string][]byte, error) {
+	if collection == "" {
+		return nil, fmt.Errorf("collection must not be an empty string")
+	}
+	return s.handler.handleGetStateMetadata(collection, key, s.ChannelID, s.TxID)
+}
+
+// SetPrivateDataMetadataEntry documentation can be found in interfaces.go
+func (s *ChaincodeStub) SetPrivateDataMetadataEntry(collection, key, metakey string, value []byte) error {
+	if collection == "" {
+		return fmt.Errorf("collection must not be an empty string")
+	}
+	if metakey == "" {
+		return errors.New("metakey must not be an empty string")
+	}
+	return s.putStateMetadataEntry(collection, key, metakey, value)
 }
 
 // CommonIterator documentation can be found in interfaces.go
@@ -324,6 +539,7 @@ type CommonIterator struct {
 	txid       string
 	response   *pb.QueryResponse
 	currentLoc int
+	budget     *queryBudget
 }
 
 // StateQueryIterator documentation can be found in interfaces.go
@@ -368,6 +584,10 @@ func (s *ChaincodeStub) handleGetStateByRange(collection, startKey, endKey strin
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := s.chargeQuery(response); err != nil {
+		return nil, nil, err
+	}
+	s.rwset.RangeQuery(collection, startKey, endKey)
 
 	iterator := s.createStateQueryIterator(response)
 	responseMetadata, err := createQueryResponseMetadata(response.Metadata)
@@ -381,10 +601,19 @@ func (s *ChaincodeStub) handleGetStateByRange(collection, startKey, endKey strin
 func (s *ChaincodeStub) handleGetQueryResult(collection, query string,
 	metadata []byte) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
 
+	if s.handler.strictQueries {
+		if err := CheckCanonicalQuery(query); err != nil {
+			return nil, nil, fmt.Errorf("rejected by strict query mode: %s", err)
+		}
+	}
+
 	response, err := s.handler.handleGetQueryResult(collection, query, metadata, s.ChannelID, s.TxID)
 	if err != nil {
 		return nil, nil, err
 	}
+	if err := s.chargeQuery(response); err != nil {
+		return nil, nil, err
+	}
 
 	iterator := s.createStateQueryIterator(response)
 	responseMetadata, err := createQueryResponseMetadata(response.Metadata)
@@ -417,7 +646,19 @@ func (s *ChaincodeStub) GetHistoryForKey(key string) (HistoryQueryIteratorInterf
 	if err != nil {
 		return nil, err
 	}
-	return &HistoryQueryIterator{CommonIterator: &CommonIterator{s.handler, s.ChannelID, s.TxID, response, 0}}, nil
+	if err := s.chargeQuery(response); err != nil {
+		return nil, err
+	}
+	return &HistoryQueryIterator{CommonIterator: &CommonIterator{s.handler, s.ChannelID, s.TxID, response, 0, s.queryBudget}}, nil
+}
+
+// GetHistoryForKeyRange documentation can be found in interfaces.go
+func (s *ChaincodeStub) GetHistoryForKeyRange(key string, from, to time.Time) (HistoryQueryIteratorInterface, error) {
+	iter, err := s.GetHistoryForKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return newTimeRangeHistoryIterator(iter, from, to), nil
 }
 
 //CreateCompositeKey documentation can be found in interfaces.go
@@ -446,6 +687,12 @@ func CreateCompositeKey(objectType string, attributes []string) (string, error)
 }
 
 func splitCompositeKey(compositeKey string) (string, []string, error) {
+	if len(compositeKey) < 2 || compositeKey[0] != compositeKeyNamespace[0] || compositeKey[len(compositeKey)-1] != minUnicodeRuneValue {
+		return "", nil, fmt.Errorf("invalid composite key: [%x]", compositeKey)
+	}
+	if !utf8.ValidString(compositeKey) {
+		return "", nil, fmt.Errorf("not a valid utf8 string: [%x]", compositeKey)
+	}
 	componentIndex := 1
 	components := []string{}
 	for i := 1; i < len(compositeKey); i++ {
@@ -511,6 +758,37 @@ func createQueryMetadata(pageSize int32, bookmark string) ([]byte, error) {
 	return metadataBytes, nil
 }
 
+// GetStateByCompositeKeyRange documentation can be found in interfaces.go
+func (s *ChaincodeStub) GetStateByCompositeKeyRange(objectType string, startAttrs, endAttrs []string) (StateQueryIteratorInterface, error) {
+	collection := ""
+	startKey, endKey, err := s.createRangeKeysForCompositeKeyRange(objectType, startAttrs, endAttrs)
+	if err != nil {
+		return nil, err
+	}
+	// ignore QueryResponseMetadata as it is not applicable for a composite key range query without pagination
+	iterator, _, err := s.handleGetStateByRange(collection, startKey, endKey, nil)
+
+	return iterator, err
+}
+
+func (s *ChaincodeStub) createRangeKeysForCompositeKeyRange(objectType string, startAttrs, endAttrs []string) (string, string, error) {
+	startKey, err := s.CreateCompositeKey(objectType, startAttrs)
+	if err != nil {
+		return "", "", err
+	}
+	if len(endAttrs) == 0 {
+		// the range extends to the end of the objectType namespace
+		_, endKey, err := s.createRangeKeysForPartialCompositeKey(objectType, nil)
+		return startKey, endKey, err
+	}
+	endKey, err := s.CreateCompositeKey(objectType, endAttrs)
+	if err != nil {
+		return "", "", err
+	}
+
+	return startKey, endKey, nil
+}
+
 // GetStateByRangeWithPagination ...
 func (s *ChaincodeStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32,
 	bookmark string) (StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
@@ -581,6 +859,54 @@ func (iter *HistoryQueryIterator) Next() (*queryresult.KeyModification, error) {
 	return result.(*queryresult.KeyModification), err
 }
 
+// timeRangeHistoryIterator is a HistoryQueryIteratorInterface returning the
+// entries of another iterator whose timestamp is within [from, to). A zero
+// from or to leaves the range unbounded.
+type timeRangeHistoryIterator struct {
+	HistoryQueryIteratorInterface
+	from time.Time
+	to   time.Time
+
+	// next holds the next matching entry, or the error encountered while
+	// looking for it.
+	next *queryresult.KeyModification
+	err  error
+}
+
+func newTimeRangeHistoryIterator(iter HistoryQueryIteratorInterface, from, to time.Time) *timeRangeHistoryIterator {
+	return &timeRangeHistoryIterator{HistoryQueryIteratorInterface: iter, from: from, to: to}
+}
+
+// HasNext ...
+func (iter *timeRangeHistoryIterator) HasNext() bool {
+	for iter.next == nil && iter.err == nil && iter.HistoryQueryIteratorInterface.HasNext() {
+		km, err := iter.HistoryQueryIteratorInterface.Next()
+		if err != nil {
+			iter.err = err
+			break
+		}
+		ts, err := ptypes.Timestamp(km.Timestamp)
+		if err != nil {
+			iter.err = fmt.Errorf("invalid timestamp in history of transaction %s: %s", km.TxId, err)
+			break
+		}
+		if (iter.from.IsZero() || !ts.Before(iter.from)) && (iter.to.IsZero() || ts.Before(iter.to)) {
+			iter.next = km
+		}
+	}
+	return iter.next != nil || iter.err != nil
+}
+
+// Next ...
+func (iter *timeRangeHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	if !iter.HasNext() {
+		return nil, errors.New("no such key")
+	}
+	km, err := iter.next, iter.err
+	iter.next, iter.err = nil, nil
+	return km, err
+}
+
 // HasNext documentation can be found in interfaces.go
 func (iter *CommonIterator) HasNext() bool {
 	if iter.currentLoc < len(iter.response.Results) || iter.response.HasMore {
@@ -620,7 +946,7 @@ func (iter *CommonIterator) fetchNextQueryResult() error {
 	}
 	iter.currentLoc = 0
 	iter.response = response
-	return nil
+	return iter.budget.charge(response)
 }
 
 // nextResult returns the next QueryResult (i.e., either a KV struct or KeyModification)
@@ -718,6 +1044,10 @@ func (s *ChaincodeStub) GetArgsSlice() ([]byte, error) {
 
 // GetTxTimestamp documentation can be found in interfaces.go
 func (s *ChaincodeStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
+	if s.proposal == nil {
+		return nil, errors.New("no proposal available to extract the timestamp from")
+	}
+
 	hdr := &common.Header{}
 	if err := proto.Unmarshal(s.proposal.Header, hdr); err != nil {
 		return nil, fmt.Errorf("error unmarshaling Header: %s", err)
@@ -731,6 +1061,20 @@ func (s *ChaincodeStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
 	return chdr.GetTimestamp(), nil
 }
 
+// Now documentation can be found in interfaces.go
+func (s *ChaincodeStub) Now() (time.Time, error) {
+	ts, err := s.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ptypes.Timestamp(ts)
+}
+
+// RWSetDigest documentation can be found in interfaces.go
+func (s *ChaincodeStub) RWSetDigest() []byte {
+	return s.rwset.Digest()
+}
+
 // ------------- ChaincodeEvent API ----------------------
 
 // SetEvent documentation can be found in interfaces.go
@@ -738,6 +1082,10 @@ func (s *ChaincodeStub) SetEvent(name string, payload []byte) error {
 	if name == "" {
 		return errors.New("event name can not be empty string")
 	}
+	payload, err := s.eventPayload(payload)
+	if err != nil {
+		return err
+	}
 	s.chaincodeEvent = &pb.ChaincodeEvent{EventName: name, Payload: payload}
 	return nil
 }
@@ -6,7 +6,9 @@ package shim
 import (
 	"crypto/sha256"
 	"encoding/binary"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
 	"github.com/hyperledger/fabric-protos-go/common"
@@ -232,7 +234,15 @@ func TestChaincodeStubGetTxTimestamp(t *testing.T) {
 
 		assert.NoError(t, err)
 		assert.True(t, proto.Equal(ts, tt.ts))
+
+		now, err := stub.Now()
+		assert.NoError(t, err)
+		assert.Equal(t, tt.ts.Seconds, now.Unix())
+		assert.Equal(t, tt.ts.Nanos, int32(now.Nanosecond()))
 	}
+
+	_, err := (&ChaincodeStub{}).Now()
+	assert.EqualError(t, err, "no proposal available to extract the timestamp from")
 }
 
 func TestChaincodeStubHandlers(t *testing.T) {
@@ -269,6 +279,12 @@ func TestChaincodeStubHandlers(t *testing.T) {
 				_, err = s.GetPrivateDataHash("", "key")
 				assert.EqualError(t, err, "collection must not be an empty string")
 
+				var changes []StateChange
+				s.OnChange("k", func(stub ChaincodeStubInterface, change StateChange) error {
+					changes = append(changes, change)
+					return nil
+				})
+
 				err = s.PutState("key", payload)
 				assert.NoError(t, err)
 
@@ -292,6 +308,22 @@ func TestChaincodeStubHandlers(t *testing.T) {
 				assert.NoError(t, err)
 				err = s.DelPrivateData("", "key")
 				assert.EqualError(t, err, "collection must not be an empty string")
+
+				assert.Equal(t, []StateChange{
+					{Key: "key", Value: payload},
+					{Collection: "col", Key: "key", Value: payload},
+					{Key: "key", IsDelete: true},
+					{Collection: "col", Key: "key", IsDelete: true},
+				}, changes)
+
+				expected := &RWSetTracker{}
+				expected.Read("", "key", payload)
+				expected.Read("col", "key", payload)
+				expected.Write("", "key", nil, true)
+				expected.Write("col", "key", nil, true)
+				expected.MetadataWrite("", "key", "mkey", payload)
+				expected.MetadataWrite("col", "key", "mkey", payload)
+				assert.Equal(t, expected.Digest(), s.RWSetDigest())
 			},
 		},
 		{
@@ -321,6 +353,40 @@ func TestChaincodeStubHandlers(t *testing.T) {
 				assert.Equal(t, []byte("metavalue"), resp)
 			},
 		},
+		{
+			name:    "Metadata",
+			resType: peerpb.ChaincodeMessage_RESPONSE,
+			payload: marshalOrPanic(
+				&peerpb.StateMetadataResult{
+					Entries: []*peerpb.StateMetadata{
+						{Metakey: "mkey", Value: []byte("metavalue")},
+						{Metakey: "custom", Value: []byte("customvalue")},
+					},
+				},
+			),
+			testFunc: func(s *ChaincodeStub, h *Handler, t *testing.T, payload []byte) {
+				expected := map[string][]byte{"mkey": []byte("metavalue"), "custom": []byte("customvalue")}
+				md, err := s.GetStateMetadata("key")
+				assert.NoError(t, err)
+				assert.Equal(t, expected, md)
+
+				md, err = s.GetPrivateDataMetadata("col", "key")
+				assert.NoError(t, err)
+				assert.Equal(t, expected, md)
+				_, err = s.GetPrivateDataMetadata("", "key")
+				assert.EqualError(t, err, "collection must not be an empty string")
+
+				assert.NoError(t, s.SetStateMetadataEntry("key", "custom", []byte("value")))
+				assert.NoError(t, s.SetPrivateDataMetadataEntry("col", "key", "custom", []byte("value")))
+				assert.EqualError(t, s.SetStateMetadataEntry("key", "", []byte("value")), "metakey must not be an empty string")
+				assert.EqualError(t, s.SetPrivateDataMetadataEntry("", "key", "custom", []byte("value")), "collection must not be an empty string")
+
+				rwset := &RWSetTracker{}
+				rwset.MetadataWrite("", "key", "custom", []byte("value"))
+				rwset.MetadataWrite("col", "key", "custom", []byte("value"))
+				assert.Equal(t, rwset.Digest(), s.RWSetDigest())
+			},
+		},
 		{
 			name:    "InvokeChaincode",
 			resType: peerpb.ChaincodeMessage_RESPONSE,
@@ -333,11 +399,24 @@ func TestChaincodeStubHandlers(t *testing.T) {
 							Payload: []byte("invokechaincode"),
 						},
 					),
+					ChaincodeEvent: &peerpb.ChaincodeEvent{EventName: "called"},
 				},
 			),
 			testFunc: func(s *ChaincodeStub, h *Handler, t *testing.T, payload []byte) {
 				resp := s.InvokeChaincode("cc", [][]byte{}, "channel")
 				assert.Equal(t, resp.Payload, []byte("invokechaincode"))
+
+				result := s.InvokeChaincodeWithResult("cc", [][]byte{}, "channel")
+				assert.True(t, result.IsOK())
+				assert.Equal(t, []byte("invokechaincode"), result.Response.Payload)
+				assert.Equal(t, &peerpb.ChaincodeEvent{EventName: "called"}, result.Event)
+
+				resp = s.InvokeChaincodeWithTransient("cc", [][]byte{}, "channel", nil)
+				assert.Equal(t, resp.Payload, []byte("invokechaincode"))
+
+				resp = s.InvokeChaincodeWithTransient("cc", [][]byte{}, "channel", map[string][]byte{"key": []byte("value")})
+				assert.Equal(t, int32(ERROR), resp.Status)
+				assert.Equal(t, ErrTransientPropagation.Error(), resp.Message)
 			},
 		},
 		{
@@ -443,6 +522,13 @@ func TestChaincodeStubHandlers(t *testing.T) {
 				}
 				assert.Equal(t, expectedResult, kv)
 
+				sqi, err = s.GetStateByCompositeKeyRange("object", []string{"attr1"}, []string{"attr2"})
+				kv, err = sqi.Next()
+				if err != nil {
+					t.Fatalf("Unexpected error for GetStateByCompositeKeyRange: %s", err)
+				}
+				assert.Equal(t, expectedResult, kv)
+
 				sqi, err = s.GetPrivateDataByPartialCompositeKey("col", "object", []string{"attr1", "attr2"})
 				kv, err = sqi.Next()
 				if err != nil {
@@ -544,6 +630,9 @@ func TestChaincodeStubHandlers(t *testing.T) {
 				_, err = s.GetHistoryForKey("key")
 				assert.EqualError(t, err, string(payload))
 
+				_, err = s.GetHistoryForKeyRange("key", time.Time{}, time.Time{})
+				assert.EqualError(t, err, string(payload))
+
 				resp := s.InvokeChaincode("cc", [][]byte{}, "channel")
 				assert.Equal(t, payload, resp.GetPayload())
 
@@ -557,9 +646,9 @@ func TestChaincodeStubHandlers(t *testing.T) {
 			t.Parallel()
 
 			handler := &Handler{
-				cc:               &mockChaincode{},
-				responseChannels: map[string]chan peerpb.ChaincodeMessage{},
-				state:            ready,
+				cc:     &mockChaincode{},
+				router: NewResponseRouter(),
+				state:  ready,
 			}
 			stub := &ChaincodeStub{
 				ChannelID:                  "channel",
@@ -586,3 +675,128 @@ func TestChaincodeStubHandlers(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitCompositeKeyMalformed(t *testing.T) {
+	for _, key := range []string{"", "\x00", "simple", "\x00type", "\x00type\x00attr", "\x00\xff\x00"} {
+		_, _, err := splitCompositeKey(key)
+		assert.Error(t, err, "key %q", key)
+	}
+
+	objectType, attributes, err := splitCompositeKey("\x00type\x00attr\x00")
+	assert.NoError(t, err)
+	assert.Equal(t, "type", objectType)
+	assert.Equal(t, []string{"attr"}, attributes)
+}
+
+func TestCreateRangeKeysForCompositeKeyRange(t *testing.T) {
+	s := &ChaincodeStub{}
+
+	startKey, endKey, err := s.createRangeKeysForCompositeKeyRange("order", []string{"cust", "2020"}, []string{"cust", "2021"})
+	assert.NoError(t, err)
+	assert.Equal(t, "\x00order\x00cust\x002020\x00", startKey)
+	assert.Equal(t, "\x00order\x00cust\x002021\x00", endKey)
+
+	startKey, endKey, err = s.createRangeKeysForCompositeKeyRange("order", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "\x00order\x00", startKey)
+	assert.Equal(t, "\x00order\x00\U0010ffff", endKey)
+
+	_, _, err = s.createRangeKeysForCompositeKeyRange("order", []string{"\x00"}, nil)
+	assert.Error(t, err)
+	_, _, err = s.createRangeKeysForCompositeKeyRange("order", nil, []string{"\x00"})
+	assert.Error(t, err)
+}
+
+// sliceHistoryIterator is a HistoryQueryIteratorInterface over a slice.
+type sliceHistoryIterator struct {
+	results []*queryresult.KeyModification
+	err     error
+}
+
+func (iter *sliceHistoryIterator) HasNext() bool { return len(iter.results) > 0 }
+func (iter *sliceHistoryIterator) Close() error  { return nil }
+func (iter *sliceHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	if iter.err != nil {
+		return nil, iter.err
+	}
+	km := iter.results[0]
+	iter.results = iter.results[1:]
+	return km, nil
+}
+
+func TestTimeRangeHistoryIterator(t *testing.T) {
+	base := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	history := func() *sliceHistoryIterator {
+		var results []*queryresult.KeyModification
+		for i := 0; i < 5; i++ {
+			ts, _ := ptypes.TimestampProto(base.Add(time.Duration(i) * time.Hour))
+			results = append(results, &queryresult.KeyModification{TxId: string(rune('a' + i)), Timestamp: ts})
+		}
+		return &sliceHistoryIterator{results: results}
+	}
+
+	var tests = []struct {
+		name     string
+		from     time.Time
+		to       time.Time
+		expected string
+	}{
+		{name: "Unbounded", expected: "abcde"},
+		{name: "From", from: base.Add(3 * time.Hour), expected: "de"},
+		{name: "To", to: base.Add(2 * time.Hour), expected: "ab"},
+		{name: "Window", from: base.Add(time.Hour), to: base.Add(3 * time.Hour), expected: "bc"},
+		{name: "Empty", from: base.Add(10 * time.Hour), expected: ""},
+	}
+
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			iter := newTimeRangeHistoryIterator(history(), test.from, test.to)
+			actual := ""
+			for iter.HasNext() {
+				km, err := iter.Next()
+				assert.NoError(t, err)
+				actual += km.TxId
+			}
+			assert.Equal(t, test.expected, actual)
+			_, err := iter.Next()
+			assert.EqualError(t, err, "no such key")
+		})
+	}
+
+	iter := newTimeRangeHistoryIterator(&sliceHistoryIterator{results: []*queryresult.KeyModification{{}}, err: errors.New("boom")}, time.Time{}, time.Time{})
+	assert.True(t, iter.HasNext())
+	_, err := iter.Next()
+	assert.EqualError(t, err, "boom")
+
+	iter = newTimeRangeHistoryIterator(&sliceHistoryIterator{results: []*queryresult.KeyModification{{TxId: "tx"}}}, time.Time{}, time.Time{})
+	assert.True(t, iter.HasNext())
+	_, err = iter.Next()
+	assert.Contains(t, err.Error(), "invalid timestamp in history of transaction tx")
+}
+
+func TestCheckTransientPropagation(t *testing.T) {
+	proposal := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+
+	var tests = []struct {
+		name      string
+		transient map[string][]byte
+		expectErr bool
+	}{
+		{name: "Nil", transient: nil},
+		{name: "Empty", transient: map[string][]byte{}},
+		{name: "Equal", transient: map[string][]byte{"a": []byte("1"), "b": []byte("2")}},
+		{name: "Subset", transient: map[string][]byte{"a": []byte("1")}, expectErr: true},
+		{name: "DifferentValue", transient: map[string][]byte{"a": []byte("1"), "b": []byte("3")}, expectErr: true},
+		{name: "DifferentKey", transient: map[string][]byte{"a": []byte("1"), "c": []byte("2")}, expectErr: true},
+	}
+
+	for _, test := range tests {
+		err := CheckTransientPropagation(proposal, test.transient)
+		if test.expectErr {
+			assert.Equal(t, ErrTransientPropagation, err, test.name)
+		} else {
+			assert.NoError(t, err, test.name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sys provides typed wrappers for querying the system chaincodes of
+// the peer from chaincode: qscc for the ledger, cscc for channels and
+// _lifecycle for chaincode definitions. Whether a system chaincode may be
+// called from chaincode depends on the peer version and configuration.
+package sys
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+)
+
+// System chaincode names.
+const (
+	QSCC      = "qscc"
+	CSCC      = "cscc"
+	Lifecycle = "_lifecycle"
+)
+
+// ChaincodeStubInterface is the subset of the chaincode stub used to invoke
+// system chaincodes.
+type ChaincodeStubInterface interface {
+	// InvokeChaincode invokes chaincodeName with args on channel.
+	InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response
+}
+
+// invoke calls function of chaincode with args and unmarshals the payload of
+// the response into result.
+func invoke(stub ChaincodeStubInterface, chaincode, function string, result proto.Message, args ...[]byte) error {
+	resp := stub.InvokeChaincode(chaincode, append([][]byte{[]byte(function)}, args...), "")
+	if resp.Status != shim.OK {
+		return fmt.Errorf("%s %s failed with status %d: %s", chaincode, function, resp.Status, resp.Message)
+	}
+	if err := proto.Unmarshal(resp.Payload, result); err != nil {
+		return fmt.Errorf("failed to unmarshal response of %s %s: %s", chaincode, function, err)
+	}
+	return nil
+}
+
+// GetChainInfo returns the height and current block hashes of the ledger of
+// channel.
+func GetChainInfo(stub ChaincodeStubInterface, channel string) (*common.BlockchainInfo, error) {
+	info := &common.BlockchainInfo{}
+	if err := invoke(stub, QSCC, "GetChainInfo", info, []byte(channel)); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// GetBlockByNumber returns block number of channel.
+func GetBlockByNumber(stub ChaincodeStubInterface, channel string, number uint64) (*common.Block, error) {
+	block := &common.Block{}
+	if err := invoke(stub, QSCC, "GetBlockByNumber", block, []byte(channel), []byte(strconv.FormatUint(number, 10))); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetBlockByTxID returns the block of channel containing transaction txID.
+func GetBlockByTxID(stub ChaincodeStubInterface, channel, txID string) (*common.Block, error) {
+	block := &common.Block{}
+	if err := invoke(stub, QSCC, "GetBlockByTxID", block, []byte(channel), []byte(txID)); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
+
+// GetTransactionByID returns transaction txID of channel along with its
+// validation code.
+func GetTransactionByID(stub ChaincodeStubInterface, channel, txID string) (*pb.ProcessedTransaction, error) {
+	tx := &pb.ProcessedTransaction{}
+	if err := invoke(stub, QSCC, "GetTransactionByID", tx, []byte(channel), []byte(txID)); err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
+
+// GetChannels returns the channels the peer has joined.
+func GetChannels(stub ChaincodeStubInterface) ([]*pb.ChannelInfo, error) {
+	resp := &pb.ChannelQueryResponse{}
+	if err := invoke(stub, CSCC, "GetChannels", resp); err != nil {
+		return nil, err
+	}
+	return resp.GetChannels(), nil
+}
+
+// GetChaincodeDefinition returns the committed definition of chaincode name
+// on the channel of the transaction.
+func GetChaincodeDefinition(stub ChaincodeStubInterface, name string) (*lifecycle.QueryChaincodeDefinitionResult, error) {
+	args, err := proto.Marshal(&lifecycle.QueryChaincodeDefinitionArgs{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	def := &lifecycle.QueryChaincodeDefinitionResult{}
+	if err := invoke(stub, Lifecycle, "QueryChaincodeDefinition", def, args); err != nil {
+		return nil, err
+	}
+	return def, nil
+}
@@ -0,0 +1,100 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package sys_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/sys"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-protos-go/peer/lifecycle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sysStub answers invocations with response and records their arguments.
+type sysStub struct {
+	response pb.Response
+	calls    []string
+}
+
+func (s *sysStub) InvokeChaincode(chaincodeName string, args [][]byte, channel string) pb.Response {
+	call := []string{chaincodeName}
+	for _, a := range args {
+		call = append(call, string(a))
+	}
+	s.calls = append(s.calls, strings.Join(call, " "))
+	return s.response
+}
+
+func ok(t *testing.T, msg proto.Message) pb.Response {
+	b, err := proto.Marshal(msg)
+	require.NoError(t, err)
+	return shim.Success(b)
+}
+
+func TestQSCC(t *testing.T) {
+	block := &common.Block{Header: &common.BlockHeader{Number: 7}}
+
+	stub := &sysStub{response: ok(t, block)}
+	b, err := sys.GetBlockByNumber(stub, "ch", 7)
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(block, b))
+
+	b, err = sys.GetBlockByTxID(stub, "ch", "tx1")
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(block, b))
+
+	stub.response = ok(t, &common.BlockchainInfo{Height: 8})
+	info, err := sys.GetChainInfo(stub, "ch")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(8), info.Height)
+
+	stub.response = ok(t, &pb.ProcessedTransaction{ValidationCode: int32(pb.TxValidationCode_VALID)})
+	tx, err := sys.GetTransactionByID(stub, "ch", "tx1")
+	require.NoError(t, err)
+	assert.Equal(t, int32(pb.TxValidationCode_VALID), tx.ValidationCode)
+
+	assert.Equal(t, []string{
+		"qscc GetBlockByNumber ch 7",
+		"qscc GetBlockByTxID ch tx1",
+		"qscc GetChainInfo ch",
+		"qscc GetTransactionByID ch tx1",
+	}, stub.calls)
+}
+
+func TestCSCC(t *testing.T) {
+	stub := &sysStub{response: ok(t, &pb.ChannelQueryResponse{Channels: []*pb.ChannelInfo{{ChannelId: "ch"}}})}
+	channels, err := sys.GetChannels(stub)
+	require.NoError(t, err)
+	require.Len(t, channels, 1)
+	assert.Equal(t, "ch", channels[0].ChannelId)
+	assert.Equal(t, []string{"cscc GetChannels"}, stub.calls)
+}
+
+func TestGetChaincodeDefinition(t *testing.T) {
+	stub := &sysStub{response: ok(t, &lifecycle.QueryChaincodeDefinitionResult{Sequence: 2, Version: "1.1"})}
+	def, err := sys.GetChaincodeDefinition(stub, "mycc")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), def.Sequence)
+	assert.Equal(t, "1.1", def.Version)
+
+	args, err := proto.Marshal(&lifecycle.QueryChaincodeDefinitionArgs{Name: "mycc"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"_lifecycle QueryChaincodeDefinition " + string(args)}, stub.calls)
+}
+
+func TestErrors(t *testing.T) {
+	stub := &sysStub{response: shim.Error("access denied")}
+	_, err := sys.GetBlockByNumber(stub, "ch", 1)
+	assert.EqualError(t, err, "qscc GetBlockByNumber failed with status 500: access denied")
+
+	stub.response = shim.Success([]byte("garbage"))
+	_, err = sys.GetChannels(stub)
+	assert.Contains(t, err.Error(), "failed to unmarshal response of cscc GetChannels")
+}
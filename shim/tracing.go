@@ -0,0 +1,119 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TraceParentKey is the key of the W3C trace context of a transaction in
+// the decorations or the transient data of its proposal.
+const TraceParentKey = "traceparent"
+
+// TraceContext identifies the trace a transaction is part of, as started
+// by the client or the gateway submitting the proposal.
+type TraceContext struct {
+	// TraceID is the hex encoded 16 byte ID of the trace.
+	TraceID string
+	// ParentID is the hex encoded 8 byte ID of the span of the caller.
+	ParentID string
+	// Sampled reports whether the caller records the trace.
+	Sampled bool
+}
+
+// String returns the W3C traceparent header of the trace context.
+func (tc TraceContext) String() string {
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceID + "-" + tc.ParentID + "-" + flags
+}
+
+// ParseTraceParent parses a W3C traceparent header, such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceParent(header string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	// future versions may append fields, which version 00 forbids
+	if version == "ff" || version == "00" && len(parts) != 4 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+	if !isLowerHex(version, 2) || !isLowerHex(traceID, 32) || !isLowerHex(parentID, 16) || !isLowerHex(flags, 2) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", header)
+	}
+	if strings.Trim(traceID, "0") == "" || strings.Trim(parentID, "0") == "" {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q: all zero ID", header)
+	}
+	f, _ := hex.DecodeString(flags)
+	return TraceContext{TraceID: traceID, ParentID: parentID, Sampled: f[0]&1 == 1}, nil
+}
+
+func isLowerHex(s string, size int) bool {
+	if len(s) != size {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// GetTraceContext returns the trace context of the transaction of stub,
+// read from the TraceParentKey decoration added by a peer decorator or,
+// failing that, the TraceParentKey transient data set by the client. The
+// peer does not attach tracing metadata to the messages of the chaincode
+// stream, so these are the only places a trace context can come from.
+func GetTraceContext(stub ChaincodeStubInterface) (TraceContext, bool) {
+	if s, ok := stub.(*ChaincodeStub); ok {
+		return s.trace, s.trace.TraceID != ""
+	}
+	return readTraceContext(stub.GetDecorations(), stub.GetTransient)
+}
+
+func readTraceContext(decorations map[string][]byte, transient func() (map[string][]byte, error)) (TraceContext, bool) {
+	if header, ok := decorations[TraceParentKey]; ok {
+		if tc, err := ParseTraceParent(string(header)); err == nil {
+			return tc, true
+		}
+	}
+	if t, err := transient(); err == nil {
+		if header, ok := t[TraceParentKey]; ok {
+			if tc, err := ParseTraceParent(string(header)); err == nil {
+				return tc, true
+			}
+		}
+	}
+	return TraceContext{}, false
+}
+
+// traceTransaction records the trace context of the transaction of stub,
+// if any, for the log records of the handler until forgetTrace is called.
+func (h *Handler) traceTransaction(stub *ChaincodeStub) {
+	if stub.trace.TraceID != "" {
+		h.traces.Store(transactionContextID(stub.ChannelID, stub.TxID), stub.trace.TraceID)
+	}
+}
+
+// forgetTrace forgets the trace context of the transaction txid once it
+// completed.
+func (h *Handler) forgetTrace(channelID, txid string) {
+	h.traces.Delete(transactionContextID(channelID, txid))
+}
+
+// txTag returns the tag identifying the transaction txid in log records:
+// its short ID, followed by the ID of its trace when it has one.
+func (h *Handler) txTag(channelID, txid string) string {
+	if traceID, ok := h.traces.Load(transactionContextID(channelID, txid)); ok {
+		return shorttxid(txid) + " trace=" + traceID.(string)
+	}
+	return shorttxid(txid)
+}
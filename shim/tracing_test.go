@@ -0,0 +1,139 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	testTraceID     = "4bf92f3577b34da6a3ce929d0e0e4736"
+)
+
+func TestParseTraceParent(t *testing.T) {
+	var tests = []struct {
+		header   string
+		expected TraceContext
+		errMsg   string
+	}{
+		{header: testTraceParent, expected: TraceContext{TraceID: testTraceID, ParentID: "00f067aa0ba902b7", Sampled: true}},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", expected: TraceContext{TraceID: testTraceID, ParentID: "00f067aa0ba902b7"}},
+		{header: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", expected: TraceContext{TraceID: testTraceID, ParentID: "00f067aa0ba902b7", Sampled: true}},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", errMsg: "invalid traceparent"},
+		{header: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", errMsg: "invalid traceparent"},
+		{header: "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", errMsg: "invalid traceparent"},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", errMsg: "invalid traceparent"},
+		{header: "00-00000000000000000000000000000000-00f067aa0ba902b7-01", errMsg: "all zero ID"},
+		{header: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01", errMsg: "all zero ID"},
+		{header: "garbage", errMsg: "invalid traceparent"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.header, func(t *testing.T) {
+			tc, err := ParseTraceParent(test.header)
+			if test.errMsg != "" {
+				assert.Contains(t, err.Error(), test.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.expected, tc)
+		})
+	}
+
+	tc, err := ParseTraceParent(testTraceParent)
+	require.NoError(t, err)
+	assert.Equal(t, testTraceParent, tc.String())
+}
+
+func TestGetTraceContext(t *testing.T) {
+	h := &Handler{}
+	stub, err := newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{Decorations: map[string][]byte{TraceParentKey: []byte(testTraceParent)}}, nil)
+	require.NoError(t, err)
+	tc, ok := GetTraceContext(stub)
+	assert.True(t, ok)
+	assert.Equal(t, testTraceID, tc.TraceID)
+
+	// the transient data is read when there is no valid decoration
+	transient := func() (map[string][]byte, error) {
+		return map[string][]byte{TraceParentKey: []byte(testTraceParent)}, nil
+	}
+	tc, ok = readTraceContext(map[string][]byte{TraceParentKey: []byte("garbage")}, transient)
+	assert.True(t, ok)
+	assert.Equal(t, testTraceID, tc.TraceID)
+
+	// stubs of other implementations are read through the interface
+	tc, ok = GetTraceContext(&instrumentTestStub{decorations: map[string][]byte{TraceParentKey: []byte(testTraceParent)}})
+	assert.True(t, ok)
+	assert.Equal(t, testTraceID, tc.TraceID)
+
+	stub, err = newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+	require.NoError(t, err)
+	_, ok = GetTraceContext(stub)
+	assert.False(t, ok)
+}
+
+func TestTracedLogRecords(t *testing.T) {
+	policy := RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}
+	logger := &recordingLogger{}
+	h, _ := newFlakyPeerHandler(t, 1, "service unavailable", []byte("value"), WithRetryPolicy(policy), WithLogger(logger))
+
+	stub, err := newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{Decorations: map[string][]byte{TraceParentKey: []byte(testTraceParent)}}, nil)
+	require.NoError(t, err)
+	h.traceTransaction(stub)
+	_, err = h.handleGetState("", "key", "channel", "txid")
+	assert.NoError(t, err)
+	require.Len(t, logger.lines, 1)
+	assert.Contains(t, logger.lines[0], "[txid trace="+testTraceID+"] retrying GET_STATE")
+
+	h.forgetTrace("channel", "txid")
+	assert.Equal(t, "txid", h.txTag("channel", "txid"))
+}
+
+func TestTracedHandlerError(t *testing.T) {
+	logger := &recordingLogger{}
+	h, err := newChaincodeHandler(&mock.PeerChaincodeStream{}, &mockChaincode{}, WithLogger(logger))
+	require.NoError(t, err)
+
+	msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, ChannelId: "channel", Txid: "txid"}
+	failing := func(msg *peerpb.ChaincodeMessage) (*peerpb.ChaincodeMessage, error) {
+		input := &peerpb.ChaincodeInput{Decorations: map[string][]byte{TraceParentKey: []byte(testTraceParent)}}
+		stub, err := newChaincodeStub(h, msg.ChannelId, msg.Txid, input, nil)
+		require.NoError(t, err)
+		h.traceTransaction(stub)
+		return nil, errors.New("failed to marshal response")
+	}
+	errc := make(chan error, 1)
+	h.handleStubInteraction(failing, msg, errc)
+	require.NoError(t, <-errc)
+
+	assert.Equal(t, []string{"[txid trace=" + testTraceID + "] TRANSACTION failed: failed to marshal response"}, logger.lines)
+	assert.Equal(t, "txid", h.txTag("channel", "txid"), "the trace is forgotten once the transaction completed")
+}
+
+func TestInstrumentTraceID(t *testing.T) {
+	var calls []CallStats
+	stub := Instrument(&instrumentTestStub{
+		queryStub:   &queryStub{},
+		state:       map[string][]byte{},
+		decorations: map[string][]byte{TraceParentKey: []byte(testTraceParent)},
+	}, func(s CallStats) {
+		calls = append(calls, s)
+	})
+	_, err := stub.GetState("key")
+	assert.NoError(t, err)
+	require.Len(t, calls, 1)
+	assert.Equal(t, testTraceID, calls[0].TraceID)
+
+	logger := &recordingLogger{}
+	LogCalls(logger)(CallStats{TxID: "txid1234", TraceID: testTraceID, Method: "GetState", Target: "key"})
+	assert.Equal(t, []string{"[txid1234 trace=" + testTraceID + "] GetState key took 0s, 0 bytes, 0 results: ok"}, logger.lines)
+}
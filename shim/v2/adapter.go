@@ -0,0 +1,251 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	shimv1 "github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// errPrivatePagination is returned for paginated queries of private data,
+// which the peer does not support.
+var errPrivatePagination = errors.New("paginated queries of private data are not supported")
+
+// Adapt returns a version 1 chaincode calling cc with version 2 stubs, to be
+// started with the Start function of version 1.
+func Adapt(cc Chaincode) shimv1.Chaincode {
+	return &adapter{cc: cc}
+}
+
+type adapter struct {
+	cc Chaincode
+}
+
+func (a *adapter) Init(stub shimv1.ChaincodeStubInterface) pb.Response {
+	return response(a.cc.Init(context.Background(), Wrap(stub)))
+}
+
+func (a *adapter) Invoke(stub shimv1.ChaincodeStubInterface) pb.Response {
+	return response(a.cc.Invoke(context.Background(), Wrap(stub)))
+}
+
+func response(payload []byte, err error) pb.Response {
+	if err == nil {
+		return shimv1.Success(payload)
+	}
+	if se, ok := err.(*StatusError); ok {
+		return pb.Response{Status: se.Status, Message: se.Message}
+	}
+	return shimv1.Error(err.Error())
+}
+
+// Wrap returns the version 2 stub of a version 1 stub.
+func Wrap(stub shimv1.ChaincodeStubInterface) Stub {
+	return &stubAdapter{v1: stub}
+}
+
+// Unwrap returns the version 1 stub of a stub returned by Wrap, or nil for
+// other stubs.
+func Unwrap(stub Stub) shimv1.ChaincodeStubInterface {
+	if s, ok := stub.(*stubAdapter); ok {
+		return s.v1
+	}
+	return nil
+}
+
+type stubAdapter struct {
+	v1 shimv1.ChaincodeStubInterface
+}
+
+func (s *stubAdapter) TxID() string {
+	return s.v1.GetTxID()
+}
+
+func (s *stubAdapter) ChannelID() string {
+	return s.v1.GetChannelID()
+}
+
+func (s *stubAdapter) Args() [][]byte {
+	return s.v1.GetArgs()
+}
+
+func (s *stubAdapter) Creator() ([]byte, error) {
+	return s.v1.GetCreator()
+}
+
+func (s *stubAdapter) Transient() (map[string][]byte, error) {
+	return s.v1.GetTransient()
+}
+
+func (s *stubAdapter) Timestamp() (time.Time, error) {
+	ts, err := s.v1.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ptypes.Timestamp(ts)
+}
+
+func (s *stubAdapter) Get(ctx context.Context, key string, opts ReadOptions) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var value []byte
+	var err error
+	if opts.Collection == "" {
+		value, err = s.v1.GetState(key)
+	} else {
+		value, err = s.v1.GetPrivateData(opts.Collection, key)
+	}
+	if err != nil {
+		return nil, &OpError{Op: "Get", Collection: opts.Collection, Key: key, Err: err}
+	}
+	if value == nil {
+		return nil, ErrNotFound
+	}
+	return value, nil
+}
+
+func (s *stubAdapter) Put(ctx context.Context, key string, value []byte, opts WriteOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	if opts.Collection == "" {
+		err = s.v1.PutState(key, value)
+	} else {
+		err = s.v1.PutPrivateData(opts.Collection, key, value)
+	}
+	if err != nil {
+		return &OpError{Op: "Put", Collection: opts.Collection, Key: key, Err: err}
+	}
+	return nil
+}
+
+func (s *stubAdapter) Delete(ctx context.Context, key string, opts WriteOptions) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	var err error
+	if opts.Collection == "" {
+		err = s.v1.DelState(key)
+	} else {
+		err = s.v1.DelPrivateData(opts.Collection, key)
+	}
+	if err != nil {
+		return &OpError{Op: "Delete", Collection: opts.Collection, Key: key, Err: err}
+	}
+	return nil
+}
+
+func (s *stubAdapter) Range(ctx context.Context, q RangeQuery) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	iter, metadata, err := s.rangeQuery(q)
+	if err != nil {
+		return nil, &OpError{Op: "Range", Collection: q.Collection, Err: err}
+	}
+	return &iterator{v1: iter, bookmark: metadata.GetBookmark()}, nil
+}
+
+func (s *stubAdapter) rangeQuery(q RangeQuery) (shimv1.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	composite := q.ObjectType != ""
+	if composite && (q.StartKey != "" || q.EndKey != "") {
+		return nil, nil, errors.New("a range query selects either a key range or composite keys")
+	}
+	if q.PageSize > 0 && q.Collection != "" {
+		return nil, nil, errPrivatePagination
+	}
+
+	switch {
+	case q.PageSize > 0 && composite:
+		return s.v1.GetStateByPartialCompositeKeyWithPagination(q.ObjectType, q.Attributes, q.PageSize, q.Bookmark)
+	case q.PageSize > 0:
+		return s.v1.GetStateByRangeWithPagination(q.StartKey, q.EndKey, q.PageSize, q.Bookmark)
+	case q.Collection != "" && composite:
+		iter, err := s.v1.GetPrivateDataByPartialCompositeKey(q.Collection, q.ObjectType, q.Attributes)
+		return iter, nil, err
+	case q.Collection != "":
+		iter, err := s.v1.GetPrivateDataByRange(q.Collection, q.StartKey, q.EndKey)
+		return iter, nil, err
+	case composite:
+		iter, err := s.v1.GetStateByPartialCompositeKey(q.ObjectType, q.Attributes)
+		return iter, nil, err
+	default:
+		iter, err := s.v1.GetStateByRange(q.StartKey, q.EndKey)
+		return iter, nil, err
+	}
+}
+
+func (s *stubAdapter) Query(ctx context.Context, q RichQuery) (Iterator, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	var iter shimv1.StateQueryIteratorInterface
+	var metadata *pb.QueryResponseMetadata
+	var err error
+	switch {
+	case q.PageSize > 0 && q.Collection != "":
+		err = errPrivatePagination
+	case q.PageSize > 0:
+		iter, metadata, err = s.v1.GetQueryResultWithPagination(q.Query, q.PageSize, q.Bookmark)
+	case q.Collection != "":
+		iter, err = s.v1.GetPrivateDataQueryResult(q.Collection, q.Query)
+	default:
+		iter, err = s.v1.GetQueryResult(q.Query)
+	}
+	if err != nil {
+		return nil, &OpError{Op: "Query", Collection: q.Collection, Err: err}
+	}
+	return &iterator{v1: iter, bookmark: metadata.GetBookmark()}, nil
+}
+
+func (s *stubAdapter) Invoke(ctx context.Context, call Call) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	resp := s.v1.InvokeChaincode(call.Chaincode, call.Args, call.Channel)
+	if resp.Status >= shimv1.ERRORTHRESHOLD {
+		return nil, &InvokeError{Chaincode: call.Chaincode, Status: resp.Status, Message: resp.Message}
+	}
+	return resp.Payload, nil
+}
+
+func (s *stubAdapter) SetEvent(name string, payload []byte) error {
+	return s.v1.SetEvent(name, payload)
+}
+
+// iterator is the Iterator of a version 1 iterator.
+type iterator struct {
+	v1       shimv1.StateQueryIteratorInterface
+	bookmark string
+}
+
+func (i *iterator) Next(ctx context.Context) (*queryresult.KV, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if !i.v1.HasNext() {
+		return nil, ErrDone
+	}
+	kv, err := i.v1.Next()
+	if err != nil {
+		return nil, &OpError{Op: "Next", Err: err}
+	}
+	return kv, nil
+}
+
+func (i *iterator) Bookmark() string {
+	return i.bookmark
+}
+
+func (i *iterator) Close() error {
+	return i.v1.Close()
+}
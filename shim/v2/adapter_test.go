@@ -0,0 +1,170 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes/timestamp"
+	shimv1 "github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/v2"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// assets is a chaincode written against version 2 of the API.
+type assets struct{}
+
+func (assets) Init(ctx context.Context, stub shim.Stub) ([]byte, error) {
+	return nil, nil
+}
+
+func (assets) Invoke(ctx context.Context, stub shim.Stub) ([]byte, error) {
+	args := stub.Args()
+	switch string(args[0]) {
+	case "put":
+		return nil, stub.Put(ctx, string(args[1]), args[2], shim.WriteOptions{})
+	case "get":
+		value, err := stub.Get(ctx, string(args[1]), shim.ReadOptions{})
+		if err == shim.ErrNotFound {
+			return nil, &shim.StatusError{Status: 404, Message: "asset not found"}
+		}
+		return value, err
+	default:
+		return nil, errors.New("unknown function")
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	stub := shimtest.NewMockStub("assets", shim.Adapt(assets{}))
+
+	assert.Equal(t, int32(shimv1.OK), stub.MockInit("tx1", nil).Status)
+	assert.Equal(t, int32(shimv1.OK), stub.MockInvoke("tx2", [][]byte{[]byte("put"), []byte("a"), []byte("1")}).Status)
+	assert.Equal(t, shimv1.Success([]byte("1")), stub.MockInvoke("tx3", [][]byte{[]byte("get"), []byte("a")}))
+	assert.Equal(t, pb.Response{Status: 404, Message: "asset not found"}, stub.MockInvoke("tx4", [][]byte{[]byte("get"), []byte("b")}))
+	assert.Equal(t, shimv1.Error("unknown function"), stub.MockInvoke("tx5", [][]byte{[]byte("bad")}))
+}
+
+func newStub(t *testing.T) (*shimtest.MockStub, shim.Stub) {
+	mock := shimtest.NewMockStub("v2", nil)
+	mock.MockTransactionStart("setup")
+	for _, key := range []string{"a", "b", "c"} {
+		require.NoError(t, mock.PutState(key, []byte(key)))
+	}
+	for _, color := range []string{"blue", "red"} {
+		key, err := mock.CreateCompositeKey("color", []string{color})
+		require.NoError(t, err)
+		require.NoError(t, mock.PutState(key, []byte(color)))
+	}
+	mock.MockTransactionEnd("setup")
+	mock.MockTransactionStart("tx")
+	return mock, shim.Wrap(mock)
+}
+
+func collect(t *testing.T, iter shim.Iterator) []string {
+	ctx := context.Background()
+	var values []string
+	for {
+		kv, err := iter.Next(ctx)
+		if err == shim.ErrDone {
+			break
+		}
+		require.NoError(t, err)
+		values = append(values, string(kv.Value))
+	}
+	assert.NoError(t, iter.Close())
+	return values
+}
+
+func TestStub(t *testing.T) {
+	ctx := context.Background()
+	mock, stub := newStub(t)
+	assert.Equal(t, shimv1.ChaincodeStubInterface(mock), shim.Unwrap(stub))
+	assert.Equal(t, "tx", stub.TxID())
+
+	mock.TxTimestamp = &timestamp.Timestamp{Seconds: 1}
+	ts, err := stub.Timestamp()
+	require.NoError(t, err)
+	assert.Equal(t, time.Unix(1, 0).UTC(), ts)
+
+	value, err := stub.Get(ctx, "a", shim.ReadOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("a"), value)
+	_, err = stub.Get(ctx, "missing", shim.ReadOptions{})
+	assert.Equal(t, shim.ErrNotFound, err)
+
+	require.NoError(t, stub.Put(ctx, "d", []byte("d"), shim.WriteOptions{}))
+	require.NoError(t, stub.Put(ctx, "secret", []byte("s"), shim.WriteOptions{Collection: "private"}))
+	value, err = stub.Get(ctx, "secret", shim.ReadOptions{Collection: "private"})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("s"), value)
+	require.NoError(t, stub.Delete(ctx, "d", shim.WriteOptions{}))
+
+	err = stub.Delete(ctx, "secret", shim.WriteOptions{Collection: "private"})
+	assert.IsType(t, &shim.OpError{}, err)
+	assert.EqualError(t, err, "Delete [private/secret] failed: Not Implemented")
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = stub.Get(canceled, "a", shim.ReadOptions{})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, context.Canceled, stub.Put(canceled, "a", nil, shim.WriteOptions{}))
+}
+
+func TestStubRange(t *testing.T) {
+	ctx := context.Background()
+	_, stub := newStub(t)
+
+	iter, err := stub.Range(ctx, shim.RangeQuery{StartKey: "a", EndKey: "c"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, collect(t, iter))
+	assert.Equal(t, "", iter.Bookmark())
+
+	iter, err = stub.Range(ctx, shim.RangeQuery{ObjectType: "color"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blue", "red"}, collect(t, iter))
+
+	iter, err = stub.Range(ctx, shim.RangeQuery{PageSize: 2})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, collect(t, iter))
+	assert.Equal(t, "c", iter.Bookmark())
+	iter, err = stub.Range(ctx, shim.RangeQuery{PageSize: 2, Bookmark: iter.Bookmark()})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"c"}, collect(t, iter))
+	assert.Equal(t, "", iter.Bookmark())
+
+	iter, err = stub.Range(ctx, shim.RangeQuery{ObjectType: "color", PageSize: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"blue"}, collect(t, iter))
+	assert.NotEmpty(t, iter.Bookmark())
+
+	_, err = stub.Range(ctx, shim.RangeQuery{StartKey: "a", ObjectType: "color"})
+	assert.EqualError(t, err, "Range failed: a range query selects either a key range or composite keys")
+	_, err = stub.Range(ctx, shim.RangeQuery{Collection: "private", PageSize: 2})
+	assert.EqualError(t, err, "Range [private] failed: paginated queries of private data are not supported")
+	_, err = stub.Query(ctx, shim.RichQuery{Collection: "private", Query: "{}", PageSize: 2})
+	assert.EqualError(t, err, "Query [private] failed: paginated queries of private data are not supported")
+}
+
+func TestStubInvoke(t *testing.T) {
+	ctx := context.Background()
+	mock, stub := newStub(t)
+	other := shimtest.NewMockStub("assets", shim.Adapt(assets{}))
+	mock.MockPeerChaincode("assets", other, "")
+
+	_, err := stub.Invoke(ctx, shim.Call{Chaincode: "assets", Args: [][]byte{[]byte("put"), []byte("a"), []byte("1")}})
+	require.NoError(t, err)
+	payload, err := stub.Invoke(ctx, shim.Call{Chaincode: "assets", Args: [][]byte{[]byte("get"), []byte("a")}})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("1"), payload)
+
+	_, err = stub.Invoke(ctx, shim.Call{Chaincode: "assets", Args: [][]byte{[]byte("get"), []byte("b")}})
+	assert.Equal(t, &shim.InvokeError{Chaincode: "assets", Status: 404, Message: "asset not found"}, err)
+	assert.EqualError(t, err, "chaincode assets returned status 404: asset not found")
+}
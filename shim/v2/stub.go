@@ -0,0 +1,207 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package shim is version 2 of the API of chaincode: a smaller Stub
+// interface whose methods take a context and options structs, and return
+// typed errors. It is implemented by an adapter of the stub of version 1,
+// so chaincode written against it is started with the shim package of
+// version 1:
+//
+//	import (
+//		shimv1 "github.com/hyperledger/fabric-chaincode-go/shim"
+//		"github.com/hyperledger/fabric-chaincode-go/shim/v2"
+//	)
+//
+//	func (c *assets) Invoke(ctx context.Context, stub shim.Stub) ([]byte, error) {
+//		value, err := stub.Get(ctx, "asset1", shim.ReadOptions{})
+//		...
+//	}
+//
+//	err := shimv1.Start(shim.Adapt(&assets{}))
+//
+// Features of version 1 missing here remain available through the
+// version 1 stub returned by Unwrap.
+package shim
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+)
+
+var (
+	// ErrNotFound is returned by Get for keys which do not exist.
+	ErrNotFound = errors.New("key not found")
+	// ErrDone is returned by the Next method of iterators after the last
+	// result.
+	ErrDone = errors.New("no more results")
+)
+
+// Chaincode is the interface of chaincode written against version 2 of the
+// API. The payload returned by Init or Invoke is the payload of a
+// successful response; an error fails the transaction, with the status of
+// a StatusError or status 500 otherwise.
+type Chaincode interface {
+	Init(ctx context.Context, stub Stub) ([]byte, error)
+	Invoke(ctx context.Context, stub Stub) ([]byte, error)
+}
+
+// Stub is the interface of chaincode to the ledger and the transaction it
+// executes. Methods taking a context return its error without calling the
+// peer once it is done.
+type Stub interface {
+	// TxID returns the ID of the transaction.
+	TxID() string
+	// ChannelID returns the ID of the channel of the transaction.
+	ChannelID() string
+	// Args returns the arguments of the transaction, the function name
+	// first.
+	Args() [][]byte
+	// Creator returns the serialized identity of the client which
+	// submitted the transaction.
+	Creator() ([]byte, error)
+	// Transient returns the transient data of the proposal.
+	Transient() (map[string][]byte, error)
+	// Timestamp returns the timestamp of the transaction, set by the
+	// client and identical on every endorser.
+	Timestamp() (time.Time, error)
+
+	// Get returns the value of key, or ErrNotFound.
+	Get(ctx context.Context, key string, opts ReadOptions) ([]byte, error)
+	// Put writes value to key.
+	Put(ctx context.Context, key string, value []byte, opts WriteOptions) error
+	// Delete deletes key.
+	Delete(ctx context.Context, key string, opts WriteOptions) error
+	// Range returns an iterator over the keys selected by query.
+	Range(ctx context.Context, query RangeQuery) (Iterator, error)
+	// Query returns an iterator over the results of a rich query.
+	Query(ctx context.Context, query RichQuery) (Iterator, error)
+
+	// Invoke calls another chaincode and returns the payload of its
+	// response, or an InvokeError if the response is not successful.
+	Invoke(ctx context.Context, call Call) ([]byte, error)
+	// SetEvent sets the event of the transaction.
+	SetEvent(name string, payload []byte) error
+}
+
+// ReadOptions are the options of reads.
+type ReadOptions struct {
+	// Collection is the private data collection to read, or empty for the
+	// public state.
+	Collection string
+}
+
+// WriteOptions are the options of writes.
+type WriteOptions struct {
+	// Collection is the private data collection to write, or empty for the
+	// public state.
+	Collection string
+}
+
+// RangeQuery selects the keys of a range: either the simple keys between
+// StartKey (inclusive) and EndKey (exclusive), empty keys being unbounded,
+// or the composite keys of ObjectType starting with Attributes.
+type RangeQuery struct {
+	// Collection is the private data collection to query, or empty for the
+	// public state.
+	Collection string
+
+	StartKey string
+	EndKey   string
+
+	ObjectType string
+	Attributes []string
+
+	// PageSize, if positive, limits the results to a page starting at
+	// Bookmark. Paginated queries are only supported on the public state.
+	PageSize int32
+	Bookmark string
+}
+
+// RichQuery is a rich query of a CouchDB state database.
+type RichQuery struct {
+	// Collection is the private data collection to query, or empty for the
+	// public state.
+	Collection string
+	// Query is the CouchDB query.
+	Query string
+
+	// PageSize, if positive, limits the results to a page starting at
+	// Bookmark. Paginated queries are only supported on the public state.
+	PageSize int32
+	Bookmark string
+}
+
+// Call is a call to another chaincode.
+type Call struct {
+	Chaincode string
+	// Channel is the channel of the chaincode, or empty for the channel of
+	// the transaction.
+	Channel string
+	Args    [][]byte
+}
+
+// Iterator iterates over the results of a query.
+type Iterator interface {
+	// Next returns the next result, or ErrDone after the last one.
+	Next(ctx context.Context) (*queryresult.KV, error)
+	// Bookmark returns the bookmark of the next page of a paginated query,
+	// which is empty after the last page and for queries without pages.
+	Bookmark() string
+	// Close releases the iterator.
+	Close() error
+}
+
+// OpError is the error of an operation on the ledger.
+type OpError struct {
+	// Op is the name of the method of Stub which failed.
+	Op         string
+	Collection string
+	Key        string
+	Err        error
+}
+
+func (e *OpError) Error() string {
+	target := e.Key
+	switch {
+	case e.Collection != "" && e.Key != "":
+		target = e.Collection + "/" + e.Key
+	case e.Collection != "":
+		target = e.Collection
+	}
+	if target == "" {
+		return fmt.Sprintf("%s failed: %s", e.Op, e.Err)
+	}
+	return fmt.Sprintf("%s [%s] failed: %s", e.Op, target, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// InvokeError is returned by Invoke when the called chaincode does not
+// return a successful response.
+type InvokeError struct {
+	Chaincode string
+	Status    int32
+	Message   string
+}
+
+func (e *InvokeError) Error() string {
+	return fmt.Sprintf("chaincode %s returned status %d: %s", e.Chaincode, e.Status, e.Message)
+}
+
+// StatusError is returned by chaincode to fail a transaction with Status,
+// such as 404 for a missing asset.
+type StatusError struct {
+	Status  int32
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
@@ -0,0 +1,198 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// DefaultWatchdogInterval is the interval at which the watchdog samples
+// the goroutines and heap of the process when WatchdogLimits.Interval is
+// zero.
+const DefaultWatchdogInterval = 10 * time.Second
+
+// WatchdogLimits are the thresholds of the watchdog set with WithWatchdog.
+// Zero limits are not enforced.
+type WatchdogLimits struct {
+	// MaxGoroutines is the maximum number of goroutines of the process.
+	MaxGoroutines int
+	// MaxHeapBytes is the maximum number of bytes of allocated heap
+	// objects.
+	MaxHeapBytes uint64
+	// MaxInFlight is the maximum number of Init and Invoke transactions
+	// being executed at once.
+	MaxInFlight int
+	// Interval is the interval at which the goroutines and heap are
+	// sampled; zero means DefaultWatchdogInterval. The number of
+	// transactions in flight is checked for every transaction.
+	Interval time.Duration
+	// Reject makes the shim answer new transactions with an OverloadError
+	// while a limit is exceeded, rather than only logging a warning.
+	Reject bool
+}
+
+// OverloadError is returned for the transactions rejected by the watchdog.
+type OverloadError struct {
+	// Resource is "goroutines", "heap_bytes" or "in_flight".
+	Resource string
+	Value    uint64
+	Max      uint64
+}
+
+func (e *OverloadError) Error() string {
+	return fmt.Sprintf("chaincode overloaded: %s %d exceeds maximum of %d", e.Resource, e.Value, e.Max)
+}
+
+// WithWatchdog monitors the goroutines, heap and transactions in flight of
+// the chaincode process, logging a warning with the exceeded limits when
+// one is crossed and, if limits.Reject is set, refusing new transactions
+// until the usage is back under the limits. This keeps a chaincode
+// overloaded by the transactions of one channel from crashing and failing
+// the transactions of the other channels served by the same process. The
+// watchdog is shared by the streams of WithStreams.
+func WithWatchdog(limits WatchdogLimits) Option {
+	if limits.MaxGoroutines < 0 || limits.MaxInFlight < 0 || limits.Interval < 0 {
+		return func(*Handler) error { return errors.New("watchdog limits must not be negative") }
+	}
+	if limits.Interval == 0 {
+		limits.Interval = DefaultWatchdogInterval
+	}
+	w := &watchdog{limits: limits, sample: sampleRuntime, sampler: newLogSampler(DefaultLogSampling)}
+	return func(h *Handler) error {
+		h.watchdog = w
+		w.start(h.logf)
+		return nil
+	}
+}
+
+// watchdog checks the usage of the process against its limits.
+type watchdog struct {
+	limits WatchdogLimits
+	// sample returns the number of goroutines and the allocated heap
+	// bytes of the process.
+	sample func() (int, uint64)
+
+	once sync.Once
+	logf func(format string, v ...interface{})
+	// sampler limits the warnings logged for each transaction over the
+	// limit of transactions in flight.
+	sampler *logSampler
+
+	mutex    sync.Mutex
+	inFlight int
+	// sampled are the limits exceeded by the last sample of the
+	// goroutines and heap.
+	sampled []*OverloadError
+}
+
+func sampleRuntime() (int, uint64) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return runtime.NumGoroutine(), stats.HeapAlloc
+}
+
+// start samples the usage of the process every interval from the first
+// call on, logging with logf.
+func (w *watchdog) start(logf func(format string, v ...interface{})) {
+	w.once.Do(func() {
+		w.logf = logf
+		if w.limits.MaxGoroutines == 0 && w.limits.MaxHeapBytes == 0 {
+			return
+		}
+		go func() {
+			for range time.Tick(w.limits.Interval) {
+				w.check()
+			}
+		}()
+	})
+}
+
+// check samples the goroutines and heap of the process and logs the
+// limits they exceed.
+func (w *watchdog) check() {
+	goroutines, heap := w.sample()
+	var exceeded []*OverloadError
+	if max := w.limits.MaxGoroutines; max > 0 && goroutines > max {
+		exceeded = append(exceeded, &OverloadError{Resource: "goroutines", Value: uint64(goroutines), Max: uint64(max)})
+	}
+	if max := w.limits.MaxHeapBytes; max > 0 && heap > max {
+		exceeded = append(exceeded, &OverloadError{Resource: "heap_bytes", Value: heap, Max: max})
+	}
+
+	w.mutex.Lock()
+	recovered := len(w.sampled) > 0 && len(exceeded) == 0
+	w.sampled = exceeded
+	inFlight := w.inFlight
+	w.mutex.Unlock()
+
+	switch {
+	case len(exceeded) > 0:
+		var names []string
+		for _, e := range exceeded {
+			names = append(names, e.Resource)
+		}
+		w.logf("watchdog: limits exceeded exceeded=%s goroutines=%d heap_bytes=%d in_flight=%d rejecting=%t", strings.Join(names, ","), goroutines, heap, inFlight, w.limits.Reject)
+	case recovered:
+		w.logf("watchdog: usage back under limits goroutines=%d heap_bytes=%d in_flight=%d", goroutines, heap, inFlight)
+	}
+}
+
+// admit counts a new transaction in flight, or returns the OverloadError
+// rejecting it. The transactions admitted must be released with done.
+func (w *watchdog) admit() error {
+	w.mutex.Lock()
+	var overload *OverloadError
+	if len(w.sampled) > 0 {
+		overload = w.sampled[0]
+	}
+	if max := w.limits.MaxInFlight; overload == nil && max > 0 && w.inFlight >= max {
+		overload = &OverloadError{Resource: "in_flight", Value: uint64(w.inFlight + 1), Max: uint64(max)}
+	}
+	if overload == nil || !w.limits.Reject {
+		w.inFlight++
+	}
+	w.mutex.Unlock()
+
+	if overload == nil {
+		return nil
+	}
+	if overload.Resource == "in_flight" {
+		w.sampler.logf(w.logf, "watchdog in_flight", "watchdog: limits exceeded exceeded=in_flight in_flight=%d rejecting=%t", overload.Value, w.limits.Reject)
+	}
+	if w.limits.Reject {
+		return overload
+	}
+	return nil
+}
+
+// done releases a transaction admitted by admit.
+func (w *watchdog) done() {
+	w.mutex.Lock()
+	w.inFlight--
+	w.mutex.Unlock()
+}
+
+// dispatchTransaction dispatches the execution of the transaction msg by
+// handler, unless the watchdog rejects it.
+func (h *Handler) dispatchTransaction(msg *pb.ChaincodeMessage, handler stubHandlerFunc, errc chan error) error {
+	if h.watchdog == nil {
+		h.dispatch(msg.ChannelId, func() { h.handleStubInteraction(handler, msg, errc) })
+		return nil
+	}
+	if err := h.watchdog.admit(); err != nil {
+		return h.rejectMessage(msg, err, errc)
+	}
+	h.dispatch(msg.ChannelId, func() {
+		defer h.watchdog.done()
+		h.handleStubInteraction(handler, msg, errc)
+	})
+	return nil
+}
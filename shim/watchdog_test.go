@@ -0,0 +1,113 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim/internal/mock"
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWatchdogInvalid(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithWatchdog(WatchdogLimits{MaxInFlight: -1}))
+	assert.EqualError(t, err, "watchdog limits must not be negative")
+}
+
+func newTestWatchdog(limits WatchdogLimits, goroutines *int, heap *uint64) (*watchdog, *recordingLogger) {
+	logger := &recordingLogger{}
+	w := &watchdog{
+		limits:  limits,
+		sample:  func() (int, uint64) { return *goroutines, *heap },
+		sampler: newLogSampler(DefaultLogSampling),
+		logf:    logger.Printf,
+	}
+	return w, logger
+}
+
+func TestWatchdogCheck(t *testing.T) {
+	for _, reject := range []bool{false, true} {
+		goroutines, heap := 10, uint64(100)
+		w, logger := newTestWatchdog(WatchdogLimits{MaxGoroutines: 20, MaxHeapBytes: 1000, Reject: reject}, &goroutines, &heap)
+
+		w.check()
+		assert.NoError(t, w.admit())
+		assert.Empty(t, logger.lines)
+
+		goroutines, heap = 30, 2000
+		w.check()
+		err := w.admit()
+		if reject {
+			assert.Equal(t, &OverloadError{Resource: "goroutines", Value: 30, Max: 20}, err)
+			assert.EqualError(t, err, "chaincode overloaded: goroutines 30 exceeds maximum of 20")
+		} else {
+			assert.NoError(t, err)
+		}
+
+		goroutines, heap = 10, 100
+		w.check()
+		assert.NoError(t, w.admit())
+
+		// the transaction rejected is not in flight
+		inFlight := 2
+		if reject {
+			inFlight = 1
+		}
+		assert.Equal(t, []string{
+			fmt.Sprintf("watchdog: limits exceeded exceeded=goroutines,heap_bytes goroutines=30 heap_bytes=2000 in_flight=1 rejecting=%t", reject),
+			fmt.Sprintf("watchdog: usage back under limits goroutines=10 heap_bytes=100 in_flight=%d", inFlight),
+		}, logger.lines)
+	}
+}
+
+func TestWatchdogInFlight(t *testing.T) {
+	goroutines, heap := 0, uint64(0)
+	w, logger := newTestWatchdog(WatchdogLimits{MaxInFlight: 1, Reject: true}, &goroutines, &heap)
+
+	assert.NoError(t, w.admit())
+	assert.EqualError(t, w.admit(), "chaincode overloaded: in_flight 2 exceeds maximum of 1")
+	assert.Equal(t, 1, w.inFlight)
+	w.done()
+	assert.NoError(t, w.admit())
+	assert.Equal(t, []string{"watchdog: limits exceeded exceeded=in_flight in_flight=2 rejecting=true"}, logger.lines)
+
+	// without rejection, transactions over the limit are counted
+	w, _ = newTestWatchdog(WatchdogLimits{MaxInFlight: 1}, &goroutines, &heap)
+	assert.NoError(t, w.admit())
+	assert.NoError(t, w.admit())
+	assert.Equal(t, 2, w.inFlight)
+}
+
+func TestHandleReadyWatchdog(t *testing.T) {
+	stream := &mock.PeerChaincodeStream{}
+	cc := &mockChaincode{}
+	h, err := newChaincodeHandler(stream, cc, WithWatchdog(WatchdogLimits{MaxInFlight: 1, Reject: true}))
+	require.NoError(t, err)
+	h.state = ready
+	assert.Equal(t, "goroutines=0,heap_bytes=0,in_flight=1,reject=true", h.features()["watchdog"])
+
+	input, err := proto.Marshal(&peerpb.ChaincodeInput{Args: [][]byte{[]byte("fn")}})
+	require.NoError(t, err)
+	msg := &peerpb.ChaincodeMessage{Type: peerpb.ChaincodeMessage_TRANSACTION, ChannelId: "channel", Txid: "txid", Payload: input}
+
+	require.NoError(t, h.watchdog.admit())
+	errc := make(chan error, 1)
+	require.NoError(t, h.handleReady(msg, errc))
+	require.NoError(t, <-errc)
+	require.Equal(t, 1, stream.SendCallCount())
+	resp := stream.SendArgsForCall(0)
+	assert.Equal(t, peerpb.ChaincodeMessage_ERROR, resp.Type)
+	assert.Equal(t, "chaincode overloaded: in_flight 2 exceeds maximum of 1", string(resp.Payload))
+	assert.False(t, cc.invokeCalled)
+
+	h.watchdog.done()
+	require.NoError(t, h.handleReady(msg, errc))
+	require.NoError(t, <-errc)
+	assert.Equal(t, peerpb.ChaincodeMessage_COMPLETED, stream.SendArgsForCall(1).Type)
+	assert.True(t, cc.invokeCalled)
+}
@@ -0,0 +1,131 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// WriteLimits are guardrails on the writes of the transactions of a
+// chaincode, typically imposed by the platform hosting it. Zero values mean
+// no limit.
+type WriteLimits struct {
+	// MaxValueSize is the maximum size in bytes of a value written with
+	// PutState or PutPrivateData, or of a validation parameter set with
+	// SetStateValidationParameter or SetPrivateDataValidationParameter.
+	MaxValueSize int
+	// MaxKeysPerTx is the maximum number of distinct keys a transaction
+	// writes or deletes, counting the keys of each collection apart.
+	MaxKeysPerTx int
+	// BannedPrefixes are prefixes of the keys the chaincode must not write
+	// or delete.
+	BannedPrefixes []string
+}
+
+// ValueTooLargeError is returned when writing a value larger than the
+// MaxValueSize of the WriteLimits.
+type ValueTooLargeError struct {
+	Key  string
+	Size int
+	Max  int
+}
+
+func (e *ValueTooLargeError) Error() string {
+	return fmt.Sprintf("value of key [%s] is %d bytes, exceeding the maximum of %d bytes", e.Key, e.Size, e.Max)
+}
+
+// TooManyKeysError is returned when a transaction writes more distinct keys
+// than the MaxKeysPerTx of the WriteLimits.
+type TooManyKeysError struct {
+	Key string
+	Max int
+}
+
+func (e *TooManyKeysError) Error() string {
+	return fmt.Sprintf("writing key [%s] exceeds the maximum of %d keys written per transaction", e.Key, e.Max)
+}
+
+// BannedKeyError is returned when writing a key starting with one of the
+// BannedPrefixes of the WriteLimits.
+type BannedKeyError struct {
+	Key    string
+	Prefix string
+}
+
+func (e *BannedKeyError) Error() string {
+	return fmt.Sprintf("key [%s] has the banned prefix [%s]", e.Key, e.Prefix)
+}
+
+// WithWriteLimits sets limits on the writes of transactions. PutState,
+// DelState, PutPrivateData, DelPrivateData and the functions setting
+// validation parameters return a ValueTooLargeError, TooManyKeysError or
+// BannedKeyError instead of sending a write exceeding a limit to the peer.
+func WithWriteLimits(limits WriteLimits) Option {
+	return func(h *Handler) error {
+		if limits.MaxValueSize < 0 || limits.MaxKeysPerTx < 0 {
+			return errors.New("write limits must not be negative")
+		}
+		for _, prefix := range limits.BannedPrefixes {
+			if prefix == "" {
+				return errors.New("banned key prefixes must not be empty strings")
+			}
+		}
+		h.writeLimits = &limits
+		return nil
+	}
+}
+
+// writeGuard enforces the write limits on the writes of a transaction.
+type writeGuard struct {
+	limits *WriteLimits
+
+	mutex sync.Mutex
+	// keys are the keys written by the transaction, by collection.
+	keys map[string]map[string]bool
+}
+
+func newWriteGuard(limits *WriteLimits) *writeGuard {
+	return &writeGuard{limits: limits, keys: map[string]map[string]bool{}}
+}
+
+// check returns an error if writing value to key of collection exceeds a
+// limit; a nil value is a delete. Otherwise the key is counted as written.
+// A nil guard has no limits.
+func (g *writeGuard) check(collection, key string, value []byte) error {
+	if g == nil {
+		return nil
+	}
+	for _, prefix := range g.limits.BannedPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return &BannedKeyError{Key: key, Prefix: prefix}
+		}
+	}
+	if g.limits.MaxValueSize > 0 && len(value) > g.limits.MaxValueSize {
+		return &ValueTooLargeError{Key: key, Size: len(value), Max: g.limits.MaxValueSize}
+	}
+	if g.limits.MaxKeysPerTx <= 0 {
+		return nil
+	}
+
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.keys[collection][key] {
+		return nil
+	}
+	count := 0
+	for _, keys := range g.keys {
+		count += len(keys)
+	}
+	if count >= g.limits.MaxKeysPerTx {
+		return &TooManyKeysError{Key: key, Max: g.limits.MaxKeysPerTx}
+	}
+	if g.keys[collection] == nil {
+		g.keys[collection] = map[string]bool{}
+	}
+	g.keys[collection][key] = true
+	return nil
+}
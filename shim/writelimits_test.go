@@ -0,0 +1,88 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shim
+
+import (
+	"testing"
+
+	peerpb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithWriteLimits(t *testing.T) {
+	_, err := newChaincodeHandler(nil, &mockChaincode{}, WithWriteLimits(WriteLimits{MaxKeysPerTx: -1}))
+	assert.EqualError(t, err, "write limits must not be negative")
+	_, err = newChaincodeHandler(nil, &mockChaincode{}, WithWriteLimits(WriteLimits{BannedPrefixes: []string{""}}))
+	assert.EqualError(t, err, "banned key prefixes must not be empty strings")
+
+	limits := WriteLimits{MaxValueSize: 10, MaxKeysPerTx: 2}
+	h, err := newChaincodeHandler(nil, &mockChaincode{}, WithWriteLimits(limits))
+	require.NoError(t, err)
+	assert.Equal(t, &limits, h.writeLimits)
+	assert.Equal(t, "value_size=10,keys=2,banned_prefixes=0", h.features()["write_limits"])
+
+	stub, err := newChaincodeStub(h, "channel", "txid", &peerpb.ChaincodeInput{}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, newWriteGuard(&limits), stub.writeGuard)
+}
+
+func TestWriteLimits(t *testing.T) {
+	newStub := func(limits WriteLimits) *ChaincodeStub {
+		stub, _ := newBatchingStub(&peerpb.QueryResponse{})
+		stub.writeGuard = newWriteGuard(&limits)
+		return stub
+	}
+
+	t.Run("Value Size", func(t *testing.T) {
+		stub := newStub(WriteLimits{MaxValueSize: 5})
+		assert.NoError(t, stub.PutState("key", []byte("12345")))
+		err := stub.PutPrivateData("col", "key", []byte("123456"))
+		assert.Equal(t, &ValueTooLargeError{Key: "key", Size: 6, Max: 5}, err)
+		assert.EqualError(t, err, "value of key [key] is 6 bytes, exceeding the maximum of 5 bytes")
+		assert.NoError(t, stub.DelState("key"))
+	})
+
+	t.Run("Keys Per Transaction", func(t *testing.T) {
+		stub := newStub(WriteLimits{MaxKeysPerTx: 2})
+		assert.NoError(t, stub.PutState("a", []byte("value")))
+		assert.NoError(t, stub.DelPrivateData("col", "a"))
+		// writing a key again is not counted
+		assert.NoError(t, stub.PutState("a", []byte("other")))
+		err := stub.DelState("b")
+		assert.Equal(t, &TooManyKeysError{Key: "b", Max: 2}, err)
+		assert.EqualError(t, err, "writing key [b] exceeds the maximum of 2 keys written per transaction")
+	})
+
+	t.Run("Banned Prefixes", func(t *testing.T) {
+		stub := newStub(WriteLimits{BannedPrefixes: []string{"system.", "\x00acl\x00"}})
+		assert.NoError(t, stub.PutState("asset", []byte("value")))
+		err := stub.PutState("system.config", []byte("value"))
+		assert.Equal(t, &BannedKeyError{Key: "system.config", Prefix: "system."}, err)
+		assert.EqualError(t, err, "key [system.config] has the banned prefix [system.]")
+
+		key, err := stub.CreateCompositeKey("acl", []string{"policy"})
+		require.NoError(t, err)
+		assert.IsType(t, &BannedKeyError{}, stub.DelState(key))
+	})
+
+	t.Run("Validation Parameters", func(t *testing.T) {
+		stub := newStub(WriteLimits{MaxValueSize: 5, MaxKeysPerTx: 2, BannedPrefixes: []string{"system."}})
+		err := stub.SetStateValidationParameter("system.config", []byte("ep"))
+		assert.Equal(t, &BannedKeyError{Key: "system.config", Prefix: "system."}, err)
+		err = stub.SetStateValidationParameter("key", []byte("123456"))
+		assert.Equal(t, &ValueTooLargeError{Key: "key", Size: 6, Max: 5}, err)
+
+		assert.NoError(t, stub.SetStateValidationParameter("a", []byte("ep")))
+		assert.NoError(t, stub.PutState("a", []byte("value")))
+		assert.NoError(t, stub.SetPrivateDataValidationParameter("col", "b", []byte("ep")))
+		err = stub.SetStateValidationParameter("c", []byte("ep"))
+		assert.Equal(t, &TooManyKeysError{Key: "c", Max: 2}, err)
+	})
+
+	t.Run("No Limits", func(t *testing.T) {
+		stub, _ := newBatchingStub(&peerpb.QueryResponse{})
+		assert.NoError(t, stub.PutState("system.config", make([]byte, 1024)))
+	})
+}
@@ -0,0 +1,260 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package bench generates ledger workloads for measuring the performance
+// of the shim and of chaincodes. A Workload describes a mix of reads,
+// writes, deletes and range queries over a key space; it can be written as
+// a spec such as
+//
+//	keys=1000,value=256,reads=80,writes=15,ranges=5,range=20,seed=1
+//
+// and is run with Run against any stub, typically a shimtest.MockStub:
+//
+//	w, err := bench.ParseWorkload(spec)
+//	...
+//	stub := shimtest.NewMockStub("bench", nil)
+//	err = bench.Populate(stub, w)
+//	stats, err := bench.Run(stub, w, 10000)
+package bench
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Workload describes a mix of ledger operations. The weights of the
+// operations are relative to each other.
+type Workload struct {
+	// Keys is the number of keys of the key space.
+	Keys int
+	// ValueSize is the size in bytes of the values written.
+	ValueSize int
+	// Reads, Writes, Deletes and Ranges are the weights of GetState,
+	// PutState, DelState and GetStateByRange.
+	Reads   int
+	Writes  int
+	Deletes int
+	Ranges  int
+	// RangeSize is the number of keys spanned by a range query.
+	RangeSize int
+	// Seed seeds the choice of operations and keys, so that a workload
+	// always issues the same operations.
+	Seed int64
+}
+
+// workloadFields maps the names of the fields of a workload spec to the
+// fields of a Workload.
+var workloadFields = map[string]func(w *Workload, v int64){
+	"keys":    func(w *Workload, v int64) { w.Keys = int(v) },
+	"value":   func(w *Workload, v int64) { w.ValueSize = int(v) },
+	"reads":   func(w *Workload, v int64) { w.Reads = int(v) },
+	"writes":  func(w *Workload, v int64) { w.Writes = int(v) },
+	"deletes": func(w *Workload, v int64) { w.Deletes = int(v) },
+	"ranges":  func(w *Workload, v int64) { w.Ranges = int(v) },
+	"range":   func(w *Workload, v int64) { w.RangeSize = int(v) },
+	"seed":    func(w *Workload, v int64) { w.Seed = v },
+}
+
+// ParseWorkload parses a workload spec, a comma separated list of
+// name=value pairs where the names are keys, value, reads, writes,
+// deletes, ranges, range and seed.
+func ParseWorkload(spec string) (Workload, error) {
+	var w Workload
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return Workload{}, fmt.Errorf("invalid workload spec: %s is not a name=value pair", pair)
+		}
+		set, ok := workloadFields[parts[0]]
+		if !ok {
+			return Workload{}, fmt.Errorf("invalid workload spec: unknown field %s", parts[0])
+		}
+		v, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return Workload{}, fmt.Errorf("invalid workload spec: %s: %s", pair, err)
+		}
+		set(&w, v)
+	}
+	return w, w.Validate()
+}
+
+// String returns the spec of w.
+func (w Workload) String() string {
+	return fmt.Sprintf("keys=%d,value=%d,reads=%d,writes=%d,deletes=%d,ranges=%d,range=%d,seed=%d",
+		w.Keys, w.ValueSize, w.Reads, w.Writes, w.Deletes, w.Ranges, w.RangeSize, w.Seed)
+}
+
+// Validate returns an error if w cannot be run.
+func (w Workload) Validate() error {
+	if w.Keys <= 0 {
+		return errors.New("workload must have at least one key")
+	}
+	if w.ValueSize < 0 || w.Reads < 0 || w.Writes < 0 || w.Deletes < 0 || w.Ranges < 0 || w.RangeSize < 0 {
+		return errors.New("workload sizes and weights must not be negative")
+	}
+	if w.Reads+w.Writes+w.Deletes+w.Ranges == 0 {
+		return errors.New("workload must have at least one operation with a positive weight")
+	}
+	if w.Ranges > 0 && w.RangeSize == 0 {
+		return errors.New("workload with range queries must have a positive range size")
+	}
+	return nil
+}
+
+// OpKind is the kind of a ledger operation.
+type OpKind string
+
+// The kinds of operations of a workload.
+const (
+	Read   = OpKind("read")
+	Write  = OpKind("write")
+	Delete = OpKind("delete")
+	Range  = OpKind("range")
+)
+
+// Op is a ledger operation.
+type Op struct {
+	Kind OpKind
+	Key  string
+	// EndKey is the exclusive end of the range of range queries.
+	EndKey string
+}
+
+// Key returns the key of index i of the key space.
+func Key(i int) string {
+	return fmt.Sprintf("key%08d", i)
+}
+
+// Generator generates the operations of a workload.
+type Generator struct {
+	w     Workload
+	rand  *rand.Rand
+	value []byte
+}
+
+// NewGenerator returns a generator of the operations of w.
+func NewGenerator(w Workload) (*Generator, error) {
+	if err := w.Validate(); err != nil {
+		return nil, err
+	}
+	r := rand.New(rand.NewSource(w.Seed))
+	value := make([]byte, w.ValueSize)
+	r.Read(value)
+	return &Generator{w: w, rand: r, value: value}, nil
+}
+
+// Next returns the next operation.
+func (g *Generator) Next() Op {
+	i := g.rand.Intn(g.w.Keys)
+	n := g.rand.Intn(g.w.Reads + g.w.Writes + g.w.Deletes + g.w.Ranges)
+	switch {
+	case n < g.w.Reads:
+		return Op{Kind: Read, Key: Key(i)}
+	case n < g.w.Reads+g.w.Writes:
+		return Op{Kind: Write, Key: Key(i)}
+	case n < g.w.Reads+g.w.Writes+g.w.Deletes:
+		return Op{Kind: Delete, Key: Key(i)}
+	default:
+		return Op{Kind: Range, Key: Key(i), EndKey: Key(i + g.w.RangeSize)}
+	}
+}
+
+// Apply performs op on stub and returns the number of results it read.
+func (g *Generator) Apply(stub shim.ChaincodeStubInterface, op Op) (int, error) {
+	switch op.Kind {
+	case Read:
+		value, err := stub.GetState(op.Key)
+		if err != nil || value == nil {
+			return 0, err
+		}
+		return 1, nil
+	case Write:
+		return 0, stub.PutState(op.Key, g.value)
+	case Delete:
+		return 0, stub.DelState(op.Key)
+	case Range:
+		iter, err := stub.GetStateByRange(op.Key, op.EndKey)
+		if err != nil {
+			return 0, err
+		}
+		defer iter.Close()
+		n := 0
+		for iter.HasNext() {
+			if _, err := iter.Next(); err != nil {
+				return n, err
+			}
+			n++
+		}
+		return n, nil
+	default:
+		return 0, fmt.Errorf("unknown operation %s", op.Kind)
+	}
+}
+
+// Populate writes every key of the key space of w with a value of the size
+// of the workload.
+func Populate(stub shim.ChaincodeStubInterface, w Workload) error {
+	if err := w.Validate(); err != nil {
+		return err
+	}
+	value := make([]byte, w.ValueSize)
+	rand.New(rand.NewSource(w.Seed)).Read(value)
+	for i := 0; i < w.Keys; i++ {
+		if err := stub.PutState(Key(i), value); err != nil {
+			return fmt.Errorf("failed to populate %s: %s", Key(i), err)
+		}
+	}
+	return nil
+}
+
+// Stats counts the operations performed by Run.
+type Stats struct {
+	// Ops is the number of operations of each kind.
+	Ops map[OpKind]int
+	// Results is the number of values read.
+	Results int
+}
+
+// String returns the counts of stats, sorted by kind.
+func (s Stats) String() string {
+	var kinds []string
+	for kind := range s.Ops {
+		kinds = append(kinds, string(kind))
+	}
+	sort.Strings(kinds)
+	var parts []string
+	for _, kind := range kinds {
+		parts = append(parts, fmt.Sprintf("%s=%d", kind, s.Ops[OpKind(kind)]))
+	}
+	parts = append(parts, fmt.Sprintf("results=%d", s.Results))
+	return strings.Join(parts, ",")
+}
+
+// Run performs n operations of w on stub.
+func Run(stub shim.ChaincodeStubInterface, w Workload, n int) (Stats, error) {
+	stats := Stats{Ops: map[OpKind]int{}}
+	g, err := NewGenerator(w)
+	if err != nil {
+		return stats, err
+	}
+	for i := 0; i < n; i++ {
+		op := g.Next()
+		results, err := g.Apply(stub, op)
+		if err != nil {
+			return stats, fmt.Errorf("%s %s failed: %s", op.Kind, op.Key, err)
+		}
+		stats.Ops[op.Kind]++
+		stats.Results += results
+	}
+	return stats, nil
+}
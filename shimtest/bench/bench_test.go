@@ -0,0 +1,138 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package bench_test
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest/bench"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseWorkload(t *testing.T) {
+	var tests = []struct {
+		spec     string
+		expected bench.Workload
+		errMsg   string
+	}{
+		{
+			spec:     "keys=100, value=32,reads=3,writes=1,ranges=1,range=5,seed=7",
+			expected: bench.Workload{Keys: 100, ValueSize: 32, Reads: 3, Writes: 1, Ranges: 1, RangeSize: 5, Seed: 7},
+		},
+		{spec: "keys=100,reads", errMsg: "invalid workload spec: reads is not a name=value pair"},
+		{spec: "keys=100,queries=1", errMsg: "invalid workload spec: unknown field queries"},
+		{spec: "keys=x", errMsg: `invalid workload spec: keys=x: strconv.ParseInt: parsing "x": invalid syntax`},
+		{spec: "reads=1", errMsg: "workload must have at least one key"},
+		{spec: "keys=1", errMsg: "workload must have at least one operation with a positive weight"},
+		{spec: "keys=1,writes=-1,reads=2", errMsg: "workload sizes and weights must not be negative"},
+		{spec: "keys=1,ranges=1", errMsg: "workload with range queries must have a positive range size"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.spec, func(t *testing.T) {
+			w, err := bench.ParseWorkload(test.spec)
+			if test.errMsg != "" {
+				assert.EqualError(t, err, test.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.expected, w)
+
+			roundTrip, err := bench.ParseWorkload(w.String())
+			require.NoError(t, err)
+			assert.Equal(t, w, roundTrip)
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	w := bench.Workload{Keys: 50, ValueSize: 16, Reads: 5, Writes: 2, Deletes: 1, Ranges: 2, RangeSize: 4, Seed: 3}
+
+	run := func() bench.Stats {
+		stub := shimtest.NewMockStub("bench", nil)
+		stub.MockTransactionStart("populate")
+		require.NoError(t, bench.Populate(stub, w))
+		stub.MockTransactionEnd("populate")
+
+		stub.MockTransactionStart("run")
+		defer stub.MockTransactionEnd("run")
+		stats, err := bench.Run(stub, w, 500)
+		require.NoError(t, err)
+		return stats
+	}
+
+	stats := run()
+	total := 0
+	for _, kind := range []bench.OpKind{bench.Read, bench.Write, bench.Delete, bench.Range} {
+		assert.NotZero(t, stats.Ops[kind], "no %s operations", kind)
+		total += stats.Ops[kind]
+	}
+	assert.Equal(t, 500, total)
+	assert.True(t, stats.Results > stats.Ops[bench.Range], "range queries should return results")
+	assert.Equal(t, stats, run(), "the same workload should perform the same operations")
+}
+
+func TestGeneratorRange(t *testing.T) {
+	g, err := bench.NewGenerator(bench.Workload{Keys: 10, Ranges: 1, RangeSize: 3})
+	require.NoError(t, err)
+
+	op := g.Next()
+	assert.Equal(t, bench.Range, op.Kind)
+	assert.True(t, op.Key < op.EndKey)
+
+	stub := shimtest.NewMockStub("bench", nil)
+	stub.MockTransactionStart("populate")
+	for i := 0; i < 20; i++ {
+		require.NoError(t, stub.PutState(bench.Key(i), []byte("value")))
+	}
+	stub.MockTransactionEnd("populate")
+
+	results, err := g.Apply(stub, op)
+	require.NoError(t, err)
+	assert.Equal(t, 3, results)
+}
+
+func benchmarkWorkload(b *testing.B, spec string) {
+	w, err := bench.ParseWorkload(spec)
+	require.NoError(b, err)
+	g, err := bench.NewGenerator(w)
+	require.NoError(b, err)
+
+	stub := shimtest.NewMockStub("bench", nil)
+	stub.MockTransactionStart("populate")
+	require.NoError(b, bench.Populate(stub, w))
+	stub.MockTransactionEnd("populate")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		stub.MockTransactionStart("bench")
+		if _, err := g.Apply(stub, g.Next()); err != nil {
+			b.Fatal(err)
+		}
+		stub.MockTransactionEnd("bench")
+	}
+}
+
+func BenchmarkReads(b *testing.B) {
+	benchmarkWorkload(b, "keys=1000,value=256,reads=1")
+}
+
+func BenchmarkWrites(b *testing.B) {
+	benchmarkWorkload(b, "keys=1000,value=256,writes=1")
+}
+
+func BenchmarkLargeWrites(b *testing.B) {
+	benchmarkWorkload(b, "keys=1000,value=65536,writes=1")
+}
+
+func BenchmarkRanges(b *testing.B) {
+	benchmarkWorkload(b, "keys=1000,value=256,ranges=1,range=20")
+}
+
+func BenchmarkMixed(b *testing.B) {
+	benchmarkWorkload(b, "keys=1000,value=256,reads=80,writes=15,deletes=1,ranges=4,range=20")
+}
@@ -0,0 +1,135 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shimtest
+
+import (
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// StubBuilder assembles a MockStub with pre-populated ledger state and
+// transaction context. Create one with NewStubBuilder.
+type StubBuilder struct {
+	name        string
+	cc          shim.Chaincode
+	channelID   string
+	state       map[string][]byte
+	collections map[string]map[string][]byte
+	creator     []byte
+	transient   map[string][]byte
+	decorations map[string][]byte
+	clock       func() time.Time
+}
+
+// NewStubBuilder returns a StubBuilder for a MockStub without a chaincode.
+func NewStubBuilder() *StubBuilder {
+	return &StubBuilder{
+		state:       map[string][]byte{},
+		collections: map[string]map[string][]byte{},
+		transient:   map[string][]byte{},
+		decorations: map[string][]byte{},
+	}
+}
+
+// WithName sets the name of the MockStub.
+func (b *StubBuilder) WithName(name string) *StubBuilder {
+	b.name = name
+	return b
+}
+
+// WithChaincode sets the chaincode invoked by MockInit and MockInvoke.
+func (b *StubBuilder) WithChaincode(cc shim.Chaincode) *StubBuilder {
+	b.cc = cc
+	return b
+}
+
+// WithChannelID sets the channel ID returned by GetChannelID.
+func (b *StubBuilder) WithChannelID(channelID string) *StubBuilder {
+	b.channelID = channelID
+	return b
+}
+
+// WithState adds the given keys and values to the public state.
+func (b *StubBuilder) WithState(state map[string][]byte) *StubBuilder {
+	for key, value := range state {
+		b.state[key] = value
+	}
+	return b
+}
+
+// WithCollection adds the given keys and values to a private data
+// collection.
+func (b *StubBuilder) WithCollection(collection string, data map[string][]byte) *StubBuilder {
+	if b.collections[collection] == nil {
+		b.collections[collection] = map[string][]byte{}
+	}
+	for key, value := range data {
+		b.collections[collection][key] = value
+	}
+	return b
+}
+
+// WithCreator sets the serialized identity returned by GetCreator.
+func (b *StubBuilder) WithCreator(creator []byte) *StubBuilder {
+	b.creator = creator
+	return b
+}
+
+// WithTransient adds the given entries to the transient map returned by
+// GetTransient.
+func (b *StubBuilder) WithTransient(transient map[string][]byte) *StubBuilder {
+	for key, value := range transient {
+		b.transient[key] = value
+	}
+	return b
+}
+
+// WithDecorations adds the given entries to the decorations returned by
+// GetDecorations.
+func (b *StubBuilder) WithDecorations(decorations map[string][]byte) *StubBuilder {
+	for key, value := range decorations {
+		b.decorations[key] = value
+	}
+	return b
+}
+
+// WithClock sets the clock returning the timestamp of the transactions of
+// the MockStub.
+func (b *StubBuilder) WithClock(clock func() time.Time) *StubBuilder {
+	b.clock = clock
+	return b
+}
+
+// Build returns a new MockStub configured by the builder. The builder may
+// be reused to build further, independent stubs.
+func (b *StubBuilder) Build() *MockStub {
+	stub := NewMockStub(b.name, b.cc)
+	stub.ChannelID = b.channelID
+	stub.Creator = b.creator
+	stub.Clock = b.clock
+
+	for key, value := range b.transient {
+		if stub.Transient == nil {
+			stub.Transient = map[string][]byte{}
+		}
+		stub.Transient[key] = value
+	}
+	for key, value := range b.decorations {
+		stub.Decorations[key] = value
+	}
+	for key, value := range b.state {
+		if len(value) != 0 {
+			stub.applyWrite("", key, value)
+		}
+	}
+	for collection, data := range b.collections {
+		stub.PvtState[collection] = map[string][]byte{}
+		for key, value := range data {
+			stub.applyWrite(collection, key, value)
+		}
+	}
+
+	return stub
+}
@@ -0,0 +1,64 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shimtest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStubBuilder(t *testing.T) {
+	builder := NewStubBuilder().
+		WithName("builder").
+		WithChannelID("mychannel").
+		WithState(map[string][]byte{"b": []byte("2"), "a": []byte("1"), "empty": nil}).
+		WithCollection("coll", map[string][]byte{"secret": []byte("s")}).
+		WithCollection("empty", nil).
+		WithCreator([]byte("creator")).
+		WithTransient(map[string][]byte{"tkey": []byte("tvalue")}).
+		WithDecorations(map[string][]byte{"dkey": []byte("dvalue")})
+
+	stub := builder.Build()
+	assert.Equal(t, "builder", stub.Name)
+	assert.Equal(t, "mychannel", stub.GetChannelID())
+
+	val, err := stub.GetState("a")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), val)
+	assert.Equal(t, 2, stub.Keys.Len())
+	assert.Equal(t, "a", stub.Keys.Front().Value)
+
+	val, err = stub.GetPrivateData("coll", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("s"), val)
+	assert.Contains(t, stub.PvtState, "empty")
+
+	creator, err := stub.GetCreator()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("creator"), creator)
+
+	transient, err := stub.GetTransient()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"tkey": []byte("tvalue")}, transient)
+	assert.Equal(t, map[string][]byte{"dkey": []byte("dvalue")}, stub.GetDecorations())
+
+	// stubs built from the same builder do not share state
+	other := builder.Build()
+	stub.MockTransactionStart("tx")
+	stub.PutState("c", []byte("3"))
+	stub.MockTransactionEnd("tx")
+	assert.Len(t, stub.State, 3)
+	assert.Len(t, other.State, 2)
+}
+
+func TestStubBuilderDefaults(t *testing.T) {
+	stub := NewStubBuilder().Build()
+	assert.Empty(t, stub.State)
+	assert.Nil(t, stub.Creator)
+
+	transient, err := stub.GetTransient()
+	assert.NoError(t, err)
+	assert.Nil(t, transient)
+}
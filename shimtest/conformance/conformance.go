@@ -0,0 +1,254 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package conformance is a test suite asserting that an implementation of
+// shim.ChaincodeStubInterface, such as a mock or an adapter, behaves like
+// the stub of the shim talking to a peer. It is run from a test of the
+// implementation:
+//
+//	func TestConformance(t *testing.T) {
+//		conformance.Run(t, func(t *testing.T, state map[string][]byte) (shim.ChaincodeStubInterface, func()) {
+//			stub := newStub(state)
+//			return stub, stub.Close
+//		})
+//	}
+//
+// The suite only reads the state it passes to the NewStub function, since
+// the peer does not return the writes of a transaction to its own reads.
+package conformance
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NewStub returns a stub executing a transaction on a ledger whose public
+// state is state, and a function releasing the stub, which may be nil. The
+// keys of state are simple keys and composite keys created with
+// shim.CreateCompositeKey.
+type NewStub func(t *testing.T, state map[string][]byte) (shim.ChaincodeStubInterface, func())
+
+// Run runs the conformance suite against the stubs returned by newStub,
+// each test in its own subtest.
+func Run(t *testing.T, newStub NewStub) {
+	tests := []struct {
+		name string
+		test func(*testing.T, shim.ChaincodeStubInterface)
+	}{
+		{name: "GetState", test: testGetState},
+		{name: "CompositeKeyRoundTrip", test: testCompositeKeyRoundTrip},
+		{name: "RangeOrdering", test: testRangeOrdering},
+		{name: "RangeExcludesCompositeKeys", test: testRangeExcludesCompositeKeys},
+		{name: "PartialCompositeKey", test: testPartialCompositeKey},
+		{name: "RangePagination", test: testRangePagination},
+		{name: "PartialCompositeKeyPagination", test: testPartialCompositeKeyPagination},
+		{name: "InvalidKeys", test: testInvalidKeys},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			stub, release := newStub(t, fixture(t))
+			if release != nil {
+				defer release()
+			}
+			tt.test(t, stub)
+		})
+	}
+}
+
+// simpleKeys are the simple keys of the fixture, in lexical order.
+var simpleKeys = []string{"a", "a0", "ab", "b", "c~", "é"}
+
+// fixture returns the state of the ledger of the tests: the simple keys,
+// whose values are their keys, and the composite keys of the object types
+// "color" and "colors".
+func fixture(t *testing.T) map[string][]byte {
+	state := map[string][]byte{}
+	for _, key := range simpleKeys {
+		state[key] = []byte(key)
+	}
+	for _, ck := range compositeKeys() {
+		key, err := shim.CreateCompositeKey(ck.objectType, ck.attributes)
+		require.NoError(t, err)
+		state[key] = []byte(fmt.Sprint(ck.attributes))
+	}
+	return state
+}
+
+type compositeKey struct {
+	objectType string
+	attributes []string
+}
+
+// compositeKeys returns the composite keys of the fixture, in key order.
+func compositeKeys() []compositeKey {
+	return []compositeKey{
+		{"color", []string{"blue", "1"}},
+		{"color", []string{"blue", "2"}},
+		{"color", []string{"blue2", "1"}},
+		{"color", []string{"red", "1"}},
+		{"colors", []string{"all"}},
+	}
+}
+
+// keys returns the keys returned by iter, which it closes.
+func keys(t *testing.T, iter shim.StateQueryIteratorInterface) []string {
+	var keys []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		require.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	_, err := iter.Next()
+	assert.Error(t, err, "Next must fail when HasNext returns false")
+	assert.NoError(t, iter.Close())
+	return keys
+}
+
+func createKeys(t *testing.T, cks ...compositeKey) []string {
+	var keys []string
+	for _, ck := range cks {
+		key, err := shim.CreateCompositeKey(ck.objectType, ck.attributes)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func testGetState(t *testing.T, stub shim.ChaincodeStubInterface) {
+	value, err := stub.GetState("ab")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("ab"), value)
+
+	value, err = stub.GetState("missing")
+	require.NoError(t, err)
+	assert.Nil(t, value, "missing keys must have a nil value")
+}
+
+func testCompositeKeyRoundTrip(t *testing.T, stub shim.ChaincodeStubInterface) {
+	for _, attributes := range [][]string{nil, {"a"}, {"", "b"}, {"a b", "é", "🙂"}} {
+		key, err := stub.CreateCompositeKey("asset", attributes)
+		require.NoError(t, err)
+		expected, err := shim.CreateCompositeKey("asset", attributes)
+		require.NoError(t, err)
+		assert.Equal(t, expected, key)
+
+		objectType, split, err := stub.SplitCompositeKey(key)
+		require.NoError(t, err)
+		assert.Equal(t, "asset", objectType)
+		if len(attributes) == 0 {
+			assert.Empty(t, split)
+		} else {
+			assert.Equal(t, attributes, split)
+		}
+	}
+
+	_, err := stub.CreateCompositeKey("asset", []string{"a\x00b"})
+	assert.Error(t, err, "attributes containing U+0000 must be rejected")
+	_, err = stub.CreateCompositeKey("asset", []string{"a\xffb"})
+	assert.Error(t, err, "attributes which are not valid UTF-8 must be rejected")
+}
+
+func testRangeOrdering(t *testing.T, stub shim.ChaincodeStubInterface) {
+	iter, err := stub.GetStateByRange("a", "c~")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "a0", "ab", "b"}, keys(t, iter), "ranges must be returned in lexical order, end key excluded")
+
+	iter, err = stub.GetStateByRange("a0", "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a0", "ab", "b", "c~", "é"}, keys(t, iter), "an empty end key must be unbounded")
+
+	iter, err = stub.GetStateByRange("x", "y")
+	require.NoError(t, err)
+	assert.Empty(t, keys(t, iter))
+}
+
+func testRangeExcludesCompositeKeys(t *testing.T, stub shim.ChaincodeStubInterface) {
+	iter, err := stub.GetStateByRange("", "")
+	require.NoError(t, err)
+	assert.Equal(t, simpleKeys, keys(t, iter), "unbounded ranges must only return simple keys")
+
+	iter, err = stub.GetStateByRange("", "b")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "a0", "ab"}, keys(t, iter))
+}
+
+func testPartialCompositeKey(t *testing.T, stub shim.ChaincodeStubInterface) {
+	all := compositeKeys()
+
+	iter, err := stub.GetStateByPartialCompositeKey("color", nil)
+	require.NoError(t, err)
+	assert.Equal(t, createKeys(t, all[:4]...), keys(t, iter), "object types must not match as prefixes")
+
+	iter, err = stub.GetStateByPartialCompositeKey("color", []string{"blue"})
+	require.NoError(t, err)
+	assert.Equal(t, createKeys(t, all[:2]...), keys(t, iter), "attributes must not match as prefixes")
+
+	iter, err = stub.GetStateByPartialCompositeKey("color", []string{"green"})
+	require.NoError(t, err)
+	assert.Empty(t, keys(t, iter))
+
+	iter, err = stub.GetStateByPartialCompositeKey("color", []string{"red"})
+	require.NoError(t, err)
+	kv, err := iter.Next()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("[red 1]"), kv.Value)
+	assert.NoError(t, iter.Close())
+}
+
+func testRangePagination(t *testing.T, stub shim.ChaincodeStubInterface) {
+	var pages [][]string
+	bookmark := ""
+	for i := 0; i < len(simpleKeys); i++ {
+		iter, metadata, err := stub.GetStateByRangeWithPagination("", "", 4, bookmark)
+		require.NoError(t, err)
+		page := keys(t, iter)
+		assert.Equal(t, int32(len(page)), metadata.FetchedRecordsCount)
+		pages = append(pages, page)
+		bookmark = metadata.Bookmark
+		if bookmark == "" {
+			break
+		}
+	}
+	assert.Equal(t, [][]string{simpleKeys[:4], simpleKeys[4:]}, pages, "bookmarks must chain the pages, the last one being empty")
+
+	iter, metadata, err := stub.GetStateByRangeWithPagination("a0", "c~", 2, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a0", "ab"}, keys(t, iter))
+	iter, metadata, err = stub.GetStateByRangeWithPagination("a0", "c~", 2, metadata.Bookmark)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"b"}, keys(t, iter), "the end key must bound the last page")
+	assert.Equal(t, "", metadata.Bookmark)
+}
+
+func testPartialCompositeKeyPagination(t *testing.T, stub shim.ChaincodeStubInterface) {
+	all := createKeys(t, compositeKeys()[:4]...)
+
+	iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination("color", nil, 3, "")
+	require.NoError(t, err)
+	assert.Equal(t, all[:3], keys(t, iter))
+	assert.Equal(t, int32(3), metadata.FetchedRecordsCount)
+	require.NotEmpty(t, metadata.Bookmark)
+
+	iter, metadata, err = stub.GetStateByPartialCompositeKeyWithPagination("color", nil, 3, metadata.Bookmark)
+	require.NoError(t, err)
+	assert.Equal(t, all[3:], keys(t, iter), "the partial key must bound the last page")
+	assert.Equal(t, int32(1), metadata.FetchedRecordsCount)
+	assert.Equal(t, "", metadata.Bookmark)
+}
+
+func testInvalidKeys(t *testing.T, stub shim.ChaincodeStubInterface) {
+	compositeKey, err := stub.CreateCompositeKey("color", []string{"blue"})
+	require.NoError(t, err)
+
+	_, err = stub.GetStateByRange(compositeKey, "")
+	assert.Error(t, err, "ranges must not start with a composite key")
+	_, err = stub.GetStateByRange("a", compositeKey)
+	assert.Error(t, err, "ranges must not end with a composite key")
+	_, _, err = stub.GetStateByRangeWithPagination(compositeKey, "", 2, "")
+	assert.Error(t, err, "ranges must not start with a composite key")
+}
@@ -0,0 +1,34 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package conformance_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest/conformance"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStub(t *testing.T) {
+	conformance.Run(t, func(t *testing.T, state map[string][]byte) (shim.ChaincodeStubInterface, func()) {
+		stub := shimtest.NewMockStub("conformance", nil)
+		var keys []string
+		for key := range state {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		stub.MockTransactionStart("setup")
+		for _, key := range keys {
+			require.NoError(t, stub.PutState(key, state[key]))
+		}
+		stub.MockTransactionEnd("setup")
+
+		stub.MockTransactionStart("tx")
+		return stub, func() { stub.MockTransactionEnd("tx") }
+	})
+}
@@ -0,0 +1,58 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shimtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// EventNamed returns the last event in events with the given name. An error
+// is returned if no such event exists.
+func EventNamed(events []*pb.ChaincodeEvent, name string) (*pb.ChaincodeEvent, error) {
+	for i := len(events) - 1; i >= 0; i-- {
+		if events[i].EventName == name {
+			return events[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no event named '%s' was set", name)
+}
+
+// EventPayloadJSONEquals checks that the payload of event is JSON equivalent
+// to expected. The expected value may be JSON as a string or byte slice, or
+// any other value which is marshaled to JSON before the comparison.
+func EventPayloadJSONEquals(event *pb.ChaincodeEvent, expected interface{}) error {
+	if event == nil {
+		return fmt.Errorf("event is nil")
+	}
+
+	var expectedJSON []byte
+	switch e := expected.(type) {
+	case []byte:
+		expectedJSON = e
+	case string:
+		expectedJSON = []byte(e)
+	default:
+		var err error
+		expectedJSON, err = json.Marshal(expected)
+		if err != nil {
+			return fmt.Errorf("failed to marshal expected payload: %s", err)
+		}
+	}
+
+	var want, got interface{}
+	if err := json.Unmarshal(expectedJSON, &want); err != nil {
+		return fmt.Errorf("expected payload is not valid JSON: %s", err)
+	}
+	if err := json.Unmarshal(event.Payload, &got); err != nil {
+		return fmt.Errorf("payload of event '%s' is not valid JSON: %s", event.EventName, err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		return fmt.Errorf("payload of event '%s' is %s, not %s", event.EventName, event.Payload, expectedJSON)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shimtest
+
+import (
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest/mock"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChaincodeEventsForTx(t *testing.T) {
+	cc := &mock.Chaincode{}
+	cc.InvokeStub = func(stub shim.ChaincodeStubInterface) pb.Response {
+		stub.SetEvent("first", []byte(`{"id":1}`))
+		stub.SetEvent("second", []byte(`{"id": 2, "owner": "tom"}`))
+		return shim.Success(nil)
+	}
+	stub := NewMockStub("events", cc)
+
+	stub.MockInvoke("tx1", nil)
+
+	events := stub.ChaincodeEventsForTx("tx1")
+	assert.Len(t, events, 2)
+	assert.Equal(t, "tx1", events[0].TxId)
+	assert.Empty(t, stub.ChaincodeEventsForTx("tx2"))
+
+	event, err := EventNamed(events, "second")
+	assert.NoError(t, err)
+	assert.NoError(t, EventPayloadJSONEquals(event, `{"owner":"tom","id":2}`))
+	assert.NoError(t, EventPayloadJSONEquals(event, map[string]interface{}{"id": 2, "owner": "tom"}))
+	assert.EqualError(t, EventPayloadJSONEquals(event, []byte(`{"id":3}`)),
+		`payload of event 'second' is {"id": 2, "owner": "tom"}, not {"id":3}`)
+
+	_, err = EventNamed(events, "third")
+	assert.EqualError(t, err, "no event named 'third' was set")
+}
+
+func TestEventPayloadJSONEqualsInvalid(t *testing.T) {
+	event := &pb.ChaincodeEvent{EventName: "e", Payload: []byte("not json")}
+	assert.EqualError(t, EventPayloadJSONEquals(nil, "{}"), "event is nil")
+	assert.Contains(t, EventPayloadJSONEquals(event, "{}").Error(), "payload of event 'e' is not valid JSON")
+	assert.Contains(t, EventPayloadJSONEquals(event, "{").Error(), "expected payload is not valid JSON")
+}
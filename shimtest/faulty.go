@@ -0,0 +1,209 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shimtest
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// ErrInjectedFault is the error returned by the calls a FaultyStub fails
+// when the FaultPolicy does not set an error.
+var ErrInjectedFault = errors.New("injected fault")
+
+// FaultPolicy describes the faults injected by a FaultyStub. The zero
+// value injects no faults.
+type FaultPolicy struct {
+	// GetStateErrorEvery fails every Nth call to GetState, starting with
+	// the Nth one.
+	GetStateErrorEvery int
+	// Err is the error returned by failed calls. It defaults to
+	// ErrInjectedFault.
+	Err error
+	// Latency delays every call that reaches the ledger by Latency plus a
+	// random duration below Jitter.
+	Latency time.Duration
+	Jitter  time.Duration
+	// Seed seeds the random jitter, so that a policy always injects the
+	// same delays.
+	Seed int64
+	// TruncateIteratorsAfter ends the iterators of queries after the given
+	// number of results, as if the ledger held no more.
+	TruncateIteratorsAfter int
+	// Sleep is called with the delays. It defaults to time.Sleep.
+	Sleep func(time.Duration)
+}
+
+// FaultyStub returns a stub that calls stub, injecting the faults of
+// policy. It is meant to test the error handling and resilience of
+// chaincode:
+//
+//	stub := shimtest.FaultyStub(mockStub, shimtest.FaultPolicy{GetStateErrorEvery: 3})
+//	res := cc.Invoke(stub)
+func FaultyStub(stub shim.ChaincodeStubInterface, policy FaultPolicy) shim.ChaincodeStubInterface {
+	if policy.Err == nil {
+		policy.Err = ErrInjectedFault
+	}
+	if policy.Sleep == nil {
+		policy.Sleep = time.Sleep
+	}
+	return &faultyStub{
+		ChaincodeStubInterface: stub,
+		policy:                 policy,
+		rand:                   rand.New(rand.NewSource(policy.Seed)),
+	}
+}
+
+type faultyStub struct {
+	shim.ChaincodeStubInterface
+	policy FaultPolicy
+
+	mutex     sync.Mutex
+	rand      *rand.Rand
+	getStates int
+}
+
+// delay injects the latency of the policy.
+func (s *faultyStub) delay() {
+	d := s.policy.Latency
+	if s.policy.Jitter > 0 {
+		s.mutex.Lock()
+		d += time.Duration(s.rand.Int63n(int64(s.policy.Jitter)))
+		s.mutex.Unlock()
+	}
+	if d > 0 {
+		s.policy.Sleep(d)
+	}
+}
+
+func (s *faultyStub) GetState(key string) ([]byte, error) {
+	s.delay()
+	if n := s.policy.GetStateErrorEvery; n > 0 {
+		s.mutex.Lock()
+		s.getStates++
+		fail := s.getStates%n == 0
+		s.mutex.Unlock()
+		if fail {
+			return nil, s.policy.Err
+		}
+	}
+	return s.ChaincodeStubInterface.GetState(key)
+}
+
+func (s *faultyStub) PutState(key string, value []byte) error {
+	s.delay()
+	return s.ChaincodeStubInterface.PutState(key, value)
+}
+
+func (s *faultyStub) DelState(key string) error {
+	s.delay()
+	return s.ChaincodeStubInterface.DelState(key)
+}
+
+func (s *faultyStub) GetPrivateData(collection, key string) ([]byte, error) {
+	s.delay()
+	return s.ChaincodeStubInterface.GetPrivateData(collection, key)
+}
+
+func (s *faultyStub) PutPrivateData(collection, key string, value []byte) error {
+	s.delay()
+	return s.ChaincodeStubInterface.PutPrivateData(collection, key, value)
+}
+
+func (s *faultyStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	s.delay()
+	return s.truncate(s.ChaincodeStubInterface.GetStateByRange(startKey, endKey))
+}
+
+func (s *faultyStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	s.delay()
+	iter, metadata, err := s.ChaincodeStubInterface.GetStateByRangeWithPagination(startKey, endKey, pageSize, bookmark)
+	iter, err = s.truncate(iter, err)
+	return iter, metadata, err
+}
+
+func (s *faultyStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	s.delay()
+	return s.truncate(s.ChaincodeStubInterface.GetStateByPartialCompositeKey(objectType, keys))
+}
+
+func (s *faultyStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	s.delay()
+	return s.truncate(s.ChaincodeStubInterface.GetQueryResult(query))
+}
+
+func (s *faultyStub) GetQueryResultWithPagination(query string, pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
+	s.delay()
+	iter, metadata, err := s.ChaincodeStubInterface.GetQueryResultWithPagination(query, pageSize, bookmark)
+	iter, err = s.truncate(iter, err)
+	return iter, metadata, err
+}
+
+func (s *faultyStub) GetPrivateDataByRange(collection, startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	s.delay()
+	return s.truncate(s.ChaincodeStubInterface.GetPrivateDataByRange(collection, startKey, endKey))
+}
+
+func (s *faultyStub) GetPrivateDataQueryResult(collection, query string) (shim.StateQueryIteratorInterface, error) {
+	s.delay()
+	return s.truncate(s.ChaincodeStubInterface.GetPrivateDataQueryResult(collection, query))
+}
+
+func (s *faultyStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	s.delay()
+	iter, err := s.ChaincodeStubInterface.GetHistoryForKey(key)
+	if err != nil || s.policy.TruncateIteratorsAfter <= 0 {
+		return iter, err
+	}
+	return &truncatedHistoryIterator{HistoryQueryIteratorInterface: iter, remaining: s.policy.TruncateIteratorsAfter}, nil
+}
+
+// truncate wraps iter in an iterator ending after the number of results of
+// the policy.
+func (s *faultyStub) truncate(iter shim.StateQueryIteratorInterface, err error) (shim.StateQueryIteratorInterface, error) {
+	if err != nil || s.policy.TruncateIteratorsAfter <= 0 {
+		return iter, err
+	}
+	return &truncatedStateIterator{StateQueryIteratorInterface: iter, remaining: s.policy.TruncateIteratorsAfter}, nil
+}
+
+type truncatedStateIterator struct {
+	shim.StateQueryIteratorInterface
+	remaining int
+}
+
+func (iter *truncatedStateIterator) HasNext() bool {
+	return iter.remaining > 0 && iter.StateQueryIteratorInterface.HasNext()
+}
+
+func (iter *truncatedStateIterator) Next() (*queryresult.KV, error) {
+	if iter.remaining <= 0 {
+		return nil, errors.New("Next() called after the iterator was truncated")
+	}
+	iter.remaining--
+	return iter.StateQueryIteratorInterface.Next()
+}
+
+type truncatedHistoryIterator struct {
+	shim.HistoryQueryIteratorInterface
+	remaining int
+}
+
+func (iter *truncatedHistoryIterator) HasNext() bool {
+	return iter.remaining > 0 && iter.HistoryQueryIteratorInterface.HasNext()
+}
+
+func (iter *truncatedHistoryIterator) Next() (*queryresult.KeyModification, error) {
+	if iter.remaining <= 0 {
+		return nil, errors.New("Next() called after the iterator was truncated")
+	}
+	iter.remaining--
+	return iter.HistoryQueryIteratorInterface.Next()
+}
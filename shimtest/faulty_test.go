@@ -0,0 +1,102 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shimtest
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newFaultyTestStub() *MockStub {
+	stub := NewStubBuilder().WithState(map[string][]byte{
+		"a": []byte("1"),
+		"b": []byte("2"),
+		"c": []byte("3"),
+		"d": []byte("4"),
+	}).Build()
+	stub.MockTransactionStart("faulty")
+	return stub
+}
+
+func TestFaultyStubGetStateErrors(t *testing.T) {
+	stub := FaultyStub(newFaultyTestStub(), FaultPolicy{GetStateErrorEvery: 3})
+
+	var failed []int
+	for i := 1; i <= 7; i++ {
+		value, err := stub.GetState("a")
+		if err != nil {
+			assert.Equal(t, ErrInjectedFault, err)
+			failed = append(failed, i)
+			continue
+		}
+		assert.Equal(t, []byte("1"), value)
+	}
+	assert.Equal(t, []int{3, 6}, failed)
+
+	custom := errors.New("peer unavailable")
+	stub = FaultyStub(newFaultyTestStub(), FaultPolicy{GetStateErrorEvery: 1, Err: custom})
+	_, err := stub.GetState("a")
+	assert.Equal(t, custom, err)
+	assert.NoError(t, stub.PutState("e", []byte("5")), "only GetState should fail")
+}
+
+func TestFaultyStubLatency(t *testing.T) {
+	run := func() []time.Duration {
+		var delays []time.Duration
+		stub := FaultyStub(newFaultyTestStub(), FaultPolicy{
+			Latency: 10 * time.Millisecond,
+			Jitter:  5 * time.Millisecond,
+			Seed:    42,
+			Sleep:   func(d time.Duration) { delays = append(delays, d) },
+		})
+		_, err := stub.GetState("a")
+		require.NoError(t, err)
+		require.NoError(t, stub.PutState("e", []byte("5")))
+		iter, err := stub.GetStateByRange("a", "z")
+		require.NoError(t, err)
+		iter.Close()
+		return delays
+	}
+
+	delays := run()
+	require.Len(t, delays, 3)
+	for _, d := range delays {
+		assert.True(t, d >= 10*time.Millisecond && d < 15*time.Millisecond, "delay %s out of range", d)
+	}
+	assert.Equal(t, delays, run(), "the same seed should inject the same delays")
+}
+
+func TestFaultyStubTruncation(t *testing.T) {
+	stub := FaultyStub(newFaultyTestStub(), FaultPolicy{TruncateIteratorsAfter: 2})
+
+	iter, err := stub.GetStateByRange("a", "z")
+	require.NoError(t, err)
+	var keys []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		require.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	assert.Equal(t, []string{"a", "b"}, keys)
+	_, err = iter.Next()
+	assert.EqualError(t, err, "Next() called after the iterator was truncated")
+	assert.NoError(t, iter.Close())
+
+	iter, err = stub.GetStateByRange("c", "z")
+	require.NoError(t, err)
+	keys = nil
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		require.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	assert.Equal(t, []string{"c", "d"}, keys)
+
+	_, err = stub.GetHistoryForKey("a")
+	assert.EqualError(t, err, "not implemented", "errors of the stub should be returned")
+}
@@ -11,14 +11,21 @@ package shimtest
 
 import (
 	"container/list"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
+	"math/rand"
 	"strings"
+	"sync"
+	"time"
 	"unicode/utf8"
 
 	"github.com/golang/protobuf/ptypes"
 	"github.com/golang/protobuf/ptypes/timestamp"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim/export"
+	"github.com/hyperledger/fabric-protos-go/common"
 	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
 	pb "github.com/hyperledger/fabric-protos-go/peer"
 )
@@ -26,6 +33,7 @@ import (
 const (
 	minUnicodeRuneValue   = 0 //U+0000
 	compositeKeyNamespace = "\x00"
+	emptyKeySubstitute    = "\x01"
 )
 
 // MockStub is an implementation of ChaincodeStubInterface for unit testing chaincode.
@@ -56,6 +64,11 @@ type MockStub struct {
 
 	TxTimestamp *timestamp.Timestamp
 
+	// Clock returns the timestamp of the transactions started by
+	// MockTransactionStart. It defaults to time.Now; set it to a fixed or
+	// stepping clock for tests that depend on the transaction time.
+	Clock func() time.Time
+
 	// mocked signedProposal
 	signedProposal *pb.SignedProposal
 
@@ -67,12 +80,59 @@ type MockStub struct {
 	// stores per-key endorsement policy, first map index is the collection, second map index is the key
 	EndorsementPolicies map[string]map[string][]byte
 
+	// stores the metadata entries of keys other than the key-level
+	// endorsement policy, first map index is the collection, second map
+	// index is the key, third map index is the name of the entry
+	StateMetadata map[string]map[string]map[string][]byte
+
 	// channel to store ChaincodeEvents
 	ChaincodeEventsChannel chan *pb.ChaincodeEvent
 
 	Creator []byte
 
 	Decorations map[string][]byte
+
+	// transient data returned by GetTransient
+	Transient map[string][]byte
+
+	// CollectionsConfig is returned by GetCollectionsConfig
+	CollectionsConfig []*common.StaticCollectionConfig
+
+	// ledgerLock guards the ledger state (State, Keys, PvtState,
+	// EndorsementPolicies and StateMetadata) and the recorded events which are shared by all
+	// transactions executing against this stub.
+	ledgerLock *sync.RWMutex
+
+	// txEvents records every event set by a transaction, keyed by txid
+	txEvents map[string][]*pb.ChaincodeEvent
+
+	// txWrites buffers the writes of the transaction in progress until
+	// MockTransactionEnd. The first map index is the collection ("" for the
+	// public state), the second map index is the key; a nil value marks a
+	// deletion.
+	txWrites map[string]map[string][]byte
+
+	// txPolicies buffers the key-level endorsement policies set by the
+	// transaction in progress, indexed like EndorsementPolicies.
+	txPolicies map[string]map[string][]byte
+
+	// txMetadata buffers the metadata entries set by the transaction in
+	// progress, indexed like StateMetadata.
+	txMetadata map[string]map[string]map[string][]byte
+
+	// rwset tracks the reads and writes of the transaction in progress
+	rwset *shim.RWSetTracker
+
+	// chaincodeEvent is the last event set by the transaction in progress
+	chaincodeEvent *pb.ChaincodeEvent
+
+	// rand is the generator returned by DeterministicRand in the
+	// transaction in progress
+	rand *rand.Rand
+
+	// observers holds the hooks registered with OnChange in the transaction
+	// in progress
+	observers *shim.ChangeObservers
 }
 
 // GetTxID ...
@@ -85,6 +145,16 @@ func (stub *MockStub) GetChannelID() string {
 	return stub.ChannelID
 }
 
+// DeterministicRand returns a generator seeded from the transaction ID. The
+// mock has no proposal nonce, so the stream matches the one of a peer only
+// for proposals with an empty nonce.
+func (stub *MockStub) DeterministicRand() *rand.Rand {
+	if stub.rand == nil {
+		stub.rand = shim.NewDeterministicRand(stub.TxID, nil)
+	}
+	return stub.rand
+}
+
 // GetArgs ...
 func (stub *MockStub) GetArgs() [][]byte {
 	return stub.args
@@ -114,19 +184,193 @@ func (stub *MockStub) GetFunctionAndParameters() (function string, params []stri
 
 // MockTransactionStart Used to indicate to a chaincode that it is part of a transaction.
 // This is important when chaincodes invoke each other.
-// MockStub doesn't support concurrent transactions at present.
+// Writes made during the transaction are buffered and only become visible
+// to other transactions once MockTransactionEnd is called. A single MockStub
+// runs one transaction at a time; use MockInit and MockInvoke to execute
+// transactions concurrently.
 func (stub *MockStub) MockTransactionStart(txid string) {
 	stub.TxID = txid
+	stub.txWrites = make(map[string]map[string][]byte)
+	stub.txPolicies = make(map[string]map[string][]byte)
+	stub.txMetadata = make(map[string]map[string]map[string][]byte)
+	stub.rwset = &shim.RWSetTracker{}
+	stub.chaincodeEvent = nil
+	stub.rand = nil
+	stub.observers = &shim.ChangeObservers{}
 	stub.setSignedProposal(&pb.SignedProposal{})
-	stub.setTxTimestamp(ptypes.TimestampNow())
+	stub.setTxTimestamp(stub.now())
 }
 
-// MockTransactionEnd End a mocked transaction, clearing the UUID.
+// MockTransactionEnd End a mocked transaction, applying its buffered writes
+// to the ledger and clearing the UUID.
 func (stub *MockStub) MockTransactionEnd(uuid string) {
+	stub.ledgerLock.Lock()
+	for collection, writes := range stub.txWrites {
+		for key, value := range writes {
+			stub.applyWrite(collection, key, value)
+		}
+	}
+	for collection, policies := range stub.txPolicies {
+		for key, ep := range policies {
+			stub.applyPolicy(collection, key, ep)
+		}
+	}
+	for collection, keys := range stub.txMetadata {
+		for key, entries := range keys {
+			for metakey, value := range entries {
+				stub.applyMetadataEntry(collection, key, metakey, value)
+			}
+		}
+	}
+	stub.ledgerLock.Unlock()
+
+	stub.txWrites = nil
+	stub.txPolicies = nil
+	stub.txMetadata = nil
+	stub.rwset = nil
 	stub.signedProposal = nil
 	stub.TxID = ""
 }
 
+// newTxStub returns a MockStub for a single transaction. It shares the
+// ledger state of stub but has its own transaction context so that several
+// transactions can run against the same ledger concurrently.
+func (stub *MockStub) newTxStub(args [][]byte) *MockStub {
+	return &MockStub{
+		args:                   args,
+		cc:                     stub.cc,
+		Name:                   stub.Name,
+		State:                  stub.State,
+		Keys:                   stub.Keys,
+		Invokables:             stub.Invokables,
+		ChannelID:              stub.ChannelID,
+		PvtState:               stub.PvtState,
+		EndorsementPolicies:    stub.EndorsementPolicies,
+		StateMetadata:          stub.StateMetadata,
+		ChaincodeEventsChannel: stub.ChaincodeEventsChannel,
+		Creator:                stub.Creator,
+		Decorations:            stub.Decorations,
+		Transient:              stub.Transient,
+		Clock:                  stub.Clock,
+		CollectionsConfig:      stub.CollectionsConfig,
+		ledgerLock:             stub.ledgerLock,
+		txEvents:               stub.txEvents,
+	}
+}
+
+// bufferWrite records a write in the transaction in progress. Outside of a
+// transaction the write is applied to the ledger directly.
+func (stub *MockStub) bufferWrite(collection, key string, value []byte) {
+	if stub.txWrites == nil {
+		stub.ledgerLock.Lock()
+		stub.applyWrite(collection, key, value)
+		stub.ledgerLock.Unlock()
+		return
+	}
+
+	writes, ok := stub.txWrites[collection]
+	if !ok {
+		writes = make(map[string][]byte)
+		stub.txWrites[collection] = writes
+	}
+	writes[key] = value
+	stub.rwset.Write(collection, key, value, value == nil)
+}
+
+// write buffers a write, a nil value marking a deletion, and calls the
+// hooks registered with OnChange.
+func (stub *MockStub) write(collection, key string, value []byte) error {
+	stub.bufferWrite(collection, key, value)
+	if stub.observers == nil {
+		return nil
+	}
+	return stub.observers.Notify(stub, shim.StateChange{
+		Collection: collection,
+		Key:        key,
+		Value:      value,
+		IsDelete:   value == nil,
+	})
+}
+
+// OnChange registers hook to be called on writes to keys starting with
+// keyPrefix during the transaction in progress.
+func (stub *MockStub) OnChange(keyPrefix string, hook shim.ChangeHook) {
+	if stub.observers == nil {
+		stub.observers = &shim.ChangeObservers{}
+	}
+	stub.observers.Register(keyPrefix, hook)
+}
+
+// bufferedWrite returns the value written to key by the transaction in
+// progress, if any.
+func (stub *MockStub) bufferedWrite(collection, key string) ([]byte, bool) {
+	value, ok := stub.txWrites[collection][key]
+	return value, ok
+}
+
+// applyWrite updates the ledger state. Deleting a key also removes its
+// key-level endorsement policy and metadata. The caller must hold the
+// ledger lock.
+func (stub *MockStub) applyWrite(collection, key string, value []byte) {
+	if value == nil {
+		delete(stub.EndorsementPolicies[collection], key)
+		delete(stub.StateMetadata[collection], key)
+	}
+
+	if collection == "" {
+		if value == nil {
+			stub.deleteKey(key)
+			return
+		}
+		stub.putKey(key, value)
+		return
+	}
+
+	m, in := stub.PvtState[collection]
+	if !in {
+		m = make(map[string][]byte)
+		stub.PvtState[collection] = m
+	}
+	if value == nil {
+		delete(m, key)
+		return
+	}
+	m[key] = value
+}
+
+// Import writes the records read from r in format, as written by
+// export.ExportNamespace, to the ledger state and returns the number of
+// records imported. The records are applied directly, outside of any
+// transaction.
+func (stub *MockStub) Import(r io.Reader, format export.Format) (int, error) {
+	dec, err := export.NewDecoder(r, format)
+	if err != nil {
+		return 0, err
+	}
+
+	n := 0
+	for {
+		kv, err := dec.Decode()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if err := validateSimpleKeys(kv.Key); err != nil {
+			return n, err
+		}
+		value := kv.Value
+		if value == nil {
+			value = []byte{}
+		}
+		stub.ledgerLock.Lock()
+		stub.applyWrite("", kv.Key, value)
+		stub.ledgerLock.Unlock()
+		n++
+	}
+}
+
 // MockPeerChaincode Register another MockStub chaincode with this MockStub.
 // invokableChaincodeName is the name of a chaincode.
 // otherStub is a MockStub of the chaincode, already initialized.
@@ -140,20 +384,23 @@ func (stub *MockStub) MockPeerChaincode(invokableChaincodeName string, otherStub
 }
 
 // MockInit Initialise this chaincode,  also starts and ends a transaction.
+// It is safe to call MockInit and MockInvoke concurrently.
 func (stub *MockStub) MockInit(uuid string, args [][]byte) pb.Response {
-	stub.args = args
-	stub.MockTransactionStart(uuid)
-	res := stub.cc.Init(stub)
-	stub.MockTransactionEnd(uuid)
+	txStub := stub.newTxStub(args)
+	txStub.MockTransactionStart(uuid)
+	res := stub.cc.Init(txStub)
+	txStub.MockTransactionEnd(uuid)
 	return res
 }
 
 // MockInvoke Invoke this chaincode, also starts and ends a transaction.
+// It is safe to call MockInvoke concurrently; each invocation gets its own
+// transaction context and its writes are applied when it completes.
 func (stub *MockStub) MockInvoke(uuid string, args [][]byte) pb.Response {
-	stub.args = args
-	stub.MockTransactionStart(uuid)
-	res := stub.cc.Invoke(stub)
-	stub.MockTransactionEnd(uuid)
+	txStub := stub.newTxStub(args)
+	txStub.MockTransactionStart(uuid)
+	res := stub.cc.Invoke(txStub)
+	txStub.MockTransactionEnd(uuid)
 	return res
 }
 
@@ -164,41 +411,65 @@ func (stub *MockStub) GetDecorations() map[string][]byte {
 
 // MockInvokeWithSignedProposal Invoke this chaincode, also starts and ends a transaction.
 func (stub *MockStub) MockInvokeWithSignedProposal(uuid string, args [][]byte, sp *pb.SignedProposal) pb.Response {
-	stub.args = args
-	stub.MockTransactionStart(uuid)
-	stub.signedProposal = sp
-	res := stub.cc.Invoke(stub)
-	stub.MockTransactionEnd(uuid)
+	txStub := stub.newTxStub(args)
+	txStub.MockTransactionStart(uuid)
+	txStub.signedProposal = sp
+	res := stub.cc.Invoke(txStub)
+	txStub.MockTransactionEnd(uuid)
 	return res
 }
 
 // GetPrivateData ...
 func (stub *MockStub) GetPrivateData(collection string, key string) ([]byte, error) {
-	m, in := stub.PvtState[collection]
+	value := stub.getState(collection, key)
+	stub.trackRead(collection, key, value)
+	return value, nil
+}
 
-	if !in {
-		return nil, nil
+// getState returns the value of key in collection as seen by the
+// transaction in progress.
+func (stub *MockStub) getState(collection, key string) []byte {
+	if value, ok := stub.bufferedWrite(collection, key); ok {
+		return value
 	}
 
-	return m[key], nil
+	stub.ledgerLock.RLock()
+	defer stub.ledgerLock.RUnlock()
+
+	if collection == "" {
+		return stub.State[key]
+	}
+	return stub.PvtState[collection][key]
 }
 
-// GetPrivateDataHash ...
+// trackRead records a read in the read/write set of the transaction in
+// progress, if any.
+func (stub *MockStub) trackRead(collection, key string, value []byte) {
+	if stub.rwset != nil {
+		stub.rwset.Read(collection, key, value)
+	}
+}
+
+// GetPrivateDataHash returns the SHA-256 hash of the value of key in
+// collection, or nil if the key does not exist.
 func (stub *MockStub) GetPrivateDataHash(collection, key string) ([]byte, error) {
-	return nil, errors.New("Not Implemented")
+	if collection == "" {
+		return nil, errors.New("collection must not be an empty string")
+	}
+	value := stub.getState(collection, key)
+	if value == nil {
+		return nil, nil
+	}
+	hash := sha256.Sum256(value)
+	return hash[:], nil
 }
 
 // PutPrivateData ...
 func (stub *MockStub) PutPrivateData(collection string, key string, value []byte) error {
-	m, in := stub.PvtState[collection]
-	if !in {
-		stub.PvtState[collection] = make(map[string][]byte)
-		m, in = stub.PvtState[collection]
+	if value == nil {
+		value = []byte{}
 	}
-
-	m[key] = value
-
-	return nil
+	return stub.write(collection, key, value)
 }
 
 // DelPrivateData ...
@@ -224,9 +495,11 @@ func (stub *MockStub) GetPrivateDataQueryResult(collection, query string) (shim.
 	return nil, errors.New("Not Implemented")
 }
 
-// GetState retrieves the value for a given key from the ledger
+// GetState retrieves the value for a given key from the ledger. Unlike a
+// real peer, writes made earlier in the same transaction are returned.
 func (stub *MockStub) GetState(key string) ([]byte, error) {
-	value := stub.State[key]
+	value := stub.getState("", key)
+	stub.trackRead("", key, value)
 	return value, nil
 }
 
@@ -241,6 +514,12 @@ func (stub *MockStub) PutState(key string, value []byte) error {
 	if len(value) == 0 {
 		return stub.DelState(key)
 	}
+	return stub.write("", key, value)
+}
+
+// putKey stores value under key in the public state and keeps Keys ordered.
+// The caller must hold the ledger lock.
+func (stub *MockStub) putKey(key string, value []byte) {
 	stub.State[key] = value
 
 	// insert key into ordered list of keys
@@ -267,21 +546,24 @@ func (stub *MockStub) PutState(key string, value []byte) error {
 	if stub.Keys.Len() == 0 {
 		stub.Keys.PushFront(key)
 	}
-
-	return nil
 }
 
 // DelState removes the specified `key` and its value from the ledger.
 func (stub *MockStub) DelState(key string) error {
+	return stub.write("", key, nil)
+}
+
+// deleteKey removes key from the public state. The caller must hold the
+// ledger lock.
+func (stub *MockStub) deleteKey(key string) {
 	delete(stub.State, key)
 
 	for elem := stub.Keys.Front(); elem != nil; elem = elem.Next() {
 		if strings.Compare(key, elem.Value.(string)) == 0 {
 			stub.Keys.Remove(elem)
+			break
 		}
 	}
-
-	return nil
 }
 
 // GetStateByRange ...
@@ -289,9 +571,36 @@ func (stub *MockStub) GetStateByRange(startKey, endKey string) (shim.StateQueryI
 	if err := validateSimpleKeys(startKey, endKey); err != nil {
 		return nil, err
 	}
+	if stub.rwset != nil {
+		stub.rwset.RangeQuery("", startKey, endKey)
+	}
+	startKey, endKey = simpleKeyRange(startKey, endKey)
 	return NewMockStateRangeQueryIterator(stub, startKey, endKey), nil
 }
 
+// simpleKeyRange returns the bounds of the range of simple keys [startKey,
+// endKey), where empty keys are unbounded. Like the peer, it excludes the
+// composite keys from ranges with an empty start key.
+func simpleKeyRange(startKey, endKey string) (string, string) {
+	if startKey == "" {
+		startKey = emptyKeySubstitute
+	}
+	if endKey == "" {
+		endKey = string(utf8.MaxRune)
+	}
+	return startKey, endKey
+}
+
+// GetStateByRangeDescending returns the keys of the range [startKey, endKey)
+// in reverse lexical order.
+func (stub *MockStub) GetStateByRangeDescending(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	iter, err := stub.GetStateByRange(startKey, endKey)
+	if err != nil {
+		return nil, err
+	}
+	return shim.ReverseStateIterator(iter, 0)
+}
+
 //To ensure that simple keys do not go into composite key namespace,
 //we validate simplekey to check whether the key starts with 0x00 (which
 //is the namespace for compositeKey). This helps in avoding simple/composite
@@ -323,6 +632,13 @@ func (stub *MockStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInt
 	return nil, errors.New("not implemented")
 }
 
+// GetHistoryForKeyRange function can be invoked by a chaincode to return a history of
+// key values within a time range.
+// This function is not implemented by MockStub.
+func (stub *MockStub) GetHistoryForKeyRange(key string, from, to time.Time) (shim.HistoryQueryIteratorInterface, error) {
+	return nil, errors.New("not implemented")
+}
+
 // GetStateByPartialCompositeKey function can be invoked by a chaincode to query the
 // state based on a given partial composite key. This function returns an
 // iterator which can be used to iterate over all composite keys whose prefix
@@ -337,6 +653,24 @@ func (stub *MockStub) GetStateByPartialCompositeKey(objectType string, attribute
 	return NewMockStateRangeQueryIterator(stub, partialCompositeKey, partialCompositeKey+string(utf8.MaxRune)), nil
 }
 
+// GetStateByCompositeKeyRange function can be invoked by a chaincode to query the
+// state for the composite keys of objectType between the composite key formed by
+// startAttrs (inclusive) and the composite key formed by endAttrs (exclusive).
+func (stub *MockStub) GetStateByCompositeKeyRange(objectType string, startAttrs, endAttrs []string) (shim.StateQueryIteratorInterface, error) {
+	startKey, err := stub.CreateCompositeKey(objectType, startAttrs)
+	if err != nil {
+		return nil, err
+	}
+	endKey, err := stub.CreateCompositeKey(objectType, endAttrs)
+	if err != nil {
+		return nil, err
+	}
+	if len(endAttrs) == 0 {
+		endKey += string(utf8.MaxRune)
+	}
+	return NewMockStateRangeQueryIterator(stub, startKey, endKey), nil
+}
+
 // CreateCompositeKey combines the list of attributes
 // to form a composite key.
 func (stub *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
@@ -361,16 +695,61 @@ func splitCompositeKey(compositeKey string) (string, []string, error) {
 	return components[0], components[1:], nil
 }
 
-// GetStateByRangeWithPagination ...
+// GetStateByRangeWithPagination returns an iterator over the page of at
+// most pageSize keys in the range [startKey, endKey) starting at bookmark.
+// The bookmark of the next page is the first key after the page, or "" if
+// the page is the last one.
 func (stub *MockStub) GetStateByRangeWithPagination(startKey, endKey string, pageSize int32,
 	bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
-	return nil, nil, nil
+	if err := validateSimpleKeys(startKey, endKey, bookmark); err != nil {
+		return nil, nil, err
+	}
+	if stub.rwset != nil {
+		stub.rwset.RangeQuery("", startKey, endKey)
+	}
+
+	pageStart, endKey := simpleKeyRange(startKey, endKey)
+	if bookmark != "" {
+		pageStart = bookmark
+	}
+	iter, metadata := stub.page(pageStart, endKey, pageSize)
+	return iter, metadata, nil
 }
 
-// GetStateByPartialCompositeKeyWithPagination ...
+// page returns an iterator over the page of at most pageSize keys in the
+// range [pageStart, endKey) and its metadata.
+func (stub *MockStub) page(pageStart, endKey string, pageSize int32) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata) {
+	next := ""
+	iter := NewMockStateRangeQueryIterator(stub, pageStart, endKey)
+	if pageSize > 0 && len(iter.keys) > int(pageSize) {
+		next = iter.keys[pageSize]
+		iter.keys = iter.keys[:pageSize]
+	}
+
+	metadata := &pb.QueryResponseMetadata{FetchedRecordsCount: int32(len(iter.keys)), Bookmark: next}
+	return iter, metadata
+}
+
+// GetStateByPartialCompositeKeyWithPagination returns an iterator over the
+// page of at most pageSize composite keys matching the partial composite
+// key starting at bookmark, like GetStateByRangeWithPagination.
 func (stub *MockStub) GetStateByPartialCompositeKeyWithPagination(objectType string, keys []string,
 	pageSize int32, bookmark string) (shim.StateQueryIteratorInterface, *pb.QueryResponseMetadata, error) {
-	return nil, nil, nil
+	partialCompositeKey, err := stub.CreateCompositeKey(objectType, keys)
+	if err != nil {
+		return nil, nil, err
+	}
+	endKey := partialCompositeKey + string(utf8.MaxRune)
+	if stub.rwset != nil {
+		stub.rwset.RangeQuery("", partialCompositeKey, endKey)
+	}
+
+	pageStart := partialCompositeKey
+	if bookmark != "" {
+		pageStart = bookmark
+	}
+	iter, metadata := stub.page(pageStart, endKey, pageSize)
+	return iter, metadata, nil
 }
 
 // GetQueryResultWithPagination ...
@@ -395,14 +774,51 @@ func (stub *MockStub) InvokeChaincode(chaincodeName string, args [][]byte, chann
 	return res
 }
 
+// InvokeChaincodeWithTransient calls a peered chaincode like InvokeChaincode,
+// making transient available to it. As with a peer, transient must be empty
+// or equal to the transient map of the calling transaction.
+func (stub *MockStub) InvokeChaincodeWithTransient(chaincodeName string, args [][]byte, channel string, transient map[string][]byte) pb.Response {
+	if err := shim.CheckTransientPropagation(stub.Transient, transient); err != nil {
+		return shim.Error(err.Error())
+	}
+	return stub.invokePeer(chaincodeName, args, channel, stub.Transient).Response
+}
+
+// InvokeChaincodeWithResult calls a peered chaincode like InvokeChaincode and
+// returns its response along with the event it set.
+func (stub *MockStub) InvokeChaincodeWithResult(chaincodeName string, args [][]byte, channel string) *shim.InvokeResult {
+	return stub.invokePeer(chaincodeName, args, channel, stub.Transient)
+}
+
+// invokePeer invokes a peered chaincode in the transaction in progress
+// with the given transient map.
+func (stub *MockStub) invokePeer(chaincodeName string, args [][]byte, channel string, transient map[string][]byte) *shim.InvokeResult {
+	// Internally we use chaincode name as a composite name
+	if channel != "" {
+		chaincodeName = chaincodeName + "/" + channel
+	}
+	otherStub := stub.Invokables[chaincodeName]
+	txStub := otherStub.newTxStub(args)
+	txStub.Transient = transient
+	txStub.MockTransactionStart(stub.TxID)
+	res := otherStub.cc.Invoke(txStub)
+	txStub.MockTransactionEnd(stub.TxID)
+	return &shim.InvokeResult{Response: res, Event: txStub.chaincodeEvent}
+}
+
 // GetCreator ...
 func (stub *MockStub) GetCreator() ([]byte, error) {
 	return stub.Creator, nil
 }
 
-// GetTransient Not implemented ...
+// GetTransient ...
 func (stub *MockStub) GetTransient() (map[string][]byte, error) {
-	return nil, nil
+	return stub.Transient, nil
+}
+
+// GetCollectionsConfig returns CollectionsConfig.
+func (stub *MockStub) GetCollectionsConfig() ([]*common.StaticCollectionConfig, error) {
+	return stub.CollectionsConfig, nil
 }
 
 // GetBinding Not implemented ...
@@ -428,6 +844,15 @@ func (stub *MockStub) setTxTimestamp(time *timestamp.Timestamp) {
 	stub.TxTimestamp = time
 }
 
+// now returns the timestamp of the clock of the stub.
+func (stub *MockStub) now() *timestamp.Timestamp {
+	if stub.Clock == nil {
+		return ptypes.TimestampNow()
+	}
+	t := stub.Clock()
+	return &timestamp.Timestamp{Seconds: t.Unix(), Nanos: int32(t.Nanosecond())}
+}
+
 // GetTxTimestamp ...
 func (stub *MockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
 	if stub.TxTimestamp == nil {
@@ -436,12 +861,49 @@ func (stub *MockStub) GetTxTimestamp() (*timestamp.Timestamp, error) {
 	return stub.TxTimestamp, nil
 }
 
+// Now returns the timestamp of the transaction.
+func (stub *MockStub) Now() (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return ptypes.Timestamp(ts)
+}
+
 // SetEvent ...
 func (stub *MockStub) SetEvent(name string, payload []byte) error {
-	stub.ChaincodeEventsChannel <- &pb.ChaincodeEvent{EventName: name, Payload: payload}
+	event := &pb.ChaincodeEvent{EventName: name, Payload: payload, TxId: stub.TxID}
+	stub.chaincodeEvent = event
+
+	stub.ledgerLock.Lock()
+	stub.txEvents[stub.TxID] = append(stub.txEvents[stub.TxID], event)
+	stub.ledgerLock.Unlock()
+
+	stub.ChaincodeEventsChannel <- event
 	return nil
 }
 
+// ChaincodeEventsForTx returns all events set by the transaction with the
+// given txid, in the order they were set. Note that a peer only retains the
+// last event set by a transaction.
+func (stub *MockStub) ChaincodeEventsForTx(txid string) []*pb.ChaincodeEvent {
+	stub.ledgerLock.RLock()
+	defer stub.ledgerLock.RUnlock()
+
+	events := make([]*pb.ChaincodeEvent, len(stub.txEvents[txid]))
+	copy(events, stub.txEvents[txid])
+	return events
+}
+
+// RWSetDigest returns a digest of the reads and writes made by the
+// transaction in progress.
+func (stub *MockStub) RWSetDigest() []byte {
+	if stub.rwset == nil {
+		return (&shim.RWSetTracker{}).Digest()
+	}
+	return stub.rwset.Digest()
+}
+
 // SetStateValidationParameter ...
 func (stub *MockStub) SetStateValidationParameter(key string, ep []byte) error {
 	return stub.SetPrivateDataValidationParameter("", key, ep)
@@ -452,20 +914,50 @@ func (stub *MockStub) GetStateValidationParameter(key string) ([]byte, error) {
 	return stub.GetPrivateDataValidationParameter("", key)
 }
 
-// SetPrivateDataValidationParameter ...
+// SetPrivateDataValidationParameter stores the key-level endorsement policy
+// for `key` in `collection`. Within a transaction the policy is buffered
+// and applied by MockTransactionEnd.
 func (stub *MockStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	if stub.txPolicies == nil {
+		stub.ledgerLock.Lock()
+		stub.applyPolicy(collection, key, ep)
+		stub.ledgerLock.Unlock()
+		return nil
+	}
+
+	policies, ok := stub.txPolicies[collection]
+	if !ok {
+		policies = make(map[string][]byte)
+		stub.txPolicies[collection] = policies
+	}
+	policies[key] = ep
+	stub.rwset.MetadataWrite(collection, key, pb.MetaDataKeys_VALIDATION_PARAMETER.String(), ep)
+	return nil
+}
+
+// applyPolicy stores a key-level endorsement policy. The caller must hold
+// the ledger lock.
+func (stub *MockStub) applyPolicy(collection, key string, ep []byte) {
 	m, in := stub.EndorsementPolicies[collection]
 	if !in {
-		stub.EndorsementPolicies[collection] = make(map[string][]byte)
-		m, in = stub.EndorsementPolicies[collection]
+		m = make(map[string][]byte)
+		stub.EndorsementPolicies[collection] = m
 	}
 
 	m[key] = ep
-	return nil
 }
 
-// GetPrivateDataValidationParameter ...
+// GetPrivateDataValidationParameter returns the key-level endorsement policy
+// for `key` in `collection`, including a policy set earlier in the same
+// transaction.
 func (stub *MockStub) GetPrivateDataValidationParameter(collection, key string) ([]byte, error) {
+	if ep, ok := stub.txPolicies[collection][key]; ok {
+		return ep, nil
+	}
+
+	stub.ledgerLock.RLock()
+	defer stub.ledgerLock.RUnlock()
+
 	m, in := stub.EndorsementPolicies[collection]
 
 	if !in {
@@ -475,6 +967,91 @@ func (stub *MockStub) GetPrivateDataValidationParameter(collection, key string)
 	return m[key], nil
 }
 
+// GetStateMetadata ...
+func (stub *MockStub) GetStateMetadata(key string) (map[string][]byte, error) {
+	return stub.GetPrivateDataMetadata("", key)
+}
+
+// SetStateMetadataEntry ...
+func (stub *MockStub) SetStateMetadataEntry(key, metakey string, value []byte) error {
+	return stub.SetPrivateDataMetadataEntry("", key, metakey, value)
+}
+
+// GetPrivateDataMetadata returns the metadata entries of `key` in
+// `collection`, including the key-level endorsement policy and the entries
+// set earlier in the same transaction.
+func (stub *MockStub) GetPrivateDataMetadata(collection, key string) (map[string][]byte, error) {
+	metadata := map[string][]byte{}
+	ep, err := stub.GetPrivateDataValidationParameter(collection, key)
+	if err != nil {
+		return nil, err
+	}
+	if ep != nil {
+		metadata[pb.MetaDataKeys_VALIDATION_PARAMETER.String()] = ep
+	}
+
+	stub.ledgerLock.RLock()
+	for metakey, value := range stub.StateMetadata[collection][key] {
+		metadata[metakey] = value
+	}
+	stub.ledgerLock.RUnlock()
+
+	for metakey, value := range stub.txMetadata[collection][key] {
+		metadata[metakey] = value
+	}
+	return metadata, nil
+}
+
+// SetPrivateDataMetadataEntry stores the metadata entry named `metakey` of
+// `key` in `collection`. The key-level endorsement policy is stored in
+// EndorsementPolicies and the other entries in StateMetadata. Within a
+// transaction the entry is buffered and applied by MockTransactionEnd.
+func (stub *MockStub) SetPrivateDataMetadataEntry(collection, key, metakey string, value []byte) error {
+	if metakey == "" {
+		return errors.New("metakey must not be an empty string")
+	}
+	if metakey == pb.MetaDataKeys_VALIDATION_PARAMETER.String() {
+		return stub.SetPrivateDataValidationParameter(collection, key, value)
+	}
+
+	if stub.txMetadata == nil {
+		stub.ledgerLock.Lock()
+		stub.applyMetadataEntry(collection, key, metakey, value)
+		stub.ledgerLock.Unlock()
+		return nil
+	}
+
+	keys, ok := stub.txMetadata[collection]
+	if !ok {
+		keys = make(map[string]map[string][]byte)
+		stub.txMetadata[collection] = keys
+	}
+	entries, ok := keys[key]
+	if !ok {
+		entries = make(map[string][]byte)
+		keys[key] = entries
+	}
+	entries[metakey] = value
+	stub.rwset.MetadataWrite(collection, key, metakey, value)
+	return nil
+}
+
+// applyMetadataEntry stores a metadata entry other than the key-level
+// endorsement policy. The caller must hold the ledger lock.
+func (stub *MockStub) applyMetadataEntry(collection, key, metakey string, value []byte) {
+	keys, in := stub.StateMetadata[collection]
+	if !in {
+		keys = make(map[string]map[string][]byte)
+		stub.StateMetadata[collection] = keys
+	}
+	entries, in := keys[key]
+	if !in {
+		entries = make(map[string][]byte)
+		keys[key] = entries
+	}
+	entries[metakey] = value
+}
+
 // NewMockStub Constructor to initialise the internal State map
 func NewMockStub(name string, cc shim.Chaincode) *MockStub {
 	s := new(MockStub)
@@ -483,10 +1060,13 @@ func NewMockStub(name string, cc shim.Chaincode) *MockStub {
 	s.State = make(map[string][]byte)
 	s.PvtState = make(map[string]map[string][]byte)
 	s.EndorsementPolicies = make(map[string]map[string][]byte)
+	s.StateMetadata = make(map[string]map[string]map[string][]byte)
 	s.Invokables = make(map[string]*MockStub)
 	s.Keys = list.New()
 	s.ChaincodeEventsChannel = make(chan *pb.ChaincodeEvent, 100) //define large capacity for non-blocking setEvent calls.
 	s.Decorations = make(map[string][]byte)
+	s.ledgerLock = &sync.RWMutex{}
+	s.txEvents = make(map[string][]*pb.ChaincodeEvent)
 
 	return s
 }
@@ -501,7 +1081,13 @@ type MockStateRangeQueryIterator struct {
 	Stub     *MockStub
 	StartKey string
 	EndKey   string
-	Current  *list.Element
+	// Current is no longer used: the keys in range are copied when the
+	// iterator is created, so that it does not hold on to the key list of
+	// the ledger while other transactions update it.
+	Current *list.Element
+
+	// keys are the keys in range not returned by Next yet.
+	keys []string
 }
 
 // HasNext returns true if the range query iterator contains additional keys
@@ -511,28 +1097,7 @@ func (iter *MockStateRangeQueryIterator) HasNext() bool {
 		// previously called Close()
 		return false
 	}
-
-	if iter.Current == nil {
-		return false
-	}
-
-	current := iter.Current
-	for current != nil {
-		// if this is an open-ended query for all keys, return true
-		if iter.StartKey == "" && iter.EndKey == "" {
-			return true
-		}
-		comp1 := strings.Compare(current.Value.(string), iter.StartKey)
-		comp2 := strings.Compare(current.Value.(string), iter.EndKey)
-		if comp1 >= 0 {
-			if comp2 < 0 {
-				return true
-			}
-			return false
-		}
-		current = current.Next()
-	}
-	return false
+	return len(iter.keys) > 0
 }
 
 // Next returns the next key and value in the range query iterator.
@@ -547,21 +1112,10 @@ func (iter *MockStateRangeQueryIterator) Next() (*queryresult.KV, error) {
 		return nil, err
 	}
 
-	for iter.Current != nil {
-		comp1 := strings.Compare(iter.Current.Value.(string), iter.StartKey)
-		comp2 := strings.Compare(iter.Current.Value.(string), iter.EndKey)
-		// compare to start and end keys. or, if this is an open-ended query for
-		// all keys, it should always return the key and value
-		if (comp1 >= 0 && comp2 < 0) || (iter.StartKey == "" && iter.EndKey == "") {
-			key := iter.Current.Value.(string)
-			value, err := iter.Stub.GetState(key)
-			iter.Current = iter.Current.Next()
-			return &queryresult.KV{Key: key, Value: value}, err
-		}
-		iter.Current = iter.Current.Next()
-	}
-	err := errors.New("MockStateRangeQueryIterator.Next() went past end of range")
-	return nil, err
+	key := iter.keys[0]
+	iter.keys = iter.keys[1:]
+	value, err := iter.Stub.GetState(key)
+	return &queryresult.KV{Key: key, Value: value}, err
 }
 
 // Close closes the range query iterator. This should be called when done
@@ -583,7 +1137,16 @@ func NewMockStateRangeQueryIterator(stub *MockStub, startKey string, endKey stri
 	iter.Stub = stub
 	iter.StartKey = startKey
 	iter.EndKey = endKey
-	iter.Current = stub.Keys.Front()
+
+	stub.ledgerLock.RLock()
+	defer stub.ledgerLock.RUnlock()
+	for elem := stub.Keys.Front(); elem != nil; elem = elem.Next() {
+		key := elem.Value.(string)
+		// an open-ended query for all keys returns every key
+		if (startKey == "" && endKey == "") || (key >= startKey && key < endKey) {
+			iter.keys = append(iter.keys, key)
+		}
+	}
 	return iter
 }
 
@@ -4,14 +4,21 @@
 package shimtest
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"reflect"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
 	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-chaincode-go/shimtest/mock"
+	"github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 //go:generate counterfeiter -o mock/chaincode.go --fake-name Chaincode . chaincode
@@ -19,6 +26,21 @@ type chaincode interface {
 	shim.Chaincode
 }
 
+//go:generate counterfeiter -o mock/peer_chaincode_stream.go --fake-name PeerChaincodeStream . peerChaincodeStream
+type peerChaincodeStream interface {
+	shim.PeerChaincodeStream
+}
+
+//go:generate counterfeiter -o mock/message_sender.go --fake-name MessageSender . messageSender
+type messageSender interface {
+	shim.MessageSender
+}
+
+//go:generate counterfeiter -o mock/response_router.go --fake-name ResponseRouter . responseRouter
+type responseRouter interface {
+	shim.ResponseRouter
+}
+
 func TestMockStateRangeQueryIterator(t *testing.T) {
 	stub := NewMockStub("rangeTest", nil)
 	stub.MockTransactionStart("init")
@@ -75,6 +97,34 @@ func TestMockStateRangeQueryIterator_openEnded(t *testing.T) {
 	}
 }
 
+func TestMockStateRangeQueryIteratorSnapshot(t *testing.T) {
+	stub := NewMockStub("rangeTest", nil)
+	stub.MockTransactionStart("init")
+	require.NoError(t, stub.PutState("1", []byte{61}))
+	require.NoError(t, stub.PutState("3", []byte{63}))
+	require.NoError(t, stub.PutState("4", []byte{64}))
+	stub.MockTransactionEnd("init")
+
+	rqi := NewMockStateRangeQueryIterator(stub, "2", "5")
+
+	// updates of the ledger made after the iterator was created do not
+	// change the keys it returns
+	stub.MockTransactionStart("update")
+	require.NoError(t, stub.DelState("3"))
+	require.NoError(t, stub.PutState("35", []byte{65}))
+	stub.MockTransactionEnd("update")
+
+	var keys []string
+	for rqi.HasNext() {
+		kv, err := rqi.Next()
+		require.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	assert.Equal(t, []string{"3", "4"}, keys)
+	_, err := rqi.Next()
+	assert.EqualError(t, err, "MockStateRangeQueryIterator.Next() called when it does not HaveNext()")
+}
+
 type Marble struct {
 	ObjectType string `json:"docType"` //docType is used to distinguish the various types of objects in state database
 	Name       string `json:"name"`    //the fieldtags are needed to keep case from bouncing around
@@ -287,3 +337,493 @@ func TestMockMock(t *testing.T) {
 	getBytes("f", []string{"a", "b"})
 	getFuncArgs([][]byte{[]byte("a")})
 }
+
+type putTxIDChaincode struct{}
+
+func (putTxIDChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (putTxIDChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	if err := stub.PutState(stub.GetTxID(), stub.GetArgs()[0]); err != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Success(nil)
+}
+
+func TestConcurrentMockInvoke(t *testing.T) {
+	stub := NewMockStub("concurrent", putTxIDChaincode{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			txid := fmt.Sprintf("tx%02d", i)
+			res := stub.MockInvoke(txid, [][]byte{[]byte(txid)})
+			assert.Equal(t, int32(shim.OK), res.Status)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Len(t, stub.State, 50)
+	assert.Equal(t, 50, stub.Keys.Len())
+	assert.Equal(t, "tx00", stub.Keys.Front().Value)
+	assert.Equal(t, "tx49", stub.Keys.Back().Value)
+}
+
+func TestMockTransactionWriteBuffering(t *testing.T) {
+	stub := NewMockStub("buffering", nil)
+	stub.MockTransactionStart("init")
+	stub.PutState("a", []byte("1"))
+	stub.MockTransactionEnd("init")
+
+	stub.MockTransactionStart("tx")
+	err := stub.PutState("b", []byte("2"))
+	assert.NoError(t, err)
+	err = stub.DelState("a")
+	assert.NoError(t, err)
+
+	// writes are visible within the transaction but not yet on the ledger
+	val, err := stub.GetState("b")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), val)
+	val, err = stub.GetState("a")
+	assert.NoError(t, err)
+	assert.Nil(t, val)
+	assert.Equal(t, map[string][]byte{"a": []byte("1")}, stub.State)
+
+	stub.MockTransactionEnd("tx")
+	assert.Equal(t, map[string][]byte{"b": []byte("2")}, stub.State)
+	assert.Equal(t, 1, stub.Keys.Len())
+}
+
+func TestStateValidationParameter(t *testing.T) {
+	stub := NewMockStub("validationParameter", nil)
+
+	ep, err := statebased.NewStateEP(nil)
+	assert.NoError(t, err)
+	err = ep.AddOrgs(statebased.RoleTypePeer, "Org1MSP", "Org2MSP")
+	assert.NoError(t, err)
+	policy, err := ep.Policy()
+	assert.NoError(t, err)
+
+	stub.MockTransactionStart("tx1")
+	stub.PutState("key", []byte("value"))
+	err = stub.SetStateValidationParameter("key", policy)
+	assert.NoError(t, err)
+	err = stub.SetPrivateDataValidationParameter("coll", "pkey", policy)
+	assert.NoError(t, err)
+
+	// visible within the transaction, applied on commit
+	got, err := stub.GetStateValidationParameter("key")
+	assert.NoError(t, err)
+	assert.Equal(t, policy, got)
+	assert.Empty(t, stub.EndorsementPolicies)
+	stub.MockTransactionEnd("tx1")
+
+	stub.MockTransactionStart("tx2")
+	got, err = stub.GetStateValidationParameter("key")
+	assert.NoError(t, err)
+	parsed, err := statebased.NewStateEP(got)
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Org1MSP", "Org2MSP"}, parsed.ListOrgs())
+
+	got, err = stub.GetPrivateDataValidationParameter("coll", "pkey")
+	assert.NoError(t, err)
+	assert.Equal(t, policy, got)
+	got, err = stub.GetStateValidationParameter("pkey")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+
+	// deleting the key removes its policy
+	stub.DelState("key")
+	stub.MockTransactionEnd("tx2")
+	got, err = stub.GetStateValidationParameter("key")
+	assert.NoError(t, err)
+	assert.Nil(t, got)
+}
+
+func TestStateMetadata(t *testing.T) {
+	stub := NewMockStub("metadata", nil)
+	vp := pb.MetaDataKeys_VALIDATION_PARAMETER.String()
+
+	stub.MockTransactionStart("tx1")
+	stub.PutState("key", []byte("value"))
+	assert.NoError(t, stub.SetStateMetadataEntry("key", "custom", []byte("a")))
+	assert.NoError(t, stub.SetStateMetadataEntry("key", vp, []byte("policy")))
+	assert.NoError(t, stub.SetPrivateDataMetadataEntry("coll", "pkey", "custom", []byte("b")))
+	assert.EqualError(t, stub.SetStateMetadataEntry("key", "", []byte("c")), "metakey must not be an empty string")
+
+	// visible within the transaction, applied on commit
+	md, err := stub.GetStateMetadata("key")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"custom": []byte("a"), vp: []byte("policy")}, md)
+	assert.Empty(t, stub.StateMetadata)
+	stub.MockTransactionEnd("tx1")
+
+	assert.Equal(t, map[string][]byte{"custom": []byte("a")}, stub.StateMetadata[""]["key"])
+	assert.Equal(t, []byte("policy"), stub.EndorsementPolicies[""]["key"])
+	md, err = stub.GetPrivateDataMetadata("coll", "pkey")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]byte{"custom": []byte("b")}, md)
+
+	// deleting the key removes its metadata
+	stub.MockTransactionStart("tx2")
+	stub.DelState("key")
+	stub.MockTransactionEnd("tx2")
+	md, err = stub.GetStateMetadata("key")
+	assert.NoError(t, err)
+	assert.Empty(t, md)
+}
+
+func TestMockStubRWSetDigest(t *testing.T) {
+	stub := NewMockStub("rwsetDigestTest", nil)
+	stub.MockTransactionStart("init")
+	stub.PutState("a", []byte("1"))
+	stub.MockTransactionEnd("init")
+
+	stub.MockTransactionStart("tx1")
+	stub.GetState("a")
+	stub.PutState("b", []byte("2"))
+	stub.DelState("a")
+	stub.SetStateValidationParameter("b", []byte("policy"))
+	digest := stub.RWSetDigest()
+	stub.MockTransactionEnd("tx1")
+
+	expected := &shim.RWSetTracker{}
+	expected.Read("", "a", []byte("1"))
+	expected.Write("", "b", []byte("2"), false)
+	expected.Write("", "a", nil, true)
+	expected.MetadataWrite("", "b", pb.MetaDataKeys_VALIDATION_PARAMETER.String(), []byte("policy"))
+	assert.Equal(t, expected.Digest(), digest)
+
+	assert.Equal(t, (&shim.RWSetTracker{}).Digest(), stub.RWSetDigest())
+}
+
+func TestGetStateByCompositeKeyRange(t *testing.T) {
+	stub := NewMockStub("GetStateByCompositeKeyRangeTest", nil)
+	stub.MockTransactionStart("init")
+	for _, attrs := range [][]string{
+		{"alice", "2020-01-01"},
+		{"alice", "2020-02-01"},
+		{"alice", "2020-03-01"},
+		{"alice", "2020-03-01", "2"},
+		{"bob", "2020-02-01"},
+	} {
+		key, _ := stub.CreateCompositeKey("order", attrs)
+		stub.PutState(key, []byte("value"))
+	}
+	stub.MockTransactionEnd("init")
+
+	var tests = []struct {
+		startAttrs []string
+		endAttrs   []string
+		expected   [][]string
+	}{
+		{
+			startAttrs: []string{"alice", "2020-02-01"},
+			endAttrs:   []string{"alice", "2020-03-01"},
+			expected:   [][]string{{"alice", "2020-02-01"}},
+		},
+		{
+			startAttrs: []string{"alice", "2020-03-01"},
+			endAttrs:   []string{"bob"},
+			expected:   [][]string{{"alice", "2020-03-01"}, {"alice", "2020-03-01", "2"}},
+		},
+		{
+			startAttrs: []string{"alice", "2020-03-01"},
+			expected:   [][]string{{"alice", "2020-03-01"}, {"alice", "2020-03-01", "2"}, {"bob", "2020-02-01"}},
+		},
+		{
+			endAttrs: []string{"alice", "2020-02-01"},
+			expected: [][]string{{"alice", "2020-01-01"}},
+		},
+	}
+
+	for _, test := range tests {
+		iter, err := stub.GetStateByCompositeKeyRange("order", test.startAttrs, test.endAttrs)
+		assert.NoError(t, err)
+		var actual [][]string
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			assert.NoError(t, err)
+			_, attrs, _ := stub.SplitCompositeKey(kv.Key)
+			actual = append(actual, attrs)
+		}
+		iter.Close()
+		assert.Equal(t, test.expected, actual)
+	}
+}
+
+// transientEchoChaincode returns the transient value named by its first
+// argument.
+type transientEchoChaincode struct{}
+
+func (transientEchoChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (transientEchoChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	transient, _ := stub.GetTransient()
+	return shim.Success(transient[string(stub.GetArgs()[0])])
+}
+
+func TestInvokeChaincodeWithTransient(t *testing.T) {
+	callee := NewMockStub("callee", transientEchoChaincode{})
+	callee.Transient = map[string][]byte{"secret": []byte("callee")}
+
+	caller := NewMockStub("caller", nil)
+	caller.Transient = map[string][]byte{"secret": []byte("caller")}
+	caller.MockPeerChaincode("callee", callee, "")
+
+	caller.MockTransactionStart("tx1")
+	resp := caller.InvokeChaincodeWithTransient("callee", [][]byte{[]byte("secret")}, "", caller.Transient)
+	assert.Equal(t, int32(shim.OK), resp.Status)
+	assert.Equal(t, []byte("caller"), resp.Payload)
+
+	resp = caller.InvokeChaincodeWithTransient("callee", [][]byte{[]byte("secret")}, "", map[string][]byte{"secret": []byte("other")})
+	assert.Equal(t, int32(shim.ERROR), resp.Status)
+	assert.Equal(t, shim.ErrTransientPropagation.Error(), resp.Message)
+	caller.MockTransactionEnd("tx1")
+}
+
+// eventChaincode sets an event named by its first argument.
+type eventChaincode struct{}
+
+func (eventChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return shim.Success(nil)
+}
+
+func (eventChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	stub.SetEvent(string(stub.GetArgs()[0]), nil)
+	return shim.Success([]byte(`{"ok":true}`))
+}
+
+func TestInvokeChaincodeWithResult(t *testing.T) {
+	callee := NewMockStub("callee", eventChaincode{})
+	caller := NewMockStub("caller", nil)
+	caller.MockPeerChaincode("callee", callee, "")
+
+	caller.MockTransactionStart("tx1")
+	result := caller.InvokeChaincodeWithResult("callee", [][]byte{[]byte("done")}, "")
+	caller.MockTransactionEnd("tx1")
+
+	assert.True(t, result.IsOK())
+	assert.Equal(t, "done", result.Event.EventName)
+	var out struct{ OK bool }
+	result.MustPayloadJSON(&out)
+	assert.True(t, out.OK)
+}
+
+func TestGetPrivateDataHash(t *testing.T) {
+	stub := NewMockStub("GetPrivateDataHash", nil)
+	stub.MockTransactionStart("init")
+	stub.PutPrivateData("col", "key", []byte("value"))
+	stub.MockTransactionEnd("init")
+
+	hash, err := stub.GetPrivateDataHash("col", "key")
+	assert.NoError(t, err)
+	expected := sha256.Sum256([]byte("value"))
+	assert.Equal(t, expected[:], hash)
+
+	hash, err = stub.GetPrivateDataHash("col", "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, hash)
+
+	_, err = stub.GetPrivateDataHash("", "key")
+	assert.EqualError(t, err, "collection must not be an empty string")
+}
+
+func TestMockStubDeterministicRand(t *testing.T) {
+	stub := NewMockStub("DeterministicRand", nil)
+	stub.MockTransactionStart("tx1")
+	r := stub.DeterministicRand()
+	assert.Same(t, r, stub.DeterministicRand())
+	first := r.Int63()
+	stub.MockTransactionEnd("tx1")
+
+	stub.MockTransactionStart("tx1")
+	assert.Equal(t, first, stub.DeterministicRand().Int63(), "expected the same stream for the same txid")
+	stub.MockTransactionEnd("tx1")
+
+	stub.MockTransactionStart("tx2")
+	assert.NotEqual(t, first, stub.DeterministicRand().Int63())
+	stub.MockTransactionEnd("tx2")
+}
+
+func TestMockStubOnChange(t *testing.T) {
+	stub := NewMockStub("OnChange", nil)
+	stub.MockTransactionStart("tx1")
+	// chaincode registers hooks through a type assertion
+	var cc shim.ChaincodeStubInterface = stub
+	observable, ok := cc.(interface {
+		OnChange(keyPrefix string, hook shim.ChangeHook)
+	})
+	require.True(t, ok)
+	observable.OnChange("asset", func(stub shim.ChaincodeStubInterface, change shim.StateChange) error {
+		index := "index~" + change.Key
+		if change.IsDelete {
+			return stub.DelState(index)
+		}
+		return stub.PutState(index, []byte{0x00})
+	})
+
+	assert.NoError(t, stub.PutState("asset1", []byte("value")))
+	assert.NoError(t, stub.PutState("other", []byte("value")))
+	assert.NoError(t, stub.PutState("asset2", []byte("value")))
+	assert.NoError(t, stub.DelState("asset2"))
+	stub.MockTransactionEnd("tx1")
+
+	assert.Equal(t, []byte{0x00}, stub.State["index~asset1"])
+	assert.NotContains(t, stub.State, "index~other")
+	assert.NotContains(t, stub.State, "index~asset2")
+
+	// hooks do not outlive the transaction
+	stub.MockTransactionStart("tx2")
+	assert.NoError(t, stub.PutState("asset3", []byte("value")))
+	stub.MockTransactionEnd("tx2")
+	assert.NotContains(t, stub.State, "index~asset3")
+}
+
+func TestGetStateByRangeWithPagination(t *testing.T) {
+	stub := NewMockStub("pagination", nil)
+	stub.MockTransactionStart("init")
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		assert.NoError(t, stub.PutState(key, []byte(key)))
+	}
+	stub.MockTransactionEnd("init")
+
+	var keys []string
+	bookmark := ""
+	for {
+		iter, metadata, err := stub.GetStateByRangeWithPagination("b", "", 2, bookmark)
+		assert.NoError(t, err)
+		var page []string
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			assert.NoError(t, err)
+			page = append(page, kv.Key)
+		}
+		assert.Equal(t, int32(len(page)), metadata.FetchedRecordsCount)
+		keys = append(keys, page...)
+		if metadata.Bookmark == "" {
+			break
+		}
+		bookmark = metadata.Bookmark
+	}
+	assert.Equal(t, []string{"b", "c", "d", "e"}, keys)
+}
+
+func TestGetStateByPartialCompositeKeyWithPagination(t *testing.T) {
+	stub := NewMockStub("pagination", nil)
+	stub.MockTransactionStart("init")
+	for _, attrs := range [][]string{{"a", "1"}, {"b", "1"}, {"b", "2"}, {"b", "3"}, {"c", "1"}} {
+		key, err := stub.CreateCompositeKey("obj", attrs)
+		assert.NoError(t, err)
+		assert.NoError(t, stub.PutState(key, []byte(key)))
+	}
+	stub.MockTransactionEnd("init")
+
+	var keys [][]string
+	bookmark := ""
+	for {
+		iter, metadata, err := stub.GetStateByPartialCompositeKeyWithPagination("obj", []string{"b"}, 2, bookmark)
+		assert.NoError(t, err)
+		var count int32
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			assert.NoError(t, err)
+			_, attrs, err := stub.SplitCompositeKey(kv.Key)
+			assert.NoError(t, err)
+			keys = append(keys, attrs)
+			count++
+		}
+		assert.Equal(t, count, metadata.FetchedRecordsCount)
+		if metadata.Bookmark == "" {
+			break
+		}
+		bookmark = metadata.Bookmark
+	}
+	assert.Equal(t, [][]string{{"b", "1"}, {"b", "2"}, {"b", "3"}}, keys)
+}
+
+func TestGetStateByRangeDescending(t *testing.T) {
+	stub := NewMockStub("descending", nil)
+	stub.MockTransactionStart("init")
+	for _, key := range []string{"a", "b", "c", "d"} {
+		assert.NoError(t, stub.PutState(key, []byte(key)))
+	}
+	stub.MockTransactionEnd("init")
+
+	iter, err := stub.GetStateByRangeDescending("b", "d")
+	assert.NoError(t, err)
+	var keys []string
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		assert.NoError(t, err)
+		keys = append(keys, kv.Key)
+	}
+	assert.Equal(t, []string{"c", "b"}, keys)
+}
+
+func TestMockStubClock(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	stub := NewStubBuilder().WithClock(func() time.Time { return now }).Build()
+
+	stub.MockTransactionStart("tx1")
+	ts, err := stub.Now()
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(ts), "expected %s, got %s", now, ts)
+	stub.MockTransactionEnd("tx1")
+
+	now = now.Add(time.Hour)
+	stub.MockTransactionStart("tx2")
+	ts, err = stub.Now()
+	assert.NoError(t, err)
+	assert.True(t, now.Equal(ts), "the clock should be read when the transaction starts")
+	stub.MockTransactionEnd("tx2")
+
+	stub.TxTimestamp = nil
+	_, err = stub.Now()
+	assert.EqualError(t, err, "TxTimestamp not set")
+}
+
+func TestMockStubClockMockInvoke(t *testing.T) {
+	now := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	var seen time.Time
+	cc := &mock.Chaincode{}
+	cc.InvokeStub = func(stub shim.ChaincodeStubInterface) pb.Response {
+		ts, err := stub.(*MockStub).Now()
+		if err != nil {
+			return shim.Error(err.Error())
+		}
+		seen = ts
+		return shim.Success(nil)
+	}
+	cc.InitStub = cc.InvokeStub
+	stub := NewStubBuilder().WithChaincode(cc).WithClock(func() time.Time { return now }).Build()
+
+	res := stub.MockInvoke("tx1", nil)
+	assert.Equal(t, int32(shim.OK), res.Status, res.Message)
+	assert.True(t, now.Equal(seen), "expected %s, got %s", now, seen)
+
+	now = now.Add(time.Hour)
+	res = stub.MockInit("tx2", nil)
+	assert.Equal(t, int32(shim.OK), res.Status, res.Message)
+	assert.True(t, now.Equal(seen), "expected %s, got %s", now, seen)
+}
+
+func TestMockStubCollectionsConfigMockInvoke(t *testing.T) {
+	var seen []*common.StaticCollectionConfig
+	cc := &mock.Chaincode{}
+	cc.InvokeStub = func(stub shim.ChaincodeStubInterface) pb.Response {
+		seen, _ = stub.GetCollectionsConfig()
+		return shim.Success(nil)
+	}
+	stub := NewMockStub("collections", cc)
+	stub.CollectionsConfig = []*common.StaticCollectionConfig{{Name: "private"}}
+
+	stub.MockInvoke("tx1", nil)
+	assert.Equal(t, stub.CollectionsConfig, seen)
+}
@@ -0,0 +1,42 @@
+// Copyright the Hyperledger Fabric contributors. All rights reserved.
+// SPDX-License-Identifier: Apache-2.0
+
+package shimtest
+
+import (
+	"io"
+	"testing"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest/mock"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	_ shim.PeerChaincodeStream = &mock.PeerChaincodeStream{}
+	_ shim.MessageSender       = &mock.MessageSender{}
+	_ shim.ResponseRouter      = &mock.ResponseRouter{}
+)
+
+func TestStartInProcWithMocks(t *testing.T) {
+	stream := &mock.PeerChaincodeStream{}
+	stream.RecvReturnsOnCall(0, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTERED}, nil)
+	stream.RecvReturnsOnCall(1, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_READY}, nil)
+	stream.RecvReturnsOnCall(2, &pb.ChaincodeMessage{Type: pb.ChaincodeMessage_RESPONSE, Txid: "tx1", ChannelId: "ch1"}, nil)
+	stream.RecvReturns(nil, io.EOF)
+	router := &mock.ResponseRouter{}
+
+	err := shim.StartInProc("cc", stream, &mock.Chaincode{}, shim.WithResponseRouter(router))
+	assert.EqualError(t, err, "received EOF, ending chaincode stream")
+
+	assert.Equal(t, 1, stream.SendCallCount())
+	assert.Equal(t, pb.ChaincodeMessage_REGISTER, stream.SendArgsForCall(0).Type)
+	assert.Equal(t, 1, stream.CloseSendCallCount())
+	if assert.Equal(t, 1, router.RouteCallCount()) {
+		assert.Equal(t, "tx1", router.RouteArgsForCall(0).Txid)
+	}
+
+	err = shim.StartInProc("cc", stream, &mock.Chaincode{}, shim.WithResponseRouter(nil))
+	assert.EqualError(t, err, "invalid shim option: response router must not be nil")
+}